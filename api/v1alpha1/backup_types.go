@@ -0,0 +1,36 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// RestoreInstanceUUIDAnnotation is the annotation on a VirtualMachine whose value is the instance
+	// UUID of an existing vSphere VM. When the VMServiceBackupRestore feature is enabled, a VirtualMachine
+	// that does not have a vSphere VM adopts the vSphere VM with this instance UUID instead of
+	// creating a new one. This is used to rebuild a VirtualMachine after its vSphere VM was restored.
+	// Only privileged users can set the annotation, and the vSphere VM is only adopted if its backup is
+	// of a VirtualMachine with the same namespace and name.
+	RestoreInstanceUUIDAnnotation = "vmoperator.vmware.com/restore-instance-uuid"
+
+	// RestoreBiosUUIDAnnotation is like RestoreInstanceUUIDAnnotation but identifies the vSphere VM
+	// by its BIOS UUID. The instance UUID is used when both annotations are set.
+	RestoreBiosUUIDAnnotation = "vmoperator.vmware.com/restore-bios-uuid"
+)
+
+// PVCDiskData describes a PVC that is bound to a disk of the VM. A list of PVCDiskData is stored,
+// as JSON, in the vSphere VM's ExtraConfig so the PVCs can be rebuilt after the VM is restored.
+type PVCDiskData struct {
+	// DiskUUID is the UUID of the disk of the VM.
+	DiskUUID string `json:"diskUUID"`
+
+	// PVCName is the name of the PVC bound to the disk.
+	PVCName string `json:"pvcName"`
+
+	// AccessModes are the access modes of the PVC.
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+}
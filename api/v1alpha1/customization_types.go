@@ -0,0 +1,16 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+const (
+	// CustomizationRequestedAtAnnotation is the annotation on a VirtualMachine with the RFC 3339 time at
+	// which the guest customization of the VM was last requested. The time, or the last boot of the VM when
+	// it is later, is compared with the CustomizationPendingTimeout to detect a pending customization that the
+	// powered on guest never ran.
+	CustomizationRequestedAtAnnotation = "vmoperator.vmware.com/customization-requested-at"
+
+	// CustomizationAttemptsAnnotation is the annotation on a VirtualMachine with the number of times the
+	// guest customization of the VM was requested.
+	CustomizationAttemptsAnnotation = "vmoperator.vmware.com/customization-attempts"
+)
@@ -0,0 +1,10 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package v1alpha1 contains the vmoperator.vmware.com/v1alpha1 API types that are
+// owned by this repository. These complement the types that are vendored from
+// vm-operator-api: either new kinds, or structured payloads that are carried in
+// annotations on the existing kinds until they are promoted to first class fields.
+// +kubebuilder:object:generate=true
+// +groupName=vmoperator.vmware.com
+package v1alpha1
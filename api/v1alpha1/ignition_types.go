@@ -0,0 +1,57 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+const (
+	// VirtualMachineMetadataIgnitionTransport is the metadata transport of Ignition based images, such as
+	// Fedora CoreOS and Flatcar Container Linux. The Ignition config in the IgnitionConfigKey key of the
+	// VM metadata is merged with the VM's network configuration, and delivered to the guest in the
+	// guestinfo.ignition.config.data ExtraConfig key. The transport is not one of the transports of the
+	// VirtualMachine API, so it is set in the VirtualMachineMetadataTransportAnnotation.
+	VirtualMachineMetadataIgnitionTransport vmopv1alpha1.VirtualMachineMetadataTransport = "Ignition"
+
+	// VirtualMachineMetadataTransportAnnotation is the annotation on a VirtualMachine with a metadata transport
+	// that is not one of the transports of spec.vmMetadata.transport, which overrides that transport. The only
+	// supported value is VirtualMachineMetadataIgnitionTransport, which requires the ExtraConfig transport in
+	// spec.vmMetadata since the Ignition config is delivered in ExtraConfig.
+	VirtualMachineMetadataTransportAnnotation = "vmoperator.vmware.com/metadata-transport"
+
+	// IgnitionConfigKey is the key of the VM metadata with the JSON Ignition config of the VM.
+	IgnitionConfigKey = "ignition"
+)
+
+// Conditions and condition Reasons for the VirtualMachine object.
+
+const (
+	// VirtualMachineIgnitionConfigReadyCondition documents that the Ignition config of a VM using the
+	// Ignition metadata transport was delivered to the guest.
+	VirtualMachineIgnitionConfigReadyCondition vmopv1alpha1.ConditionType = "IgnitionConfigReady"
+
+	// VirtualMachineIgnitionConfigInvalidReason (Severity=Error) documents that the Ignition config in the
+	// VM metadata is not a valid Ignition config.
+	VirtualMachineIgnitionConfigInvalidReason = "IgnitionConfigInvalid"
+
+	// VirtualMachineIgnitionConfigTooLargeReason (Severity=Error) documents that the encoded Ignition config
+	// exceeds the maximum size of a guestinfo ExtraConfig value.
+	VirtualMachineIgnitionConfigTooLargeReason = "IgnitionConfigTooLarge"
+)
+
+// GetVirtualMachineMetadataTransport returns the metadata transport of the VM, which is the transport in the
+// VirtualMachineMetadataTransportAnnotation when the VM has the annotation, or else the transport of its
+// spec.vmMetadata. It returns an empty transport when the VM does not have metadata.
+func GetVirtualMachineMetadataTransport(vm *vmopv1alpha1.VirtualMachine) vmopv1alpha1.VirtualMachineMetadataTransport {
+	if vm.Spec.VmMetadata == nil {
+		return ""
+	}
+
+	if transport, ok := vm.Annotations[VirtualMachineMetadataTransportAnnotation]; ok {
+		return vmopv1alpha1.VirtualMachineMetadataTransport(transport)
+	}
+
+	return vm.Spec.VmMetadata.Transport
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+const (
+	// VirtualMachineImageCompatibilityReportAnnotation is the annotation on a VirtualMachineImage with the JSON
+	// VirtualMachineImageCompatibilityReport of the image. The reasons the image is not supported are also in
+	// the message of the VirtualMachineImageV1Alpha1Compatible condition of the image.
+	VirtualMachineImageCompatibilityReportAnnotation = "vmoperator.vmware.com/compatibility-report"
+)
+
+// Guest OS families of a VirtualMachineImageCompatibilityReport.
+const (
+	GuestOSFamilyLinux   = "Linux"
+	GuestOSFamilyWindows = "Windows"
+	GuestOSFamilyOther   = "Other"
+)
+
+// VirtualMachineImageCompatibilityReport describes how the OVF of a VirtualMachineImage is compatible with
+// VM Operator.
+type VirtualMachineImageCompatibilityReport struct {
+	// Supported is true if VirtualMachines can be deployed from the image.
+	Supported bool `json:"supported"`
+
+	// Reasons are the reasons the image is not supported.
+	// +optional
+	Reasons []string `json:"reasons,omitempty"`
+
+	// V1Alpha1Compatible is true if the OVF disables cloud-init on first boot, as required by the v1alpha1
+	// OvfEnv and ExtraConfig metadata transports.
+	V1Alpha1Compatible bool `json:"v1alpha1Compatible"`
+
+	// TKGImage is true if the image is a Tanzu Kubernetes Grid node image.
+	TKGImage bool `json:"tkgImage"`
+
+	// HardwareVersion is the virtual hardware version of the image.
+	// +optional
+	HardwareVersion int32 `json:"hardwareVersion,omitempty"`
+
+	// MinPersistentVolumeHardwareVersion is the minimum hardware version of a VM with PersistentVolumes.
+	MinPersistentVolumeHardwareVersion int32 `json:"minPersistentVolumeHardwareVersion"`
+
+	// PersistentVolumesSupported is true if the hardware version of the image supports PersistentVolumes.
+	PersistentVolumesSupported bool `json:"persistentVolumesSupported"`
+
+	// Firmware is the firmware of the image, bios or efi.
+	// +optional
+	Firmware string `json:"firmware,omitempty"`
+
+	// Transports are the metadata transports that the image supports.
+	// +optional
+	Transports []vmopv1alpha1.VirtualMachineMetadataTransport `json:"transports,omitempty"`
+
+	// DiskCount is the number of disks of the image.
+	DiskCount int `json:"diskCount"`
+
+	// DiskCapacityBytes is the total capacity of the disks of the image.
+	// +optional
+	DiskCapacityBytes int64 `json:"diskCapacityBytes,omitempty"`
+
+	// GuestOSType is the vSphere guest OS identifier of the image.
+	// +optional
+	GuestOSType string `json:"guestOSType,omitempty"`
+
+	// GuestOSFamily is the family of the guest OS of the image: Linux, Windows or Other.
+	// +optional
+	GuestOSFamily string `json:"guestOSFamily,omitempty"`
+}
+
+// GetImageCompatibilityReport returns the VirtualMachineImageCompatibilityReport of the image, or nil if
+// the image does not have a report.
+func GetImageCompatibilityReport(image metav1.Object) (*VirtualMachineImageCompatibilityReport, error) {
+	data, ok := image.GetAnnotations()[VirtualMachineImageCompatibilityReportAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	report := &VirtualMachineImageCompatibilityReport{}
+	if err := json.Unmarshal([]byte(data), report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+const (
+	// VirtualMachineImageFamilyLabel is the label of a VirtualMachineImage with the family of the image, whose
+	// newest versions are kept by the image retention. The family of an image without the label is the vendor
+	// and product of the OVF of the image, or the name of the image when the OVF does not have a product.
+	VirtualMachineImageFamilyLabel = "vmoperator.vmware.com/image-family"
+
+	// VirtualMachineImageUsageCountAnnotation is the annotation of a VirtualMachineImage with the number of
+	// VirtualMachines that reference the image.
+	VirtualMachineImageUsageCountAnnotation = "vmoperator.vmware.com/usage-count"
+
+	// VirtualMachineImageUnusedSinceAnnotation is the annotation of a VirtualMachineImage with the RFC 3339
+	// time since which the image is unused and is not one of the newest versions of its family. The library
+	// item of the image is deleted once the retention grace period has passed since that time.
+	VirtualMachineImageUnusedSinceAnnotation = "vmoperator.vmware.com/unused-since"
+
+	// VirtualMachineImageItemCreationTimeAnnotation is the annotation of a VirtualMachineImage with the RFC 3339
+	// creation time of its content library item, which orders the versions of an image family. Images without
+	// the annotation are never garbage collected.
+	VirtualMachineImageItemCreationTimeAnnotation = "vmoperator.vmware.com/library-item-creation-time"
+
+	// ContentSourceImageRetentionAnnotation is the annotation of a ContentSource that opts the images of its
+	// content library into the image retention when set to "true". Only the images of writable libraries are
+	// garbage collected, so the annotation is ignored on the ContentSources of subscribed and TKG libraries.
+	ContentSourceImageRetentionAnnotation = "vmoperator.vmware.com/image-retention"
+)
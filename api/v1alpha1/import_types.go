@@ -0,0 +1,28 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ImportVMMoIDAnnotation is the annotation on a VirtualMachine whose value is the managed object ID
+	// of an existing vSphere VM that is not managed by VM Operator. Instead of creating a new vSphere VM,
+	// the existing VM is relocated into the namespace's ResourcePool and Folder, renamed to the name of
+	// the VirtualMachine, and then managed like any other VM. The VirtualMachine's class may be empty, in
+	// which case it is set to a class with the same CPU and memory as the vSphere VM, and its image may be
+	// empty. Only privileged users can set the annotation.
+	ImportVMMoIDAnnotation = "vmoperator.vmware.com/import-vm-moid"
+
+	// ImportVMInstanceUUIDAnnotation is like ImportVMMoIDAnnotation but identifies the vSphere VM by its
+	// instance UUID. When both annotations are set, they must identify the same vSphere VM.
+	ImportVMInstanceUUIDAnnotation = "vmoperator.vmware.com/import-vm-instance-uuid"
+)
+
+// IsImportedVM returns true if the VirtualMachine imports an existing vSphere VM.
+func IsImportedVM(obj metav1.Object) bool {
+	annotations := obj.GetAnnotations()
+	return annotations[ImportVMMoIDAnnotation] != "" || annotations[ImportVMInstanceUUIDAnnotation] != ""
+}
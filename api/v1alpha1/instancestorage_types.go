@@ -0,0 +1,229 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+const (
+	// InstanceStoragePlacementDiagnosticsAnnotation is the annotation on a VirtualMachine with the JSON
+	// InstanceStoragePlacementDiagnostics of the placement of its instance storage volumes.
+	InstanceStoragePlacementDiagnosticsAnnotation = "vmoperator.vmware.com/instance-storage-placement-diagnostics"
+
+	// InstanceStorageReplaceAnnotation is the annotation that requests that the instance storage volumes of a
+	// powered off VirtualMachine are recreated, like when their host enters maintenance mode. The PVCs and the
+	// attachments of the volumes are deleted, which deletes the data of the volumes, and the volumes are placed
+	// again once a new host is selected. The value of the annotation is the reason of the replacement. The replaced
+	// host is excluded from the next placements for the InstanceStorageHostExclusionDuration in the
+	// InstanceStoragePlacementDiagnostics. The annotation is removed once the volumes are deleted.
+	InstanceStorageReplaceAnnotation = "vmoperator.vmware.com/instance-storage-replace"
+
+	// InstanceStoragePlacementMaxAttempts is the maximum number of attempts in the
+	// InstanceStoragePlacementDiagnostics.
+	InstanceStoragePlacementMaxAttempts = 10
+
+	// InstanceStorageHostExclusionDuration is how long a host the instance storage volumes were replaced on is
+	// excluded from the placement of the volumes, which is long enough for the maintenance of the host.
+	InstanceStorageHostExclusionDuration = 24 * time.Hour
+)
+
+// InstanceStoragePlacementResult is the result of an attempt to place the instance storage volumes on a host.
+type InstanceStoragePlacementResult string
+
+const (
+	// InstanceStoragePlacementPending is the result of an attempt whose volumes are not bound yet.
+	InstanceStoragePlacementPending InstanceStoragePlacementResult = "Pending"
+	// InstanceStoragePlacementPlaced is the result of an attempt whose volumes are all bound.
+	InstanceStoragePlacementPlaced InstanceStoragePlacementResult = "Placed"
+	// InstanceStoragePlacementFailed is the result of an attempt for which the storage provider could not
+	// place a volume on the host.
+	InstanceStoragePlacementFailed InstanceStoragePlacementResult = "Failed"
+	// InstanceStoragePlacementReplaced is the result of an attempt whose volumes were deleted by the
+	// InstanceStorageReplaceAnnotation.
+	InstanceStoragePlacementReplaced InstanceStoragePlacementResult = "Replaced"
+	// InstanceStoragePlacementExcluded is the result of an attempt whose host was rejected before any volume was
+	// placed on it, since the host is excluded.
+	InstanceStoragePlacementExcluded InstanceStoragePlacementResult = "Excluded"
+)
+
+// InstanceStoragePlacementDiagnostics is the history of the attempts to place the instance storage volumes of a
+// VirtualMachine.
+type InstanceStoragePlacementDiagnostics struct {
+	// Attempts are the most recent attempts, oldest first. Each attempt is a host that was considered for the
+	// volumes, with the datastores of the host the volumes were placed on and why the host was rejected.
+	// +optional
+	Attempts []InstanceStoragePlacementAttempt `json:"attempts,omitempty"`
+
+	// ExcludedHosts are the hosts the volumes must not be placed on again until the exclusion expires, since the
+	// volumes were replaced on them, like when the host entered maintenance mode. The excluded hosts are not
+	// candidates of the placement of the VM, and an excluded host that is selected anyway is rejected.
+	// +optional
+	ExcludedHosts []InstanceStorageExcludedHost `json:"excludedHosts,omitempty"`
+}
+
+// IsHostExcluded returns whether the host is one of the ExcludedHosts whose exclusion has not expired.
+func (d *InstanceStoragePlacementDiagnostics) IsHostExcluded(host string, now time.Time) bool {
+	return d.GetExcludedHost(host, now) != nil
+}
+
+// GetExcludedHost returns the exclusion of the host that has not expired, or nil if the host is not excluded.
+func (d *InstanceStoragePlacementDiagnostics) GetExcludedHost(host string, now time.Time) *InstanceStorageExcludedHost {
+	if d == nil {
+		return nil
+	}
+	for i := range d.ExcludedHosts {
+		if h := &d.ExcludedHosts[i]; h.Host == host && now.Before(h.ExpirationTime.Time) {
+			return h
+		}
+	}
+	return nil
+}
+
+// ExcludeHost excludes the host from the placement for the InstanceStorageHostExclusionDuration, replacing any
+// previous exclusion of the host.
+func (d *InstanceStoragePlacementDiagnostics) ExcludeHost(host, reason string, now time.Time) {
+	exclusion := InstanceStorageExcludedHost{
+		Host:           host,
+		Reason:         reason,
+		ExpirationTime: metav1.NewTime(now.Add(InstanceStorageHostExclusionDuration)),
+	}
+	for i := range d.ExcludedHosts {
+		if d.ExcludedHosts[i].Host == host {
+			d.ExcludedHosts[i] = exclusion
+			return
+		}
+	}
+	d.ExcludedHosts = append(d.ExcludedHosts, exclusion)
+}
+
+// PruneExcludedHosts removes the ExcludedHosts whose exclusion has expired.
+func (d *InstanceStoragePlacementDiagnostics) PruneExcludedHosts(now time.Time) {
+	excludedHosts := d.ExcludedHosts[:0]
+	for _, h := range d.ExcludedHosts {
+		if now.Before(h.ExpirationTime.Time) {
+			excludedHosts = append(excludedHosts, h)
+		}
+	}
+	if len(excludedHosts) == 0 {
+		excludedHosts = nil
+	}
+	d.ExcludedHosts = excludedHosts
+}
+
+// InstanceStorageExcludedHost is a host the instance storage volumes must not be placed on.
+type InstanceStorageExcludedHost struct {
+	// Host is the excluded host.
+	Host string `json:"host"`
+
+	// Reason is why the host is excluded, like the reason of the replacement of the volumes.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// ExpirationTime is when the host is no longer excluded.
+	ExpirationTime metav1.Time `json:"expirationTime"`
+}
+
+// InstanceStoragePlacementAttempt is an attempt to place the instance storage volumes on a host.
+type InstanceStoragePlacementAttempt struct {
+	// Host is the host the volumes were placed on.
+	Host string `json:"host"`
+
+	// StartTime is when the host was selected, or when the PVCs of the volumes were created if the host was
+	// not selected by VM Operator.
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is when the attempt was placed, failed or replaced.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Result is the result of the attempt.
+	Result InstanceStoragePlacementResult `json:"result"`
+
+	// Reason is why the host was rejected when the Result is Failed, Replaced or Excluded, like the placement
+	// errors of the volumes or the reason of the replacement.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Candidates are the hosts of the cluster that were considered when the host was selected, with their
+	// datastores and why each host other than the selected one was rejected.
+	// +optional
+	Candidates []InstanceStorageHostCandidate `json:"candidates,omitempty"`
+
+	// Volumes are the placements of the volumes on the datastores of the host.
+	// +optional
+	Volumes []InstanceStorageVolumePlacement `json:"volumes,omitempty"`
+}
+
+// InstanceStorageHostCandidate is a host that was considered for the instance storage volumes.
+type InstanceStorageHostCandidate struct {
+	// Host is the name of the host.
+	Host string `json:"host"`
+
+	// Datastores are the names of the datastores of the host.
+	// +optional
+	Datastores []string `json:"datastores,omitempty"`
+
+	// Reason is why the host was rejected, like the host being excluded or in maintenance mode. It is empty
+	// for the selected host.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// InstanceStorageVolumePlacement is the placement of an instance storage volume by the storage provider.
+type InstanceStorageVolumePlacement struct {
+	// ClaimName is the name of the PVC of the volume.
+	ClaimName string `json:"claimName"`
+
+	// StoragePool is the storage pool, that is the datastore of the host, the volume was placed on.
+	// +optional
+	StoragePool string `json:"storagePool,omitempty"`
+
+	// Error is why the storage provider could not place the volume on any datastore of the host, like
+	// FAILED_PLACEMENT-NotEnoughResources.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// GetInstanceStoragePlacementDiagnostics returns the InstanceStoragePlacementDiagnostics of the VM, or nil if the
+// VM does not have the diagnostics annotation.
+func GetInstanceStoragePlacementDiagnostics(vm metav1.Object) (*InstanceStoragePlacementDiagnostics, error) {
+	data, ok := vm.GetAnnotations()[InstanceStoragePlacementDiagnosticsAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	diagnostics := &InstanceStoragePlacementDiagnostics{}
+	if err := json.Unmarshal([]byte(data), diagnostics); err != nil {
+		return nil, err
+	}
+
+	return diagnostics, nil
+}
+
+// Conditions and condition Reasons for the VirtualMachine object.
+
+const (
+	// VirtualMachineInstanceStoragePlacedCondition documents the placement of the instance storage volumes of
+	// the VM. The condition is not present when the VM does not have instance storage volumes. The details of
+	// the placement attempts are in the InstanceStoragePlacementDiagnosticsAnnotation.
+	VirtualMachineInstanceStoragePlacedCondition vmopv1alpha1.ConditionType = "VirtualMachineInstanceStoragePlaced"
+
+	// InstanceStoragePlacementPendingReason (Severity=Info) documents that the instance storage volumes are
+	// waiting for a host to be selected or for their PVCs to be bound.
+	InstanceStoragePlacementPendingReason = "PlacementPending"
+
+	// InstanceStoragePlacementFailedReason (Severity=Warning) documents that the storage provider could not
+	// place the instance storage volumes on the selected host, and that they are placed again.
+	InstanceStoragePlacementFailedReason = "PlacementFailed"
+
+	// InstanceStorageReplacingReason (Severity=Info) documents that the instance storage volumes are being
+	// recreated because of the InstanceStorageReplaceAnnotation.
+	InstanceStorageReplacingReason = "Replacing"
+)
@@ -0,0 +1,21 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+// Conditions and condition Reasons for the VirtualMachine object.
+
+const (
+	// VirtualMachineMetadataTemplateRenderedCondition documents that the templates in the values of the VM
+	// metadata were rendered.
+	VirtualMachineMetadataTemplateRenderedCondition vmopv1alpha1.ConditionType = "MetadataTemplateRendered"
+
+	// VirtualMachineMetadataTemplateRenderFailedReason (Severity=Warning) documents that a template in the VM
+	// metadata could not be parsed or executed, like a template for the guest such as a cloud-init Jinja
+	// template. The VM is customized with the value as is.
+	VirtualMachineMetadataTemplateRenderFailedReason = "TemplateRenderFailed"
+)
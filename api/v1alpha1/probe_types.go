@@ -0,0 +1,177 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+const (
+	// ReadinessProbeActionsAnnotation is the annotation on a VirtualMachine whose value is a JSON
+	// encoded ProbeActions. The actions are used in addition to the VirtualMachine's
+	// spec.readinessProbe, which must still be set to configure the probe period and timeout.
+	ReadinessProbeActionsAnnotation = "vmoperator.vmware.com/readiness-probe-actions"
+
+	// LivenessProbeAnnotation is the annotation on a VirtualMachine whose value is a JSON encoded
+	// LivenessProbe.
+	LivenessProbeAnnotation = "vmoperator.vmware.com/liveness-probe"
+
+	// DefaultHTTPGetSuccessStatusMin and DefaultHTTPGetSuccessStatusMax are the inclusive range
+	// of HTTP status codes that are considered a success when one is not specified. We use the
+	// same range as the kubernetes container probe.
+	DefaultHTTPGetSuccessStatusMin = 200
+	DefaultHTTPGetSuccessStatusMax = 399
+
+	// DefaultLivenessFailureThreshold and DefaultLivenessSuccessThreshold are the thresholds used
+	// when they are not specified in the LivenessProbe.
+	DefaultLivenessFailureThreshold = 3
+	DefaultLivenessSuccessThreshold = 1
+)
+
+// LivenessAction is the action taken when a VM fails its liveness probe.
+type LivenessAction string
+
+const (
+	// LivenessActionEventOnly only emits an event on the VM.
+	LivenessActionEventOnly LivenessAction = "EventOnly"
+	// LivenessActionReset hard resets the VM.
+	LivenessActionReset LivenessAction = "Reset"
+	// LivenessActionPowerCycle powers the VM off and back on.
+	LivenessActionPowerCycle LivenessAction = "PowerCycle"
+)
+
+// ProbeActions describes the probe actions that are not yet part of the vm-operator-api Probe.
+type ProbeActions struct {
+	// HTTPGet specifies an action involving a HTTP(S) GET request.
+	// +optional
+	HTTPGet *HTTPGetAction `json:"httpGet,omitempty"`
+
+	// Exec specifies a command that is run in the guest via VMware Tools.
+	// +optional
+	Exec *ExecAction `json:"exec,omitempty"`
+}
+
+// HTTPGetAction describes an action based on HTTP(S) GET requests.
+type HTTPGetAction struct {
+	// Path to access on the HTTP server.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Port specifies a number or name of the port to access on the VM.
+	// If the format of port is a number, it must be in the range 1 to 65535.
+	// If the format of name is a string, it must be an IANA_SVC_NAME.
+	Port intstr.IntOrString `json:"port"`
+
+	// Host name to connect to. Defaults to the VM IP.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Scheme to use for connecting to the host. Defaults to HTTP.
+	// +optional
+	// +kubebuilder:validation:Enum=HTTP;HTTPS
+	Scheme corev1.URIScheme `json:"scheme,omitempty"`
+
+	// HTTPHeaders are custom headers to set in the request. HTTP allows repeated headers.
+	// +optional
+	HTTPHeaders []corev1.HTTPHeader `json:"httpHeaders,omitempty"`
+
+	// SuccessStatusRange is the inclusive range of HTTP status codes that are considered a
+	// success. Defaults to 200-399.
+	// +optional
+	SuccessStatusRange *HTTPStatusRange `json:"successStatusRange,omitempty"`
+
+	// InsecureSkipTLSVerify disables the verification of the server certificate when the
+	// scheme is HTTPS.
+	// +optional
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+}
+
+// HTTPStatusRange is an inclusive range of HTTP status codes.
+type HTTPStatusRange struct {
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	Min int32 `json:"min"`
+
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	Max int32 `json:"max"`
+}
+
+// ExecAction describes a command that is run in the guest via the VMware Tools guest
+// operations. The probe succeeds if the command exits with a zero exit code.
+type ExecAction struct {
+	// Command is the command line to execute inside the guest. The first element is the
+	// absolute path of the program, and the remaining elements are its arguments. The
+	// command is not run in a shell.
+	Command []string `json:"command"`
+
+	// CredentialsSecretName is the name of the Secret, in the same namespace as the VM, that
+	// contains the "username" and "password" keys of the guest account to run the command as.
+	CredentialsSecretName string `json:"credentialsSecretName"`
+}
+
+// LivenessProbe describes a probe that is periodically run against a powered on VM, and the action that
+// is taken once the VM has failed the probe FailureThreshold consecutive times.
+type LivenessProbe struct {
+	// Probe is the action, period and timeout of the probe. Exactly one action must be specified
+	// between the Probe and the ProbeActions.
+	vmopv1alpha1.Probe `json:",inline"`
+	ProbeActions       `json:",inline"`
+
+	// FailureThreshold is the number of consecutive failures after which the Action is taken.
+	// Defaults to 3.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+
+	// SuccessThreshold is the number of consecutive successes after which the failures are no
+	// longer counted towards the FailureThreshold. Defaults to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	SuccessThreshold int32 `json:"successThreshold,omitempty"`
+
+	// Action is the action taken once the FailureThreshold is reached. Defaults to EventOnly.
+	// Reset and PowerCycle are rate limited per VM with an exponential back off.
+	// +optional
+	// +kubebuilder:validation:Enum=EventOnly;Reset;PowerCycle
+	Action LivenessAction `json:"action,omitempty"`
+}
+
+// GetReadinessProbeActions returns the ProbeActions from the ReadinessProbeActionsAnnotation
+// annotation of the object, or nil if the annotation is not set.
+func GetReadinessProbeActions(obj metav1.Object) (*ProbeActions, error) {
+	val, ok := obj.GetAnnotations()[ReadinessProbeActionsAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	actions := &ProbeActions{}
+	if err := json.Unmarshal([]byte(val), actions); err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
+
+// GetLivenessProbe returns the LivenessProbe from the LivenessProbeAnnotation annotation of the
+// object, or nil if the annotation is not set.
+func GetLivenessProbe(obj metav1.Object) (*LivenessProbe, error) {
+	val, ok := obj.GetAnnotations()[LivenessProbeAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	probe := &LivenessProbe{}
+	if err := json.Unmarshal([]byte(val), probe); err != nil {
+		return nil, err
+	}
+
+	return probe, nil
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+const (
+	// RelocateToZoneAnnotation is the annotation on a VirtualMachine whose value is the name of the
+	// availability zone the VM should be relocated to. The vSphere VM is relocated into the target zone's
+	// ResourcePool and Folder, and onto a datastore accessible from the zone. Once the VM is relocated,
+	// VM Operator changes the VM's zone label to the target zone and removes the annotation. Only privileged
+	// users can change the zone label to the target zone, so the label cannot be changed without relocating.
+	RelocateToZoneAnnotation = "vmoperator.vmware.com/relocate-to-zone"
+)
+
+// Conditions and condition Reasons for the VirtualMachine object.
+
+const (
+	// VirtualMachineZoneRelocatedCondition documents the relocation of the VM to another availability
+	// zone. The condition is not present when the VM was never relocated.
+	VirtualMachineZoneRelocatedCondition vmopv1alpha1.ConditionType = "VirtualMachineZoneRelocated"
+
+	// VirtualMachineZoneRelocatingReason (Severity=Info) documents that the VM is being relocated to
+	// another availability zone.
+	VirtualMachineZoneRelocatingReason = "Relocating"
+
+	// VirtualMachineZoneRelocateFailedReason (Severity=Error) documents that the VM could not be
+	// relocated to another availability zone.
+	VirtualMachineZoneRelocateFailedReason = "RelocateFailed"
+)
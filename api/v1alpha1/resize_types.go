@@ -0,0 +1,42 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+const (
+	// ResizePolicyAnnotation is the annotation on a VirtualMachine that controls how the CPU and memory of
+	// a powered on VM are changed after its class is changed. By default, the VM is only resized when CPU
+	// and memory can be hot added to the running VM. When the value is ResizePolicyPowerCycle, a VM that
+	// cannot be hot resized is powered off, reconfigured, and powered back on.
+	ResizePolicyAnnotation = "vmoperator.vmware.com/resize-policy"
+
+	// ResizePolicyPowerCycle is the ResizePolicyAnnotation value that allows the VM to be power cycled
+	// to change its CPU and memory.
+	ResizePolicyPowerCycle = "PowerCycle"
+)
+
+// Conditions and condition Reasons for the VirtualMachine object.
+
+const (
+	// VirtualMachineResizedCondition documents that the CPU and memory of a powered on VM were changed to
+	// match its class. The condition is not present when the powered on VM was never resized.
+	VirtualMachineResizedCondition vmopv1alpha1.ConditionType = "VirtualMachineResized"
+
+	// VirtualMachineResizedHotAddReason documents that the VM was resized while it was running.
+	VirtualMachineResizedHotAddReason = "HotAdded"
+
+	// VirtualMachineResizedPowerCycleReason documents that the VM was powered off to be resized, and then
+	// powered back on.
+	VirtualMachineResizedPowerCycleReason = "PowerCycled"
+
+	// VirtualMachineResizePowerCycleRequiredReason (Severity=Warning) documents that the VM cannot be resized
+	// while it is running, and that the ResizePolicyAnnotation does not allow the VM to be power cycled.
+	VirtualMachineResizePowerCycleRequiredReason = "PowerCycleRequired"
+
+	// VirtualMachineResizeFailedReason (Severity=Error) documents that the VM could not be resized.
+	VirtualMachineResizeFailedReason = "ResizeFailed"
+)
@@ -0,0 +1,151 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// VirtualMachineStatusDetailsAnnotation is the annotation on a VirtualMachine with the JSON
+	// VirtualMachineStatusDetails of the VM. It is updated together with the status of the VirtualMachine,
+	// but only when the details change.
+	VirtualMachineStatusDetailsAnnotation = "vmoperator.vmware.com/status-details"
+
+	// VirtualMachineRecentTasksAnnotation is the annotation on a VirtualMachine with the JSON list of the
+	// VirtualMachineTaskDetails of the most recent completed vCenter tasks of the VM, newest first. Tasks that are
+	// still queued or running are not included, and the completed tasks are kept after vCenter drops them from the
+	// recent tasks of the VM, so the annotation is only updated when a task of the VM completes.
+	VirtualMachineRecentTasksAnnotation = "vmoperator.vmware.com/recent-tasks"
+
+	// VirtualMachineRecentTasksMaxTasks is the maximum number of tasks in the VirtualMachineRecentTasksAnnotation.
+	VirtualMachineRecentTasksMaxTasks = 10
+)
+
+// VirtualMachineStatusDetails is the observed hardware, disks and guest OS of the vSphere VM of a
+// VirtualMachine, which are not part of the VirtualMachineStatus. Only details that rarely change are
+// included, so that the annotation does not cause an update of the VirtualMachine on every reconcile.
+type VirtualMachineStatusDetails struct {
+	// GuestOSFullName is the full name of the guest OS, as last reported by VMware Tools or else as configured.
+	// +optional
+	GuestOSFullName string `json:"guestOSFullName,omitempty"`
+
+	// GuestOSFamily is the family of the guest OS: Linux, Windows or Other.
+	// +optional
+	GuestOSFamily string `json:"guestOSFamily,omitempty"`
+
+	// ToolsVersion is the version of VMware Tools running in the guest.
+	// +optional
+	ToolsVersion string `json:"toolsVersion,omitempty"`
+
+	// ToolsVersionStatus is the status of the version of VMware Tools, like guestToolsCurrent.
+	// +optional
+	ToolsVersionStatus string `json:"toolsVersionStatus,omitempty"`
+
+	// HardwareVersion is the virtual hardware version of the VM, like vmx-19.
+	// +optional
+	HardwareVersion string `json:"hardwareVersion,omitempty"`
+
+	// NumCPUs is the number of virtual CPUs of the VM.
+	// +optional
+	NumCPUs int32 `json:"numCPUs,omitempty"`
+
+	// MemoryMiB is the memory of the VM in MiB.
+	// +optional
+	MemoryMiB int32 `json:"memoryMiB,omitempty"`
+
+	// Disks are the virtual disks of the VM.
+	// +optional
+	Disks []VirtualMachineDiskDetails `json:"disks,omitempty"`
+}
+
+// VirtualMachineDiskDetails is a virtual disk of a VM.
+type VirtualMachineDiskDetails struct {
+	// Label is the label of the disk, like "Hard disk 1".
+	Label string `json:"label"`
+
+	// DeviceKey is the device key of the disk, which is the DeviceKey of its vSphere volume.
+	// +optional
+	DeviceKey int32 `json:"deviceKey,omitempty"`
+
+	// FileName is the datastore path of the disk backing.
+	// +optional
+	FileName string `json:"fileName,omitempty"`
+
+	// Datastore is the name of the datastore of the disk.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
+
+	// CapacityBytes is the capacity of the disk.
+	CapacityBytes int64 `json:"capacityBytes"`
+
+	// Provisioning is the provisioning type of the disk: thin, thick or eagerZeroedThick.
+	// +optional
+	Provisioning string `json:"provisioning,omitempty"`
+
+	// Controller is the label of the controller the disk is attached to, like "SCSI controller 0".
+	// +optional
+	Controller string `json:"controller,omitempty"`
+
+	// UnitNumber is the unit number of the disk on its controller.
+	// +optional
+	UnitNumber *int32 `json:"unitNumber,omitempty"`
+}
+
+// VirtualMachineTaskDetails is a completed vCenter task of a VM.
+type VirtualMachineTaskDetails struct {
+	// Key is the identifier of the task in vCenter, like task-123.
+	Key string `json:"key"`
+
+	// Name is the identifier of the operation of the task, like VirtualMachine.powerOn.
+	Name string `json:"name"`
+
+	// State is the state of the task: success or error.
+	State string `json:"state"`
+
+	// StartTime is the time the task started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompleteTime is the time the task completed.
+	CompleteTime metav1.Time `json:"completeTime"`
+
+	// Error is the error of the task when it failed.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// GetVirtualMachineStatusDetails returns the VirtualMachineStatusDetails of the VM, or nil if the VM does not
+// have the details.
+func GetVirtualMachineStatusDetails(vm metav1.Object) (*VirtualMachineStatusDetails, error) {
+	data, ok := vm.GetAnnotations()[VirtualMachineStatusDetailsAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	details := &VirtualMachineStatusDetails{}
+	if err := json.Unmarshal([]byte(data), details); err != nil {
+		return nil, err
+	}
+
+	return details, nil
+}
+
+// GetVirtualMachineRecentTasks returns the recent tasks of the VM, or nil if the VM does not have the recent
+// tasks annotation.
+func GetVirtualMachineRecentTasks(vm metav1.Object) ([]VirtualMachineTaskDetails, error) {
+	data, ok := vm.GetAnnotations()[VirtualMachineRecentTasksAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	var tasks []VirtualMachineTaskDetails
+	if err := json.Unmarshal([]byte(data), &tasks); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
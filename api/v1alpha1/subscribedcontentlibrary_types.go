@@ -0,0 +1,138 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+// Conditions and condition Reasons for the SubscribedContentLibrary object.
+
+const (
+	// SubscribedContentLibraryReadyCondition documents that the subscribed content library exists on the
+	// infrastructure, and that its images are available to the namespace through a ContentSource.
+	SubscribedContentLibraryReadyCondition vmopv1alpha1.ConditionType = "SubscribedContentLibraryReady"
+
+	// SubscribedContentLibraryCreateFailedReason (Severity=Error) documents that the subscribed content
+	// library could not be created or updated on the infrastructure.
+	SubscribedContentLibraryCreateFailedReason = "CreateFailed"
+
+	// SubscribedContentLibraryContentSourceFailedReason (Severity=Error) documents that the ContentSource of
+	// the subscribed content library could not be created or bound to the namespace.
+	SubscribedContentLibraryContentSourceFailedReason = "ContentSourceFailed"
+
+	// SubscribedContentLibrarySyncedCondition documents that the subscribed content library was synced with
+	// its publisher.
+	SubscribedContentLibrarySyncedCondition vmopv1alpha1.ConditionType = "SubscribedContentLibrarySynced"
+
+	// SubscribedContentLibrarySyncPendingReason (Severity=Info) documents that the subscribed content library
+	// was never synced with its publisher.
+	SubscribedContentLibrarySyncPendingReason = "SyncPending"
+
+	// SubscribedContentLibrarySyncFailedReason (Severity=Error) documents that the sync status of the
+	// subscribed content library could not be retrieved, or that the sync failed.
+	SubscribedContentLibrarySyncFailedReason = "SyncFailed"
+)
+
+// ContentLibrarySyncPolicy is when the content of the items of a subscribed content library is downloaded
+// from the publisher.
+type ContentLibrarySyncPolicy string
+
+const (
+	// ContentLibrarySyncImmediate downloads the content of all the items when the library is synced.
+	ContentLibrarySyncImmediate ContentLibrarySyncPolicy = "Immediate"
+	// ContentLibrarySyncOnDemand only downloads the metadata of the items when the library is synced,
+	// and the content of an item when the item is first used.
+	ContentLibrarySyncOnDemand ContentLibrarySyncPolicy = "OnDemand"
+)
+
+// ContentLibraryStorageBacking is the storage of the content of a content library.
+type ContentLibraryStorageBacking struct {
+	// DatastoreID is the managed object ID of the datastore, like datastore-42, that stores the
+	// content of the library.
+	DatastoreID string `json:"datastoreID"`
+}
+
+// SubscribedContentLibrarySpec defines the desired state of SubscribedContentLibrary.
+type SubscribedContentLibrarySpec struct {
+	// PublisherURL is the URL of the lib.json of the published content library.
+	PublisherURL string `json:"publisherURL"`
+
+	// SSLThumbprint is the SHA-1 thumbprint of the certificate of the publisher, and is required when
+	// the PublisherURL is an HTTPS URL whose certificate is not trusted by vCenter.
+	// +optional
+	SSLThumbprint string `json:"sslThumbprint,omitempty"`
+
+	// SyncPolicy is when the content of the library items is downloaded from the publisher. Defaults
+	// to Immediate.
+	// +optional
+	// +kubebuilder:validation:Enum=Immediate;OnDemand
+	SyncPolicy ContentLibrarySyncPolicy `json:"syncPolicy,omitempty"`
+
+	// StorageBacking is the storage of the content of the library. It cannot be changed once the
+	// library is created.
+	StorageBacking ContentLibraryStorageBacking `json:"storageBacking"`
+}
+
+// SubscribedContentLibraryStatus defines the observed state of SubscribedContentLibrary.
+type SubscribedContentLibraryStatus struct {
+	// LibraryUUID is the UUID of the subscribed content library on the infrastructure. The ContentSource
+	// and ContentLibraryProvider of the library are named after the UUID.
+	// +optional
+	LibraryUUID string `json:"libraryUUID,omitempty"`
+
+	// LastSyncTime is the last time the library was synced with its publisher.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// Conditions describes the current condition information of the SubscribedContentLibrary.
+	// +optional
+	Conditions []vmopv1alpha1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=scl
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Publisher",type="string",JSONPath=".spec.publisherURL"
+// +kubebuilder:printcolumn:name="Library-UUID",type="string",JSONPath=".status.libraryUUID"
+// +kubebuilder:printcolumn:name="Last-Sync",type="date",JSONPath=".status.lastSyncTime"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// SubscribedContentLibrary is the Schema for the subscribedcontentlibraries API.
+// A SubscribedContentLibrary is a vSphere content library subscribed to a published content library,
+// whose images are available to the VirtualMachines of its namespace.
+type SubscribedContentLibrary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SubscribedContentLibrarySpec   `json:"spec,omitempty"`
+	Status SubscribedContentLibraryStatus `json:"status,omitempty"`
+}
+
+func (scl *SubscribedContentLibrary) NamespacedName() string {
+	return scl.Namespace + "/" + scl.Name
+}
+
+func (scl *SubscribedContentLibrary) GetConditions() vmopv1alpha1.Conditions {
+	return scl.Status.Conditions
+}
+
+func (scl *SubscribedContentLibrary) SetConditions(conditions vmopv1alpha1.Conditions) {
+	scl.Status.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// SubscribedContentLibraryList contains a list of SubscribedContentLibrary.
+type SubscribedContentLibraryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SubscribedContentLibrary `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SubscribedContentLibrary{}, &SubscribedContentLibraryList{})
+}
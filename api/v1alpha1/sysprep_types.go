@@ -0,0 +1,47 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+const (
+	// SysprepSecretAnnotation is the annotation on a Windows VirtualMachine whose value is the name of the
+	// Secret, in the VM's namespace, with the credentials used by the Sysprep guest customization. Windows VMs
+	// are customized with Sysprep when the OS type of their image is Windows.
+	SysprepSecretAnnotation = "vmoperator.vmware.com/sysprep-secret"
+
+	// SysprepAdminPasswordKey is the key in the Sysprep Secret of the password of the Administrator account.
+	SysprepAdminPasswordKey = "admin-password"
+
+	// SysprepDomainAdminKey is the key in the Sysprep Secret of the user that joins the VM to the domain.
+	SysprepDomainAdminKey = "domain-admin"
+
+	// SysprepDomainAdminPasswordKey is the key in the Sysprep Secret of the password of the user that joins
+	// the VM to the domain.
+	SysprepDomainAdminPasswordKey = "domain-admin-password"
+)
+
+// Keys of the VM metadata ConfigMap or Secret that are used by the Sysprep guest customization.
+const (
+	// SysprepUnattendKey is the key of a complete unattend.xml answer file. When present, the answer file is
+	// used as is and the other Sysprep keys are ignored.
+	SysprepUnattendKey = "sysprep-unattend"
+
+	// SysprepFullNameKey is the key of the full name of the user of the VM.
+	SysprepFullNameKey = "sysprep-full-name"
+
+	// SysprepOrgNameKey is the key of the name of the organization that owns the VM.
+	SysprepOrgNameKey = "sysprep-org-name"
+
+	// SysprepProductIDKey is the key of the Windows product key.
+	SysprepProductIDKey = "sysprep-product-id"
+
+	// SysprepTimeZoneKey is the key of the Microsoft time zone index of the VM.
+	SysprepTimeZoneKey = "sysprep-timezone"
+
+	// SysprepDomainKey is the key of the domain the VM joins. The SysprepDomainAdminKey and
+	// SysprepDomainAdminPasswordKey credentials must be in the Sysprep Secret to join a domain.
+	SysprepDomainKey = "sysprep-domain"
+
+	// SysprepWorkgroupKey is the key of the workgroup the VM joins when it does not join a domain.
+	SysprepWorkgroupKey = "sysprep-workgroup"
+)
@@ -0,0 +1,165 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+// Conditions and condition Reasons for the VirtualMachineImageImport object.
+
+const (
+	// VirtualMachineImageImportUploadedCondition documents that the image was downloaded, verified and
+	// uploaded into the content library of the namespace.
+	VirtualMachineImageImportUploadedCondition vmopv1alpha1.ConditionType = "Uploaded"
+
+	// VirtualMachineImageImportContentLibraryNotFoundReason (Severity=Error) documents that the namespace does
+	// not have a content library of its own that images can be uploaded into.
+	VirtualMachineImageImportContentLibraryNotFoundReason = "ContentLibraryNotFound"
+
+	// VirtualMachineImageImportDownloadingReason (Severity=Info) documents that the image is being downloaded.
+	VirtualMachineImageImportDownloadingReason = "Downloading"
+
+	// VirtualMachineImageImportDownloadFailedReason (Severity=Error) documents that the image could not be
+	// downloaded from its source.
+	VirtualMachineImageImportDownloadFailedReason = "DownloadFailed"
+
+	// VirtualMachineImageImportVerificationFailedReason (Severity=Error) documents that the digest or the
+	// signature of the downloaded image does not match. The import is not retried until the spec changes.
+	VirtualMachineImageImportVerificationFailedReason = "VerificationFailed"
+
+	// VirtualMachineImageImportUploadFailedReason (Severity=Error) documents that the image could not be
+	// uploaded into the content library.
+	VirtualMachineImageImportUploadFailedReason = "UploadFailed"
+
+	// VirtualMachineImageImportReadyCondition documents that the VirtualMachineImage of the uploaded image
+	// is available.
+	VirtualMachineImageImportReadyCondition vmopv1alpha1.ConditionType = "Ready"
+
+	// VirtualMachineImageImportImagePendingReason (Severity=Info) documents that the content library has not
+	// yet been synced into a VirtualMachineImage for the uploaded image.
+	VirtualMachineImageImportImagePendingReason = "ImagePending"
+)
+
+// HTTPImageSource is an OVA that is downloaded from an HTTP or HTTPS URL.
+type HTTPImageSource struct {
+	// URL is the URL of the OVA.
+	URL string `json:"url"`
+}
+
+// OCIImageSource is an OVA that is stored as the single layer of an OCI artifact.
+type OCIImageSource struct {
+	// Reference is the reference of the artifact, like registry.example.com/images/ubuntu:20.04 or
+	// registry.example.com/images/ubuntu@sha256:<digest>. The artifact is pulled anonymously.
+	Reference string `json:"reference"`
+}
+
+// VirtualMachineImageImportSource is the source of an imported image. Exactly one source must be set.
+type VirtualMachineImageImportSource struct {
+	// HTTP is an OVA downloaded from an HTTP URL.
+	// +optional
+	HTTP *HTTPImageSource `json:"http,omitempty"`
+
+	// OCI is an OVA pulled from an OCI registry.
+	// +optional
+	OCI *OCIImageSource `json:"oci,omitempty"`
+}
+
+// VirtualMachineImageImportSignature is the signature of the digest of an imported image.
+type VirtualMachineImageImportSignature struct {
+	// PublicKey is the PEM encoded RSA or ECDSA public key that verifies the signature.
+	PublicKey string `json:"publicKey"`
+
+	// Value is the base64 encoded signature of the SHA-256 digest of the image.
+	Value string `json:"value"`
+}
+
+// VirtualMachineImageImportSpec defines the desired state of VirtualMachineImageImport. The image is imported
+// again when the spec changes.
+type VirtualMachineImageImportSpec struct {
+	// Source is where the OVA of the image is downloaded from.
+	Source VirtualMachineImageImportSource `json:"source"`
+
+	// Digest is the SHA-256 digest of the OVA, like sha256:<hex>, that the downloaded OVA is verified against.
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	Digest string `json:"digest"`
+
+	// Signature is the optional signature of the digest of the OVA.
+	// +optional
+	Signature *VirtualMachineImageImportSignature `json:"signature,omitempty"`
+
+	// ImageName is the name of the content library item, and so of the VirtualMachineImage, of the image.
+	// Defaults to the name of the VirtualMachineImageImport. An existing item with the name is only replaced
+	// when it was imported by the same VirtualMachineImageImport.
+	// +optional
+	ImageName string `json:"imageName,omitempty"`
+}
+
+// VirtualMachineImageImportStatus defines the observed state of VirtualMachineImageImport.
+type VirtualMachineImageImportStatus struct {
+	// ObservedGeneration is the generation of the spec that the image was last imported from.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LibraryUUID is the UUID of the content library the image was uploaded into.
+	// +optional
+	LibraryUUID string `json:"libraryUUID,omitempty"`
+
+	// LibraryItemID is the ID of the content library item of the image.
+	// +optional
+	LibraryItemID string `json:"libraryItemID,omitempty"`
+
+	// ImageName is the name of the VirtualMachineImage of the image.
+	// +optional
+	ImageName string `json:"imageName,omitempty"`
+
+	// Conditions describes the current condition information of the VirtualMachineImageImport.
+	// +optional
+	Conditions []vmopv1alpha1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=vmiimport
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".status.imageName"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VirtualMachineImageImport is the Schema for the virtualmachineimageimports API.
+// A VirtualMachineImageImport downloads an OVA from an HTTP URL or an OCI registry, verifies it, and uploads
+// it into the content library of its namespace, where it is available as a VirtualMachineImage.
+type VirtualMachineImageImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VirtualMachineImageImportSpec   `json:"spec,omitempty"`
+	Status VirtualMachineImageImportStatus `json:"status,omitempty"`
+}
+
+func (vmii *VirtualMachineImageImport) NamespacedName() string {
+	return vmii.Namespace + "/" + vmii.Name
+}
+
+func (vmii *VirtualMachineImageImport) GetConditions() vmopv1alpha1.Conditions {
+	return vmii.Status.Conditions
+}
+
+func (vmii *VirtualMachineImageImport) SetConditions(conditions vmopv1alpha1.Conditions) {
+	vmii.Status.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// VirtualMachineImageImportList contains a list of VirtualMachineImageImport.
+type VirtualMachineImageImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VirtualMachineImageImport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VirtualMachineImageImport{}, &VirtualMachineImageImportList{})
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+// Conditions and condition Reasons for the VirtualMachinePublishRequest object.
+
+const (
+	// VirtualMachinePublishRequestUploadedCondition documents that the source VirtualMachine was captured as a
+	// library item in the target content library.
+	VirtualMachinePublishRequestUploadedCondition vmopv1alpha1.ConditionType = "Uploaded"
+
+	// VirtualMachinePublishRequestSourceNotFoundReason (Severity=Error) documents that the source
+	// VirtualMachine does not exist.
+	VirtualMachinePublishRequestSourceNotFoundReason = "SourceNotFound"
+
+	// VirtualMachinePublishRequestContentLibraryNotFoundReason (Severity=Error) documents that the target
+	// content library does not exist, or that the namespace does not have a content library that images can
+	// be published to.
+	VirtualMachinePublishRequestContentLibraryNotFoundReason = "ContentLibraryNotFound"
+
+	// VirtualMachinePublishRequestSourcePoweringOffReason (Severity=Info) documents that the source
+	// VirtualMachine is being powered off before it is captured.
+	VirtualMachinePublishRequestSourcePoweringOffReason = "SourcePoweringOff"
+
+	// VirtualMachinePublishRequestPublishFailedReason (Severity=Error) documents that the source
+	// VirtualMachine could not be captured into the content library. The power state of the VirtualMachine is
+	// restored, and the publish is not retried.
+	VirtualMachinePublishRequestPublishFailedReason = "PublishFailed"
+
+	// VirtualMachinePublishRequestReadyCondition documents that the VirtualMachineImage of the published
+	// library item is available.
+	VirtualMachinePublishRequestReadyCondition vmopv1alpha1.ConditionType = "Ready"
+
+	// VirtualMachinePublishRequestImagePendingReason (Severity=Info) documents that the content library has
+	// not yet been synced into a VirtualMachineImage for the published library item.
+	VirtualMachinePublishRequestImagePendingReason = "ImagePending"
+)
+
+// VirtualMachinePublishMethod is how a VirtualMachine is captured while it is published.
+type VirtualMachinePublishMethod string
+
+const (
+	// VirtualMachinePublishPowerOff powers the VirtualMachine off while it is captured, and then restores its
+	// power state.
+	VirtualMachinePublishPowerOff VirtualMachinePublishMethod = "PowerOff"
+	// VirtualMachinePublishSnapshot captures a linked clone of a snapshot of the VirtualMachine, so that the
+	// VirtualMachine keeps running.
+	VirtualMachinePublishSnapshot VirtualMachinePublishMethod = "Snapshot"
+)
+
+// VirtualMachinePublishRequestSource is the VirtualMachine that is published.
+type VirtualMachinePublishRequestSource struct {
+	// Name is the name of the VirtualMachine, in the namespace of the VirtualMachinePublishRequest.
+	Name string `json:"name"`
+}
+
+// VirtualMachinePublishRequestTarget is the library item that the VirtualMachine is published as.
+type VirtualMachinePublishRequestTarget struct {
+	// ItemName is the name of the library item, and so of the VirtualMachineImage, of the published
+	// VirtualMachine. Defaults to the name of the VirtualMachinePublishRequest.
+	// +optional
+	ItemName string `json:"itemName,omitempty"`
+
+	// ItemDescription is the description of the library item. An existing item with the same name and
+	// description is taken to be the item of an earlier publish of the VirtualMachine. Defaults to a
+	// description with the namespace and name of the VirtualMachinePublishRequest.
+	// +optional
+	ItemDescription string `json:"itemDescription,omitempty"`
+
+	// ContentSourceName is the name of the ContentSource of the content library that the VirtualMachine is
+	// published to. The ContentSource must only be bound to the namespace, and must not be of a subscribed
+	// library. Defaults to the content library that images are uploaded into in the namespace.
+	// +optional
+	ContentSourceName string `json:"contentSourceName,omitempty"`
+}
+
+// VirtualMachinePublishRequestSpec defines the desired state of VirtualMachinePublishRequest.
+type VirtualMachinePublishRequestSpec struct {
+	// Source is the VirtualMachine that is published.
+	Source VirtualMachinePublishRequestSource `json:"source"`
+
+	// Target is the library item that the VirtualMachine is published as.
+	// +optional
+	Target VirtualMachinePublishRequestTarget `json:"target,omitempty"`
+
+	// Method is how the VirtualMachine is captured. Defaults to PowerOff.
+	// +optional
+	// +kubebuilder:validation:Enum=PowerOff;Snapshot
+	Method VirtualMachinePublishMethod `json:"method,omitempty"`
+}
+
+// VirtualMachinePublishRequestStatus defines the observed state of VirtualMachinePublishRequest.
+type VirtualMachinePublishRequestStatus struct {
+	// SourcePowerState is the power state of the source VirtualMachine before it was powered off to be
+	// captured, and is restored once the VirtualMachine is captured.
+	// +optional
+	SourcePowerState vmopv1alpha1.VirtualMachinePowerState `json:"sourcePowerState,omitempty"`
+
+	// LibraryUUID is the UUID of the content library the VirtualMachine was published to.
+	// +optional
+	LibraryUUID string `json:"libraryUUID,omitempty"`
+
+	// LibraryItemID is the ID of the library item of the published VirtualMachine.
+	// +optional
+	LibraryItemID string `json:"libraryItemID,omitempty"`
+
+	// ImageName is the name of the VirtualMachineImage of the published VirtualMachine.
+	// +optional
+	ImageName string `json:"imageName,omitempty"`
+
+	// CompletionTime is the time the VirtualMachine was captured.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Conditions describes the current condition information of the VirtualMachinePublishRequest.
+	// +optional
+	Conditions []vmopv1alpha1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=vmpub
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Source",type="string",JSONPath=".spec.source.name"
+// +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".status.imageName"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VirtualMachinePublishRequest is the Schema for the virtualmachinepublishrequests API.
+// A VirtualMachinePublishRequest captures a VirtualMachine as an OVF library item in a content library, where
+// it is available as a VirtualMachineImage to create other VirtualMachines from.
+type VirtualMachinePublishRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VirtualMachinePublishRequestSpec   `json:"spec,omitempty"`
+	Status VirtualMachinePublishRequestStatus `json:"status,omitempty"`
+}
+
+func (vmpub *VirtualMachinePublishRequest) NamespacedName() string {
+	return vmpub.Namespace + "/" + vmpub.Name
+}
+
+func (vmpub *VirtualMachinePublishRequest) GetConditions() vmopv1alpha1.Conditions {
+	return vmpub.Status.Conditions
+}
+
+func (vmpub *VirtualMachinePublishRequest) SetConditions(conditions vmopv1alpha1.Conditions) {
+	vmpub.Status.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// VirtualMachinePublishRequestList contains a list of VirtualMachinePublishRequest.
+type VirtualMachinePublishRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VirtualMachinePublishRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VirtualMachinePublishRequest{}, &VirtualMachinePublishRequestList{})
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+const (
+	// VirtualMachineReplicaSetRevisionAnnotation is the annotation on the VirtualMachines of a
+	// VirtualMachineReplicaSet with the revision of the template the VM was created from. The revision
+	// changes when the template changes, like its class, and when the content library item of the
+	// template's image is updated.
+	VirtualMachineReplicaSetRevisionAnnotation = "vmoperator.vmware.com/replicaset-revision"
+)
+
+// Conditions and condition Reasons for the VirtualMachineReplicaSet object.
+
+const (
+	// VirtualMachineReplicaSetRolledOutCondition documents that all VirtualMachines of the replica set
+	// were created from the current revision of the template.
+	VirtualMachineReplicaSetRolledOutCondition vmopv1alpha1.ConditionType = "VirtualMachineReplicaSetRolledOut"
+
+	// VirtualMachineReplicaSetRollingUpdateReason (Severity=Info) documents that VirtualMachines of a
+	// previous revision are being replaced.
+	VirtualMachineReplicaSetRollingUpdateReason = "RollingUpdate"
+
+	// VirtualMachineReplicaSetReplacementNotReadyReason (Severity=Warning) documents that the rolling
+	// update is paused because a replacement VirtualMachine did not become ready in time.
+	VirtualMachineReplicaSetReplacementNotReadyReason = "ReplacementNotReady"
+)
+
+// VirtualMachineRollingUpdateStrategy controls the replacement of the VirtualMachines of a previous
+// revision of the template.
+type VirtualMachineRollingUpdateStrategy struct {
+	// MaxSurge is the maximum number of VirtualMachines that can be created above the desired number of
+	// VirtualMachines during a rolling update. The value can be a number or a percentage of the desired
+	// number of VirtualMachines, which is rounded up. Defaults to 1.
+	// +optional
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+
+	// MaxUnavailable is the maximum number of VirtualMachines that can be unavailable below the desired
+	// number of VirtualMachines during a rolling update. The value can be a number or a percentage of the
+	// desired number of VirtualMachines, which is rounded down. Defaults to 0. MaxUnavailable and MaxSurge
+	// cannot both be zero.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// ProgressDeadlineSeconds is the number of seconds a replacement VirtualMachine has to become ready.
+	// The rolling update is paused when a replacement is not ready in time, and continues once the
+	// replacement is ready. Defaults to 600.
+	// +optional
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+}
+
+// VirtualMachineTemplateSpec describes the VirtualMachines created from a template.
+type VirtualMachineTemplateSpec struct {
+	// Standard object's metadata. Only the labels and annotations are used.
+	// +optional
+	ObjectMeta metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the spec of the VirtualMachines.
+	Spec vmopv1alpha1.VirtualMachineSpec `json:"spec"`
+}
+
+// VirtualMachineReplicaSetSpec defines the desired state of VirtualMachineReplicaSet.
+type VirtualMachineReplicaSetSpec struct {
+	// Replicas is the number of desired VirtualMachines. Defaults to 1.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Selector is a label query over the VirtualMachines of the replica set. It must match the labels
+	// of the template.
+	Selector *metav1.LabelSelector `json:"selector"`
+
+	// Template describes the VirtualMachines that are created. When the template has a resource policy
+	// with cluster modules, the VirtualMachines are placed in the first cluster module of the policy
+	// for anti-affinity, unless the template already has the cluster module annotations. The
+	// VirtualMachines are spread across the availability zones when the template does not have a zone.
+	Template VirtualMachineTemplateSpec `json:"template"`
+
+	// RollingUpdate controls how the VirtualMachines are replaced when the template changes, or when the
+	// content library item of the template's image is updated. A replacement must be ready before a
+	// VirtualMachine of the previous revision is deleted.
+	// +optional
+	RollingUpdate *VirtualMachineRollingUpdateStrategy `json:"rollingUpdate,omitempty"`
+}
+
+// VirtualMachineReplicaSetStatus defines the observed state of VirtualMachineReplicaSet.
+type VirtualMachineReplicaSetStatus struct {
+	// Replicas is the number of VirtualMachines of the replica set.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// ReadyReplicas is the number of VirtualMachines of the replica set that are ready.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// UpdatedReplicas is the number of VirtualMachines of the replica set that were created from the
+	// current revision of the template.
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+
+	// CurrentRevision is the current revision of the template.
+	// +optional
+	CurrentRevision string `json:"currentRevision,omitempty"`
+
+	// Selector is the label selector, in string form, of the VirtualMachines of the replica set.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// ObservedGeneration is the most recent generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describes the current condition information of the VirtualMachineReplicaSet.
+	// +optional
+	Conditions []vmopv1alpha1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=vmrs
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+// +kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".spec.replicas"
+// +kubebuilder:printcolumn:name="Current",type="integer",JSONPath=".status.replicas"
+// +kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas"
+// +kubebuilder:printcolumn:name="Up-To-Date",type="integer",JSONPath=".status.updatedReplicas"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VirtualMachineReplicaSet is the Schema for the virtualmachinereplicasets API.
+// A VirtualMachineReplicaSet keeps a number of identical VirtualMachines, created from a template,
+// running.
+type VirtualMachineReplicaSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VirtualMachineReplicaSetSpec   `json:"spec,omitempty"`
+	Status VirtualMachineReplicaSetStatus `json:"status,omitempty"`
+}
+
+func (rs *VirtualMachineReplicaSet) NamespacedName() string {
+	return rs.Namespace + "/" + rs.Name
+}
+
+func (rs *VirtualMachineReplicaSet) GetConditions() vmopv1alpha1.Conditions {
+	return rs.Status.Conditions
+}
+
+func (rs *VirtualMachineReplicaSet) SetConditions(conditions vmopv1alpha1.Conditions) {
+	rs.Status.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// VirtualMachineReplicaSetList contains a list of VirtualMachineReplicaSet.
+type VirtualMachineReplicaSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VirtualMachineReplicaSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VirtualMachineReplicaSet{}, &VirtualMachineReplicaSetList{})
+}
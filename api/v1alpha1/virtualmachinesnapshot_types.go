@@ -0,0 +1,139 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+const (
+	// RevertSnapshotAnnotation is the annotation on a VirtualMachineSnapshot that requests the VM to
+	// be reverted to the snapshot. The value is ignored, and the annotation is removed once the VM
+	// has been reverted.
+	RevertSnapshotAnnotation = "vmoperator.vmware.com/revert-to-snapshot"
+)
+
+// Conditions and condition Reasons for the VirtualMachineSnapshot object.
+
+const (
+	// VirtualMachineSnapshotCreatedCondition documents that the snapshot exists on the VM.
+	VirtualMachineSnapshotCreatedCondition vmopv1alpha1.ConditionType = "VirtualMachineSnapshotCreated"
+
+	// VirtualMachineSnapshotVMNotFoundReason (Severity=Error) documents that the VirtualMachine of
+	// the snapshot does not exist.
+	VirtualMachineSnapshotVMNotFoundReason = "VirtualMachineNotFound"
+
+	// VirtualMachineSnapshotCreateFailedReason (Severity=Error) documents that the snapshot could not
+	// be created.
+	VirtualMachineSnapshotCreateFailedReason = "CreateFailed"
+
+	// VirtualMachineSnapshotNotFoundReason (Severity=Error) documents that the snapshot was created
+	// but no longer exists on the VM, likely because it was removed outside of VM Operator.
+	VirtualMachineSnapshotNotFoundReason = "SnapshotNotFound"
+)
+
+// Conditions and condition Reasons for the VirtualMachine object.
+
+const (
+	// VirtualMachineSnapshotCondition documents the current snapshot of the VM. The condition is
+	// not present when the VM does not have any snapshots.
+	VirtualMachineSnapshotCondition vmopv1alpha1.ConditionType = "VirtualMachineSnapshot"
+
+	// VirtualMachineSnapshotPresentReason documents that the VM has snapshots. The message of the
+	// condition contains the name of the current snapshot.
+	VirtualMachineSnapshotPresentReason = "SnapshotPresent"
+)
+
+// VirtualMachineSnapshotSpec defines the desired state of VirtualMachineSnapshot.
+type VirtualMachineSnapshotSpec struct {
+	// VirtualMachineName is the name of the VirtualMachine, in the same namespace, to snapshot.
+	VirtualMachineName string `json:"virtualMachineName"`
+
+	// Description is the description of the snapshot.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Memory includes the memory of the VM in the snapshot, so that the VM is powered on in the same
+	// state when reverted to the snapshot. Only applies when the VM is powered on.
+	// +optional
+	Memory bool `json:"memory,omitempty"`
+
+	// Quiesce quiesces the file system of the VM through VMware Tools before the snapshot is taken.
+	// Only applies when the VM is powered on and Memory is false.
+	// +optional
+	Quiesce bool `json:"quiesce,omitempty"`
+}
+
+// VirtualMachineSnapshotStatus defines the observed state of VirtualMachineSnapshot.
+type VirtualMachineSnapshotStatus struct {
+	// SnapshotID is the vSphere managed object ID of the snapshot.
+	// +optional
+	SnapshotID string `json:"snapshotID,omitempty"`
+
+	// CreationTime is when the snapshot was taken.
+	// +optional
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+
+	// PowerState is the power state of the VM when the snapshot was taken: poweredOn, poweredOff
+	// or suspended.
+	// +optional
+	PowerState string `json:"powerState,omitempty"`
+
+	// Quiesced is true if the file system of the VM was quiesced when the snapshot was taken.
+	// +optional
+	Quiesced bool `json:"quiesced,omitempty"`
+
+	// LastRevertTime is when the VM was last reverted to the snapshot.
+	// +optional
+	LastRevertTime *metav1.Time `json:"lastRevertTime,omitempty"`
+
+	// Conditions describes the current condition information of the VirtualMachineSnapshot.
+	// +optional
+	Conditions []vmopv1alpha1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=vmsnapshot
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="VirtualMachine",type="string",JSONPath=".spec.virtualMachineName"
+// +kubebuilder:printcolumn:name="SnapshotID",type="string",JSONPath=".status.snapshotID"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VirtualMachineSnapshot is the Schema for the virtualmachinesnapshots API.
+// A VirtualMachineSnapshot represents a point in time snapshot of a VirtualMachine, that the
+// VirtualMachine may be reverted to.
+type VirtualMachineSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VirtualMachineSnapshotSpec   `json:"spec,omitempty"`
+	Status VirtualMachineSnapshotStatus `json:"status,omitempty"`
+}
+
+func (s *VirtualMachineSnapshot) NamespacedName() string {
+	return s.Namespace + "/" + s.Name
+}
+
+func (s *VirtualMachineSnapshot) GetConditions() vmopv1alpha1.Conditions {
+	return s.Status.Conditions
+}
+
+func (s *VirtualMachineSnapshot) SetConditions(conditions vmopv1alpha1.Conditions) {
+	s.Status.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// VirtualMachineSnapshotList contains a list of VirtualMachineSnapshot.
+type VirtualMachineSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VirtualMachineSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VirtualMachineSnapshot{}, &VirtualMachineSnapshotList{})
+}
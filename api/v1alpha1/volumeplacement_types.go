@@ -0,0 +1,116 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+const (
+	// VirtualMachineVolumePlacementAnnotation is the annotation on a VirtualMachine with the JSON list of the
+	// VirtualMachineVolumePlacements of its vSphere volumes. When the annotation is set, the controllers
+	// requested by the placements are added to the VM before it is powered on and the disks are moved to their
+	// requested controller and unit.
+	//
+	// Only vSphere volumes can be placed. Placements of PersistentVolumeClaim volumes are not supported and are
+	// rejected, since their disks are attached by the storage provider through a CnsNodeVmAttachment, which
+	// picks the controller and unit. Instead, when the annotation is set, the PersistentVolumeClaim volumes are
+	// attached one at a time in the order of the volumes in the spec so that the guest sees them in that order.
+	VirtualMachineVolumePlacementAnnotation = "vmoperator.vmware.com/volume-placement"
+)
+
+// VirtualMachineVolumeControllerType is the type of the controller a volume is attached to.
+type VirtualMachineVolumeControllerType string
+
+const (
+	// VirtualMachineVolumeControllerParaVirtualSCSI is a VMware Paravirtual SCSI controller. This is the
+	// default controller type.
+	VirtualMachineVolumeControllerParaVirtualSCSI VirtualMachineVolumeControllerType = "ParaVirtualSCSI"
+	// VirtualMachineVolumeControllerLsiLogic is an LSI Logic Parallel SCSI controller.
+	VirtualMachineVolumeControllerLsiLogic VirtualMachineVolumeControllerType = "LsiLogic"
+	// VirtualMachineVolumeControllerNVMe is an NVMe controller.
+	VirtualMachineVolumeControllerNVMe VirtualMachineVolumeControllerType = "NVMe"
+)
+
+const (
+	// MaxVolumeControllerBusNumber is the maximum bus number of a controller of each type.
+	MaxVolumeControllerBusNumber = 3
+	// MaxSCSIUnitNumber is the maximum unit number of a disk on a SCSI controller.
+	MaxSCSIUnitNumber = 15
+	// SCSIControllerUnitNumber is the unit number of a SCSI controller itself, which a disk cannot use.
+	SCSIControllerUnitNumber = 7
+	// MaxNVMeUnitNumber is the maximum unit number of a disk on an NVMe controller.
+	MaxNVMeUnitNumber = 14
+)
+
+// VirtualMachineVolumePlacement is the controller and unit a volume of a VirtualMachine is attached to.
+type VirtualMachineVolumePlacement struct {
+	// VolumeName is the name of the volume in the spec of the VirtualMachine. It must be a vSphere volume.
+	VolumeName string `json:"volumeName"`
+
+	// ControllerType is the type of the controller. Defaults to ParaVirtualSCSI.
+	// +optional
+	ControllerType VirtualMachineVolumeControllerType `json:"controllerType,omitempty"`
+
+	// BusNumber is the bus number of the controller. Defaults to 0.
+	// +optional
+	BusNumber *int32 `json:"busNumber,omitempty"`
+
+	// UnitNumber is the unit number of the volume on the controller. Defaults to the first free unit.
+	// +optional
+	UnitNumber *int32 `json:"unitNumber,omitempty"`
+}
+
+// GetControllerType returns the controller type of the placement, or its default.
+func (p VirtualMachineVolumePlacement) GetControllerType() VirtualMachineVolumeControllerType {
+	if p.ControllerType == "" {
+		return VirtualMachineVolumeControllerParaVirtualSCSI
+	}
+	return p.ControllerType
+}
+
+// GetBusNumber returns the bus number of the placement, or its default.
+func (p VirtualMachineVolumePlacement) GetBusNumber() int32 {
+	if p.BusNumber == nil {
+		return 0
+	}
+	return *p.BusNumber
+}
+
+// GetVolumePlacements returns the VirtualMachineVolumePlacements of the VM, or nil if the VM does not have
+// the placement annotation.
+func GetVolumePlacements(vm metav1.Object) ([]VirtualMachineVolumePlacement, error) {
+	data, ok := vm.GetAnnotations()[VirtualMachineVolumePlacementAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	var placements []VirtualMachineVolumePlacement
+	if err := json.Unmarshal([]byte(data), &placements); err != nil {
+		return nil, err
+	}
+
+	return placements, nil
+}
+
+// Conditions and condition Reasons for the VirtualMachine object.
+
+const (
+	// VirtualMachineVolumesPlacedCondition documents that the volumes of the VM are attached to the controllers
+	// and units requested by the VirtualMachineVolumePlacementAnnotation. The condition is not present when the
+	// VM does not have the annotation.
+	VirtualMachineVolumesPlacedCondition vmopv1alpha1.ConditionType = "VirtualMachineVolumesPlaced"
+
+	// VirtualMachineVolumePlacementMismatchReason (Severity=Warning) documents that an attached volume is not
+	// on its requested controller or unit.
+	VirtualMachineVolumePlacementMismatchReason = "PlacementMismatch"
+
+	// VirtualMachineVolumePlacementInvalidReason (Severity=Error) documents that the
+	// VirtualMachineVolumePlacementAnnotation cannot be parsed.
+	VirtualMachineVolumePlacementInvalidReason = "PlacementInvalid"
+)
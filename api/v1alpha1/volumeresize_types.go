@@ -0,0 +1,26 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+// Conditions and condition Reasons for the VirtualMachine object.
+
+const (
+	// VirtualMachineVolumesResizedCondition documents the expansion of the PersistentVolumeClaims of the
+	// volumes of the VM. The condition is not present when no volume of the VM was ever expanded. Failures
+	// to expand a vSphere volume are in the Error of the volume's status.
+	VirtualMachineVolumesResizedCondition vmopv1alpha1.ConditionType = "VirtualMachineVolumesResized"
+
+	// VirtualMachineVolumeResizingReason (Severity=Info) documents that the capacity of a
+	// PersistentVolumeClaim of the VM is being expanded by the storage provider.
+	VirtualMachineVolumeResizingReason = "Resizing"
+
+	// VirtualMachineVolumeFileSystemResizePendingReason (Severity=Info) documents that the capacity of a
+	// PersistentVolumeClaim of the VM was expanded, and that the file system in the guest still has to be
+	// resized.
+	VirtualMachineVolumeFileSystemResizePendingReason = "FileSystemResizePending"
+)
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -19,19 +21,37 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/lib"
+	"github.com/acharyasreej/vm-operator/pkg/metrics"
 	"github.com/acharyasreej/vm-operator/pkg/record"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
 )
 
 const (
 	finalizerName = "contentsource.vmoperator.vmware.com"
+
+	// fullResyncInterval is the interval after which the images of a ContentSource are synced even when
+	// the change token of its content library did not change, to repair changes made to the images on
+	// the API server, and to pick up changes to the content of existing items of a local library, which
+	// do not change the token.
+	fullResyncInterval = time.Hour
 )
 
+// retentionAnnotations are the annotations of a VirtualMachineImage that are set by the image retention
+// controller, and so are kept when the image is updated from its content library item.
+var retentionAnnotations = []string{
+	vmopapi.VirtualMachineImageUsageCountAnnotation,
+	vmopapi.VirtualMachineImageUnusedSinceAnnotation,
+}
+
 // AddToManager adds this package's controller to the provided manager.
 func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
 	var (
@@ -49,11 +69,26 @@ func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) er
 		ctx.VMProvider,
 	)
 
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(controlledType).
 		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
-		Owns(&vmopv1alpha1.ContentLibraryProvider{}).
-		Complete(r)
+		Owns(&vmopv1alpha1.ContentLibraryProvider{})
+
+	if interval := lib.GetContentLibraryPollInterval(); interval > 0 {
+		poller := newChangePoller(
+			mgr.GetClient(),
+			ctrl.Log.WithName("controllers").WithName(controlledTypeName).WithName("poller"),
+			r,
+			interval,
+		)
+		if err := mgr.Add(poller); err != nil {
+			return err
+		}
+
+		builder = builder.Watches(&source.Channel{Source: poller.events}, &handler.EnqueueRequestForObject{})
+	}
+
+	return builder.Complete(r)
 }
 
 func NewReconciler(
@@ -62,19 +97,88 @@ func NewReconciler(
 	recorder record.Recorder,
 	vmProvider vmprovider.VirtualMachineProviderInterface) *Reconciler {
 	return &Reconciler{
-		Client:     client,
-		Logger:     logger,
-		Recorder:   recorder,
-		VMProvider: vmProvider,
+		Client:       client,
+		Logger:       logger,
+		Recorder:     recorder,
+		VMProvider:   vmProvider,
+		syncedTokens: map[string]syncedToken{},
 	}
 }
 
+// syncedToken is the change token of a content library when the images of its ContentSource were last synced.
+type syncedToken struct {
+	token string
+	// resourceVersion is the resource version of the ContentSource at the sync. The images are synced again when
+	// the ContentSource changes, like when its imported or published item annotation is updated after the content
+	// of an existing item is replaced, which does not change the change token.
+	resourceVersion string
+	syncTime        time.Time
+}
+
 // Reconciler reconciles a ContentSource object.
 type Reconciler struct {
 	client.Client
 	Logger     logr.Logger
 	Recorder   record.Recorder
 	VMProvider vmprovider.VirtualMachineProviderInterface
+
+	syncedTokensMutex sync.Mutex
+	// syncedTokens are the synced change tokens keyed by ContentSource name.
+	syncedTokens map[string]syncedToken
+}
+
+// GetChangeToken returns the change token of the content library of the ContentSource, or an empty string
+// if the change token cannot be determined.
+func (r *Reconciler) GetChangeToken(ctx goctx.Context, contentSource *vmopv1alpha1.ContentSource) string {
+	providerRef := contentSource.Spec.ProviderRef
+	if providerRef.Kind != "ContentLibraryProvider" {
+		return ""
+	}
+
+	clProvider := &vmopv1alpha1.ContentLibraryProvider{}
+	if err := r.Get(ctx, client.ObjectKey{Name: providerRef.Name, Namespace: providerRef.Namespace}, clProvider); err != nil {
+		return ""
+	}
+
+	token, err := r.VMProvider.GetContentLibraryChangeToken(ctx, clProvider.Spec.UUID)
+	if err != nil {
+		r.Logger.Error(err, "failed to get content library change token", "contentLibraryUUID", clProvider.Spec.UUID)
+		return ""
+	}
+
+	return token
+}
+
+// getSyncedToken returns the change token of the content library of the ContentSource at the last sync.
+func (r *Reconciler) getSyncedToken(contentSourceName string) (syncedToken, bool) {
+	r.syncedTokensMutex.Lock()
+	defer r.syncedTokensMutex.Unlock()
+
+	synced, ok := r.syncedTokens[contentSourceName]
+	return synced, ok
+}
+
+func (r *Reconciler) setSyncedToken(contentSource *vmopv1alpha1.ContentSource, token string) {
+	r.syncedTokensMutex.Lock()
+	defer r.syncedTokensMutex.Unlock()
+
+	if token == "" {
+		delete(r.syncedTokens, contentSource.Name)
+		return
+	}
+	r.syncedTokens[contentSource.Name] = syncedToken{
+		token:           token,
+		resourceVersion: contentSource.ResourceVersion,
+		syncTime:        time.Now(),
+	}
+}
+
+// IsSyncNeeded returns true if the content library or the ContentSource changed since the last sync of the
+// ContentSource, or the last sync is older than the full resync interval.
+func (r *Reconciler) IsSyncNeeded(contentSource *vmopv1alpha1.ContentSource, token string) bool {
+	synced, ok := r.getSyncedToken(contentSource.Name)
+	return token == "" || !ok || synced.token != token || synced.resourceVersion != contentSource.ResourceVersion ||
+		time.Since(synced.syncTime) > fullResyncInterval
 }
 
 // CreateImages creates a set of VirtualMachineImages in a best effort manner.
@@ -220,7 +324,7 @@ func (r *Reconciler) DiffImages(
 			// Image already exists on the API server.
 			beforeUpdate := image.DeepCopy()
 			// Identify updated items.
-			image.Annotations = providerImages[i].Annotations
+			image.Annotations = mergeRetentionAnnotations(providerImages[i].Annotations, beforeUpdate.Annotations)
 			image.OwnerReferences = providerImages[i].OwnerReferences
 			image.Spec = providerImages[i].Spec
 			image.Status = providerImages[i].Status
@@ -242,6 +346,25 @@ func (r *Reconciler) DiffImages(
 	return added, removed, updated
 }
 
+// mergeRetentionAnnotations returns a copy of the annotations of a provider image with the retention
+// annotations of the existing image.
+func mergeRetentionAnnotations(providerAnnotations, existingAnnotations map[string]string) map[string]string {
+	annotations := make(map[string]string, len(providerAnnotations)+len(retentionAnnotations))
+	for k, v := range providerAnnotations {
+		annotations[k] = v
+	}
+	for _, key := range retentionAnnotations {
+		if v, ok := existingAnnotations[key]; ok {
+			annotations[key] = v
+		}
+	}
+
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
 // GetImagesFromContentProvider fetches the VM images from a given content provider. Also sets the owner ref in the images.
 func (r *Reconciler) GetImagesFromContentProvider(
 	ctx goctx.Context,
@@ -326,7 +449,14 @@ func (r *Reconciler) DifferenceImages(ctx goctx.Context,
 }
 
 // SyncImages syncs images from the given content sources.
-func (r *Reconciler) SyncImages(ctx goctx.Context, contentSource *vmopv1alpha1.ContentSource) error {
+func (r *Reconciler) SyncImages(ctx goctx.Context, contentSource *vmopv1alpha1.ContentSource) (retErr error) {
+	start := time.Now()
+
+	var added, removed, updated []vmopv1alpha1.VirtualMachineImage
+	defer func() {
+		metrics.ObserveContentSourceSync(contentSource.Name, time.Since(start), len(added), len(removed), len(updated), retErr)
+	}()
+
 	added, removed, updated, err := r.DifferenceImages(ctx, contentSource)
 	if err != nil {
 		r.Logger.Error(err, "failed to difference images")
@@ -443,10 +573,17 @@ func (r *Reconciler) ReconcileNormal(ctx goctx.Context, contentSource *vmopv1alp
 		return err
 	}
 
+	token := r.GetChangeToken(ctx, contentSource)
+	if !r.IsSyncNeeded(contentSource, token) {
+		logger.Info("Content library and ContentSource have not changed since the last sync, skipping image sync")
+		return nil
+	}
+
 	if err := r.SyncImages(ctx, contentSource); err != nil {
 		logger.Error(err, "Error in syncing image from the content provider")
 		return err
 	}
+	r.setSyncedToken(contentSource, token)
 
 	logger.Info("Finished reconciling ContentSource")
 	return nil
@@ -472,6 +609,9 @@ func (r *Reconciler) ReconcileDelete(ctx goctx.Context, contentSource *vmopv1alp
 		if err := r.Update(ctx, contentSource); err != nil {
 			return err
 		}
+
+		metrics.DeleteContentSourceMetrics(contentSource.Name)
+		r.setSyncedToken(contentSource, "")
 	}
 
 	return nil
@@ -17,6 +17,7 @@ import (
 
 	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/controllers/contentsource"
 	providerfake "github.com/acharyasreej/vm-operator/pkg/vmprovider/fake"
 	"github.com/acharyasreej/vm-operator/test/builder"
@@ -26,6 +27,7 @@ func unitTests() {
 	Describe("Invoking VirtualMachineImage CRUD unit tests", unitTestsCRUDImage)
 	Describe("Invoking ReconcileProviderRef unit tests", reconcileProviderRef)
 	Describe("Invoking IsImageOwnedByContentLibrary unit tests", unitTestIsImageOwnedByContentLibrary)
+	Describe("Invoking incremental sync unit tests", unitTestsIncrementalSync)
 }
 
 func reconcileProviderRef() {
@@ -669,6 +671,41 @@ func unitTestsCRUDImage() {
 				})
 			})
 
+			When("the k8s image has the image retention annotations", func() {
+				BeforeEach(func() {
+					imageK8s = v1alpha1.VirtualMachineImage{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								vmopapi.VirtualMachineImageUsageCountAnnotation:  "0",
+								vmopapi.VirtualMachineImageUnusedSinceAnnotation: "2021-01-01T00:00:00Z",
+								"key": "old-value",
+							},
+						},
+					}
+
+					imageProvider = v1alpha1.VirtualMachineImage{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								"key": "value",
+							},
+						},
+					}
+				})
+
+				It("should keep the image retention annotations", func() {
+					added, removed, updated := reconciler.DiffImages(cl.Name, k8sImages, providerImages)
+					Expect(added).To(BeEmpty())
+					Expect(removed).To(BeEmpty())
+					Expect(updated).To(HaveLen(1))
+					Expect(updated[0].Annotations).To(Equal(map[string]string{
+						vmopapi.VirtualMachineImageUsageCountAnnotation:  "0",
+						vmopapi.VirtualMachineImageUnusedSinceAnnotation: "2021-01-01T00:00:00Z",
+						"key": "value",
+					}))
+					Expect(providerImages[0].Annotations).To(HaveLen(1))
+				})
+			})
+
 			When("k8s and provider lists have different OwnerReference", func() {
 				var ownerRef = []metav1.OwnerReference{{
 					Name: "dummy-name",
@@ -1032,3 +1069,111 @@ func unitTestsCRUDImage() {
 		})
 	})
 }
+
+func unitTestsIncrementalSync() {
+	var (
+		ctx            *builder.UnitTestContextForController
+		reconciler     *contentsource.Reconciler
+		fakeVMProvider *providerfake.VMProvider
+		initObjects    []client.Object
+
+		cs        *v1alpha1.ContentSource
+		cl        *v1alpha1.ContentLibraryProvider
+		token     string
+		listCalls int
+	)
+
+	BeforeEach(func() {
+		cl = &v1alpha1.ContentLibraryProvider{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "dummy-cl",
+			},
+			Spec: v1alpha1.ContentLibraryProviderSpec{
+				UUID: "dummy-cl-uuid",
+			},
+		}
+		cs = &v1alpha1.ContentSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "dummy-cs",
+			},
+			Spec: v1alpha1.ContentSourceSpec{
+				ProviderRef: v1alpha1.ContentProviderReference{
+					Name: cl.Name,
+					Kind: "ContentLibraryProvider",
+				},
+			},
+		}
+		initObjects = append(initObjects, cl, cs)
+		token = "token-1"
+		listCalls = 0
+	})
+
+	JustBeforeEach(func() {
+		ctx = suite.NewUnitTestContextForController(initObjects...)
+		reconciler = contentsource.NewReconciler(
+			ctx.Client,
+			ctx.Logger,
+			ctx.Recorder,
+			ctx.VMProvider,
+		)
+		fakeVMProvider = ctx.VMProvider.(*providerfake.VMProvider)
+
+		fakeVMProvider.GetContentLibraryChangeTokenFn = func(_ context.Context, clUUID string) (string, error) {
+			Expect(clUUID).To(Equal(cl.Spec.UUID))
+			return token, nil
+		}
+		fakeVMProvider.ListVirtualMachineImagesFromContentLibraryFn = func(_ context.Context, _ v1alpha1.ContentLibraryProvider, _ map[string]v1alpha1.VirtualMachineImage) ([]*v1alpha1.VirtualMachineImage, error) {
+			listCalls++
+			return nil, nil
+		}
+	})
+
+	AfterEach(func() {
+		ctx.AfterEach()
+		ctx = nil
+		initObjects = nil
+		reconciler = nil
+		fakeVMProvider.Reset()
+		fakeVMProvider = nil
+	})
+
+	It("skips the image sync when the content library did not change", func() {
+		Expect(reconciler.ReconcileNormal(ctx, cs)).To(Succeed())
+		Expect(listCalls).To(Equal(1))
+		Expect(reconciler.IsSyncNeeded(cs, token)).To(BeFalse())
+
+		Expect(reconciler.ReconcileNormal(ctx, cs)).To(Succeed())
+		Expect(listCalls).To(Equal(1))
+	})
+
+	It("syncs the images when the content library changed", func() {
+		Expect(reconciler.ReconcileNormal(ctx, cs)).To(Succeed())
+		Expect(listCalls).To(Equal(1))
+
+		token = "token-2"
+		Expect(reconciler.IsSyncNeeded(cs, token)).To(BeTrue())
+		Expect(reconciler.ReconcileNormal(ctx, cs)).To(Succeed())
+		Expect(listCalls).To(Equal(2))
+	})
+
+	It("syncs the images when the ContentSource changed", func() {
+		Expect(reconciler.ReconcileNormal(ctx, cs)).To(Succeed())
+		Expect(listCalls).To(Equal(1))
+
+		cs.Annotations = map[string]string{"vmoperator.vmware.com/imported-item": "ns/import/2"}
+		Expect(ctx.Client.Update(ctx, cs)).To(Succeed())
+		Expect(reconciler.IsSyncNeeded(cs, token)).To(BeTrue())
+		Expect(reconciler.ReconcileNormal(ctx, cs)).To(Succeed())
+		Expect(listCalls).To(Equal(2))
+	})
+
+	It("always syncs the images when the change token is unknown", func() {
+		fakeVMProvider.GetContentLibraryChangeTokenFn = func(_ context.Context, _ string) (string, error) {
+			return "", fmt.Errorf("token error")
+		}
+
+		Expect(reconciler.ReconcileNormal(ctx, cs)).To(Succeed())
+		Expect(reconciler.ReconcileNormal(ctx, cs)).To(Succeed())
+		Expect(listCalls).To(Equal(2))
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package contentsource
+
+import (
+	goctx "context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+// changePoller periodically polls the change tokens of the content libraries of the ContentSources, and
+// triggers a reconcile of the ContentSources whose content library changed since the last sync, so that
+// library changes are picked up without waiting for the next resync of the ContentSources.
+type changePoller struct {
+	client.Client
+	Logger     logr.Logger
+	reconciler *Reconciler
+	interval   time.Duration
+	events     chan event.GenericEvent
+}
+
+func newChangePoller(
+	client client.Client,
+	logger logr.Logger,
+	reconciler *Reconciler,
+	interval time.Duration) *changePoller {
+	return &changePoller{
+		Client:     client,
+		Logger:     logger,
+		reconciler: reconciler,
+		interval:   interval,
+		events:     make(chan event.GenericEvent),
+	}
+}
+
+// Start polls the content libraries until the context is done.
+func (p *changePoller) Start(ctx goctx.Context) error {
+	p.Logger.Info("Starting content library change poller", "interval", p.interval)
+	wait.UntilWithContext(ctx, p.Poll, p.interval)
+	return nil
+}
+
+// Poll triggers a reconcile of the ContentSources whose content library changed since the last sync.
+func (p *changePoller) Poll(ctx goctx.Context) {
+	csList := &vmopv1alpha1.ContentSourceList{}
+	if err := p.List(ctx, csList); err != nil {
+		p.Logger.Error(err, "failed to list ContentSources")
+		return
+	}
+
+	for i := range csList.Items {
+		cs := &csList.Items[i]
+
+		token := p.reconciler.GetChangeToken(ctx, cs)
+		if token == "" {
+			// Leave it to the periodic resync when the change token is unknown.
+			continue
+		}
+		if synced, ok := p.reconciler.getSyncedToken(cs.Name); ok && synced.token == token {
+			continue
+		}
+
+		p.Logger.V(4).Info("Content library changed, triggering reconcile", "contentSourceName", cs.Name)
+		select {
+		case p.events <- event.GenericEvent{Object: cs}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
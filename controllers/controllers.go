@@ -10,14 +10,20 @@ import (
 	"github.com/acharyasreej/vm-operator/pkg/context"
 
 	"github.com/acharyasreej/vm-operator/controllers/contentsource"
+	"github.com/acharyasreej/vm-operator/controllers/imageretention"
 	"github.com/acharyasreej/vm-operator/controllers/infracluster"
 	"github.com/acharyasreej/vm-operator/controllers/infraprovider"
 	"github.com/acharyasreej/vm-operator/controllers/providerconfigmap"
+	"github.com/acharyasreej/vm-operator/controllers/subscribedcontentlibrary"
 	"github.com/acharyasreej/vm-operator/controllers/virtualmachine"
 	"github.com/acharyasreej/vm-operator/controllers/virtualmachineclass"
 	"github.com/acharyasreej/vm-operator/controllers/virtualmachineimage"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachineimageimport"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachinepublishrequest"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachinereplicaset"
 	"github.com/acharyasreej/vm-operator/controllers/virtualmachineservice"
 	"github.com/acharyasreej/vm-operator/controllers/virtualmachinesetresourcepolicy"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachinesnapshot"
 	"github.com/acharyasreej/vm-operator/controllers/volume"
 )
 
@@ -26,6 +32,9 @@ func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) er
 	if err := contentsource.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize ContentSource controller")
 	}
+	if err := imageretention.AddToManager(ctx, mgr); err != nil {
+		return errors.Wrap(err, "failed to initialize ImageRetention controller")
+	}
 	if err := infracluster.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize InfraCluster controller")
 	}
@@ -35,6 +44,9 @@ func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) er
 	if err := providerconfigmap.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize ProviderConfigMap controller")
 	}
+	if err := subscribedcontentlibrary.AddToManager(ctx, mgr); err != nil {
+		return errors.Wrap(err, "failed to initialize SubscribedContentLibrary controller")
+	}
 	if err := virtualmachine.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize VirtualMachine controller")
 	}
@@ -44,12 +56,24 @@ func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) er
 	if err := virtualmachineimage.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize VirtualMachineImage controller")
 	}
+	if err := virtualmachineimageimport.AddToManager(ctx, mgr); err != nil {
+		return errors.Wrap(err, "failed to initialize VirtualMachineImageImport controller")
+	}
+	if err := virtualmachinepublishrequest.AddToManager(ctx, mgr); err != nil {
+		return errors.Wrap(err, "failed to initialize VirtualMachinePublishRequest controller")
+	}
+	if err := virtualmachinereplicaset.AddToManager(ctx, mgr); err != nil {
+		return errors.Wrap(err, "failed to initialize VirtualMachineReplicaSet controller")
+	}
 	if err := virtualmachineservice.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize VirtualMachineService controller")
 	}
 	if err := virtualmachinesetresourcepolicy.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize VirtualMachineSetResourcePolicy controller")
 	}
+	if err := virtualmachinesnapshot.AddToManager(ctx, mgr); err != nil {
+		return errors.Wrap(err, "failed to initialize VirtualMachineSnapshot controller")
+	}
 	if err := volume.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize Volume controller")
 	}
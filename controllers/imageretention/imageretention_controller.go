@@ -0,0 +1,483 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package imageretention
+
+import (
+	goctx "context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/providerconfigmap"
+	"github.com/acharyasreej/vm-operator/controllers/subscribedcontentlibrary"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/lib"
+	"github.com/acharyasreej/vm-operator/pkg/record"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+)
+
+const (
+	controllerName = "imageretention-controller"
+
+	// maxRequeueDelay is the maximum delay after which the images of a content library are reconciled
+	// again, so that images that became unused without a watch event are eventually garbage collected.
+	maxRequeueDelay = 10 * time.Minute
+
+	// Reasons of the events emitted by the controller.
+	deletedImageReason      = "DeletedImage"
+	deleteImageFailedReason = "DeleteImageFailed"
+	retentionDryRunReason   = "RetentionDryRun"
+)
+
+// tkrListGVK is the kind of the list of TanzuKubernetesReleases, whose node images are in use. The
+// TanzuKubernetesRelease API is not vendored, so the releases are read as unstructured objects.
+var tkrListGVK = schema.GroupVersionKind{
+	Group:   "run.tanzu.vmware.com",
+	Version: "v1alpha1",
+	Kind:    "TanzuKubernetesReleaseList",
+}
+
+// AddToManager adds this package's controller to the provided manager. The controller is only added when
+// an image retention count is set.
+func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	if lib.GetImageRetentionCount() == 0 {
+		ctx.Logger.Info("Image retention count is not set, unused images are not garbage collected")
+		return nil
+	}
+
+	controllerNameLong := fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerName)
+
+	r := NewReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("ImageRetention"),
+		record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		ctx.VMProvider,
+	)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&vmopv1alpha1.ContentLibraryProvider{}).
+		Watches(&source.Kind{Type: &vmopv1alpha1.VirtualMachine{}},
+			handler.EnqueueRequestsFromMapFunc(vmToContentLibraryProviderMapperFn(ctx, mgr.GetClient()))).
+		Watches(&source.Kind{Type: &vmopv1alpha1.ContentSource{}},
+			handler.EnqueueRequestsFromMapFunc(contentSourceToContentLibraryProviderMapperFn(ctx))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
+		Complete(r)
+}
+
+// vmToContentLibraryProviderMapperFn returns a mapper function that can be used to queue reconcile requests
+// for the ContentLibraryProvider of the image of the VirtualMachine, so that the usage of the image is
+// updated when the VirtualMachine is created or deleted.
+func vmToContentLibraryProviderMapperFn(ctx *context.ControllerManagerContext, c client.Reader) func(o client.Object) []reconcile.Request {
+	return func(o client.Object) []reconcile.Request {
+		vm := o.(*vmopv1alpha1.VirtualMachine)
+		if vm.Spec.ImageName == "" {
+			return nil
+		}
+		logger := ctx.Logger.WithValues("name", vm.NamespacedName(), "imageName", vm.Spec.ImageName)
+
+		image := &vmopv1alpha1.VirtualMachineImage{}
+		if err := c.Get(ctx, client.ObjectKey{Name: vm.Spec.ImageName}, image); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				logger.Error(err, "Failed to get VirtualMachineImage for reconciliation due to VirtualMachine watch")
+			}
+			return nil
+		}
+
+		providerName := getContentLibraryProviderName(image)
+		if providerName == "" {
+			return nil
+		}
+
+		logger.V(4).Info("Returning ContentLibraryProvider reconcile request due to VirtualMachine watch", "providerName", providerName)
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: providerName}}}
+	}
+}
+
+// contentSourceToContentLibraryProviderMapperFn returns a mapper function that can be used to queue reconcile
+// requests for the ContentLibraryProvider of the ContentSource, so that the images of the library are garbage
+// collected as soon as the library is opted into the image retention.
+func contentSourceToContentLibraryProviderMapperFn(ctx *context.ControllerManagerContext) func(o client.Object) []reconcile.Request {
+	return func(o client.Object) []reconcile.Request {
+		contentSource := o.(*vmopv1alpha1.ContentSource)
+		providerRef := contentSource.Spec.ProviderRef
+		if providerRef.Kind != "ContentLibraryProvider" || providerRef.Name == "" {
+			return nil
+		}
+
+		ctx.Logger.V(4).Info("Returning ContentLibraryProvider reconcile request due to ContentSource watch",
+			"contentSourceName", contentSource.Name, "providerName", providerRef.Name)
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: providerRef.Name}}}
+	}
+}
+
+func NewReconciler(
+	client client.Client,
+	logger logr.Logger,
+	recorder record.Recorder,
+	vmProvider vmprovider.VirtualMachineProviderInterface) *Reconciler {
+	return &Reconciler{
+		Client:         client,
+		Logger:         logger,
+		Recorder:       recorder,
+		VMProvider:     vmProvider,
+		RetentionCount: lib.GetImageRetentionCount(),
+		GracePeriod:    lib.GetImageRetentionGracePeriod(),
+		DryRun:         lib.IsImageRetentionDryRun(),
+	}
+}
+
+// Reconciler garbage collects the unused VirtualMachineImages of a ContentLibraryProvider whose ContentSource
+// opted into the image retention, and whose library is writable. The newest RetentionCount images of each
+// image family, by the creation time of their library items, and the images that are used by a
+// VirtualMachine, by the template of a VirtualMachineReplicaSet or by a TanzuKubernetesRelease, are kept.
+// The content library items of the other images are deleted once the images have been unused for the
+// GracePeriod, or only reported when DryRun is set.
+type Reconciler struct {
+	client.Client
+	Logger         logr.Logger
+	Recorder       record.Recorder
+	VMProvider     vmprovider.VirtualMachineProviderInterface
+	RetentionCount int
+	GracePeriod    time.Duration
+	DryRun         bool
+}
+
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentlibraryproviders,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentsources,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachineimages,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachinereplicasets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=run.tanzu.vmware.com,resources=tanzukubernetesreleases,verbs=get;list;watch
+
+func (r *Reconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (ctrl.Result, error) {
+	clProvider := &vmopv1alpha1.ContentLibraryProvider{}
+	if err := r.Get(ctx, req.NamespacedName, clProvider); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !clProvider.DeletionTimestamp.IsZero() {
+		// The images of the library are deleted with the ContentLibraryProvider.
+		return ctrl.Result{}, nil
+	}
+
+	logger := r.Logger.WithValues("providerName", clProvider.Name)
+	retained, err := r.isRetentionEnabled(ctx, clProvider)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !retained {
+		logger.V(4).Info("Image retention is not enabled for the content library")
+		return ctrl.Result{}, nil
+	}
+
+	requeueAfter, err := r.ReconcileNormal(ctx, logger, clProvider)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// isRetentionEnabled returns whether the images of the ContentLibraryProvider are garbage collected. The
+// ContentSource of the library must opt into the image retention, and the library must be writable. The
+// images of subscribed libraries, whose items are replaced by their publishers, and of the TKG library
+// are never garbage collected.
+func (r *Reconciler) isRetentionEnabled(
+	ctx goctx.Context,
+	clProvider *vmopv1alpha1.ContentLibraryProvider) (bool, error) {
+
+	csList := &vmopv1alpha1.ContentSourceList{}
+	if err := r.List(ctx, csList); err != nil {
+		return false, errors.Wrap(err, "failed to list ContentSources")
+	}
+
+	var contentSource *vmopv1alpha1.ContentSource
+	for i := range csList.Items {
+		providerRef := csList.Items[i].Spec.ProviderRef
+		if providerRef.Kind == "ContentLibraryProvider" && providerRef.Name == clProvider.Name {
+			contentSource = &csList.Items[i]
+			break
+		}
+	}
+
+	switch {
+	case contentSource == nil:
+		return false, nil
+	case contentSource.Annotations[vmopapi.ContentSourceImageRetentionAnnotation] != "true":
+		return false, nil
+	case contentSource.Labels[providerconfigmap.TKGContentSourceLabelKey] == providerconfigmap.TKGContentSourceLabelValue:
+		return false, nil
+	case contentSource.Labels[subscribedcontentlibrary.SubscribedContentLibraryLabelKey] != "":
+		return false, nil
+	}
+
+	writable, err := r.VMProvider.IsContentLibraryWritable(ctx, clProvider.Spec.UUID)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get whether content library %s is writable", clProvider.Spec.UUID)
+	}
+
+	return writable, nil
+}
+
+// ReconcileNormal updates the usage of the images of the ContentLibraryProvider, and garbage collects the
+// images that are no longer retained. It returns the delay after which the images should be reconciled
+// again.
+func (r *Reconciler) ReconcileNormal(
+	ctx goctx.Context,
+	logger logr.Logger,
+	clProvider *vmopv1alpha1.ContentLibraryProvider) (time.Duration, error) {
+
+	images, err := r.getProviderImages(ctx, clProvider)
+	if err != nil {
+		return 0, err
+	}
+
+	usage, inUseImages, err := r.getImageUsage(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	families := map[string][]*vmopv1alpha1.VirtualMachineImage{}
+	for _, image := range images {
+		family := GetImageFamily(image)
+		families[family] = append(families[family], image)
+	}
+
+	now := time.Now()
+	requeueAfter := maxRequeueDelay
+	var errs []string
+
+	for _, familyImages := range families {
+		// Newest versions first. The CreationTimestamp of an image is when the image was created by the
+		// ContentSource, which is not when its version was added to the library, and the version of a library
+		// item only counts the changes of that item, so the images are ordered by the creation time of their
+		// library items.
+		sort.SliceStable(familyImages, func(i, j int) bool {
+			ti, oki := getItemCreationTime(familyImages[i])
+			tj, okj := getItemCreationTime(familyImages[j])
+			if oki != okj {
+				return oki
+			}
+			if !ti.Equal(tj) {
+				return tj.Before(ti)
+			}
+			return familyImages[i].Name > familyImages[j].Name
+		})
+
+		for i, image := range familyImages {
+			count := usage[image.Name]
+			_, inUse := inUseImages[image.Name]
+			_, hasCreationTime := getItemCreationTime(image)
+			retained := i < r.RetentionCount || count > 0 || inUse || !hasCreationTime
+
+			remaining, err := r.reconcileImage(ctx, logger, image, count, retained, now)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			if remaining > 0 && remaining < requeueAfter {
+				requeueAfter = remaining
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return 0, errors.Errorf("failed to reconcile the retention of images: %s", strings.Join(errs, "; "))
+	}
+
+	return requeueAfter, nil
+}
+
+// reconcileImage updates the usage annotations of the image, and garbage collects the image when it is
+// not retained and its grace period has passed. It returns the remaining grace period of the image.
+func (r *Reconciler) reconcileImage(
+	ctx goctx.Context,
+	logger logr.Logger,
+	image *vmopv1alpha1.VirtualMachineImage,
+	usageCount int,
+	retained bool,
+	now time.Time) (time.Duration, error) {
+
+	logger = logger.WithValues("imageName", image.Name)
+	origImage := image.DeepCopy()
+
+	if image.Annotations == nil {
+		image.Annotations = map[string]string{}
+	}
+	image.Annotations[vmopapi.VirtualMachineImageUsageCountAnnotation] = strconv.Itoa(usageCount)
+
+	var remaining time.Duration
+	if retained {
+		delete(image.Annotations, vmopapi.VirtualMachineImageUnusedSinceAnnotation)
+	} else {
+		unusedSince, err := time.Parse(time.RFC3339, image.Annotations[vmopapi.VirtualMachineImageUnusedSinceAnnotation])
+		if err != nil {
+			unusedSince = now
+			image.Annotations[vmopapi.VirtualMachineImageUnusedSinceAnnotation] = now.UTC().Format(time.RFC3339)
+		}
+		remaining = unusedSince.Add(r.GracePeriod).Sub(now)
+	}
+
+	if err := r.Patch(ctx, image, client.MergeFrom(origImage)); err != nil {
+		return 0, errors.Wrapf(err, "failed to update usage of VirtualMachineImage %s", image.Name)
+	}
+
+	if retained || remaining > 0 {
+		return remaining, nil
+	}
+
+	if r.DryRun {
+		logger.Info("Would garbage collect unused VirtualMachineImage", "itemID", image.Spec.ImageID)
+		r.Recorder.Eventf(image, retentionDryRunReason,
+			"Would delete the content library item %s of the unused image", image.Spec.ImageID)
+		return 0, nil
+	}
+
+	logger.Info("Garbage collecting unused VirtualMachineImage", "itemID", image.Spec.ImageID)
+	if err := r.VMProvider.DeleteContentLibraryItem(ctx, image.Spec.ImageID); err != nil {
+		r.Recorder.Warnf(image, deleteImageFailedReason,
+			"Failed to delete the content library item %s of the unused image: %v", image.Spec.ImageID, err)
+		return 0, errors.Wrapf(err, "failed to delete content library item of VirtualMachineImage %s", image.Name)
+	}
+
+	if err := r.Delete(ctx, image); client.IgnoreNotFound(err) != nil {
+		return 0, errors.Wrapf(err, "failed to delete VirtualMachineImage %s", image.Name)
+	}
+	r.Recorder.Eventf(image, deletedImageReason,
+		"Deleted the content library item %s of the unused image", image.Spec.ImageID)
+
+	return 0, nil
+}
+
+// getProviderImages returns the VirtualMachineImages of the content library of the ContentLibraryProvider.
+func (r *Reconciler) getProviderImages(
+	ctx goctx.Context,
+	clProvider *vmopv1alpha1.ContentLibraryProvider) ([]*vmopv1alpha1.VirtualMachineImage, error) {
+
+	imageList := &vmopv1alpha1.VirtualMachineImageList{}
+	if err := r.List(ctx, imageList); err != nil {
+		return nil, errors.Wrap(err, "failed to list VirtualMachineImages")
+	}
+
+	var images []*vmopv1alpha1.VirtualMachineImage
+	for i := range imageList.Items {
+		image := &imageList.Items[i]
+		if image.DeletionTimestamp.IsZero() && getContentLibraryProviderName(image) == clProvider.Name {
+			images = append(images, image)
+		}
+	}
+
+	return images, nil
+}
+
+// getImageUsage returns the number of VirtualMachines that use each image, and the images of the templates
+// of the VirtualMachineReplicaSets and the node images of the TanzuKubernetesReleases, which are in use even
+// when there is no VirtualMachine of the image.
+func (r *Reconciler) getImageUsage(ctx goctx.Context) (map[string]int, map[string]struct{}, error) {
+	usage := map[string]int{}
+	vmList := &vmopv1alpha1.VirtualMachineList{}
+	if err := r.List(ctx, vmList); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list VirtualMachines")
+	}
+	for _, vm := range vmList.Items {
+		usage[vm.Spec.ImageName]++
+	}
+
+	inUseImages := map[string]struct{}{}
+	rsList := &vmopapi.VirtualMachineReplicaSetList{}
+	if err := r.List(ctx, rsList); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list VirtualMachineReplicaSets")
+	}
+	for _, rs := range rsList.Items {
+		inUseImages[rs.Spec.Template.Spec.ImageName] = struct{}{}
+	}
+
+	tkrImages, err := r.getTKRImages(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range tkrImages {
+		inUseImages[name] = struct{}{}
+	}
+
+	return usage, inUseImages, nil
+}
+
+// getTKRImages returns the names of the node images of the TanzuKubernetesReleases. No images are returned
+// when the TanzuKubernetesRelease API is not installed.
+func (r *Reconciler) getTKRImages(ctx goctx.Context) ([]string, error) {
+	tkrList := &unstructured.UnstructuredList{}
+	tkrList.SetGroupVersionKind(tkrListGVK)
+	if err := r.List(ctx, tkrList); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to list TanzuKubernetesReleases")
+	}
+
+	var names []string
+	for _, tkr := range tkrList.Items {
+		name, _, err := unstructured.NestedString(tkr.Object, "spec", "nodeImageRef", "name")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get node image of TanzuKubernetesRelease %s", tkr.GetName())
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// getItemCreationTime returns the creation time of the library item of the image, and whether the image has
+// a valid creation time annotation.
+func getItemCreationTime(image *vmopv1alpha1.VirtualMachineImage) (time.Time, bool) {
+	creationTime, err := time.Parse(time.RFC3339, image.Annotations[vmopapi.VirtualMachineImageItemCreationTimeAnnotation])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return creationTime, true
+}
+
+// GetImageFamily returns the family of the image, whose newest versions are kept. The family is the value
+// of the family label of the image, else the vendor and product of the OVF of the image, else the name of
+// the image.
+func GetImageFamily(image *vmopv1alpha1.VirtualMachineImage) string {
+	if family := image.Labels[vmopapi.VirtualMachineImageFamilyLabel]; family != "" {
+		return family
+	}
+	if product := image.Spec.ProductInfo.Product; product != "" {
+		return image.Spec.ProductInfo.Vendor + "/" + product
+	}
+	return image.Name
+}
+
+// getContentLibraryProviderName returns the name of the ContentLibraryProvider that owns the image.
+func getContentLibraryProviderName(image *vmopv1alpha1.VirtualMachineImage) string {
+	for _, ref := range image.OwnerReferences {
+		if ref.Kind == "ContentLibraryProvider" {
+			return ref.Name
+		}
+	}
+	return ""
+}
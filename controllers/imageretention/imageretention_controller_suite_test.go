@@ -0,0 +1,35 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package imageretention_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+
+	ctrlmgr "sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/acharyasreej/vm-operator/controllers/imageretention"
+	ctrlContext "github.com/acharyasreej/vm-operator/pkg/context"
+	providerfake "github.com/acharyasreej/vm-operator/pkg/vmprovider/fake"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+var intgFakeVMProvider = providerfake.NewVMProvider()
+
+var suite = builder.NewTestSuiteForController(
+	imageretention.AddToManager,
+	func(ctx *ctrlContext.ControllerManagerContext, _ ctrlmgr.Manager) error {
+		ctx.VMProvider = intgFakeVMProvider
+		return nil
+	},
+)
+
+func TestImageRetention(t *testing.T) {
+	suite.Register(t, "ImageRetention controller suite", nil, unitTests)
+}
+
+var _ = BeforeSuite(suite.BeforeSuite)
+
+var _ = AfterSuite(suite.AfterSuite)
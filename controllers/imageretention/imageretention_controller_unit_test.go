@@ -0,0 +1,359 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package imageretention_test
+
+import (
+	goctx "context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/imageretention"
+	"github.com/acharyasreej/vm-operator/controllers/providerconfigmap"
+	providerfake "github.com/acharyasreej/vm-operator/pkg/vmprovider/fake"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func unitTests() {
+	Describe("Invoking Reconcile", unitTestsReconcile)
+	Describe("GetImageFamily", unitTestsGetImageFamily)
+}
+
+// newImage returns an image whose library item was created at the time. The CreationTimestamp of the image
+// is the reverse of the creation time of the item, since it is not when the version was added to the library.
+func newImage(name, providerName string, created time.Time) *vmopv1alpha1.VirtualMachineImage {
+	return &vmopv1alpha1.VirtualMachineImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created.Add(2 * time.Since(created))),
+			Annotations: map[string]string{
+				vmopapi.VirtualMachineImageItemCreationTimeAnnotation: created.UTC().Format(time.RFC3339),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "vmoperator.vmware.com/v1alpha1",
+					Kind:       "ContentLibraryProvider",
+					Name:       providerName,
+				},
+			},
+		},
+		Spec: vmopv1alpha1.VirtualMachineImageSpec{
+			ImageID: name + "-item-id",
+			ProductInfo: vmopv1alpha1.VirtualMachineImageProductInfo{
+				Vendor:  "vendor",
+				Product: "product",
+			},
+		},
+	}
+}
+
+func unitTestsReconcile() {
+	const (
+		clUUID = "dummy-cl-uuid"
+	)
+
+	var (
+		initObjects    []client.Object
+		ctx            *builder.UnitTestContextForController
+		reconciler     *imageretention.Reconciler
+		fakeVMProvider *providerfake.VMProvider
+
+		clProvider    *vmopv1alpha1.ContentLibraryProvider
+		contentSource *vmopv1alpha1.ContentSource
+		now           time.Time
+		deleted       []string
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		clProvider = &vmopv1alpha1.ContentLibraryProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: clUUID},
+			Spec:       vmopv1alpha1.ContentLibraryProviderSpec{UUID: clUUID},
+		}
+		contentSource = &vmopv1alpha1.ContentSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: clUUID,
+				Annotations: map[string]string{
+					vmopapi.ContentSourceImageRetentionAnnotation: "true",
+				},
+			},
+			Spec: vmopv1alpha1.ContentSourceSpec{
+				ProviderRef: vmopv1alpha1.ContentProviderReference{
+					APIVersion: "vmoperator.vmware.com/v1alpha1",
+					Kind:       "ContentLibraryProvider",
+					Name:       clUUID,
+				},
+			},
+		}
+
+		initObjects = append(initObjects,
+			clProvider,
+			contentSource,
+			newImage("image-v1", clUUID, now.Add(-3*time.Hour)),
+			newImage("image-v2", clUUID, now.Add(-2*time.Hour)),
+			newImage("image-v3", clUUID, now.Add(-1*time.Hour)),
+			newImage("other-provider-image", "other-cl-uuid", now.Add(-4*time.Hour)),
+		)
+	})
+
+	JustBeforeEach(func() {
+		ctx = suite.NewUnitTestContextForController(initObjects...)
+		reconciler = imageretention.NewReconciler(
+			ctx.Client,
+			ctx.Logger,
+			ctx.Recorder,
+			ctx.VMProvider,
+		)
+		reconciler.RetentionCount = 1
+		reconciler.GracePeriod = time.Hour
+
+		fakeVMProvider = ctx.VMProvider.(*providerfake.VMProvider)
+		fakeVMProvider.DeleteContentLibraryItemFn = func(_ goctx.Context, itemID string) error {
+			deleted = append(deleted, itemID)
+			return nil
+		}
+	})
+
+	AfterEach(func() {
+		ctx.AfterEach()
+		ctx = nil
+		initObjects = nil
+		reconciler = nil
+		fakeVMProvider = nil
+		deleted = nil
+	})
+
+	getImage := func(name string) *vmopv1alpha1.VirtualMachineImage {
+		image := &vmopv1alpha1.VirtualMachineImage{}
+		Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: name}, image)).To(Succeed())
+		return image
+	}
+
+	setUnusedSince := func(name string, unusedSince time.Time) {
+		image := getImage(name)
+		image.Annotations[vmopapi.VirtualMachineImageUnusedSinceAnnotation] = unusedSince.UTC().Format(time.RFC3339)
+		Expect(ctx.Client.Update(ctx, image)).To(Succeed())
+	}
+
+	reconcileNormal := func() time.Duration {
+		requeueAfter, err := reconciler.ReconcileNormal(ctx, ctx.Logger, clProvider)
+		Expect(err).ToNot(HaveOccurred())
+		return requeueAfter
+	}
+
+	Context("Reconcile", func() {
+		reconcile := func() {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Name: clUUID}})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		It("will mark the unused images of an opted-in writable library", func() {
+			reconcile()
+			Expect(getImage("image-v1").Annotations).To(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+		})
+
+		When("the ContentSource did not opt into the image retention", func() {
+			BeforeEach(func() {
+				contentSource.Annotations = nil
+			})
+
+			It("will not garbage collect the images", func() {
+				reconcile()
+				Expect(getImage("image-v1").Annotations).ToNot(HaveKey(vmopapi.VirtualMachineImageUsageCountAnnotation))
+			})
+		})
+
+		When("the ContentSource is of the TKG library", func() {
+			BeforeEach(func() {
+				contentSource.Labels = map[string]string{
+					providerconfigmap.TKGContentSourceLabelKey: providerconfigmap.TKGContentSourceLabelValue,
+				}
+			})
+
+			It("will not garbage collect the images", func() {
+				reconcile()
+				Expect(getImage("image-v1").Annotations).ToNot(HaveKey(vmopapi.VirtualMachineImageUsageCountAnnotation))
+			})
+		})
+
+		When("the library is not writable", func() {
+			JustBeforeEach(func() {
+				fakeVMProvider.IsContentLibraryWritableFn = func(_ goctx.Context, _ string) (bool, error) {
+					return false, nil
+				}
+			})
+
+			It("will not garbage collect the images", func() {
+				reconcile()
+				Expect(getImage("image-v1").Annotations).ToNot(HaveKey(vmopapi.VirtualMachineImageUsageCountAnnotation))
+			})
+		})
+	})
+
+	Context("ReconcileNormal", func() {
+		It("will mark the unused images that are not the newest versions of their family", func() {
+			requeueAfter := reconcileNormal()
+			Expect(requeueAfter).To(BeNumerically("<=", time.Hour))
+			Expect(deleted).To(BeEmpty())
+
+			image := getImage("image-v3")
+			Expect(image.Annotations).To(HaveKeyWithValue(vmopapi.VirtualMachineImageUsageCountAnnotation, "0"))
+			Expect(image.Annotations).ToNot(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+
+			for _, name := range []string{"image-v1", "image-v2"} {
+				image := getImage(name)
+				Expect(image.Annotations).To(HaveKeyWithValue(vmopapi.VirtualMachineImageUsageCountAnnotation, "0"))
+				Expect(image.Annotations).To(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+			}
+
+			image = getImage("other-provider-image")
+			Expect(image.Annotations).ToNot(HaveKey(vmopapi.VirtualMachineImageUsageCountAnnotation))
+		})
+
+		When("the creation time of the library item of an image is unknown", func() {
+			JustBeforeEach(func() {
+				image := getImage("image-v1")
+				delete(image.Annotations, vmopapi.VirtualMachineImageItemCreationTimeAnnotation)
+				Expect(ctx.Client.Update(ctx, image)).To(Succeed())
+			})
+
+			It("will keep the image", func() {
+				reconcileNormal()
+				Expect(getImage("image-v1").Annotations).ToNot(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+				Expect(getImage("image-v2").Annotations).To(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+			})
+		})
+
+		When("the grace period of an unused image has passed", func() {
+			JustBeforeEach(func() {
+				setUnusedSince("image-v1", now.Add(-2*time.Hour))
+			})
+
+			It("will delete the library item and the image", func() {
+				reconcileNormal()
+				Expect(deleted).To(ConsistOf("image-v1-item-id"))
+				Expect(ctx.Events).To(Receive(ContainSubstring("DeletedImage")))
+
+				err := ctx.Client.Get(ctx, client.ObjectKey{Name: "image-v1"}, &vmopv1alpha1.VirtualMachineImage{})
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+				Expect(getImage("image-v2").Annotations).To(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+			})
+
+			When("dry run is enabled", func() {
+				JustBeforeEach(func() {
+					reconciler.DryRun = true
+				})
+
+				It("will only report the image", func() {
+					reconcileNormal()
+					Expect(deleted).To(BeEmpty())
+					Expect(ctx.Events).To(Receive(ContainSubstring("RetentionDryRun")))
+					Expect(getImage("image-v1").Annotations).To(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+				})
+			})
+
+			When("the image is used by a VirtualMachine", func() {
+				BeforeEach(func() {
+					vm := &vmopv1alpha1.VirtualMachine{
+						ObjectMeta: metav1.ObjectMeta{Name: "dummy-vm", Namespace: "dummy-ns"},
+						Spec:       vmopv1alpha1.VirtualMachineSpec{ImageName: "image-v1"},
+					}
+					initObjects = append(initObjects, vm)
+				})
+
+				It("will keep the image", func() {
+					reconcileNormal()
+					Expect(deleted).To(BeEmpty())
+
+					image := getImage("image-v1")
+					Expect(image.Annotations).To(HaveKeyWithValue(vmopapi.VirtualMachineImageUsageCountAnnotation, "1"))
+					Expect(image.Annotations).ToNot(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+				})
+			})
+
+			When("the image is used by the template of a VirtualMachineReplicaSet", func() {
+				BeforeEach(func() {
+					rs := &vmopapi.VirtualMachineReplicaSet{
+						ObjectMeta: metav1.ObjectMeta{Name: "dummy-rs", Namespace: "dummy-ns"},
+						Spec: vmopapi.VirtualMachineReplicaSetSpec{
+							Template: vmopapi.VirtualMachineTemplateSpec{
+								Spec: vmopv1alpha1.VirtualMachineSpec{ImageName: "image-v1"},
+							},
+						},
+					}
+					initObjects = append(initObjects, rs)
+				})
+
+				It("will keep the image", func() {
+					reconcileNormal()
+					Expect(deleted).To(BeEmpty())
+					Expect(getImage("image-v1").Annotations).ToNot(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+				})
+			})
+
+			When("the image is the node image of a TanzuKubernetesRelease", func() {
+				BeforeEach(func() {
+					tkr := &unstructured.Unstructured{}
+					tkr.SetAPIVersion("run.tanzu.vmware.com/v1alpha1")
+					tkr.SetKind("TanzuKubernetesRelease")
+					tkr.SetName("dummy-tkr")
+					Expect(unstructured.SetNestedField(tkr.Object, "image-v1", "spec", "nodeImageRef", "name")).To(Succeed())
+					initObjects = append(initObjects, tkr)
+				})
+
+				It("will keep the image", func() {
+					reconcileNormal()
+					Expect(deleted).To(BeEmpty())
+					Expect(getImage("image-v1").Annotations).ToNot(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+				})
+			})
+
+			When("the image has its own family", func() {
+				JustBeforeEach(func() {
+					image := getImage("image-v1")
+					image.Labels = map[string]string{vmopapi.VirtualMachineImageFamilyLabel: "dummy-family"}
+					Expect(ctx.Client.Update(ctx, image)).To(Succeed())
+				})
+
+				It("will keep the image as the newest version of its family", func() {
+					reconcileNormal()
+					Expect(deleted).To(BeEmpty())
+					Expect(getImage("image-v1").Annotations).ToNot(HaveKey(vmopapi.VirtualMachineImageUnusedSinceAnnotation))
+				})
+			})
+		})
+	})
+}
+
+func unitTestsGetImageFamily() {
+	var image *vmopv1alpha1.VirtualMachineImage
+
+	BeforeEach(func() {
+		image = newImage("dummy-image", "dummy-cl-uuid", time.Now())
+	})
+
+	It("returns the vendor and product of the image", func() {
+		Expect(imageretention.GetImageFamily(image)).To(Equal("vendor/product"))
+	})
+
+	It("returns the family label of the image", func() {
+		image.Labels = map[string]string{vmopapi.VirtualMachineImageFamilyLabel: "dummy-family"}
+		Expect(imageretention.GetImageFamily(image)).To(Equal("dummy-family"))
+	})
+
+	It("returns the name of an image without a product", func() {
+		image.Spec.ProductInfo = vmopv1alpha1.VirtualMachineImageProductInfo{}
+		Expect(imageretention.GetImageFamily(image)).To(Equal("dummy-image"))
+	})
+}
@@ -0,0 +1,370 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscribedcontentlibrary
+
+import (
+	goctx "context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/patch"
+	"github.com/acharyasreej/vm-operator/pkg/record"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+)
+
+const (
+	finalizerName = "subscribedcontentlibrary.vmoperator.vmware.com"
+
+	// SubscribedContentLibraryLabelKey is the label on the ContentSource of a SubscribedContentLibrary
+	// with the namespace and name of the SubscribedContentLibrary.
+	SubscribedContentLibraryLabelKey = "vmoperator.vmware.com/subscribed-content-library"
+
+	// syncPendingRequeueDelay is the delay after which a library that was never synced is reconciled
+	// again to pick up its sync status.
+	syncPendingRequeueDelay = 30 * time.Second
+
+	// syncStatusRequeueDelay is the delay after which a library that was synced is reconciled again to
+	// pick up the status of its later syncs.
+	syncStatusRequeueDelay = 10 * time.Minute
+)
+
+// AddToManager adds this package's controller to the provided manager.
+func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controlledType     = &vmopapi.SubscribedContentLibrary{}
+		controlledTypeName = reflect.TypeOf(controlledType).Elem().Name()
+
+		controllerNameShort = fmt.Sprintf("%s-controller", strings.ToLower(controlledTypeName))
+		controllerNameLong  = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
+	)
+
+	r := NewReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName(controlledTypeName),
+		record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		ctx.VMProvider,
+	)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(controlledType).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+func NewReconciler(
+	client client.Client,
+	logger logr.Logger,
+	recorder record.Recorder,
+	vmProvider vmprovider.VirtualMachineProviderInterface) *Reconciler {
+	return &Reconciler{
+		Client:     client,
+		Logger:     logger,
+		Recorder:   recorder,
+		VMProvider: vmProvider,
+	}
+}
+
+// Reconciler reconciles a SubscribedContentLibrary object.
+type Reconciler struct {
+	client.Client
+	Logger     logr.Logger
+	Recorder   record.Recorder
+	VMProvider vmprovider.VirtualMachineProviderInterface
+}
+
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=subscribedcontentlibraries,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=subscribedcontentlibraries/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentlibraryproviders,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentsources,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentsourcebindings,verbs=get;list;create;update;delete
+
+func (r *Reconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	scl := &vmopapi.SubscribedContentLibrary{}
+	if err := r.Get(ctx, req.NamespacedName, scl); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	sclCtx := &context.SubscribedContentLibraryContext{
+		Context: ctx,
+		Logger:  r.Logger.WithName("SubscribedContentLibrary").WithValues("name", scl.NamespacedName()),
+		Library: scl,
+	}
+
+	patchHelper, err := patch.NewHelper(scl, r.Client)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to init patch helper for %s", sclCtx.String())
+	}
+	defer func() {
+		if err := patchHelper.Patch(ctx, scl); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			sclCtx.Logger.Error(err, "patch failed")
+		}
+	}()
+
+	if !scl.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.ReconcileDelete(sclCtx)
+	}
+
+	if err := r.ReconcileNormal(sclCtx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: requeueDelay(sclCtx)}, nil
+}
+
+// requeueDelay returns the delay after which a library is reconciled again, so that its sync status is
+// updated once the first sync completes, and then after each periodic sync with its publisher.
+func requeueDelay(ctx *context.SubscribedContentLibraryContext) time.Duration {
+	if c := conditions.Get(ctx.Library, vmopapi.SubscribedContentLibrarySyncedCondition); c != nil &&
+		c.Reason == vmopapi.SubscribedContentLibrarySyncPendingReason {
+		return syncPendingRequeueDelay
+	}
+
+	return syncStatusRequeueDelay
+}
+
+// LibraryName returns the name of the subscribed content library of the SubscribedContentLibrary. The name
+// includes the UID of the SubscribedContentLibrary so that it does not collide with the library of another,
+// or of a recreated, SubscribedContentLibrary, and identifies the libraries that the controller may update
+// or delete.
+func LibraryName(scl *vmopapi.SubscribedContentLibrary) string {
+	return fmt.Sprintf("%s-%s-%s", scl.Namespace, scl.Name, scl.UID)
+}
+
+// contentSourceLabelValue returns the value of the SubscribedContentLibraryLabelKey label on the ContentSource
+// of the SubscribedContentLibrary.
+func contentSourceLabelValue(scl *vmopapi.SubscribedContentLibrary) string {
+	return fmt.Sprintf("%s.%s", scl.Namespace, scl.Name)
+}
+
+// ReconcileNormal creates or updates the subscribed content library on the infrastructure, makes its images
+// available to the namespace through a ContentSource, and updates the status with the sync status of
+// the library.
+func (r *Reconciler) ReconcileNormal(ctx *context.SubscribedContentLibraryContext) error {
+	scl := ctx.Library
+
+	if !controllerutil.ContainsFinalizer(scl, finalizerName) {
+		// Return here so the SubscribedContentLibrary can be patched immediately. This ensures that
+		// the library is deleted from the infrastructure when the SubscribedContentLibrary is deleted.
+		controllerutil.AddFinalizer(scl, finalizerName)
+		return nil
+	}
+
+	ctx.Logger.Info("Reconciling SubscribedContentLibrary")
+	defer func() {
+		ctx.Logger.Info("Finished Reconciling SubscribedContentLibrary")
+	}()
+
+	sub := vmprovider.ContentLibrarySubscription{
+		Name:          LibraryName(scl),
+		PublisherURL:  scl.Spec.PublisherURL,
+		SSLThumbprint: scl.Spec.SSLThumbprint,
+		OnDemand:      scl.Spec.SyncPolicy == vmopapi.ContentLibrarySyncOnDemand,
+		DatastoreID:   scl.Spec.StorageBacking.DatastoreID,
+	}
+
+	clUUID, err := r.VMProvider.CreateOrUpdateSubscribedContentLibrary(ctx, scl.Status.LibraryUUID, sub)
+	if err != nil {
+		conditions.MarkFalse(scl, vmopapi.SubscribedContentLibraryReadyCondition,
+			vmopapi.SubscribedContentLibraryCreateFailedReason, vmopv1alpha1.ConditionSeverityError, "%v", err)
+		return errors.Wrapf(err, "failed to create or update subscribed content library %s", sub.Name)
+	}
+
+	if clUUID != scl.Status.LibraryUUID {
+		if scl.Status.LibraryUUID != "" {
+			// The library was deleted out of band and has been created again.
+			if err := r.deleteContentSource(ctx, scl.Status.LibraryUUID); err != nil {
+				return err
+			}
+		}
+
+		ctx.Logger.Info("Created subscribed content library", "libraryUUID", clUUID)
+		scl.Status.LibraryUUID = clUUID
+		scl.Status.LastSyncTime = nil
+	}
+
+	if err := r.createOrUpdateContentSource(ctx, clUUID); err != nil {
+		conditions.MarkFalse(scl, vmopapi.SubscribedContentLibraryReadyCondition,
+			vmopapi.SubscribedContentLibraryContentSourceFailedReason, vmopv1alpha1.ConditionSeverityError, "%v", err)
+		return err
+	}
+
+	conditions.MarkTrue(scl, vmopapi.SubscribedContentLibraryReadyCondition)
+
+	lastSyncTime, err := r.VMProvider.GetContentLibraryLastSyncTime(ctx, clUUID)
+	if err != nil {
+		conditions.MarkFalse(scl, vmopapi.SubscribedContentLibrarySyncedCondition,
+			vmopapi.SubscribedContentLibrarySyncFailedReason, vmopv1alpha1.ConditionSeverityError, "%v", err)
+		return errors.Wrapf(err, "failed to get sync status of subscribed content library %s", clUUID)
+	}
+
+	if lastSyncTime == nil {
+		conditions.MarkFalse(scl, vmopapi.SubscribedContentLibrarySyncedCondition,
+			vmopapi.SubscribedContentLibrarySyncPendingReason, vmopv1alpha1.ConditionSeverityInfo,
+			"Waiting for the first sync with %s", scl.Spec.PublisherURL)
+		return nil
+	}
+
+	syncTime := metav1.NewTime(*lastSyncTime)
+	scl.Status.LastSyncTime = &syncTime
+	conditions.MarkTrue(scl, vmopapi.SubscribedContentLibrarySyncedCondition)
+
+	return nil
+}
+
+// createOrUpdateContentSource creates the ContentLibraryProvider and ContentSource of the library, and binds
+// the ContentSource to the namespace of the SubscribedContentLibrary. The ContentSource is cluster scoped
+// so it cannot be owned by the SubscribedContentLibrary, and is instead deleted by ReconcileDelete. An existing
+// ContentSource is only updated when it has the label of the SubscribedContentLibrary.
+func (r *Reconciler) createOrUpdateContentSource(ctx *context.SubscribedContentLibraryContext, clUUID string) error {
+	scl := ctx.Library
+
+	clProvider := &vmopv1alpha1.ContentLibraryProvider{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clUUID,
+		},
+	}
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, clProvider, func() error {
+		clProvider.Spec = vmopv1alpha1.ContentLibraryProviderSpec{
+			UUID: clUUID,
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to create or update ContentLibraryProvider %s", clUUID)
+	}
+
+	clProviderGVK, err := apiutil.GVKForObject(clProvider, r.Scheme())
+	if err != nil {
+		return err
+	}
+
+	cs := &vmopv1alpha1.ContentSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clUUID,
+		},
+	}
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, cs, func() error {
+		if cs.ResourceVersion != "" && cs.Labels[SubscribedContentLibraryLabelKey] != contentSourceLabelValue(scl) {
+			return errors.Errorf("ContentSource %s is not owned by SubscribedContentLibrary %s", cs.Name, scl.NamespacedName())
+		}
+		if cs.Labels == nil {
+			cs.Labels = map[string]string{}
+		}
+		cs.Labels[SubscribedContentLibraryLabelKey] = contentSourceLabelValue(scl)
+		cs.Spec = vmopv1alpha1.ContentSourceSpec{
+			ProviderRef: vmopv1alpha1.ContentProviderReference{
+				APIVersion: clProviderGVK.GroupVersion().String(),
+				Kind:       clProviderGVK.Kind,
+				Name:       clProvider.Name,
+			},
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to create or update ContentSource %s", clUUID)
+	}
+
+	csGVK, err := apiutil.GVKForObject(cs, r.Scheme())
+	if err != nil {
+		return err
+	}
+
+	csBinding := &vmopv1alpha1.ContentSourceBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      clUUID,
+			Namespace: scl.Namespace,
+		},
+	}
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, csBinding, func() error {
+		// Set OwnerRef to the ContentSource so the binding gets cleaned up when the ContentSource is deleted.
+		if err := controllerutil.SetOwnerReference(cs, csBinding, r.Scheme()); err != nil {
+			return err
+		}
+
+		csBinding.ContentSourceRef = vmopv1alpha1.ContentSourceReference{
+			APIVersion: csGVK.GroupVersion().String(),
+			Kind:       csGVK.Kind,
+			Name:       cs.Name,
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to create or update ContentSourceBinding %s/%s", scl.Namespace, clUUID)
+	}
+
+	return nil
+}
+
+// deleteContentSource deletes the ContentSource of the library. Its ContentLibraryProvider, ContentSourceBindings
+// and VirtualMachineImages are garbage collected through their owner references. The ContentSource is only
+// deleted when it has the label of the SubscribedContentLibrary.
+func (r *Reconciler) deleteContentSource(ctx *context.SubscribedContentLibraryContext, clUUID string) error {
+	scl := ctx.Library
+
+	cs := &vmopv1alpha1.ContentSource{}
+	if err := r.Get(ctx, client.ObjectKey{Name: clUUID}, cs); err != nil {
+		if apiErrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get ContentSource %s", clUUID)
+	}
+
+	if cs.Labels[SubscribedContentLibraryLabelKey] != contentSourceLabelValue(scl) {
+		return errors.Errorf("ContentSource %s is not owned by SubscribedContentLibrary %s", clUUID, scl.NamespacedName())
+	}
+
+	if err := r.Delete(ctx, cs); client.IgnoreNotFound(err) != nil {
+		return errors.Wrapf(err, "failed to delete ContentSource %s", clUUID)
+	}
+
+	return nil
+}
+
+// ReconcileDelete deletes the ContentSource of the library, and the library from the infrastructure.
+func (r *Reconciler) ReconcileDelete(ctx *context.SubscribedContentLibraryContext) error {
+	scl := ctx.Library
+
+	if !controllerutil.ContainsFinalizer(scl, finalizerName) {
+		return nil
+	}
+
+	ctx.Logger.Info("Reconciling SubscribedContentLibrary Deletion")
+	defer func() {
+		ctx.Logger.Info("Finished Reconciling SubscribedContentLibrary Deletion")
+	}()
+
+	if clUUID := scl.Status.LibraryUUID; clUUID != "" {
+		if err := r.deleteContentSource(ctx, clUUID); err != nil {
+			return err
+		}
+
+		if err := r.VMProvider.DeleteContentLibrary(ctx, clUUID, LibraryName(scl)); err != nil {
+			return errors.Wrapf(err, "failed to delete subscribed content library %s", clUUID)
+		}
+	}
+
+	controllerutil.RemoveFinalizer(scl, finalizerName)
+	return nil
+}
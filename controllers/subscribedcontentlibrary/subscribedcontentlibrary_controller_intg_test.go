@@ -0,0 +1,107 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscribedcontentlibrary_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func intgTests() {
+	var (
+		ctx *builder.IntegrationTestContext
+
+		scl *vmopapi.SubscribedContentLibrary
+	)
+
+	BeforeEach(func() {
+		ctx = suite.NewIntegrationTestContext()
+
+		scl = &vmopapi.SubscribedContentLibrary{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-library",
+				Namespace: ctx.Namespace,
+			},
+			Spec: vmopapi.SubscribedContentLibrarySpec{
+				PublisherURL: "https://publisher.example.com/lib.json",
+				StorageBacking: vmopapi.ContentLibraryStorageBacking{
+					DatastoreID: "datastore-42",
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		ctx.AfterEach()
+		ctx = nil
+		intgFakeVMProvider.Reset()
+	})
+
+	getLibrary := func(ctx *builder.IntegrationTestContext, objKey client.ObjectKey) *vmopapi.SubscribedContentLibrary {
+		scl := &vmopapi.SubscribedContentLibrary{}
+		if err := ctx.Client.Get(ctx, objKey, scl); err != nil {
+			return nil
+		}
+		return scl
+	}
+
+	Context("Reconcile", func() {
+		It("Reconciles after SubscribedContentLibrary creation", func() {
+			Expect(ctx.Client.Create(ctx, scl)).To(Succeed())
+			sclKey := client.ObjectKeyFromObject(scl)
+
+			By("SubscribedContentLibrary should have finalizer added", func() {
+				Eventually(func() []string {
+					if scl := getLibrary(ctx, sclKey); scl != nil {
+						return scl.GetFinalizers()
+					}
+					return nil
+				}).Should(ContainElement(finalizer))
+			})
+
+			var clUUID string
+			By("SubscribedContentLibrary should be ready and synced", func() {
+				Eventually(func() bool {
+					if scl := getLibrary(ctx, sclKey); scl != nil {
+						clUUID = scl.Status.LibraryUUID
+						return conditions.IsTrue(scl, vmopapi.SubscribedContentLibraryReadyCondition) &&
+							conditions.IsTrue(scl, vmopapi.SubscribedContentLibrarySyncedCondition)
+					}
+					return false
+				}).Should(BeTrue())
+				Expect(clUUID).ToNot(BeEmpty())
+			})
+
+			By("ContentSource should be bound to the namespace", func() {
+				cs := &vmopv1alpha1.ContentSource{}
+				Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: clUUID}, cs)).To(Succeed())
+
+				binding := &vmopv1alpha1.ContentSourceBinding{}
+				Expect(ctx.Client.Get(ctx, client.ObjectKey{Namespace: ctx.Namespace, Name: clUUID}, binding)).To(Succeed())
+				Expect(binding.ContentSourceRef.Name).To(Equal(cs.Name))
+			})
+
+			By("Deleting the SubscribedContentLibrary", func() {
+				Expect(ctx.Client.Delete(ctx, scl)).To(Succeed())
+				Eventually(func() *vmopapi.SubscribedContentLibrary {
+					return getLibrary(ctx, sclKey)
+				}).Should(BeNil())
+			})
+
+			By("Library should be deleted", func() {
+				_, err := intgFakeVMProvider.GetContentLibraryLastSyncTime(ctx, clUUID)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+}
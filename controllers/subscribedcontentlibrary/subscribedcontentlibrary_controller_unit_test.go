@@ -0,0 +1,313 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscribedcontentlibrary_test
+
+import (
+	goctx "context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/subscribedcontentlibrary"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+	providerfake "github.com/acharyasreej/vm-operator/pkg/vmprovider/fake"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func unitTests() {
+	Describe("Invoking Reconcile", unitTestsReconcile)
+}
+
+const (
+	finalizer = "subscribedcontentlibrary.vmoperator.vmware.com"
+)
+
+func unitTestsReconcile() {
+	var (
+		initObjects    []client.Object
+		ctx            *builder.UnitTestContextForController
+		reconciler     *subscribedcontentlibrary.Reconciler
+		fakeVMProvider *providerfake.VMProvider
+
+		sclCtx *context.SubscribedContentLibraryContext
+		scl    *vmopapi.SubscribedContentLibrary
+	)
+
+	BeforeEach(func() {
+		scl = &vmopapi.SubscribedContentLibrary{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "dummy-library",
+				Namespace:  "dummy-ns",
+				UID:        "dummy-uid",
+				Finalizers: []string{finalizer},
+			},
+			Spec: vmopapi.SubscribedContentLibrarySpec{
+				PublisherURL: "https://publisher.example.com/lib.json",
+				SyncPolicy:   vmopapi.ContentLibrarySyncOnDemand,
+				StorageBacking: vmopapi.ContentLibraryStorageBacking{
+					DatastoreID: "datastore-42",
+				},
+			},
+		}
+		initObjects = append(initObjects, scl)
+	})
+
+	JustBeforeEach(func() {
+		ctx = suite.NewUnitTestContextForController(initObjects...)
+		reconciler = subscribedcontentlibrary.NewReconciler(
+			ctx.Client,
+			ctx.Logger,
+			ctx.Recorder,
+			ctx.VMProvider,
+		)
+		fakeVMProvider = ctx.VMProvider.(*providerfake.VMProvider)
+
+		sclCtx = &context.SubscribedContentLibraryContext{
+			Context: ctx,
+			Logger:  ctx.Logger.WithName(scl.Name),
+			Library: scl,
+		}
+	})
+
+	AfterEach(func() {
+		ctx.AfterEach()
+		ctx = nil
+		initObjects = nil
+		sclCtx = nil
+		reconciler = nil
+		fakeVMProvider = nil
+	})
+
+	Context("ReconcileNormal", func() {
+		It("will have finalizer set after reconciliation", func() {
+			scl.Finalizers = nil
+			Expect(reconciler.ReconcileNormal(sclCtx)).To(Succeed())
+			Expect(scl.GetFinalizers()).To(ContainElement(finalizer))
+			Expect(scl.Status.LibraryUUID).To(BeEmpty())
+		})
+
+		It("will create the library and its ContentSource", func() {
+			var sub vmprovider.ContentLibrarySubscription
+			fakeVMProvider.CreateOrUpdateSubscribedContentLibraryFn = func(_ goctx.Context, _ string, s vmprovider.ContentLibrarySubscription) (string, error) {
+				sub = s
+				return "dummy-uuid", nil
+			}
+			fakeVMProvider.GetContentLibraryLastSyncTimeFn = func(_ goctx.Context, _ string) (*time.Time, error) {
+				now := time.Now()
+				return &now, nil
+			}
+
+			Expect(reconciler.ReconcileNormal(sclCtx)).To(Succeed())
+			Expect(sub.Name).To(Equal("dummy-ns-dummy-library-dummy-uid"))
+			Expect(sub.PublisherURL).To(Equal(scl.Spec.PublisherURL))
+			Expect(sub.OnDemand).To(BeTrue())
+			Expect(sub.DatastoreID).To(Equal("datastore-42"))
+
+			Expect(scl.Status.LibraryUUID).To(Equal("dummy-uuid"))
+			Expect(scl.Status.LastSyncTime).ToNot(BeNil())
+			Expect(conditions.IsTrue(scl, vmopapi.SubscribedContentLibraryReadyCondition)).To(BeTrue())
+			Expect(conditions.IsTrue(scl, vmopapi.SubscribedContentLibrarySyncedCondition)).To(BeTrue())
+
+			clProvider := &vmopv1alpha1.ContentLibraryProvider{}
+			Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: "dummy-uuid"}, clProvider)).To(Succeed())
+			Expect(clProvider.Spec.UUID).To(Equal("dummy-uuid"))
+
+			cs := &vmopv1alpha1.ContentSource{}
+			Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: "dummy-uuid"}, cs)).To(Succeed())
+			Expect(cs.Spec.ProviderRef.Name).To(Equal(clProvider.Name))
+			Expect(cs.Labels).To(HaveKeyWithValue(subscribedcontentlibrary.SubscribedContentLibraryLabelKey, "dummy-ns.dummy-library"))
+
+			binding := &vmopv1alpha1.ContentSourceBinding{}
+			Expect(ctx.Client.Get(ctx, client.ObjectKey{Namespace: scl.Namespace, Name: "dummy-uuid"}, binding)).To(Succeed())
+			Expect(binding.ContentSourceRef.Name).To(Equal(cs.Name))
+			Expect(binding.OwnerReferences).To(HaveLen(1))
+			Expect(binding.OwnerReferences[0].Name).To(Equal(cs.Name))
+		})
+
+		It("will update the existing library", func() {
+			scl.Status.LibraryUUID = "dummy-uuid"
+			var clUUID string
+			fakeVMProvider.CreateOrUpdateSubscribedContentLibraryFn = func(_ goctx.Context, uuid string, _ vmprovider.ContentLibrarySubscription) (string, error) {
+				clUUID = uuid
+				return uuid, nil
+			}
+			fakeVMProvider.GetContentLibraryLastSyncTimeFn = func(_ goctx.Context, _ string) (*time.Time, error) {
+				return nil, nil
+			}
+
+			Expect(reconciler.ReconcileNormal(sclCtx)).To(Succeed())
+			Expect(clUUID).To(Equal("dummy-uuid"))
+			Expect(scl.Status.LibraryUUID).To(Equal("dummy-uuid"))
+		})
+
+		It("will not update the library of another SubscribedContentLibrary", func() {
+			clUUID, err := fakeVMProvider.CreateOrUpdateSubscribedContentLibrary(ctx, "", vmprovider.ContentLibrarySubscription{Name: "other-library"})
+			Expect(err).ToNot(HaveOccurred())
+			scl.Status.LibraryUUID = clUUID
+
+			Expect(reconciler.ReconcileNormal(sclCtx)).ToNot(Succeed())
+			Expect(conditions.GetReason(scl, vmopapi.SubscribedContentLibraryReadyCondition)).To(
+				Equal(vmopapi.SubscribedContentLibraryCreateFailedReason))
+			Expect(scl.Status.LibraryUUID).To(Equal(clUUID))
+		})
+
+		When("a ContentSource of another library has the UUID of the library", func() {
+			BeforeEach(func() {
+				initObjects = append(initObjects, &vmopv1alpha1.ContentSource{
+					ObjectMeta: metav1.ObjectMeta{Name: "dummy-uuid"},
+				})
+			})
+
+			It("will not update the ContentSource", func() {
+				fakeVMProvider.CreateOrUpdateSubscribedContentLibraryFn = func(_ goctx.Context, _ string, _ vmprovider.ContentLibrarySubscription) (string, error) {
+					return "dummy-uuid", nil
+				}
+
+				err := reconciler.ReconcileNormal(sclCtx)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("is not owned by SubscribedContentLibrary"))
+				Expect(conditions.GetReason(scl, vmopapi.SubscribedContentLibraryReadyCondition)).To(
+					Equal(vmopapi.SubscribedContentLibraryContentSourceFailedReason))
+
+				cs := &vmopv1alpha1.ContentSource{}
+				Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: "dummy-uuid"}, cs)).To(Succeed())
+				Expect(cs.Labels).ToNot(HaveKey(subscribedcontentlibrary.SubscribedContentLibraryLabelKey))
+			})
+		})
+
+		It("will requeue to update the sync status after the library was synced", func() {
+			result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(scl)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).ToNot(BeZero())
+
+			updatedSCL := &vmopapi.SubscribedContentLibrary{}
+			Expect(ctx.Client.Get(ctx, client.ObjectKeyFromObject(scl), updatedSCL)).To(Succeed())
+			Expect(conditions.IsTrue(updatedSCL, vmopapi.SubscribedContentLibrarySyncedCondition)).To(BeTrue())
+		})
+
+		It("will mark the library as pending sync when it was never synced", func() {
+			Expect(reconciler.ReconcileNormal(sclCtx)).To(Succeed())
+			fakeVMProvider.GetContentLibraryLastSyncTimeFn = func(_ goctx.Context, _ string) (*time.Time, error) {
+				return nil, nil
+			}
+
+			Expect(reconciler.ReconcileNormal(sclCtx)).To(Succeed())
+			c := conditions.Get(scl, vmopapi.SubscribedContentLibrarySyncedCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Status).To(Equal(corev1.ConditionFalse))
+			Expect(c.Reason).To(Equal(vmopapi.SubscribedContentLibrarySyncPendingReason))
+			Expect(c.Severity).To(Equal(vmopv1alpha1.ConditionSeverityInfo))
+		})
+
+		It("will mark the condition false when the library creation fails", func() {
+			fakeVMProvider.CreateOrUpdateSubscribedContentLibraryFn = func(_ goctx.Context, _ string, _ vmprovider.ContentLibrarySubscription) (string, error) {
+				return "", fmt.Errorf("create error")
+			}
+
+			err := reconciler.ReconcileNormal(sclCtx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("create error"))
+			c := conditions.Get(scl, vmopapi.SubscribedContentLibraryReadyCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Status).To(Equal(corev1.ConditionFalse))
+			Expect(c.Reason).To(Equal(vmopapi.SubscribedContentLibraryCreateFailedReason))
+			Expect(c.Message).To(ContainSubstring("create error"))
+		})
+
+		It("will mark the condition false when the sync status cannot be retrieved", func() {
+			fakeVMProvider.GetContentLibraryLastSyncTimeFn = func(_ goctx.Context, _ string) (*time.Time, error) {
+				return nil, fmt.Errorf("sync error")
+			}
+
+			Expect(reconciler.ReconcileNormal(sclCtx)).ToNot(Succeed())
+			Expect(conditions.IsTrue(scl, vmopapi.SubscribedContentLibraryReadyCondition)).To(BeTrue())
+			c := conditions.Get(scl, vmopapi.SubscribedContentLibrarySyncedCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Reason).To(Equal(vmopapi.SubscribedContentLibrarySyncFailedReason))
+			Expect(c.Message).To(ContainSubstring("sync error"))
+		})
+	})
+
+	Context("ReconcileDelete", func() {
+		It("will delete the library and its ContentSource, and remove the finalizer", func() {
+			Expect(reconciler.ReconcileNormal(sclCtx)).To(Succeed())
+			clUUID := scl.Status.LibraryUUID
+			Expect(clUUID).ToNot(BeEmpty())
+
+			Expect(reconciler.ReconcileDelete(sclCtx)).To(Succeed())
+			Expect(scl.GetFinalizers()).To(BeEmpty())
+
+			cs := &vmopv1alpha1.ContentSource{}
+			err := ctx.Client.Get(ctx, client.ObjectKey{Name: clUUID}, cs)
+			Expect(client.IgnoreNotFound(err)).To(Succeed())
+			Expect(err).To(HaveOccurred())
+
+			_, err = fakeVMProvider.GetContentLibraryLastSyncTime(ctx, clUUID)
+			Expect(err).To(HaveOccurred())
+		})
+
+		When("the ContentSource with the UUID of the library is not owned by the SubscribedContentLibrary", func() {
+			BeforeEach(func() {
+				initObjects = append(initObjects, &vmopv1alpha1.ContentSource{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "other-uuid",
+						Labels: map[string]string{
+							subscribedcontentlibrary.SubscribedContentLibraryLabelKey: "other-ns.other-library",
+						},
+					},
+				})
+			})
+
+			It("will not delete the ContentSource or the library", func() {
+				scl.Status.LibraryUUID = "other-uuid"
+				deleted := false
+				fakeVMProvider.DeleteContentLibraryFn = func(_ goctx.Context, _, _ string) error {
+					deleted = true
+					return nil
+				}
+
+				Expect(reconciler.ReconcileDelete(sclCtx)).ToNot(Succeed())
+				Expect(scl.GetFinalizers()).To(ContainElement(finalizer))
+				Expect(deleted).To(BeFalse())
+
+				cs := &vmopv1alpha1.ContentSource{}
+				Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: "other-uuid"}, cs)).To(Succeed())
+			})
+		})
+
+		It("will not delete the library of another SubscribedContentLibrary", func() {
+			clUUID, err := fakeVMProvider.CreateOrUpdateSubscribedContentLibrary(ctx, "", vmprovider.ContentLibrarySubscription{Name: "other-library"})
+			Expect(err).ToNot(HaveOccurred())
+			scl.Status.LibraryUUID = clUUID
+
+			Expect(reconciler.ReconcileDelete(sclCtx)).ToNot(Succeed())
+			Expect(scl.GetFinalizers()).To(ContainElement(finalizer))
+
+			_, err = fakeVMProvider.GetContentLibraryLastSyncTime(ctx, clUUID)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("will keep the finalizer when the library deletion fails", func() {
+			scl.Status.LibraryUUID = "dummy-uuid"
+			fakeVMProvider.DeleteContentLibraryFn = func(_ goctx.Context, _, _ string) error {
+				return fmt.Errorf("delete error")
+			}
+
+			Expect(reconciler.ReconcileDelete(sclCtx)).ToNot(Succeed())
+			Expect(scl.GetFinalizers()).To(ContainElement(finalizer))
+		})
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,6 +32,7 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	"github.com/acharyasreej/vm-operator/pkg/context"
 	"github.com/acharyasreej/vm-operator/pkg/lib"
@@ -69,14 +71,21 @@ func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) er
 		proberManager,
 	)
 
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(controlledType).
 		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
 		Watches(&source.Kind{Type: &vmopv1alpha1.VirtualMachineClassBinding{}},
 			handler.EnqueueRequestsFromMapFunc(classBindingToVMMapperFn(ctx, r.Client))).
 		Watches(&source.Kind{Type: &vmopv1alpha1.ContentSourceBinding{}},
-			handler.EnqueueRequestsFromMapFunc(csBindingToVMMapperFn(ctx, r.Client))).
-		Complete(r)
+			handler.EnqueueRequestsFromMapFunc(csBindingToVMMapperFn(ctx, r.Client)))
+
+	// Reconcile the VirtualMachines whose VM changed on the provider, instead of polling them.
+	if vmEvents := ctx.VMProvider.VirtualMachineEvents(); vmEvents != nil {
+		builder = builder.Watches(&source.Channel{Source: vmEvents}, &handler.EnqueueRequestForObject{})
+		r.WatchesProviderVMs = true
+	}
+
+	return builder.Complete(r)
 }
 
 // csBindingToVMMapperFn returns a mapper function that can be used to queue reconcile request
@@ -199,6 +208,10 @@ type Reconciler struct {
 	VMProvider vmprovider.VirtualMachineProviderInterface
 	Prober     prober.Manager
 
+	// WatchesProviderVMs is true when the VirtualMachines are reconciled whenever their VM changes on the
+	// provider, so they do not have to be requeued to wait for the changes.
+	WatchesProviderVMs bool
+
 	// Hack to limit concurrent create operations because they block and can take a long time.
 	mutex                            sync.Mutex
 	NumVMsBeingCreatedOnProvider     int
@@ -244,7 +257,8 @@ func (r *Reconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Resu
 	}
 
 	defer func() {
-		if err := patchHelper.Patch(ctx, vm); err != nil {
+		// The status of the volumes is also updated by the volume controller.
+		if err := patchHelper.Patch(ctx, vm, patch.WithOptimisticLockOnStatusFields{Fields: []string{"volumes"}}); err != nil {
 			if reterr == nil {
 				reterr = err
 			}
@@ -262,7 +276,7 @@ func (r *Reconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Resu
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{RequeueAfter: requeueDelay(vmCtx)}, nil
+	return ctrl.Result{RequeueAfter: requeueDelay(vmCtx, r.WatchesProviderVMs)}, nil
 }
 
 // Determine if we should request a non-zero requeue delay in order to trigger a non-rate limited reconcile
@@ -272,14 +286,16 @@ func (r *Reconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Resu
 // TODO: It would be much preferable to determine that a non-error resync is required at the source of the determination that
 // TODO: the VM IP isn't available rather than up here in the reconcile loop.  However, in the interest of time, we are making
 // TODO: this determination here and will have to refactor at some later date.
-func requeueDelay(ctx *context.VirtualMachineContext) time.Duration {
+//
+// When the provider VMs are watched, the VM is reconciled once its IP address is reported, so it is not requeued.
+func requeueDelay(ctx *context.VirtualMachineContext, watchesProviderVMs bool) time.Duration {
 	// If the VM is in Creating phase, the reconciler has run out of threads to Create VMs on the provider. Do not queue
 	// immediately to avoid exponential backoff.
 	if ctx.VM.Status.Phase == vmopv1alpha1.Creating {
 		return 10 * time.Second
 	}
 
-	if ctx.VM.Status.VmIp == "" && ctx.VM.Status.PowerState == vmopv1alpha1.VirtualMachinePoweredOn {
+	if !watchesProviderVMs && ctx.VM.Status.VmIp == "" && ctx.VM.Status.PowerState == vmopv1alpha1.VirtualMachinePoweredOn {
 		return 10 * time.Second
 	}
 
@@ -425,6 +441,10 @@ func (r *Reconciler) getContentSourceFromCLProvider(ctx *context.VirtualMachineC
 // if a ContentSourceBinding existing in the namespace that points to the ContentSource corresponding to the specified image.
 func (r *Reconciler) getImageAndContentLibraryUUID(ctx *context.VirtualMachineContext) (*vmopv1alpha1.VirtualMachineImage, string, error) {
 	imageName := ctx.VM.Spec.ImageName
+	if imageName == "" && vmopapi.IsImportedVM(ctx.VM) {
+		// An imported VM was not deployed from an image.
+		return nil, "", nil
+	}
 
 	vmImage := &vmopv1alpha1.VirtualMachineImage{}
 	if err := r.Get(ctx, client.ObjectKey{Name: imageName}, vmImage); err != nil {
@@ -490,6 +510,86 @@ func (r *Reconciler) getImageAndContentLibraryUUID(ctx *context.VirtualMachineCo
 	return vmImage, clUUID, nil
 }
 
+// importVM imports the existing provider VM that the VM's import annotations point to. When the VM does
+// not specify a class, the VM's class is set to a class that matches the hardware of the imported VM.
+func (r *Reconciler) importVM(ctx *context.VirtualMachineContext) error {
+	vm := ctx.VM
+	if vm.Status.UniqueID != "" && vm.Spec.ClassName != "" {
+		// Already imported.
+		return nil
+	}
+
+	hardware, err := r.VMProvider.ImportVirtualMachine(ctx, vm)
+	if err != nil {
+		return err
+	}
+
+	if vm.Spec.ClassName == "" {
+		className, err := r.getVMClassNameForHardware(ctx, hardware)
+		if err != nil {
+			return err
+		}
+
+		ctx.Logger.Info("Setting VirtualMachineClass of imported VM", "className", className)
+		vm.Spec.ClassName = className
+	}
+
+	return nil
+}
+
+// getVMClassNameForHardware returns the name of a VM class with the same CPU and memory as the hardware. When
+// several classes match, the first by name is returned so the result does not change between reconciles. When
+// the VMServiceFSSEnabled is enabled, only the classes bound to the VM's namespace are considered.
+func (r *Reconciler) getVMClassNameForHardware(ctx *context.VirtualMachineContext, hardware vmprovider.VMHardware) (string, error) {
+	classList := &vmopv1alpha1.VirtualMachineClassList{}
+	if err := r.List(ctx, classList); err != nil {
+		return "", errors.Wrap(err, "failed to list VirtualMachineClasses")
+	}
+
+	var boundClassNames map[string]struct{}
+	if lib.IsVMServiceFSSEnabled() {
+		classBindingList := &vmopv1alpha1.VirtualMachineClassBindingList{}
+		if err := r.List(ctx, classBindingList, client.InNamespace(ctx.VM.Namespace)); err != nil {
+			return "", errors.Wrapf(err, "failed to list VirtualMachineClassBindings in namespace: %s", ctx.VM.Namespace)
+		}
+
+		boundClassNames = map[string]struct{}{}
+		for _, classBinding := range classBindingList.Items {
+			if classBinding.ClassRef.Kind == "VirtualMachineClass" {
+				boundClassNames[classBinding.ClassRef.Name] = struct{}{}
+			}
+		}
+	}
+
+	var classNames []string
+	for _, vmClass := range classList.Items {
+		if boundClassNames != nil {
+			if _, ok := boundClassNames[vmClass.Name]; !ok {
+				continue
+			}
+		}
+
+		hw := vmClass.Spec.Hardware
+		if hw.Cpus == hardware.NumCPUs && hw.Memory.Cmp(hardware.Memory) == 0 {
+			classNames = append(classNames, vmClass.Name)
+		}
+	}
+
+	if len(classNames) == 0 {
+		msg := fmt.Sprintf("No VirtualMachineClass matches the imported VM with %d CPUs and %s memory",
+			hardware.NumCPUs, hardware.Memory.String())
+		conditions.MarkFalse(ctx.VM,
+			vmopv1alpha1.VirtualMachinePrereqReadyCondition,
+			vmopv1alpha1.VirtualMachineClassNotFoundReason,
+			vmopv1alpha1.ConditionSeverityError,
+			msg)
+		return "", errors.New(msg)
+	}
+
+	sort.Strings(classNames)
+	return classNames[0], nil
+}
+
 // getVMClass checks if a VM class specified by a VM spec is valid. When the VMServiceFSSEnabled is enabled,
 // a valid VM Class binding for the class in the VM's namespace must exist.
 func (r *Reconciler) getVMClass(ctx *context.VirtualMachineContext) (*vmopv1alpha1.VirtualMachineClass, error) {
@@ -581,10 +681,31 @@ func (r *Reconciler) getVMMetadata(ctx *context.VirtualMachineContext) (vmprovid
 		}
 	}
 
-	outMetadata.Transport = inMetadata.Transport
+	outMetadata.Transport = vmopapi.GetVirtualMachineMetadataTransport(ctx.VM)
 	return outMetadata, nil
 }
 
+// getSysprepSecretData returns the data of the Secret named by the VM's SysprepSecretAnnotation, or nil
+// if the VM does not have the annotation.
+func (r *Reconciler) getSysprepSecretData(ctx *context.VirtualMachineContext) (map[string]string, error) {
+	secretName := ctx.VM.Annotations[vmopapi.SysprepSecretAnnotation]
+	if secretName == "" {
+		return nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Name: secretName, Namespace: ctx.VM.Namespace}, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to get Sysprep Secret %s", secretName)
+	}
+
+	data := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		data[k] = string(v)
+	}
+
+	return data, nil
+}
+
 func (r *Reconciler) getResourcePolicy(ctx *context.VirtualMachineContext) (*vmopv1alpha1.VirtualMachineSetResourcePolicy, error) {
 	rpName := ctx.VM.Spec.ResourcePolicyName
 	if rpName == "" {
@@ -612,28 +733,44 @@ func (r *Reconciler) getResourcePolicy(ctx *context.VirtualMachineContext) (*vmo
 	return resourcePolicy, nil
 }
 
-func (r *Reconciler) findInstanceStorageVMPlacementStatus(vmCtx *context.VirtualMachineContext) (ready bool) {
+func (r *Reconciler) findInstanceStorageVMPlacementStatus(vmCtx *context.VirtualMachineContext) (ready bool, err error) {
 	if !instancestorage.IsConfigured(vmCtx.VM) {
-		return true
+		return true, nil
 	}
 
-	// TODO:
-	// 1. Set the selected-node (if not set already) annotation for the volume controller to place PVCs on that node.
+	_, selected := vmCtx.VM.Annotations[constants.InstanceStorageSelectedNodeAnnotationKey]
+	_, bound := vmCtx.VM.Annotations[constants.InstanceStoragePVCsBoundAnnotationKey]
+
+	// Select the host for the volume controller to place the PVCs on. A host is selected again when the
+	// volumes are replaced or fail to be placed, since the selected-node annotation is then removed.
+	if !selected && !bound {
+		if err := r.VMProvider.PlaceVirtualMachineInstanceStorage(vmCtx, vmCtx.VM); err != nil {
+			return false, err
+		}
+	}
 
 	// Check if all PVCs are realized, if not, inform reconcile handler to wait till the state is ready.
-	if _, exists := vmCtx.VM.Annotations[constants.InstanceStoragePVCsBoundAnnotationKey]; !exists {
+	if !bound {
 		vmCtx.Logger.V(5).WithValues(
 			"reason", "Instance storage PVCs are not realized yet",
 		).Info("Returning with not ready")
-		return false
+		return false, nil
 	}
 
 	// Placement successful
-	return true
+	return true, nil
 }
 
 // createOrUpdateVM calls into the VM provider to reconcile a VirtualMachine.
 func (r *Reconciler) createOrUpdateVM(ctx *context.VirtualMachineContext) error {
+	if vmopapi.IsImportedVM(ctx.VM) {
+		if err := r.importVM(ctx); err != nil {
+			ctx.Logger.Error(err, "Provider failed to import VirtualMachine")
+			r.Recorder.EmitEvent(ctx.VM, "Import", err, false)
+			return err
+		}
+	}
+
 	vmClass, err := r.getVMClass(ctx)
 	if err != nil {
 		return err
@@ -654,6 +791,11 @@ func (r *Reconciler) createOrUpdateVM(ctx *context.VirtualMachineContext) error
 		return err
 	}
 
+	sysprepSecretData, err := r.getSysprepSecretData(ctx)
+	if err != nil {
+		return err
+	}
+
 	resourcePolicy, err := r.getResourcePolicy(ctx)
 	if err != nil {
 		return err
@@ -675,6 +817,7 @@ func (r *Reconciler) createOrUpdateVM(ctx *context.VirtualMachineContext) error
 		ResourcePolicy:     resourcePolicy,
 		StorageProfileID:   storagePolicyID,
 		ContentLibraryUUID: clUUID,
+		SysprepSecretData:  sysprepSecretData,
 	}
 
 	exists, err := r.VMProvider.DoesVirtualMachineExist(ctx, vm)
@@ -683,6 +826,26 @@ func (r *Reconciler) createOrUpdateVM(ctx *context.VirtualMachineContext) error
 		return err
 	}
 
+	if !exists && vmopapi.IsImportedVM(vm) {
+		// Never create a new VM in place of an imported VM that went missing.
+		err = fmt.Errorf("imported VirtualMachine does not exist")
+		ctx.Logger.Error(err, "Failed to find imported VirtualMachine")
+		r.Recorder.EmitEvent(vm, "Import", err, false)
+		return err
+	}
+
+	if _, ok := vm.Annotations[vmopapi.RelocateToZoneAnnotation]; ok {
+		relocated, err := r.relocateVM(ctx, exists)
+		if err != nil {
+			ctx.Logger.Error(err, "Provider failed to relocate VirtualMachine")
+			r.Recorder.EmitEvent(vm, "Relocate", err, false)
+			return err
+		}
+		if !relocated {
+			return nil
+		}
+	}
+
 	if !exists {
 		// Set the phase to Creating first so we do not queue the reconcile immediately if we do not have threads available.
 		vm.Status.Phase = vmopv1alpha1.Creating
@@ -725,7 +888,13 @@ func (r *Reconciler) createOrUpdateVM(ctx *context.VirtualMachineContext) error
 	}
 
 	if lib.IsInstanceStorageFSSEnabled() {
-		if !r.findInstanceStorageVMPlacementStatus(ctx) {
+		ready, err := r.findInstanceStorageVMPlacementStatus(ctx)
+		if err != nil {
+			ctx.Logger.Error(err, "Provider failed to place instance storage volumes")
+			r.Recorder.EmitEvent(vm, "PlaceInstanceStorage", err, false)
+			return err
+		}
+		if !ready {
 			return nil
 		}
 	}
@@ -739,9 +908,117 @@ func (r *Reconciler) createOrUpdateVM(ctx *context.VirtualMachineContext) error
 		return err
 	}
 
+	if lib.IsVMServiceBackupRestoreFSSEnabled() {
+		if err := r.backupVM(ctx); err != nil {
+			ctx.Logger.Error(err, "Provider failed to backup VirtualMachine")
+			r.Recorder.EmitEvent(vm, "Backup", err, false)
+			return err
+		}
+	}
+
 	return nil
 }
 
+// relocateVM relocates the VM to the availability zone in the VM's RelocateToZoneAnnotation, and returns
+// true once the VM is in that zone. The relocation is started on the reconcile after the one that marks the
+// VM as relocating, so that the condition is visible while the provider relocates the VM. Once relocated,
+// the VM's zone label is set to the zone and the annotation is removed.
+func (r *Reconciler) relocateVM(ctx *context.VirtualMachineContext, exists bool) (bool, error) {
+	vm := ctx.VM
+	zone := vm.Annotations[vmopapi.RelocateToZoneAnnotation]
+
+	if zone == vm.Labels[topology.KubernetesTopologyZoneLabelKey] {
+		delete(vm.Annotations, vmopapi.RelocateToZoneAnnotation)
+		return true, nil
+	}
+
+	if exists {
+		if conditions.GetReason(vm, vmopapi.VirtualMachineZoneRelocatedCondition) != vmopapi.VirtualMachineZoneRelocatingReason {
+			// Return immediately to let the patch helper update the condition, and then relocate the
+			// VM on the next reconcile.
+			conditions.MarkFalse(vm, vmopapi.VirtualMachineZoneRelocatedCondition, vmopapi.VirtualMachineZoneRelocatingReason,
+				vmopv1alpha1.ConditionSeverityInfo, "Relocating to availability zone %s", zone)
+			return false, nil
+		}
+
+		ctx.Logger.Info("Relocating VirtualMachine to availability zone", "zone", zone)
+		if err := r.VMProvider.RelocateVirtualMachine(ctx, vm, zone); err != nil {
+			conditions.MarkFalse(vm, vmopapi.VirtualMachineZoneRelocatedCondition, vmopapi.VirtualMachineZoneRelocateFailedReason,
+				vmopv1alpha1.ConditionSeverityError, "Failed to relocate to availability zone %s: %v", zone, err)
+			return false, err
+		}
+
+		conditions.MarkTrue(vm, vmopapi.VirtualMachineZoneRelocatedCondition)
+	}
+
+	// When the provider VM does not exist yet, there is nothing to relocate and the VM is just created in
+	// the zone.
+	if vm.Labels == nil {
+		vm.Labels = map[string]string{}
+	}
+	vm.Labels[topology.KubernetesTopologyZoneLabelKey] = zone
+	delete(vm.Annotations, vmopapi.RelocateToZoneAnnotation)
+
+	return true, nil
+}
+
+// backupVM stores the Kubernetes state of the VM in the provider's VM, so the VirtualMachine can be
+// rebuilt after the provider's VM is restored by a backup tool.
+func (r *Reconciler) backupVM(ctx *context.VirtualMachineContext) error {
+	vm := ctx.VM
+	backupArgs := vmprovider.VMBackupArgs{}
+
+	if md := vm.Spec.VmMetadata; md != nil {
+		key := client.ObjectKey{Namespace: vm.Namespace}
+
+		switch {
+		case md.ConfigMapName != "":
+			key.Name = md.ConfigMapName
+			cm := &corev1.ConfigMap{}
+			if err := r.Get(ctx, key, cm); err != nil {
+				return err
+			}
+			cm.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+			backupArgs.VMMetadata = cm
+		case md.SecretName != "":
+			key.Name = md.SecretName
+			secret := &corev1.Secret{}
+			if err := r.Get(ctx, key, secret); err != nil {
+				return err
+			}
+			secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+			backupArgs.VMMetadata = secret
+		}
+	}
+
+	claimNames := map[string]string{}
+	for _, volume := range vm.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claimNames[volume.Name] = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+
+	// Only the volumes that are attached have a disk on the VM.
+	for _, volumeStatus := range vm.Status.Volumes {
+		claimName, ok := claimNames[volumeStatus.Name]
+		if !ok || !volumeStatus.Attached || volumeStatus.DiskUuid == "" {
+			continue
+		}
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: vm.Namespace, Name: claimName}, pvc); err != nil {
+			return err
+		}
+
+		if backupArgs.DiskUUIDToPVC == nil {
+			backupArgs.DiskUUIDToPVC = map[string]corev1.PersistentVolumeClaim{}
+		}
+		backupArgs.DiskUUIDToPVC[volumeStatus.DiskUuid] = *pvc
+	}
+
+	return r.VMProvider.BackupVirtualMachine(ctx, vm, backupArgs)
+}
+
 // reconcileInstanceStorageSpec checks if VM class is configured with instance volumes and adds instance storage data in VM spec accordingly.
 func (r *Reconciler) reconcileInstanceStorageSpec(
 	ctx *context.VirtualMachineContext,
@@ -24,11 +24,13 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/controllers/virtualmachine"
 	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	vmopContext "github.com/acharyasreej/vm-operator/pkg/context"
 	"github.com/acharyasreej/vm-operator/pkg/lib"
 	proberfake "github.com/acharyasreej/vm-operator/pkg/prober/fake"
+	"github.com/acharyasreej/vm-operator/pkg/topology"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
 	providerfake "github.com/acharyasreej/vm-operator/pkg/vmprovider/fake"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
@@ -685,6 +687,269 @@ func unitTestsReconcile() {
 			})
 		})
 
+		When("the WCP_VMService_BackupRestore FSS is enabled", func() {
+			var (
+				orgIsVMServiceBackupRestoreFSSEnabled = lib.IsVMServiceBackupRestoreFSSEnabled
+
+				pvc        *corev1.PersistentVolumeClaim
+				backupArgs *vmprovider.VMBackupArgs
+			)
+
+			BeforeEach(func() {
+				lib.IsVMServiceBackupRestoreFSSEnabled = func() bool {
+					return true
+				}
+
+				pvc = &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "dummy-pvc",
+						Namespace: vm.Namespace,
+					},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					},
+				}
+
+				vm.Spec.VmMetadata = &vmopv1alpha1.VirtualMachineMetadata{
+					ConfigMapName: vmMetaDataConfigMap.Name,
+					Transport:     "transport",
+				}
+				vm.Spec.Volumes = []vmopv1alpha1.VirtualMachineVolume{
+					{
+						Name: "dummy-volume",
+						PersistentVolumeClaim: &vmopv1alpha1.PersistentVolumeClaimVolumeSource{
+							PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: pvc.Name,
+							},
+						},
+					},
+				}
+				vm.Status.Volumes = []vmopv1alpha1.VirtualMachineVolumeStatus{
+					{
+						Name:     "dummy-volume",
+						Attached: true,
+						DiskUuid: "dummy-disk-uuid",
+					},
+				}
+				initObjects = append(initObjects, vmMetaDataConfigMap, pvc)
+				backupArgs = nil
+			})
+
+			JustBeforeEach(func() {
+				fakeVMProvider.BackupVirtualMachineFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine, args vmprovider.VMBackupArgs) error {
+					backupArgs = &args
+					return nil
+				}
+			})
+
+			AfterEach(func() {
+				lib.IsVMServiceBackupRestoreFSSEnabled = orgIsVMServiceBackupRestoreFSSEnabled
+			})
+
+			It("backs up the VM with its metadata and PVCs", func() {
+				err := reconciler.ReconcileNormal(vmCtx)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(backupArgs).ToNot(BeNil())
+				Expect(backupArgs.VMMetadata).ToNot(BeNil())
+				Expect(backupArgs.VMMetadata.GetName()).To(Equal(vmMetaDataConfigMap.Name))
+				Expect(backupArgs.VMMetadata.GetObjectKind().GroupVersionKind().Kind).To(Equal("ConfigMap"))
+				Expect(backupArgs.DiskUUIDToPVC).To(HaveKey("dummy-disk-uuid"))
+				Expect(backupArgs.DiskUUIDToPVC["dummy-disk-uuid"].Name).To(Equal(pvc.Name))
+			})
+
+			It("does not back up volumes that are not attached", func() {
+				vm.Status.Volumes[0].Attached = false
+
+				err := reconciler.ReconcileNormal(vmCtx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(backupArgs).ToNot(BeNil())
+				Expect(backupArgs.DiskUUIDToPVC).To(BeEmpty())
+			})
+
+			It("returns an error when the provider fails to backup the VM", func() {
+				fakeVMProvider.BackupVirtualMachineFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine, args vmprovider.VMBackupArgs) error {
+					return errors.New(providerError)
+				}
+
+				err := reconciler.ReconcileNormal(vmCtx)
+				Expect(err).To(MatchError(providerError))
+				expectEvent(ctx, "BackupFailure")
+			})
+		})
+
+		When("the VM imports an existing VM", func() {
+			var (
+				otherVMClass *vmopv1alpha1.VirtualMachineClass
+				numImports   int
+			)
+
+			BeforeEach(func() {
+				vmClass.Spec.Hardware.Cpus = 2
+				vmClass.Spec.Hardware.Memory = resource.MustParse("4Gi")
+				otherVMClass = &vmopv1alpha1.VirtualMachineClass{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "dummy-other-vmclass",
+					},
+					Spec: vmopv1alpha1.VirtualMachineClassSpec{
+						Hardware: vmopv1alpha1.VirtualMachineClassHardware{
+							Cpus:   4,
+							Memory: resource.MustParse("4Gi"),
+						},
+					},
+				}
+				initObjects = append(initObjects, otherVMClass)
+
+				vm.Annotations = map[string]string{vmopapi.ImportVMMoIDAnnotation: "vm-42"}
+				vm.Spec.ClassName = ""
+				vm.Spec.ImageName = ""
+				numImports = 0
+			})
+
+			JustBeforeEach(func() {
+				fakeVMProvider.ImportVirtualMachineFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine) (vmprovider.VMHardware, error) {
+					numImports++
+					vm.Status.UniqueID = "vm-42"
+					return vmprovider.VMHardware{NumCPUs: 2, Memory: resource.MustParse("4096Mi")}, nil
+				}
+				fakeVMProvider.DoesVirtualMachineExistFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine) (bool, error) {
+					return vm.Status.UniqueID != "", nil
+				}
+			})
+
+			It("imports the VM and sets the class that matches its hardware", func() {
+				err := reconciler.ReconcileNormal(vmCtx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(numImports).To(Equal(1))
+				Expect(vmCtx.VM.Spec.ClassName).To(Equal(vmClass.Name))
+				Expect(vmCtx.VM.Status.UniqueID).To(Equal("vm-42"))
+				Expect(conditions.IsTrue(vmCtx.VM, vmopv1alpha1.VirtualMachinePrereqReadyCondition)).To(BeTrue())
+
+				By("does not import the VM again", func() {
+					err := reconciler.ReconcileNormal(vmCtx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(numImports).To(Equal(1))
+				})
+			})
+
+			It("returns an error when no class matches the hardware of the VM", func() {
+				fakeVMProvider.ImportVirtualMachineFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine) (vmprovider.VMHardware, error) {
+					return vmprovider.VMHardware{NumCPUs: 8, Memory: resource.MustParse("8Gi")}, nil
+				}
+
+				err := reconciler.ReconcileNormal(vmCtx)
+				Expect(err).To(HaveOccurred())
+				Expect(vmCtx.VM.Spec.ClassName).To(BeEmpty())
+
+				msg := "No VirtualMachineClass matches the imported VM with 8 CPUs and 8Gi memory"
+				expectedCondition := vmopv1alpha1.Conditions{
+					*conditions.FalseCondition(
+						vmopv1alpha1.VirtualMachinePrereqReadyCondition,
+						vmopv1alpha1.VirtualMachineClassNotFoundReason,
+						vmopv1alpha1.ConditionSeverityError,
+						msg),
+				}
+				Expect(vmCtx.VM.Status.Conditions).To(conditions.MatchConditions(expectedCondition))
+				expectEvent(ctx, "ImportFailure")
+			})
+
+			It("returns an error instead of creating a new VM when the imported VM does not exist", func() {
+				fakeVMProvider.DoesVirtualMachineExistFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine) (bool, error) {
+					return false, nil
+				}
+				fakeVMProvider.CreateVirtualMachineFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine, _ vmprovider.VMConfigArgs) error {
+					return errors.New("unexpected create")
+				}
+
+				err := reconciler.ReconcileNormal(vmCtx)
+				Expect(err).To(MatchError("imported VirtualMachine does not exist"))
+				expectEvent(ctx, "ImportFailure")
+			})
+
+			It("returns an error when the provider fails to import the VM", func() {
+				fakeVMProvider.ImportVirtualMachineFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine) (vmprovider.VMHardware, error) {
+					return vmprovider.VMHardware{}, errors.New(providerError)
+				}
+
+				err := reconciler.ReconcileNormal(vmCtx)
+				Expect(err).To(MatchError(providerError))
+				expectEvent(ctx, "ImportFailure")
+			})
+		})
+
+		When("the VM is relocated to another availability zone", func() {
+			const (
+				zoneName       = "zone-1"
+				targetZoneName = "zone-2"
+			)
+
+			var (
+				vmExists    bool
+				relocatedTo []string
+			)
+
+			BeforeEach(func() {
+				vm.Labels = map[string]string{topology.KubernetesTopologyZoneLabelKey: zoneName}
+				vm.Annotations = map[string]string{vmopapi.RelocateToZoneAnnotation: targetZoneName}
+				vmExists = true
+				relocatedTo = nil
+			})
+
+			JustBeforeEach(func() {
+				fakeVMProvider.DoesVirtualMachineExistFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine) (bool, error) {
+					return vmExists, nil
+				}
+				fakeVMProvider.RelocateVirtualMachineFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine, zone string) error {
+					relocatedTo = append(relocatedTo, zone)
+					return nil
+				}
+			})
+
+			It("marks the VM as relocating and then relocates the VM", func() {
+				err := reconciler.ReconcileNormal(vmCtx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(relocatedTo).To(BeEmpty())
+				Expect(vmCtx.VM.Labels).To(HaveKeyWithValue(topology.KubernetesTopologyZoneLabelKey, zoneName))
+				Expect(conditions.IsFalse(vmCtx.VM, vmopapi.VirtualMachineZoneRelocatedCondition)).To(BeTrue())
+				Expect(conditions.GetReason(vmCtx.VM, vmopapi.VirtualMachineZoneRelocatedCondition)).To(Equal(vmopapi.VirtualMachineZoneRelocatingReason))
+
+				By("relocates the VM on the next reconcile", func() {
+					err := reconciler.ReconcileNormal(vmCtx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(relocatedTo).To(Equal([]string{targetZoneName}))
+					Expect(vmCtx.VM.Labels).To(HaveKeyWithValue(topology.KubernetesTopologyZoneLabelKey, targetZoneName))
+					Expect(vmCtx.VM.Annotations).ToNot(HaveKey(vmopapi.RelocateToZoneAnnotation))
+					Expect(conditions.IsTrue(vmCtx.VM, vmopapi.VirtualMachineZoneRelocatedCondition)).To(BeTrue())
+				})
+			})
+
+			It("changes the zone without relocating when the VM does not exist", func() {
+				vmExists = false
+
+				err := reconciler.ReconcileNormal(vmCtx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(relocatedTo).To(BeEmpty())
+				Expect(vmCtx.VM.Labels).To(HaveKeyWithValue(topology.KubernetesTopologyZoneLabelKey, targetZoneName))
+				Expect(vmCtx.VM.Annotations).ToNot(HaveKey(vmopapi.RelocateToZoneAnnotation))
+				Expect(conditions.Has(vmCtx.VM, vmopapi.VirtualMachineZoneRelocatedCondition)).To(BeFalse())
+			})
+
+			It("returns an error when the provider fails to relocate the VM", func() {
+				conditions.MarkFalse(vm, vmopapi.VirtualMachineZoneRelocatedCondition, vmopapi.VirtualMachineZoneRelocatingReason,
+					vmopv1alpha1.ConditionSeverityInfo, "")
+				fakeVMProvider.RelocateVirtualMachineFn = func(ctx context.Context, vm *vmopv1alpha1.VirtualMachine, zone string) error {
+					return errors.New(providerError)
+				}
+
+				err := reconciler.ReconcileNormal(vmCtx)
+				Expect(err).To(MatchError(providerError))
+				Expect(vmCtx.VM.Labels).To(HaveKeyWithValue(topology.KubernetesTopologyZoneLabelKey, zoneName))
+				Expect(vmCtx.VM.Annotations).To(HaveKeyWithValue(vmopapi.RelocateToZoneAnnotation, targetZoneName))
+				Expect(conditions.GetReason(vmCtx.VM, vmopapi.VirtualMachineZoneRelocatedCondition)).To(Equal(vmopapi.VirtualMachineZoneRelocateFailedReason))
+				expectEvent(ctx, "RelocateFailure")
+			})
+		})
+
 		When("VM ResourcePolicy is specified", func() {
 			BeforeEach(func() {
 				vm.Spec.ResourcePolicyName = vmResourcePolicy.Name
@@ -0,0 +1,389 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachineimageimport
+
+import (
+	goctx "context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/subscribedcontentlibrary"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/patch"
+	"github.com/acharyasreej/vm-operator/pkg/record"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+)
+
+const (
+	// ImportedItemAnnotationKey is the annotation on the ContentSource of the content library that an image
+	// was uploaded into, which is updated after each upload to trigger a sync of the ContentSource.
+	ImportedItemAnnotationKey = "vmoperator.vmware.com/imported-item"
+
+	// imagePendingRequeueDelay is the delay after which an import is reconciled again while the
+	// VirtualMachineImage of the uploaded image does not exist yet.
+	imagePendingRequeueDelay = 10 * time.Second
+
+	// downloadPendingRequeueDelay is the delay after which an import is reconciled again while its image is
+	// downloading, in case the reconcile triggered at the end of the download is missed.
+	downloadPendingRequeueDelay = time.Minute
+
+	// downloadTimeout is the timeout of the download of an image.
+	downloadTimeout = time.Hour
+
+	// DefaultMaxImageSize is the default maximum size of the OVA of an image.
+	DefaultMaxImageSize = 16 * 1024 * 1024 * 1024
+)
+
+// AddToManager adds this package's controller to the provided manager.
+func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controlledType     = &vmopapi.VirtualMachineImageImport{}
+		controlledTypeName = reflect.TypeOf(controlledType).Elem().Name()
+
+		controllerNameShort = fmt.Sprintf("%s-controller", strings.ToLower(controlledTypeName))
+		controllerNameLong  = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
+	)
+
+	r := NewReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName(controlledTypeName),
+		record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		ctx.VMProvider,
+	)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(controlledType).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Watches(&source.Channel{Source: r.downloader.events}, &handler.EnqueueRequestForObject{}).
+		Complete(r)
+}
+
+func NewReconciler(
+	client client.Client,
+	logger logr.Logger,
+	recorder record.Recorder,
+	vmProvider vmprovider.VirtualMachineProviderInterface) *Reconciler {
+	return &Reconciler{
+		Client:       client,
+		Logger:       logger,
+		Recorder:     recorder,
+		VMProvider:   vmProvider,
+		HTTPClient:   newHTTPClient(),
+		MaxImageSize: DefaultMaxImageSize,
+		downloader:   newDownloader(logger.WithName("downloader")),
+	}
+}
+
+// Reconciler reconciles a VirtualMachineImageImport object.
+type Reconciler struct {
+	client.Client
+	Logger       logr.Logger
+	Recorder     record.Recorder
+	VMProvider   vmprovider.VirtualMachineProviderInterface
+	HTTPClient   *http.Client
+	MaxImageSize int64
+
+	downloader *downloader
+}
+
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachineimageimports,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachineimageimports/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentsourcebindings,verbs=get;list
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentsources,verbs=get;list;update;patch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentlibraryproviders,verbs=get;list
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachineimages,verbs=get;list
+
+func (r *Reconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	vmii := &vmopapi.VirtualMachineImageImport{}
+	if err := r.Get(ctx, req.NamespacedName, vmii); err != nil {
+		if apiErrors.IsNotFound(err) {
+			r.downloader.Forget(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// An import is a one time operation that does not own the uploaded image, so there is nothing to
+	// clean up when it is deleted except for its download.
+	if !vmii.DeletionTimestamp.IsZero() {
+		r.downloader.Forget(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	importCtx := &context.VirtualMachineImageImportContext{
+		Context: ctx,
+		Logger:  r.Logger.WithName("VirtualMachineImageImport").WithValues("name", vmii.NamespacedName()),
+		Import:  vmii,
+	}
+
+	patchHelper, err := patch.NewHelper(vmii, r.Client)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to init patch helper for %s", importCtx.String())
+	}
+	defer func() {
+		if err := patchHelper.Patch(ctx, vmii); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			importCtx.Logger.Error(err, "patch failed")
+		}
+	}()
+
+	if err := r.ReconcileNormal(importCtx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if conditions.IsFalse(vmii, vmopapi.VirtualMachineImageImportReadyCondition) {
+		switch conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportReadyCondition) {
+		case vmopapi.VirtualMachineImageImportImagePendingReason:
+			return ctrl.Result{RequeueAfter: imagePendingRequeueDelay}, nil
+		case vmopapi.VirtualMachineImageImportDownloadingReason:
+			return ctrl.Result{RequeueAfter: downloadPendingRequeueDelay}, nil
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// ReconcileNormal uploads the image into the content library of the namespace, if it was not uploaded yet or the
+// spec changed since it was uploaded, and updates the status with the VirtualMachineImage of the uploaded image.
+func (r *Reconciler) ReconcileNormal(ctx *context.VirtualMachineImageImportContext) error {
+	vmii := ctx.Import
+
+	if conditions.IsTrue(vmii, vmopapi.VirtualMachineImageImportUploadedCondition) &&
+		vmii.Status.ObservedGeneration != vmii.Generation {
+		// The spec changed since the image was uploaded, so the image is imported again.
+		ctx.Logger.Info("Spec changed, importing image again",
+			"observedGeneration", vmii.Status.ObservedGeneration, "generation", vmii.Generation)
+		conditions.MarkFalse(vmii, vmopapi.VirtualMachineImageImportUploadedCondition,
+			vmopapi.VirtualMachineImageImportDownloadingReason, vmopv1alpha1.ConditionSeverityInfo,
+			"Spec changed, importing image again")
+	}
+
+	if !conditions.IsTrue(vmii, vmopapi.VirtualMachineImageImportUploadedCondition) {
+		// Downloading the same image again would fail the verification again, so a verification failure is
+		// only retried once the spec changes.
+		if conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportUploadedCondition) ==
+			vmopapi.VirtualMachineImageImportVerificationFailedReason &&
+			vmii.Status.ObservedGeneration == vmii.Generation {
+			return nil
+		}
+
+		vmii.Status.ObservedGeneration = vmii.Generation
+		if err := r.importImage(ctx); err != nil || !conditions.IsTrue(vmii, vmopapi.VirtualMachineImageImportUploadedCondition) {
+			markNotReady(vmii)
+			return err
+		}
+	}
+
+	return r.updateImageStatus(ctx)
+}
+
+// markNotReady sets the Ready condition of an import whose image was not uploaded from the Uploaded condition.
+func markNotReady(vmii *vmopapi.VirtualMachineImageImport) {
+	c := conditions.Get(vmii, vmopapi.VirtualMachineImageImportUploadedCondition)
+	if c == nil {
+		return
+	}
+	conditions.MarkFalse(vmii, vmopapi.VirtualMachineImageImportReadyCondition, c.Reason, c.Severity, "%s", c.Message)
+}
+
+// importImage downloads and verifies the image, and uploads it into the content library of the namespace. The
+// image is downloaded in the background, and the import is reconciled again once the download is done.
+func (r *Reconciler) importImage(ctx *context.VirtualMachineImageImportContext) error {
+	vmii := ctx.Import
+
+	clUUID, cs, err := GetContentLibrary(ctx, r.Client, vmii.Namespace, "")
+	if err != nil {
+		conditions.MarkFalse(vmii, vmopapi.VirtualMachineImageImportUploadedCondition,
+			vmopapi.VirtualMachineImageImportContentLibraryNotFoundReason, vmopv1alpha1.ConditionSeverityError, "%v", err)
+		return err
+	}
+
+	dl := r.downloader.Get(vmii, r.HTTPClient, r.MaxImageSize)
+	if !dl.done {
+		conditions.MarkFalse(vmii, vmopapi.VirtualMachineImageImportUploadedCondition,
+			vmopapi.VirtualMachineImageImportDownloadingReason, vmopv1alpha1.ConditionSeverityInfo,
+			"Downloading image")
+		return nil
+	}
+
+	// The next attempt, if any, downloads the image again.
+	defer r.downloader.Forget(types.NamespacedName{Namespace: vmii.Namespace, Name: vmii.Name})
+
+	if dl.err != nil {
+		conditions.MarkFalse(vmii, vmopapi.VirtualMachineImageImportUploadedCondition,
+			vmopapi.VirtualMachineImageImportDownloadFailedReason, vmopv1alpha1.ConditionSeverityError, "%v", dl.err)
+		return errors.Wrapf(dl.err, "failed to download image of %s", vmii.NamespacedName())
+	}
+
+	if err := verifyImage(dl.digest, vmii.Spec.Digest, vmii.Spec.Signature); err != nil {
+		ctx.Logger.Error(err, "Image verification failed")
+		conditions.MarkFalse(vmii, vmopapi.VirtualMachineImageImportUploadedCondition,
+			vmopapi.VirtualMachineImageImportVerificationFailedReason, vmopv1alpha1.ConditionSeverityError, "%v", err)
+		return nil
+	}
+
+	filePaths, err := extractOVA(dl.ovaPath, dl.dir)
+	if err != nil {
+		ctx.Logger.Error(err, "Invalid OVA")
+		conditions.MarkFalse(vmii, vmopapi.VirtualMachineImageImportUploadedCondition,
+			vmopapi.VirtualMachineImageImportVerificationFailedReason, vmopv1alpha1.ConditionSeverityError, "%v", err)
+		return nil
+	}
+
+	imageName := vmii.Spec.ImageName
+	if imageName == "" {
+		imageName = vmii.Name
+	}
+
+	ctx.Logger.Info("Uploading image into content library", "libraryUUID", clUUID, "imageName", imageName)
+	itemID, err := r.VMProvider.ImportContentLibraryItem(ctx, clUUID, imageName, itemDescription(vmii), filePaths)
+	if err != nil {
+		conditions.MarkFalse(vmii, vmopapi.VirtualMachineImageImportUploadedCondition,
+			vmopapi.VirtualMachineImageImportUploadFailedReason, vmopv1alpha1.ConditionSeverityError, "%v", err)
+		return errors.Wrapf(err, "failed to upload image of %s", vmii.NamespacedName())
+	}
+
+	vmii.Status.LibraryUUID = clUUID
+	vmii.Status.LibraryItemID = itemID
+	vmii.Status.ImageName = ""
+	conditions.MarkTrue(vmii, vmopapi.VirtualMachineImageImportUploadedCondition)
+	r.Recorder.EmitEvent(vmii, "Upload", nil, false)
+
+	// Trigger a sync of the ContentSource so the VirtualMachineImage of the item is created without waiting
+	// for the next resync.
+	if cs.Annotations == nil {
+		cs.Annotations = map[string]string{}
+	}
+	cs.Annotations[ImportedItemAnnotationKey] = fmt.Sprintf("%s/%d", vmii.NamespacedName(), vmii.Generation)
+	if err := r.Update(ctx, cs); err != nil {
+		ctx.Logger.Error(err, "failed to trigger sync of ContentSource", "contentSourceName", cs.Name)
+	}
+
+	return nil
+}
+
+// itemDescription returns the description of the library item of the import, which identifies the items that
+// the import may update.
+func itemDescription(vmii *vmopapi.VirtualMachineImageImport) string {
+	return fmt.Sprintf("Imported by VirtualMachineImageImport %s", vmii.NamespacedName())
+}
+
+// GetContentLibrary returns the UUID and ContentSource of the content library of the namespace that images are
+// uploaded into. The library must be owned by the namespace, that is its ContentSource must only be bound to the
+// namespace, so that images shared with other namespaces cannot be replaced, and must not be a subscribed library.
+// When contentSourceName is empty, the first such library by name of its ContentSourceBinding is returned.
+func GetContentLibrary(
+	ctx goctx.Context,
+	c client.Reader,
+	namespace, contentSourceName string) (string, *vmopv1alpha1.ContentSource, error) {
+
+	allBindings := &vmopv1alpha1.ContentSourceBindingList{}
+	if err := c.List(ctx, allBindings); err != nil {
+		return "", nil, errors.Wrap(err, "failed to list ContentSourceBindings")
+	}
+
+	var bindings []vmopv1alpha1.ContentSourceBinding
+	sharedContentSources := map[string]bool{}
+	for _, binding := range allBindings.Items {
+		if binding.Namespace == namespace {
+			bindings = append(bindings, binding)
+		} else {
+			sharedContentSources[binding.ContentSourceRef.Name] = true
+		}
+	}
+
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Name < bindings[j].Name
+	})
+
+	for _, binding := range bindings {
+		csName := binding.ContentSourceRef.Name
+		if contentSourceName != "" && csName != contentSourceName {
+			continue
+		}
+
+		if sharedContentSources[csName] {
+			if contentSourceName != "" {
+				return "", nil, errors.Errorf("ContentSource %s is shared with other namespaces", csName)
+			}
+			continue
+		}
+
+		cs := &vmopv1alpha1.ContentSource{}
+		if err := c.Get(ctx, client.ObjectKey{Name: csName}, cs); err != nil {
+			if client.IgnoreNotFound(err) == nil && contentSourceName == "" {
+				continue
+			}
+			return "", nil, errors.Wrapf(err, "failed to get ContentSource %s", csName)
+		}
+
+		// Images cannot be uploaded into a subscribed library.
+		if _, ok := cs.Labels[subscribedcontentlibrary.SubscribedContentLibraryLabelKey]; ok {
+			if contentSourceName != "" {
+				return "", nil, errors.Errorf("ContentSource %s is of a subscribed content library", csName)
+			}
+			continue
+		}
+
+		clProvider := &vmopv1alpha1.ContentLibraryProvider{}
+		if err := c.Get(ctx, client.ObjectKey{Name: cs.Spec.ProviderRef.Name}, clProvider); err != nil {
+			if client.IgnoreNotFound(err) == nil && contentSourceName == "" {
+				continue
+			}
+			return "", nil, errors.Wrapf(err, "failed to get ContentLibraryProvider %s", cs.Spec.ProviderRef.Name)
+		}
+
+		return clProvider.Spec.UUID, cs, nil
+	}
+
+	if contentSourceName != "" {
+		return "", nil, errors.Errorf("ContentSource %s is not bound to namespace %s", contentSourceName, namespace)
+	}
+	return "", nil, errors.Errorf("namespace %s does not have a content library of its own to upload images into", namespace)
+}
+
+// updateImageStatus updates the status with the VirtualMachineImage of the uploaded image, once the
+// ContentSource of the content library has been synced.
+func (r *Reconciler) updateImageStatus(ctx *context.VirtualMachineImageImportContext) error {
+	vmii := ctx.Import
+
+	images := &vmopv1alpha1.VirtualMachineImageList{}
+	if err := r.List(ctx, images); err != nil {
+		return errors.Wrap(err, "failed to list VirtualMachineImages")
+	}
+
+	for _, image := range images.Items {
+		if image.Spec.ImageID == vmii.Status.LibraryItemID {
+			vmii.Status.ImageName = image.Name
+			conditions.MarkTrue(vmii, vmopapi.VirtualMachineImageImportReadyCondition)
+			return nil
+		}
+	}
+
+	conditions.MarkFalse(vmii, vmopapi.VirtualMachineImageImportReadyCondition,
+		vmopapi.VirtualMachineImageImportImagePendingReason, vmopv1alpha1.ConditionSeverityInfo,
+		"Waiting for the VirtualMachineImage of library item %s", vmii.Status.LibraryItemID)
+	return nil
+}
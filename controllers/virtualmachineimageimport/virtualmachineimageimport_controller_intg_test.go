@@ -0,0 +1,126 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachineimageimport_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachineimageimport"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func intgTests() {
+	const (
+		clUUID = "dummy-intg-cl-uuid"
+	)
+
+	var (
+		ctx *builder.IntegrationTestContext
+
+		server *httptest.Server
+		vmii   *vmopapi.VirtualMachineImageImport
+	)
+
+	BeforeEach(func() {
+		ctx = suite.NewIntegrationTestContext()
+
+		ova := newOVA()
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(ova)
+		}))
+
+		vmii = &vmopapi.VirtualMachineImageImport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-import",
+				Namespace: ctx.Namespace,
+			},
+			Spec: vmopapi.VirtualMachineImageImportSpec{
+				Source: vmopapi.VirtualMachineImageImportSource{
+					HTTP: &vmopapi.HTTPImageSource{URL: server.URL + "/dummy.ova"},
+				},
+				Digest:    sha256Digest(ova),
+				ImageName: "dummy-intg-image",
+			},
+		}
+
+		clProvider := &vmopv1alpha1.ContentLibraryProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: clUUID},
+			Spec:       vmopv1alpha1.ContentLibraryProviderSpec{UUID: clUUID},
+		}
+		Expect(ctx.Client.Create(ctx, clProvider)).To(Succeed())
+
+		cs := &vmopv1alpha1.ContentSource{
+			ObjectMeta: metav1.ObjectMeta{Name: clUUID},
+			Spec: vmopv1alpha1.ContentSourceSpec{
+				ProviderRef: vmopv1alpha1.ContentProviderReference{Name: clUUID, Kind: "ContentLibraryProvider"},
+			},
+		}
+		Expect(ctx.Client.Create(ctx, cs)).To(Succeed())
+
+		binding := &vmopv1alpha1.ContentSourceBinding{
+			ObjectMeta:       metav1.ObjectMeta{Name: clUUID, Namespace: ctx.Namespace},
+			ContentSourceRef: vmopv1alpha1.ContentSourceReference{Name: clUUID, Kind: "ContentSource"},
+		}
+		Expect(ctx.Client.Create(ctx, binding)).To(Succeed())
+
+		// The fake provider returns the library UUID and item name as the ID of the item.
+		image := &vmopv1alpha1.VirtualMachineImage{
+			ObjectMeta: metav1.ObjectMeta{Name: "dummy-intg-image"},
+			Spec:       vmopv1alpha1.VirtualMachineImageSpec{ImageID: clUUID + "-dummy-intg-image"},
+		}
+		Expect(ctx.Client.Create(ctx, image)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		for _, obj := range []client.Object{
+			&vmopv1alpha1.VirtualMachineImage{ObjectMeta: metav1.ObjectMeta{Name: "dummy-intg-image"}},
+			&vmopv1alpha1.ContentSource{ObjectMeta: metav1.ObjectMeta{Name: clUUID}},
+			&vmopv1alpha1.ContentLibraryProvider{ObjectMeta: metav1.ObjectMeta{Name: clUUID}},
+		} {
+			Expect(client.IgnoreNotFound(ctx.Client.Delete(ctx, obj))).To(Succeed())
+		}
+		ctx.AfterEach()
+		ctx = nil
+		intgFakeVMProvider.Reset()
+	})
+
+	Context("Reconcile", func() {
+		It("Reconciles after VirtualMachineImageImport creation", func() {
+			Expect(ctx.Client.Create(ctx, vmii)).To(Succeed())
+			vmiiKey := client.ObjectKeyFromObject(vmii)
+
+			By("VirtualMachineImageImport should be ready", func() {
+				Eventually(func() bool {
+					vmii := &vmopapi.VirtualMachineImageImport{}
+					if err := ctx.Client.Get(ctx, vmiiKey, vmii); err != nil {
+						return false
+					}
+					return conditions.IsTrue(vmii, vmopapi.VirtualMachineImageImportReadyCondition)
+				}).Should(BeTrue())
+
+				Expect(ctx.Client.Get(ctx, vmiiKey, vmii)).To(Succeed())
+				Expect(vmii.Status.ImageName).To(Equal("dummy-intg-image"))
+				Expect(vmii.Status.LibraryUUID).To(Equal(clUUID))
+			})
+
+			By("ContentSource should be annotated to trigger a sync", func() {
+				cs := &vmopv1alpha1.ContentSource{}
+				Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: clUUID}, cs)).To(Succeed())
+				Expect(cs.Annotations).To(HaveKey(virtualmachineimageimport.ImportedItemAnnotationKey))
+			})
+		})
+	})
+}
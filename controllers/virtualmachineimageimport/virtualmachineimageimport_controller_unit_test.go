@@ -0,0 +1,462 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachineimageimport_test
+
+import (
+	"archive/tar"
+	"bytes"
+	goctx "context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/subscribedcontentlibrary"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachineimageimport"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	providerfake "github.com/acharyasreej/vm-operator/pkg/vmprovider/fake"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func unitTests() {
+	Describe("Invoking Reconcile", unitTestsReconcile)
+}
+
+// newOVA returns an OVA with an OVF descriptor and a disk.
+func newOVA() []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range []struct{ name, content string }{
+		{"dummy.ovf", "<Envelope/>"},
+		{"dummy-disk1.vmdk", "disk"},
+	} {
+		Expect(tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0600, Size: int64(len(f.content)), Typeflag: tar.TypeReg})).To(Succeed())
+		_, err := tw.Write([]byte(f.content))
+		Expect(err).ToNot(HaveOccurred())
+	}
+	Expect(tw.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func unitTestsReconcile() {
+	const (
+		clUUID    = "dummy-cl-uuid"
+		namespace = "dummy-ns"
+	)
+
+	var (
+		initObjects    []client.Object
+		ctx            *builder.UnitTestContextForController
+		reconciler     *virtualmachineimageimport.Reconciler
+		fakeVMProvider *providerfake.VMProvider
+
+		importCtx *context.VirtualMachineImageImportContext
+		vmii      *vmopapi.VirtualMachineImageImport
+		cs        *vmopv1alpha1.ContentSource
+
+		ova       []byte
+		server    *httptest.Server
+		downloads int32
+
+		uploadedItemName        string
+		uploadedItemDescription string
+		uploadedFiles           []string
+	)
+
+	BeforeEach(func() {
+		ova = newOVA()
+		atomic.StoreInt32(&downloads, 0)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&downloads, 1)
+			if r.URL.Path != "/images/dummy.ova" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(ova)
+		}))
+
+		vmii = &vmopapi.VirtualMachineImageImport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "dummy-import",
+				Namespace:  namespace,
+				Generation: 1,
+			},
+			Spec: vmopapi.VirtualMachineImageImportSpec{
+				Source: vmopapi.VirtualMachineImageImportSource{
+					HTTP: &vmopapi.HTTPImageSource{URL: server.URL + "/images/dummy.ova"},
+				},
+				Digest:    sha256Digest(ova),
+				ImageName: "dummy-image",
+			},
+		}
+
+		var clProvider *vmopv1alpha1.ContentLibraryProvider
+		var binding *vmopv1alpha1.ContentSourceBinding
+		clProvider, cs, binding = builder.DummyContentSource(clUUID, namespace)
+		initObjects = append(initObjects, vmii, clProvider, cs, binding)
+	})
+
+	JustBeforeEach(func() {
+		ctx = suite.NewUnitTestContextForController(initObjects...)
+		reconciler = virtualmachineimageimport.NewReconciler(
+			ctx.Client,
+			ctx.Logger,
+			ctx.Recorder,
+			ctx.VMProvider,
+		)
+		// The test servers listen on the loopback, which the default client does not connect to.
+		reconciler.HTTPClient = &http.Client{}
+		fakeVMProvider = ctx.VMProvider.(*providerfake.VMProvider)
+		fakeVMProvider.ImportContentLibraryItemFn = func(_ goctx.Context, uuid, itemName, itemDescription string, filePaths []string) (string, error) {
+			uploadedItemName = itemName
+			uploadedItemDescription = itemDescription
+			uploadedFiles = nil
+			for _, p := range filePaths {
+				uploadedFiles = append(uploadedFiles, filepath.Base(p))
+			}
+			return "dummy-item-id", nil
+		}
+
+		importCtx = &context.VirtualMachineImageImportContext{
+			Context: ctx,
+			Logger:  ctx.Logger.WithName(vmii.Name),
+			Import:  vmii,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		ctx.AfterEach()
+		ctx = nil
+		initObjects = nil
+		importCtx = nil
+		reconciler = nil
+		fakeVMProvider = nil
+		uploadedItemName = ""
+		uploadedItemDescription = ""
+		uploadedFiles = nil
+	})
+
+	// reconcileNormal reconciles the import until its image is no longer downloading.
+	reconcileNormal := func() error {
+		var err error
+		Eventually(func() string {
+			err = reconciler.ReconcileNormal(importCtx)
+			return conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportUploadedCondition)
+		}).ShouldNot(Equal(vmopapi.VirtualMachineImageImportDownloadingReason))
+		return err
+	}
+
+	expectUploaded := func() {
+		Expect(conditions.IsTrue(vmii, vmopapi.VirtualMachineImageImportUploadedCondition)).To(BeTrue())
+		Expect(uploadedItemName).To(Equal("dummy-image"))
+		Expect(uploadedItemDescription).To(Equal("Imported by VirtualMachineImageImport dummy-ns/dummy-import"))
+		Expect(uploadedFiles).To(Equal([]string{"dummy.ovf", "dummy-disk1.vmdk"}))
+		Expect(vmii.Status.LibraryUUID).To(Equal(clUUID))
+		Expect(vmii.Status.LibraryItemID).To(Equal("dummy-item-id"))
+	}
+
+	expectVerificationFailed := func(msg string) {
+		c := conditions.Get(vmii, vmopapi.VirtualMachineImageImportUploadedCondition)
+		Expect(c).ToNot(BeNil())
+		Expect(c.Reason).To(Equal(vmopapi.VirtualMachineImageImportVerificationFailedReason))
+		Expect(c.Message).To(ContainSubstring(msg))
+		Expect(conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportReadyCondition)).To(
+			Equal(vmopapi.VirtualMachineImageImportVerificationFailedReason))
+		Expect(uploadedItemName).To(BeEmpty())
+	}
+
+	Context("ReconcileNormal", func() {
+		It("will download the image in the background", func() {
+			Expect(reconciler.ReconcileNormal(importCtx)).To(Succeed())
+			Expect(conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportReadyCondition)).To(
+				Equal(vmopapi.VirtualMachineImageImportDownloadingReason))
+			Expect(uploadedItemName).To(BeEmpty())
+
+			Expect(reconcileNormal()).To(Succeed())
+			expectUploaded()
+			Expect(atomic.LoadInt32(&downloads)).To(BeEquivalentTo(1))
+		})
+
+		It("will upload the image and wait for its VirtualMachineImage", func() {
+			Expect(reconcileNormal()).To(Succeed())
+			expectUploaded()
+			Expect(conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportReadyCondition)).To(
+				Equal(vmopapi.VirtualMachineImageImportImagePendingReason))
+
+			updatedCS := &vmopv1alpha1.ContentSource{}
+			Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: cs.Name}, updatedCS)).To(Succeed())
+			Expect(updatedCS.Annotations).To(HaveKeyWithValue(virtualmachineimageimport.ImportedItemAnnotationKey, "dummy-ns/dummy-import/1"))
+
+			By("VirtualMachineImage of the item is created", func() {
+				image := &vmopv1alpha1.VirtualMachineImage{
+					ObjectMeta: metav1.ObjectMeta{Name: "dummy-image"},
+					Spec:       vmopv1alpha1.VirtualMachineImageSpec{ImageID: "dummy-item-id"},
+				}
+				Expect(ctx.Client.Create(ctx, image)).To(Succeed())
+			})
+
+			Expect(reconcileNormal()).To(Succeed())
+			Expect(vmii.Status.ImageName).To(Equal("dummy-image"))
+			Expect(conditions.IsTrue(vmii, vmopapi.VirtualMachineImageImportReadyCondition)).To(BeTrue())
+			Expect(atomic.LoadInt32(&downloads)).To(BeEquivalentTo(1))
+		})
+
+		It("will upload the image again when the spec changes", func() {
+			Expect(reconcileNormal()).To(Succeed())
+			expectUploaded()
+
+			Expect(reconcileNormal()).To(Succeed())
+			Expect(atomic.LoadInt32(&downloads)).To(BeEquivalentTo(1))
+
+			uploadedItemName = ""
+			vmii.Generation++
+			Expect(reconcileNormal()).To(Succeed())
+			expectUploaded()
+			Expect(vmii.Status.ObservedGeneration).To(Equal(vmii.Generation))
+			Expect(atomic.LoadInt32(&downloads)).To(BeEquivalentTo(2))
+
+			updatedCS := &vmopv1alpha1.ContentSource{}
+			Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: cs.Name}, updatedCS)).To(Succeed())
+			Expect(updatedCS.Annotations).To(HaveKeyWithValue(virtualmachineimageimport.ImportedItemAnnotationKey, "dummy-ns/dummy-import/2"))
+		})
+
+		It("will default the image name to the name of the import", func() {
+			vmii.Spec.ImageName = ""
+			Expect(reconcileNormal()).To(Succeed())
+			Expect(uploadedItemName).To(Equal(vmii.Name))
+		})
+
+		When("the digest does not match", func() {
+			BeforeEach(func() {
+				vmii.Spec.Digest = sha256Digest([]byte("other"))
+			})
+
+			It("will not upload the image and not retry until the spec changes", func() {
+				Expect(reconcileNormal()).To(Succeed())
+				expectVerificationFailed("does not match expected digest")
+
+				Expect(reconcileNormal()).To(Succeed())
+				Expect(atomic.LoadInt32(&downloads)).To(BeEquivalentTo(1))
+
+				vmii.Spec.Digest = sha256Digest(ova)
+				vmii.Generation++
+				Expect(reconcileNormal()).To(Succeed())
+				expectUploaded()
+			})
+		})
+
+		When("the image is signed", func() {
+			var (
+				key *ecdsa.PrivateKey
+			)
+
+			BeforeEach(func() {
+				var err error
+				key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				Expect(err).ToNot(HaveOccurred())
+
+				der, err := x509.MarshalPKIXPublicKey(key.Public())
+				Expect(err).ToNot(HaveOccurred())
+
+				digest := sha256.Sum256(ova)
+				sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+				Expect(err).ToNot(HaveOccurred())
+
+				vmii.Spec.Signature = &vmopapi.VirtualMachineImageImportSignature{
+					PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+					Value:     base64.StdEncoding.EncodeToString(sig),
+				}
+			})
+
+			It("will upload the image with a valid signature", func() {
+				Expect(reconcileNormal()).To(Succeed())
+				expectUploaded()
+			})
+
+			It("will not upload the image with an invalid signature", func() {
+				vmii.Spec.Signature.Value = base64.StdEncoding.EncodeToString([]byte("bogus"))
+				Expect(reconcileNormal()).To(Succeed())
+				expectVerificationFailed("invalid image signature")
+			})
+		})
+
+		When("the source is not an OVA", func() {
+			BeforeEach(func() {
+				ova = []byte("not an ova")
+				vmii.Spec.Digest = sha256Digest(ova)
+			})
+
+			It("will not upload the image", func() {
+				Expect(reconcileNormal()).To(Succeed())
+				expectVerificationFailed("OVA")
+			})
+		})
+
+		When("the download fails", func() {
+			BeforeEach(func() {
+				vmii.Spec.Source.HTTP.URL = server.URL + "/images/missing.ova"
+			})
+
+			It("will return an error", func() {
+				err := reconcileNormal()
+				Expect(err).To(HaveOccurred())
+				Expect(conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportUploadedCondition)).To(
+					Equal(vmopapi.VirtualMachineImageImportDownloadFailedReason))
+				Expect(conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportReadyCondition)).To(
+					Equal(vmopapi.VirtualMachineImageImportDownloadFailedReason))
+			})
+		})
+
+		When("the upload fails", func() {
+			It("will return an error", func() {
+				fakeVMProvider.ImportContentLibraryItemFn = func(_ goctx.Context, _, _, _ string, _ []string) (string, error) {
+					return "", fmt.Errorf("upload error")
+				}
+
+				err := reconcileNormal()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("upload error"))
+				Expect(conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportUploadedCondition)).To(
+					Equal(vmopapi.VirtualMachineImageImportUploadFailedReason))
+			})
+		})
+
+		When("the namespace only has a subscribed content library", func() {
+			BeforeEach(func() {
+				cs.Labels = map[string]string{subscribedcontentlibrary.SubscribedContentLibraryLabelKey: "dummy-ns.dummy"}
+			})
+
+			It("will return an error", func() {
+				Expect(reconcileNormal()).ToNot(Succeed())
+				Expect(conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportUploadedCondition)).To(
+					Equal(vmopapi.VirtualMachineImageImportContentLibraryNotFoundReason))
+				Expect(atomic.LoadInt32(&downloads)).To(BeZero())
+			})
+		})
+
+		When("the content library is shared with another namespace", func() {
+			BeforeEach(func() {
+				initObjects = append(initObjects, &vmopv1alpha1.ContentSourceBinding{
+					ObjectMeta:       metav1.ObjectMeta{Name: clUUID, Namespace: "other-ns"},
+					ContentSourceRef: vmopv1alpha1.ContentSourceReference{Name: clUUID, Kind: "ContentSource"},
+				})
+			})
+
+			It("will return an error", func() {
+				Expect(reconcileNormal()).ToNot(Succeed())
+				Expect(conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportUploadedCondition)).To(
+					Equal(vmopapi.VirtualMachineImageImportContentLibraryNotFoundReason))
+				Expect(atomic.LoadInt32(&downloads)).To(BeZero())
+			})
+		})
+
+		When("the image is larger than the maximum size", func() {
+			It("will return an error", func() {
+				reconciler.MaxImageSize = int64(len(ova) - 1)
+				err := reconcileNormal()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("larger than the maximum size"))
+				Expect(conditions.GetReason(vmii, vmopapi.VirtualMachineImageImportUploadedCondition)).To(
+					Equal(vmopapi.VirtualMachineImageImportDownloadFailedReason))
+			})
+		})
+
+		When("the source is on the loopback", func() {
+			It("will not download the image with the default client", func() {
+				reconciler.HTTPClient = virtualmachineimageimport.NewReconciler(ctx.Client, ctx.Logger, ctx.Recorder, ctx.VMProvider).HTTPClient
+				err := reconcileNormal()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("is not allowed"))
+				Expect(atomic.LoadInt32(&downloads)).To(BeZero())
+			})
+		})
+
+		When("the source is an OCI artifact", func() {
+			var (
+				registry *httptest.Server
+			)
+
+			BeforeEach(func() {
+				const token = "dummy-token"
+				layerDigest := sha256Digest(ova)
+
+				registry = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/token" {
+						if r.URL.Query().Get("scope") != "repository:images/dummy:pull" {
+							w.WriteHeader(http.StatusBadRequest)
+							return
+						}
+						_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+						return
+					}
+
+					if r.Header.Get("Authorization") != "Bearer "+token {
+						w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+							`Bearer realm="https://%s/token",service="registry",scope="repository:images/dummy:pull"`, r.Host))
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+
+					switch r.URL.Path {
+					case "/v2/images/dummy/manifests/1.0":
+						_ = json.NewEncoder(w).Encode(map[string]interface{}{
+							"schemaVersion": 2,
+							"layers": []map[string]string{
+								{"mediaType": "application/vnd.vmware.ova", "digest": layerDigest},
+							},
+						})
+					case "/v2/images/dummy/blobs/" + layerDigest:
+						_, _ = w.Write(ova)
+					default:
+						w.WriteHeader(http.StatusNotFound)
+					}
+				}))
+
+				vmii.Spec.Source = vmopapi.VirtualMachineImageImportSource{
+					OCI: &vmopapi.OCIImageSource{
+						Reference: strings.TrimPrefix(registry.URL, "https://") + "/images/dummy:1.0",
+					},
+				}
+			})
+
+			AfterEach(func() {
+				registry.Close()
+			})
+
+			It("will pull and upload the image", func() {
+				reconciler.HTTPClient = registry.Client()
+				Expect(reconcileNormal()).To(Succeed())
+				expectUploaded()
+			})
+		})
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachineimageimport
+
+import (
+	goctx "context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+)
+
+// download is the download of the image of a generation of a VirtualMachineImageImport.
+type download struct {
+	generation int64
+	cancel     goctx.CancelFunc
+
+	// The fields below are set once the download is done.
+	done    bool
+	dir     string
+	ovaPath string
+	digest  []byte
+	err     error
+}
+
+// downloader downloads the images of the VirtualMachineImageImports in the background, so that a download
+// does not block a reconcile for up to the download timeout. A reconcile of the import is triggered when its
+// download is done.
+type downloader struct {
+	Logger logr.Logger
+
+	mu        sync.Mutex
+	downloads map[types.NamespacedName]*download
+	events    chan event.GenericEvent
+}
+
+func newDownloader(logger logr.Logger) *downloader {
+	return &downloader{
+		Logger:    logger,
+		downloads: map[types.NamespacedName]*download{},
+		events:    make(chan event.GenericEvent, 100),
+	}
+}
+
+// Get returns the download of the image of the generation of the import. The download is started when there
+// is no download for the generation, and the download of any other generation is canceled.
+func (d *downloader) Get(
+	vmii *vmopapi.VirtualMachineImageImport,
+	httpClient *http.Client,
+	maxSize int64) download {
+
+	key := types.NamespacedName{Namespace: vmii.Namespace, Name: vmii.Name}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if dl, ok := d.downloads[key]; ok {
+		if dl.generation == vmii.Generation {
+			return *dl
+		}
+		d.forgetLocked(key)
+	}
+
+	ctx, cancel := goctx.WithTimeout(goctx.Background(), downloadTimeout)
+	dl := &download{generation: vmii.Generation, cancel: cancel}
+	d.downloads[key] = dl
+
+	source := *vmii.Spec.Source.DeepCopy()
+	go func() {
+		defer cancel()
+
+		dir, err := ioutil.TempDir("", "vmimageimport-")
+		var ovaPath string
+		var digest []byte
+		if err == nil {
+			d.Logger.Info("Downloading image", "name", key, "generation", dl.generation)
+			ovaPath, digest, err = downloadImage(ctx, httpClient, source, dir, maxSize)
+		}
+
+		d.mu.Lock()
+		if d.downloads[key] != dl {
+			// The download was canceled or forgotten while it was running.
+			d.mu.Unlock()
+			_ = os.RemoveAll(dir)
+			return
+		}
+		dl.done, dl.dir, dl.ovaPath, dl.digest, dl.err = true, dir, ovaPath, digest, err
+		d.mu.Unlock()
+
+		// The import is also requeued while its image is downloading, so the event is dropped rather than
+		// blocking when nothing consumes the events.
+		select {
+		case d.events <- event.GenericEvent{Object: &vmopapi.VirtualMachineImageImport{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		}}:
+		default:
+		}
+	}()
+
+	return *dl
+}
+
+// Forget cancels the download of the import, if it is still running, and removes the downloaded files.
+func (d *downloader) Forget(key types.NamespacedName) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.forgetLocked(key)
+}
+
+func (d *downloader) forgetLocked(key types.NamespacedName) {
+	dl, ok := d.downloads[key]
+	if !ok {
+		return
+	}
+
+	dl.cancel()
+	if dl.dir != "" {
+		_ = os.RemoveAll(dl.dir)
+	}
+	delete(d.downloads, key)
+}
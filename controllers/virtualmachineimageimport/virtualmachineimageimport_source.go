@@ -0,0 +1,451 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachineimageimport
+
+import (
+	"archive/tar"
+	goctx "context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+)
+
+const (
+	ovaFileName = "image.ova"
+
+	ociManifestMediaTypes = "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+
+	// maxMetadataSize is the maximum size of a manifest or a token response of a registry.
+	maxMetadataSize = 4 * 1024 * 1024
+)
+
+// nonPublicNetworks are the networks, besides the loopback, link-local, multicast and unspecified addresses,
+// that images are not downloaded from.
+var nonPublicNetworks = mustParseCIDRs(
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+)
+
+// verificationError is an error of a downloaded image that does not match its digest or signature. The
+// import is not retried after a verification error, since downloading the same image again fails too.
+type verificationError struct {
+	error
+}
+
+// newHTTPClient returns the HTTP client that downloads images. The client only connects to public addresses,
+// so that an import cannot reach the services of the cluster, the host or the management network.
+func newHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		// The address is checked after it is resolved, so a name that resolves to a private address is denied.
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicIP(net.ParseIP(host)) {
+				return errors.Errorf("downloading images from %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect to the address on behalf of the client, bypassing the check of the address.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   downloadTimeout,
+	}
+}
+
+// isPublicIP returns true if the IP is not a loopback, link-local, multicast, unspecified or private address.
+func isPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// downloadImage downloads the OVA of the source into the directory, and returns the path and the SHA-256
+// digest of the OVA. The download fails when the OVA is larger than maxSize.
+func downloadImage(
+	ctx goctx.Context,
+	httpClient *http.Client,
+	source vmopapi.VirtualMachineImageImportSource,
+	dir string,
+	maxSize int64) (string, []byte, error) {
+
+	var body io.ReadCloser
+	var err error
+
+	switch {
+	case source.HTTP != nil:
+		body, err = httpGet(ctx, httpClient, source.HTTP.URL, nil, maxSize)
+	case source.OCI != nil:
+		body, err = pullOCIArtifact(ctx, httpClient, source.OCI.Reference, maxSize)
+	default:
+		return "", nil, errors.New("source must have an HTTP URL or an OCI reference")
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	path := filepath.Join(dir, ovaFileName)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	// Read one more byte than allowed to tell an OVA of the maximum size from a larger one.
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to download image")
+	}
+	if n > maxSize {
+		return "", nil, errors.Errorf("image is larger than the maximum size of %d bytes", maxSize)
+	}
+
+	return path, hash.Sum(nil), nil
+}
+
+func httpGet(
+	ctx goctx.Context,
+	httpClient *http.Client,
+	url string,
+	header http.Header,
+	maxSize int64) (io.ReadCloser, error) {
+
+	resp, err := httpDo(ctx, httpClient, url, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, errors.Errorf("GET %s returned %s", url, resp.Status)
+	}
+
+	// Fail early when the server says the image is too large.
+	if resp.ContentLength > maxSize {
+		_ = resp.Body.Close()
+		return nil, errors.Errorf("image is larger than the maximum size of %d bytes", maxSize)
+	}
+
+	return resp.Body, nil
+}
+
+func httpDo(ctx goctx.Context, httpClient *http.Client, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	return httpClient.Do(req)
+}
+
+// verifyImage verifies the digest of a downloaded image against the expected digest and signature.
+func verifyImage(digest []byte, expectedDigest string, signature *vmopapi.VirtualMachineImageImportSignature) error {
+	if actual := "sha256:" + hex.EncodeToString(digest); actual != expectedDigest {
+		return verificationError{errors.Errorf("image digest %s does not match expected digest %s", actual, expectedDigest)}
+	}
+
+	if signature == nil {
+		return nil
+	}
+
+	if err := verifySignature(digest, signature); err != nil {
+		return verificationError{err}
+	}
+
+	return nil
+}
+
+func verifySignature(digest []byte, signature *vmopapi.VirtualMachineImageImportSignature) error {
+	block, _ := pem.Decode([]byte(signature.PublicKey))
+	if block == nil {
+		return errors.New("public key is not PEM encoded")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse public key")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature.Value)
+	if err != nil {
+		return errors.Wrap(err, "signature is not base64 encoded")
+	}
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig); err != nil {
+			return errors.Wrap(err, "invalid image signature")
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest, sig) {
+			return errors.New("invalid image signature")
+		}
+	default:
+		return errors.Errorf("unsupported public key type %T", publicKey)
+	}
+
+	return nil
+}
+
+// extractOVA extracts the files of the OVA into the directory, and returns their paths with the OVF
+// descriptor first.
+func extractOVA(path, dir string) ([]string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var ovfPath string
+	var paths []string
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read OVA")
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// The files of an OVA are all in its root, so only the base name of the file is used.
+		name := filepath.Base(hdr.Name)
+		if name == ovaFileName {
+			return nil, errors.Errorf("OVA has an invalid file name %s", hdr.Name)
+		}
+		filePath := filepath.Join(dir, name)
+		if err := writeFile(filePath, tr); err != nil {
+			return nil, err
+		}
+
+		if strings.HasSuffix(strings.ToLower(name), ".ovf") && ovfPath == "" {
+			ovfPath = filePath
+		} else {
+			paths = append(paths, filePath)
+		}
+	}
+
+	if ovfPath == "" {
+		return nil, errors.New("OVA does not have an OVF descriptor")
+	}
+
+	return append([]string{ovfPath}, paths...), nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+type ociManifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"layers"`
+}
+
+// pullOCIArtifact returns the content of the single layer of an OCI artifact. The artifact is pulled
+// anonymously, with a bearer token when the registry requires one.
+func pullOCIArtifact(ctx goctx.Context, httpClient *http.Client, reference string, maxSize int64) (io.ReadCloser, error) {
+	host, repository, ref, err := parseOCIReference(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := fmt.Sprintf("https://%s/v2/%s", host, repository)
+	header := http.Header{"Accept": []string{ociManifestMediaTypes}}
+
+	manifestURL := baseURL + "/manifests/" + ref
+	resp, err := httpDo(ctx, httpClient, manifestURL, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		_ = resp.Body.Close()
+
+		token, err := getRegistryToken(ctx, httpClient, challenge)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+
+		if resp, err = httpDo(ctx, httpClient, manifestURL, header); err != nil {
+			return nil, err
+		}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("GET %s returned %s", manifestURL, resp.Status)
+	}
+
+	manifest := ociManifest{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxMetadataSize)).Decode(&manifest); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode manifest of %s", reference)
+	}
+
+	if len(manifest.Layers) != 1 {
+		return nil, errors.Errorf("artifact %s has %d layers instead of a single OVA layer", reference, len(manifest.Layers))
+	}
+
+	header.Del("Accept")
+	return httpGet(ctx, httpClient, baseURL+"/blobs/"+manifest.Layers[0].Digest, header, maxSize)
+}
+
+// parseOCIReference returns the registry host, repository and tag or digest of an OCI reference.
+func parseOCIReference(reference string) (string, string, string, error) {
+	i := strings.Index(reference, "/")
+	if i <= 0 {
+		return "", "", "", errors.Errorf("OCI reference %s does not have a registry", reference)
+	}
+	host, repository := reference[:i], reference[i+1:]
+
+	ref := "latest"
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository, ref = repository[:i], repository[i+1:]
+	} else if i := strings.LastIndex(repository, ":"); i >= 0 {
+		repository, ref = repository[:i], repository[i+1:]
+	}
+
+	if repository == "" || ref == "" {
+		return "", "", "", errors.Errorf("invalid OCI reference %s", reference)
+	}
+
+	return host, repository, ref, nil
+}
+
+// getRegistryToken returns an anonymous bearer token for the WWW-Authenticate challenge of a registry.
+func getRegistryToken(ctx goctx.Context, httpClient *http.Client, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", errors.Errorf("unsupported registry authentication challenge %q", challenge)
+	}
+
+	params := map[string]string{}
+	for _, param := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.Errorf("registry authentication challenge %q does not have a realm", challenge)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm, nil)
+	if err != nil {
+		return "", err
+	}
+	q := req.URL.Query()
+	for _, k := range []string{"service", "scope"} {
+		if v := params[k]; v != "" {
+			q.Set(k, v)
+		}
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("GET %s returned %s", realm, resp.Status)
+	}
+
+	tokenResp := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxMetadataSize)).Decode(&tokenResp); err != nil {
+		return "", errors.Wrap(err, "failed to decode registry token")
+	}
+
+	if tokenResp.Token != "" {
+		return tokenResp.Token, nil
+	}
+	return tokenResp.AccessToken, nil
+}
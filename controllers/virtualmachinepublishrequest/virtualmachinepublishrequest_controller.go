@@ -0,0 +1,401 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinepublishrequest
+
+import (
+	goctx "context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachineimageimport"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/patch"
+	"github.com/acharyasreej/vm-operator/pkg/record"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+)
+
+const (
+	finalizerName = "virtualmachinepublishrequest.vmoperator.vmware.com"
+
+	// PublishedItemAnnotationKey is the annotation on the ContentSource of the content library that a
+	// VirtualMachine was published to, which is updated after each publish to trigger a sync of the ContentSource.
+	PublishedItemAnnotationKey = "vmoperator.vmware.com/published-item"
+
+	// pendingRequeueDelay is the delay after which a publish request is reconciled again while the source
+	// VirtualMachine is powering off, or while the VirtualMachineImage of the published item does not exist yet.
+	pendingRequeueDelay = 10 * time.Second
+)
+
+// AddToManager adds this package's controller to the provided manager.
+func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controlledType     = &vmopapi.VirtualMachinePublishRequest{}
+		controlledTypeName = reflect.TypeOf(controlledType).Elem().Name()
+
+		controllerNameShort = fmt.Sprintf("%s-controller", strings.ToLower(controlledTypeName))
+		controllerNameLong  = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
+	)
+
+	r := NewReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName(controlledTypeName),
+		record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		ctx.VMProvider,
+	)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(controlledType).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+func NewReconciler(
+	client client.Client,
+	logger logr.Logger,
+	recorder record.Recorder,
+	vmProvider vmprovider.VirtualMachineProviderInterface) *Reconciler {
+	return &Reconciler{
+		Client:     client,
+		Logger:     logger,
+		Recorder:   recorder,
+		VMProvider: vmProvider,
+	}
+}
+
+// Reconciler reconciles a VirtualMachinePublishRequest object.
+type Reconciler struct {
+	client.Client
+	Logger     logr.Logger
+	Recorder   record.Recorder
+	VMProvider vmprovider.VirtualMachineProviderInterface
+}
+
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachinepublishrequests,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachinepublishrequests/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachines,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentsourcebindings,verbs=get;list
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentsources,verbs=get;list;update;patch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=contentlibraryproviders,verbs=get;list
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachineimages,verbs=get;list
+
+func (r *Reconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	vmpub := &vmopapi.VirtualMachinePublishRequest{}
+	if err := r.Get(ctx, req.NamespacedName, vmpub); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	pubCtx := &context.VirtualMachinePublishRequestContext{
+		Context:        ctx,
+		Logger:         r.Logger.WithName("VirtualMachinePublishRequest").WithValues("name", vmpub.NamespacedName()),
+		PublishRequest: vmpub,
+	}
+
+	patchHelper, err := patch.NewHelper(vmpub, r.Client)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to init patch helper for %s", pubCtx.String())
+	}
+	defer func() {
+		if err := patchHelper.Patch(ctx, vmpub); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			pubCtx.Logger.Error(err, "patch failed")
+		}
+	}()
+
+	if !vmpub.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.ReconcileDelete(pubCtx)
+	}
+
+	if err := r.ReconcileNormal(pubCtx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if conditions.IsFalse(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition) {
+		switch conditions.GetReason(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition) {
+		case vmopapi.VirtualMachinePublishRequestSourcePoweringOffReason, vmopapi.VirtualMachinePublishRequestImagePendingReason:
+			return ctrl.Result{RequeueAfter: pendingRequeueDelay}, nil
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// ReconcileNormal publishes the source VirtualMachine, if it was not published yet, and updates the status with
+// the VirtualMachineImage of the published item.
+func (r *Reconciler) ReconcileNormal(ctx *context.VirtualMachinePublishRequestContext) error {
+	vmpub := ctx.PublishRequest
+
+	if !controllerutil.ContainsFinalizer(vmpub, finalizerName) {
+		// Return here so the VirtualMachinePublishRequest can be patched immediately. This ensures that the
+		// power state of the source VirtualMachine is restored when the request is deleted while publishing.
+		controllerutil.AddFinalizer(vmpub, finalizerName)
+		return nil
+	}
+
+	if conditions.GetReason(vmpub, vmopapi.VirtualMachinePublishRequestUploadedCondition) ==
+		vmopapi.VirtualMachinePublishRequestPublishFailedReason {
+		// A failed publish is not retried, so that the VirtualMachine is not powered off again for an error
+		// that is likely to persist. Only the power state is restored if that failed before.
+		return r.restoreFailedPublishPowerState(ctx)
+	}
+
+	if !conditions.IsTrue(vmpub, vmopapi.VirtualMachinePublishRequestUploadedCondition) {
+		if err := r.publishVM(ctx); err != nil || !conditions.IsTrue(vmpub, vmopapi.VirtualMachinePublishRequestUploadedCondition) {
+			markNotReady(vmpub)
+			return err
+		}
+	}
+
+	return r.updateImageStatus(ctx)
+}
+
+// markNotReady sets the Ready condition of a request whose VirtualMachine was not published from the Uploaded
+// condition.
+func markNotReady(vmpub *vmopapi.VirtualMachinePublishRequest) {
+	c := conditions.Get(vmpub, vmopapi.VirtualMachinePublishRequestUploadedCondition)
+	if c == nil {
+		return
+	}
+	conditions.MarkFalse(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition, c.Reason, c.Severity, "%s", c.Message)
+}
+
+// getVM gets the source VirtualMachine of the request, and sets it in the context.
+func (r *Reconciler) getVM(ctx *context.VirtualMachinePublishRequestContext) error {
+	vm := &vmopv1alpha1.VirtualMachine{}
+	key := client.ObjectKey{Namespace: ctx.PublishRequest.Namespace, Name: ctx.PublishRequest.Spec.Source.Name}
+	if err := r.Get(ctx, key, vm); err != nil {
+		return err
+	}
+
+	ctx.VM = vm
+	return nil
+}
+
+// publishVM captures the source VirtualMachine as a library item in the target content library. With the PowerOff
+// method, the VirtualMachine is first powered off, and its power state is restored once it is captured.
+func (r *Reconciler) publishVM(ctx *context.VirtualMachinePublishRequestContext) error {
+	vmpub := ctx.PublishRequest
+
+	if err := r.getVM(ctx); err != nil {
+		if apierrors.IsNotFound(err) {
+			conditions.MarkFalse(vmpub, vmopapi.VirtualMachinePublishRequestUploadedCondition,
+				vmopapi.VirtualMachinePublishRequestSourceNotFoundReason, vmopv1alpha1.ConditionSeverityError,
+				"VirtualMachine %s not found", vmpub.Spec.Source.Name)
+		}
+		return err
+	}
+
+	clUUID, cs, err := r.getTargetContentLibrary(ctx)
+	if err != nil {
+		conditions.MarkFalse(vmpub, vmopapi.VirtualMachinePublishRequestUploadedCondition,
+			vmopapi.VirtualMachinePublishRequestContentLibraryNotFoundReason, vmopv1alpha1.ConditionSeverityError, "%v", err)
+		return err
+	}
+
+	fromSnapshot := vmpub.Spec.Method == vmopapi.VirtualMachinePublishSnapshot
+	if !fromSnapshot {
+		poweredOff, err := r.powerOffVM(ctx)
+		if err != nil || !poweredOff {
+			return err
+		}
+	}
+
+	args := vmprovider.VMPublishArgs{
+		LibraryUUID:  clUUID,
+		ItemName:     vmpub.Spec.Target.ItemName,
+		Description:  vmpub.Spec.Target.ItemDescription,
+		FromSnapshot: fromSnapshot,
+	}
+	if args.ItemName == "" {
+		args.ItemName = vmpub.Name
+	}
+	if args.Description == "" {
+		// The description identifies the item of the request, so that an item that was created before the
+		// request was last updated is not published again.
+		args.Description = itemDescription(vmpub)
+	}
+
+	itemID, err := r.VMProvider.PublishVirtualMachine(ctx, ctx.VM, args)
+	if err != nil {
+		conditions.MarkFalse(vmpub, vmopapi.VirtualMachinePublishRequestUploadedCondition,
+			vmopapi.VirtualMachinePublishRequestPublishFailedReason, vmopv1alpha1.ConditionSeverityError, "%v", err)
+		// The publish is not retried, so do not leave the VirtualMachine powered off.
+		if restoreErr := r.restorePowerState(ctx); restoreErr != nil {
+			ctx.Logger.Error(restoreErr, "failed to restore power state after failed publish")
+		}
+		return errors.Wrapf(err, "failed to publish VM %s", ctx.VM.NamespacedName())
+	}
+
+	ctx.Logger.Info("Published VM to content library", "libraryUUID", clUUID, "itemID", itemID)
+	now := metav1.Now()
+	vmpub.Status.LibraryUUID = clUUID
+	vmpub.Status.LibraryItemID = itemID
+	vmpub.Status.ImageName = ""
+	vmpub.Status.CompletionTime = &now
+	conditions.MarkTrue(vmpub, vmopapi.VirtualMachinePublishRequestUploadedCondition)
+	r.Recorder.EmitEvent(vmpub, "Publish", nil, false)
+
+	if err := r.restorePowerState(ctx); err != nil {
+		return err
+	}
+
+	// Trigger a sync of the ContentSource so the VirtualMachineImage of the item is created without waiting
+	// for the next resync.
+	if cs.Annotations == nil {
+		cs.Annotations = map[string]string{}
+	}
+	cs.Annotations[PublishedItemAnnotationKey] = vmpub.NamespacedName()
+	if err := r.Update(ctx, cs); err != nil {
+		ctx.Logger.Error(err, "failed to trigger sync of ContentSource", "contentSourceName", cs.Name)
+	}
+
+	return nil
+}
+
+// itemDescription returns the description of the library item of a request that does not set one.
+func itemDescription(vmpub *vmopapi.VirtualMachinePublishRequest) string {
+	return fmt.Sprintf("Published by VirtualMachinePublishRequest %s", vmpub.NamespacedName())
+}
+
+// powerOffVM powers off the source VirtualMachine, and records its power state so that it is restored once the
+// VirtualMachine is published. It returns true once the VirtualMachine is powered off.
+func (r *Reconciler) powerOffVM(ctx *context.VirtualMachinePublishRequestContext) (bool, error) {
+	vmpub, vm := ctx.PublishRequest, ctx.VM
+
+	if vm.Spec.PowerState != vmopv1alpha1.VirtualMachinePoweredOff {
+		if vmpub.Status.SourcePowerState == "" {
+			vmpub.Status.SourcePowerState = vm.Spec.PowerState
+		}
+
+		ctx.Logger.Info("Powering off VM to publish it")
+		vmPatch := client.MergeFrom(vm.DeepCopy())
+		vm.Spec.PowerState = vmopv1alpha1.VirtualMachinePoweredOff
+		if err := r.Patch(ctx, vm, vmPatch); err != nil {
+			return false, errors.Wrapf(err, "failed to power off VM %s", vm.NamespacedName())
+		}
+	}
+
+	if vm.Status.PowerState != vmopv1alpha1.VirtualMachinePoweredOff {
+		conditions.MarkFalse(vmpub, vmopapi.VirtualMachinePublishRequestUploadedCondition,
+			vmopapi.VirtualMachinePublishRequestSourcePoweringOffReason, vmopv1alpha1.ConditionSeverityInfo,
+			"Waiting for VirtualMachine %s to power off", vm.Name)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// restorePowerState restores the power state of the source VirtualMachine from before it was powered off to be
+// published.
+func (r *Reconciler) restorePowerState(ctx *context.VirtualMachinePublishRequestContext) error {
+	vmpub, vm := ctx.PublishRequest, ctx.VM
+
+	if vmpub.Status.SourcePowerState == "" {
+		return nil
+	}
+
+	if vm.Spec.PowerState != vmpub.Status.SourcePowerState {
+		ctx.Logger.Info("Restoring power state of published VM", "powerState", vmpub.Status.SourcePowerState)
+		vmPatch := client.MergeFrom(vm.DeepCopy())
+		vm.Spec.PowerState = vmpub.Status.SourcePowerState
+		if err := r.Patch(ctx, vm, vmPatch); err != nil {
+			return errors.Wrapf(err, "failed to restore power state of VM %s", vm.NamespacedName())
+		}
+	}
+
+	vmpub.Status.SourcePowerState = ""
+	return nil
+}
+
+// restoreFailedPublishPowerState restores the power state of the source VirtualMachine of a failed publish, when
+// it was not restored when the publish failed.
+func (r *Reconciler) restoreFailedPublishPowerState(ctx *context.VirtualMachinePublishRequestContext) error {
+	if ctx.PublishRequest.Status.SourcePowerState == "" {
+		return nil
+	}
+
+	if err := r.getVM(ctx); err != nil {
+		if apierrors.IsNotFound(err) {
+			ctx.PublishRequest.Status.SourcePowerState = ""
+			return nil
+		}
+		return err
+	}
+
+	return r.restorePowerState(ctx)
+}
+
+// getTargetContentLibrary returns the UUID and ContentSource of the content library that the VirtualMachine is
+// published to. The ContentSource of the target must only be bound to the namespace, and must not be a
+// subscribed library.
+func (r *Reconciler) getTargetContentLibrary(
+	ctx *context.VirtualMachinePublishRequestContext) (string, *vmopv1alpha1.ContentSource, error) {
+
+	vmpub := ctx.PublishRequest
+	return virtualmachineimageimport.GetContentLibrary(ctx, r.Client, vmpub.Namespace, vmpub.Spec.Target.ContentSourceName)
+}
+
+// updateImageStatus updates the status with the VirtualMachineImage of the published item, once the
+// ContentSource of the content library has been synced.
+func (r *Reconciler) updateImageStatus(ctx *context.VirtualMachinePublishRequestContext) error {
+	vmpub := ctx.PublishRequest
+
+	images := &vmopv1alpha1.VirtualMachineImageList{}
+	if err := r.List(ctx, images); err != nil {
+		return errors.Wrap(err, "failed to list VirtualMachineImages")
+	}
+
+	for _, image := range images.Items {
+		if image.Spec.ImageID == vmpub.Status.LibraryItemID {
+			vmpub.Status.ImageName = image.Name
+			conditions.MarkTrue(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)
+			return nil
+		}
+	}
+
+	conditions.MarkFalse(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition,
+		vmopapi.VirtualMachinePublishRequestImagePendingReason, vmopv1alpha1.ConditionSeverityInfo,
+		"Waiting for the VirtualMachineImage of library item %s", vmpub.Status.LibraryItemID)
+	return nil
+}
+
+// ReconcileDelete restores the power state of the source VirtualMachine when the request is deleted before the
+// VirtualMachine was published. The published item is not deleted along with the request.
+func (r *Reconciler) ReconcileDelete(ctx *context.VirtualMachinePublishRequestContext) error {
+	vmpub := ctx.PublishRequest
+
+	if !controllerutil.ContainsFinalizer(vmpub, finalizerName) {
+		return nil
+	}
+
+	if vmpub.Status.SourcePowerState != "" {
+		if err := r.getVM(ctx); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+		} else if err := r.restorePowerState(ctx); err != nil {
+			return err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(vmpub, finalizerName)
+	return nil
+}
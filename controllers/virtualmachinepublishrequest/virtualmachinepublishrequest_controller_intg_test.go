@@ -0,0 +1,134 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinepublishrequest_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachinepublishrequest"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func intgTests() {
+	const (
+		clUUID = "dummy-intg-cl-uuid"
+	)
+
+	var (
+		ctx *builder.IntegrationTestContext
+
+		vm    *vmopv1alpha1.VirtualMachine
+		vmpub *vmopapi.VirtualMachinePublishRequest
+	)
+
+	BeforeEach(func() {
+		ctx = suite.NewIntegrationTestContext()
+
+		vm = &vmopv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-vm",
+				Namespace: ctx.Namespace,
+			},
+			Spec: vmopv1alpha1.VirtualMachineSpec{
+				ImageName:  "dummy-image",
+				ClassName:  "dummy-class",
+				PowerState: vmopv1alpha1.VirtualMachinePoweredOn,
+			},
+		}
+		Expect(ctx.Client.Create(ctx, vm)).To(Succeed())
+
+		vmpub = &vmopapi.VirtualMachinePublishRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-publish",
+				Namespace: ctx.Namespace,
+			},
+			Spec: vmopapi.VirtualMachinePublishRequestSpec{
+				Source: vmopapi.VirtualMachinePublishRequestSource{Name: vm.Name},
+				Target: vmopapi.VirtualMachinePublishRequestTarget{ItemName: "dummy-intg-image"},
+				Method: vmopapi.VirtualMachinePublishSnapshot,
+			},
+		}
+
+		clProvider := &vmopv1alpha1.ContentLibraryProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: clUUID},
+			Spec:       vmopv1alpha1.ContentLibraryProviderSpec{UUID: clUUID},
+		}
+		Expect(ctx.Client.Create(ctx, clProvider)).To(Succeed())
+
+		cs := &vmopv1alpha1.ContentSource{
+			ObjectMeta: metav1.ObjectMeta{Name: clUUID},
+			Spec: vmopv1alpha1.ContentSourceSpec{
+				ProviderRef: vmopv1alpha1.ContentProviderReference{Name: clUUID, Kind: "ContentLibraryProvider"},
+			},
+		}
+		Expect(ctx.Client.Create(ctx, cs)).To(Succeed())
+
+		binding := &vmopv1alpha1.ContentSourceBinding{
+			ObjectMeta:       metav1.ObjectMeta{Name: clUUID, Namespace: ctx.Namespace},
+			ContentSourceRef: vmopv1alpha1.ContentSourceReference{Name: clUUID, Kind: "ContentSource"},
+		}
+		Expect(ctx.Client.Create(ctx, binding)).To(Succeed())
+
+		// The fake provider returns the library UUID and item name as the ID of the item.
+		image := &vmopv1alpha1.VirtualMachineImage{
+			ObjectMeta: metav1.ObjectMeta{Name: "dummy-intg-image"},
+			Spec:       vmopv1alpha1.VirtualMachineImageSpec{ImageID: clUUID + "-dummy-intg-image"},
+		}
+		Expect(ctx.Client.Create(ctx, image)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		for _, obj := range []client.Object{
+			&vmopv1alpha1.VirtualMachineImage{ObjectMeta: metav1.ObjectMeta{Name: "dummy-intg-image"}},
+			&vmopv1alpha1.ContentSource{ObjectMeta: metav1.ObjectMeta{Name: clUUID}},
+			&vmopv1alpha1.ContentLibraryProvider{ObjectMeta: metav1.ObjectMeta{Name: clUUID}},
+		} {
+			Expect(client.IgnoreNotFound(ctx.Client.Delete(ctx, obj))).To(Succeed())
+		}
+		ctx.AfterEach()
+		ctx = nil
+		intgFakeVMProvider.Reset()
+	})
+
+	Context("Reconcile", func() {
+		It("Reconciles after VirtualMachinePublishRequest creation", func() {
+			Expect(ctx.Client.Create(ctx, vmpub)).To(Succeed())
+			vmpubKey := client.ObjectKeyFromObject(vmpub)
+
+			By("VirtualMachinePublishRequest should be ready", func() {
+				Eventually(func() bool {
+					vmpub := &vmopapi.VirtualMachinePublishRequest{}
+					if err := ctx.Client.Get(ctx, vmpubKey, vmpub); err != nil {
+						return false
+					}
+					return conditions.IsTrue(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)
+				}).Should(BeTrue())
+
+				Expect(ctx.Client.Get(ctx, vmpubKey, vmpub)).To(Succeed())
+				Expect(vmpub.Status.ImageName).To(Equal("dummy-intg-image"))
+				Expect(vmpub.Status.LibraryUUID).To(Equal(clUUID))
+				Expect(vmpub.Status.CompletionTime).ToNot(BeNil())
+			})
+
+			By("ContentSource should be annotated to trigger a sync", func() {
+				cs := &vmopv1alpha1.ContentSource{}
+				Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: clUUID}, cs)).To(Succeed())
+				Expect(cs.Annotations).To(HaveKey(virtualmachinepublishrequest.PublishedItemAnnotationKey))
+			})
+
+			By("VirtualMachine should be left powered on", func() {
+				Expect(ctx.Client.Get(ctx, client.ObjectKeyFromObject(vm), vm)).To(Succeed())
+				Expect(vm.Spec.PowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOn))
+			})
+		})
+	})
+}
@@ -0,0 +1,373 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinepublishrequest_test
+
+import (
+	goctx "context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/subscribedcontentlibrary"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachinepublishrequest"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+	providerfake "github.com/acharyasreej/vm-operator/pkg/vmprovider/fake"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func unitTests() {
+	Describe("Invoking Reconcile", unitTestsReconcile)
+}
+
+func unitTestsReconcile() {
+	const (
+		clUUID    = "dummy-cl-uuid"
+		namespace = "dummy-ns"
+	)
+
+	var (
+		initObjects    []client.Object
+		ctx            *builder.UnitTestContextForController
+		reconciler     *virtualmachinepublishrequest.Reconciler
+		fakeVMProvider *providerfake.VMProvider
+
+		pubCtx *context.VirtualMachinePublishRequestContext
+		vmpub  *vmopapi.VirtualMachinePublishRequest
+		vm     *vmopv1alpha1.VirtualMachine
+		cs     *vmopv1alpha1.ContentSource
+
+		publishArgs *vmprovider.VMPublishArgs
+	)
+
+	BeforeEach(func() {
+		vm = &vmopv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-vm",
+				Namespace: namespace,
+			},
+			Spec: vmopv1alpha1.VirtualMachineSpec{
+				ImageName:  "dummy-image",
+				ClassName:  "dummy-class",
+				PowerState: vmopv1alpha1.VirtualMachinePoweredOn,
+			},
+			Status: vmopv1alpha1.VirtualMachineStatus{
+				PowerState: vmopv1alpha1.VirtualMachinePoweredOn,
+			},
+		}
+
+		vmpub = &vmopapi.VirtualMachinePublishRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "dummy-publish",
+				Namespace:  namespace,
+				Finalizers: []string{"virtualmachinepublishrequest.vmoperator.vmware.com"},
+			},
+			Spec: vmopapi.VirtualMachinePublishRequestSpec{
+				Source: vmopapi.VirtualMachinePublishRequestSource{Name: vm.Name},
+				Target: vmopapi.VirtualMachinePublishRequestTarget{
+					ItemName:        "dummy-image-v2",
+					ItemDescription: "dummy description",
+				},
+			},
+		}
+
+		var clProvider *vmopv1alpha1.ContentLibraryProvider
+		var binding *vmopv1alpha1.ContentSourceBinding
+		clProvider, cs, binding = builder.DummyContentSource(clUUID, namespace)
+		initObjects = append(initObjects, vm, vmpub, clProvider, cs, binding)
+	})
+
+	JustBeforeEach(func() {
+		ctx = suite.NewUnitTestContextForController(initObjects...)
+		reconciler = virtualmachinepublishrequest.NewReconciler(
+			ctx.Client,
+			ctx.Logger,
+			ctx.Recorder,
+			ctx.VMProvider,
+		)
+		fakeVMProvider = ctx.VMProvider.(*providerfake.VMProvider)
+		fakeVMProvider.PublishVirtualMachineFn = func(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine, args vmprovider.VMPublishArgs) (string, error) {
+			publishArgs = &args
+			return "dummy-item-id", nil
+		}
+
+		pubCtx = &context.VirtualMachinePublishRequestContext{
+			Context:        ctx,
+			Logger:         ctx.Logger.WithName(vmpub.Name),
+			PublishRequest: vmpub,
+		}
+	})
+
+	AfterEach(func() {
+		ctx.AfterEach()
+		ctx = nil
+		initObjects = nil
+		pubCtx = nil
+		reconciler = nil
+		fakeVMProvider = nil
+		publishArgs = nil
+	})
+
+	getVM := func() *vmopv1alpha1.VirtualMachine {
+		vm := &vmopv1alpha1.VirtualMachine{}
+		Expect(ctx.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "dummy-vm"}, vm)).To(Succeed())
+		return vm
+	}
+
+	setVMPowerState := func(powerState vmopv1alpha1.VirtualMachinePowerState) {
+		vm := getVM()
+		vm.Status.PowerState = powerState
+		Expect(ctx.Client.Status().Update(ctx, vm)).To(Succeed())
+	}
+
+	expectPublished := func() {
+		Expect(conditions.IsTrue(vmpub, vmopapi.VirtualMachinePublishRequestUploadedCondition)).To(BeTrue())
+		Expect(publishArgs).ToNot(BeNil())
+		Expect(publishArgs.LibraryUUID).To(Equal(clUUID))
+		Expect(publishArgs.ItemName).To(Equal("dummy-image-v2"))
+		Expect(publishArgs.Description).To(Equal("dummy description"))
+		Expect(vmpub.Status.LibraryUUID).To(Equal(clUUID))
+		Expect(vmpub.Status.LibraryItemID).To(Equal("dummy-item-id"))
+		Expect(vmpub.Status.CompletionTime).ToNot(BeNil())
+	}
+
+	Context("ReconcileNormal", func() {
+		When("the request does not have the finalizer", func() {
+			BeforeEach(func() {
+				vmpub.Finalizers = nil
+			})
+
+			It("will add the finalizer", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).To(Succeed())
+				Expect(controllerutil.ContainsFinalizer(vmpub, "virtualmachinepublishrequest.vmoperator.vmware.com")).To(BeTrue())
+				Expect(publishArgs).To(BeNil())
+			})
+		})
+
+		It("will power off the VM, publish it, and restore its power state", func() {
+			Expect(reconciler.ReconcileNormal(pubCtx)).To(Succeed())
+			Expect(publishArgs).To(BeNil())
+			Expect(conditions.GetReason(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)).To(
+				Equal(vmopapi.VirtualMachinePublishRequestSourcePoweringOffReason))
+			Expect(vmpub.Status.SourcePowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOn))
+			Expect(getVM().Spec.PowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOff))
+
+			By("VM is powered off", func() {
+				setVMPowerState(vmopv1alpha1.VirtualMachinePoweredOff)
+			})
+
+			Expect(reconciler.ReconcileNormal(pubCtx)).To(Succeed())
+			expectPublished()
+			Expect(publishArgs.FromSnapshot).To(BeFalse())
+			Expect(vmpub.Status.SourcePowerState).To(BeEmpty())
+			Expect(getVM().Spec.PowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOn))
+			Expect(conditions.GetReason(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)).To(
+				Equal(vmopapi.VirtualMachinePublishRequestImagePendingReason))
+
+			updatedCS := &vmopv1alpha1.ContentSource{}
+			Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: cs.Name}, updatedCS)).To(Succeed())
+			Expect(updatedCS.Annotations).To(HaveKeyWithValue(virtualmachinepublishrequest.PublishedItemAnnotationKey, "dummy-ns/dummy-publish"))
+
+			By("VirtualMachineImage of the item is created", func() {
+				image := &vmopv1alpha1.VirtualMachineImage{
+					ObjectMeta: metav1.ObjectMeta{Name: "dummy-image-v2"},
+					Spec:       vmopv1alpha1.VirtualMachineImageSpec{ImageID: "dummy-item-id"},
+				}
+				Expect(ctx.Client.Create(ctx, image)).To(Succeed())
+			})
+
+			Expect(reconciler.ReconcileNormal(pubCtx)).To(Succeed())
+			Expect(vmpub.Status.ImageName).To(Equal("dummy-image-v2"))
+			Expect(conditions.IsTrue(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)).To(BeTrue())
+		})
+
+		When("the method is Snapshot", func() {
+			BeforeEach(func() {
+				vmpub.Spec.Method = vmopapi.VirtualMachinePublishSnapshot
+			})
+
+			It("will publish the VM without powering it off", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).To(Succeed())
+				expectPublished()
+				Expect(publishArgs.FromSnapshot).To(BeTrue())
+				Expect(vmpub.Status.SourcePowerState).To(BeEmpty())
+				Expect(getVM().Spec.PowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOn))
+			})
+		})
+
+		When("the item name is not set", func() {
+			BeforeEach(func() {
+				vmpub.Spec.Method = vmopapi.VirtualMachinePublishSnapshot
+				vmpub.Spec.Target.ItemName = ""
+			})
+
+			It("will name the item after the request", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).To(Succeed())
+				Expect(publishArgs).ToNot(BeNil())
+				Expect(publishArgs.ItemName).To(Equal("dummy-publish"))
+			})
+		})
+
+		When("the item description is not set", func() {
+			BeforeEach(func() {
+				vmpub.Spec.Method = vmopapi.VirtualMachinePublishSnapshot
+				vmpub.Spec.Target.ItemDescription = ""
+			})
+
+			It("will describe the item with the request", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).To(Succeed())
+				Expect(publishArgs).ToNot(BeNil())
+				Expect(publishArgs.Description).To(Equal("Published by VirtualMachinePublishRequest dummy-ns/dummy-publish"))
+			})
+		})
+
+		When("the VM does not exist", func() {
+			BeforeEach(func() {
+				vmpub.Spec.Source.Name = "does-not-exist"
+			})
+
+			It("will mark the request as failed", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).ToNot(Succeed())
+				Expect(conditions.GetReason(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)).To(
+					Equal(vmopapi.VirtualMachinePublishRequestSourceNotFoundReason))
+				Expect(publishArgs).To(BeNil())
+			})
+		})
+
+		When("the target ContentSource is not bound to the namespace", func() {
+			BeforeEach(func() {
+				vmpub.Spec.Target.ContentSourceName = "other-cl-uuid"
+				clProvider, cs, _ := builder.DummyContentSource("other-cl-uuid", namespace)
+				initObjects = append(initObjects, clProvider, cs)
+			})
+
+			It("will mark the request as failed", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).ToNot(Succeed())
+				Expect(conditions.GetReason(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)).To(
+					Equal(vmopapi.VirtualMachinePublishRequestContentLibraryNotFoundReason))
+				Expect(publishArgs).To(BeNil())
+			})
+		})
+
+		When("the target ContentSource is a subscribed library", func() {
+			BeforeEach(func() {
+				vmpub.Spec.Target.ContentSourceName = clUUID
+				cs.Labels = map[string]string{subscribedcontentlibrary.SubscribedContentLibraryLabelKey: "dummy"}
+			})
+
+			It("will mark the request as failed", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).ToNot(Succeed())
+				Expect(conditions.GetReason(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)).To(
+					Equal(vmopapi.VirtualMachinePublishRequestContentLibraryNotFoundReason))
+			})
+		})
+
+		When("the target ContentSource is shared with other namespaces", func() {
+			BeforeEach(func() {
+				vmpub.Spec.Target.ContentSourceName = clUUID
+				_, _, otherBinding := builder.DummyContentSource(clUUID, "other-ns")
+				initObjects = append(initObjects, otherBinding)
+			})
+
+			It("will mark the request as failed", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).ToNot(Succeed())
+				Expect(conditions.GetReason(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)).To(
+					Equal(vmopapi.VirtualMachinePublishRequestContentLibraryNotFoundReason))
+				Expect(publishArgs).To(BeNil())
+			})
+		})
+
+		When("publishing the powered off VM fails", func() {
+			BeforeEach(func() {
+				vm.Spec.PowerState = vmopv1alpha1.VirtualMachinePoweredOff
+				vm.Status.PowerState = vmopv1alpha1.VirtualMachinePoweredOff
+				vmpub.Status.SourcePowerState = vmopv1alpha1.VirtualMachinePoweredOn
+			})
+
+			JustBeforeEach(func() {
+				fakeVMProvider.PublishVirtualMachineFn = func(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine, _ vmprovider.VMPublishArgs) (string, error) {
+					return "", errors.New("fake publish error")
+				}
+			})
+
+			It("will restore the power state of the VM", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).ToNot(Succeed())
+				Expect(conditions.GetReason(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)).To(
+					Equal(vmopapi.VirtualMachinePublishRequestPublishFailedReason))
+				Expect(vmpub.Status.SourcePowerState).To(BeEmpty())
+				Expect(getVM().Spec.PowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOn))
+			})
+
+			It("will not power off the VM again", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).ToNot(Succeed())
+				Expect(getVM().Spec.PowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOn))
+
+				Expect(reconciler.ReconcileNormal(pubCtx)).To(Succeed())
+				Expect(conditions.GetReason(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)).To(
+					Equal(vmopapi.VirtualMachinePublishRequestPublishFailedReason))
+				Expect(vmpub.Status.SourcePowerState).To(BeEmpty())
+				Expect(getVM().Spec.PowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOn))
+			})
+		})
+
+		When("publishing fails", func() {
+			BeforeEach(func() {
+				vmpub.Spec.Method = vmopapi.VirtualMachinePublishSnapshot
+			})
+
+			JustBeforeEach(func() {
+				fakeVMProvider.PublishVirtualMachineFn = func(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine, _ vmprovider.VMPublishArgs) (string, error) {
+					return "", errors.New("fake publish error")
+				}
+			})
+
+			It("will mark the request as failed", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).ToNot(Succeed())
+				c := conditions.Get(vmpub, vmopapi.VirtualMachinePublishRequestReadyCondition)
+				Expect(c).ToNot(BeNil())
+				Expect(c.Reason).To(Equal(vmopapi.VirtualMachinePublishRequestPublishFailedReason))
+				Expect(c.Message).To(ContainSubstring("fake publish error"))
+			})
+
+			It("will not retry the publish", func() {
+				Expect(reconciler.ReconcileNormal(pubCtx)).ToNot(Succeed())
+				fakeVMProvider.PublishVirtualMachineFn = func(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine, _ vmprovider.VMPublishArgs) (string, error) {
+					Fail("publish was retried")
+					return "", nil
+				}
+				Expect(reconciler.ReconcileNormal(pubCtx)).To(Succeed())
+			})
+		})
+	})
+
+	Context("ReconcileDelete", func() {
+		When("the VM was powered off to be published", func() {
+			BeforeEach(func() {
+				vm.Spec.PowerState = vmopv1alpha1.VirtualMachinePoweredOff
+				vmpub.Status.SourcePowerState = vmopv1alpha1.VirtualMachinePoweredOn
+			})
+
+			It("will restore the power state of the VM", func() {
+				Expect(reconciler.ReconcileDelete(pubCtx)).To(Succeed())
+				Expect(getVM().Spec.PowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOn))
+				Expect(vmpub.Finalizers).To(BeEmpty())
+			})
+		})
+
+		It("will remove the finalizer", func() {
+			Expect(reconciler.ReconcileDelete(pubCtx)).To(Succeed())
+			Expect(vmpub.Finalizers).To(BeEmpty())
+			Expect(getVM().Spec.PowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOn))
+		})
+	})
+}
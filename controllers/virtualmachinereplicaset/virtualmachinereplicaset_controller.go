@@ -0,0 +1,438 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinereplicaset
+
+import (
+	goctx "context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/indexer"
+	"github.com/acharyasreej/vm-operator/pkg/patch"
+	"github.com/acharyasreej/vm-operator/pkg/record"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/config"
+)
+
+const (
+	// Reasons of the events emitted by the controller.
+	createdVMReason           = "CreatedVM"
+	createVMFailedReason      = "CreateVMFailed"
+	deletedVMReason           = "DeletedVM"
+	deleteVMFailedReason      = "DeleteVMFailed"
+	invalidSelectorReason     = "InvalidSelector"
+	rollingUpdatePausedReason = "RollingUpdatePaused"
+)
+
+// AddToManager adds this package's controller to the provided manager.
+func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controlledType     = &vmopapi.VirtualMachineReplicaSet{}
+		controlledTypeName = reflect.TypeOf(controlledType).Elem().Name()
+
+		controllerNameShort = fmt.Sprintf("%s-controller", strings.ToLower(controlledTypeName))
+		controllerNameLong  = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
+	)
+
+	r := NewReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName(controlledTypeName),
+		record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		ctx.VMProvider,
+	)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(controlledType).
+		Owns(&vmopv1alpha1.VirtualMachine{}).
+		Watches(&source.Kind{Type: &vmopv1alpha1.VirtualMachineImage{}},
+			handler.EnqueueRequestsFromMapFunc(imageToReplicaSetMapperFn(ctx, mgr.GetClient()))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// imageToReplicaSetMapperFn returns a mapper function that can be used to queue reconcile requests for
+// the VirtualMachineReplicaSets whose template uses the VirtualMachineImage, so that their VirtualMachines
+// are replaced when the content library item of the image is updated.
+func imageToReplicaSetMapperFn(ctx *context.ControllerManagerContext, c client.Reader) func(o client.Object) []reconcile.Request {
+	return func(o client.Object) []reconcile.Request {
+		image := o.(*vmopv1alpha1.VirtualMachineImage)
+		logger := ctx.Logger.WithValues("name", image.Name)
+
+		rsList := &vmopapi.VirtualMachineReplicaSetList{}
+		if err := c.List(ctx, rsList, client.MatchingFields{indexer.VirtualMachineReplicaSetImageNameField: image.Name}); err != nil {
+			logger.Error(err, "Failed to list VirtualMachineReplicaSets for reconciliation due to VirtualMachineImage watch")
+			return nil
+		}
+
+		var reconcileRequests []reconcile.Request
+		for _, rs := range rsList.Items {
+			// Check the image name again since the field selector is not applied by every client.
+			if rs.Spec.Template.Spec.ImageName == image.Name {
+				key := client.ObjectKey{Namespace: rs.Namespace, Name: rs.Name}
+				reconcileRequests = append(reconcileRequests, reconcile.Request{NamespacedName: key})
+			}
+		}
+
+		logger.V(4).Info("Returning VirtualMachineReplicaSet reconcile requests due to VirtualMachineImage watch", "requests", reconcileRequests)
+		return reconcileRequests
+	}
+}
+
+func NewReconciler(
+	client client.Client,
+	logger logr.Logger,
+	recorder record.Recorder,
+	vmProvider vmprovider.VirtualMachineProviderInterface) *Reconciler {
+	return &Reconciler{
+		Client:       client,
+		Logger:       logger,
+		Recorder:     recorder,
+		VMProvider:   vmProvider,
+		expectations: newExpectations(),
+	}
+}
+
+// Reconciler reconciles a VirtualMachineReplicaSet object.
+type Reconciler struct {
+	client.Client
+	Logger     logr.Logger
+	Recorder   record.Recorder
+	VMProvider vmprovider.VirtualMachineProviderInterface
+
+	expectations *expectations
+}
+
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachinereplicasets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachinereplicasets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachines,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachinesetresourcepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachineimages,verbs=get;list;watch
+
+func (r *Reconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	rs := &vmopapi.VirtualMachineReplicaSet{}
+	if err := r.Get(ctx, req.NamespacedName, rs); err != nil {
+		if apiErrors.IsNotFound(err) {
+			r.expectations.Forget(req.NamespacedName)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	rsCtx := &context.VirtualMachineReplicaSetContext{
+		Context:    ctx,
+		Logger:     r.Logger.WithName("VirtualMachineReplicaSet").WithValues("name", rs.NamespacedName()),
+		ReplicaSet: rs,
+	}
+
+	patchHelper, err := patch.NewHelper(rs, r.Client)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to init patch helper for %s", rsCtx.String())
+	}
+	defer func() {
+		if err := patchHelper.Patch(ctx, rs); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			rsCtx.Logger.Error(err, "patch failed")
+		}
+	}()
+
+	if !rs.DeletionTimestamp.IsZero() {
+		// The VirtualMachines are garbage collected through their controller reference.
+		r.expectations.Forget(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.ReconcileNormal(rsCtx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	delay := requeueDelay(rsCtx)
+
+	// The replica set is reconciled again when the VirtualMachines it created or deleted are observed, and
+	// otherwise once its expectations expire.
+	if r.expectations.Pending(req.NamespacedName) && (delay == 0 || delay > expectationsTimeout) {
+		delay = expectationsTimeout
+	}
+
+	return ctrl.Result{RequeueAfter: delay}, nil
+}
+
+// requeueDelay returns the delay after which a replica set is reconciled again while a rolling update is in
+// progress, so that a replacement that does not become ready in time is noticed even when nothing changes.
+func requeueDelay(ctx *context.VirtualMachineReplicaSetContext) time.Duration {
+	if c := conditions.Get(ctx.ReplicaSet, vmopapi.VirtualMachineReplicaSetRolledOutCondition); c != nil &&
+		c.Reason == vmopapi.VirtualMachineReplicaSetRollingUpdateReason {
+		return 30 * time.Second
+	}
+
+	return 0
+}
+
+// ReconcileNormal creates or deletes VirtualMachines until the replica set has the desired number of
+// VirtualMachines, replaces the VirtualMachines of previous revisions of the template, and updates the
+// status.
+func (r *Reconciler) ReconcileNormal(ctx *context.VirtualMachineReplicaSetContext) error {
+	rs := ctx.ReplicaSet
+
+	ctx.Logger.Info("Reconciling VirtualMachineReplicaSet")
+	defer func() {
+		ctx.Logger.Info("Finished Reconciling VirtualMachineReplicaSet")
+	}()
+
+	selector, err := metav1.LabelSelectorAsSelector(rs.Spec.Selector)
+	if err != nil {
+		r.Recorder.Warnf(rs, invalidSelectorReason, "Invalid selector: %v", err)
+		return nil
+	}
+
+	// An empty selector would match all VirtualMachines in the namespace, and a selector that does not
+	// match the template would never find the VirtualMachines that are created. Neither can be fixed by
+	// retrying, so wait for the replica set to be updated.
+	if selector.Empty() || !selector.Matches(labels.Set(rs.Spec.Template.ObjectMeta.Labels)) {
+		r.Recorder.Warnf(rs, invalidSelectorReason, "Selector %q does not match the template labels", selector.String())
+		return nil
+	}
+
+	revision, err := r.getRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	vms, err := r.getVMs(ctx, selector)
+	if err != nil {
+		return err
+	}
+
+	var newVMs, oldVMs []vmopv1alpha1.VirtualMachine
+	for i := range vms {
+		if vms[i].Annotations[vmopapi.VirtualMachineReplicaSetRevisionAnnotation] == revision {
+			newVMs = append(newVMs, vms[i])
+		} else {
+			oldVMs = append(oldVMs, vms[i])
+		}
+	}
+
+	rs.Status.Selector = selector.String()
+	rs.Status.ObservedGeneration = rs.Generation
+	rs.Status.CurrentRevision = revision
+	rs.Status.Replicas = int32(len(vms))
+	rs.Status.ReadyReplicas = int32(countReadyVMs(vms))
+	rs.Status.UpdatedReplicas = int32(len(newVMs))
+
+	replicas := 1
+	if rs.Spec.Replicas != nil {
+		replicas = int(*rs.Spec.Replicas)
+	}
+
+	if !r.expectations.Satisfied(rsKey(rs), vms) {
+		ctx.Logger.V(4).Info("Waiting for the created and deleted VirtualMachines to be observed")
+		return nil
+	}
+
+	if len(oldVMs) > 0 {
+		return r.rollingUpdate(ctx, revision, replicas, newVMs, oldVMs)
+	}
+
+	conditions.MarkTrue(rs, vmopapi.VirtualMachineReplicaSetRolledOutCondition)
+
+	switch diff := replicas - len(newVMs); {
+	case diff > 0:
+		return r.createVMs(ctx, revision, diff)
+	case diff < 0:
+		return r.deleteVMs(ctx, newVMs, -diff)
+	}
+
+	return nil
+}
+
+// getVMs returns the VirtualMachines that match the selector and are controlled by the replica set,
+// excluding the VirtualMachines that are being deleted.
+func (r *Reconciler) getVMs(
+	ctx *context.VirtualMachineReplicaSetContext,
+	selector labels.Selector) ([]vmopv1alpha1.VirtualMachine, error) {
+
+	vmList := &vmopv1alpha1.VirtualMachineList{}
+	if err := r.List(ctx, vmList, client.InNamespace(ctx.ReplicaSet.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list VirtualMachines of %s", ctx.String())
+	}
+
+	vms := make([]vmopv1alpha1.VirtualMachine, 0, len(vmList.Items))
+	for i := range vmList.Items {
+		vm := &vmList.Items[i]
+		if metav1.IsControlledBy(vm, ctx.ReplicaSet) && vm.DeletionTimestamp.IsZero() {
+			vms = append(vms, *vm)
+		}
+	}
+
+	return vms, nil
+}
+
+func (r *Reconciler) createVMs(ctx *context.VirtualMachineReplicaSetContext, revision string, count int) error {
+	rs := ctx.ReplicaSet
+
+	clusterModuleAnnotations, err := r.getClusterModuleAnnotations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		vm := &vmopv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("%s-%s", rs.Name, utilrand.String(5)),
+				Namespace:   rs.Namespace,
+				Labels:      map[string]string{},
+				Annotations: map[string]string{},
+			},
+			Spec: *rs.Spec.Template.Spec.DeepCopy(),
+		}
+
+		// The zone label is only set when the template has it. Otherwise, the VM mutation webhook assigns
+		// the VirtualMachines to the availability zones in turn so the replicas are spread across them.
+		for k, v := range rs.Spec.Template.ObjectMeta.Labels {
+			vm.Labels[k] = v
+		}
+		for k, v := range rs.Spec.Template.ObjectMeta.Annotations {
+			vm.Annotations[k] = v
+		}
+		for k, v := range clusterModuleAnnotations {
+			vm.Annotations[k] = v
+		}
+		vm.Annotations[vmopapi.VirtualMachineReplicaSetRevisionAnnotation] = revision
+
+		if err := controllerutil.SetControllerReference(rs, vm, r.Scheme()); err != nil {
+			return err
+		}
+
+		if err := r.Create(ctx, vm); err != nil {
+			r.Recorder.Warnf(rs, createVMFailedReason, "Failed to create VirtualMachine %s: %v", vm.Name, err)
+			return errors.Wrapf(err, "failed to create VirtualMachine %s", vm.Name)
+		}
+		r.expectations.ExpectCreate(rsKey(rs), vm.Name)
+
+		ctx.Logger.Info("Created VirtualMachine", "vmName", vm.Name)
+		r.Recorder.Eventf(rs, createdVMReason, "Created VirtualMachine %s", vm.Name)
+	}
+
+	return nil
+}
+
+// getClusterModuleAnnotations returns the annotations that place the VirtualMachines in the first cluster
+// module of the template's resource policy, so the vSphere VMs are kept on different hosts. No annotations
+// are returned when the template does not have a resource policy with cluster modules, or when the
+// template already has the cluster module annotations.
+func (r *Reconciler) getClusterModuleAnnotations(ctx *context.VirtualMachineReplicaSetContext) (map[string]string, error) {
+	template := &ctx.ReplicaSet.Spec.Template
+
+	policyName := template.Spec.ResourcePolicyName
+	if policyName == "" || template.ObjectMeta.Annotations[pkg.ClusterModuleNameKey] != "" {
+		return nil, nil
+	}
+
+	resourcePolicy := &vmopv1alpha1.VirtualMachineSetResourcePolicy{}
+	key := client.ObjectKey{Namespace: ctx.ReplicaSet.Namespace, Name: policyName}
+	if err := r.Get(ctx, key, resourcePolicy); err != nil {
+		return nil, errors.Wrapf(err, "failed to get VirtualMachineSetResourcePolicy %s", policyName)
+	}
+
+	if len(resourcePolicy.Spec.ClusterModules) == 0 {
+		return nil, nil
+	}
+
+	annotations := map[string]string{
+		pkg.ClusterModuleNameKey: resourcePolicy.Spec.ClusterModules[0].GroupName,
+	}
+	if template.ObjectMeta.Annotations[pkg.ProviderTagsAnnotationKey] == "" {
+		annotations[pkg.ProviderTagsAnnotationKey] = config.WorkerVMVMAntiAffinityTagKey
+	}
+
+	return annotations, nil
+}
+
+// deleteVMs deletes count VirtualMachines. VirtualMachines that are not ready are deleted first, then
+// VirtualMachines that are not powered on, and then the newest VirtualMachines.
+func (r *Reconciler) deleteVMs(
+	ctx *context.VirtualMachineReplicaSetContext,
+	vms []vmopv1alpha1.VirtualMachine,
+	count int) error {
+
+	sort.SliceStable(vms, func(i, j int) bool {
+		if ri, rj := isVMReady(&vms[i]), isVMReady(&vms[j]); ri != rj {
+			return !ri
+		}
+		if pi, pj := isVMPoweredOn(&vms[i]), isVMPoweredOn(&vms[j]); pi != pj {
+			return !pi
+		}
+		return vms[j].CreationTimestamp.Before(&vms[i].CreationTimestamp)
+	})
+
+	rs := ctx.ReplicaSet
+	for i := 0; i < count; i++ {
+		vm := &vms[i]
+		if err := r.Delete(ctx, vm); err != nil {
+			if apiErrors.IsNotFound(err) {
+				continue
+			}
+			r.Recorder.Warnf(rs, deleteVMFailedReason, "Failed to delete VirtualMachine %s: %v", vm.Name, err)
+			return errors.Wrapf(err, "failed to delete VirtualMachine %s", vm.Name)
+		}
+		r.expectations.ExpectDelete(rsKey(rs), vm.Name)
+
+		ctx.Logger.Info("Deleted VirtualMachine", "vmName", vm.Name)
+		r.Recorder.Eventf(rs, deletedVMReason, "Deleted VirtualMachine %s", vm.Name)
+	}
+
+	return nil
+}
+
+func rsKey(rs *vmopapi.VirtualMachineReplicaSet) types.NamespacedName {
+	return types.NamespacedName{Namespace: rs.Namespace, Name: rs.Name}
+}
+
+func countReadyVMs(vms []vmopv1alpha1.VirtualMachine) int {
+	count := 0
+	for i := range vms {
+		if isVMReady(&vms[i]) {
+			count++
+		}
+	}
+	return count
+}
+
+// isVMReady returns whether the VM is ready. The Ready condition is only set for VMs with a readiness probe,
+// so VMs without one are ready when they are powered on and have an IP.
+func isVMReady(vm *vmopv1alpha1.VirtualMachine) bool {
+	if vm.Spec.ReadinessProbe == nil {
+		return isVMPoweredOn(vm) && vm.Status.VmIp != ""
+	}
+	return conditions.IsTrue(vm, vmopv1alpha1.ReadyCondition)
+}
+
+func isVMPoweredOn(vm *vmopv1alpha1.VirtualMachine) bool {
+	return vm.Status.PowerState == vmopv1alpha1.VirtualMachinePoweredOn
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinereplicaset_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func intgTests() {
+	var (
+		ctx *builder.IntegrationTestContext
+
+		rs    *vmopapi.VirtualMachineReplicaSet
+		image *vmopv1alpha1.VirtualMachineImage
+	)
+
+	BeforeEach(func() {
+		ctx = suite.NewIntegrationTestContext()
+
+		image = &vmopv1alpha1.VirtualMachineImage{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "dummy-image",
+			},
+			Spec: vmopv1alpha1.VirtualMachineImageSpec{
+				ImageID: "dummy-id",
+			},
+		}
+
+		rs = &vmopapi.VirtualMachineReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-rs",
+				Namespace: ctx.Namespace,
+			},
+			Spec: vmopapi.VirtualMachineReplicaSetSpec{
+				Replicas: pointer.Int32Ptr(3),
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "dummy"},
+				},
+				Template: vmopapi.VirtualMachineTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{"app": "dummy"},
+					},
+					Spec: vmopv1alpha1.VirtualMachineSpec{
+						ImageName:  "dummy-image",
+						ClassName:  "dummy-class",
+						PowerState: vmopv1alpha1.VirtualMachinePoweredOn,
+					},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		ctx.AfterEach()
+		ctx = nil
+		intgFakeVMProvider.Reset()
+	})
+
+	getRS := func(ctx *builder.IntegrationTestContext, objKey client.ObjectKey) *vmopapi.VirtualMachineReplicaSet {
+		rs := &vmopapi.VirtualMachineReplicaSet{}
+		if err := ctx.Client.Get(ctx, objKey, rs); err != nil {
+			return nil
+		}
+		return rs
+	}
+
+	listVMs := func(ctx *builder.IntegrationTestContext) []vmopv1alpha1.VirtualMachine {
+		vmList := &vmopv1alpha1.VirtualMachineList{}
+		if err := ctx.Client.List(ctx, vmList, client.InNamespace(ctx.Namespace)); err != nil {
+			return nil
+		}
+		return vmList.Items
+	}
+
+	Context("Reconcile", func() {
+		BeforeEach(func() {
+			Expect(ctx.Client.Create(ctx, image)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(ctx.Client.Delete(ctx, image)).To(Succeed())
+		})
+
+		It("Reconciles after VirtualMachineReplicaSet creation and scaling", func() {
+			Expect(ctx.Client.Create(ctx, rs)).To(Succeed())
+			rsKey := client.ObjectKeyFromObject(rs)
+
+			By("VirtualMachines should be created", func() {
+				Eventually(func() int {
+					return len(listVMs(ctx))
+				}).Should(Equal(3))
+			})
+
+			By("VirtualMachineReplicaSet should have the replicas in its status", func() {
+				Eventually(func() int32 {
+					if rs := getRS(ctx, rsKey); rs != nil {
+						return rs.Status.Replicas
+					}
+					return 0
+				}).Should(BeEquivalentTo(3))
+			})
+
+			By("VirtualMachines should be deleted when scaled down", func() {
+				rs := getRS(ctx, rsKey)
+				Expect(rs).ToNot(BeNil())
+				rs.Spec.Replicas = pointer.Int32Ptr(1)
+				Expect(ctx.Client.Update(ctx, rs)).To(Succeed())
+
+				Eventually(func() int {
+					count := 0
+					for _, vm := range listVMs(ctx) {
+						if vm.DeletionTimestamp.IsZero() {
+							count++
+						}
+					}
+					return count
+				}).Should(Equal(1))
+			})
+		})
+	})
+}
@@ -0,0 +1,436 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinereplicaset_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachinereplicaset"
+	"github.com/acharyasreej/vm-operator/pkg"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/config"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func unitTests() {
+	Describe("Invoking Reconcile", unitTestsReconcile)
+}
+
+func unitTestsReconcile() {
+	var (
+		initObjects []client.Object
+		ctx         *builder.UnitTestContextForController
+		reconciler  *virtualmachinereplicaset.Reconciler
+
+		rsCtx *context.VirtualMachineReplicaSetContext
+		rs    *vmopapi.VirtualMachineReplicaSet
+		image *vmopv1alpha1.VirtualMachineImage
+	)
+
+	BeforeEach(func() {
+		image = &vmopv1alpha1.VirtualMachineImage{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "dummy-image",
+				Annotations: map[string]string{
+					constants.VMImageCLVersionAnnotation: "dummy-item:1:1",
+				},
+			},
+		}
+		rs = &vmopapi.VirtualMachineReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-rs",
+				Namespace: "dummy-ns",
+				UID:       "dummy-uid",
+			},
+			Spec: vmopapi.VirtualMachineReplicaSetSpec{
+				Replicas: pointer.Int32Ptr(2),
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "dummy"},
+				},
+				Template: vmopapi.VirtualMachineTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels:      map[string]string{"app": "dummy"},
+						Annotations: map[string]string{"foo": "bar"},
+					},
+					Spec: vmopv1alpha1.VirtualMachineSpec{
+						ImageName:  "dummy-image",
+						ClassName:  "dummy-class",
+						PowerState: vmopv1alpha1.VirtualMachinePoweredOn,
+					},
+				},
+			},
+		}
+		initObjects = append(initObjects, image)
+	})
+
+	JustBeforeEach(func() {
+		ctx = suite.NewUnitTestContextForController(initObjects...)
+		reconciler = virtualmachinereplicaset.NewReconciler(
+			ctx.Client,
+			ctx.Logger,
+			ctx.Recorder,
+			ctx.VMProvider,
+		)
+
+		rsCtx = &context.VirtualMachineReplicaSetContext{
+			Context:    ctx,
+			Logger:     ctx.Logger.WithName(rs.Name),
+			ReplicaSet: rs,
+		}
+	})
+
+	AfterEach(func() {
+		ctx.AfterEach()
+		ctx = nil
+		initObjects = nil
+		rsCtx = nil
+		reconciler = nil
+	})
+
+	listVMs := func() []vmopv1alpha1.VirtualMachine {
+		vmList := &vmopv1alpha1.VirtualMachineList{}
+		Expect(ctx.Client.List(ctx, vmList, client.InNamespace(rs.Namespace))).To(Succeed())
+		return vmList.Items
+	}
+
+	getRevision := func() string {
+		revision, err := virtualmachinereplicaset.TemplateRevision(&rs.Spec.Template, image)
+		Expect(err).ToNot(HaveOccurred())
+		return revision
+	}
+
+	// newVM returns a ready VM with a readiness probe of the current revision of the replica set that was created age ago.
+	newVM := func(name string, age time.Duration) *vmopv1alpha1.VirtualMachine {
+		vm := &vmopv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: rs.Namespace,
+				Labels:    map[string]string{"app": "dummy"},
+				Annotations: map[string]string{
+					vmopapi.VirtualMachineReplicaSetRevisionAnnotation: getRevision(),
+				},
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(rs, vmopapi.GroupVersion.WithKind("VirtualMachineReplicaSet")),
+				},
+			},
+			Spec: vmopv1alpha1.VirtualMachineSpec{
+				ReadinessProbe: &vmopv1alpha1.Probe{},
+			},
+			Status: vmopv1alpha1.VirtualMachineStatus{
+				PowerState: vmopv1alpha1.VirtualMachinePoweredOn,
+				VmIp:       "1.2.3.4",
+			},
+		}
+		conditions.MarkTrue(vm, vmopv1alpha1.ReadyCondition)
+		return vm
+	}
+
+	Context("ReconcileNormal", func() {
+		When("the replica set has no VMs", func() {
+			BeforeEach(func() {
+				initObjects = append(initObjects, rs)
+			})
+
+			It("will create the VMs from the template", func() {
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+
+				vms := listVMs()
+				Expect(vms).To(HaveLen(2))
+				for _, vm := range vms {
+					Expect(vm.Name).To(HavePrefix(rs.Name + "-"))
+					Expect(vm.Labels).To(HaveKeyWithValue("app", "dummy"))
+					Expect(vm.Annotations).To(HaveKeyWithValue("foo", "bar"))
+					Expect(vm.Annotations).ToNot(HaveKey(pkg.ClusterModuleNameKey))
+					Expect(vm.Annotations).To(HaveKeyWithValue(vmopapi.VirtualMachineReplicaSetRevisionAnnotation, getRevision()))
+					Expect(vm.Spec).To(Equal(rs.Spec.Template.Spec))
+					Expect(metav1.IsControlledBy(&vm, rs)).To(BeTrue())
+				}
+				Expect(ctx.Events).To(Receive(ContainSubstring("CreatedVM")))
+
+				Expect(rs.Status.Replicas).To(BeZero())
+				Expect(rs.Status.Selector).To(Equal("app=dummy"))
+				Expect(rs.Status.CurrentRevision).To(Equal(getRevision()))
+				Expect(conditions.IsTrue(rs, vmopapi.VirtualMachineReplicaSetRolledOutCondition)).To(BeTrue())
+			})
+
+			It("will not create more VMs until the created VMs are observed", func() {
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+				vms := listVMs()
+				Expect(vms).To(HaveLen(2))
+
+				By("the created VMs are not in the cache yet", func() {
+					for i := range vms {
+						Expect(ctx.Client.Delete(ctx, &vms[i])).To(Succeed())
+					}
+				})
+
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+				Expect(listVMs()).To(BeEmpty())
+			})
+
+			It("will create the VMs when the image of the template does not exist", func() {
+				Expect(ctx.Client.Delete(ctx, image)).To(Succeed())
+
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+				Expect(listVMs()).To(HaveLen(2))
+				Expect(rs.Status.CurrentRevision).ToNot(BeEmpty())
+			})
+
+			It("will not create VMs when the selector does not match the template", func() {
+				rs.Spec.Selector.MatchLabels = map[string]string{"app": "other"}
+
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+				Expect(listVMs()).To(BeEmpty())
+				Expect(ctx.Events).To(Receive(ContainSubstring("InvalidSelector")))
+			})
+
+			When("the template has a resource policy with cluster modules", func() {
+				var resourcePolicy *vmopv1alpha1.VirtualMachineSetResourcePolicy
+
+				BeforeEach(func() {
+					resourcePolicy = &vmopv1alpha1.VirtualMachineSetResourcePolicy{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "dummy-policy",
+							Namespace: rs.Namespace,
+						},
+						Spec: vmopv1alpha1.VirtualMachineSetResourcePolicySpec{
+							ClusterModules: []vmopv1alpha1.ClusterModuleSpec{
+								{GroupName: "dummy-group"},
+							},
+						},
+					}
+					rs.Spec.Template.Spec.ResourcePolicyName = resourcePolicy.Name
+					initObjects = append(initObjects, resourcePolicy)
+				})
+
+				It("will place the VMs in the cluster module", func() {
+					Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+
+					vms := listVMs()
+					Expect(vms).To(HaveLen(2))
+					for _, vm := range vms {
+						Expect(vm.Annotations).To(HaveKeyWithValue(pkg.ClusterModuleNameKey, "dummy-group"))
+						Expect(vm.Annotations).To(HaveKeyWithValue(pkg.ProviderTagsAnnotationKey, config.WorkerVMVMAntiAffinityTagKey))
+					}
+				})
+
+				It("will keep the cluster module annotations of the template", func() {
+					rs.Spec.Template.ObjectMeta.Annotations[pkg.ClusterModuleNameKey] = "other-group"
+					rs.Spec.Template.ObjectMeta.Annotations[pkg.ProviderTagsAnnotationKey] = config.CtrlVMVMAntiAffinityTagKey
+
+					Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+
+					for _, vm := range listVMs() {
+						Expect(vm.Annotations).To(HaveKeyWithValue(pkg.ClusterModuleNameKey, "other-group"))
+						Expect(vm.Annotations).To(HaveKeyWithValue(pkg.ProviderTagsAnnotationKey, config.CtrlVMVMAntiAffinityTagKey))
+					}
+				})
+			})
+		})
+
+		When("the replica set has more VMs than desired", func() {
+			var readyOldVM, readyNewVM, notReadyVM, poweredOffVM *vmopv1alpha1.VirtualMachine
+
+			BeforeEach(func() {
+				readyOldVM = newVM("ready-old", time.Hour)
+				readyNewVM = newVM("ready-new", time.Minute)
+
+				notReadyVM = newVM("not-ready", 2*time.Hour)
+				conditions.MarkFalse(notReadyVM, vmopv1alpha1.ReadyCondition, "NotReady", vmopv1alpha1.ConditionSeverityInfo, "")
+
+				poweredOffVM = newVM("powered-off", 2*time.Hour)
+				poweredOffVM.Status.PowerState = vmopv1alpha1.VirtualMachinePoweredOff
+
+				otherVM := newVM("not-controlled", time.Minute)
+				otherVM.OwnerReferences = nil
+
+				initObjects = append(initObjects, rs, readyOldVM, readyNewVM, notReadyVM, poweredOffVM, otherVM)
+			})
+
+			getVMNames := func() []string {
+				var names []string
+				for _, vm := range listVMs() {
+					names = append(names, vm.Name)
+				}
+				return names
+			}
+
+			It("will delete the VMs that are not ready first", func() {
+				rs.Spec.Replicas = pointer.Int32Ptr(3)
+
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+				Expect(getVMNames()).To(ConsistOf(readyOldVM.Name, readyNewVM.Name, poweredOffVM.Name, "not-controlled"))
+				Expect(ctx.Events).To(Receive(ContainSubstring("DeletedVM")))
+
+				Expect(rs.Status.Replicas).To(BeEquivalentTo(4))
+				Expect(rs.Status.ReadyReplicas).To(BeEquivalentTo(3))
+			})
+
+			When("the VMs do not have a readiness probe", func() {
+				BeforeEach(func() {
+					for _, vm := range []*vmopv1alpha1.VirtualMachine{readyOldVM, readyNewVM} {
+						vm.Spec.ReadinessProbe = nil
+						conditions.Delete(vm, vmopv1alpha1.ReadyCondition)
+					}
+					readyNewVM.Status.VmIp = ""
+				})
+
+				It("will consider them ready when they are powered on and have an IP", func() {
+					rs.Spec.Replicas = pointer.Int32Ptr(4)
+
+					Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+					Expect(rs.Status.Replicas).To(BeEquivalentTo(4))
+					Expect(rs.Status.ReadyReplicas).To(BeEquivalentTo(2))
+				})
+			})
+
+			It("will not delete more VMs until the deleted VMs are observed", func() {
+				rs.Spec.Replicas = pointer.Int32Ptr(3)
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+				Expect(getVMNames()).ToNot(ContainElement(notReadyVM.Name))
+
+				By("the deleted VM is still in the cache", func() {
+					vm := notReadyVM.DeepCopy()
+					vm.ResourceVersion = ""
+					Expect(ctx.Client.Create(ctx, vm)).To(Succeed())
+				})
+
+				rs.Spec.Replicas = pointer.Int32Ptr(1)
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+				Expect(getVMNames()).To(ConsistOf(readyOldVM.Name, readyNewVM.Name, notReadyVM.Name, poweredOffVM.Name, "not-controlled"))
+			})
+
+			It("will delete the VMs that are not powered on next, and then the newest VMs", func() {
+				rs.Spec.Replicas = pointer.Int32Ptr(1)
+
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+				Expect(getVMNames()).To(ConsistOf(readyOldVM.Name, "not-controlled"))
+			})
+		})
+
+		When("the replica set has VMs of a previous revision", func() {
+			var oldVM1, oldVM2 *vmopv1alpha1.VirtualMachine
+
+			BeforeEach(func() {
+				oldVM1 = newVM("old-1", 2*time.Hour)
+				oldVM2 = newVM("old-2", time.Hour)
+				for _, vm := range []*vmopv1alpha1.VirtualMachine{oldVM1, oldVM2} {
+					vm.Annotations[vmopapi.VirtualMachineReplicaSetRevisionAnnotation] = "old-revision"
+				}
+
+				initObjects = append(initObjects, rs, oldVM1, oldVM2)
+			})
+
+			getNewVMs := func() []vmopv1alpha1.VirtualMachine {
+				var vms []vmopv1alpha1.VirtualMachine
+				for _, vm := range listVMs() {
+					if vm.Annotations[vmopapi.VirtualMachineReplicaSetRevisionAnnotation] == getRevision() {
+						vms = append(vms, vm)
+					}
+				}
+				return vms
+			}
+
+			It("will create a replacement within the surge budget", func() {
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+
+				Expect(listVMs()).To(HaveLen(3))
+				Expect(getNewVMs()).To(HaveLen(1))
+				Expect(rs.Status.UpdatedReplicas).To(BeZero())
+
+				c := conditions.Get(rs, vmopapi.VirtualMachineReplicaSetRolledOutCondition)
+				Expect(c).ToNot(BeNil())
+				Expect(c.Status).To(Equal(corev1.ConditionFalse))
+				Expect(c.Reason).To(Equal(vmopapi.VirtualMachineReplicaSetRollingUpdateReason))
+			})
+
+			It("will delete a VM of the previous revision when within the unavailable budget", func() {
+				rs.Spec.RollingUpdate = &vmopapi.VirtualMachineRollingUpdateStrategy{
+					MaxSurge:       &intstr.IntOrString{Type: intstr.Int, IntVal: 0},
+					MaxUnavailable: &intstr.IntOrString{Type: intstr.String, StrVal: "50%"},
+				}
+
+				Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+
+				vms := listVMs()
+				Expect(vms).To(HaveLen(1))
+				Expect(vms[0].Name).To(Equal(oldVM1.Name))
+			})
+
+			When("the replacement is ready", func() {
+				BeforeEach(func() {
+					initObjects = append(initObjects, newVM("new-1", time.Minute))
+				})
+
+				It("will delete a VM of the previous revision", func() {
+					Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+
+					vms := listVMs()
+					Expect(vms).To(HaveLen(2))
+					Expect(getNewVMs()).To(HaveLen(1))
+					Expect(ctx.Events).To(Receive(ContainSubstring("DeletedVM")))
+					Expect(rs.Status.UpdatedReplicas).To(BeEquivalentTo(1))
+				})
+			})
+
+			When("the replacement does not become ready in time", func() {
+				BeforeEach(func() {
+					notReadyVM := newVM("new-1", time.Hour)
+					conditions.MarkFalse(notReadyVM, vmopv1alpha1.ReadyCondition, "NotReady", vmopv1alpha1.ConditionSeverityInfo, "")
+					initObjects = append(initObjects, notReadyVM)
+				})
+
+				It("will pause the rolling update", func() {
+					Expect(reconciler.ReconcileNormal(rsCtx)).To(Succeed())
+
+					Expect(listVMs()).To(HaveLen(3))
+					Expect(ctx.Events).To(Receive(ContainSubstring("RollingUpdatePaused")))
+
+					c := conditions.Get(rs, vmopapi.VirtualMachineReplicaSetRolledOutCondition)
+					Expect(c).ToNot(BeNil())
+					Expect(c.Reason).To(Equal(vmopapi.VirtualMachineReplicaSetReplacementNotReadyReason))
+					Expect(c.Message).To(ContainSubstring("new-1"))
+				})
+			})
+		})
+	})
+
+	Context("TemplateRevision", func() {
+		It("will change when the content library version of the image changes", func() {
+			revision := getRevision()
+
+			image.Annotations[constants.VMImageCLVersionAnnotation] = "dummy-item:2:1"
+			Expect(getRevision()).ToNot(Equal(revision))
+		})
+
+		It("will not change when only the format of the content library version of the image changes", func() {
+			revision := getRevision()
+
+			image.Annotations[constants.VMImageCLVersionAnnotation] = "dummy-item:1:2"
+			Expect(getRevision()).To(Equal(revision))
+		})
+
+		It("will change when the class of the template changes", func() {
+			revision := getRevision()
+
+			rs.Spec.Template.Spec.ClassName = "other-class"
+			Expect(getRevision()).ToNot(Equal(revision))
+		})
+	})
+}
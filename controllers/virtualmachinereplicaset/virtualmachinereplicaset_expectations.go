@@ -0,0 +1,112 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinereplicaset
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+)
+
+// expectationsTimeout is how long a replica set waits for the VirtualMachines that it created or deleted to
+// be observed before it is scaled again anyway, in case an expected event was missed.
+const expectationsTimeout = 5 * time.Minute
+
+// expectations records, for each replica set, the VirtualMachines that were created but are not listed yet, and
+// the VirtualMachines that were deleted but are still listed. The VirtualMachines are listed from the cache of
+// the manager, which lags behind the creates and deletes of the controller, so a replica set is not scaled
+// while it has such VirtualMachines, since it would otherwise create or delete more VirtualMachines than
+// desired.
+type expectations struct {
+	mu        sync.Mutex
+	byReplica map[types.NamespacedName]*replicaSetExpectations
+}
+
+type replicaSetExpectations struct {
+	creates   sets.String
+	deletes   sets.String
+	timestamp time.Time
+}
+
+func newExpectations() *expectations {
+	return &expectations{
+		byReplica: map[types.NamespacedName]*replicaSetExpectations{},
+	}
+}
+
+func (e *expectations) getLocked(key types.NamespacedName) *replicaSetExpectations {
+	exp, ok := e.byReplica[key]
+	if !ok {
+		exp = &replicaSetExpectations{creates: sets.NewString(), deletes: sets.NewString()}
+		e.byReplica[key] = exp
+	}
+	exp.timestamp = time.Now()
+	return exp
+}
+
+// ExpectCreate records that the VirtualMachine was created for the replica set.
+func (e *expectations) ExpectCreate(key types.NamespacedName, vmName string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.getLocked(key).creates.Insert(vmName)
+}
+
+// ExpectDelete records that the VirtualMachine was deleted for the replica set.
+func (e *expectations) ExpectDelete(key types.NamespacedName, vmName string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.getLocked(key).deletes.Insert(vmName)
+}
+
+// Satisfied observes the VirtualMachines listed for the replica set, and returns true when all the
+// VirtualMachines that were created are listed and all the VirtualMachines that were deleted are not, or
+// when the expectations have expired.
+func (e *expectations) Satisfied(key types.NamespacedName, vms []vmopv1alpha1.VirtualMachine) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	exp, ok := e.byReplica[key]
+	if !ok {
+		return true
+	}
+
+	listed := sets.NewString()
+	for i := range vms {
+		listed.Insert(vms[i].Name)
+	}
+	exp.creates = exp.creates.Difference(listed)
+	exp.deletes = exp.deletes.Intersection(listed)
+
+	if exp.creates.Len() > 0 || exp.deletes.Len() > 0 {
+		if time.Since(exp.timestamp) < expectationsTimeout {
+			return false
+		}
+	}
+
+	delete(e.byReplica, key)
+	return true
+}
+
+// Pending returns true if the replica set has VirtualMachines that were created or deleted and not observed.
+func (e *expectations) Pending(key types.NamespacedName) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, ok := e.byReplica[key]
+	return ok
+}
+
+// Forget removes the expectations of the replica set.
+func (e *expectations) Forget(key types.NamespacedName) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.byReplica, key)
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinereplicaset
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
+)
+
+const (
+	defaultMaxSurge                = 1
+	defaultMaxUnavailable          = 0
+	defaultProgressDeadlineSeconds = 600
+)
+
+// getRevision returns the revision of the replica set's template. When the template's image does not exist,
+// the current revision is kept so that the replica set can still be scaled, and otherwise the revision is
+// computed without the version of the image.
+func (r *Reconciler) getRevision(ctx *context.VirtualMachineReplicaSetContext) (string, error) {
+	template := &ctx.ReplicaSet.Spec.Template
+
+	image := &vmopv1alpha1.VirtualMachineImage{}
+	if err := r.Get(ctx, client.ObjectKey{Name: template.Spec.ImageName}, image); err != nil {
+		if !apiErrors.IsNotFound(err) {
+			return "", errors.Wrapf(err, "failed to get VirtualMachineImage %s", template.Spec.ImageName)
+		}
+
+		ctx.Logger.Info("VirtualMachineImage of the template not found", "imageName", template.Spec.ImageName)
+		if rs := ctx.ReplicaSet; rs.Status.CurrentRevision != "" && rs.Status.ObservedGeneration == rs.Generation {
+			return rs.Status.CurrentRevision, nil
+		}
+		image = nil
+	}
+
+	return TemplateRevision(template, image)
+}
+
+// TemplateRevision returns the revision of the template. The revision is a hash of the template and of the
+// content library item version of the template's image, so the revision also changes when the content library
+// item of the image is updated. The image may be nil.
+func TemplateRevision(
+	template *vmopapi.VirtualMachineTemplateSpec,
+	image *vmopv1alpha1.VirtualMachineImage) (string, error) {
+
+	var imageVersion string
+	if image != nil {
+		imageVersion = imageItemVersion(image.Annotations[constants.VMImageCLVersionAnnotation])
+	}
+
+	data, err := json.Marshal(struct {
+		Template     *vmopapi.VirtualMachineTemplateSpec
+		ImageVersion string
+	}{
+		Template:     template,
+		ImageVersion: imageVersion,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	hasher := fnv.New32a()
+	_, _ = hasher.Write(data)
+	return utilrand.SafeEncodeString(fmt.Sprint(hasher.Sum32())), nil
+}
+
+// imageItemVersion returns the library item ID and version of the content library version annotation of an
+// image. The annotation ends with the version of its own format, which is dropped so that bumping the format
+// does not change the revision of every template.
+func imageItemVersion(clVersion string) string {
+	if i := strings.LastIndex(clVersion, ":"); i >= 0 && strings.Count(clVersion, ":") >= 2 {
+		return clVersion[:i]
+	}
+	return clVersion
+}
+
+// rollingUpdate replaces the VirtualMachines of previous revisions of the template. Replacements are created
+// while there are less VirtualMachines than the desired number plus the surge, and VirtualMachines of previous
+// revisions are deleted while the number of ready VirtualMachines stays at or above the desired number minus
+// the unavailable budget. The rolling update is paused when a replacement does not become ready in time.
+func (r *Reconciler) rollingUpdate(
+	ctx *context.VirtualMachineReplicaSetContext,
+	revision string,
+	replicas int,
+	newVMs, oldVMs []vmopv1alpha1.VirtualMachine) error {
+
+	rs := ctx.ReplicaSet
+
+	maxSurge, maxUnavailable, err := rollingUpdateBudgets(rs.Spec.RollingUpdate, replicas)
+	if err != nil {
+		r.Recorder.Warnf(rs, rollingUpdatePausedReason, "Invalid rolling update strategy: %v", err)
+		return nil
+	}
+
+	progressDeadline := time.Duration(defaultProgressDeadlineSeconds) * time.Second
+	if rs.Spec.RollingUpdate != nil && rs.Spec.RollingUpdate.ProgressDeadlineSeconds != nil {
+		progressDeadline = time.Duration(*rs.Spec.RollingUpdate.ProgressDeadlineSeconds) * time.Second
+	}
+
+	for i := range newVMs {
+		vm := &newVMs[i]
+		if isVMReady(vm) || time.Since(vm.CreationTimestamp.Time) < progressDeadline {
+			continue
+		}
+
+		if c := conditions.Get(rs, vmopapi.VirtualMachineReplicaSetRolledOutCondition); c == nil ||
+			c.Reason != vmopapi.VirtualMachineReplicaSetReplacementNotReadyReason {
+			r.Recorder.Warnf(rs, rollingUpdatePausedReason, "Replacement VirtualMachine %s is not ready", vm.Name)
+		}
+
+		conditions.MarkFalse(rs, vmopapi.VirtualMachineReplicaSetRolledOutCondition,
+			vmopapi.VirtualMachineReplicaSetReplacementNotReadyReason, vmopv1alpha1.ConditionSeverityWarning,
+			"Rolling update is paused because VirtualMachine %s did not become ready within %s", vm.Name, progressDeadline)
+		return nil
+	}
+
+	conditions.MarkFalse(rs, vmopapi.VirtualMachineReplicaSetRolledOutCondition,
+		vmopapi.VirtualMachineReplicaSetRollingUpdateReason, vmopv1alpha1.ConditionSeverityInfo,
+		"%d of %d VirtualMachines are updated", len(newVMs), replicas)
+
+	// The replica set was scaled down during the rolling update.
+	if len(newVMs) > replicas {
+		return r.deleteVMs(ctx, newVMs, len(newVMs)-replicas)
+	}
+
+	total := len(newVMs) + len(oldVMs)
+	if count := min(replicas-len(newVMs), replicas+maxSurge-total); count > 0 {
+		if err := r.createVMs(ctx, revision, count); err != nil {
+			return err
+		}
+	}
+
+	// VirtualMachines of previous revisions that are not ready can always be deleted since they do not
+	// count towards the available VirtualMachines.
+	ready := countReadyVMs(newVMs) + countReadyVMs(oldVMs)
+	oldNotReady := len(oldVMs) - countReadyVMs(oldVMs)
+	count := min(len(oldVMs), oldNotReady+max(0, ready-(replicas-maxUnavailable)))
+	if count > 0 {
+		return r.deleteVMs(ctx, oldVMs, count)
+	}
+
+	return nil
+}
+
+// rollingUpdateBudgets returns the number of VirtualMachines that can be created above, and that can be
+// unavailable below, the desired number of VirtualMachines during a rolling update.
+func rollingUpdateBudgets(strategy *vmopapi.VirtualMachineRollingUpdateStrategy, replicas int) (int, int, error) {
+	maxSurge := intstr.FromInt(defaultMaxSurge)
+	maxUnavailable := intstr.FromInt(defaultMaxUnavailable)
+	if strategy != nil {
+		if strategy.MaxSurge != nil {
+			maxSurge = *strategy.MaxSurge
+		}
+		if strategy.MaxUnavailable != nil {
+			maxUnavailable = *strategy.MaxUnavailable
+		}
+	}
+
+	surge, err := intstr.GetScaledValueFromIntOrPercent(&maxSurge, replicas, true)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "invalid maxSurge")
+	}
+
+	unavailable, err := intstr.GetScaledValueFromIntOrPercent(&maxUnavailable, replicas, false)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "invalid maxUnavailable")
+	}
+
+	// The rolling update could not make progress without surge or unavailable VirtualMachines.
+	if surge == 0 && unavailable == 0 {
+		unavailable = 1
+	}
+
+	return surge, unavailable, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
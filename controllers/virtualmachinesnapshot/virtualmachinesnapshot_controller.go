@@ -0,0 +1,285 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinesnapshot
+
+import (
+	goctx "context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/patch"
+	"github.com/acharyasreej/vm-operator/pkg/record"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+)
+
+const (
+	finalizerName = "virtualmachinesnapshot.vmoperator.vmware.com"
+
+	// Reasons of the events emitted by the controller.
+	revertedReason     = "Reverted"
+	revertFailedReason = "RevertFailed"
+)
+
+// AddToManager adds this package's controller to the provided manager.
+func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controlledType     = &vmopapi.VirtualMachineSnapshot{}
+		controlledTypeName = reflect.TypeOf(controlledType).Elem().Name()
+
+		controllerNameShort = fmt.Sprintf("%s-controller", strings.ToLower(controlledTypeName))
+		controllerNameLong  = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
+	)
+
+	r := NewReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName(controlledTypeName),
+		record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		ctx.VMProvider,
+	)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(controlledType).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+func NewReconciler(
+	client client.Client,
+	logger logr.Logger,
+	recorder record.Recorder,
+	vmProvider vmprovider.VirtualMachineProviderInterface) *Reconciler {
+	return &Reconciler{
+		Client:     client,
+		Logger:     logger,
+		Recorder:   recorder,
+		VMProvider: vmProvider,
+	}
+}
+
+// Reconciler reconciles a VirtualMachineSnapshot object.
+type Reconciler struct {
+	client.Client
+	Logger     logr.Logger
+	Recorder   record.Recorder
+	VMProvider vmprovider.VirtualMachineProviderInterface
+}
+
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachinesnapshots,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachinesnapshots/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachines,verbs=get;list;watch
+
+func (r *Reconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	snapshot := &vmopapi.VirtualMachineSnapshot{}
+	if err := r.Get(ctx, req.NamespacedName, snapshot); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	snapshotCtx := &context.VirtualMachineSnapshotContext{
+		Context:  ctx,
+		Logger:   r.Logger.WithName("VirtualMachineSnapshot").WithValues("name", snapshot.NamespacedName()),
+		Snapshot: snapshot,
+	}
+
+	patchHelper, err := patch.NewHelper(snapshot, r.Client)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to init patch helper for %s", snapshotCtx.String())
+	}
+	defer func() {
+		if err := patchHelper.Patch(ctx, snapshot); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			snapshotCtx.Logger.Error(err, "patch failed")
+		}
+	}()
+
+	if !snapshot.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.ReconcileDelete(snapshotCtx)
+	}
+
+	return ctrl.Result{}, r.ReconcileNormal(snapshotCtx)
+}
+
+// getVM gets the VirtualMachine of the snapshot, and sets it in the context.
+func (r *Reconciler) getVM(ctx *context.VirtualMachineSnapshotContext) error {
+	vm := &vmopv1alpha1.VirtualMachine{}
+	key := client.ObjectKey{Namespace: ctx.Snapshot.Namespace, Name: ctx.Snapshot.Spec.VirtualMachineName}
+	if err := r.Get(ctx, key, vm); err != nil {
+		return err
+	}
+
+	ctx.VM = vm
+	return nil
+}
+
+// ReconcileNormal creates the snapshot if it does not exist yet, updates the status from the
+// provider, and reverts the VM to the snapshot if requested.
+func (r *Reconciler) ReconcileNormal(ctx *context.VirtualMachineSnapshotContext) error {
+	snapshot := ctx.Snapshot
+
+	if !controllerutil.ContainsFinalizer(snapshot, finalizerName) {
+		// Return here so the VirtualMachineSnapshot can be patched immediately. This ensures that
+		// the snapshot is removed from the VM when the VirtualMachineSnapshot is deleted.
+		controllerutil.AddFinalizer(snapshot, finalizerName)
+		return nil
+	}
+
+	ctx.Logger.Info("Reconciling VirtualMachineSnapshot")
+	defer func() {
+		ctx.Logger.Info("Finished Reconciling VirtualMachineSnapshot")
+	}()
+
+	if err := r.getVM(ctx); err != nil {
+		if apierrors.IsNotFound(err) {
+			conditions.MarkFalse(snapshot, vmopapi.VirtualMachineSnapshotCreatedCondition,
+				vmopapi.VirtualMachineSnapshotVMNotFoundReason, vmopv1alpha1.ConditionSeverityError,
+				"VirtualMachine %s not found", snapshot.Spec.VirtualMachineName)
+		}
+		return err
+	}
+
+	// The snapshot cannot outlive its VM, so have it garbage collected along with the VM.
+	if err := controllerutil.SetOwnerReference(ctx.VM, snapshot, r.Scheme()); err != nil {
+		return err
+	}
+
+	snapshots, err := r.VMProvider.ListVirtualMachineSnapshots(ctx, ctx.VM)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list snapshots of VM %s", ctx.VM.NamespacedName())
+	}
+
+	if snapshot.Status.SnapshotID == "" {
+		// Adopt a snapshot of the same name in case we created the snapshot but failed to
+		// update the status with its ID.
+		for i := range snapshots {
+			if snapshots[i].Name == snapshot.Name {
+				snapshot.Status.SnapshotID = snapshots[i].ID
+				break
+			}
+		}
+	}
+
+	if snapshot.Status.SnapshotID == "" {
+		if err := r.createSnapshot(ctx); err != nil {
+			return err
+		}
+
+		if snapshots, err = r.VMProvider.ListVirtualMachineSnapshots(ctx, ctx.VM); err != nil {
+			return errors.Wrapf(err, "failed to list snapshots of VM %s", ctx.VM.NamespacedName())
+		}
+	}
+
+	var vmSnapshot *vmprovider.VMSnapshot
+	for i := range snapshots {
+		if snapshots[i].ID == snapshot.Status.SnapshotID {
+			vmSnapshot = &snapshots[i]
+			break
+		}
+	}
+
+	if vmSnapshot == nil {
+		conditions.MarkFalse(snapshot, vmopapi.VirtualMachineSnapshotCreatedCondition,
+			vmopapi.VirtualMachineSnapshotNotFoundReason, vmopv1alpha1.ConditionSeverityError,
+			"Snapshot %s no longer exists on the VM", snapshot.Status.SnapshotID)
+		return nil
+	}
+
+	creationTime := metav1.NewTime(vmSnapshot.CreateTime)
+	snapshot.Status.CreationTime = &creationTime
+	snapshot.Status.PowerState = string(vmSnapshot.PowerState)
+	snapshot.Status.Quiesced = vmSnapshot.Quiesced
+	conditions.MarkTrue(snapshot, vmopapi.VirtualMachineSnapshotCreatedCondition)
+
+	if _, ok := snapshot.Annotations[vmopapi.RevertSnapshotAnnotation]; ok {
+		return r.revertSnapshot(ctx)
+	}
+
+	return nil
+}
+
+func (r *Reconciler) createSnapshot(ctx *context.VirtualMachineSnapshotContext) error {
+	snapshot := ctx.Snapshot
+
+	args := vmprovider.VMSnapshotArgs{
+		Name:        snapshot.Name,
+		Description: snapshot.Spec.Description,
+		Memory:      snapshot.Spec.Memory,
+		Quiesce:     snapshot.Spec.Quiesce,
+	}
+
+	snapshotID, err := r.VMProvider.CreateVirtualMachineSnapshot(ctx, ctx.VM, args)
+	if err != nil {
+		conditions.MarkFalse(snapshot, vmopapi.VirtualMachineSnapshotCreatedCondition,
+			vmopapi.VirtualMachineSnapshotCreateFailedReason, vmopv1alpha1.ConditionSeverityError, err.Error())
+		return errors.Wrapf(err, "failed to create snapshot of VM %s", ctx.VM.NamespacedName())
+	}
+
+	ctx.Logger.Info("Created VM snapshot", "snapshotID", snapshotID)
+	snapshot.Status.SnapshotID = snapshotID
+
+	return nil
+}
+
+func (r *Reconciler) revertSnapshot(ctx *context.VirtualMachineSnapshotContext) error {
+	snapshot := ctx.Snapshot
+
+	if err := r.VMProvider.RevertVirtualMachineSnapshot(ctx, ctx.VM, snapshot.Status.SnapshotID); err != nil {
+		r.Recorder.Warnf(snapshot, revertFailedReason, "Failed to revert VM %s to snapshot: %v", ctx.VM.Name, err)
+		return errors.Wrapf(err, "failed to revert VM %s to snapshot", ctx.VM.NamespacedName())
+	}
+
+	ctx.Logger.Info("Reverted VM to snapshot", "snapshotID", snapshot.Status.SnapshotID)
+	r.Recorder.Eventf(snapshot, revertedReason, "Reverted VM %s to snapshot", ctx.VM.Name)
+
+	now := metav1.Now()
+	snapshot.Status.LastRevertTime = &now
+	delete(snapshot.Annotations, vmopapi.RevertSnapshotAnnotation)
+
+	return nil
+}
+
+// ReconcileDelete removes the snapshot from the VM.
+func (r *Reconciler) ReconcileDelete(ctx *context.VirtualMachineSnapshotContext) error {
+	snapshot := ctx.Snapshot
+
+	if !controllerutil.ContainsFinalizer(snapshot, finalizerName) {
+		return nil
+	}
+
+	ctx.Logger.Info("Reconciling VirtualMachineSnapshot Deletion")
+	defer func() {
+		ctx.Logger.Info("Finished Reconciling VirtualMachineSnapshot Deletion")
+	}()
+
+	if snapshot.Status.SnapshotID != "" {
+		err := r.getVM(ctx)
+		if err == nil {
+			err = r.VMProvider.DeleteVirtualMachineSnapshot(ctx, ctx.VM, snapshot.Status.SnapshotID)
+		}
+
+		// The snapshot is already gone if the VM no longer exists.
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete snapshot %s", snapshot.Status.SnapshotID)
+		}
+	}
+
+	controllerutil.RemoveFinalizer(snapshot, finalizerName)
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinesnapshot_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func intgTests() {
+	var (
+		ctx *builder.IntegrationTestContext
+
+		vm       *vmopv1alpha1.VirtualMachine
+		snapshot *vmopapi.VirtualMachineSnapshot
+	)
+
+	BeforeEach(func() {
+		ctx = suite.NewIntegrationTestContext()
+
+		vm = &vmopv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-vm",
+				Namespace: ctx.Namespace,
+			},
+			Spec: vmopv1alpha1.VirtualMachineSpec{
+				ImageName:  "dummy-image",
+				ClassName:  "dummy-class",
+				PowerState: vmopv1alpha1.VirtualMachinePoweredOn,
+			},
+		}
+		snapshot = &vmopapi.VirtualMachineSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-snapshot",
+				Namespace: ctx.Namespace,
+			},
+			Spec: vmopapi.VirtualMachineSnapshotSpec{
+				VirtualMachineName: vm.Name,
+			},
+		}
+	})
+
+	AfterEach(func() {
+		ctx.AfterEach()
+		ctx = nil
+		intgFakeVMProvider.Reset()
+	})
+
+	getSnapshot := func(ctx *builder.IntegrationTestContext, objKey client.ObjectKey) *vmopapi.VirtualMachineSnapshot {
+		snapshot := &vmopapi.VirtualMachineSnapshot{}
+		if err := ctx.Client.Get(ctx, objKey, snapshot); err != nil {
+			return nil
+		}
+		return snapshot
+	}
+
+	Context("Reconcile", func() {
+		BeforeEach(func() {
+			Expect(ctx.Client.Create(ctx, vm)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(ctx.Client.Delete(ctx, vm)).To(Succeed())
+		})
+
+		It("Reconciles after VirtualMachineSnapshot creation", func() {
+			Expect(ctx.Client.Create(ctx, snapshot)).To(Succeed())
+			snapshotKey := client.ObjectKeyFromObject(snapshot)
+
+			By("VirtualMachineSnapshot should have finalizer added", func() {
+				Eventually(func() []string {
+					if snapshot := getSnapshot(ctx, snapshotKey); snapshot != nil {
+						return snapshot.GetFinalizers()
+					}
+					return nil
+				}).Should(ContainElement(finalizer))
+			})
+
+			By("VirtualMachineSnapshot should have the snapshot ID", func() {
+				Eventually(func() string {
+					if snapshot := getSnapshot(ctx, snapshotKey); snapshot != nil {
+						return snapshot.Status.SnapshotID
+					}
+					return ""
+				}).ShouldNot(BeEmpty())
+			})
+
+			By("Deleting the VirtualMachineSnapshot", func() {
+				Expect(ctx.Client.Delete(ctx, snapshot)).To(Succeed())
+				Eventually(func() *vmopapi.VirtualMachineSnapshot {
+					return getSnapshot(ctx, snapshotKey)
+				}).Should(BeNil())
+			})
+
+			By("Snapshot should be removed from the VM", func() {
+				snapshots, err := intgFakeVMProvider.ListVirtualMachineSnapshots(ctx, vm)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(snapshots).To(BeEmpty())
+			})
+		})
+	})
+}
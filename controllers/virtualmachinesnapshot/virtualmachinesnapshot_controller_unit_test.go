@@ -0,0 +1,226 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package virtualmachinesnapshot_test
+
+import (
+	goctx "context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/controllers/virtualmachinesnapshot"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+	providerfake "github.com/acharyasreej/vm-operator/pkg/vmprovider/fake"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func unitTests() {
+	Describe("Invoking Reconcile", unitTestsReconcile)
+}
+
+const (
+	finalizer = "virtualmachinesnapshot.vmoperator.vmware.com"
+)
+
+func unitTestsReconcile() {
+	var (
+		initObjects    []client.Object
+		ctx            *builder.UnitTestContextForController
+		reconciler     *virtualmachinesnapshot.Reconciler
+		fakeVMProvider *providerfake.VMProvider
+
+		snapshotCtx *context.VirtualMachineSnapshotContext
+		snapshot    *vmopapi.VirtualMachineSnapshot
+		vm          *vmopv1alpha1.VirtualMachine
+	)
+
+	BeforeEach(func() {
+		vm = &vmopv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-vm",
+				Namespace: "dummy-ns",
+				UID:       "dummy-uid",
+			},
+		}
+		snapshot = &vmopapi.VirtualMachineSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "dummy-snapshot",
+				Namespace:  "dummy-ns",
+				Finalizers: []string{finalizer},
+			},
+			Spec: vmopapi.VirtualMachineSnapshotSpec{
+				VirtualMachineName: vm.Name,
+				Description:        "dummy description",
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		ctx = suite.NewUnitTestContextForController(initObjects...)
+		reconciler = virtualmachinesnapshot.NewReconciler(
+			ctx.Client,
+			ctx.Logger,
+			ctx.Recorder,
+			ctx.VMProvider,
+		)
+		fakeVMProvider = ctx.VMProvider.(*providerfake.VMProvider)
+
+		snapshotCtx = &context.VirtualMachineSnapshotContext{
+			Context:  ctx,
+			Logger:   ctx.Logger.WithName(snapshot.Name),
+			Snapshot: snapshot,
+		}
+	})
+
+	AfterEach(func() {
+		ctx.AfterEach()
+		ctx = nil
+		initObjects = nil
+		snapshotCtx = nil
+		reconciler = nil
+		fakeVMProvider = nil
+	})
+
+	Context("ReconcileNormal", func() {
+		When("VM exists", func() {
+			BeforeEach(func() {
+				initObjects = append(initObjects, vm, snapshot)
+			})
+
+			It("will have finalizer set after reconciliation", func() {
+				snapshot.Finalizers = nil
+				Expect(reconciler.ReconcileNormal(snapshotCtx)).To(Succeed())
+				Expect(snapshot.GetFinalizers()).To(ContainElement(finalizer))
+				Expect(snapshot.Status.SnapshotID).To(BeEmpty())
+			})
+
+			It("will create the snapshot", func() {
+				Expect(reconciler.ReconcileNormal(snapshotCtx)).To(Succeed())
+				Expect(snapshot.Status.SnapshotID).ToNot(BeEmpty())
+				Expect(snapshot.Status.CreationTime).ToNot(BeNil())
+				Expect(conditions.IsTrue(snapshot, vmopapi.VirtualMachineSnapshotCreatedCondition)).To(BeTrue())
+				Expect(snapshot.OwnerReferences).To(HaveLen(1))
+				Expect(snapshot.OwnerReferences[0].Name).To(Equal(vm.Name))
+
+				snapshots, err := fakeVMProvider.ListVirtualMachineSnapshots(ctx, vm)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(snapshots).To(HaveLen(1))
+				Expect(snapshots[0].Name).To(Equal(snapshot.Name))
+				Expect(snapshots[0].Description).To(Equal(snapshot.Spec.Description))
+
+				By("will not create the snapshot again", func() {
+					Expect(reconciler.ReconcileNormal(snapshotCtx)).To(Succeed())
+					snapshots, err := fakeVMProvider.ListVirtualMachineSnapshots(ctx, vm)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(snapshots).To(HaveLen(1))
+				})
+			})
+
+			It("will adopt an existing snapshot with the same name", func() {
+				id, err := fakeVMProvider.CreateVirtualMachineSnapshot(ctx, vm, vmprovider.VMSnapshotArgs{Name: snapshot.Name})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(reconciler.ReconcileNormal(snapshotCtx)).To(Succeed())
+				Expect(snapshot.Status.SnapshotID).To(Equal(id))
+			})
+
+			It("will mark the condition false when the snapshot creation fails", func() {
+				fakeVMProvider.CreateVirtualMachineSnapshotFn = func(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine, _ vmprovider.VMSnapshotArgs) (string, error) {
+					return "", fmt.Errorf("create error")
+				}
+
+				err := reconciler.ReconcileNormal(snapshotCtx)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("create error"))
+				c := conditions.Get(snapshot, vmopapi.VirtualMachineSnapshotCreatedCondition)
+				Expect(c).ToNot(BeNil())
+				Expect(c.Status).To(Equal(corev1.ConditionFalse))
+				Expect(c.Reason).To(Equal(vmopapi.VirtualMachineSnapshotCreateFailedReason))
+			})
+
+			It("will mark the condition false when the snapshot no longer exists", func() {
+				snapshot.Status.SnapshotID = "snapshot-gone"
+				Expect(reconciler.ReconcileNormal(snapshotCtx)).To(Succeed())
+				c := conditions.Get(snapshot, vmopapi.VirtualMachineSnapshotCreatedCondition)
+				Expect(c).ToNot(BeNil())
+				Expect(c.Reason).To(Equal(vmopapi.VirtualMachineSnapshotNotFoundReason))
+			})
+
+			When("revert is requested", func() {
+				BeforeEach(func() {
+					snapshot.Annotations = map[string]string{vmopapi.RevertSnapshotAnnotation: ""}
+				})
+
+				It("will revert the VM to the snapshot", func() {
+					Expect(reconciler.ReconcileNormal(snapshotCtx)).To(Succeed())
+					Expect(snapshot.Status.LastRevertTime).ToNot(BeNil())
+					Expect(snapshot.Annotations).ToNot(HaveKey(vmopapi.RevertSnapshotAnnotation))
+					Expect(ctx.Events).To(Receive(ContainSubstring("Reverted")))
+				})
+
+				It("will keep the annotation when the revert fails", func() {
+					fakeVMProvider.RevertVirtualMachineSnapshotFn = func(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine, _ string) error {
+						return fmt.Errorf("revert error")
+					}
+
+					Expect(reconciler.ReconcileNormal(snapshotCtx)).ToNot(Succeed())
+					Expect(snapshot.Status.LastRevertTime).To(BeNil())
+					Expect(snapshot.Annotations).To(HaveKey(vmopapi.RevertSnapshotAnnotation))
+					Expect(ctx.Events).To(Receive(ContainSubstring("RevertFailed")))
+				})
+			})
+		})
+
+		When("VM does not exist", func() {
+			BeforeEach(func() {
+				initObjects = append(initObjects, snapshot)
+			})
+
+			It("will mark the condition false", func() {
+				Expect(reconciler.ReconcileNormal(snapshotCtx)).ToNot(Succeed())
+				c := conditions.Get(snapshot, vmopapi.VirtualMachineSnapshotCreatedCondition)
+				Expect(c).ToNot(BeNil())
+				Expect(c.Reason).To(Equal(vmopapi.VirtualMachineSnapshotVMNotFoundReason))
+			})
+		})
+	})
+
+	Context("ReconcileDelete", func() {
+		BeforeEach(func() {
+			initObjects = append(initObjects, vm, snapshot)
+		})
+
+		It("will delete the snapshot and remove the finalizer", func() {
+			Expect(reconciler.ReconcileNormal(snapshotCtx)).To(Succeed())
+			Expect(snapshot.Status.SnapshotID).ToNot(BeEmpty())
+
+			Expect(reconciler.ReconcileDelete(snapshotCtx)).To(Succeed())
+			Expect(snapshot.GetFinalizers()).To(BeEmpty())
+
+			snapshots, err := fakeVMProvider.ListVirtualMachineSnapshots(ctx, vm)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshots).To(BeEmpty())
+		})
+
+		It("will keep the finalizer when the snapshot deletion fails", func() {
+			snapshot.Status.SnapshotID = "snapshot-1"
+			fakeVMProvider.DeleteVirtualMachineSnapshotFn = func(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine, _ string) error {
+				return fmt.Errorf("delete error")
+			}
+
+			Expect(reconciler.ReconcileDelete(snapshotCtx)).ToNot(Succeed())
+			Expect(snapshot.GetFinalizers()).To(ContainElement(finalizer))
+		})
+	})
+}
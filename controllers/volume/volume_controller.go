@@ -29,7 +29,9 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	cnsv1alpha1 "github.com/acharyasreej/vm-operator/external/vsphere-csi-driver/pkg/syncer/cnsoperator/apis/cnsnodevmattachment/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	"github.com/acharyasreej/vm-operator/pkg/context"
 	"github.com/acharyasreej/vm-operator/pkg/lib"
 	"github.com/acharyasreej/vm-operator/pkg/patch"
@@ -40,6 +42,10 @@ import (
 
 const (
 	AttributeFirstClassDiskUUID = "diskUUID"
+
+	// attachmentTimeout is how long the attachments of the later volumes wait for a pending attachment when
+	// the volumes are attached in order. The attachment is then reported as failed and no longer waited for.
+	attachmentTimeout = 5 * time.Minute
 )
 
 // AddToManager adds this package's controller to the provided manager.
@@ -80,11 +86,10 @@ func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) er
 		return err
 	}
 
-	// Watch for changes for PersistentVolumeClaim, and enqueue VirtualMachine which is the owner of PersistentVolumeClaim.
-	err = c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestForOwner{
-		OwnerType:    &vmopv1alpha1.VirtualMachine{},
-		IsController: true,
-	})
+	// Watch for changes for PersistentVolumeClaim, and enqueue the VirtualMachines with a volume for the claim. This
+	// includes the instance storage PVCs owned by the VirtualMachine, and PVCs that are being expanded.
+	err = c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}},
+		handler.EnqueueRequestsFromMapFunc(pvcToVMMapperFn(ctx, r.Client)))
 	if err != nil {
 		return err
 	}
@@ -92,6 +97,35 @@ func AddToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) er
 	return nil
 }
 
+// pvcToVMMapperFn returns a mapper function that can be used to queue reconcile request for the
+// VirtualMachines in response to an event on the PersistentVolumeClaim resource.
+func pvcToVMMapperFn(ctx *context.ControllerManagerContext, c client.Reader) func(o client.Object) []reconcile.Request {
+	return func(o client.Object) []reconcile.Request {
+		pvc := o.(*corev1.PersistentVolumeClaim)
+
+		vmList := &vmopv1alpha1.VirtualMachineList{}
+		if err := c.List(ctx, vmList, client.InNamespace(pvc.Namespace)); err != nil {
+			ctx.Logger.Error(err, "Failed to list VirtualMachines for PersistentVolumeClaim watch",
+				"name", pvc.Name, "namespace", pvc.Namespace)
+			return nil
+		}
+
+		var reconcileRequests []reconcile.Request
+		for _, vm := range vmList.Items {
+			for _, volume := range vm.Spec.Volumes {
+				if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc.Name {
+					reconcileRequests = append(reconcileRequests, reconcile.Request{
+						NamespacedName: client.ObjectKey{Namespace: vm.Namespace, Name: vm.Name},
+					})
+					break
+				}
+			}
+		}
+
+		return reconcileRequests
+	}
+}
+
 func NewReconciler(
 	client client.Client,
 	logger logr.Logger,
@@ -154,12 +188,15 @@ func (r *Reconciler) Reconcile(ctx goctx.Context, request ctrl.Request) (_ ctrl.
 		return ctrl.Result{}, errors.Wrapf(err, "failed to init patch helper for %s", volCtx.String())
 	}
 	defer func() {
-		if err := patchHelper.Patch(ctx, vm); err != nil {
+		// The status of the vSphere volumes is also updated by the VirtualMachine controller.
+		if err := patchHelper.Patch(ctx, vm, patch.WithOptimisticLockOnStatusFields{Fields: []string{"volumes"}}); err != nil {
 			if reterr == nil {
 				reterr = err
 			}
 			volCtx.Logger.Error(err, "patch failed")
+			return
 		}
+		observeInstanceStoragePlacements(volCtx)
 	}()
 
 	if !vm.DeletionTimestamp.IsZero() {
@@ -173,15 +210,27 @@ func (r *Reconciler) Reconcile(ctx goctx.Context, request ctrl.Request) (_ ctrl.
 }
 
 func (r *Reconciler) reconcileResult(ctx *context.VolumeContext) ctrl.Result {
+	var result ctrl.Result
+
 	if ctx.InstanceStorageFSSEnabled {
 		// Requeue the request if all instance storage PVCs are not bound.
 		_, pvcsBound := ctx.VM.Annotations[constants.InstanceStoragePVCsBoundAnnotationKey]
 		if instancestorage.IsConfigured(ctx.VM) && !pvcsBound {
-			return ctrl.Result{RequeueAfter: lib.GetInstanceStorageRequeueDelay()}
+			result.RequeueAfter = lib.GetInstanceStorageRequeueDelay()
+		}
+	}
+
+	// Requeue the request when a pending attachment times out, since nothing else may change by then.
+	if !ctx.AttachmentDeadline.IsZero() {
+		if requeueAfter := time.Until(ctx.AttachmentDeadline); result.RequeueAfter == 0 || requeueAfter < result.RequeueAfter {
+			result.RequeueAfter = requeueAfter
+		}
+		if result.RequeueAfter <= 0 {
+			result.RequeueAfter = time.Second
 		}
 	}
 
-	return ctrl.Result{}
+	return result
 }
 
 func (r *Reconciler) ReconcileDelete(_ *context.VolumeContext) error {
@@ -234,7 +283,80 @@ func (r *Reconciler) ReconcileNormal(ctx *context.VolumeContext) error {
 		// Keep going to return aggregated error below.
 	}
 
-	return k8serrors.NewAggregate([]error{deleteErr, processErr})
+	resizeErr := r.reconcilePVCResize(ctx)
+	if resizeErr != nil {
+		ctx.Logger.Error(resizeErr, "Error getting PersistentVolumeClaims of VM volumes")
+	}
+
+	return k8serrors.NewAggregate([]error{deleteErr, processErr, resizeErr})
+}
+
+// reconcilePVCResize reflects the expansion of the PersistentVolumeClaims of the VM volumes in the
+// VirtualMachineVolumesResized condition. The storage provider expands the attached volume online once the
+// requested storage of the PVC is increased.
+func (r *Reconciler) reconcilePVCResize(ctx *context.VolumeContext) error {
+	var resizing, fileSystemResizePending []string
+
+	for _, volume := range ctx.VM.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		objKey := client.ObjectKey{Namespace: ctx.VM.Namespace, Name: volume.PersistentVolumeClaim.ClaimName}
+		if err := r.Get(ctx, objKey, pvc); err != nil {
+			if apiErrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		switch {
+		case pvcHasCondition(pvc, corev1.PersistentVolumeClaimFileSystemResizePending):
+			fileSystemResizePending = append(fileSystemResizePending, volume.Name)
+		case pvcHasCondition(pvc, corev1.PersistentVolumeClaimResizing), isPVCExpanding(pvc):
+			resizing = append(resizing, volume.Name)
+		}
+	}
+
+	switch {
+	case len(resizing) > 0:
+		conditions.MarkFalse(ctx.VM, vmopapi.VirtualMachineVolumesResizedCondition,
+			vmopapi.VirtualMachineVolumeResizingReason, vmopv1alpha1.ConditionSeverityInfo,
+			"Volumes %s are being expanded", strings.Join(resizing, ", "))
+	case len(fileSystemResizePending) > 0:
+		conditions.MarkFalse(ctx.VM, vmopapi.VirtualMachineVolumesResizedCondition,
+			vmopapi.VirtualMachineVolumeFileSystemResizePendingReason, vmopv1alpha1.ConditionSeverityInfo,
+			"File systems of volumes %s have to be resized", strings.Join(fileSystemResizePending, ", "))
+	case conditions.Has(ctx.VM, vmopapi.VirtualMachineVolumesResizedCondition):
+		conditions.MarkTrue(ctx.VM, vmopapi.VirtualMachineVolumesResizedCondition)
+	}
+
+	return nil
+}
+
+func pvcHasCondition(pvc *corev1.PersistentVolumeClaim, conditionType corev1.PersistentVolumeClaimConditionType) bool {
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// isPVCExpanding returns true if the requested storage of the bound PVC is more than its capacity.
+func isPVCExpanding(pvc *corev1.PersistentVolumeClaim) bool {
+	if pvc.Status.Phase != corev1.ClaimBound {
+		return false
+	}
+
+	capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+	if !ok {
+		return false
+	}
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+
+	return requested.Cmp(capacity) > 0
 }
 
 func (r *Reconciler) reconcileInstanceStoragePVCs(ctx *context.VolumeContext) (bool, error) {
@@ -249,6 +371,10 @@ func (r *Reconciler) reconcileInstanceStoragePVCs(ctx *context.VolumeContext) (b
 		return true, nil
 	}
 
+	if _, ok := ctx.VM.Annotations[vmopapi.InstanceStorageReplaceAnnotation]; ok {
+		return false, r.replaceInstanceStorage(ctx, isVolumes)
+	}
+
 	pvcList, getErrs := r.getInstanceStoragePVCs(ctx, isVolumes)
 	if getErrs != nil {
 		return false, k8serrors.NewAggregate(getErrs)
@@ -261,12 +387,23 @@ func (r *Reconciler) reconcileInstanceStoragePVCs(ctx *context.VolumeContext) (b
 
 	var (
 		stalePVCs  []client.ObjectKey
-		createErrs []error
+		hostPVCs   []corev1.PersistentVolumeClaim
+		attachErrs []error
 	)
 	existingVolumesMap := map[string]struct{}{}
 	failedVolumesMap := map[string]struct{}{}
 	boundCount := 0
 	selectedNode := ctx.VM.Annotations[constants.InstanceStorageSelectedNodeAnnotationKey]
+	var hostExcluded bool
+	if selectedNode != "" {
+		if exclusion := getExcludedInstanceStorageHost(ctx.VM, selectedNode); exclusion != nil {
+			// The volumes were replaced on this host, so do not place them on it again. Any PVCs on the host
+			// are deleted below as stale.
+			rejectExcludedInstanceStorageHost(ctx, selectedNode, exclusion)
+			selectedNode = ""
+			hostExcluded = true
+		}
+	}
 	createPVCs := len(selectedNode) > 0
 
 	for _, pvc := range pvcList {
@@ -297,6 +434,7 @@ func (r *Reconciler) reconcileInstanceStoragePVCs(ctx *context.VolumeContext) (b
 			stalePVCs = append(stalePVCs, client.ObjectKeyFromObject(&pvc))
 			continue
 		}
+		hostPVCs = append(hostPVCs, pvc)
 
 		if instanceStoragePVCFailed(&pvc) {
 			// This PVC is ours but has failed. This instance storage placement is doomed.
@@ -314,6 +452,11 @@ func (r *Reconciler) reconcileInstanceStoragePVCs(ctx *context.VolumeContext) (b
 	}
 
 	placementFailed := len(failedVolumesMap) > 0
+	fullyBound := boundCount == len(isVolumes)
+	if !hostExcluded {
+		updateInstanceStoragePlacementStatus(ctx, selectedNode, hostPVCs, placementFailed, fullyBound)
+	}
+
 	if placementFailed {
 		// Need to start placement over. PVCs successfully realized are recreated or
 		// retailed depending on the next host selection.
@@ -322,10 +465,9 @@ func (r *Reconciler) reconcileInstanceStoragePVCs(ctx *context.VolumeContext) (b
 
 	deleteErrs := r.deleteInstanceStoragePVCs(ctx, stalePVCs)
 	if createPVCs {
-		createErrs = r.createMissingInstanceStoragePVCs(ctx, isVolumes, existingVolumesMap, selectedNode)
+		attachErrs = r.createMissingInstanceStoragePVCs(ctx, isVolumes, existingVolumesMap, selectedNode)
 	}
 
-	fullyBound := boundCount == len(isVolumes)
 	if fullyBound {
 		// All of our instance storage volumes are bound. This is our final state.
 		ctx.VM.Annotations[constants.InstanceStoragePVCsBoundAnnotationKey] = lib.TrueString
@@ -338,7 +480,7 @@ func (r *Reconciler) reconcileInstanceStoragePVCs(ctx *context.VolumeContext) (b
 	//   1. (false, nil) if some or all PVCs not bound and all or some PVCs created.
 	//   2. (false, err) if some or all PVCs not bound and error occurs while deleting or creating PVCs.
 	//   3. (true, nil) if all PVCs are bound.
-	return fullyBound, k8serrors.NewAggregate(append(deleteErrs, createErrs...))
+	return fullyBound, k8serrors.NewAggregate(append(deleteErrs, attachErrs...))
 }
 
 func instanceStoragePVCFailed(pvc *corev1.PersistentVolumeClaim) bool {
@@ -380,15 +522,15 @@ func (r *Reconciler) createMissingInstanceStoragePVCs(
 	existingVolumesMap map[string]struct{},
 	selectedNode string) []error {
 
-	var createErrs []error
+	var attachErrs []error
 
 	for _, vol := range isVolumes {
 		if _, exists := existingVolumesMap[vol.Name]; !exists {
-			createErrs = append(createErrs, r.createInstanceStoragePVC(ctx, vol, selectedNode))
+			attachErrs = append(attachErrs, r.createInstanceStoragePVC(ctx, vol, selectedNode))
 		}
 	}
 
-	return createErrs
+	return attachErrs
 }
 
 func (r *Reconciler) createInstanceStoragePVC(
@@ -506,18 +648,25 @@ func (r *Reconciler) processAttachments(
 	attachments map[string]cnsv1alpha1.CnsNodeVmAttachment,
 	orphanedAttachments []cnsv1alpha1.CnsNodeVmAttachment) error {
 	var volumeStatus []vmopv1alpha1.VirtualMachineVolumeStatus
-	var createErrs []error
+	var attachErrs []error
+
+	// When the VM has volume placements, the PersistentVolumeClaim volumes are attached in order, so
+	// only one attachment is outstanding at a time. An attachment that fails or times out is reported
+	// and no longer blocks the later volumes. A placement that cannot be parsed is rejected by the
+	// webhook and reported by the VM provider.
+	placements, _ := vmopapi.GetVolumePlacements(ctx.VM)
+	attachInOrder := len(placements) > 0
+	attachmentPending := false
 
 	// Use Spec.Volumes order when attaching as a best effort to preserve spec order. There
 	// is no guarantee order will be preserved however, as the CNS attachment controller may
-	// not receive/process the requests in order. The nuclear option would be for us to only
-	// have one pending volume attachment outstanding per VM at a time.
+	// not receive/process the requests in order, unless the attachments are created in order
+	// because the VM has volume placements.
 	// Create() errors below may also result in attachments being out of the original spec
 	// order.
 	for _, volume := range ctx.VM.Spec.Volumes {
 		if volume.PersistentVolumeClaim == nil {
-			// Don't process VsphereVolumes here. Note that we don't have Volume status
-			// for Vsphere volumes, so there is nothing to preserve here.
+			// Don't process VsphereVolumes here. Their Volume status is preserved below.
 			continue
 		}
 
@@ -528,30 +677,73 @@ func (r *Reconciler) processAttachments(
 			// but the old code didn't and let's match that behavior until we need to do otherwise.
 			// Also, the CNS attachment controller doesn't reconcile Spec changes once the volume
 			// is attached.
-			volumeStatus = append(volumeStatus, attachmentToVolumeStatus(volume.Name, attachment))
+			status := attachmentToVolumeStatus(volume.Name, attachment)
+			if attachInOrder && !attachment.Status.Attached {
+				if err := attachmentFailed(attachment); err != nil {
+					if status.Error == "" {
+						status.Error = err.Error()
+					}
+					attachErrs = append(attachErrs, errors.Wrapf(err, "volume %s", volume.Name))
+				} else {
+					attachmentPending = true
+					setAttachmentDeadline(ctx, attachment.CreationTimestamp.Add(attachmentTimeout))
+				}
+			}
+			volumeStatus = append(volumeStatus, status)
+			continue
+		}
+
+		if attachInOrder && attachmentPending {
+			// The attachment is created once the attachments of the prior volumes are attached.
+			ctx.Logger.V(4).Info("Waiting for prior volumes to be attached", "volumeName", volume.Name)
 			continue
 		}
 
 		if err := r.createCNSAttachment(ctx, attachmentName, volume); err != nil {
-			createErrs = append(createErrs, errors.Wrap(err, "Cannot create CnsNodeVmAttachment"))
+			attachErrs = append(attachErrs, errors.Wrap(err, "Cannot create CnsNodeVmAttachment"))
 		} else {
 			// Add a placeholder Status entry for this volume. We'll populate it fully on a later
 			// reconcile after the CNS attachment controller updates it.
 			volumeStatus = append(volumeStatus, vmopv1alpha1.VirtualMachineVolumeStatus{Name: volume.Name})
+			if attachInOrder {
+				setAttachmentDeadline(ctx, time.Now().Add(attachmentTimeout))
+			}
 		}
+		attachmentPending = true
 	}
 
 	// Fix up the Volume Status so that attachments that are no longer referenced in the Spec but
 	// still exist are included in the Status. This is more than a little odd.
 	volumeStatus = append(volumeStatus, r.preserveOrphanedAttachmentStatus(ctx, orphanedAttachments)...)
 
+	// The Status of the VsphereVolumes is updated by the VM provider.
+	volumeStatus = append(volumeStatus, preserveVsphereVolumeStatus(ctx)...)
+
 	// This is how the previous code sorted, but IMO keeping in Spec order makes more sense.
 	sort.Slice(volumeStatus, func(i, j int) bool {
 		return volumeStatus[i].DiskUuid < volumeStatus[j].DiskUuid
 	})
 	ctx.VM.Status.Volumes = volumeStatus
 
-	return k8serrors.NewAggregate(createErrs)
+	return k8serrors.NewAggregate(attachErrs)
+}
+
+// attachmentFailed returns the error of an attachment that is not attached when it has an error, or when it has not
+// been attached within the attachmentTimeout.
+func attachmentFailed(attachment cnsv1alpha1.CnsNodeVmAttachment) error {
+	if attachment.Status.Error != "" {
+		return errors.Errorf("failed to attach: %s", sanitizeCNSErrorMessage(attachment.Status.Error))
+	}
+	if !attachment.CreationTimestamp.IsZero() && time.Since(attachment.CreationTimestamp.Time) > attachmentTimeout {
+		return errors.Errorf("volume was not attached within %s", attachmentTimeout)
+	}
+	return nil
+}
+
+func setAttachmentDeadline(ctx *context.VolumeContext, deadline time.Time) {
+	if ctx.AttachmentDeadline.IsZero() || deadline.Before(ctx.AttachmentDeadline) {
+		ctx.AttachmentDeadline = deadline
+	}
 }
 
 func (r *Reconciler) createCNSAttachment(
@@ -613,6 +805,25 @@ func (r *Reconciler) preserveOrphanedAttachmentStatus(
 	return volumeStatus
 }
 
+// preserveVsphereVolumeStatus returns the Status of the VsphereVolumes that are in the Spec.
+func preserveVsphereVolumeStatus(ctx *context.VolumeContext) []vmopv1alpha1.VirtualMachineVolumeStatus {
+	vsphereVolumes := map[string]struct{}{}
+	for _, volume := range ctx.VM.Spec.Volumes {
+		if volume.VsphereVolume != nil {
+			vsphereVolumes[volume.Name] = struct{}{}
+		}
+	}
+
+	var volumeStatus []vmopv1alpha1.VirtualMachineVolumeStatus
+	for _, volume := range ctx.VM.Status.Volumes {
+		if _, ok := vsphereVolumes[volume.Name]; ok {
+			volumeStatus = append(volumeStatus, volume)
+		}
+	}
+
+	return volumeStatus
+}
+
 func (r *Reconciler) attachmentsToDelete(
 	ctx *context.VolumeContext,
 	attachments map[string]cnsv1alpha1.CnsNodeVmAttachment) []cnsv1alpha1.CnsNodeVmAttachment {
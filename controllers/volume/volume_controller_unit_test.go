@@ -18,8 +18,10 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/controllers/volume"
 	cnsv1alpha1 "github.com/acharyasreej/vm-operator/external/vsphere-csi-driver/pkg/syncer/cnsoperator/apis/cnsnodevmattachment/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	volContext "github.com/acharyasreej/vm-operator/pkg/context"
 	"github.com/acharyasreej/vm-operator/pkg/lib"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
@@ -194,6 +196,27 @@ func unitTestsReconcile() {
 					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
 					expectPVCsStatus(volCtx, ctx, false, false, 0)
 				})
+
+				By("Placement failure is in the diagnostics", func() {
+					diagnostics, err := vmopapi.GetInstanceStoragePlacementDiagnostics(vm)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(diagnostics).ToNot(BeNil())
+					Expect(diagnostics.Attempts).To(HaveLen(1))
+					attempt := diagnostics.Attempts[0]
+					Expect(attempt.Host).To(Equal("selected-node.domain.com"))
+					Expect(attempt.Result).To(Equal(vmopapi.InstanceStoragePlacementFailed))
+					Expect(attempt.CompletionTime).ToNot(BeNil())
+					Expect(attempt.Volumes).To(HaveLen(1))
+					Expect(attempt.Volumes[0].ClaimName).To(Equal(vmVol.PersistentVolumeClaim.ClaimName))
+					Expect(attempt.Volumes[0].Error).To(Equal(constants.InstanceStorageNotEnoughResErr))
+					Expect(attempt.Reason).To(ContainSubstring(constants.InstanceStorageNotEnoughResErr))
+					Expect(volCtx.CompletedInstanceStoragePlacements).To(HaveLen(1))
+					Expect(volCtx.CompletedInstanceStoragePlacements[0].Result).To(Equal(vmopapi.InstanceStoragePlacementFailed))
+
+					Expect(conditions.IsFalse(vm, vmopapi.VirtualMachineInstanceStoragePlacedCondition)).To(BeTrue())
+					Expect(conditions.GetReason(vm, vmopapi.VirtualMachineInstanceStoragePlacedCondition)).To(Equal(vmopapi.InstanceStoragePlacementFailedReason))
+					Expect(conditions.GetMessage(vm, vmopapi.VirtualMachineInstanceStoragePlacedCondition)).To(ContainSubstring(constants.InstanceStorageNotEnoughResErr))
+				})
 			})
 
 			It("PVCs are created and realized", func() {
@@ -207,6 +230,94 @@ func unitTestsReconcile() {
 					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
 					expectPVCsStatus(volCtx, ctx, true, true, len(vm.Spec.Volumes))
 				})
+
+				By("Placement is in the diagnostics", func() {
+					diagnostics, err := vmopapi.GetInstanceStoragePlacementDiagnostics(vm)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(diagnostics).ToNot(BeNil())
+					Expect(diagnostics.Attempts).To(HaveLen(1))
+					Expect(diagnostics.Attempts[0].Result).To(Equal(vmopapi.InstanceStoragePlacementPlaced))
+					Expect(conditions.IsTrue(vm, vmopapi.VirtualMachineInstanceStoragePlacedCondition)).To(BeTrue())
+					Expect(volCtx.CompletedInstanceStoragePlacements).To(HaveLen(1))
+					Expect(volCtx.CompletedInstanceStoragePlacements[0].Result).To(Equal(vmopapi.InstanceStoragePlacementPlaced))
+				})
+			})
+
+			When("the instance storage is replaced", func() {
+				JustBeforeEach(func() {
+					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
+					patchInstanceStoragePVCs(volCtx, ctx, true, false)
+					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
+					expectPVCsStatus(volCtx, ctx, true, true, len(vm.Spec.Volumes))
+
+					vm.Annotations[vmopapi.InstanceStorageReplaceAnnotation] = "host maintenance"
+				})
+
+				It("waits for the VM to be powered off", func() {
+					vm.Status.PowerState = vmopv1alpha1.VirtualMachinePoweredOn
+					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
+
+					Expect(vm.Annotations).To(HaveKey(vmopapi.InstanceStorageReplaceAnnotation))
+					expectPVCsStatus(volCtx, ctx, true, true, len(vm.Spec.Volumes))
+					Expect(conditions.GetReason(vm, vmopapi.VirtualMachineInstanceStoragePlacedCondition)).To(Equal(vmopapi.InstanceStorageReplacingReason))
+				})
+
+				It("deletes the PVCs of the powered off VM", func() {
+					vm.Status.PowerState = vmopv1alpha1.VirtualMachinePoweredOff
+					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
+
+					Expect(vm.Annotations).ToNot(HaveKey(vmopapi.InstanceStorageReplaceAnnotation))
+					expectPVCsStatus(volCtx, ctx, false, false, 0)
+					Expect(conditions.GetReason(vm, vmopapi.VirtualMachineInstanceStoragePlacedCondition)).To(Equal(vmopapi.InstanceStorageReplacingReason))
+
+					diagnostics, err := vmopapi.GetInstanceStoragePlacementDiagnostics(vm)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(diagnostics).ToNot(BeNil())
+					Expect(diagnostics.Attempts).To(HaveLen(1))
+					Expect(diagnostics.Attempts[0].Host).To(Equal("selected-node.domain.com"))
+					Expect(diagnostics.Attempts[0].Result).To(Equal(vmopapi.InstanceStoragePlacementReplaced))
+					Expect(diagnostics.Attempts[0].Reason).To(Equal("host maintenance"))
+					Expect(diagnostics.ExcludedHosts).To(HaveLen(1))
+					Expect(diagnostics.ExcludedHosts[0].Host).To(Equal("selected-node.domain.com"))
+					Expect(diagnostics.ExcludedHosts[0].Reason).To(Equal("host maintenance"))
+					Expect(diagnostics.ExcludedHosts[0].ExpirationTime.Time).To(BeTemporally("~",
+						time.Now().Add(vmopapi.InstanceStorageHostExclusionDuration), time.Minute))
+				})
+
+				It("rejects the replaced host when it is selected again", func() {
+					vm.Status.PowerState = vmopv1alpha1.VirtualMachinePoweredOff
+					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
+
+					vm.Annotations[constants.InstanceStorageSelectedNodeAnnotationKey] = "selected-node.domain.com"
+					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
+
+					Expect(vm.Annotations).ToNot(HaveKey(constants.InstanceStorageSelectedNodeAnnotationKey))
+					expectPVCsStatus(volCtx, ctx, false, false, 0)
+					Expect(conditions.GetReason(vm, vmopapi.VirtualMachineInstanceStoragePlacedCondition)).To(Equal(vmopapi.InstanceStoragePlacementFailedReason))
+
+					diagnostics, err := vmopapi.GetInstanceStoragePlacementDiagnostics(vm)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(diagnostics.Attempts).To(HaveLen(2))
+					Expect(diagnostics.Attempts[1].Host).To(Equal("selected-node.domain.com"))
+					Expect(diagnostics.Attempts[1].Result).To(Equal(vmopapi.InstanceStoragePlacementExcluded))
+					Expect(diagnostics.Attempts[1].Reason).To(ContainSubstring("host maintenance"))
+				})
+
+				It("does not reject the replaced host once its exclusion expired", func() {
+					vm.Status.PowerState = vmopv1alpha1.VirtualMachinePoweredOff
+					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
+
+					diagnostics, err := vmopapi.GetInstanceStoragePlacementDiagnostics(vm)
+					Expect(err).ToNot(HaveOccurred())
+					diagnostics.ExcludedHosts[0].ExpirationTime = metav1.NewTime(time.Now().Add(-time.Minute))
+					Expect(instancestorage.SetPlacementDiagnostics(vm, diagnostics)).To(Succeed())
+
+					vm.Annotations[constants.InstanceStorageSelectedNodeAnnotationKey] = "selected-node.domain.com"
+					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
+
+					Expect(vm.Annotations).To(HaveKeyWithValue(constants.InstanceStorageSelectedNodeAnnotationKey, "selected-node.domain.com"))
+					Expect(conditions.GetReason(vm, vmopapi.VirtualMachineInstanceStoragePlacedCondition)).To(Equal(vmopapi.InstanceStoragePlacementPendingReason))
+				})
 			})
 		})
 
@@ -278,6 +389,115 @@ func unitTestsReconcile() {
 			})
 		})
 
+		When("VM Status.Volumes contains Vsphere volume", func() {
+			BeforeEach(func() {
+				vm.Spec.Volumes = append(vm.Spec.Volumes, *vmVolumeWithVsphere)
+				vm.Status.Volumes = append(vm.Status.Volumes, vmopv1alpha1.VirtualMachineVolumeStatus{
+					Name:     vmVolumeWithVsphere.Name,
+					Attached: true,
+					Error:    "failed to expand disk",
+				})
+			})
+
+			It("preserves the Vsphere volume status", func() {
+				err := reconciler.ReconcileNormal(volCtx)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(vm.Status.Volumes).To(HaveLen(1))
+				Expect(vm.Status.Volumes[0].Name).To(Equal(vmVolumeWithVsphere.Name))
+				Expect(vm.Status.Volumes[0].Error).To(Equal("failed to expand disk"))
+			})
+
+			When("the Vsphere volume is removed from the Spec", func() {
+				BeforeEach(func() {
+					vm.Spec.Volumes = nil
+				})
+
+				It("removes the Vsphere volume status", func() {
+					err := reconciler.ReconcileNormal(volCtx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(vm.Status.Volumes).To(BeEmpty())
+				})
+			})
+		})
+
+		When("PVC of CNS volume is being expanded", func() {
+			var pvc *corev1.PersistentVolumeClaim
+
+			BeforeEach(func() {
+				vmVol = *vmVolumeWithPVC1
+				vm.Spec.Volumes = append(vm.Spec.Volumes, vmVol)
+
+				pvc = &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      vmVol.PersistentVolumeClaim.ClaimName,
+						Namespace: vm.Namespace,
+					},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceStorage: resource.MustParse("20Gi"),
+							},
+						},
+					},
+					Status: corev1.PersistentVolumeClaimStatus{
+						Phase: corev1.ClaimBound,
+						Capacity: corev1.ResourceList{
+							corev1.ResourceStorage: resource.MustParse("10Gi"),
+						},
+					},
+				}
+			})
+
+			JustBeforeEach(func() {
+				Expect(ctx.Client.Create(ctx, pvc)).To(Succeed())
+			})
+
+			It("marks the VirtualMachineVolumesResized condition as resizing", func() {
+				err := reconciler.ReconcileNormal(volCtx)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(conditions.IsFalse(vm, vmopapi.VirtualMachineVolumesResizedCondition)).To(BeTrue())
+				Expect(conditions.GetReason(vm, vmopapi.VirtualMachineVolumesResizedCondition)).To(Equal(vmopapi.VirtualMachineVolumeResizingReason))
+				Expect(conditions.GetMessage(vm, vmopapi.VirtualMachineVolumesResizedCondition)).To(ContainSubstring(vmVol.Name))
+			})
+
+			When("the file system of the PVC has to be resized", func() {
+				BeforeEach(func() {
+					pvc.Status.Capacity[corev1.ResourceStorage] = resource.MustParse("20Gi")
+					pvc.Status.Conditions = []corev1.PersistentVolumeClaimCondition{
+						{
+							Type:   corev1.PersistentVolumeClaimFileSystemResizePending,
+							Status: corev1.ConditionTrue,
+						},
+					}
+				})
+
+				It("marks the VirtualMachineVolumesResized condition as file system resize pending", func() {
+					err := reconciler.ReconcileNormal(volCtx)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(conditions.GetReason(vm, vmopapi.VirtualMachineVolumesResizedCondition)).To(Equal(vmopapi.VirtualMachineVolumeFileSystemResizePendingReason))
+				})
+			})
+
+			When("the PVC was expanded", func() {
+				BeforeEach(func() {
+					pvc.Status.Capacity[corev1.ResourceStorage] = resource.MustParse("20Gi")
+				})
+
+				It("marks the VirtualMachineVolumesResized condition as true only when it was present", func() {
+					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
+					Expect(conditions.Has(vm, vmopapi.VirtualMachineVolumesResizedCondition)).To(BeFalse())
+
+					conditions.MarkFalse(vm, vmopapi.VirtualMachineVolumesResizedCondition,
+						vmopapi.VirtualMachineVolumeResizingReason, vmopv1alpha1.ConditionSeverityInfo, "")
+					Expect(reconciler.ReconcileNormal(volCtx)).To(Succeed())
+					Expect(conditions.IsTrue(vm, vmopapi.VirtualMachineVolumesResizedCondition)).To(BeTrue())
+				})
+			})
+		})
+
 		When("VM Spec.Volumes has CNS volume", func() {
 			BeforeEach(func() {
 				vmVol = *vmVolumeWithPVC1
@@ -394,6 +614,107 @@ FaultMessage: ([]types.LocalizableMessage) \u003cnil\u003e\\n }\\n },\\n Type: (
 			})
 		})
 
+		When("VM has volume placements", func() {
+			var vmVol1 vmopv1alpha1.VirtualMachineVolume
+			var vmVol2 vmopv1alpha1.VirtualMachineVolume
+
+			BeforeEach(func() {
+				vmVol1 = *vmVolumeWithPVC1
+				vmVol2 = *vmVolumeWithPVC2
+				vsphereVol := vmopv1alpha1.VirtualMachineVolume{
+					Name:          "vsphere-volume",
+					VsphereVolume: &vmopv1alpha1.VsphereVolumeSource{},
+				}
+				vm.Spec.Volumes = append(vm.Spec.Volumes, vsphereVol, vmVol1, vmVol2)
+				vm.Annotations = map[string]string{
+					vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "vsphere-volume", "unitNumber": 1}]`,
+				}
+			})
+
+			It("creates one CnsNodeVmAttachment at a time in Spec.Volumes order", func() {
+				err := reconciler.ReconcileNormal(volCtx)
+				Expect(err).ToNot(HaveOccurred())
+
+				attachment1 := getCNSAttachmentForVolumeName(vm, vmVol1.Name)
+				Expect(attachment1).ToNot(BeNil())
+				assertAttachmentSpecFromVMVol(vm, vmVol1, attachment1)
+				Expect(getCNSAttachmentForVolumeName(vm, vmVol2.Name)).To(BeNil())
+
+				Expect(vm.Status.Volumes).To(HaveLen(1))
+				Expect(vm.Status.Volumes[0].Name).To(Equal(vmVol1.Name))
+
+				By("Reconciling again while the first volume is not attached", func() {
+					err := reconciler.ReconcileNormal(volCtx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(getCNSAttachmentForVolumeName(vm, vmVol2.Name)).To(BeNil())
+				})
+
+				By("Reconciling again once the first volume is attached", func() {
+					attachment1.Status.Attached = true
+					attachment1.Status.AttachmentMetadata = map[string]string{
+						volume.AttributeFirstClassDiskUUID: dummyDiskUUID,
+					}
+					Expect(ctx.Client.Update(ctx, attachment1)).To(Succeed())
+
+					err := reconciler.ReconcileNormal(volCtx)
+					Expect(err).ToNot(HaveOccurred())
+
+					attachment2 := getCNSAttachmentForVolumeName(vm, vmVol2.Name)
+					Expect(attachment2).ToNot(BeNil())
+					assertAttachmentSpecFromVMVol(vm, vmVol2, attachment2)
+					Expect(vm.Status.Volumes).To(HaveLen(2))
+				})
+			})
+
+			It("requeues the request when the pending attachment times out", func() {
+				err := reconciler.ReconcileNormal(volCtx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(volCtx.AttachmentDeadline).ToNot(BeZero())
+				Expect(volCtx.AttachmentDeadline).To(BeTemporally("~", time.Now().Add(5*time.Minute), time.Minute))
+			})
+
+			It("returns an error and creates the next CnsNodeVmAttachment when the attachment fails", func() {
+				err := reconciler.ReconcileNormal(volCtx)
+				Expect(err).ToNot(HaveOccurred())
+
+				attachment1 := getCNSAttachmentForVolumeName(vm, vmVol1.Name)
+				Expect(attachment1).ToNot(BeNil())
+				attachment1.Status.Error = "attach failed"
+				Expect(ctx.Client.Update(ctx, attachment1)).To(Succeed())
+
+				err = reconciler.ReconcileNormal(volCtx)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("volume cns-volume-1: failed to attach: attach failed"))
+
+				attachment2 := getCNSAttachmentForVolumeName(vm, vmVol2.Name)
+				Expect(attachment2).ToNot(BeNil())
+				assertAttachmentSpecFromVMVol(vm, vmVol2, attachment2)
+
+				Expect(vm.Status.Volumes).To(HaveLen(2))
+				Expect(vm.Status.Volumes[0].Name).To(Equal(vmVol1.Name))
+				Expect(vm.Status.Volumes[0].Error).To(Equal("attach failed"))
+			})
+
+			It("returns an error and creates the next CnsNodeVmAttachment when the attachment times out", func() {
+				err := reconciler.ReconcileNormal(volCtx)
+				Expect(err).ToNot(HaveOccurred())
+
+				attachment1 := getCNSAttachmentForVolumeName(vm, vmVol1.Name)
+				Expect(attachment1).ToNot(BeNil())
+				attachment1.CreationTimestamp = metav1.NewTime(time.Now().Add(-10 * time.Minute))
+				Expect(ctx.Client.Update(ctx, attachment1)).To(Succeed())
+
+				err = reconciler.ReconcileNormal(volCtx)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("volume cns-volume-1: volume was not attached within 5m0s"))
+
+				Expect(getCNSAttachmentForVolumeName(vm, vmVol2.Name)).ToNot(BeNil())
+				Expect(vm.Status.Volumes).To(HaveLen(2))
+				Expect(vm.Status.Volumes[0].Name).To(Equal(vmVol1.Name))
+				Expect(vm.Status.Volumes[0].Error).To(Equal("volume was not attached within 5m0s"))
+			})
+		})
+
 		When("VM Status.Volumes is sorted as expected", func() {
 			var vmVol1 vmopv1alpha1.VirtualMachineVolume
 			var vmVol2 vmopv1alpha1.VirtualMachineVolume
@@ -0,0 +1,240 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package volume
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8serrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	cnsv1alpha1 "github.com/acharyasreej/vm-operator/external/vsphere-csi-driver/pkg/syncer/cnsoperator/apis/cnsnodevmattachment/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/metrics"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/instancestorage"
+)
+
+// replaceInstanceStorage deletes the PVCs and the CnsNodeVmAttachments of the instance storage volumes of the
+// powered off VM, so that they are placed again once a new host is selected. The replace annotation is removed
+// once everything is deleted.
+func (r *Reconciler) replaceInstanceStorage(
+	ctx *context.VolumeContext,
+	isVolumes []vmopv1alpha1.VirtualMachineVolume) error {
+
+	if ctx.VM.Status.PowerState != vmopv1alpha1.VirtualMachinePoweredOff {
+		conditions.MarkFalse(ctx.VM, vmopapi.VirtualMachineInstanceStoragePlacedCondition,
+			vmopapi.InstanceStorageReplacingReason, vmopv1alpha1.ConditionSeverityInfo,
+			"Waiting for the VM to be powered off to replace the instance storage volumes")
+		return nil
+	}
+
+	ctx.Logger.Info("Replacing instance storage volumes",
+		"host", ctx.VM.Annotations[constants.InstanceStorageSelectedNodeAnnotationKey])
+
+	var objKeys []client.ObjectKey
+	var errs []error
+	for _, vol := range isVolumes {
+		objKeys = append(objKeys, client.ObjectKey{Namespace: ctx.VM.Namespace, Name: vol.PersistentVolumeClaim.ClaimName})
+
+		attachment := &cnsv1alpha1.CnsNodeVmAttachment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      CNSAttachmentNameForVolume(ctx.VM, vol.Name),
+				Namespace: ctx.VM.Namespace,
+			},
+		}
+		if err := r.Delete(ctx, attachment); err != nil && !apiErrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, r.deleteInstanceStoragePVCs(ctx, objKeys)...)
+
+	conditions.MarkFalse(ctx.VM, vmopapi.VirtualMachineInstanceStoragePlacedCondition,
+		vmopapi.InstanceStorageReplacingReason, vmopv1alpha1.ConditionSeverityInfo,
+		"Instance storage volumes are being replaced")
+
+	if err := k8serrors.NewAggregate(errs); err != nil {
+		return err
+	}
+
+	// Exclude the replaced host from the next placements, since it is likely to be unavailable, like when it
+	// entered maintenance mode.
+	host := ctx.VM.Annotations[constants.InstanceStorageSelectedNodeAnnotationKey]
+	reason := ctx.VM.Annotations[vmopapi.InstanceStorageReplaceAnnotation]
+	if reason == "" {
+		reason = "instance storage volumes were replaced"
+	}
+	if err := instancestorage.UpdatePlacementDiagnostics(ctx.VM, func(diagnostics *vmopapi.InstanceStoragePlacementDiagnostics) {
+		now := metav1.Now()
+		diagnostics.PruneExcludedHosts(now.Time)
+		if host != "" {
+			diagnostics.ExcludeHost(host, reason, now.Time)
+		}
+		if n := len(diagnostics.Attempts); n > 0 {
+			attempt := &diagnostics.Attempts[n-1]
+			if attempt.CompletionTime == nil || attempt.Result == vmopapi.InstanceStoragePlacementPlaced {
+				attempt.Result = vmopapi.InstanceStoragePlacementReplaced
+				attempt.Reason = reason
+				attempt.CompletionTime = &now
+			}
+		}
+	}); err != nil {
+		ctx.Logger.Error(err, "Failed to update instance storage placement diagnostics")
+	}
+
+	delete(ctx.VM.Annotations, constants.InstanceStorageSelectedNodeAnnotationKey)
+	delete(ctx.VM.Annotations, constants.InstanceStoragePVCsBoundAnnotationKey)
+	delete(ctx.VM.Annotations, vmopapi.InstanceStorageReplaceAnnotation)
+
+	return nil
+}
+
+// getExcludedInstanceStorageHost returns the exclusion of the host from the placement of the instance storage
+// volumes of the VM, or nil if the host is not excluded.
+func getExcludedInstanceStorageHost(vm *vmopv1alpha1.VirtualMachine, host string) *vmopapi.InstanceStorageExcludedHost {
+	diagnostics, err := vmopapi.GetInstanceStoragePlacementDiagnostics(vm)
+	if err != nil {
+		return nil
+	}
+	return diagnostics.GetExcludedHost(host, time.Now())
+}
+
+// rejectExcludedInstanceStorageHost records that the selected host was rejected since it is excluded, and tells
+// the VM controller to compute placement again. The VM placement does not select excluded hosts, so this only
+// happens when the host was selected by something else.
+func rejectExcludedInstanceStorageHost(
+	ctx *context.VolumeContext,
+	selectedNode string,
+	exclusion *vmopapi.InstanceStorageExcludedHost) {
+
+	ctx.Logger.Info("Rejecting excluded host for instance storage volumes", "host", selectedNode)
+
+	reason := fmt.Sprintf("the host is excluded until %s", exclusion.ExpirationTime.UTC().Format(time.RFC3339))
+	if exclusion.Reason != "" {
+		reason += ": " + exclusion.Reason
+	}
+
+	if err := instancestorage.UpdatePlacementDiagnostics(ctx.VM, func(diagnostics *vmopapi.InstanceStoragePlacementDiagnostics) {
+		now := metav1.Now()
+		instancestorage.AppendPlacementAttempt(diagnostics, vmopapi.InstanceStoragePlacementAttempt{
+			Host:           selectedNode,
+			StartTime:      now,
+			CompletionTime: &now,
+			Result:         vmopapi.InstanceStoragePlacementExcluded,
+			Reason:         reason,
+		})
+	}); err != nil {
+		ctx.Logger.Error(err, "Failed to update instance storage placement diagnostics")
+	}
+
+	delete(ctx.VM.Annotations, constants.InstanceStorageSelectedNodeAnnotationKey)
+
+	conditions.MarkFalse(ctx.VM, vmopapi.VirtualMachineInstanceStoragePlacedCondition,
+		vmopapi.InstanceStoragePlacementFailedReason, vmopv1alpha1.ConditionSeverityWarning,
+		"Host %s was rejected since %s; placing again", selectedNode, reason)
+}
+
+// updateInstanceStoragePlacementStatus records the placement of the instance storage PVCs on the selected host
+// in the placement diagnostics annotation and in the VirtualMachineInstanceStoragePlaced condition. An attempt
+// that completes is added to the context, so its metrics are recorded once the VM is patched.
+func updateInstanceStoragePlacementStatus(
+	ctx *context.VolumeContext,
+	selectedNode string,
+	hostPVCs []corev1.PersistentVolumeClaim,
+	placementFailed, fullyBound bool) {
+
+	if selectedNode == "" {
+		conditions.MarkFalse(ctx.VM, vmopapi.VirtualMachineInstanceStoragePlacedCondition,
+			vmopapi.InstanceStoragePlacementPendingReason, vmopv1alpha1.ConditionSeverityInfo,
+			"Waiting for a host to be selected")
+		return
+	}
+
+	diagnostics, err := vmopapi.GetInstanceStoragePlacementDiagnostics(ctx.VM)
+	if err != nil || diagnostics == nil {
+		// Start over when the annotation was mangled.
+		diagnostics = &vmopapi.InstanceStoragePlacementDiagnostics{}
+	}
+
+	var attempt *vmopapi.InstanceStoragePlacementAttempt
+	if n := len(diagnostics.Attempts); n > 0 {
+		last := &diagnostics.Attempts[n-1]
+		if last.Host == selectedNode &&
+			(last.Result == vmopapi.InstanceStoragePlacementPending || last.Result == vmopapi.InstanceStoragePlacementPlaced) {
+			attempt = last
+		}
+	}
+	if attempt == nil {
+		attempt = instancestorage.AppendPlacementAttempt(diagnostics, vmopapi.InstanceStoragePlacementAttempt{
+			Host:      selectedNode,
+			StartTime: metav1.Now(),
+			Result:    vmopapi.InstanceStoragePlacementPending,
+		})
+	}
+
+	var rejections []string
+	attempt.Volumes = nil
+	for _, pvc := range hostPVCs {
+		volume := vmopapi.InstanceStorageVolumePlacement{ClaimName: pvc.Name}
+		// The storage provider sets the annotation to the storage pool of the volume, or to the placement error.
+		storagePool := pvc.Annotations[constants.InstanceStoragePVPlacementErrorAnnotationKey]
+		if strings.HasPrefix(storagePool, constants.InstanceStoragePVPlacementErrorPrefix) {
+			volume.Error = storagePool
+			rejections = append(rejections, fmt.Sprintf("%s: %s", pvc.Name, storagePool))
+		} else {
+			volume.StoragePool = storagePool
+		}
+		attempt.Volumes = append(attempt.Volumes, volume)
+	}
+
+	if attempt.Result == vmopapi.InstanceStoragePlacementPending && (placementFailed || fullyBound) {
+		now := metav1.Now()
+		attempt.CompletionTime = &now
+		attempt.Result = vmopapi.InstanceStoragePlacementPlaced
+		if placementFailed {
+			attempt.Result = vmopapi.InstanceStoragePlacementFailed
+			attempt.Reason = strings.Join(rejections, ", ")
+		}
+		ctx.CompletedInstanceStoragePlacements = append(ctx.CompletedInstanceStoragePlacements, *attempt)
+	}
+
+	if err := instancestorage.SetPlacementDiagnostics(ctx.VM, diagnostics); err != nil {
+		ctx.Logger.Error(err, "Failed to set instance storage placement diagnostics")
+	}
+
+	switch {
+	case fullyBound:
+		conditions.MarkTrue(ctx.VM, vmopapi.VirtualMachineInstanceStoragePlacedCondition)
+	case len(rejections) > 0:
+		msg := "will be retried"
+		if placementFailed {
+			msg = "placing again"
+		}
+		conditions.MarkFalse(ctx.VM, vmopapi.VirtualMachineInstanceStoragePlacedCondition,
+			vmopapi.InstanceStoragePlacementFailedReason, vmopv1alpha1.ConditionSeverityWarning,
+			"Host %s was rejected for %s; %s", selectedNode, strings.Join(rejections, ", "), msg)
+	default:
+		conditions.MarkFalse(ctx.VM, vmopapi.VirtualMachineInstanceStoragePlacedCondition,
+			vmopapi.InstanceStoragePlacementPendingReason, vmopv1alpha1.ConditionSeverityInfo,
+			"Waiting for the PVCs to be bound on host %s", selectedNode)
+	}
+}
+
+// observeInstanceStoragePlacements records the metrics of the placement attempts that completed in the reconcile.
+// It is called once the VM is patched, so an attempt whose completion was not persisted is not recorded twice.
+func observeInstanceStoragePlacements(ctx *context.VolumeContext) {
+	for _, attempt := range ctx.CompletedInstanceStoragePlacements {
+		metrics.ObserveInstanceStoragePlacement(string(attempt.Result), attempt.CompletionTime.Sub(attempt.StartTime.Time))
+	}
+	ctx.CompletedInstanceStoragePlacements = nil
+}
@@ -11,6 +11,7 @@ require (
 	github.com/onsi/ginkgo v1.16.4
 	github.com/onsi/gomega v1.13.0
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.11.0
 	github.com/acharyasreej/vm-operator-api v0.1.4-0.20211202185235-43eb44c09ecd
 	github.com/acharyasreej/vm-operator/external/ncp v0.0.0-00010101000000-000000000000
 	github.com/acharyasreej/vm-operator/external/tanzu-topology v0.0.0-00010101000000-000000000000
@@ -0,0 +1,24 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+)
+
+// SubscribedContentLibraryContext is the context used for SubscribedContentLibraryControllers.
+type SubscribedContentLibraryContext struct {
+	context.Context
+	Logger  logr.Logger
+	Library *vmopapi.SubscribedContentLibrary
+}
+
+func (v *SubscribedContentLibraryContext) String() string {
+	return fmt.Sprintf("%s %s/%s", v.Library.GroupVersionKind(), v.Library.Namespace, v.Library.Name)
+}
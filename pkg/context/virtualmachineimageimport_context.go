@@ -0,0 +1,24 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+)
+
+// VirtualMachineImageImportContext is the context used for VirtualMachineImageImportControllers.
+type VirtualMachineImageImportContext struct {
+	context.Context
+	Logger logr.Logger
+	Import *vmopapi.VirtualMachineImageImport
+}
+
+func (v *VirtualMachineImageImportContext) String() string {
+	return fmt.Sprintf("%s %s/%s", v.Import.GroupVersionKind(), v.Import.Namespace, v.Import.Name)
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+)
+
+// VirtualMachinePublishRequestContext is the context used for VirtualMachinePublishRequestControllers.
+type VirtualMachinePublishRequestContext struct {
+	context.Context
+	Logger         logr.Logger
+	PublishRequest *vmopapi.VirtualMachinePublishRequest
+	VM             *vmopv1alpha1.VirtualMachine
+}
+
+func (v *VirtualMachinePublishRequestContext) String() string {
+	return fmt.Sprintf("%s %s/%s", v.PublishRequest.GroupVersionKind(), v.PublishRequest.Namespace, v.PublishRequest.Name)
+}
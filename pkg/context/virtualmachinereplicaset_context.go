@@ -0,0 +1,24 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+)
+
+// VirtualMachineReplicaSetContext is the context used for VirtualMachineReplicaSetControllers.
+type VirtualMachineReplicaSetContext struct {
+	context.Context
+	Logger     logr.Logger
+	ReplicaSet *vmopapi.VirtualMachineReplicaSet
+}
+
+func (v *VirtualMachineReplicaSetContext) String() string {
+	return fmt.Sprintf("%s %s/%s", v.ReplicaSet.GroupVersionKind(), v.ReplicaSet.Namespace, v.ReplicaSet.Name)
+}
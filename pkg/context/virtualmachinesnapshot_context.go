@@ -0,0 +1,27 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+)
+
+// VirtualMachineSnapshotContext is the context used for VirtualMachineSnapshotControllers.
+type VirtualMachineSnapshotContext struct {
+	context.Context
+	Logger   logr.Logger
+	Snapshot *vmopapi.VirtualMachineSnapshot
+	VM       *vmopv1alpha1.VirtualMachine
+}
+
+func (v *VirtualMachineSnapshotContext) String() string {
+	return fmt.Sprintf("%s %s/%s", v.Snapshot.GroupVersionKind(), v.Snapshot.Namespace, v.Snapshot.Name)
+}
@@ -6,10 +6,13 @@ package context
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 
 	vmopv1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 )
 
 // VolumeContext is the context used for VolumeController.
@@ -18,6 +21,14 @@ type VolumeContext struct {
 	Logger                    logr.Logger
 	VM                        *vmopv1.VirtualMachine
 	InstanceStorageFSSEnabled bool
+
+	// AttachmentDeadline is when the earliest pending attachment, that the attachments of the later volumes
+	// wait for, times out. It is zero when no attachment is waited for.
+	AttachmentDeadline time.Time
+
+	// CompletedInstanceStoragePlacements are the instance storage placement attempts that completed in this
+	// reconcile. Their metrics are recorded once the VM is patched.
+	CompletedInstanceStoragePlacements []vmopapi.InstanceStoragePlacementAttempt
 }
 
 func (v *VolumeContext) String() string {
@@ -0,0 +1,79 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package indexer
+
+import (
+	goctx "context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmgr "sigs.k8s.io/controller-runtime/pkg/manager"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+)
+
+const (
+	// VirtualMachineMoIDField is the field index of the VirtualMachines by the managed object ID of their
+	// vSphere VM, that is their status.uniqueID and their ImportVMMoIDAnnotation.
+	VirtualMachineMoIDField = "vmoperator.vmware.com/vm-moid"
+
+	// VirtualMachineInstanceUUIDField is the field index of the VirtualMachines by the instance UUID of their
+	// vSphere VM, that is their status.instanceUUID and their ImportVMInstanceUUIDAnnotation.
+	VirtualMachineInstanceUUIDField = "vmoperator.vmware.com/vm-instance-uuid"
+
+	// VirtualMachineReplicaSetImageNameField is the field index of the VirtualMachineReplicaSets by the image
+	// name of their template.
+	VirtualMachineReplicaSetImageNameField = "vmoperator.vmware.com/replicaset-image-name"
+)
+
+// AddToManager adds the field indexes to the manager's cache.
+func AddToManager(ctx goctx.Context, mgr ctrlmgr.Manager) error {
+	indexer := mgr.GetFieldIndexer()
+
+	if err := indexer.IndexField(ctx, &vmopv1alpha1.VirtualMachine{}, VirtualMachineMoIDField,
+		func(obj client.Object) []string {
+			vm := obj.(*vmopv1alpha1.VirtualMachine)
+			return nonEmpty(vm.Status.UniqueID, vm.Annotations[vmopapi.ImportVMMoIDAnnotation])
+		}); err != nil {
+		return errors.Wrapf(err, "failed to add %s index", VirtualMachineMoIDField)
+	}
+
+	if err := indexer.IndexField(ctx, &vmopv1alpha1.VirtualMachine{}, VirtualMachineInstanceUUIDField,
+		func(obj client.Object) []string {
+			vm := obj.(*vmopv1alpha1.VirtualMachine)
+			return nonEmpty(vm.Status.InstanceUUID, vm.Annotations[vmopapi.ImportVMInstanceUUIDAnnotation])
+		}); err != nil {
+		return errors.Wrapf(err, "failed to add %s index", VirtualMachineInstanceUUIDField)
+	}
+
+	if err := indexer.IndexField(ctx, &vmopapi.VirtualMachineReplicaSet{}, VirtualMachineReplicaSetImageNameField,
+		func(obj client.Object) []string {
+			rs := obj.(*vmopapi.VirtualMachineReplicaSet)
+			return nonEmpty(rs.Spec.Template.Spec.ImageName)
+		}); err != nil {
+		return errors.Wrapf(err, "failed to add %s index", VirtualMachineReplicaSetImageNameField)
+	}
+
+	return nil
+}
+
+// nonEmpty returns the values that are not empty, without duplicates.
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		dup := false
+		for _, o := range out {
+			dup = dup || o == v
+		}
+		if !dup {
+			out = append(out, v)
+		}
+	}
+	return out
+}
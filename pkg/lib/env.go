@@ -45,6 +45,25 @@ const (
 	InstanceStorageSeedRequeueDurationEnv = "INSTANCE_STORAGE_SEED_REQUEUE_DURATION"
 	// DefaultInstanceStorageSeedRequeueDuration is the default seed requeue duration for instance storage.
 	DefaultInstanceStorageSeedRequeueDuration = 10 * time.Second
+
+	// ContentLibraryOvfCacheDirEnv is environment variable for setting the directory in which the OVF
+	// envelopes of content library items are cached. OVF envelopes are only cached in memory when not set.
+	ContentLibraryOvfCacheDirEnv = "CONTENT_LIBRARY_OVF_CACHE_DIR"
+	// ContentLibraryPollIntervalEnv is environment variable for setting the interval at which content
+	// libraries are polled for changes. Content libraries are not polled when not set.
+	ContentLibraryPollIntervalEnv = "CONTENT_LIBRARY_POLL_INTERVAL"
+
+	// ImageRetentionCountEnv is environment variable for setting the number of newest versions of each image
+	// family that are kept. Unused images are not garbage collected when not set.
+	ImageRetentionCountEnv = "IMAGE_RETENTION_COUNT"
+	// ImageRetentionGracePeriodEnv is environment variable for setting how long an image is unused before it
+	// is garbage collected.
+	ImageRetentionGracePeriodEnv = "IMAGE_RETENTION_GRACE_PERIOD"
+	// DefaultImageRetentionGracePeriod is the default grace period of unused images.
+	DefaultImageRetentionGracePeriod = 24 * time.Hour
+	// ImageRetentionDryRunEnv is environment variable for only reporting the images that would be garbage
+	// collected, instead of deleting them.
+	ImageRetentionDryRunEnv = "IMAGE_RETENTION_DRY_RUN"
 )
 
 // SetVMOpNamespaceEnv sets the VM Operator pod's namespace in the environment.
@@ -136,3 +155,47 @@ func GetInstanceStorageRequeueDelay() time.Duration {
 
 	return wait.Jitter(seedDuration, maxFactor)
 }
+
+// GetContentLibraryOvfCacheDir returns the directory in which the OVF envelopes of content library items
+// are cached, or an empty string if the OVF envelopes are only cached in memory.
+func GetContentLibraryOvfCacheDir() string {
+	return os.Getenv(ContentLibraryOvfCacheDirEnv)
+}
+
+// GetContentLibraryPollInterval returns the interval at which content libraries are polled for changes,
+// or zero if content libraries are not polled.
+func GetContentLibraryPollInterval() time.Duration {
+	if interval := os.Getenv(ContentLibraryPollIntervalEnv); len(interval) > 0 {
+		if duration, err := time.ParseDuration(interval); err == nil && duration > 0 {
+			return duration
+		}
+	}
+	return 0
+}
+
+// GetImageRetentionCount returns the number of newest versions of each image family that are kept, or zero
+// if unused images are not garbage collected.
+func GetImageRetentionCount() int {
+	if s := os.Getenv(ImageRetentionCountEnv); len(s) > 0 {
+		if count, err := strconv.Atoi(s); err == nil && count > 0 {
+			return count
+		}
+	}
+	return 0
+}
+
+// GetImageRetentionGracePeriod returns how long an image is unused before it is garbage collected.
+func GetImageRetentionGracePeriod() time.Duration {
+	if s := os.Getenv(ImageRetentionGracePeriodEnv); len(s) > 0 {
+		if duration, err := time.ParseDuration(s); err == nil && duration >= 0 {
+			return duration
+		}
+	}
+	return DefaultImageRetentionGracePeriod
+}
+
+// IsImageRetentionDryRun returns true if the images that would be garbage collected are only reported.
+func IsImageRetentionDryRun() bool {
+	dryRun, _ := strconv.ParseBool(os.Getenv(ImageRetentionDryRunEnv))
+	return dryRun
+}
@@ -6,6 +6,7 @@ package lib
 import (
 	"os"
 	"strconv"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -42,3 +43,55 @@ var _ = Describe("MaxAllowedConcurrentVMsOnProvider", func() {
 		})
 	})
 })
+
+var _ = Describe("GetContentLibraryPollInterval", func() {
+	AfterEach(func() {
+		Expect(os.Unsetenv(ContentLibraryPollIntervalEnv)).To(Succeed())
+	})
+
+	It("returns zero when the env is not set", func() {
+		Expect(GetContentLibraryPollInterval()).To(BeZero())
+	})
+
+	It("returns the value from the env", func() {
+		Expect(os.Setenv(ContentLibraryPollIntervalEnv, "90s")).To(Succeed())
+		Expect(GetContentLibraryPollInterval()).To(Equal(90 * time.Second))
+	})
+
+	It("returns zero with an invalid env value", func() {
+		Expect(os.Setenv(ContentLibraryPollIntervalEnv, "-1m")).To(Succeed())
+		Expect(GetContentLibraryPollInterval()).To(BeZero())
+	})
+})
+
+var _ = Describe("Image retention", func() {
+	AfterEach(func() {
+		Expect(os.Unsetenv(ImageRetentionCountEnv)).To(Succeed())
+		Expect(os.Unsetenv(ImageRetentionGracePeriodEnv)).To(Succeed())
+		Expect(os.Unsetenv(ImageRetentionDryRunEnv)).To(Succeed())
+	})
+
+	It("returns the defaults when the envs are not set", func() {
+		Expect(GetImageRetentionCount()).To(BeZero())
+		Expect(GetImageRetentionGracePeriod()).To(Equal(DefaultImageRetentionGracePeriod))
+		Expect(IsImageRetentionDryRun()).To(BeFalse())
+	})
+
+	It("returns the values from the envs", func() {
+		Expect(os.Setenv(ImageRetentionCountEnv, "3")).To(Succeed())
+		Expect(os.Setenv(ImageRetentionGracePeriodEnv, "1h")).To(Succeed())
+		Expect(os.Setenv(ImageRetentionDryRunEnv, "true")).To(Succeed())
+		Expect(GetImageRetentionCount()).To(Equal(3))
+		Expect(GetImageRetentionGracePeriod()).To(Equal(time.Hour))
+		Expect(IsImageRetentionDryRun()).To(BeTrue())
+	})
+
+	It("returns the defaults with invalid env values", func() {
+		Expect(os.Setenv(ImageRetentionCountEnv, "-1")).To(Succeed())
+		Expect(os.Setenv(ImageRetentionGracePeriodEnv, "soon")).To(Succeed())
+		Expect(os.Setenv(ImageRetentionDryRunEnv, "maybe")).To(Succeed())
+		Expect(GetImageRetentionCount()).To(BeZero())
+		Expect(GetImageRetentionGracePeriod()).To(Equal(DefaultImageRetentionGracePeriod))
+		Expect(IsImageRetentionDryRun()).To(BeFalse())
+	})
+})
@@ -21,9 +21,11 @@ import (
 
 	topologyv1 "github.com/acharyasreej/vm-operator/external/tanzu-topology/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	netopv1alpha1 "github.com/acharyasreej/vm-operator/external/net-operator/api/v1alpha1"
 	cnsv1alpha1 "github.com/acharyasreej/vm-operator/external/vsphere-csi-driver/pkg/syncer/cnsoperator/apis/cnsnodevmattachment/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/indexer"
 	"github.com/acharyasreej/vm-operator/pkg/record"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere"
 )
@@ -47,6 +49,7 @@ func New(opts Options) (Manager, error) {
 	_ = cnsv1alpha1.AddToScheme(opts.Scheme)
 	_ = netopv1alpha1.AddToScheme(opts.Scheme)
 	_ = topologyv1.AddToScheme(opts.Scheme)
+	_ = vmopapi.AddToScheme(opts.Scheme)
 	// +kubebuilder:scaffold:scheme
 
 	// controller-runtime Client creates an Informer for each resource that we watch.
@@ -87,6 +90,10 @@ func New(opts Options) (Manager, error) {
 		SyncPeriod:              opts.SyncPeriod,
 	}
 
+	if err := indexer.AddToManager(controllerManagerContext, mgr); err != nil {
+		return nil, err
+	}
+
 	if err := opts.InitializeProviders(controllerManagerContext, mgr); err != nil {
 		return nil, err
 	}
@@ -108,7 +115,12 @@ func InitializeProviders(ctx *context.ControllerManagerContext, mgr ctrlmgr.Mana
 	vmProviderName := fmt.Sprintf("%s/%s/vmProvider", ctx.Namespace, ctx.Name)
 	recorder := record.New(mgr.GetEventRecorderFor(vmProviderName))
 	ctx.VMProvider = vsphere.NewVSphereVMProviderFromClient(mgr.GetClient(), recorder)
-	return nil
+
+	// Initialize the provider once the manager is started, so its goroutines are stopped with the manager.
+	return mgr.Add(ctrlmgr.RunnableFunc(func(runCtx goctx.Context) error {
+		ctx.VMProvider.Initialize(runCtx.Done())
+		return nil
+	}))
 }
 
 type manager struct {
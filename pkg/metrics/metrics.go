@@ -0,0 +1,278 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	goctx "context"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vim25/soap"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsNamespace = "vmoperator"
+
+	// ResultSuccess and ResultError are the values of the result label.
+	ResultSuccess = "success"
+	ResultError   = "error"
+
+	// Values of the error_class label for errors that are not a vSphere fault.
+	ErrorClassNone     = "None"
+	ErrorClassTimeout  = "Timeout"
+	ErrorClassCanceled = "Canceled"
+	ErrorClassNotFound = "NotFound"
+	ErrorClassOther    = "Other"
+)
+
+var (
+	// VMOperationDuration is the duration of the VirtualMachine provider operations. Clones may take
+	// several minutes so the buckets go up to 10 minutes.
+	VMOperationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "vm",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of the VirtualMachine provider operations",
+			Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+		},
+		[]string{"operation", "result", "error_class"},
+	)
+
+	// VMOperationsTotal is the number of VirtualMachine provider operations.
+	VMOperationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "vm",
+			Name:      "operations_total",
+			Help:      "Number of VirtualMachine provider operations",
+		},
+		[]string{"operation", "result", "error_class"},
+	)
+
+	// SessionCacheSize is the number of vSphere sessions cached by the session manager.
+	SessionCacheSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "vsphere",
+			Name:      "session_cache_size",
+			Help:      "Number of cached vSphere sessions",
+		},
+	)
+
+	// ProberQueueDepth is the number of VMs that are ready to be probed. VMs waiting for their
+	// next probe period are not counted.
+	ProberQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "prober",
+			Name:      "queue_depth",
+			Help:      "Number of VirtualMachines waiting in the prober queue",
+		},
+		[]string{"queue"},
+	)
+
+	// ContentSourceSyncDuration is the duration of the last image sync of a ContentSource.
+	ContentSourceSyncDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "contentsource",
+			Name:      "sync_duration_seconds",
+			Help:      "Duration of the last VirtualMachineImage sync of the ContentSource",
+		},
+		[]string{"content_source"},
+	)
+
+	// ContentSourceSyncItems is the number of images that were added, removed and updated by the
+	// last image sync of a ContentSource.
+	ContentSourceSyncItems = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "contentsource",
+			Name:      "sync_items",
+			Help:      "Number of VirtualMachineImages changed by the last sync of the ContentSource",
+		},
+		[]string{"content_source", "state"},
+	)
+
+	// ContentSourceSyncsTotal is the number of image syncs of a ContentSource by result.
+	ContentSourceSyncsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "contentsource",
+			Name:      "syncs_total",
+			Help:      "Number of VirtualMachineImage syncs of the ContentSource",
+		},
+		[]string{"content_source", "result"},
+	)
+
+	// InstanceStoragePlacementDuration is the duration of the attempts to place the instance storage volumes of
+	// a VM on a host, from the creation of their PVCs until they are all bound or one of them failed.
+	InstanceStoragePlacementDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "instancestorage",
+			Name:      "placement_duration_seconds",
+			Help:      "Duration of the attempts to place the instance storage volumes of a VirtualMachine",
+			Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800},
+		},
+		[]string{"result"},
+	)
+
+	// InstanceStoragePlacementAttemptsTotal is the number of attempts to place the instance storage volumes of
+	// a VM on a host.
+	InstanceStoragePlacementAttemptsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "instancestorage",
+			Name:      "placement_attempts_total",
+			Help:      "Number of attempts to place the instance storage volumes of a VirtualMachine",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		VMOperationDuration,
+		VMOperationsTotal,
+		SessionCacheSize,
+		ProberQueueDepth,
+		ContentSourceSyncDuration,
+		ContentSourceSyncItems,
+		ContentSourceSyncsTotal,
+		InstanceStoragePlacementDuration,
+		InstanceStoragePlacementAttemptsTotal,
+	)
+}
+
+// ObserveVMOperation records the duration and result of a VirtualMachine provider operation
+// that was started at start.
+func ObserveVMOperation(operation string, start time.Time, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultError
+	}
+	errClass := ErrorClass(err)
+
+	VMOperationDuration.WithLabelValues(operation, result, errClass).Observe(time.Since(start).Seconds())
+	VMOperationsTotal.WithLabelValues(operation, result, errClass).Inc()
+}
+
+// SetSessionCacheSize sets the number of cached vSphere sessions.
+func SetSessionCacheSize(size int) {
+	SessionCacheSize.Set(float64(size))
+}
+
+// SetProberQueueDepth sets the number of VMs waiting in the named prober queue.
+func SetProberQueueDepth(queue string, depth int) {
+	ProberQueueDepth.WithLabelValues(queue).Set(float64(depth))
+}
+
+// ObserveContentSourceSync records the duration, the image counts and the result of a ContentSource sync.
+func ObserveContentSourceSync(contentSource string, duration time.Duration, added, removed, updated int, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultError
+	}
+
+	ContentSourceSyncsTotal.WithLabelValues(contentSource, result).Inc()
+	ContentSourceSyncDuration.WithLabelValues(contentSource).Set(duration.Seconds())
+	ContentSourceSyncItems.WithLabelValues(contentSource, "added").Set(float64(added))
+	ContentSourceSyncItems.WithLabelValues(contentSource, "removed").Set(float64(removed))
+	ContentSourceSyncItems.WithLabelValues(contentSource, "updated").Set(float64(updated))
+}
+
+// DeleteContentSourceMetrics removes the metrics of a deleted ContentSource.
+func DeleteContentSourceMetrics(contentSource string) {
+	ContentSourceSyncDuration.Delete(prometheus.Labels{"content_source": contentSource})
+	for _, state := range []string{"added", "removed", "updated"} {
+		ContentSourceSyncItems.Delete(prometheus.Labels{"content_source": contentSource, "state": state})
+	}
+	for _, result := range []string{ResultSuccess, ResultError} {
+		ContentSourceSyncsTotal.Delete(prometheus.Labels{"content_source": contentSource, "result": result})
+	}
+}
+
+// ObserveInstanceStoragePlacement records the duration and result, like Placed or Failed, of a completed
+// attempt to place the instance storage volumes of a VM.
+func ObserveInstanceStoragePlacement(result string, duration time.Duration) {
+	InstanceStoragePlacementDuration.WithLabelValues(result).Observe(duration.Seconds())
+	InstanceStoragePlacementAttemptsTotal.WithLabelValues(result).Inc()
+}
+
+// ErrorClass returns a low cardinality classification of the error, suitable for a metric label.
+// vSphere faults are classified by their fault type, like "InvalidPowerState" or "NotAuthenticated".
+func ErrorClass(err error) string {
+	if err == nil {
+		return ErrorClassNone
+	}
+
+	switch {
+	case errors.Is(err, goctx.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, goctx.Canceled):
+		return ErrorClassCanceled
+	}
+
+	// Task errors, like task.Error, carry the fault of the failed task.
+	var faultErr faultError
+	if errors.As(err, &faultErr) {
+		if class := faultClass(faultErr.Fault()); class != "" {
+			return class
+		}
+	}
+
+	cause := errors.Cause(err)
+
+	if soap.IsSoapFault(cause) {
+		if class := faultClass(soap.ToSoapFault(cause).VimFault()); class != "" {
+			return class
+		}
+		return "SoapFault"
+	}
+
+	if soap.IsVimFault(cause) {
+		if class := faultClass(soap.ToVimFault(cause)); class != "" {
+			return class
+		}
+	}
+
+	switch cause.(type) {
+	case *find.NotFoundError, *find.DefaultNotFoundError:
+		return ErrorClassNotFound
+	}
+
+	if reason := apierrors.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+		return string(reason)
+	}
+
+	return ErrorClassOther
+}
+
+// faultError is implemented by the errors that carry a vSphere fault.
+type faultError interface {
+	Fault() vimtypes.BaseMethodFault
+}
+
+// faultClass returns the type name of the vSphere fault, or an empty string if there is no fault.
+func faultClass(fault interface{}) string {
+	if fault == nil {
+		return ""
+	}
+
+	t := reflect.TypeOf(fault)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Name()
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics_test
+
+import (
+	goctx "context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/task"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/acharyasreej/vm-operator/pkg/metrics"
+)
+
+var _ = Describe("ErrorClass", func() {
+	DescribeTable("classifies the error",
+		func(err error, expected string) {
+			Expect(metrics.ErrorClass(err)).To(Equal(expected))
+		},
+		Entry("nil", nil, metrics.ErrorClassNone),
+		Entry("deadline exceeded", errors.Wrap(goctx.DeadlineExceeded, "clone failed"), metrics.ErrorClassTimeout),
+		Entry("canceled", goctx.Canceled, metrics.ErrorClassCanceled),
+		Entry("task fault",
+			errors.Wrap(task.Error{LocalizedMethodFault: &vimtypes.LocalizedMethodFault{Fault: &vimtypes.InvalidPowerState{}}}, "power on failed"),
+			"InvalidPowerState"),
+		Entry("govmomi not found", &find.NotFoundError{}, metrics.ErrorClassNotFound),
+		Entry("kubernetes not found", apierrors.NewNotFound(schema.GroupResource{Resource: "virtualmachine"}, "dummy-vm"), "NotFound"),
+		Entry("kubernetes conflict", apierrors.NewConflict(schema.GroupResource{Resource: "virtualmachine"}, "dummy-vm", fmt.Errorf("conflict")), "Conflict"),
+		Entry("other", fmt.Errorf("dummy error"), metrics.ErrorClassOther),
+	)
+})
+
+var _ = Describe("ObserveVMOperation", func() {
+	It("counts the operation by result and error class", func() {
+		success := metrics.VMOperationsTotal.WithLabelValues("test-op", metrics.ResultSuccess, metrics.ErrorClassNone)
+		failure := metrics.VMOperationsTotal.WithLabelValues("test-op", metrics.ResultError, metrics.ErrorClassOther)
+		successCount, failureCount := testutil.ToFloat64(success), testutil.ToFloat64(failure)
+
+		metrics.ObserveVMOperation("test-op", time.Now(), nil)
+		metrics.ObserveVMOperation("test-op", time.Now(), fmt.Errorf("dummy error"))
+		metrics.ObserveVMOperation("test-op", time.Now(), fmt.Errorf("dummy error"))
+
+		Expect(testutil.ToFloat64(success)).To(Equal(successCount + 1))
+		Expect(testutil.ToFloat64(failure)).To(Equal(failureCount + 2))
+	})
+})
+
+var _ = Describe("ContentSource sync metrics", func() {
+	It("sets and deletes the metrics of the content source", func() {
+		metrics.ObserveContentSourceSync("dummy-cs", 2*time.Second, 3, 2, 1, nil)
+		metrics.ObserveContentSourceSync("dummy-cs", 2*time.Second, 3, 2, 1, fmt.Errorf("dummy error"))
+
+		Expect(testutil.ToFloat64(metrics.ContentSourceSyncsTotal.WithLabelValues("dummy-cs", metrics.ResultSuccess))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(metrics.ContentSourceSyncsTotal.WithLabelValues("dummy-cs", metrics.ResultError))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(metrics.ContentSourceSyncDuration.WithLabelValues("dummy-cs"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(metrics.ContentSourceSyncItems.WithLabelValues("dummy-cs", "added"))).To(Equal(3.0))
+		Expect(testutil.ToFloat64(metrics.ContentSourceSyncItems.WithLabelValues("dummy-cs", "removed"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(metrics.ContentSourceSyncItems.WithLabelValues("dummy-cs", "updated"))).To(Equal(1.0))
+
+		metrics.DeleteContentSourceMetrics("dummy-cs")
+		Expect(testutil.CollectAndCount(metrics.ContentSourceSyncItems)).To(Equal(0))
+		Expect(testutil.CollectAndCount(metrics.ContentSourceSyncsTotal)).To(Equal(0))
+	})
+})
+
+var _ = Describe("ObserveInstanceStoragePlacement", func() {
+	It("counts the placement attempts by result", func() {
+		placed := metrics.InstanceStoragePlacementAttemptsTotal.WithLabelValues("Placed")
+		failed := metrics.InstanceStoragePlacementAttemptsTotal.WithLabelValues("Failed")
+		placedCount, failedCount := testutil.ToFloat64(placed), testutil.ToFloat64(failed)
+
+		metrics.ObserveInstanceStoragePlacement("Placed", time.Minute)
+		metrics.ObserveInstanceStoragePlacement("Failed", 5*time.Minute)
+
+		Expect(testutil.ToFloat64(placed)).To(Equal(placedCount + 1))
+		Expect(testutil.ToFloat64(failed)).To(Equal(failedCount + 1))
+	})
+})
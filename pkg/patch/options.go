@@ -39,6 +39,10 @@ type HelperOptions struct {
 	// OwnedConditions defines condition types owned by the controller.
 	// In case of conflicts for the owned conditions, the patch helper will always use the value provided by the controller.
 	OwnedConditions []vmopv1alpha1.ConditionType
+
+	// OptimisticLockStatusFields defines the status fields that are updated by more than one controller.
+	// When one of these fields changed, the status is patched with an optimistic lock.
+	OptimisticLockStatusFields []string
 }
 
 // WithForceOverwriteConditions allows the patch helper to overwrite conditions in case of conflicts.
@@ -69,3 +73,14 @@ type WithOwnedConditions struct {
 func (w WithOwnedConditions) ApplyToHelper(in *HelperOptions) {
 	in.OwnedConditions = w.Conditions
 }
+
+// WithOptimisticLockOnStatusFields patches the status with an optimistic lock when one of the given status fields
+// changed, so that a field that is updated by more than one controller is not overwritten with a stale value.
+type WithOptimisticLockOnStatusFields struct {
+	Fields []string
+}
+
+// ApplyToHelper applies this configuration to the given HelperOptions.
+func (w WithOptimisticLockOnStatusFields) ApplyToHelper(in *HelperOptions) {
+	in.OptimisticLockStatusFields = w.Fields
+}
@@ -112,6 +112,16 @@ func (h *Helper) Patch(ctx context.Context, obj client.Object, opts ...Option) e
 		return err
 	}
 
+	// When a status field that other controllers also update changed, patch the status first with an optimistic
+	// lock on the resourceVersion the object was read with, since the other patches change the resourceVersion.
+	if h.statusFieldsChanged(options.OptimisticLockStatusFields) {
+		return kerrors.NewAggregate([]error{
+			h.patchStatus(ctx, obj, true),
+			h.patchStatusConditions(ctx, obj, options.ForceOverwriteConditions, options.OwnedConditions),
+			h.patch(ctx, obj),
+		})
+	}
+
 	// Issue patches and return errors in an aggregate.
 	return kerrors.NewAggregate([]error{
 		// Patch the conditions first.
@@ -123,7 +133,7 @@ func (h *Helper) Patch(ctx context.Context, obj client.Object, opts ...Option) e
 
 		// Then proceed to patch the rest of the object.
 		h.patch(ctx, obj),
-		h.patchStatus(ctx, obj),
+		h.patchStatus(ctx, obj, false),
 	})
 }
 
@@ -140,7 +150,7 @@ func (h *Helper) patch(ctx context.Context, obj client.Object) error {
 }
 
 // patchStatus issues a patch if the status has changed.
-func (h *Helper) patchStatus(ctx context.Context, obj client.Object) error {
+func (h *Helper) patchStatus(ctx context.Context, obj client.Object, optimisticLock bool) error {
 	if !h.shouldPatch("status") {
 		return nil
 	}
@@ -148,9 +158,27 @@ func (h *Helper) patchStatus(ctx context.Context, obj client.Object) error {
 	if err != nil {
 		return err
 	}
+	if optimisticLock {
+		return h.client.Status().Patch(ctx, afterObject, client.MergeFromWithOptions(beforeObject, client.MergeFromWithOptimisticLock{}))
+	}
 	return h.client.Status().Patch(ctx, afterObject, client.MergeFrom(beforeObject))
 }
 
+// statusFieldsChanged returns whether any of the given status fields differs between the before and after objects.
+func (h *Helper) statusFieldsChanged(fields []string) bool {
+	if !h.shouldPatch("status") {
+		return false
+	}
+	for _, f := range fields {
+		before, _, _ := unstructured.NestedFieldNoCopy(h.before.Object, "status", f)
+		after, _, _ := unstructured.NestedFieldNoCopy(h.after.Object, "status", f)
+		if !reflect.DeepEqual(before, after) {
+			return true
+		}
+	}
+	return false
+}
+
 // patchStatusConditions issues a patch if there are any changes to the conditions slice under
 // the status subresource. This is a special case and it's handled separately given that
 // we allow different controllers to act on conditions of the same object.
@@ -593,6 +593,73 @@ func intgTests() {
 						reflect.DeepEqual(obj.Spec, objAfter.Spec)
 				}, timeout).Should(BeTrue())
 			})
+
+			Specify("updating a status field with an optimistic lock", func() {
+				obj := obj.DeepCopy()
+
+				By("Creating the object")
+				Expect(ctx.Client.Create(ctx, obj)).ToNot(HaveOccurred())
+				key := client.ObjectKey{Name: obj.Name, Namespace: obj.Namespace}
+				defer func() {
+					Expect(ctx.Client.Delete(ctx, obj)).To(Succeed())
+				}()
+
+				By("Creating a new patch helper")
+				patcher, err := NewHelper(obj, ctx.Client)
+				Expect(err).NotTo(HaveOccurred())
+
+				By("Updating the volumes of the object status with another client")
+				otherObj := obj.DeepCopy()
+				otherObj.Status.Volumes = []vmopv1alpha1.VirtualMachineVolumeStatus{{Name: "other-volume"}}
+				Expect(ctx.Client.Status().Update(ctx, otherObj)).To(Succeed())
+
+				By("Updating the volumes of the object status")
+				obj.Status.Volumes = []vmopv1alpha1.VirtualMachineVolumeStatus{{Name: "volume"}}
+
+				By("Setting Ready condition")
+				conditions.MarkTrue(obj, vmopv1alpha1.ReadyCondition)
+
+				By("Patching the object")
+				Expect(patcher.Patch(ctx, obj, WithOptimisticLockOnStatusFields{Fields: []string{"volumes"}})).ToNot(Succeed())
+
+				By("Validating the volumes have not been overwritten")
+				objAfter := obj.DeepCopy()
+				Expect(ctx.Client.Get(ctx, key, objAfter)).To(Succeed())
+				Expect(objAfter.Status.Volumes).To(Equal(otherObj.Status.Volumes))
+				Expect(conditions.IsTrue(objAfter, vmopv1alpha1.ReadyCondition)).To(BeTrue())
+			})
+
+			Specify("updating other status fields with an optimistic lock on a status field", func() {
+				obj := obj.DeepCopy()
+
+				By("Creating the object")
+				Expect(ctx.Client.Create(ctx, obj)).ToNot(HaveOccurred())
+				key := client.ObjectKey{Name: obj.Name, Namespace: obj.Namespace}
+				defer func() {
+					Expect(ctx.Client.Delete(ctx, obj)).To(Succeed())
+				}()
+
+				By("Creating a new patch helper")
+				patcher, err := NewHelper(obj, ctx.Client)
+				Expect(err).NotTo(HaveOccurred())
+
+				By("Updating the volumes of the object status with another client")
+				otherObj := obj.DeepCopy()
+				otherObj.Status.Volumes = []vmopv1alpha1.VirtualMachineVolumeStatus{{Name: "other-volume"}}
+				Expect(ctx.Client.Status().Update(ctx, otherObj)).To(Succeed())
+
+				By("Updating the object status")
+				obj.Status.Host = "vm-host"
+
+				By("Patching the object")
+				Expect(patcher.Patch(ctx, obj, WithOptimisticLockOnStatusFields{Fields: []string{"volumes"}})).To(Succeed())
+
+				By("Validating the object has been updated")
+				objAfter := obj.DeepCopy()
+				Expect(ctx.Client.Get(ctx, key, objAfter)).To(Succeed())
+				Expect(objAfter.Status.Host).To(Equal("vm-host"))
+				Expect(objAfter.Status.Volumes).To(Equal(otherObj.Status.Volumes))
+			})
 		})
 
 		/*
@@ -10,6 +10,7 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/patch"
 )
 
@@ -21,6 +22,10 @@ type ProbeContext struct {
 	VM          *vmopv1alpha1.VirtualMachine
 	ProbeType   string
 	ProbeSpec   *vmopv1alpha1.Probe
+	// ProbeActions are the probe actions that are not part of the ProbeSpec.
+	ProbeActions *vmopapi.ProbeActions
+	// LivenessProbe is the liveness probe when the ProbeType is liveness.
+	LivenessProbe *vmopapi.LivenessProbe
 }
 
 // String returns probe type.
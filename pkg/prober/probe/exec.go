@@ -0,0 +1,80 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package probe
+
+import (
+	goctx "context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/acharyasreej/vm-operator/pkg/prober/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+)
+
+const (
+	// ExecCredentialsUsernameKey and ExecCredentialsPasswordKey are the keys in the exec probe
+	// credentials Secret that contain the guest account to run the command as.
+	ExecCredentialsUsernameKey = "username"
+	ExecCredentialsPasswordKey = "password"
+)
+
+// execProber implements the Probe interface.
+type execProber struct {
+	client client.Client
+	prober vmProviderProber
+}
+
+// NewExecProber creates a new exec prober which implements the Probe interface to run a command in
+// the guest via VMware Tools.
+func NewExecProber(client client.Client, vmProviderProber vmProviderProber) Probe {
+	return &execProber{
+		client: client,
+		prober: vmProviderProber,
+	}
+}
+
+func (ep execProber) Probe(ctx *context.ProbeContext) (Result, error) {
+	vm := ctx.VM
+	if ctx.ProbeActions == nil || ctx.ProbeActions.Exec == nil {
+		return Unknown, fmt.Errorf("VM %s does not have an exec probe action", vm.NamespacedName())
+	}
+	action := ctx.ProbeActions.Exec
+
+	if len(action.Command) == 0 {
+		return Unknown, fmt.Errorf("VM %s exec probe does not specify a command", vm.NamespacedName())
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := client.ObjectKey{Name: action.CredentialsSecretName, Namespace: vm.Namespace}
+	if err := ep.client.Get(ctx, secretKey, secret); err != nil {
+		return Unknown, errors.Wrapf(err, "failed to get exec probe credentials Secret %s", secretKey)
+	}
+
+	cmd := vmprovider.GuestCommand{
+		Path:     action.Command[0],
+		Args:     action.Command[1:],
+		Username: string(secret.Data[ExecCredentialsUsernameKey]),
+		Password: string(secret.Data[ExecCredentialsPasswordKey]),
+	}
+
+	timeoutCtx, cancel := goctx.WithTimeout(ctx, probeTimeout(ctx.ProbeSpec))
+	defer cancel()
+
+	exitCode, err := ep.prober.RunVirtualMachineGuestCommand(timeoutCtx, vm, cmd)
+	if err != nil {
+		// The guest operations may fail because VMware Tools is not running yet, so we cannot
+		// tell if the command would have succeeded.
+		return Unknown, err
+	}
+
+	if exitCode != 0 {
+		return Failure, fmt.Errorf("exec probe command %q exited with code %d", cmd.Path, exitCode)
+	}
+
+	return Success, nil
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package probe
+
+import (
+	goctx "context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/prober/context"
+)
+
+var _ = Describe("Exec probe", func() {
+	var (
+		vm            *vmopv1alpha1.VirtualMachine
+		secret        *corev1.Secret
+		action        *vmopapi.ExecAction
+		fakeProvider  fakeVMProviderProber
+		testExecProbe Probe
+		probeCtx      *context.ProbeContext
+
+		res Result
+		err error
+	)
+
+	BeforeEach(func() {
+		vm = &vmopv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-vm",
+				Namespace: "dummy-ns",
+			},
+			Spec: vmopv1alpha1.VirtualMachineSpec{
+				ClassName:      "dummy-vmclass",
+				ReadinessProbe: &vmopv1alpha1.Probe{PeriodSeconds: 1},
+			},
+		}
+
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-credentials",
+				Namespace: vm.Namespace,
+			},
+			Data: map[string][]byte{
+				ExecCredentialsUsernameKey: []byte("root"),
+				ExecCredentialsPasswordKey: []byte("secret"),
+			},
+		}
+
+		action = &vmopapi.ExecAction{
+			Command:               []string{"/usr/bin/systemctl", "is-active", "nginx"},
+			CredentialsSecretName: secret.Name,
+		}
+		fakeProvider = fakeVMProviderProber{}
+	})
+
+	JustBeforeEach(func() {
+		fakeClient := fake.NewClientBuilder().WithObjects(secret).Build()
+		testExecProbe = NewExecProber(fakeClient, &fakeProvider)
+
+		probeCtx = &context.ProbeContext{
+			Context:      goctx.Background(),
+			VM:           vm,
+			ProbeSpec:    vm.Spec.ReadinessProbe,
+			ProbeActions: &vmopapi.ProbeActions{Exec: action},
+			Logger:       ctrl.Log.WithName("Probe").WithValues("name", vm.NamespacedName()),
+		}
+
+		res, err = testExecProbe.Probe(probeCtx)
+	})
+
+	When("the command exits with zero", func() {
+		It("returns success", func() {
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res).To(Equal(Success))
+		})
+
+		It("runs the command with the credentials from the Secret", func() {
+			Expect(fakeProvider.guestCommand.Path).To(Equal("/usr/bin/systemctl"))
+			Expect(fakeProvider.guestCommand.Args).To(Equal([]string{"is-active", "nginx"}))
+			Expect(fakeProvider.guestCommand.Username).To(Equal("root"))
+			Expect(fakeProvider.guestCommand.Password).To(Equal("secret"))
+		})
+	})
+
+	When("the command exits with non-zero", func() {
+		BeforeEach(func() { fakeProvider.exitCode = 3 })
+
+		It("returns failure", func() {
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("exited with code 3"))
+			Expect(res).To(Equal(Failure))
+		})
+	})
+
+	When("the guest command cannot be run", func() {
+		BeforeEach(func() { fakeProvider.err = fmt.Errorf("fake error") })
+
+		It("returns unknown", func() {
+			Expect(err).Should(HaveOccurred())
+			Expect(res).To(Equal(Unknown))
+		})
+	})
+
+	When("the credentials Secret does not exist", func() {
+		BeforeEach(func() { action.CredentialsSecretName = "bogus" })
+
+		It("returns unknown", func() {
+			Expect(err).Should(HaveOccurred())
+			Expect(res).To(Equal(Unknown))
+		})
+	})
+})
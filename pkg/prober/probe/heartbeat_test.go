@@ -16,17 +16,26 @@ import (
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
 	"github.com/acharyasreej/vm-operator/pkg/prober/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
 )
 
 type fakeVMProviderProber struct {
 	status vmopv1alpha1.GuestHeartbeatStatus
 	err    error
+
+	guestCommand vmprovider.GuestCommand
+	exitCode     int32
 }
 
 func (tp fakeVMProviderProber) GetVirtualMachineGuestHeartbeat(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine) (vmopv1alpha1.GuestHeartbeatStatus, error) {
 	return tp.status, tp.err
 }
 
+func (tp *fakeVMProviderProber) RunVirtualMachineGuestCommand(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine, cmd vmprovider.GuestCommand) (int32, error) {
+	tp.guestCommand = cmd
+	return tp.exitCode, tp.err
+}
+
 var _ = Describe("Guest heartbeat probe", func() {
 	var (
 		vm                   *vmopv1alpha1.VirtualMachine
@@ -0,0 +1,116 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package probe
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/prober/context"
+)
+
+// maxRespBodyLength is the number of bytes of the response body that are read before the connection
+// is closed, so that keep-alive connections could be reused.
+const maxRespBodyLength = 10 * 1 << 10
+
+// httpGetProber implements the Probe interface.
+type httpGetProber struct{}
+
+// NewHTTPGetProber creates a new http prober which implements the Probe interface to execute HTTP(S) GET probes.
+func NewHTTPGetProber() Probe {
+	return &httpGetProber{}
+}
+
+func (pr httpGetProber) Probe(ctx *context.ProbeContext) (Result, error) {
+	vm := ctx.VM
+	if ctx.ProbeActions == nil || ctx.ProbeActions.HTTPGet == nil {
+		return Unknown, fmt.Errorf("VM %s does not have a HTTP GET probe action", vm.NamespacedName())
+	}
+	action := ctx.ProbeActions.HTTPGet
+
+	portNum, err := findPort(vm, action.Port, corev1.ProtocolTCP)
+	if err != nil {
+		return Failure, err
+	}
+
+	var host string
+	if action.Host != "" {
+		host = action.Host
+	} else {
+		ctx.Logger.V(4).Info("HTTPGet Host not specified, using VM IP", "probe", ctx.String())
+		if host = vm.Status.VmIp; host == "" {
+			return Failure, fmt.Errorf("VM %s doesn't have an IP assigned", vm.NamespacedName())
+		}
+	}
+
+	scheme := strings.ToLower(string(action.Scheme))
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	path := action.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	u := &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(host, strconv.Itoa(portNum)),
+		Path:   path,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Failure, err
+	}
+	for _, h := range action.HTTPHeaders {
+		if strings.EqualFold(h.Name, "Host") {
+			req.Host = h.Value
+		} else {
+			req.Header.Add(h.Name, h.Value)
+		}
+	}
+
+	client := &http.Client{
+		Timeout: probeTimeout(ctx.ProbeSpec),
+		Transport: &http.Transport{
+			// #nosec G402 -- Skipping the verification is opt-in by the user.
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: action.InsecureSkipTLSVerify},
+			DisableKeepAlives: true,
+			Proxy:             http.ProxyURL(nil),
+		},
+		// A redirect is the response of the probe, which must not be sent to another host or port.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Failure, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxRespBodyLength))
+
+	minStatus, maxStatus := int32(vmopapi.DefaultHTTPGetSuccessStatusMin), int32(vmopapi.DefaultHTTPGetSuccessStatusMax)
+	if r := action.SuccessStatusRange; r != nil {
+		minStatus, maxStatus = r.Min, r.Max
+	}
+
+	if code := int32(resp.StatusCode); code < minStatus || code > maxStatus {
+		return Failure, fmt.Errorf("HTTP probe failed with statuscode: %d", resp.StatusCode)
+	}
+
+	return Success, nil
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package probe
+
+import (
+	goctx "context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/prober/context"
+)
+
+var _ = Describe("HTTP GET probe", func() {
+	var (
+		vm               *vmopv1alpha1.VirtualMachine
+		testHTTPGetProbe Probe
+		action           *vmopapi.HTTPGetAction
+		probeCtx         *context.ProbeContext
+
+		testServer     *httptest.Server
+		testHost       string
+		testPort       int
+		responseStatus int
+		redirectPath   string
+		lastRequest    *http.Request
+	)
+
+	BeforeEach(func() {
+		vm = &vmopv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-vm",
+				Namespace: "dummy-ns",
+			},
+			Spec: vmopv1alpha1.VirtualMachineSpec{
+				ClassName:      "dummy-vmclass",
+				ReadinessProbe: &vmopv1alpha1.Probe{PeriodSeconds: 1},
+			},
+		}
+
+		responseStatus = http.StatusOK
+		testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lastRequest = r
+			if redirectPath != "" && r.URL.Path != redirectPath {
+				http.Redirect(w, r, redirectPath, http.StatusFound)
+				return
+			}
+			w.WriteHeader(responseStatus)
+		}))
+		host, port, err := net.SplitHostPort(testServer.Listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		testHost = host
+		testPort, err = strconv.Atoi(port)
+		Expect(err).NotTo(HaveOccurred())
+
+		action = &vmopapi.HTTPGetAction{
+			Path: "/healthz",
+			Host: testHost,
+			Port: intstr.FromInt(testPort),
+		}
+		testHTTPGetProbe = NewHTTPGetProber()
+	})
+
+	JustBeforeEach(func() {
+		probeCtx = &context.ProbeContext{
+			Context:      goctx.Background(),
+			VM:           vm,
+			ProbeSpec:    vm.Spec.ReadinessProbe,
+			ProbeActions: &vmopapi.ProbeActions{HTTPGet: action},
+			Logger:       ctrl.Log.WithName("Probe").WithValues("name", vm.NamespacedName()),
+		}
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+		redirectPath = ""
+		lastRequest = nil
+	})
+
+	It("HTTP GET probe succeeds, with host set in the action", func() {
+		action.HTTPHeaders = []corev1.HTTPHeader{{Name: "X-Probe", Value: "readiness"}}
+
+		res, err := testHTTPGetProbe.Probe(probeCtx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res).To(Equal(Success))
+
+		Expect(lastRequest).ToNot(BeNil())
+		Expect(lastRequest.URL.Path).To(Equal("/healthz"))
+		Expect(lastRequest.Header.Get("X-Probe")).To(Equal("readiness"))
+	})
+
+	It("HTTP GET probe succeeds, with empty host", func() {
+		vm.Status.VmIp = testHost
+		action.Host = ""
+
+		res, err := testHTTPGetProbe.Probe(probeCtx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res).To(Equal(Success))
+	})
+
+	It("HTTP GET probe succeeds, with named port", func() {
+		vm.Spec.Ports = []vmopv1alpha1.VirtualMachinePort{
+			{Name: "health", Port: testPort, Protocol: corev1.ProtocolTCP},
+		}
+		action.Port = intstr.FromString("health")
+
+		res, err := testHTTPGetProbe.Probe(probeCtx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res).To(Equal(Success))
+	})
+
+	It("HTTP GET probe fails, with status outside the default range", func() {
+		responseStatus = http.StatusServiceUnavailable
+
+		res, err := testHTTPGetProbe.Probe(probeCtx)
+		Expect(err).Should(HaveOccurred())
+		Expect(res).To(Equal(Failure))
+	})
+
+	It("HTTP GET probe succeeds, with status inside the specified range", func() {
+		responseStatus = http.StatusUnauthorized
+		action.SuccessStatusRange = &vmopapi.HTTPStatusRange{Min: 200, Max: 401}
+
+		res, err := testHTTPGetProbe.Probe(probeCtx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res).To(Equal(Success))
+	})
+
+	It("HTTP GET probe does not follow redirects", func() {
+		redirectPath = "/redirected"
+		action.SuccessStatusRange = &vmopapi.HTTPStatusRange{Min: 200, Max: 299}
+
+		res, err := testHTTPGetProbe.Probe(probeCtx)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("302"))
+		Expect(res).To(Equal(Failure))
+		Expect(lastRequest.URL.Path).To(Equal("/healthz"))
+	})
+
+	It("HTTP GET probe fails, when the VM has no IP", func() {
+		action.Host = ""
+
+		res, err := testHTTPGetProbe.Probe(probeCtx)
+		Expect(err).Should(HaveOccurred())
+		Expect(res).To(Equal(Failure))
+	})
+
+	It("HTTP GET probe fails, when nothing is listening", func() {
+		action.Port = intstr.FromInt(10001)
+
+		res, err := testHTTPGetProbe.Probe(probeCtx)
+		Expect(err).Should(HaveOccurred())
+		Expect(res).To(Equal(Failure))
+	})
+
+	Context("HTTPS", func() {
+		BeforeEach(func() {
+			testServer.Close()
+			testServer = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			_, port, err := net.SplitHostPort(testServer.Listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			testPort, err = strconv.Atoi(port)
+			Expect(err).NotTo(HaveOccurred())
+
+			action.Scheme = corev1.URISchemeHTTPS
+			action.Port = intstr.FromInt(testPort)
+		})
+
+		It("HTTP GET probe fails, with an untrusted certificate", func() {
+			res, err := testHTTPGetProbe.Probe(probeCtx)
+			Expect(err).Should(HaveOccurred())
+			Expect(res).To(Equal(Failure))
+		})
+
+		It("HTTP GET probe succeeds, when TLS verification is skipped", func() {
+			action.InsecureSkipTLSVerify = true
+
+			res, err := testHTTPGetProbe.Probe(probeCtx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res).To(Equal(Success))
+		})
+	})
+})
@@ -7,9 +7,12 @@ import (
 	goctx "context"
 	"time"
 
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
 	"github.com/acharyasreej/vm-operator/pkg/prober/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
 )
 
 type Result int
@@ -33,18 +36,31 @@ type Probe interface {
 // Probing related provider methods.
 type vmProviderProber interface {
 	GetVirtualMachineGuestHeartbeat(ctx goctx.Context, vm *vmopv1alpha1.VirtualMachine) (vmopv1alpha1.GuestHeartbeatStatus, error)
+	RunVirtualMachineGuestCommand(ctx goctx.Context, vm *vmopv1alpha1.VirtualMachine, cmd vmprovider.GuestCommand) (int32, error)
 }
 
 // Prober contains the different type of probes.
 type Prober struct {
 	TCPProbe       Probe
+	HTTPGetProbe   Probe
+	ExecProbe      Probe
 	GuestHeartbeat Probe
 }
 
 // NewProber creates a new Prober.
-func NewProber(vmProviderProber vmProviderProber) *Prober {
+func NewProber(client client.Client, vmProviderProber vmProviderProber) *Prober {
 	return &Prober{
 		TCPProbe:       NewTCPProber(),
+		HTTPGetProbe:   NewHTTPGetProber(),
+		ExecProbe:      NewExecProber(client, vmProviderProber),
 		GuestHeartbeat: NewGuestHeartbeatProber(vmProviderProber),
 	}
 }
+
+// probeTimeout returns the timeout of the probe specified in the probe spec.
+func probeTimeout(p *vmopv1alpha1.Probe) time.Duration {
+	if p.TimeoutSeconds <= 0 {
+		return defaultConnectTimeout
+	}
+	return time.Duration(p.TimeoutSeconds) * time.Second
+}
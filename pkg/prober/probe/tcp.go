@@ -45,14 +45,7 @@ func (pr tcpProber) Probe(ctx *context.ProbeContext) (Result, error) {
 		}
 	}
 
-	var timeout time.Duration
-	if p.TimeoutSeconds <= 0 {
-		timeout = defaultConnectTimeout
-	} else {
-		timeout = time.Duration(p.TimeoutSeconds) * time.Second
-	}
-
-	if err := checkConnection("tcp", host, strconv.Itoa(portNum), timeout); err != nil {
+	if err := checkConnection("tcp", host, strconv.Itoa(portNum), probeTimeout(p)); err != nil {
 		return Failure, err
 	}
 
@@ -20,6 +20,8 @@ import (
 
 	vmoperatorv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/metrics"
 	"github.com/acharyasreej/vm-operator/pkg/prober/context"
 	"github.com/acharyasreej/vm-operator/pkg/prober/probe"
 	"github.com/acharyasreej/vm-operator/pkg/prober/worker"
@@ -30,6 +32,7 @@ import (
 const (
 	proberManagerName       = "virtualmachine-prober-manager"
 	readinessProbeQueueName = "readinessProbeQueue"
+	livenessProbeQueueName  = "livenessProbeQueue"
 
 	// defaultPeriodSeconds represents the default value for the frequency (in seconds) to perform the probe.
 	// We use the same default value as the kubernetes container probe.
@@ -38,6 +41,9 @@ const (
 	// the number of readiness workers.
 	// TODO: find a way to calibrate it.
 	numberOfReadinessWorkers = 5
+
+	// the number of liveness workers.
+	numberOfLivenessWorkers = 5
 )
 
 // Manager represents a prober manager interface.
@@ -51,7 +57,9 @@ type Manager interface {
 type manager struct {
 	client         client.Client
 	readinessQueue workqueue.DelayingInterface
+	livenessQueue  workqueue.DelayingInterface
 	prober         *probe.Prober
+	vmProvider     vmprovider.VirtualMachineProviderInterface
 	log            logr.Logger
 	recorder       vmoprecord.Recorder
 
@@ -62,6 +70,12 @@ type manager struct {
 	// adding VMs to the readiness queue when this VM is already in the heap but not in the queue.
 	readinessMutex       sync.Mutex
 	vmReadinessProbeList map[string]*vmoperatorv1alpha1.Probe
+
+	// livenessMutex and vmLivenessProbeList serve the same purpose for the liveness queue. The
+	// livenessTracker holds the liveness probe results that are shared between the liveness workers.
+	livenessMutex       sync.Mutex
+	vmLivenessProbeList map[string]*vmopapi.LivenessProbe
+	livenessTracker     *worker.LivenessTracker
 }
 
 // NewManger initializes a prober manager.
@@ -69,10 +83,14 @@ func NewManger(client client.Client, record vmoprecord.Recorder, vmProvider vmpr
 	probeManager := &manager{
 		client:               client,
 		readinessQueue:       workqueue.NewNamedDelayingQueue(readinessProbeQueueName),
-		prober:               probe.NewProber(vmProvider),
+		livenessQueue:        workqueue.NewNamedDelayingQueue(livenessProbeQueueName),
+		prober:               probe.NewProber(client, vmProvider),
+		vmProvider:           vmProvider,
 		log:                  ctrl.Log.WithName(proberManagerName),
 		recorder:             record,
 		vmReadinessProbeList: make(map[string]*vmoperatorv1alpha1.Probe),
+		vmLivenessProbeList:  make(map[string]*vmopapi.LivenessProbe),
+		livenessTracker:      worker.NewLivenessTracker(),
 	}
 	return probeManager
 }
@@ -95,6 +113,8 @@ func (m *manager) AddToProberManager(vm *vmoperatorv1alpha1.VirtualMachine) {
 	vmName := vm.NamespacedName()
 	m.log.V(4).Info("Add to prober manager", "vm", vmName)
 
+	m.addToLivenessProbeList(vm)
+
 	m.readinessMutex.Lock()
 	defer m.readinessMutex.Unlock()
 
@@ -109,19 +129,54 @@ func (m *manager) AddToProberManager(vm *vmoperatorv1alpha1.VirtualMachine) {
 
 		m.readinessQueue.Add(client.ObjectKey{Name: vm.Name, Namespace: vm.Namespace})
 		m.vmReadinessProbeList[vmName] = newProbe
+		m.updateQueueDepthMetrics()
 	} else {
 		delete(m.vmReadinessProbeList, vmName)
 	}
 }
 
+// addToLivenessProbeList adds the VM to the liveness queue if it has a liveness probe that is not
+// already in the list.
+func (m *manager) addToLivenessProbeList(vm *vmoperatorv1alpha1.VirtualMachine) {
+	vmName := vm.NamespacedName()
+
+	m.livenessMutex.Lock()
+	defer m.livenessMutex.Unlock()
+
+	newProbe, err := vmopapi.GetLivenessProbe(vm)
+	if err != nil {
+		m.log.Error(err, "Failed to decode liveness probe annotation, ignoring it", "vm", vmName)
+	}
+
+	if newProbe == nil {
+		delete(m.vmLivenessProbeList, vmName)
+		m.livenessTracker.Remove(vm)
+		return
+	}
+
+	if oldProbe, ok := m.vmLivenessProbeList[vmName]; ok && reflect.DeepEqual(oldProbe, newProbe) {
+		m.log.V(4).Info("VM is already in the liveness probe list and its probe is not updated, skip it", "vm", vmName)
+		return
+	}
+
+	m.livenessQueue.Add(client.ObjectKey{Name: vm.Name, Namespace: vm.Namespace})
+	m.vmLivenessProbeList[vmName] = newProbe
+	m.updateQueueDepthMetrics()
+}
+
 // RemoveFromProberManager removes a VM from the prober manager.
 func (m *manager) RemoveFromProberManager(vm *vmoperatorv1alpha1.VirtualMachine) {
 	vmName := vm.NamespacedName()
 	m.log.V(4).Info("Remove from prober manager", "vm", vmName)
 
 	m.readinessMutex.Lock()
-	defer m.readinessMutex.Unlock()
 	delete(m.vmReadinessProbeList, vmName)
+	m.readinessMutex.Unlock()
+
+	m.livenessMutex.Lock()
+	delete(m.vmLivenessProbeList, vmName)
+	m.livenessTracker.Remove(vm)
+	m.livenessMutex.Unlock()
 }
 
 // Start starts the probe manager.
@@ -136,9 +191,17 @@ func (m *manager) Start(ctx goctx.Context) error {
 		m.worker(readinessWorker)
 	}
 
+	m.log.Info("Starting liveness workers", "count", numberOfLivenessWorkers)
+	m.workersWG.Add(numberOfLivenessWorkers)
+	for i := 0; i < numberOfLivenessWorkers; i++ {
+		livenessWorker := worker.NewLivenessWorker(m.livenessQueue, m.prober, m.vmProvider, m.livenessTracker, m.recorder)
+		m.worker(livenessWorker)
+	}
+
 	<-ctx.Done()
 
 	m.readinessQueue.ShutDown()
+	m.livenessQueue.ShutDown()
 	m.workersWG.Wait()
 	return nil
 }
@@ -165,6 +228,7 @@ func (m *manager) processItemFromQueue(w worker.Worker) bool {
 		return true
 	}
 	defer queue.Done(itemIf)
+	defer m.updateQueueDepthMetrics()
 
 	item := itemIf.(client.ObjectKey)
 
@@ -200,6 +264,12 @@ func (m *manager) processItemFromQueue(w worker.Worker) bool {
 	return false
 }
 
+// updateQueueDepthMetrics updates the queue depth metrics of the probe queues.
+func (m *manager) updateQueueDepthMetrics() {
+	metrics.SetProberQueueDepth(readinessProbeQueueName, m.readinessQueue.Len())
+	metrics.SetProberQueueDepth(livenessProbeQueueName, m.livenessQueue.Len())
+}
+
 // processVMProbe processes the Probe specified in VM spec.
 func (m *manager) processVMProbe(w worker.Worker, ctx *context.ProbeContext) error {
 	vm := ctx.VM
@@ -18,6 +18,7 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/prober/context"
 	fakeworker "github.com/acharyasreej/vm-operator/pkg/prober/fake/worker"
 	"github.com/acharyasreej/vm-operator/pkg/prober/probe"
@@ -213,6 +214,45 @@ var _ = Describe("VirtualMachine probes", func() {
 			testManager.readinessMutex.Unlock()
 		})
 
+		When("VM has a liveness probe", func() {
+			BeforeEach(func() {
+				vm.Annotations = map[string]string{
+					vmopapi.LivenessProbeAnnotation: `{"guestHeartbeat": {}, "periodSeconds": 10}`,
+				}
+			})
+
+			It("Should add to the liveness queue and list", func() {
+				testManager.AddToProberManager(vm)
+
+				Expect(testManager.livenessQueue.Len()).To(Equal(1))
+				testManager.livenessMutex.Lock()
+				Expect(testManager.vmLivenessProbeList).Should(HaveKey(vm.NamespacedName()))
+				testManager.livenessMutex.Unlock()
+
+				By("Should not add to the liveness queue again if the probe is not updated", func() {
+					testManager.AddToProberManager(vm)
+					Expect(testManager.livenessQueue.Len()).To(Equal(1))
+				})
+
+				By("Should remove from the liveness list if the annotation is removed", func() {
+					delete(vm.Annotations, vmopapi.LivenessProbeAnnotation)
+					testManager.AddToProberManager(vm)
+					testManager.livenessMutex.Lock()
+					Expect(testManager.vmLivenessProbeList).ShouldNot(HaveKey(vm.NamespacedName()))
+					testManager.livenessMutex.Unlock()
+				})
+			})
+
+			It("Should remove from the liveness list when removed from the prober manager", func() {
+				testManager.AddToProberManager(vm)
+				testManager.RemoveFromProberManager(vm)
+
+				testManager.livenessMutex.Lock()
+				Expect(testManager.vmLivenessProbeList).ShouldNot(HaveKey(vm.NamespacedName()))
+				testManager.livenessMutex.Unlock()
+			})
+		})
+
 		When("VM is first time being added to the prober manager", func() {
 			It("Should add to the queue and list", func() {
 				testManager.AddToProberManager(vm)
@@ -0,0 +1,256 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package worker
+
+import (
+	goctx "context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/prober/context"
+	"github.com/acharyasreej/vm-operator/pkg/prober/probe"
+	vmoprecord "github.com/acharyasreej/vm-operator/pkg/record"
+)
+
+const (
+	// Reasons of the events emitted by the liveness worker.
+	livenessProbeFailedReason        string = "LivenessProbeFailed"
+	livenessRemediatedReason         string = "LivenessRemediated"
+	livenessRemediationFailedReason  string = "LivenessRemediationFailed"
+	livenessRemediationBackOffReason string = "LivenessRemediationBackOff"
+
+	// livenessInitialBackOff and livenessMaxBackOff bound the per-VM exponential back off between
+	// remediations, so that a VM that never becomes live does not continuously reset.
+	livenessInitialBackOff = 1 * time.Minute
+	livenessMaxBackOff     = 30 * time.Minute
+)
+
+// Remediation related provider methods.
+type vmProviderRemediator interface {
+	ResetVirtualMachine(ctx goctx.Context, vm *vmopv1alpha1.VirtualMachine) error
+	PowerCycleVirtualMachine(ctx goctx.Context, vm *vmopv1alpha1.VirtualMachine) error
+}
+
+// livenessResults are the consecutive liveness probe results of a VM.
+type livenessResults struct {
+	failures  int32
+	successes int32
+}
+
+// LivenessTracker tracks the consecutive liveness probe results and the remediation back off of
+// VMs. It is shared by all the liveness workers since a VM may be processed by any of them.
+type LivenessTracker struct {
+	mutex   sync.Mutex
+	results map[types.UID]*livenessResults
+	backOff *flowcontrol.Backoff
+}
+
+// NewLivenessTracker creates a new LivenessTracker.
+func NewLivenessTracker() *LivenessTracker {
+	return &LivenessTracker{
+		results: make(map[types.UID]*livenessResults),
+		backOff: flowcontrol.NewBackOff(livenessInitialBackOff, livenessMaxBackOff),
+	}
+}
+
+// Remove forgets the probe results and the remediation back off of the VM.
+func (t *LivenessTracker) Remove(vm *vmopv1alpha1.VirtualMachine) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.results, vm.UID)
+	t.backOff.Reset(string(vm.UID))
+}
+
+// resetResults forgets the probe results, but not the remediation back off, of the VM.
+func (t *LivenessTracker) resetResults(vm *vmopv1alpha1.VirtualMachine) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.results, vm.UID)
+}
+
+// record records the probe result of the VM, and returns true if the VM has reached the failure
+// threshold. The failures are counted again from zero once the threshold has been reached.
+func (t *LivenessTracker) record(vm *vmopv1alpha1.VirtualMachine, res probe.Result, failureThreshold, successThreshold int32) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	results, ok := t.results[vm.UID]
+	if !ok {
+		results = &livenessResults{}
+		t.results[vm.UID] = results
+	}
+
+	switch res {
+	case probe.Success:
+		results.successes++
+		if results.successes >= successThreshold {
+			results.failures = 0
+		}
+	case probe.Failure:
+		results.successes = 0
+		results.failures++
+		if results.failures >= failureThreshold {
+			results.failures = 0
+			return true
+		}
+	default: // probe.Unknown
+		// We cannot tell if the VM is live so don't count it either way.
+	}
+
+	return false
+}
+
+// inBackOff returns true if the VM was remediated too recently to be remediated again. Otherwise, the
+// remediation is recorded and the back off of the VM is increased.
+func (t *LivenessTracker) inBackOff(vm *vmopv1alpha1.VirtualMachine) bool {
+	id := string(vm.UID)
+	now := t.backOff.Clock.Now()
+
+	if t.backOff.IsInBackOffSinceUpdate(id, now) {
+		return true
+	}
+
+	t.backOff.Next(id, now)
+	return false
+}
+
+// livenessWorker implements Worker interface.
+type livenessWorker struct {
+	queue      workqueue.DelayingInterface
+	prober     *probe.Prober
+	remediator vmProviderRemediator
+	tracker    *LivenessTracker
+	recorder   vmoprecord.Recorder
+}
+
+// NewLivenessWorker creates a new liveness worker to run liveness probes, and remediate the VMs that
+// fail them.
+func NewLivenessWorker(
+	queue workqueue.DelayingInterface,
+	prober *probe.Prober,
+	remediator vmProviderRemediator,
+	tracker *LivenessTracker,
+	recorder vmoprecord.Recorder,
+) Worker {
+	return &livenessWorker{
+		queue:      queue,
+		prober:     prober,
+		remediator: remediator,
+		tracker:    tracker,
+		recorder:   recorder,
+	}
+}
+
+func (w *livenessWorker) GetQueue() workqueue.DelayingInterface {
+	return w.queue
+}
+
+// CreateProbeContext creates a probe context for liveness probe. The ProbeSpec is nil if the VM
+// does not have a liveness probe.
+func (w *livenessWorker) CreateProbeContext(vm *vmopv1alpha1.VirtualMachine) (*context.ProbeContext, error) {
+	livenessProbe, err := vmopapi.GetLivenessProbe(vm)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &context.ProbeContext{
+		Context:   goctx.Background(),
+		Logger:    ctrl.Log.WithName("liveness-probe").WithValues("vmName", vm.NamespacedName()),
+		VM:        vm,
+		ProbeType: "liveness",
+	}
+
+	if livenessProbe != nil {
+		ctx.ProbeSpec = &livenessProbe.Probe
+		ctx.ProbeActions = &livenessProbe.ProbeActions
+		ctx.LivenessProbe = livenessProbe
+	}
+
+	return ctx, nil
+}
+
+// ProcessProbeResult counts the consecutive probe results, and takes the liveness probe action
+// once the VM reaches the failure threshold.
+func (w *livenessWorker) ProcessProbeResult(ctx *context.ProbeContext, res probe.Result, resErr error) error {
+	vm := ctx.VM
+
+	if vm.Status.PowerState != vmopv1alpha1.VirtualMachinePoweredOn {
+		// A VM that is not powered on is not expected to be live, so start counting again once it
+		// has been powered on.
+		w.tracker.resetResults(vm)
+		return nil
+	}
+
+	failureThreshold, successThreshold := int32(vmopapi.DefaultLivenessFailureThreshold), int32(vmopapi.DefaultLivenessSuccessThreshold)
+	action := vmopapi.LivenessActionEventOnly
+	if p := ctx.LivenessProbe; p != nil {
+		if p.FailureThreshold > 0 {
+			failureThreshold = p.FailureThreshold
+		}
+		if p.SuccessThreshold > 0 {
+			successThreshold = p.SuccessThreshold
+		}
+		if p.Action != "" {
+			action = p.Action
+		}
+	}
+
+	if !w.tracker.record(vm, res, failureThreshold, successThreshold) {
+		return nil
+	}
+
+	msg := ""
+	if resErr != nil {
+		msg = resErr.Error()
+	}
+
+	ctx.Logger.Info("VM failed its liveness probe", "failureThreshold", failureThreshold, "action", action, "lastError", msg)
+	w.recorder.Warnf(vm, livenessProbeFailedReason, "Liveness probe failed %d consecutive times: %s", failureThreshold, msg)
+
+	if action == vmopapi.LivenessActionEventOnly {
+		return nil
+	}
+
+	if w.tracker.inBackOff(vm) {
+		ctx.Logger.Info("Skipping liveness remediation since the VM was recently remediated", "action", action)
+		w.recorder.Warnf(vm, livenessRemediationBackOffReason, "Back-off performing %s of VM after failed liveness probe", action)
+		return nil
+	}
+
+	var err error
+	switch action {
+	case vmopapi.LivenessActionReset:
+		err = w.remediator.ResetVirtualMachine(ctx, vm)
+	case vmopapi.LivenessActionPowerCycle:
+		err = w.remediator.PowerCycleVirtualMachine(ctx, vm)
+	}
+
+	if err != nil {
+		// The next remediation attempt is still subject to the back off, so don't immediately requeue.
+		ctx.Logger.Error(err, "Failed to remediate VM after failed liveness probe", "action", action)
+		w.recorder.Warnf(vm, livenessRemediationFailedReason, "Failed to perform %s of VM: %v", action, err)
+		return nil
+	}
+
+	w.recorder.Eventf(vm, livenessRemediatedReason, "Performed %s of VM after failed liveness probe", action)
+	return nil
+}
+
+func (w *livenessWorker) DoProbe(ctx *context.ProbeContext) error {
+	res, err := runProbe(w.prober, ctx)
+	if err != nil {
+		ctx.Logger.V(4).Info("liveness probe fails", "result", res, "error", err.Error())
+	}
+	return w.ProcessProbeResult(ctx, res, err)
+}
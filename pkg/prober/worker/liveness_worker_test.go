@@ -0,0 +1,224 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package worker
+
+import (
+	goctx "context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgorecord "k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/prober/context"
+	fakeprobe "github.com/acharyasreej/vm-operator/pkg/prober/fake/probe"
+	"github.com/acharyasreej/vm-operator/pkg/prober/probe"
+	"github.com/acharyasreej/vm-operator/pkg/record"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/fake"
+)
+
+var _ = Describe("VirtualMachine liveness probes", func() {
+	var (
+		testWorker Worker
+		tracker    *LivenessTracker
+
+		vm         *vmopv1alpha1.VirtualMachine
+		annotation string
+		ctx        *context.ProbeContext
+
+		fakeEvents       chan string
+		fakeVMProvider   *fake.VMProvider
+		fakeHTTPGetProbe *fakeprobe.FakeProbe
+		probeResult      probe.Result
+		numResets        int
+		numPowerCycles   int
+	)
+
+	BeforeEach(func() {
+		vm = &vmopv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dummy-vm",
+				Namespace: "dummy-ns",
+				UID:       "dummy-uid",
+			},
+			Spec: vmopv1alpha1.VirtualMachineSpec{
+				ClassName: "dummy-vmclass",
+			},
+			Status: vmopv1alpha1.VirtualMachineStatus{
+				PowerState: vmopv1alpha1.VirtualMachinePoweredOn,
+			},
+		}
+		annotation = `{"httpGet": {"port": 8080}, "periodSeconds": 1, "failureThreshold": 2, "action": "Reset"}`
+
+		eventRecorder := clientgorecord.NewFakeRecorder(1024)
+		fakeEvents = eventRecorder.Events
+
+		numResets, numPowerCycles = 0, 0
+		fakeVMProvider = fake.NewVMProvider()
+		fakeVMProvider.ResetVirtualMachineFn = func(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine) error {
+			numResets++
+			return nil
+		}
+		fakeVMProvider.PowerCycleVirtualMachineFn = func(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine) error {
+			numPowerCycles++
+			return nil
+		}
+
+		probeResult = probe.Failure
+		fakeHTTPGetProbe = fakeprobe.NewFakeProbe().(*fakeprobe.FakeProbe)
+		fakeHTTPGetProbe.ProbeFn = func(ctx *context.ProbeContext) (probe.Result, error) {
+			if probeResult == probe.Failure {
+				return probeResult, fmt.Errorf("connection refused")
+			}
+			return probeResult, nil
+		}
+		prober := &probe.Prober{
+			HTTPGetProbe: fakeHTTPGetProbe,
+		}
+
+		tracker = NewLivenessTracker()
+		testWorker = NewLivenessWorker(workqueue.NewNamedDelayingQueue("test"), prober, fakeVMProvider, tracker, record.New(eventRecorder))
+	})
+
+	JustBeforeEach(func() {
+		vm.Annotations = map[string]string{vmopapi.LivenessProbeAnnotation: annotation}
+		var err error
+		ctx, err = testWorker.CreateProbeContext(vm)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("Should create the probe context from the annotation", func() {
+		Expect(ctx.ProbeSpec).ToNot(BeNil())
+		Expect(ctx.ProbeSpec.PeriodSeconds).To(BeEquivalentTo(1))
+		Expect(ctx.ProbeActions).ToNot(BeNil())
+		Expect(ctx.ProbeActions.HTTPGet).ToNot(BeNil())
+		Expect(ctx.LivenessProbe.FailureThreshold).To(BeEquivalentTo(2))
+	})
+
+	When("VM does not have a liveness probe", func() {
+		JustBeforeEach(func() {
+			delete(vm.Annotations, vmopapi.LivenessProbeAnnotation)
+			var err error
+			ctx, err = testWorker.CreateProbeContext(vm)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should not have a probe spec", func() {
+			Expect(ctx.ProbeSpec).To(BeNil())
+		})
+	})
+
+	When("liveness probe annotation is invalid", func() {
+		It("Should return an error", func() {
+			vm.Annotations[vmopapi.LivenessProbeAnnotation] = "not-json"
+			_, err := testWorker.CreateProbeContext(vm)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	It("Should reset the VM once the failure threshold is reached", func() {
+		Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		Expect(numResets).To(Equal(0))
+		Expect(fakeEvents).ShouldNot(Receive())
+
+		Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		Expect(numResets).To(Equal(1))
+		Expect(fakeEvents).Should(Receive(ContainSubstring(livenessProbeFailedReason)))
+		Expect(fakeEvents).Should(Receive(ContainSubstring(livenessRemediatedReason)))
+	})
+
+	It("Should not reset the VM again while in back off", func() {
+		for i := 0; i < 4; i++ {
+			Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		}
+		Expect(numResets).To(Equal(1))
+		Expect(fakeEvents).Should(Receive(ContainSubstring(livenessProbeFailedReason)))
+		Expect(fakeEvents).Should(Receive(ContainSubstring(livenessRemediatedReason)))
+		Expect(fakeEvents).Should(Receive(ContainSubstring(livenessProbeFailedReason)))
+		Expect(fakeEvents).Should(Receive(ContainSubstring(livenessRemediationBackOffReason)))
+
+		By("Should reset the VM again once removed from the tracker", func() {
+			tracker.Remove(vm)
+			Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+			Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+			Expect(numResets).To(Equal(2))
+		})
+	})
+
+	It("Should not count failures once the success threshold is reached", func() {
+		Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		probeResult = probe.Success
+		Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		probeResult = probe.Failure
+		Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		Expect(numResets).To(Equal(0))
+	})
+
+	It("Should not count unknown results", func() {
+		Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		probeResult = probe.Unknown
+		Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		Expect(numResets).To(Equal(0))
+		probeResult = probe.Failure
+		Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		Expect(numResets).To(Equal(1))
+	})
+
+	It("Should not count failures while the VM is powered off", func() {
+		Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		vm.Status.PowerState = vmopv1alpha1.VirtualMachinePoweredOff
+		Expect(testWorker.ProcessProbeResult(ctx, probe.Failure, fmt.Errorf("virtual machine is not powered on"))).Should(Succeed())
+		vm.Status.PowerState = vmopv1alpha1.VirtualMachinePoweredOn
+		Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+		Expect(numResets).To(Equal(0))
+	})
+
+	When("action is PowerCycle", func() {
+		BeforeEach(func() {
+			annotation = `{"httpGet": {"port": 8080}, "failureThreshold": 1, "action": "PowerCycle"}`
+		})
+
+		It("Should power cycle the VM", func() {
+			Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+			Expect(numPowerCycles).To(Equal(1))
+			Expect(numResets).To(Equal(0))
+		})
+	})
+
+	When("action is not specified", func() {
+		BeforeEach(func() {
+			annotation = `{"httpGet": {"port": 8080}}`
+		})
+
+		It("Should only emit an event after the default failure threshold", func() {
+			for i := 0; i < vmopapi.DefaultLivenessFailureThreshold; i++ {
+				Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+			}
+			Expect(fakeEvents).Should(Receive(ContainSubstring(livenessProbeFailedReason)))
+			Expect(numResets).To(Equal(0))
+			Expect(numPowerCycles).To(Equal(0))
+		})
+	})
+
+	When("remediation fails", func() {
+		BeforeEach(func() {
+			fakeVMProvider.ResetVirtualMachineFn = func(_ goctx.Context, _ *vmopv1alpha1.VirtualMachine) error {
+				return fmt.Errorf("reset error")
+			}
+		})
+
+		It("Should emit a failure event", func() {
+			Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+			Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+			Expect(fakeEvents).Should(Receive(ContainSubstring(livenessProbeFailedReason)))
+			Expect(fakeEvents).Should(Receive(ContainSubstring("reset error")))
+		})
+	})
+})
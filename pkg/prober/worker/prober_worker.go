@@ -4,10 +4,13 @@
 package worker
 
 import (
+	"fmt"
+
 	"k8s.io/client-go/util/workqueue"
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/prober/context"
 	"github.com/acharyasreej/vm-operator/pkg/prober/probe"
 )
@@ -19,3 +22,32 @@ type Worker interface {
 	DoProbe(ctx *context.ProbeContext) error
 	ProcessProbeResult(ctx *context.ProbeContext, res probe.Result, resErr error) error
 }
+
+// getProbe returns a specific type of probe method.
+func getProbe(prober *probe.Prober, probeSpec *vmopv1alpha1.Probe, probeActions *vmopapi.ProbeActions) probe.Probe {
+	if probeSpec.TCPSocket != nil {
+		return prober.TCPProbe
+	}
+	if probeSpec.GuestHeartbeat != nil {
+		return prober.GuestHeartbeat
+	}
+	if probeActions != nil {
+		if probeActions.HTTPGet != nil {
+			return prober.HTTPGetProbe
+		}
+		if probeActions.Exec != nil {
+			return prober.ExecProbe
+		}
+	}
+
+	return nil
+}
+
+// runProbe runs a specific type of probe based on the probe spec and actions in the context.
+func runProbe(prober *probe.Prober, ctx *context.ProbeContext) (probe.Result, error) {
+	if p := getProbe(prober, ctx.ProbeSpec, ctx.ProbeActions); p != nil {
+		return p.Probe(ctx)
+	}
+
+	return probe.Unknown, fmt.Errorf("unknown action specified for VM %s %s probe", ctx.VM.NamespacedName(), ctx.ProbeType)
+}
@@ -5,7 +5,6 @@ package worker
 
 import (
 	goctx "context"
-	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/util/workqueue"
@@ -16,6 +15,7 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	"github.com/acharyasreej/vm-operator/pkg/patch"
 	"github.com/acharyasreej/vm-operator/pkg/prober/context"
@@ -64,13 +64,23 @@ func (w *readinessWorker) CreateProbeContext(vm *vmopv1alpha1.VirtualMachine) (*
 		return nil, err
 	}
 
+	logger := ctrl.Log.WithName("readiness-probe").WithValues("vmName", vm.NamespacedName())
+
+	// The webhook validates the annotation so this is not expected to fail. If it does, the probe
+	// is run without the actions and reports an unknown action.
+	probeActions, err := vmopapi.GetReadinessProbeActions(vm)
+	if err != nil {
+		logger.Error(err, "failed to decode readiness probe actions annotation")
+	}
+
 	return &context.ProbeContext{
-		Context:     goctx.Background(),
-		Logger:      ctrl.Log.WithName("readiness-probe").WithValues("vmName", vm.NamespacedName()),
-		PatchHelper: patchHelper,
-		VM:          vm,
-		ProbeSpec:   vm.Spec.ReadinessProbe,
-		ProbeType:   "readiness",
+		Context:      goctx.Background(),
+		Logger:       logger,
+		PatchHelper:  patchHelper,
+		VM:           vm,
+		ProbeSpec:    vm.Spec.ReadinessProbe,
+		ProbeActions: probeActions,
+		ProbeType:    "readiness",
 	}, nil
 }
 
@@ -114,25 +124,9 @@ func (w *readinessWorker) DoProbe(ctx *context.ProbeContext) error {
 	return w.ProcessProbeResult(ctx, res, err)
 }
 
-// getProbe returns a specific type of probe method.
-func (w *readinessWorker) getProbe(probeSpec *vmopv1alpha1.Probe) probe.Probe {
-	if probeSpec.TCPSocket != nil {
-		return w.prober.TCPProbe
-	}
-	if probeSpec.GuestHeartbeat != nil {
-		return w.prober.GuestHeartbeat
-	}
-
-	return nil
-}
-
-// runProbe runs a specific type of probe based on the VM probe spec.
+// runProbe runs a specific type of probe based on the VM probe spec and actions.
 func (w *readinessWorker) runProbe(ctx *context.ProbeContext) (probe.Result, error) {
-	if p := w.getProbe(ctx.ProbeSpec); p != nil {
-		return p.Probe(ctx)
-	}
-
-	return probe.Unknown, fmt.Errorf("unknown action specified for VM %s readiness probe", ctx.VM.NamespacedName())
+	return runProbe(w.prober, ctx)
 }
 
 // getCondition returns condition based on VM probe results.
@@ -20,6 +20,7 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	"github.com/acharyasreej/vm-operator/pkg/prober/context"
 	fakeprobe "github.com/acharyasreej/vm-operator/pkg/prober/fake/probe"
@@ -40,6 +41,8 @@ var _ = Describe("VirtualMachine readiness probes", func() {
 		fakeRecorder       record.Recorder
 		fakeEvents         chan string
 		fakeTCPProbe       *fakeprobe.FakeProbe
+		fakeHTTPGetProbe   *fakeprobe.FakeProbe
+		fakeExecProbe      *fakeprobe.FakeProbe
 		fakeHeartbeatProbe *fakeprobe.FakeProbe
 	)
 
@@ -63,9 +66,13 @@ var _ = Describe("VirtualMachine readiness probes", func() {
 
 		queue := workqueue.NewNamedDelayingQueue("test")
 		fakeTCPProbe = fakeprobe.NewFakeProbe().(*fakeprobe.FakeProbe)
+		fakeHTTPGetProbe = fakeprobe.NewFakeProbe().(*fakeprobe.FakeProbe)
+		fakeExecProbe = fakeprobe.NewFakeProbe().(*fakeprobe.FakeProbe)
 		fakeHeartbeatProbe = fakeprobe.NewFakeProbe().(*fakeprobe.FakeProbe)
 		prober := &probe.Prober{
 			TCPProbe:       fakeTCPProbe,
+			HTTPGetProbe:   fakeHTTPGetProbe,
+			ExecProbe:      fakeExecProbe,
 			GuestHeartbeat: fakeHeartbeatProbe,
 		}
 		testWorker = NewReadinessWorker(queue, prober, fakeClient, fakeRecorder)
@@ -185,6 +192,71 @@ var _ = Describe("VirtualMachine readiness probes", func() {
 			Expect(condition.Message).To(ContainSubstring("heartbeat error"))
 		})
 	})
+
+	Context("Probe actions annotation", func() {
+		var (
+			annotation string
+		)
+
+		JustBeforeEach(func() {
+			vm.Spec.ReadinessProbe = &vmopv1alpha1.Probe{PeriodSeconds: 1}
+			vm.Annotations = map[string]string{vmopapi.ReadinessProbeActionsAnnotation: annotation}
+			Expect(fakeClient.Create(goctx.Background(), vm)).Should(Succeed())
+			Expect(fakeClient.Get(goctx.Background(), vmKey, vm)).Should(Succeed())
+			var err error
+			ctx, err = testWorker.CreateProbeContext(vm)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		When("HTTP GET action is specified", func() {
+			BeforeEach(func() {
+				annotation = `{"httpGet": {"path": "/healthz", "port": 8080}}`
+			})
+
+			It("Should run the HTTP GET probe", func() {
+				fakeHTTPGetProbe.ProbeFn = func(ctx *context.ProbeContext) (probe.Result, error) {
+					Expect(ctx.ProbeActions).ToNot(BeNil())
+					Expect(ctx.ProbeActions.HTTPGet).ToNot(BeNil())
+					Expect(ctx.ProbeActions.HTTPGet.Path).To(Equal("/healthz"))
+					return probe.Success, nil
+				}
+
+				Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+				checkReadyCondition(fakeClient, vmKey, corev1.ConditionTrue)
+			})
+		})
+
+		When("exec action is specified", func() {
+			BeforeEach(func() {
+				annotation = `{"exec": {"command": ["/bin/true"], "credentialsSecretName": "creds"}}`
+			})
+
+			It("Should run the exec probe", func() {
+				fakeExecProbe.ProbeFn = func(ctx *context.ProbeContext) (probe.Result, error) {
+					return probe.Failure, fmt.Errorf("exec error")
+				}
+
+				Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+				Expect(fakeClient.Get(ctx, vmKey, vm)).Should(Succeed())
+				condition := conditions.Get(vm, vmopv1alpha1.ReadyCondition)
+				Expect(condition).ToNot(BeNil())
+				Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+				Expect(condition.Message).To(ContainSubstring("exec error"))
+			})
+		})
+
+		When("annotation is invalid", func() {
+			BeforeEach(func() {
+				annotation = "not-json"
+			})
+
+			It("Should set ReadyCondition to unknown", func() {
+				Expect(ctx.ProbeActions).To(BeNil())
+				Expect(testWorker.DoProbe(ctx)).Should(Succeed())
+				checkReadyCondition(fakeClient, vmKey, corev1.ConditionUnknown)
+			})
+		})
+	})
 })
 
 func TestReadinessProbeWorker(t *testing.T) {
@@ -5,9 +5,12 @@ package fake
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
@@ -22,14 +25,35 @@ import (
 // expected to evolve as more tests get added in the future.
 
 type funcs struct {
-	DoesVirtualMachineExistFn         func(ctx context.Context, vm *v1alpha1.VirtualMachine) (bool, error)
-	CreateVirtualMachineFn            func(ctx context.Context, vm *v1alpha1.VirtualMachine, vmConfigArgs vmprovider.VMConfigArgs) error
-	UpdateVirtualMachineFn            func(ctx context.Context, vm *v1alpha1.VirtualMachine, vmConfigArgs vmprovider.VMConfigArgs) error
-	DeleteVirtualMachineFn            func(ctx context.Context, vm *v1alpha1.VirtualMachine) error
-	GetVirtualMachineGuestHeartbeatFn func(ctx context.Context, vm *v1alpha1.VirtualMachine) (v1alpha1.GuestHeartbeatStatus, error)
+	DoesVirtualMachineExistFn            func(ctx context.Context, vm *v1alpha1.VirtualMachine) (bool, error)
+	CreateVirtualMachineFn               func(ctx context.Context, vm *v1alpha1.VirtualMachine, vmConfigArgs vmprovider.VMConfigArgs) error
+	UpdateVirtualMachineFn               func(ctx context.Context, vm *v1alpha1.VirtualMachine, vmConfigArgs vmprovider.VMConfigArgs) error
+	DeleteVirtualMachineFn               func(ctx context.Context, vm *v1alpha1.VirtualMachine) error
+	GetVirtualMachineGuestHeartbeatFn    func(ctx context.Context, vm *v1alpha1.VirtualMachine) (v1alpha1.GuestHeartbeatStatus, error)
+	RunVirtualMachineGuestCommandFn      func(ctx context.Context, vm *v1alpha1.VirtualMachine, cmd vmprovider.GuestCommand) (int32, error)
+	ResetVirtualMachineFn                func(ctx context.Context, vm *v1alpha1.VirtualMachine) error
+	PowerCycleVirtualMachineFn           func(ctx context.Context, vm *v1alpha1.VirtualMachine) error
+	BackupVirtualMachineFn               func(ctx context.Context, vm *v1alpha1.VirtualMachine, args vmprovider.VMBackupArgs) error
+	ImportVirtualMachineFn               func(ctx context.Context, vm *v1alpha1.VirtualMachine) (vmprovider.VMHardware, error)
+	RelocateVirtualMachineFn             func(ctx context.Context, vm *v1alpha1.VirtualMachine, zone string) error
+	PlaceVirtualMachineInstanceStorageFn func(ctx context.Context, vm *v1alpha1.VirtualMachine) error
+	PublishVirtualMachineFn              func(ctx context.Context, vm *v1alpha1.VirtualMachine, args vmprovider.VMPublishArgs) (string, error)
+
+	CreateVirtualMachineSnapshotFn func(ctx context.Context, vm *v1alpha1.VirtualMachine, args vmprovider.VMSnapshotArgs) (string, error)
+	ListVirtualMachineSnapshotsFn  func(ctx context.Context, vm *v1alpha1.VirtualMachine) ([]vmprovider.VMSnapshot, error)
+	RevertVirtualMachineSnapshotFn func(ctx context.Context, vm *v1alpha1.VirtualMachine, snapshotID string) error
+	DeleteVirtualMachineSnapshotFn func(ctx context.Context, vm *v1alpha1.VirtualMachine, snapshotID string) error
 
 	ListVirtualMachineImagesFromContentLibraryFn func(ctx context.Context, cl v1alpha1.ContentLibraryProvider, currentCLImages map[string]v1alpha1.VirtualMachineImage) ([]*v1alpha1.VirtualMachineImage, error)
 	DoesContentLibraryExistFn                    func(ctx context.Context, cl *v1alpha1.ContentLibraryProvider) (bool, error)
+	GetContentLibraryChangeTokenFn               func(ctx context.Context, clUUID string) (string, error)
+
+	CreateOrUpdateSubscribedContentLibraryFn func(ctx context.Context, clUUID string, sub vmprovider.ContentLibrarySubscription) (string, error)
+	GetContentLibraryLastSyncTimeFn          func(ctx context.Context, clUUID string) (*time.Time, error)
+	IsContentLibraryWritableFn               func(ctx context.Context, clUUID string) (bool, error)
+	DeleteContentLibraryFn                   func(ctx context.Context, clUUID, libraryName string) error
+	ImportContentLibraryItemFn               func(ctx context.Context, clUUID, itemName, itemDescription string, filePaths []string) (string, error)
+	DeleteContentLibraryItemFn               func(ctx context.Context, itemID string) error
 
 	UpdateVcPNIDFn                  func(ctx context.Context, vcPNID, vcPort string) error
 	ClearSessionsAndClientFn        func(ctx context.Context)
@@ -46,6 +70,10 @@ type VMProvider struct {
 	funcs
 	vmMap             map[client.ObjectKey]*v1alpha1.VirtualMachine
 	resourcePolicyMap map[client.ObjectKey]*v1alpha1.VirtualMachineSetResourcePolicy
+	snapshotMap       map[client.ObjectKey][]vmprovider.VMSnapshot
+	nextSnapshotID    int
+	libraryMap        map[string]vmprovider.ContentLibrarySubscription
+	nextLibraryID     int
 }
 
 var _ vmprovider.VirtualMachineProviderInterface = &VMProvider{}
@@ -57,6 +85,8 @@ func (s *VMProvider) Reset() {
 	s.funcs = funcs{}
 	s.vmMap = make(map[client.ObjectKey]*v1alpha1.VirtualMachine)
 	s.resourcePolicyMap = make(map[client.ObjectKey]*v1alpha1.VirtualMachineSetResourcePolicy)
+	s.snapshotMap = make(map[client.ObjectKey][]vmprovider.VMSnapshot)
+	s.libraryMap = make(map[string]vmprovider.ContentLibrarySubscription)
 }
 
 func (s *VMProvider) DoesVirtualMachineExist(ctx context.Context, vm *v1alpha1.VirtualMachine) (bool, error) {
@@ -113,8 +143,171 @@ func (s *VMProvider) GetVirtualMachineGuestHeartbeat(ctx context.Context, vm *v1
 	return "", nil
 }
 
+func (s *VMProvider) RunVirtualMachineGuestCommand(ctx context.Context, vm *v1alpha1.VirtualMachine, cmd vmprovider.GuestCommand) (int32, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.RunVirtualMachineGuestCommandFn != nil {
+		return s.RunVirtualMachineGuestCommandFn(ctx, vm, cmd)
+	}
+	return 0, nil
+}
+
+func (s *VMProvider) ResetVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.ResetVirtualMachineFn != nil {
+		return s.ResetVirtualMachineFn(ctx, vm)
+	}
+	return nil
+}
+
+func (s *VMProvider) PowerCycleVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.PowerCycleVirtualMachineFn != nil {
+		return s.PowerCycleVirtualMachineFn(ctx, vm)
+	}
+	return nil
+}
+
+func (s *VMProvider) BackupVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine, args vmprovider.VMBackupArgs) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.BackupVirtualMachineFn != nil {
+		return s.BackupVirtualMachineFn(ctx, vm, args)
+	}
+	return nil
+}
+
+func (s *VMProvider) ImportVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine) (vmprovider.VMHardware, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.ImportVirtualMachineFn != nil {
+		return s.ImportVirtualMachineFn(ctx, vm)
+	}
+	s.addToVMMap(vm)
+	return vmprovider.VMHardware{}, nil
+}
+
+func (s *VMProvider) RelocateVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine, zone string) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.RelocateVirtualMachineFn != nil {
+		return s.RelocateVirtualMachineFn(ctx, vm, zone)
+	}
+	return nil
+}
+
+func (s *VMProvider) PlaceVirtualMachineInstanceStorage(ctx context.Context, vm *v1alpha1.VirtualMachine) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.PlaceVirtualMachineInstanceStorageFn != nil {
+		return s.PlaceVirtualMachineInstanceStorageFn(ctx, vm)
+	}
+	return nil
+}
+
+func (s *VMProvider) PublishVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine, args vmprovider.VMPublishArgs) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.PublishVirtualMachineFn != nil {
+		return s.PublishVirtualMachineFn(ctx, vm, args)
+	}
+	return fmt.Sprintf("%s-%s", args.LibraryUUID, args.ItemName), nil
+}
+
+func (s *VMProvider) CreateVirtualMachineSnapshot(ctx context.Context, vm *v1alpha1.VirtualMachine, args vmprovider.VMSnapshotArgs) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.CreateVirtualMachineSnapshotFn != nil {
+		return s.CreateVirtualMachineSnapshotFn(ctx, vm, args)
+	}
+
+	objectKey := client.ObjectKey{Namespace: vm.Namespace, Name: vm.Name}
+	snapshots := s.snapshotMap[objectKey]
+
+	var parentID string
+	for i := range snapshots {
+		if snapshots[i].Current {
+			parentID = snapshots[i].ID
+			snapshots[i].Current = false
+		}
+	}
+
+	s.nextSnapshotID++
+	snapshot := vmprovider.VMSnapshot{
+		ID:          fmt.Sprintf("snapshot-%d", s.nextSnapshotID),
+		Name:        args.Name,
+		Description: args.Description,
+		CreateTime:  time.Now(),
+		PowerState:  vm.Status.PowerState,
+		Quiesced:    args.Quiesce,
+		Current:     true,
+		ParentID:    parentID,
+	}
+	s.snapshotMap[objectKey] = append(snapshots, snapshot)
+
+	return snapshot.ID, nil
+}
+
+func (s *VMProvider) ListVirtualMachineSnapshots(ctx context.Context, vm *v1alpha1.VirtualMachine) ([]vmprovider.VMSnapshot, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.ListVirtualMachineSnapshotsFn != nil {
+		return s.ListVirtualMachineSnapshotsFn(ctx, vm)
+	}
+
+	objectKey := client.ObjectKey{Namespace: vm.Namespace, Name: vm.Name}
+	return append([]vmprovider.VMSnapshot(nil), s.snapshotMap[objectKey]...), nil
+}
+
+func (s *VMProvider) RevertVirtualMachineSnapshot(ctx context.Context, vm *v1alpha1.VirtualMachine, snapshotID string) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.RevertVirtualMachineSnapshotFn != nil {
+		return s.RevertVirtualMachineSnapshotFn(ctx, vm, snapshotID)
+	}
+
+	objectKey := client.ObjectKey{Namespace: vm.Namespace, Name: vm.Name}
+	snapshots := s.snapshotMap[objectKey]
+
+	found := false
+	for i := range snapshots {
+		snapshots[i].Current = snapshots[i].ID == snapshotID
+		found = found || snapshots[i].Current
+	}
+	if !found {
+		return fmt.Errorf("snapshot %q of VM %s not found", snapshotID, vm.NamespacedName())
+	}
+
+	return nil
+}
+
+func (s *VMProvider) DeleteVirtualMachineSnapshot(ctx context.Context, vm *v1alpha1.VirtualMachine, snapshotID string) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.DeleteVirtualMachineSnapshotFn != nil {
+		return s.DeleteVirtualMachineSnapshotFn(ctx, vm, snapshotID)
+	}
+
+	objectKey := client.ObjectKey{Namespace: vm.Namespace, Name: vm.Name}
+	snapshots := s.snapshotMap[objectKey][:0]
+	for _, snapshot := range s.snapshotMap[objectKey] {
+		if snapshot.ID != snapshotID {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	s.snapshotMap[objectKey] = snapshots
+
+	return nil
+}
+
 func (s *VMProvider) Initialize(stop <-chan struct{}) {}
 
+func (s *VMProvider) VirtualMachineEvents() <-chan event.GenericEvent {
+	return nil
+}
+
 func (s *VMProvider) Name() string {
 	return "fake"
 }
@@ -224,6 +417,106 @@ func (s *VMProvider) ListVirtualMachineImagesFromContentLibrary(ctx context.Cont
 	return []*v1alpha1.VirtualMachineImage{}, nil
 }
 
+func (s *VMProvider) GetContentLibraryChangeToken(ctx context.Context, clUUID string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.GetContentLibraryChangeTokenFn != nil {
+		return s.GetContentLibraryChangeTokenFn(ctx, clUUID)
+	}
+
+	// An empty token always results in a full sync.
+	return "", nil
+}
+
+func (s *VMProvider) CreateOrUpdateSubscribedContentLibrary(ctx context.Context, clUUID string, sub vmprovider.ContentLibrarySubscription) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.CreateOrUpdateSubscribedContentLibraryFn != nil {
+		return s.CreateOrUpdateSubscribedContentLibraryFn(ctx, clUUID, sub)
+	}
+
+	if existing, ok := s.libraryMap[clUUID]; ok && existing.Name != sub.Name {
+		return "", fmt.Errorf("content library %s is not the subscribed library %s", clUUID, sub.Name)
+	}
+
+	if _, ok := s.libraryMap[clUUID]; !ok || clUUID == "" {
+		s.nextLibraryID++
+		clUUID = fmt.Sprintf("fake-library-%d", s.nextLibraryID)
+	}
+	s.libraryMap[clUUID] = sub
+
+	return clUUID, nil
+}
+
+func (s *VMProvider) GetContentLibraryLastSyncTime(ctx context.Context, clUUID string) (*time.Time, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.GetContentLibraryLastSyncTimeFn != nil {
+		return s.GetContentLibraryLastSyncTimeFn(ctx, clUUID)
+	}
+
+	if _, ok := s.libraryMap[clUUID]; !ok {
+		return nil, fmt.Errorf("content library %s not found", clUUID)
+	}
+
+	now := time.Now()
+	return &now, nil
+}
+
+func (s *VMProvider) IsContentLibraryWritable(ctx context.Context, clUUID string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.IsContentLibraryWritableFn != nil {
+		return s.IsContentLibraryWritableFn(ctx, clUUID)
+	}
+
+	// The libraries created by the fake provider are subscribed libraries.
+	_, subscribed := s.libraryMap[clUUID]
+	return !subscribed, nil
+}
+
+func (s *VMProvider) DeleteContentLibrary(ctx context.Context, clUUID, libraryName string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.DeleteContentLibraryFn != nil {
+		return s.DeleteContentLibraryFn(ctx, clUUID, libraryName)
+	}
+
+	if existing, ok := s.libraryMap[clUUID]; ok && existing.Name != libraryName {
+		return fmt.Errorf("content library %s is not the subscribed library %s", clUUID, libraryName)
+	}
+
+	delete(s.libraryMap, clUUID)
+	return nil
+}
+
+func (s *VMProvider) ImportContentLibraryItem(ctx context.Context, clUUID, itemName, itemDescription string, filePaths []string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.ImportContentLibraryItemFn != nil {
+		return s.ImportContentLibraryItemFn(ctx, clUUID, itemName, itemDescription, filePaths)
+	}
+
+	return fmt.Sprintf("%s-%s", clUUID, itemName), nil
+}
+
+func (s *VMProvider) DeleteContentLibraryItem(ctx context.Context, itemID string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.DeleteContentLibraryItemFn != nil {
+		return s.DeleteContentLibraryItemFn(ctx, itemID)
+	}
+
+	return nil
+}
+
 func (s *VMProvider) ListVirtualMachineImages(ctx context.Context, namespace string) ([]*v1alpha1.VirtualMachineImage, error) {
 	return []*v1alpha1.VirtualMachineImage{}, nil
 }
@@ -269,6 +562,8 @@ func NewVMProvider() *VMProvider {
 	provider := VMProvider{
 		vmMap:             map[client.ObjectKey]*v1alpha1.VirtualMachine{},
 		resourcePolicyMap: map[client.ObjectKey]*v1alpha1.VirtualMachineSetResourcePolicy{},
+		snapshotMap:       map[client.ObjectKey][]vmprovider.VMSnapshot{},
+		libraryMap:        map[string]vmprovider.ContentLibrarySubscription{},
 	}
 	return &provider
 }
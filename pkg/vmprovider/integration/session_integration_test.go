@@ -24,10 +24,13 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg"
 	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/lib"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/config"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
 	vmopsession "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/session"
 	"github.com/acharyasreej/vm-operator/test/builder"
@@ -187,6 +190,140 @@ var _ = Describe("Sessions", func() {
 				})
 			})
 		})
+
+		Context("When the VM is restored with the BackupRestore FSS enabled", func() {
+			var origIsVMServiceBackupRestoreFSSEnabled func() bool
+
+			BeforeEach(func() {
+				origIsVMServiceBackupRestoreFSSEnabled = lib.IsVMServiceBackupRestoreFSSEnabled
+				lib.IsVMServiceBackupRestoreFSSEnabled = func() bool {
+					return true
+				}
+			})
+
+			AfterEach(func() {
+				lib.IsVMServiceBackupRestoreFSSEnabled = origIsVMServiceBackupRestoreFSSEnabled
+			})
+
+			It("should back up the VM and adopt the restored VM by its instance UUID", func() {
+				imageName := "test-item"
+				vmName := "getvm-restored"
+
+				vmConfigArgs := getVmConfigArgs(testNamespace, vmName, imageName)
+				vm := getVirtualMachineInstance(vmName, testNamespace, imageName, vmConfigArgs.VMClass.Name)
+
+				clonedVM, err := session.CloneVirtualMachine(vmContext(ctx, vm), vmConfigArgs)
+				Expect(err).NotTo(HaveOccurred())
+				moId, err := clonedVM.UniqueID(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(session.BackupVirtualMachine(vmContext(ctx, vm), vmprovider.VMBackupArgs{})).To(Succeed())
+				moVM, err := clonedVM.GetProperties(ctx, []string{"config.extraConfig", "config.instanceUuid"})
+				Expect(err).NotTo(HaveOccurred())
+				extraConfig := vmopsession.ExtraConfigToMap(moVM.Config.ExtraConfig)
+				Expect(extraConfig).To(HaveKey(constants.BackupVMKubeDataExtraConfigKey))
+
+				// The backup tool may restore the vSphere VM with another name.
+				Expect(clonedVM.Rename(ctx, vmName+"-restored")).To(Succeed())
+
+				restoredVM := getVirtualMachineInstance(vmName, testNamespace, imageName, vmConfigArgs.VMClass.Name)
+				restoredVM.Status.UniqueID = ""
+
+				By("should not find the VM without the restore annotation", func() {
+					_, err := session.GetVirtualMachine(vmContext(ctx, restoredVM))
+					Expect(err).To(HaveOccurred())
+				})
+
+				By("should not adopt the VM for another VirtualMachine", func() {
+					otherVM := getVirtualMachineInstance("other-vm", testNamespace, imageName, vmConfigArgs.VMClass.Name)
+					otherVM.Status.UniqueID = ""
+					otherVM.Annotations = map[string]string{
+						vmopapi.RestoreInstanceUUIDAnnotation: moVM.Config.InstanceUuid,
+					}
+					_, err := session.GetVirtualMachine(vmContext(ctx, otherVM))
+					Expect(err).To(HaveOccurred())
+				})
+
+				restoredVM.Annotations = map[string]string{
+					vmopapi.RestoreInstanceUUIDAnnotation: moVM.Config.InstanceUuid,
+				}
+				vm1, err := session.GetVirtualMachine(vmContext(ctx, restoredVM))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(vm1.UniqueID(ctx)).To(Equal(moId))
+			})
+		})
+	})
+
+	Describe("Import VM", func() {
+		It("should move the VM into the namespace and return its hardware", func() {
+			objVM, err := session.Finder.VirtualMachine(ctx, "DC0_H0_VM1")
+			Expect(err).NotTo(HaveOccurred())
+			resVM, err := resources.NewVMFromObject(objVM)
+			Expect(err).NotTo(HaveOccurred())
+			moVM, err := resVM.GetProperties(ctx, []string{"config.instanceUuid", "config.hardware"})
+			Expect(err).NotTo(HaveOccurred())
+
+			vm := getVirtualMachineInstance("imported-vm", testNamespace, "", "")
+			vm.Annotations = map[string]string{
+				vmopapi.ImportVMInstanceUUIDAnnotation: moVM.Config.InstanceUuid,
+			}
+
+			hardware, err := session.ImportVirtualMachine(vmContext(ctx, vm))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hardware.NumCPUs).To(BeEquivalentTo(moVM.Config.Hardware.NumCPU))
+			Expect(hardware.Memory.Value()).To(BeEquivalentTo(int64(moVM.Config.Hardware.MemoryMB) * 1024 * 1024))
+			Expect(vm.Status.UniqueID).To(Equal(objVM.Reference().Value))
+
+			By("should find the imported VM by path", func() {
+				vm.Status.UniqueID = ""
+				vm1, err := session.GetVirtualMachine(vmContext(ctx, vm))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(vm1.UniqueID(ctx)).To(Equal(objVM.Reference().Value))
+			})
+
+			By("should not change the VM when imported again", func() {
+				_, err := session.ImportVirtualMachine(vmContext(ctx, vm))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(vm.Status.UniqueID).To(Equal(objVM.Reference().Value))
+			})
+
+			By("should not import when the MoID is of another VM", func() {
+				otherVM, err := session.Finder.VirtualMachine(ctx, "DC0_H0_VM0")
+				Expect(err).NotTo(HaveOccurred())
+
+				vm2 := getVirtualMachineInstance("imported-vm-2", testNamespace, "", "")
+				vm2.Annotations = map[string]string{
+					vmopapi.ImportVMMoIDAnnotation:         otherVM.Reference().Value,
+					vmopapi.ImportVMInstanceUUIDAnnotation: moVM.Config.InstanceUuid,
+				}
+				_, err = session.ImportVirtualMachine(vmContext(ctx, vm2))
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("does not have instance UUID"))
+			})
+		})
+	})
+
+	Describe("Relocate VM", func() {
+		It("should move the VM into the session's Folder", func() {
+			vmName := "DC0_C0_RP0_VM1"
+			objVM, err := session.Finder.VirtualMachine(ctx, vmName)
+			Expect(err).NotTo(HaveOccurred())
+			resVM, err := resources.NewVMFromObject(objVM)
+			Expect(err).NotTo(HaveOccurred())
+
+			vm := getVirtualMachineInstance(vmName, testNamespace, "", "")
+			Expect(session.RelocateVirtualMachine(vmContext(ctx, vm), resVM)).To(Succeed())
+
+			By("should find the relocated VM by path", func() {
+				vm1, err := session.GetVirtualMachine(vmContext(ctx, vm))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(vm1.UniqueID(ctx)).To(Equal(objVM.Reference().Value))
+			})
+
+			By("should not change the VM when relocated again", func() {
+				Expect(session.RelocateVirtualMachine(vmContext(ctx, vm), resVM)).To(Succeed())
+			})
+		})
 	})
 
 	Describe("Clone VM", func() {
@@ -159,6 +159,32 @@ var _ = Describe("VMProvider Inventory Tests", func() {
 			Expect(vm.Status.BiosUUID).ToNot(BeEmpty())
 			Expect(vm.Status.InstanceUUID).ToNot(BeEmpty())
 
+			Expect(vmProvider.ResetVirtualMachine(ctx, vm)).To(Succeed())
+			Expect(vmProvider.PowerCycleVirtualMachine(ctx, vm)).To(Succeed())
+
+			snapshotID, err := vmProvider.CreateVirtualMachineSnapshot(ctx, vm, vmprovider.VMSnapshotArgs{
+				Name:        "test-snapshot",
+				Description: "test snapshot",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshotID).ToNot(BeEmpty())
+
+			snapshots, err := vmProvider.ListVirtualMachineSnapshots(ctx, vm)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshots).To(HaveLen(1))
+			Expect(snapshots[0].ID).To(Equal(snapshotID))
+			Expect(snapshots[0].Name).To(Equal("test-snapshot"))
+			Expect(snapshots[0].Current).To(BeTrue())
+
+			Expect(vmProvider.RevertVirtualMachineSnapshot(ctx, vm, snapshotID)).To(Succeed())
+			Expect(vmProvider.RevertVirtualMachineSnapshot(ctx, vm, "snapshot-does-not-exist")).ToNot(Succeed())
+
+			Expect(vmProvider.DeleteVirtualMachineSnapshot(ctx, vm, snapshotID)).To(Succeed())
+			Expect(vmProvider.DeleteVirtualMachineSnapshot(ctx, vm, snapshotID)).To(Succeed())
+			snapshots, err = vmProvider.ListVirtualMachineSnapshots(ctx, vm)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshots).To(BeEmpty())
+
 			vm.Spec.PowerState = vmoperatorv1alpha1.VirtualMachinePoweredOff
 			err = vmProvider.UpdateVirtualMachine(context.TODO(), vm, vmConfigArgs)
 			Expect(err).ToNot(HaveOccurred())
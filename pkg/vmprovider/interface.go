@@ -5,6 +5,12 @@ package vmprovider
 
 import (
 	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 )
@@ -21,6 +27,81 @@ type VMConfigArgs struct {
 	VMMetadata         VMMetadata
 	StorageProfileID   string
 	ContentLibraryUUID string
+	// SysprepSecretData is the data of the VM's Sysprep Secret, if any.
+	SysprepSecretData map[string]string
+}
+
+// GuestCommand is a command that is run inside the guest of a VM via VMware Tools.
+type GuestCommand struct {
+	Path     string
+	Args     []string
+	Username string
+	Password string
+}
+
+// VMSnapshotArgs are the arguments to snapshot a VM.
+type VMSnapshotArgs struct {
+	Name        string
+	Description string
+	Memory      bool
+	Quiesce     bool
+}
+
+// VMPublishArgs are the arguments to publish a VM as a content library item.
+type VMPublishArgs struct {
+	LibraryUUID string
+	ItemName    string
+	Description string
+	// FromSnapshot publishes a linked clone of a snapshot of the VM, so that the VM does not have to be
+	// powered off.
+	FromSnapshot bool
+}
+
+// VMSnapshot describes a snapshot of a VM.
+type VMSnapshot struct {
+	// ID is the managed object ID of the snapshot.
+	ID          string
+	Name        string
+	Description string
+	CreateTime  time.Time
+	PowerState  v1alpha1.VirtualMachinePowerState
+	Quiesced    bool
+	// Current is true if this is the snapshot that the VM is currently running from.
+	Current bool
+	// ParentID is the ID of the parent snapshot, and is empty for a root snapshot.
+	ParentID string
+}
+
+// VMBackupArgs is the Kubernetes state, in addition to the VM itself, that is stored in the VM so
+// that the VM can be rebuilt in Kubernetes after the VM is restored on the infrastructure.
+type VMBackupArgs struct {
+	// VMMetadata is the ConfigMap or Secret of the VM's metadata, if any. Only the metadata of a
+	// Secret is backed up, not its data.
+	VMMetadata client.Object
+	// DiskUUIDToPVC maps the UUID of a disk of the VM to the PVC bound to the disk.
+	DiskUUIDToPVC map[string]corev1.PersistentVolumeClaim
+}
+
+// VMHardware is the hardware of an existing VM, and is used to find the VirtualMachineClass of an
+// imported VM.
+type VMHardware struct {
+	NumCPUs int64
+	Memory  resource.Quantity
+}
+
+// ContentLibrarySubscription is the desired state of a content library subscribed to a published
+// content library.
+type ContentLibrarySubscription struct {
+	// Name is the name of the content library.
+	Name string
+	// PublisherURL is the URL of the lib.json of the published content library.
+	PublisherURL string
+	// SSLThumbprint is the SHA-1 thumbprint of the certificate of the publisher.
+	SSLThumbprint string
+	// OnDemand only downloads the content of an item when the item is first used.
+	OnDemand bool
+	// DatastoreID is the managed object ID of the datastore that stores the content of the library.
+	DatastoreID string
 }
 
 // VirtualMachineProviderInterface is a plugable interface for VM Providers.
@@ -32,11 +113,29 @@ type VirtualMachineProviderInterface interface {
 	// Any tasks started here should be cleaned up when the stop channel closes.
 	Initialize(stop <-chan struct{})
 
+	// VirtualMachineEvents returns a channel of events for the VirtualMachines whose VM changed outside of a
+	// reconcile, like when the guest reports a new IP address. A nil channel means the provider does not watch
+	// for changes, and so the VirtualMachines have to be polled.
+	VirtualMachineEvents() <-chan event.GenericEvent
+
 	DoesVirtualMachineExist(ctx context.Context, vm *v1alpha1.VirtualMachine) (bool, error)
 	CreateVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine, vmConfigArgs VMConfigArgs) error
 	UpdateVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine, vmConfigArgs VMConfigArgs) error
 	DeleteVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine) error
 	GetVirtualMachineGuestHeartbeat(ctx context.Context, vm *v1alpha1.VirtualMachine) (v1alpha1.GuestHeartbeatStatus, error)
+	RunVirtualMachineGuestCommand(ctx context.Context, vm *v1alpha1.VirtualMachine, cmd GuestCommand) (int32, error)
+	ResetVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine) error
+	PowerCycleVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine) error
+	BackupVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine, args VMBackupArgs) error
+	ImportVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine) (VMHardware, error)
+	RelocateVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine, zone string) error
+	PlaceVirtualMachineInstanceStorage(ctx context.Context, vm *v1alpha1.VirtualMachine) error
+	PublishVirtualMachine(ctx context.Context, vm *v1alpha1.VirtualMachine, args VMPublishArgs) (string, error)
+
+	CreateVirtualMachineSnapshot(ctx context.Context, vm *v1alpha1.VirtualMachine, args VMSnapshotArgs) (string, error)
+	ListVirtualMachineSnapshots(ctx context.Context, vm *v1alpha1.VirtualMachine) ([]VMSnapshot, error)
+	RevertVirtualMachineSnapshot(ctx context.Context, vm *v1alpha1.VirtualMachine, snapshotID string) error
+	DeleteVirtualMachineSnapshot(ctx context.Context, vm *v1alpha1.VirtualMachine, snapshotID string) error
 
 	CreateOrUpdateVirtualMachineSetResourcePolicy(ctx context.Context, resourcePolicy *v1alpha1.VirtualMachineSetResourcePolicy) error
 	IsVirtualMachineSetResourcePolicyReady(ctx context.Context, availabilityZoneName string, resourcePolicy *v1alpha1.VirtualMachineSetResourcePolicy) (bool, error)
@@ -50,4 +149,12 @@ type VirtualMachineProviderInterface interface {
 
 	ListVirtualMachineImagesFromContentLibrary(ctx context.Context, cl v1alpha1.ContentLibraryProvider,
 		currentCLImages map[string]v1alpha1.VirtualMachineImage) ([]*v1alpha1.VirtualMachineImage, error)
+	GetContentLibraryChangeToken(ctx context.Context, clUUID string) (string, error)
+
+	CreateOrUpdateSubscribedContentLibrary(ctx context.Context, clUUID string, sub ContentLibrarySubscription) (string, error)
+	GetContentLibraryLastSyncTime(ctx context.Context, clUUID string) (*time.Time, error)
+	IsContentLibraryWritable(ctx context.Context, clUUID string) (bool, error)
+	DeleteContentLibrary(ctx context.Context, clUUID, libraryName string) error
+	ImportContentLibraryItem(ctx context.Context, clUUID, itemName, itemDescription string, filePaths []string) (string, error)
+	DeleteContentLibraryItem(ctx context.Context, itemID string) error
 }
@@ -73,7 +73,9 @@ const (
 	ProviderTagCategoryNameKey   = "VmVmAntiAffinityTagCategoryName"
 
 	NetworkConfigMapName = "vmoperator-network-config"
-	NameserversKey       = "nameservers" // Key in the NetworkConfigMapName.
+	NameserversKey       = "nameservers"   // Key in the NetworkConfigMapName.
+	SearchDomainsKey     = "searchdomains" // Optional key in the NetworkConfigMapName.
+	NTPServersKey        = "ntpservers"    // Optional key in the NetworkConfigMapName.
 )
 
 // ConfigMapToProviderConfig converts the VM provider ConfigMap to a VSphereVMProviderConfig.
@@ -197,6 +199,23 @@ func GetNameserversFromConfigMap(client ctrlruntime.Client) ([]string, error) {
 	return nameserverList, nil
 }
 
+// GetSearchDomainsAndNTPServersFromConfigMap returns the optional search domains and NTP servers in the
+// NetworkConfigMapName ConfigMap.
+func GetSearchDomainsAndNTPServersFromConfigMap(client ctrlruntime.Client) ([]string, []string, error) {
+	vmopNamespace, err := lib.GetVMOpNamespaceFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configMap := &corev1.ConfigMap{}
+	configMapKey := ctrlruntime.ObjectKey{Name: NetworkConfigMapName, Namespace: vmopNamespace}
+	if err := client.Get(context.Background(), configMapKey, configMap); err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot retrieve %v ConfigMap", NetworkConfigMapName)
+	}
+
+	return strings.Fields(configMap.Data[SearchDomainsKey]), strings.Fields(configMap.Data[NTPServersKey]), nil
+}
+
 // getProviderConfigMap returns the provider ConfigMap.
 func getProviderConfigMap(
 	ctx context.Context,
@@ -48,7 +48,7 @@ const (
 	// VMImageCLVersionAnnotation VirtualMachineImage annotation to cache the last fetched version.
 	VMImageCLVersionAnnotation = pkg.VMOperatorKey + "/content-library-version"
 	// VMImageCLVersionAnnotationVersion is the version of the VMImageCLVersionAnnotation for the VirtualMachineImage.
-	VMImageCLVersionAnnotationVersion = 1
+	VMImageCLVersionAnnotationVersion = 3
 
 	PCIPassthruMMIOOverrideAnnotation = pkg.VMOperatorKey + "/pci-passthru-64bit-mmio-size"
 	PCIPassthruMMIOExtraConfigKey     = "pciPassthru.use64bitMMIO"    // nolint:gosec
@@ -70,6 +70,12 @@ const (
 	CloudInitGuestInfoUserdata         = "guestinfo.userdata"
 	CloudInitGuestInfoUserdataEncoding = "guestinfo.userdata.encoding"
 
+	IgnitionGuestInfoConfigData         = "guestinfo.ignition.config.data"
+	IgnitionGuestInfoConfigDataEncoding = "guestinfo.ignition.config.data.encoding"
+	// IgnitionGuestInfoConfigDataMaxSize is the maximum size of the encoded Ignition config, which is
+	// the default limit of the size of the guestinfo values that the guest can read.
+	IgnitionGuestInfoConfigDataMaxSize = 1024 * 1024
+
 	// InstanceStoragePVCNamePrefix prefix of auto-generated PVC names.
 	InstanceStoragePVCNamePrefix = "instance-pvc-"
 	// InstanceStorageLabelKey identifies resources related to instance storage.
@@ -90,4 +96,14 @@ const (
 	InstanceStoragePVPlacementErrorPrefix = "FAILED_"
 	// InstanceStorageNotEnoughResErr is an error constant to indicate not enough resources.
 	InstanceStorageNotEnoughResErr = "FAILED_PLACEMENT-NotEnoughResources"
+
+	// BackupVMKubeDataExtraConfigKey is the ExtraConfig key of the VM's Kubernetes YAML, without its status.
+	BackupVMKubeDataExtraConfigKey = "vmservice.virtualmachine.resource.yaml"
+	// BackupVMAdditionalResourcesExtraConfigKey is the ExtraConfig key of the YAML of the VM's metadata
+	// ConfigMap or Secret.
+	BackupVMAdditionalResourcesExtraConfigKey = "vmservice.virtualmachine.additional.resources.yaml"
+	// BackupVMPVCDiskDataExtraConfigKey is the ExtraConfig key of the JSON of the PVCs bound to the VM's disks.
+	BackupVMPVCDiskDataExtraConfigKey = "vmservice.virtualmachine.pvc.disk.data"
+	// BackupVMDataEncoding is the encoding of the values of the backup ExtraConfig keys.
+	BackupVMDataEncoding = "gzip+base64"
 )
@@ -4,10 +4,17 @@
 package contentlibrary
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"time"
 
@@ -23,6 +30,7 @@ import (
 	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
 	"github.com/acharyasreej/vm-operator/pkg/lib"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
 )
 
@@ -31,10 +39,17 @@ type Provider interface {
 	GetLibraryItem(ctx context.Context, clUUID, itemName string) (*library.Item, error)
 	RetrieveOvfEnvelopeFromLibraryItem(ctx context.Context, item *library.Item) (*ovf.Envelope, error)
 
+	CreateOrUpdateSubscribedLibrary(ctx context.Context, clUUID string, sub vmprovider.ContentLibrarySubscription) (string, error)
+	GetLibraryLastSyncTime(ctx context.Context, clUUID string) (*time.Time, error)
+	IsLibraryWritable(ctx context.Context, clUUID string) (bool, error)
+	GetLibraryChangeToken(ctx context.Context, clUUID string) (string, error)
+	DeleteLibrary(ctx context.Context, clUUID, libraryName string) error
+	ImportLibraryItem(ctx context.Context, clUUID, itemName, itemDescription string, paths []string) (string, error)
+	DeleteLibraryItem(ctx context.Context, libraryItem *library.Item) error
+
 	// TODO: Testing only. Remove these from this file.
 	CreateLibrary(ctx context.Context, contentSource, datastoreID string) (string, error)
 	CreateLibraryItem(ctx context.Context, libraryItem library.Item, path string) error
-	DeleteLibraryItem(ctx context.Context, libraryItem *library.Item) error
 
 	VirtualMachineImageResourcesForLibrary(
 		ctx context.Context,
@@ -45,8 +60,18 @@ type Provider interface {
 type provider struct {
 	libMgr        *library.Manager
 	retryInterval time.Duration
+	ovfCache      *OvfCache
 }
 
+const (
+	// subscribedLibraryPath is the path of the subscribed library API, which the library.Manager
+	// only uses to create, sync and delete a subscribed library.
+	subscribedLibraryPath = "/com/vmware/content/subscribed-library"
+
+	librarySubscribed = "SUBSCRIBED"
+	libraryLocal      = "LOCAL"
+)
+
 const (
 	EnvContentLibAPIWaitSecs     = "CONTENT_API_WAIT_SECS" // BMV: Investigate if setting this to 1 actually reduces the integration test time.
 	DefaultContentLibAPIWaitSecs = 5
@@ -75,6 +100,7 @@ func NewProviderWithWaitSec(restClient *rest.Client, waitSeconds int) Provider {
 	return &provider{
 		libMgr:        library.NewManager(restClient),
 		retryInterval: time.Duration(waitSeconds) * time.Second,
+		ovfCache:      getDefaultOvfCache(),
 	}
 }
 
@@ -114,7 +140,31 @@ func (cs *provider) GetLibraryItem(ctx context.Context, libraryUUID, itemName st
 
 // RetrieveOvfEnvelopeFromLibraryItem downloads the supported file from content library.
 // parses the downloaded ovf and returns the OVF Envelope descriptor for consumption.
+// The OVF is only downloaded when it is not in the OVF cache for the content version of the item.
 func (cs *provider) RetrieveOvfEnvelopeFromLibraryItem(ctx context.Context, item *library.Item) (*ovf.Envelope, error) {
+	logger := log.WithValues("itemID", item.ID, "itemName", item.Name)
+
+	ovfData, ok := cs.ovfCache.Get(item)
+	if ok {
+		logger.V(4).Info("found library item OVF in cache", "contentVersion", itemContentVersion(item))
+	} else {
+		var err error
+		if ovfData, err = cs.downloadOvfFromLibraryItem(ctx, item); err != nil {
+			return nil, err
+		}
+		cs.ovfCache.Put(item, ovfData)
+	}
+
+	envelope, err := ovf.Unmarshal(bytes.NewReader(ovfData))
+	if err != nil {
+		logger.Error(err, "error parsing the OVF envelope")
+		return nil, nil
+	}
+
+	return envelope, nil
+}
+
+func (cs *provider) downloadOvfFromLibraryItem(ctx context.Context, item *library.Item) ([]byte, error) {
 	// Create a download session for the file referred to by item id.
 	sessionID, err := cs.libMgr.CreateLibraryItemDownloadSession(ctx, library.Session{LibraryItemID: item.ID})
 	if err != nil {
@@ -147,13 +197,202 @@ func (cs *provider) RetrieveOvfEnvelopeFromLibraryItem(ctx context.Context, item
 		_ = downloadedFileContent.Close()
 	}()
 
-	envelope, err := ovf.Unmarshal(downloadedFileContent)
+	ovfData, err := ioutil.ReadAll(downloadedFileContent)
 	if err != nil {
-		logger.Error(err, "error parsing the OVF envelope")
-		return nil, nil
+		logger.Error(err, "error reading file from library item")
+		return nil, err
 	}
 
-	return envelope, nil
+	return ovfData, nil
+}
+
+// CreateOrUpdateSubscribedLibrary creates a subscribed content library, or updates the subscription of the
+// subscribed content library with the UUID when it exists, and returns the UUID of the library. The library
+// is synced with its publisher after its subscription is updated. An existing library is only updated when it
+// is a subscribed library with the name of the subscription, so that the UUID cannot be used to re-point
+// another library. A subscribed library with the name is adopted when there is no library with the UUID.
+func (cs *provider) CreateOrUpdateSubscribedLibrary(
+	ctx context.Context,
+	clUUID string,
+	sub vmprovider.ContentLibrarySubscription) (string, error) {
+
+	onDemand := sub.OnDemand
+	automaticSyncEnabled := true
+	subscription := &library.Subscription{
+		AuthenticationMethod: "NONE",
+		AutomaticSyncEnabled: &automaticSyncEnabled,
+		OnDemand:             &onDemand,
+		SslThumbprint:        sub.SSLThumbprint,
+		SubscriptionURL:      sub.PublisherURL,
+	}
+
+	if clUUID != "" {
+		cl, err := cs.libMgr.GetLibraryByID(ctx, clUUID)
+		if err == nil {
+			if err := checkSubscribedLibrary(cl, sub.Name); err != nil {
+				return "", err
+			}
+			return clUUID, cs.updateSubscribedLibrary(ctx, cl, subscription)
+		}
+		if !lib.IsNotFoundError(err) {
+			return "", errors.Wrapf(err, "failed to get content library %s", clUUID)
+		}
+		log.Info("Subscribed content library does not exist, creating it", "libraryUUID", clUUID)
+	}
+
+	// The library may have been created without its UUID being recorded.
+	libIDs, err := cs.libMgr.FindLibrary(ctx, library.Find{Name: sub.Name, Type: librarySubscribed})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find content library %s", sub.Name)
+	}
+	if len(libIDs) > 0 {
+		cl, err := cs.libMgr.GetLibraryByID(ctx, libIDs[0])
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get content library %s", libIDs[0])
+		}
+		log.Info("Found existing subscribed library", "libraryName", sub.Name, "libraryUUID", cl.ID)
+		return cl.ID, cs.updateSubscribedLibrary(ctx, cl, subscription)
+	}
+
+	log.Info("Creating subscribed library", "libraryName", sub.Name, "publisherURL", sub.PublisherURL)
+
+	cl := library.Library{
+		Name: sub.Name,
+		Type: librarySubscribed,
+		Storage: []library.StorageBackings{
+			{
+				DatastoreID: sub.DatastoreID,
+				Type:        "DATASTORE",
+			},
+		},
+		Subscription: subscription,
+	}
+
+	libID, err := cs.libMgr.CreateLibrary(ctx, cl)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create subscribed library %s", sub.Name)
+	}
+
+	return libID, nil
+}
+
+// checkSubscribedLibrary returns an error when the library is not a subscribed library with the name.
+func checkSubscribedLibrary(cl *library.Library, libraryName string) error {
+	if cl.Type != librarySubscribed || cl.Name != libraryName {
+		return errors.Errorf("content library %s is not the subscribed library %s", cl.ID, libraryName)
+	}
+	return nil
+}
+
+func (cs *provider) updateSubscribedLibrary(ctx context.Context, cl *library.Library, subscription *library.Subscription) error {
+	if current := cl.Subscription; current != nil &&
+		current.SubscriptionURL == subscription.SubscriptionURL &&
+		current.SslThumbprint == subscription.SslThumbprint &&
+		current.OnDemand != nil && *current.OnDemand == *subscription.OnDemand {
+		return nil
+	}
+
+	log.Info("Updating subscription of subscribed library", "libraryUUID", cl.ID,
+		"publisherURL", subscription.SubscriptionURL)
+
+	// library.Manager UpdateLibrary only updates the name and description of a library.
+	spec := struct {
+		Library library.Library `json:"update_spec"`
+	}{
+		Library: library.Library{Subscription: subscription},
+	}
+	resource := cs.libMgr.Resource(subscribedLibraryPath).WithID(cl.ID)
+	if err := cs.libMgr.Do(ctx, resource.Request(http.MethodPatch, spec), nil); err != nil {
+		return errors.Wrapf(err, "failed to update subscription of content library %s", cl.ID)
+	}
+
+	if err := cs.libMgr.SyncLibrary(ctx, cl); err != nil {
+		return errors.Wrapf(err, "failed to sync content library %s", cl.ID)
+	}
+
+	return nil
+}
+
+// GetLibraryLastSyncTime returns the last time the subscribed content library with the UUID was synced
+// with its publisher, or nil if the library was never synced.
+func (cs *provider) GetLibraryLastSyncTime(ctx context.Context, clUUID string) (*time.Time, error) {
+	cl, err := cs.libMgr.GetLibraryByID(ctx, clUUID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get content library %s", clUUID)
+	}
+
+	return cl.LastSyncTime, nil
+}
+
+// IsLibraryWritable returns whether the items of the content library with the UUID can be changed, which is
+// only the case for a local library. The items of a subscribed library are replaced by its publisher.
+func (cs *provider) IsLibraryWritable(ctx context.Context, clUUID string) (bool, error) {
+	cl, err := cs.libMgr.GetLibraryByID(ctx, clUUID)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get content library %s", clUUID)
+	}
+
+	return cl.Type == libraryLocal, nil
+}
+
+// GetLibraryChangeToken returns a token that changes whenever the library is changed or synced, or items are
+// added to or removed from the library. The token is computed from the library and the IDs of its items, so it
+// only takes two requests regardless of the number of items. Changes to the content of existing items of a local
+// library do not change the token. The ContentSource of the library is updated when VM Operator imports or
+// publishes an item, which syncs the images regardless of the token, and other changes are picked up by the
+// periodic full resync of the library.
+func (cs *provider) GetLibraryChangeToken(ctx context.Context, clUUID string) (string, error) {
+	cl, err := cs.libMgr.GetLibraryByID(ctx, clUUID)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get content library %s", clUUID)
+	}
+
+	itemIDs, err := cs.libMgr.ListLibraryItems(ctx, clUUID)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list items of content library %s", clUUID)
+	}
+	sort.Strings(itemIDs)
+
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n", cl.Version)
+	for _, t := range []*time.Time{cl.LastModifiedTime, cl.LastSyncTime} {
+		if t != nil {
+			_, _ = fmt.Fprintf(h, "%d\n", t.UnixNano())
+		} else {
+			_, _ = fmt.Fprintln(h)
+		}
+	}
+	for _, id := range itemIDs {
+		_, _ = fmt.Fprintf(h, "%s\n", id)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DeleteLibrary deletes the subscribed content library with the UUID and name. It is not an error if the library
+// does not exist, but it is an error if the library with the UUID is not a subscribed library with the name.
+func (cs *provider) DeleteLibrary(ctx context.Context, clUUID, libraryName string) error {
+	cl, err := cs.libMgr.GetLibraryByID(ctx, clUUID)
+	if err != nil {
+		if lib.IsNotFoundError(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get content library %s", clUUID)
+	}
+
+	if err := checkSubscribedLibrary(cl, libraryName); err != nil {
+		return err
+	}
+
+	log.Info("Deleting subscribed library", "libraryUUID", clUUID, "libraryName", libraryName)
+
+	err = cs.libMgr.DeleteLibrary(ctx, cl)
+	if err != nil && !lib.IsNotFoundError(err) {
+		return errors.Wrapf(err, "failed to delete content library %s", clUUID)
+	}
+
+	cs.ovfCache.Prune(clUUID, nil)
+	return nil
 }
 
 // Only used in testing.
@@ -180,9 +419,14 @@ func (cs *provider) CreateLibrary(ctx context.Context, name, datastoreID string)
 	return libID, nil
 }
 
-// Only used in testing.
+// DeleteLibraryItem deletes a library item, and evicts its OVF from the OVF cache.
 func (cs *provider) DeleteLibraryItem(ctx context.Context, libraryItem *library.Item) error {
-	return cs.libMgr.DeleteLibraryItem(ctx, libraryItem)
+	if err := cs.libMgr.DeleteLibraryItem(ctx, libraryItem); err != nil {
+		return err
+	}
+
+	cs.ovfCache.Delete(libraryItem)
+	return nil
 }
 
 // Only used in testing.
@@ -200,47 +444,112 @@ func (cs *provider) CreateLibraryItem(ctx context.Context, libraryItem library.I
 	}
 
 	// Update Library item with library file "ovf"
-	uploadFunc := func(c *rest.Client, path string) error {
-		f, err := os.Open(filepath.Clean(path))
+	if err = cs.uploadLibraryItemFile(ctx, sessionID, path); err != nil {
+		return err
+	}
+
+	return cs.libMgr.CompleteLibraryItemUpdateSession(ctx, sessionID)
+}
+
+// ImportLibraryItem uploads the files of an OVF into an OVF library item with the name and description, and
+// returns the ID of the item. When the library already has an item with the name, its content is only replaced
+// if it has the same description, that is if it was created by the same importer, so an import cannot overwrite
+// the items of others.
+func (cs *provider) ImportLibraryItem(
+	ctx context.Context,
+	clUUID, itemName, itemDescription string,
+	paths []string) (string, error) {
+
+	logger := log.WithValues("libraryUUID", clUUID, "itemName", itemName)
+
+	itemIDs, err := cs.libMgr.FindLibraryItems(ctx, library.FindItem{LibraryID: clUUID, Name: itemName})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find library item %s", itemName)
+	}
+
+	var itemID string
+	switch len(itemIDs) {
+	case 0:
+		logger.Info("Creating library item")
+		itemID, err = cs.libMgr.CreateLibraryItem(ctx, library.Item{
+			Name:        itemName,
+			Description: itemDescription,
+			Type:        library.ItemTypeOVF,
+			LibraryID:   clUUID,
+		})
 		if err != nil {
-			return err
+			return "", errors.Wrapf(err, "failed to create library item %s", itemName)
 		}
-		defer func() {
-			_ = f.Close()
-		}()
-
-		fi, err := f.Stat()
+	case 1:
+		item, err := cs.libMgr.GetLibraryItem(ctx, itemIDs[0])
 		if err != nil {
-			return err
+			return "", errors.Wrapf(err, "failed to get library item %s", itemIDs[0])
 		}
-
-		info := library.UpdateFile{
-			Name:       filepath.Base(path),
-			SourceType: "PUSH",
-			Size:       fi.Size(),
+		if item.Description != itemDescription {
+			return "", errors.Errorf("library already has an item named %s", itemName)
 		}
+		logger.Info("Updating content of existing library item", "itemID", item.ID)
+		itemID = item.ID
+	default:
+		return "", errors.Errorf("multiple library items named: %s", itemName)
+	}
 
-		update, err := cs.libMgr.AddLibraryItemFile(ctx, sessionID, info)
-		if err != nil {
-			return err
-		}
+	sessionID, err := cs.libMgr.CreateLibraryItemUpdateSession(ctx, library.Session{LibraryItemID: itemID})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create update session for library item %s", itemID)
+	}
 
-		u, err := url.Parse(update.UploadEndpoint.URI)
-		if err != nil {
-			return err
+	for _, path := range paths {
+		if err := cs.uploadLibraryItemFile(ctx, sessionID, path); err != nil {
+			if cancelErr := cs.libMgr.CancelLibraryItemUpdateSession(ctx, sessionID); cancelErr != nil {
+				logger.Error(cancelErr, "failed to cancel library item update session", "sessionID", sessionID)
+			}
+			return "", errors.Wrapf(err, "failed to upload %s to library item %s", filepath.Base(path), itemID)
 		}
+	}
+
+	if err := cs.libMgr.CompleteLibraryItemUpdateSession(ctx, sessionID); err != nil {
+		return "", errors.Wrapf(err, "failed to complete update session for library item %s", itemID)
+	}
+
+	return itemID, nil
+}
+
+// uploadLibraryItemFile uploads a file in an update session of a library item.
+func (cs *provider) uploadLibraryItemFile(ctx context.Context, sessionID, path string) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
 
-		p := soap.DefaultUpload
-		p.ContentLength = info.Size
+	info := library.UpdateFile{
+		Name:       filepath.Base(path),
+		SourceType: "PUSH",
+		Size:       fi.Size(),
+	}
 
-		return c.Upload(ctx, f, u, &p)
+	update, err := cs.libMgr.AddLibraryItemFile(ctx, sessionID, info)
+	if err != nil {
+		return err
 	}
 
-	if err = uploadFunc(cs.libMgr.Client, path); err != nil {
+	u, err := url.Parse(update.UploadEndpoint.URI)
+	if err != nil {
 		return err
 	}
 
-	return cs.libMgr.CompleteLibraryItemUpdateSession(ctx, sessionID)
+	p := soap.DefaultUpload
+	p.ContentLength = info.Size
+
+	return cs.libMgr.Client.Upload(ctx, f, u, &p)
 }
 
 // Lists all the VirtualMachineImages from a CL by a given UUID.
@@ -256,6 +565,9 @@ func (cs *provider) VirtualMachineImageResourcesForLibrary(
 		return nil, err
 	}
 
+	// Evict the OVFs of the items that were removed from the library since the last listing.
+	cs.ovfCache.Prune(clUUID, items)
+
 	images := make([]*v1alpha1.VirtualMachineImage, 0, len(items))
 	for i := range items {
 		var ovfEnvelope *ovf.Envelope
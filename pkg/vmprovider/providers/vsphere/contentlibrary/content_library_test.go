@@ -19,6 +19,7 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	"github.com/acharyasreej/vm-operator/pkg/lib"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
@@ -113,9 +114,24 @@ var _ = Describe("LibItemToVirtualMachineImage", func() {
 			Expect(image).ToNot(BeNil())
 			Expect(image.Name).Should(Equal("fakeItem"))
 
-			Expect(image.Annotations).To(HaveLen(2))
+			Expect(image.Annotations).To(HaveLen(4))
 			Expect(image.Annotations).To(HaveKey(constants.VMImageCLVersionAnnotation))
+			Expect(image.Annotations).To(HaveKeyWithValue(vmopapi.VirtualMachineImageItemCreationTimeAnnotation,
+				ts.UTC().Format(time.RFC3339)))
 			Expect(image.Annotations).Should(HaveKeyWithValue(versionKey, versionVal))
+			Expect(image.Annotations).To(HaveKey(vmopapi.VirtualMachineImageCompatibilityReportAnnotation))
+			report, err := vmopapi.GetImageCompatibilityReport(image)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report).ToNot(BeNil())
+			Expect(report.Supported).To(BeFalse())
+			Expect(report.Reasons).To(HaveLen(2))
+			Expect(report.V1Alpha1Compatible).To(BeFalse())
+			Expect(report.TKGImage).To(BeFalse())
+			Expect(report.MinPersistentVolumeHardwareVersion).To(BeEquivalentTo(constants.MinSupportedHWVersionForPVC))
+			Expect(report.PersistentVolumesSupported).To(BeTrue())
+			Expect(report.Firmware).To(Equal("bios"))
+			Expect(report.Transports).To(ConsistOf(vmopv1alpha1.VirtualMachineMetadataExtraConfigTransport,
+				vmopv1alpha1.VirtualMachineMetadataOvfEnvTransport))
 			Expect(image.CreationTimestamp).To(BeEquivalentTo(metav1.NewTime(ts)))
 
 			Expect(image.Spec.ProductInfo.Vendor).Should(Equal("vendor"))
@@ -264,9 +280,17 @@ var _ = Describe("LibItemToVirtualMachineImage", func() {
 					*conditions.FalseCondition(vmopv1alpha1.VirtualMachineImageV1Alpha1CompatibleCondition,
 						vmopv1alpha1.VirtualMachineImageV1Alpha1NotCompatibleReason,
 						vmopv1alpha1.ConditionSeverityError,
-						"VirtualMachineImage is either not a TKG image or is not compatible with VMService v1alpha1"),
+						"VirtualMachineImage is either not a TKG image or is not compatible with VMService v1alpha1: %s",
+						"the OVF does not set the ExtraConfig key guestinfo.vmservice.defer-cloud-init to \"ready\" to defer cloud-init; "+
+							"the OVF does not have the vmware-system.guest.kubernetes properties of a TKG image"),
 				}
 				Expect(image.Status.Conditions).Should(conditions.MatchConditions(expectedCondition))
+
+				report, err := vmopapi.GetImageCompatibilityReport(image)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(report).ToNot(BeNil())
+				Expect(report.Supported).To(BeFalse())
+				Expect(report.Reasons).To(HaveLen(2))
 			})
 
 			It("ImageSupported should be set to true when OVF Envelope has VMOperatorV1Alpha1ExtraConfigKey set to VMOperatorV1Alpha1ConfigReady in extraConfig and has a valid OS type set", func() {
@@ -296,3 +320,116 @@ var _ = Describe("LibItemToVirtualMachineImage", func() {
 		})
 	})
 })
+
+var _ = Describe("GetImageCompatibilityReport", func() {
+	var (
+		image       *vmopv1alpha1.VirtualMachineImage
+		ovfEnvelope *ovf.Envelope
+		report      *vmopapi.VirtualMachineImageCompatibilityReport
+	)
+
+	BeforeEach(func() {
+		lib.IsUnifiedTKGBYOIFSSEnabled = func() bool {
+			return false
+		}
+
+		image = &vmopv1alpha1.VirtualMachineImage{}
+		ovfEnvelope = &ovf.Envelope{
+			VirtualSystem: &ovf.VirtualSystem{},
+		}
+	})
+
+	JustBeforeEach(func() {
+		report = contentlibrary.GetImageCompatibilityReport(image, ovfEnvelope,
+			contentlibrary.GetVmwareSystemPropertiesFromOvf(ovfEnvelope))
+	})
+
+	Context("Image with an old hardware version", func() {
+		BeforeEach(func() {
+			image.Spec.HardwareVersion = 11
+		})
+
+		It("does not support PersistentVolumes", func() {
+			Expect(report.HardwareVersion).To(BeEquivalentTo(11))
+			Expect(report.MinPersistentVolumeHardwareVersion).To(BeEquivalentTo(constants.MinSupportedHWVersionForPVC))
+			Expect(report.PersistentVolumesSupported).To(BeFalse())
+		})
+	})
+
+	Context("Linux EFI image with vApp properties and disks", func() {
+		BeforeEach(func() {
+			image.Spec.HardwareVersion = 15
+			image.Spec.OSInfo.Type = "ubuntu64Guest"
+			ovfEnvelope.Disk = &ovf.DiskSection{
+				Disks: []ovf.VirtualDiskDesc{
+					{
+						DiskID:                  "disk-1",
+						Capacity:                "10",
+						CapacityAllocationUnits: pointer.String("byte * 2^30"),
+					},
+					{
+						DiskID:   "disk-2",
+						Capacity: "1024",
+					},
+				},
+			}
+			ovfEnvelope.VirtualSystem.VirtualHardware = []ovf.VirtualHardwareSection{
+				{
+					Config: []ovf.Config{
+						{
+							Key:   "firmware",
+							Value: "efi",
+						},
+					},
+					ExtraConfig: []ovf.Config{
+						{
+							Key:   constants.VMOperatorV1Alpha1ExtraConfigKey,
+							Value: constants.VMOperatorV1Alpha1ConfigReady,
+						},
+					},
+				},
+			}
+			ovfEnvelope.VirtualSystem.Product = []ovf.ProductSection{
+				{
+					Property: []ovf.Property{
+						{
+							Key:     "user-data",
+							Default: pointer.String(""),
+						},
+					},
+				},
+			}
+		})
+
+		It("returns a supported report", func() {
+			Expect(report.Supported).To(BeTrue())
+			Expect(report.Reasons).To(BeEmpty())
+			Expect(report.V1Alpha1Compatible).To(BeTrue())
+			Expect(report.PersistentVolumesSupported).To(BeTrue())
+			Expect(report.Firmware).To(Equal("efi"))
+			Expect(report.Transports).To(ConsistOf(
+				vmopv1alpha1.VirtualMachineMetadataExtraConfigTransport,
+				vmopv1alpha1.VirtualMachineMetadataOvfEnvTransport,
+				vmopv1alpha1.VirtualMachineMetadataCloudInitTransport))
+			Expect(report.DiskCount).To(Equal(2))
+			Expect(report.DiskCapacityBytes).To(BeEquivalentTo(10*1024*1024*1024 + 1024))
+			Expect(report.GuestOSType).To(Equal("ubuntu64Guest"))
+			Expect(report.GuestOSFamily).To(Equal(vmopapi.GuestOSFamilyLinux))
+		})
+	})
+
+	Context("Windows image without vApp properties", func() {
+		BeforeEach(func() {
+			image.Spec.OSInfo.Type = "windows9Server64Guest"
+		})
+
+		It("returns an unsupported report", func() {
+			Expect(report.Supported).To(BeFalse())
+			Expect(report.Reasons).To(HaveLen(2))
+			Expect(report.Firmware).To(Equal("bios"))
+			Expect(report.Transports).To(ConsistOf(vmopv1alpha1.VirtualMachineMetadataExtraConfigTransport))
+			Expect(report.DiskCount).To(BeZero())
+			Expect(report.GuestOSFamily).To(Equal(vmopapi.GuestOSFamilyWindows))
+		})
+	})
+})
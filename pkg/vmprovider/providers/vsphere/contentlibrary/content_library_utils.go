@@ -5,12 +5,14 @@ package contentlibrary
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/vmware/govmomi/ovf"
 	"github.com/vmware/govmomi/vapi/library"
@@ -22,6 +24,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	"github.com/acharyasreej/vm-operator/pkg/lib"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
@@ -33,9 +36,16 @@ func LibItemToVirtualMachineImage(
 	item *library.Item,
 	ovfEnvelope *ovf.Envelope) *v1alpha1.VirtualMachineImage {
 
+	annotations := map[string]string{
+		constants.VMImageCLVersionAnnotation: libItemVersionAnnotation(item),
+	}
+
 	var ts metav1.Time
 	if item.CreationTime != nil {
 		ts = metav1.NewTime(*item.CreationTime)
+		// The API server overwrites the CreationTimestamp, so the creation time of the item is also kept in an
+		// annotation for the image retention.
+		annotations[vmopapi.VirtualMachineImageItemCreationTimeAnnotation] = item.CreationTime.UTC().Format(time.RFC3339)
 	}
 
 	// NOTE: Whenever a Spec/Status field, label, annotation, etc is added or removed, the or the logic
@@ -46,9 +56,7 @@ func LibItemToVirtualMachineImage(
 		ObjectMeta: metav1.ObjectMeta{
 			Name:              item.Name,
 			CreationTimestamp: ts,
-			Annotations: map[string]string{
-				constants.VMImageCLVersionAnnotation: libItemVersionAnnotation(item),
-			},
+			Annotations:       annotations,
 		},
 		Spec: v1alpha1.VirtualMachineImageSpec{
 			Type:            item.Type,
@@ -107,7 +115,9 @@ func LibItemToVirtualMachineImage(
 			image.Spec.HardwareVersion = hwVersion
 
 			// Set Status.ImageSupported to combined compatibility of OVF compatibility or WCP_VMService_UnifiedTKG_BYOI FSS state.
-			image.Status.ImageSupported = pointer.BoolPtr(isImageSupported(image, ovfEnvelope, ovfSystemProps))
+			report := GetImageCompatibilityReport(image, ovfEnvelope, ovfSystemProps)
+			setImageCompatibilityReportAnnotation(image, report)
+			image.Status.ImageSupported = pointer.BoolPtr(isImageSupported(image, report))
 		}
 	}
 
@@ -194,20 +204,166 @@ func libItemVersionAnnotation(item *library.Item) string {
 // Image is marked supported if:
 // - WCP_VMService_UnifiedTKG_BYOI FSS is enabled. We assume images are compliant by default and not rely on the presence of ExtraConfig key in the OVF image.
 // - Otherwise, The OVF should contain the VMOperatorV1Alpha1ConfigKey key that denotes cloud-init being disabled at first-boot, or it is a TKG image.
-func isImageSupported(image *v1alpha1.VirtualMachineImage, ovfEnvelope *ovf.Envelope, ovfSystemProps map[string]string) bool {
+func isImageSupported(image *v1alpha1.VirtualMachineImage, report *vmopapi.VirtualMachineImageCompatibilityReport) bool {
 	if lib.IsUnifiedTKGBYOIFSSEnabled() {
 		return true
 	}
-	if isOVFV1Alpha1Compatible(ovfEnvelope) || isATKGImage(ovfSystemProps) {
+	if report.V1Alpha1Compatible || report.TKGImage {
 		conditions.MarkTrue(image, v1alpha1.VirtualMachineImageV1Alpha1CompatibleCondition)
 	} else {
 		msg := "VirtualMachineImage is either not a TKG image or is not compatible with VMService v1alpha1"
 		conditions.MarkFalse(image, v1alpha1.VirtualMachineImageV1Alpha1CompatibleCondition,
-			v1alpha1.VirtualMachineImageV1Alpha1NotCompatibleReason, v1alpha1.ConditionSeverityError, msg)
+			v1alpha1.VirtualMachineImageV1Alpha1NotCompatibleReason, v1alpha1.ConditionSeverityError,
+			"%s: %s", msg, strings.Join(report.Reasons, "; "))
 	}
 	return conditions.IsTrue(image, v1alpha1.VirtualMachineImageV1Alpha1CompatibleCondition)
 }
 
+// GetImageCompatibilityReport returns the compatibility report of the OVF of an image.
+func GetImageCompatibilityReport(
+	image *v1alpha1.VirtualMachineImage,
+	ovfEnvelope *ovf.Envelope,
+	ovfSystemProps map[string]string) *vmopapi.VirtualMachineImageCompatibilityReport {
+
+	report := &vmopapi.VirtualMachineImageCompatibilityReport{
+		V1Alpha1Compatible:                 isOVFV1Alpha1Compatible(ovfEnvelope),
+		TKGImage:                           isATKGImage(ovfSystemProps),
+		HardwareVersion:                    image.Spec.HardwareVersion,
+		MinPersistentVolumeHardwareVersion: constants.MinSupportedHWVersionForPVC,
+		Firmware:                           getOvfFirmware(ovfEnvelope),
+		Transports:                         getOvfTransports(ovfEnvelope, image.Spec.OSInfo.Type),
+		GuestOSType:                        image.Spec.OSInfo.Type,
+		GuestOSFamily:                      getGuestOSFamily(image.Spec.OSInfo.Type),
+	}
+
+	// An unknown hardware version is upgraded when the VM is created, so it does not prevent PVCs.
+	report.PersistentVolumesSupported = report.HardwareVersion == 0 ||
+		report.HardwareVersion >= report.MinPersistentVolumeHardwareVersion
+
+	if ovfEnvelope.Disk != nil {
+		for _, disk := range ovfEnvelope.Disk.Disks {
+			report.DiskCount++
+			report.DiskCapacityBytes += getOvfDiskCapacity(disk)
+		}
+	}
+
+	if !lib.IsUnifiedTKGBYOIFSSEnabled() && !report.V1Alpha1Compatible && !report.TKGImage {
+		report.Reasons = append(report.Reasons,
+			fmt.Sprintf("the OVF does not set the ExtraConfig key %s to %q to defer cloud-init",
+				constants.VMOperatorV1Alpha1ExtraConfigKey, constants.VMOperatorV1Alpha1ConfigReady),
+			"the OVF does not have the vmware-system.guest.kubernetes properties of a TKG image")
+	}
+	report.Supported = len(report.Reasons) == 0
+
+	return report
+}
+
+// setImageCompatibilityReportAnnotation sets the JSON compatibility report in the annotations of the image.
+func setImageCompatibilityReportAnnotation(
+	image *v1alpha1.VirtualMachineImage,
+	report *vmopapi.VirtualMachineImageCompatibilityReport) {
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		log.Error(err, "failed to marshal compatibility report", "imageName", image.Name)
+		return
+	}
+	image.Annotations[vmopapi.VirtualMachineImageCompatibilityReportAnnotation] = string(data)
+}
+
+// getOvfFirmware returns the firmware of the first virtual hardware section of the OVF, which defaults to bios.
+func getOvfFirmware(ovfEnvelope *ovf.Envelope) string {
+	if ovfEnvelope.VirtualSystem != nil {
+		for _, virtualHardware := range ovfEnvelope.VirtualSystem.VirtualHardware {
+			for _, config := range virtualHardware.Config {
+				if config.Key == "firmware" && config.Value != "" {
+					return config.Value
+				}
+			}
+		}
+	}
+	return "bios"
+}
+
+// getOvfTransports returns the metadata transports supported by the OVF. ExtraConfig is always supported,
+// while the other transports depend on the vApp properties of the OVF.
+func getOvfTransports(ovfEnvelope *ovf.Envelope, osType string) []v1alpha1.VirtualMachineMetadataTransport {
+	var hasProperties, hasUserData, hasIgnition bool
+	if ovfEnvelope.VirtualSystem != nil {
+		for _, product := range ovfEnvelope.VirtualSystem.Product {
+			for _, prop := range product.Property {
+				if strings.HasPrefix(prop.Key, "vmware-system") {
+					continue
+				}
+				hasProperties = true
+				switch prop.Key {
+				case "user-data":
+					hasUserData = true
+				case constants.IgnitionGuestInfoConfigData:
+					hasIgnition = true
+				}
+			}
+		}
+	}
+
+	transports := []v1alpha1.VirtualMachineMetadataTransport{v1alpha1.VirtualMachineMetadataExtraConfigTransport}
+	if hasProperties {
+		transports = append(transports, v1alpha1.VirtualMachineMetadataOvfEnvTransport)
+	}
+	if hasUserData || isOVFV1Alpha1Compatible(ovfEnvelope) {
+		transports = append(transports, v1alpha1.VirtualMachineMetadataCloudInitTransport)
+	}
+	if hasIgnition || strings.Contains(strings.ToLower(osType), "coreos") {
+		transports = append(transports, vmopapi.VirtualMachineMetadataIgnitionTransport)
+	}
+	return transports
+}
+
+// getGuestOSFamily returns the family of a vSphere guest OS identifier, like windows9_64Guest.
+func getGuestOSFamily(osType string) string {
+	osType = strings.ToLower(osType)
+	switch {
+	case osType == "":
+		return ""
+	case strings.HasPrefix(osType, "win"):
+		return vmopapi.GuestOSFamilyWindows
+	case strings.Contains(osType, "linux"), strings.HasPrefix(osType, "rhel"), strings.HasPrefix(osType, "centos"),
+		strings.HasPrefix(osType, "ubuntu"), strings.HasPrefix(osType, "debian"), strings.HasPrefix(osType, "sles"),
+		strings.HasPrefix(osType, "oracle"), strings.HasPrefix(osType, "photon"), strings.HasPrefix(osType, "coreos"),
+		strings.HasPrefix(osType, "fedora"), strings.HasPrefix(osType, "opensuse"), strings.HasPrefix(osType, "rocky"),
+		strings.HasPrefix(osType, "almalinux"), strings.HasPrefix(osType, "amazonlinux"):
+		return vmopapi.GuestOSFamilyLinux
+	default:
+		return vmopapi.GuestOSFamilyOther
+	}
+}
+
+// getOvfDiskCapacity returns the capacity in bytes of an OVF disk, or 0 if the capacity is not known, like
+// when it refers to a property. The allocation units are like "byte * 2^30".
+func getOvfDiskCapacity(disk ovf.VirtualDiskDesc) int64 {
+	capacity, err := strconv.ParseInt(disk.Capacity, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	if disk.CapacityAllocationUnits == nil {
+		return capacity
+	}
+
+	units := strings.ReplaceAll(*disk.CapacityAllocationUnits, " ", "")
+	if units == "byte" {
+		return capacity
+	}
+	if !strings.HasPrefix(units, "byte*2^") {
+		return 0
+	}
+	exp, err := strconv.Atoi(strings.TrimPrefix(units, "byte*2^"))
+	if err != nil || exp < 0 || exp > 62 {
+		return 0
+	}
+	return capacity << uint(exp)
+}
+
 // isOVFV1Alpha1Compatible checks the image if it has VMOperatorV1Alpha1ExtraConfigKey set to VMOperatorV1Alpha1ConfigReady
 // in the ExtraConfig.
 func isOVFV1Alpha1Compatible(ovfEnvelope *ovf.Envelope) bool {
@@ -0,0 +1,186 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package contentlibrary
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/vmware/govmomi/vapi/library"
+
+	"github.com/acharyasreej/vm-operator/pkg/lib"
+)
+
+var (
+	defaultOvfCache     *OvfCache
+	defaultOvfCacheOnce sync.Once
+)
+
+// getDefaultOvfCache returns the OVF cache shared by all the providers, so that the cache outlives the
+// vSphere client that a provider is created for.
+func getDefaultOvfCache() *OvfCache {
+	defaultOvfCacheOnce.Do(func() {
+		defaultOvfCache = NewOvfCache(lib.GetContentLibraryOvfCacheDir())
+	})
+	return defaultOvfCache
+}
+
+type ovfCacheEntry struct {
+	libraryID      string
+	contentVersion string
+	ovf            []byte
+}
+
+// OvfCache caches the OVF descriptors of content library items by the content version of the items,
+// so that the OVF of an item is only downloaded again when the content of the item changes. When
+// created with a directory, the OVFs are also written to a directory per library in the directory so
+// that they persist across restarts. The OVFs of the items that are no longer in a library are evicted
+// when the library is pruned.
+type OvfCache struct {
+	mutex   sync.Mutex
+	dir     string
+	entries map[string]ovfCacheEntry
+}
+
+// NewOvfCache returns an OvfCache that persists the OVFs in the directory, or that only caches the OVFs
+// in memory if the directory is empty.
+func NewOvfCache(dir string) *OvfCache {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			log.Error(err, "failed to create OVF cache directory, caching OVFs in memory only", "dir", dir)
+			dir = ""
+		} else {
+			// Remove the OVFs that were written to the directory itself before the OVFs were kept per library.
+			removeFiles(filepath.Join(dir, "*.ovf"))
+		}
+	}
+
+	return &OvfCache{
+		dir:     dir,
+		entries: map[string]ovfCacheEntry{},
+	}
+}
+
+// itemContentVersion returns the version of the content of the item. The item version, which also changes
+// when only the metadata of the item changes, is used when the content version is not known.
+func itemContentVersion(item *library.Item) string {
+	if item.ContentVersion != "" {
+		return item.ContentVersion
+	}
+	return item.Version
+}
+
+func (c *OvfCache) path(item *library.Item, contentVersion string) string {
+	return filepath.Join(c.dir, item.LibraryID, item.ID+"_"+contentVersion+".ovf")
+}
+
+// Get returns the cached OVF of the current content version of the item.
+func (c *OvfCache) Get(item *library.Item) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	contentVersion := itemContentVersion(item)
+	if entry, ok := c.entries[item.ID]; ok && entry.contentVersion == contentVersion {
+		return entry.ovf, true
+	}
+
+	if c.dir == "" {
+		return nil, false
+	}
+
+	data, err := ioutil.ReadFile(c.path(item, contentVersion))
+	if err != nil {
+		return nil, false
+	}
+
+	c.entries[item.ID] = ovfCacheEntry{libraryID: item.LibraryID, contentVersion: contentVersion, ovf: data}
+	return data, true
+}
+
+// Put caches the OVF of the current content version of the item, and evicts the OVFs of the previous
+// content versions of the item.
+func (c *OvfCache) Put(item *library.Item, data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	contentVersion := itemContentVersion(item)
+	c.entries[item.ID] = ovfCacheEntry{libraryID: item.LibraryID, contentVersion: contentVersion, ovf: data}
+
+	if c.dir == "" {
+		return
+	}
+
+	removeFiles(filepath.Join(c.dir, item.LibraryID, item.ID+"_*.ovf"))
+	if err := os.MkdirAll(filepath.Join(c.dir, item.LibraryID), 0750); err != nil {
+		log.Error(err, "failed to create OVF cache directory of library", "libraryID", item.LibraryID, "dir", c.dir)
+		return
+	}
+	if err := ioutil.WriteFile(c.path(item, contentVersion), data, 0640); err != nil {
+		log.Error(err, "failed to write OVF to cache directory", "itemID", item.ID, "dir", c.dir)
+	}
+}
+
+// Delete evicts the OVFs of the item.
+func (c *OvfCache) Delete(item *library.Item) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, item.ID)
+	if c.dir != "" {
+		removeFiles(filepath.Join(c.dir, item.LibraryID, item.ID+"_*.ovf"))
+	}
+}
+
+// Prune evicts the OVFs of the items of the library that are not in the current items of the library. All
+// the OVFs of the library are evicted when it does not have items, for example when it was deleted.
+func (c *OvfCache) Prune(libraryID string, items []library.Item) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	itemIDs := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		itemIDs[item.ID] = struct{}{}
+	}
+
+	for itemID, entry := range c.entries {
+		if _, ok := itemIDs[itemID]; !ok && entry.libraryID == libraryID {
+			delete(c.entries, itemID)
+		}
+	}
+
+	if c.dir == "" || libraryID == "" {
+		return
+	}
+
+	libraryDir := filepath.Join(c.dir, libraryID)
+	if len(items) == 0 {
+		_ = os.RemoveAll(libraryDir)
+		return
+	}
+
+	files, err := filepath.Glob(filepath.Join(libraryDir, "*.ovf"))
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		itemID := strings.SplitN(filepath.Base(f), "_", 2)[0]
+		if _, ok := itemIDs[itemID]; !ok {
+			_ = os.Remove(f)
+		}
+	}
+}
+
+func removeFiles(pattern string) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+
+	for _, f := range files {
+		_ = os.Remove(f)
+	}
+}
@@ -0,0 +1,140 @@
+// +build !integration
+
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package contentlibrary_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vmware/govmomi/vapi/library"
+
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/contentlibrary"
+)
+
+var _ = Describe("OvfCache", func() {
+	var (
+		dir   string
+		cache *contentlibrary.OvfCache
+		item  *library.Item
+	)
+
+	BeforeEach(func() {
+		dir = ""
+		item = &library.Item{
+			ID:             "dummy-item-id",
+			LibraryID:      "dummy-library-id",
+			Version:        "3",
+			ContentVersion: "1",
+		}
+	})
+
+	JustBeforeEach(func() {
+		cache = contentlibrary.NewOvfCache(dir)
+	})
+
+	Context("In memory", func() {
+		It("returns the OVF of the content version of the item", func() {
+			_, ok := cache.Get(item)
+			Expect(ok).To(BeFalse())
+
+			cache.Put(item, []byte("ovf-1"))
+			data, ok := cache.Get(item)
+			Expect(ok).To(BeTrue())
+			Expect(string(data)).To(Equal("ovf-1"))
+
+			By("ignoring metadata only changes", func() {
+				item.Version = "4"
+				_, ok := cache.Get(item)
+				Expect(ok).To(BeTrue())
+			})
+
+			By("missing when the content changes", func() {
+				item.ContentVersion = "2"
+				_, ok := cache.Get(item)
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		It("evicts the OVF of a deleted item", func() {
+			cache.Put(item, []byte("ovf-1"))
+			cache.Delete(item)
+			_, ok := cache.Get(item)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("evicts the OVFs of the items that are no longer in the library", func() {
+			otherItem := &library.Item{ID: "other-item-id", LibraryID: item.LibraryID, ContentVersion: "1"}
+			otherLibraryItem := &library.Item{ID: "other-library-item-id", LibraryID: "other-library-id", ContentVersion: "1"}
+			cache.Put(item, []byte("ovf-1"))
+			cache.Put(otherItem, []byte("ovf-1"))
+			cache.Put(otherLibraryItem, []byte("ovf-1"))
+
+			cache.Prune(item.LibraryID, []library.Item{*item})
+			_, ok := cache.Get(item)
+			Expect(ok).To(BeTrue())
+			_, ok = cache.Get(otherItem)
+			Expect(ok).To(BeFalse())
+			_, ok = cache.Get(otherLibraryItem)
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Context("With a directory", func() {
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "ovf-cache-")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("persists the OVFs across caches", func() {
+			cache.Put(item, []byte("ovf-1"))
+
+			data, ok := contentlibrary.NewOvfCache(dir).Get(item)
+			Expect(ok).To(BeTrue())
+			Expect(string(data)).To(Equal("ovf-1"))
+		})
+
+		It("removes the OVFs of previous content versions", func() {
+			cache.Put(item, []byte("ovf-1"))
+			item.ContentVersion = "2"
+			cache.Put(item, []byte("ovf-2"))
+
+			files, err := filepath.Glob(filepath.Join(dir, item.LibraryID, "*.ovf"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+		})
+
+		It("removes the OVFs of the items that are no longer in the library", func() {
+			otherItem := &library.Item{ID: "other-item-id", LibraryID: item.LibraryID, ContentVersion: "1"}
+			cache.Put(item, []byte("ovf-1"))
+			cache.Put(otherItem, []byte("ovf-1"))
+
+			cache.Prune(item.LibraryID, []library.Item{*item})
+			files, err := filepath.Glob(filepath.Join(dir, item.LibraryID, "*.ovf"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(ConsistOf(filepath.Join(dir, item.LibraryID, "dummy-item-id_1.ovf")))
+
+			_, ok := contentlibrary.NewOvfCache(dir).Get(otherItem)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("removes the OVFs of a library without items", func() {
+			cache.Put(item, []byte("ovf-1"))
+
+			cache.Prune(item.LibraryID, nil)
+			_, err := os.Stat(filepath.Join(dir, item.LibraryID))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+})
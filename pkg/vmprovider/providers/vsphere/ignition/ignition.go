@@ -0,0 +1,231 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package ignition
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultVersion is the version of the Ignition config when the VM metadata does not have one.
+	DefaultVersion = "3.0.0"
+
+	// maxFileMode is the largest mode of an Ignition file or directory, with the setuid, setgid and sticky bits.
+	maxFileMode = 07777
+)
+
+// SupportedVersions are the versions of the Ignition config spec that are supported.
+var SupportedVersions = []string{"3.0.0", "3.1.0", "3.2.0", "3.3.0", "3.4.0"}
+
+// supportedSourceSchemes are the URL schemes of the sources of the contents of Ignition files.
+var supportedSourceSchemes = map[string]struct{}{
+	"data":  {},
+	"http":  {},
+	"https": {},
+	"tftp":  {},
+	"s3":    {},
+	"gs":    {},
+}
+
+// Config is the subset of an Ignition v3 config that is validated. The other sections of the config are passed
+// to the guest as they are.
+type Config struct {
+	Ignition struct {
+		Version string `json:"version"`
+	} `json:"ignition"`
+	Storage *Storage `json:"storage,omitempty"`
+	Systemd *Systemd `json:"systemd,omitempty"`
+	Passwd  *Passwd  `json:"passwd,omitempty"`
+}
+
+// Storage is the storage section of an Ignition config.
+type Storage struct {
+	Directories []Node `json:"directories,omitempty"`
+	Files       []File `json:"files,omitempty"`
+	Links       []Link `json:"links,omitempty"`
+}
+
+// Node is a file system node of an Ignition config.
+type Node struct {
+	Path string `json:"path"`
+	Mode *int   `json:"mode,omitempty"`
+}
+
+// File is a file of an Ignition config.
+type File struct {
+	Node
+	Contents *Resource  `json:"contents,omitempty"`
+	Append   []Resource `json:"append,omitempty"`
+}
+
+// Link is a link of an Ignition config.
+type Link struct {
+	Node
+	Target string `json:"target"`
+}
+
+// Resource is the source of the contents of a file of an Ignition config.
+type Resource struct {
+	Source *string `json:"source,omitempty"`
+}
+
+// Systemd is the systemd section of an Ignition config.
+type Systemd struct {
+	Units []Unit `json:"units,omitempty"`
+}
+
+// Unit is a systemd unit of an Ignition config.
+type Unit struct {
+	Name string `json:"name"`
+}
+
+// Passwd is the passwd section of an Ignition config.
+type Passwd struct {
+	Users  []User  `json:"users,omitempty"`
+	Groups []Group `json:"groups,omitempty"`
+}
+
+// User is a user of an Ignition config.
+type User struct {
+	Name string `json:"name"`
+}
+
+// Group is a group of an Ignition config.
+type Group struct {
+	Name string `json:"name"`
+}
+
+// Validate validates the Ignition config: its version must be supported, and the files, directories, links,
+// systemd units, users and groups must be well-formed, like Ignition in the guest checks before it applies the
+// config. An empty config is valid, since the default config is used instead.
+func Validate(data string) error {
+	if data == "" {
+		return nil
+	}
+
+	config := Config{}
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		return err
+	}
+
+	return config.validate()
+}
+
+func (c Config) validate() error {
+	if c.Ignition.Version == "" {
+		return errors.New("ignition.version is required")
+	}
+	if !isSupportedVersion(c.Ignition.Version) {
+		return errors.Errorf("unsupported ignition.version %s, supported versions are %s",
+			c.Ignition.Version, strings.Join(SupportedVersions, ", "))
+	}
+
+	var errs []string
+	if c.Storage != nil {
+		errs = append(errs, c.Storage.validate()...)
+	}
+	if c.Systemd != nil {
+		for i, unit := range c.Systemd.Units {
+			if unit.Name == "" || strings.Contains(unit.Name, "/") || path.Ext(unit.Name) == "" {
+				errs = append(errs, fmt.Sprintf("systemd.units[%d].name %q is not a valid unit name", i, unit.Name))
+			}
+		}
+	}
+	if c.Passwd != nil {
+		for i, user := range c.Passwd.Users {
+			if user.Name == "" {
+				errs = append(errs, fmt.Sprintf("passwd.users[%d].name is required", i))
+			}
+		}
+		for i, group := range c.Passwd.Groups {
+			if group.Name == "" {
+				errs = append(errs, fmt.Sprintf("passwd.groups[%d].name is required", i))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (s Storage) validate() []string {
+	var errs []string
+
+	paths := map[string]string{}
+	checkNode := func(field string, node Node) {
+		if !path.IsAbs(node.Path) || path.Clean(node.Path) != node.Path {
+			errs = append(errs, fmt.Sprintf("%s.path %q must be a clean absolute path", field, node.Path))
+		} else if other, ok := paths[node.Path]; ok {
+			errs = append(errs, fmt.Sprintf("%s.path %q is also the path of %s", field, node.Path, other))
+		} else {
+			paths[node.Path] = field
+		}
+		if node.Mode != nil && (*node.Mode < 0 || *node.Mode > maxFileMode) {
+			errs = append(errs, fmt.Sprintf("%s.mode %o is not a valid file mode", field, *node.Mode))
+		}
+	}
+
+	for i, dir := range s.Directories {
+		checkNode(fmt.Sprintf("storage.directories[%d]", i), dir)
+	}
+	for i, file := range s.Files {
+		field := fmt.Sprintf("storage.files[%d]", i)
+		checkNode(field, file.Node)
+		if file.Contents != nil {
+			if err := validateSource(file.Contents.Source); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.contents.source %v", field, err))
+			}
+		}
+		for j, r := range file.Append {
+			if err := validateSource(r.Source); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.append[%d].source %v", field, j, err))
+			}
+		}
+	}
+	for i, link := range s.Links {
+		field := fmt.Sprintf("storage.links[%d]", i)
+		checkNode(field, link.Node)
+		if link.Target == "" {
+			errs = append(errs, fmt.Sprintf("%s.target is required", field))
+		}
+	}
+
+	return errs
+}
+
+func validateSource(source *string) error {
+	if source == nil {
+		return nil
+	}
+
+	u, err := url.Parse(*source)
+	if err != nil {
+		return err
+	}
+	if _, ok := supportedSourceSchemes[u.Scheme]; !ok {
+		return errors.Errorf("has unsupported scheme %q", u.Scheme)
+	}
+	if u.Scheme == "data" && !strings.Contains(u.Opaque, ",") {
+		return errors.New("is not a valid data URL")
+	}
+
+	return nil
+}
+
+func isSupportedVersion(version string) bool {
+	for _, v := range SupportedVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
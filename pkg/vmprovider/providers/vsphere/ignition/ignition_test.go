@@ -0,0 +1,57 @@
+// +build !integration
+
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package ignition_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/ignition"
+)
+
+var _ = Describe("Validate", func() {
+	DescribeTable("Ignition configs",
+		func(config string, expectedErr string) {
+			err := ignition.Validate(config)
+			if expectedErr == "" {
+				Expect(err).ToNot(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+			}
+		},
+		Entry("empty config", ``, ""),
+		Entry("minimal config", `{"ignition": {"version": "3.0.0"}}`, ""),
+		Entry("config with files, units and users", `{
+  "ignition": {"version": "3.3.0"},
+  "passwd": {"users": [{"name": "core", "sshAuthorizedKeys": ["ssh-rsa AAAA"]}]},
+  "storage": {
+    "directories": [{"path": "/opt/app", "mode": 493}],
+    "files": [{"path": "/opt/app/config", "mode": 420, "contents": {"source": "data:;base64,Zm9v"}}],
+    "links": [{"path": "/opt/current", "target": "/opt/app"}]
+  },
+  "systemd": {"units": [{"name": "app.service", "enabled": true}]}
+}`, ""),
+		Entry("invalid JSON", `{"ignition": `, "unexpected end of JSON input"),
+		Entry("missing version", `{"storage": {}}`, "ignition.version is required"),
+		Entry("unsupported version", `{"ignition": {"version": "2.3.0"}}`, "unsupported ignition.version 2.3.0"),
+		Entry("storage that is not an object", `{"ignition": {"version": "3.0.0"}, "storage": []}`, "cannot unmarshal array"),
+		Entry("relative file path", `{"ignition": {"version": "3.0.0"}, "storage": {"files": [{"path": "etc/motd"}]}}`,
+			`storage.files[0].path "etc/motd" must be a clean absolute path`),
+		Entry("duplicate paths", `{"ignition": {"version": "3.0.0"}, "storage": {"directories": [{"path": "/etc/app"}], "files": [{"path": "/etc/app"}]}}`,
+			`storage.files[0].path "/etc/app" is also the path of storage.directories[0]`),
+		Entry("invalid file mode", `{"ignition": {"version": "3.0.0"}, "storage": {"files": [{"path": "/etc/motd", "mode": 65535}]}}`,
+			"storage.files[0].mode 177777 is not a valid file mode"),
+		Entry("unsupported source scheme", `{"ignition": {"version": "3.0.0"}, "storage": {"files": [{"path": "/etc/motd", "contents": {"source": "file:///etc/passwd"}}]}}`,
+			`storage.files[0].contents.source has unsupported scheme "file"`),
+		Entry("link without target", `{"ignition": {"version": "3.0.0"}, "storage": {"links": [{"path": "/etc/link"}]}}`,
+			"storage.links[0].target is required"),
+		Entry("invalid unit name", `{"ignition": {"version": "3.0.0"}, "systemd": {"units": [{"name": "app"}]}}`,
+			`systemd.units[0].name "app" is not a valid unit name`),
+		Entry("user without name", `{"ignition": {"version": "3.0.0"}, "passwd": {"users": [{"uid": 1000}]}}`,
+			"passwd.users[0].name is required"),
+	)
+})
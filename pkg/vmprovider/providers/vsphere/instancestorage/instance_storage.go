@@ -4,7 +4,11 @@
 package instancestorage
 
 import (
+	"encoding/json"
+
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 )
 
 // IsConfigured checks if VM spec has instance volumes to identify if VM is configured with instance storage
@@ -34,3 +38,49 @@ func FilterVolumes(vm *vmopv1alpha1.VirtualMachine) []vmopv1alpha1.VirtualMachin
 
 	return volumes
 }
+
+// AppendPlacementAttempt appends the attempt to the diagnostics, dropping the oldest attempts beyond the
+// InstanceStoragePlacementMaxAttempts, and returns the appended attempt.
+func AppendPlacementAttempt(
+	diagnostics *vmopapi.InstanceStoragePlacementDiagnostics,
+	attempt vmopapi.InstanceStoragePlacementAttempt) *vmopapi.InstanceStoragePlacementAttempt {
+
+	diagnostics.Attempts = append(diagnostics.Attempts, attempt)
+	if n := len(diagnostics.Attempts); n > vmopapi.InstanceStoragePlacementMaxAttempts {
+		diagnostics.Attempts = diagnostics.Attempts[n-vmopapi.InstanceStoragePlacementMaxAttempts:]
+	}
+	return &diagnostics.Attempts[len(diagnostics.Attempts)-1]
+}
+
+// UpdatePlacementDiagnostics calls update with the placement diagnostics of the VM, which are empty when the VM
+// does not have them or they cannot be parsed, and sets the updated diagnostics on the VM.
+func UpdatePlacementDiagnostics(
+	vm *vmopv1alpha1.VirtualMachine,
+	update func(diagnostics *vmopapi.InstanceStoragePlacementDiagnostics)) error {
+
+	diagnostics, err := vmopapi.GetInstanceStoragePlacementDiagnostics(vm)
+	if err != nil || diagnostics == nil {
+		diagnostics = &vmopapi.InstanceStoragePlacementDiagnostics{}
+	}
+
+	update(diagnostics)
+	return SetPlacementDiagnostics(vm, diagnostics)
+}
+
+// SetPlacementDiagnostics sets the placement diagnostics annotation of the VM.
+func SetPlacementDiagnostics(
+	vm *vmopv1alpha1.VirtualMachine,
+	diagnostics *vmopapi.InstanceStoragePlacementDiagnostics) error {
+
+	data, err := json.Marshal(diagnostics)
+	if err != nil {
+		return err
+	}
+
+	if vm.Annotations == nil {
+		vm.Annotations = map[string]string{}
+	}
+	vm.Annotations[vmopapi.InstanceStoragePlacementDiagnosticsAnnotation] = string(data)
+
+	return nil
+}
@@ -98,3 +98,20 @@ func CloneVMRelocateSpec(
 
 	return placeVM(ctx, cluster, placementSpec)
 }
+
+// RelocateVMRelocateSpec returns the placement of the existing VM on one of the hosts.
+func RelocateVMRelocateSpec(
+	ctx context.Context,
+	cluster *object.ClusterComputeResource,
+	vmRef vimTypes.ManagedObjectReference,
+	hosts []vimTypes.ManagedObjectReference) (*vimTypes.VirtualMachineRelocateSpec, error) {
+
+	placementSpec := vimTypes.PlacementSpec{
+		PlacementType: string(vimTypes.PlacementSpecPlacementTypeRelocate),
+		RelocateSpec:  &vimTypes.VirtualMachineRelocateSpec{},
+		Vm:            &vmRef,
+		Hosts:         hosts,
+	}
+
+	return placeVM(ctx, cluster, placementSpec)
+}
@@ -7,9 +7,11 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/guest"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/task"
 	"github.com/vmware/govmomi/vim25/mo"
@@ -27,6 +29,10 @@ type VirtualMachine struct {
 
 var log = logf.Log.WithName("vmresource")
 
+// guestProgramPollInterval is how often the guest processes are listed while waiting for a guest
+// program started by RunGuestProgram to exit.
+const guestProgramPollInterval = 500 * time.Millisecond
+
 // NewVMForCreate returns a VirtualMachine that Create() can be called on
 // to create the VM and set the VirtualMachine object reference.
 func NewVMForCreate(name string) *VirtualMachine {
@@ -231,6 +237,125 @@ func (vm *VirtualMachine) SetPowerState(ctx context.Context, desiredPowerState v
 	return nil
 }
 
+// Reset hard resets the VM.
+func (vm *VirtualMachine) Reset(ctx context.Context) error {
+	vm.logger.V(5).Info("Reset VM")
+
+	resetTask, err := vm.vcVirtualMachine.Reset(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := resetTask.WaitForResult(ctx, nil); err != nil {
+		return errors.Wrapf(err, "reset VM task failed")
+	}
+
+	return nil
+}
+
+// CreateSnapshot snapshots the VM and returns the reference of the new snapshot.
+func (vm *VirtualMachine) CreateSnapshot(ctx context.Context, name, description string, memory, quiesce bool) (*types.ManagedObjectReference, error) {
+	vm.logger.V(5).Info("Create VM snapshot", "snapshotName", name)
+
+	snapshotTask, err := vm.vcVirtualMachine.CreateSnapshot(ctx, name, description, memory, quiesce)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := snapshotTask.WaitForResult(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create VM snapshot task failed")
+	}
+
+	ref := result.Result.(types.ManagedObjectReference)
+	return &ref, nil
+}
+
+// RevertToSnapshot reverts the VM to the snapshot, which may be the snapshot name or ID. The VM is
+// powered on if it was powered on when the snapshot was taken.
+func (vm *VirtualMachine) RevertToSnapshot(ctx context.Context, snapshot string) error {
+	vm.logger.V(5).Info("Revert VM to snapshot", "snapshot", snapshot)
+
+	revertTask, err := vm.vcVirtualMachine.RevertToSnapshot(ctx, snapshot, false)
+	if err != nil {
+		return err
+	}
+
+	if _, err := revertTask.WaitForResult(ctx, nil); err != nil {
+		return errors.Wrapf(err, "revert VM to snapshot task failed")
+	}
+
+	return nil
+}
+
+// RemoveSnapshot removes the snapshot, which may be the snapshot name or ID, and consolidates the
+// disks of the VM. The children of the snapshot are not removed.
+func (vm *VirtualMachine) RemoveSnapshot(ctx context.Context, snapshot string) error {
+	vm.logger.V(5).Info("Remove VM snapshot", "snapshot", snapshot)
+
+	consolidate := true
+	removeTask, err := vm.vcVirtualMachine.RemoveSnapshot(ctx, snapshot, false, &consolidate)
+	if err != nil {
+		return err
+	}
+
+	if _, err := removeTask.WaitForResult(ctx, nil); err != nil {
+		return errors.Wrapf(err, "remove VM snapshot task failed")
+	}
+
+	return nil
+}
+
+// Relocate relocates the VM, for example into a different ResourcePool.
+func (vm *VirtualMachine) Relocate(ctx context.Context, spec types.VirtualMachineRelocateSpec) error {
+	vm.logger.V(5).Info("Relocate VM", "spec", spec)
+
+	relocateTask, err := vm.vcVirtualMachine.Relocate(ctx, spec, types.VirtualMachineMovePriorityDefaultPriority)
+	if err != nil {
+		return err
+	}
+
+	if _, err := relocateTask.WaitForResult(ctx, nil); err != nil {
+		return errors.Wrapf(err, "relocate VM task failed")
+	}
+
+	return nil
+}
+
+// MoveInto moves the VM into the folder.
+func (vm *VirtualMachine) MoveInto(ctx context.Context, folder *object.Folder) error {
+	vm.logger.V(5).Info("Move VM into folder", "folder", folder.Reference())
+
+	moveTask, err := folder.MoveInto(ctx, []types.ManagedObjectReference{vm.vcVirtualMachine.Reference()})
+	if err != nil {
+		return err
+	}
+
+	if _, err := moveTask.WaitForResult(ctx, nil); err != nil {
+		return errors.Wrapf(err, "move VM into folder task failed")
+	}
+
+	return nil
+}
+
+// Rename renames the VM.
+func (vm *VirtualMachine) Rename(ctx context.Context, name string) error {
+	vm.logger.V(5).Info("Rename VM", "newName", name)
+
+	renameTask, err := vm.vcVirtualMachine.Rename(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := renameTask.WaitForResult(ctx, nil); err != nil {
+		return errors.Wrapf(err, "rename VM task failed")
+	}
+
+	vm.Name = name
+	vm.logger = log.WithValues("name", name)
+	return nil
+}
+
 // GetVirtualDevices returns the VMs VirtualDeviceList.
 func (vm *VirtualMachine) GetVirtualDevices(ctx context.Context) (object.VirtualDeviceList, error) {
 	vm.logger.V(5).Info("GetVirtualDevices")
@@ -293,3 +418,46 @@ func (vm *VirtualMachine) Customize(ctx context.Context, spec types.Customizatio
 
 	return nil
 }
+
+// RunGuestProgram starts the program in the guest via the VMware Tools guest operations, waits for
+// it to exit, and returns its exit code. The arguments are the command line of the program, already
+// quoted for the guest OS. The caller should bound the wait with the context.
+func (vm *VirtualMachine) RunGuestProgram(ctx context.Context, auth types.BaseGuestAuthentication, path, arguments string) (int32, error) {
+	vm.logger.V(5).Info("RunGuestProgram", "path", path)
+
+	opsManager := guest.NewOperationsManager(vm.vcVirtualMachine.Client(), vm.vcVirtualMachine.Reference())
+	procManager, err := opsManager.ProcessManager(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get guest process manager")
+	}
+
+	spec := &types.GuestProgramSpec{
+		ProgramPath: path,
+		Arguments:   arguments,
+	}
+
+	pid, err := procManager.StartProgram(ctx, auth, spec)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to start guest program %q", path)
+	}
+
+	for {
+		procs, err := procManager.ListProcesses(ctx, auth, []int64{pid})
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to list guest process %d", pid)
+		}
+		if len(procs) == 0 {
+			return 0, fmt.Errorf("guest process %d for program %q not found", pid, path)
+		}
+
+		if procs[0].EndTime != nil {
+			return procs[0].ExitCode, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, errors.Wrapf(ctx.Err(), "waiting for guest program %q to exit", path)
+		case <-time.After(guestProgramPollInterval):
+		}
+	}
+}
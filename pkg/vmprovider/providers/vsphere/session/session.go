@@ -25,6 +25,7 @@ import (
 	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
 	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/lib"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/client"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/config"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
@@ -492,6 +493,14 @@ func (s *Session) GetVirtualMachine(vmCtx context.VirtualMachineContext) (*res.V
 	path := folder.InventoryPath + "/" + vmCtx.VM.Name
 	vm, err := s.Finder.VirtualMachine(vmCtx, path)
 	if err != nil {
+		if lib.IsVMServiceBackupRestoreFSSEnabled() {
+			// The VM may have been restored on vSphere, so adopt the restored VM instead of
+			// reporting that the VM does not exist.
+			if resVM, restoreErr := s.lookupRestoredVM(vmCtx); restoreErr != nil || resVM != nil {
+				return resVM, restoreErr
+			}
+		}
+
 		vmCtx.Logger.Error(err, "Failed lookup VM by path", "path", path)
 		return nil, err
 	}
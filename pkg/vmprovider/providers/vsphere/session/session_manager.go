@@ -13,6 +13,7 @@ import (
 
 	"github.com/acharyasreej/vm-operator/pkg/context"
 	"github.com/acharyasreej/vm-operator/pkg/lib"
+	"github.com/acharyasreej/vm-operator/pkg/metrics"
 	"github.com/acharyasreej/vm-operator/pkg/topology"
 	vcclient "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/client"
 	vcconfig "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/config"
@@ -62,6 +63,7 @@ func (sm *Manager) DeleteSession(
 	for _, az := range availabilityZones {
 		delete(sm.sessions, getSessionKey(az.Name, namespace))
 	}
+	metrics.SetSessionCacheSize(len(sm.sessions))
 
 	return nil
 }
@@ -112,6 +114,7 @@ func (sm *Manager) GetSession(
 		return nil, err
 	}
 	sm.sessions[sessionKey] = newSession
+	metrics.SetSessionCacheSize(len(sm.sessions))
 
 	return newSession, nil
 }
@@ -250,6 +253,7 @@ func (sm *Manager) clearSessionsAndClient(ctx goctx.Context) {
 	for k := range sm.sessions {
 		delete(sm.sessions, k)
 	}
+	metrics.SetSessionCacheSize(0)
 
 	if sm.client != nil {
 		sm.client.Logout(ctx)
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
+	"io/ioutil"
 	"math"
 	"strings"
 
@@ -120,3 +121,73 @@ func EncodeGzipBase64(s string) (string, error) {
 	b64 := base64.StdEncoding.EncodeToString(zbuf.Bytes())
 	return b64, nil
 }
+
+// DecodeGzipBase64 is the inverse of EncodeGzipBase64.
+func DecodeGzipBase64(s string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	out, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// GuestProgramArguments returns the command line of the arguments of a guest program, with each argument quoted
+// for the guest OS family so that it is passed to the program as a single argument even when it contains spaces
+// or quotes. Windows guests split the command line like CommandLineToArgvW, and other guests like a POSIX shell.
+func GuestProgramArguments(args []string, guestFamily string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if guestFamily == string(vimTypes.VirtualMachineGuestOsFamilyWindowsGuest) {
+			quoted = append(quoted, quoteWindowsArgument(arg))
+		} else {
+			quoted = append(quoted, quotePOSIXArgument(arg))
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quotePOSIXArgument(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,@%+", r))
+	}) == -1 {
+		return arg
+	}
+	// Nothing is special within single quotes, so only a single quote needs to be closed, escaped and reopened.
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func quoteWindowsArgument(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	backslashes := 0
+	for _, r := range arg {
+		switch r {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			// The backslashes before a quote, and the quote itself, must be escaped.
+			b.WriteString(strings.Repeat(`\`, 2*backslashes+1))
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
+		}
+		backslashes = 0
+		b.WriteRune(r)
+	}
+	// The backslashes before the closing quote must be escaped.
+	b.WriteString(strings.Repeat(`\`, 2*backslashes))
+	b.WriteByte('"')
+	return b.String()
+}
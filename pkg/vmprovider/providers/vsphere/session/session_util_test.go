@@ -223,4 +223,26 @@ var _ = Describe("Test Session Utils", func() {
 			Expect(output).To(Equal("H4sIAAAAAAAA//JIzcnJD88vykkBAAAA//8BAAD//3kMd3cKAAAA"))
 		})
 	})
+
+	Context("GuestProgramArguments", func() {
+		linux := string(vimTypes.VirtualMachineGuestOsFamilyLinuxGuest)
+		windows := string(vimTypes.VirtualMachineGuestOsFamilyWindowsGuest)
+
+		DescribeTable("quotes each argument for the guest",
+			func(args []string, guestFamily, expected string) {
+				Expect(session.GuestProgramArguments(args, guestFamily)).To(Equal(expected))
+			},
+			Entry("no arguments", nil, linux, ""),
+			Entry("plain arguments", []string{"-c", "/tmp/ready.sh"}, linux, "-c /tmp/ready.sh"),
+			Entry("argument with spaces", []string{"-c", "test -f /tmp/ready"}, linux, `-c 'test -f /tmp/ready'`),
+			Entry("argument with quotes", []string{"it's", "$HOME"}, linux, `'it'\''s' '$HOME'`),
+			Entry("empty argument", []string{""}, linux, "''"),
+			Entry("unknown guest family", []string{"a b"}, "", "'a b'"),
+			Entry("Windows plain arguments", []string{"/c", `C:\ready.cmd`}, windows, `/c C:\ready.cmd`),
+			Entry("Windows argument with spaces", []string{`C:\Program Files\`}, windows, `"C:\Program Files\\"`),
+			Entry("Windows argument with quotes", []string{`say "hi"`}, windows, `"say \"hi\""`),
+			Entry("Windows backslashes before a quote", []string{`a\"b`}, windows, `"a\\\"b"`),
+			Entry("Windows empty argument", []string{""}, windows, `""`),
+		)
+	})
 })
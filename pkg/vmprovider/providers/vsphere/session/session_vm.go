@@ -9,6 +9,7 @@ import (
 	vimTypes "github.com/vmware/govmomi/vim25/types"
 
 	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 )
@@ -47,6 +48,56 @@ func (s *Session) GetVirtualMachineGuestHeartbeat(vmCtx context.VirtualMachineCo
 	return vmopv1alpha1.GuestHeartbeatStatus(moVM.GuestHeartbeatStatus), nil
 }
 
+// RunVirtualMachineGuestCommand runs the command in the VM's guest via the VMware Tools guest
+// operations and returns the exit code of the command. The arguments are quoted for the guest OS.
+func (s *Session) RunVirtualMachineGuestCommand(vmCtx context.VirtualMachineContext, cmd vmprovider.GuestCommand) (int32, error) {
+	resVM, err := s.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return 0, transformVMError(vmCtx.VM.NamespacedName(), err)
+	}
+
+	moVM, err := resVM.GetProperties(vmCtx, []string{"guest.guestFamily"})
+	if err != nil {
+		return 0, err
+	}
+
+	guestFamily := ""
+	if moVM.Guest != nil {
+		guestFamily = moVM.Guest.GuestFamily
+	}
+
+	auth := &vimTypes.NamePasswordAuthentication{
+		Username: cmd.Username,
+		Password: cmd.Password,
+	}
+
+	return resVM.RunGuestProgram(vmCtx, auth, cmd.Path, GuestProgramArguments(cmd.Args, guestFamily))
+}
+
+// ResetVirtualMachine hard resets the VM.
+func (s *Session) ResetVirtualMachine(vmCtx context.VirtualMachineContext) error {
+	resVM, err := s.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return transformVMError(vmCtx.VM.NamespacedName(), err)
+	}
+
+	return resVM.Reset(vmCtx)
+}
+
+// PowerCycleVirtualMachine powers the VM off and then back on.
+func (s *Session) PowerCycleVirtualMachine(vmCtx context.VirtualMachineContext) error {
+	resVM, err := s.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return transformVMError(vmCtx.VM.NamespacedName(), err)
+	}
+
+	if err := resVM.SetPowerState(vmCtx, vmopv1alpha1.VirtualMachinePoweredOff); err != nil {
+		return err
+	}
+
+	return resVM.SetPowerState(vmCtx, vmopv1alpha1.VirtualMachinePoweredOn)
+}
+
 func updateVirtualDiskDeviceChanges(
 	vmCtx context.VirtualMachineContext,
 	virtualDisks object.VirtualDeviceList) ([]vimTypes.BaseVirtualDeviceConfigSpec, error) {
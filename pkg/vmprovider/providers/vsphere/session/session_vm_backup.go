@@ -0,0 +1,260 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
+	res "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
+)
+
+// backupExcludedAnnotations are the annotations that VM Operator updates as the VM is reconciled.
+// They are not part of the backup, since they are rebuilt after the restore and would otherwise
+// require a reconfigure of the VM every time they change.
+var backupExcludedAnnotations = []string{
+	vmopapi.CustomizationRequestedAtAnnotation,
+	vmopapi.CustomizationAttemptsAnnotation,
+	vmopapi.VirtualMachineStatusDetailsAnnotation,
+	vmopapi.VirtualMachineRecentTasksAnnotation,
+	vmopapi.InstanceStoragePlacementDiagnosticsAnnotation,
+}
+
+// BackupVirtualMachine stores the Kubernetes state of the VM in its ExtraConfig, so that backup tools
+// that restore the vSphere VM also restore what is needed to rebuild the VM in Kubernetes. The VM is
+// only reconfigured when the state has changed since the last backup.
+func (s *Session) BackupVirtualMachine(vmCtx context.VirtualMachineContext, args vmprovider.VMBackupArgs) error {
+	resVM, err := s.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return transformVMError(vmCtx.VM.NamespacedName(), err)
+	}
+
+	backupExtraConfig, err := GetBackupExtraConfig(vmCtx.VM, args)
+	if err != nil {
+		return err
+	}
+
+	moVM, err := resVM.GetProperties(vmCtx, []string{"config.extraConfig"})
+	if err != nil {
+		return err
+	}
+
+	var curExtraConfig map[string]string
+	if moVM.Config != nil {
+		curExtraConfig = ExtraConfigToMap(moVM.Config.ExtraConfig)
+	}
+
+	keys := make([]string, 0, len(backupExtraConfig))
+	for k := range backupExtraConfig {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// An empty value removes the key from the ExtraConfig, and a missing key is the same as an empty value.
+	var extraConfig []vimTypes.BaseOptionValue
+	for _, k := range keys {
+		if v := backupExtraConfig[k]; curExtraConfig[k] != v {
+			extraConfig = append(extraConfig, &vimTypes.OptionValue{Key: k, Value: v})
+		}
+	}
+
+	if len(extraConfig) == 0 {
+		vmCtx.Logger.V(4).Info("VirtualMachine backup is up to date")
+		return nil
+	}
+
+	vmCtx.Logger.Info("Backing up VirtualMachine", "numKeys", len(extraConfig))
+	return resVM.Reconfigure(vmCtx, &vimTypes.VirtualMachineConfigSpec{ExtraConfig: extraConfig})
+}
+
+// GetBackupExtraConfig returns the ExtraConfig that holds the backup of the VM. The values are
+// empty for the data that the VM does not have.
+func GetBackupExtraConfig(vm *vmopv1alpha1.VirtualMachine, args vmprovider.VMBackupArgs) (map[string]string, error) {
+	vmYAML, err := getVMBackupYAML(vm)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadataYAML string
+	if args.VMMetadata != nil {
+		if metadataYAML, err = getObjectBackupYAML(args.VMMetadata); err != nil {
+			return nil, err
+		}
+	}
+
+	var pvcDiskDataJSON string
+	if len(args.DiskUUIDToPVC) > 0 {
+		pvcDiskData := make([]vmopapi.PVCDiskData, 0, len(args.DiskUUIDToPVC))
+		for diskUUID, pvc := range args.DiskUUIDToPVC {
+			pvcDiskData = append(pvcDiskData, vmopapi.PVCDiskData{
+				DiskUUID:    diskUUID,
+				PVCName:     pvc.Name,
+				AccessModes: pvc.Spec.AccessModes,
+			})
+		}
+		// Sort so the data does not change, and require a reconfigure, between reconciles.
+		sort.Slice(pvcDiskData, func(i, j int) bool {
+			return pvcDiskData[i].DiskUUID < pvcDiskData[j].DiskUUID
+		})
+
+		data, err := json.Marshal(pvcDiskData)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal PVC disk data")
+		}
+		pvcDiskDataJSON = string(data)
+	}
+
+	extraConfig := map[string]string{}
+	for k, v := range map[string]string{
+		constants.BackupVMKubeDataExtraConfigKey:            vmYAML,
+		constants.BackupVMAdditionalResourcesExtraConfigKey: metadataYAML,
+		constants.BackupVMPVCDiskDataExtraConfigKey:         pvcDiskDataJSON,
+	} {
+		if v == "" {
+			extraConfig[k] = ""
+			continue
+		}
+
+		encoded, err := EncodeGzipBase64(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to encode %s", k)
+		}
+		extraConfig[k] = encoded
+	}
+
+	return extraConfig, nil
+}
+
+// getVMBackupYAML returns the YAML of the VM without the fields that change on every update and
+// without the status and the annotations managed by VM Operator, which are rebuilt when the VM is
+// reconciled after the restore.
+func getVMBackupYAML(vm *vmopv1alpha1.VirtualMachine) (string, error) {
+	vmCopy := vm.DeepCopy()
+	vmCopy.APIVersion = vmopv1alpha1.SchemeGroupVersion.String()
+	vmCopy.Kind = "VirtualMachine"
+	vmCopy.ResourceVersion = ""
+	vmCopy.ManagedFields = nil
+	vmCopy.Generation = 0
+	vmCopy.Status = vmopv1alpha1.VirtualMachineStatus{}
+	for _, key := range backupExcludedAnnotations {
+		delete(vmCopy.Annotations, key)
+	}
+	if len(vmCopy.Annotations) == 0 {
+		vmCopy.Annotations = nil
+	}
+
+	data, err := yaml.Marshal(vmCopy)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal VirtualMachine")
+	}
+
+	return string(data), nil
+}
+
+// getObjectBackupYAML returns the YAML of the object. The data of a Secret is left out, since the
+// ExtraConfig can be read by any vCenter user who can read the configuration of the VM, so only the
+// metadata of the Secret is kept for it to be recreated after the restore.
+func getObjectBackupYAML(obj client.Object) (string, error) {
+	objCopy := obj.DeepCopyObject().(client.Object)
+	objCopy.SetResourceVersion("")
+	objCopy.SetManagedFields(nil)
+	if secret, ok := objCopy.(*corev1.Secret); ok {
+		secret.Data = nil
+		secret.StringData = nil
+	}
+
+	data, err := yaml.Marshal(objCopy)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal %s", obj.GetName())
+	}
+
+	return string(data), nil
+}
+
+// lookupRestoredVM returns the vSphere VM that the VM's restore annotations point to, or nil if
+// the VM does not have the annotations or there is no such vSphere VM.
+func (s *Session) lookupRestoredVM(vmCtx context.VirtualMachineContext) (*res.VirtualMachine, error) {
+	uuid, instanceUUID := vmCtx.VM.Annotations[vmopapi.RestoreInstanceUUIDAnnotation], true
+	if uuid == "" {
+		uuid, instanceUUID = vmCtx.VM.Annotations[vmopapi.RestoreBiosUUIDAnnotation], false
+	}
+	if uuid == "" {
+		return nil, nil
+	}
+
+	searchIndex := object.NewSearchIndex(s.Client.VimClient())
+	ref, err := searchIndex.FindByUuid(vmCtx, s.datacenter, uuid, true, &instanceUUID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find restored VM with UUID %s", uuid)
+	}
+	if ref == nil {
+		vmCtx.Logger.Info("Restored VM does not exist", "uuid", uuid, "instanceUUID", instanceUUID)
+		return nil, nil
+	}
+
+	vm, ok := ref.(*object.VirtualMachine)
+	if !ok {
+		return nil, errors.Errorf("restored VM UUID %s is for a %s", uuid, ref.Reference().Type)
+	}
+
+	resVM, err := res.NewVMFromObject(vm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only adopt a vSphere VM that is a backup of this VM, so the annotations cannot be used to take
+	// over the VMs of other namespaces or VMs that are not managed by VM Service.
+	moVM, err := resVM.GetProperties(vmCtx, []string{"config.extraConfig"})
+	if err != nil {
+		return nil, err
+	}
+	var extraConfig map[string]string
+	if moVM.Config != nil {
+		extraConfig = ExtraConfigToMap(moVM.Config.ExtraConfig)
+	}
+	if err := VerifyBackupOfVM(vmCtx.VM, extraConfig); err != nil {
+		return nil, errors.Wrapf(err, "restored VM with UUID %s cannot be adopted", uuid)
+	}
+
+	vmCtx.Logger.Info("Adopting restored VM", "uuid", uuid, "instanceUUID", instanceUUID, "moRef", vm.Reference())
+	return resVM, nil
+}
+
+// VerifyBackupOfVM returns an error if the ExtraConfig of a vSphere VM does not have the backup of
+// the VM, that is a backup with the same namespace and name.
+func VerifyBackupOfVM(vm *vmopv1alpha1.VirtualMachine, extraConfig map[string]string) error {
+	encoded := extraConfig[constants.BackupVMKubeDataExtraConfigKey]
+	if encoded == "" {
+		return errors.New("VM does not have a backup")
+	}
+
+	vmYAML, err := DecodeGzipBase64(encoded)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode VM backup")
+	}
+
+	backupVM := &vmopv1alpha1.VirtualMachine{}
+	if err := yaml.Unmarshal([]byte(vmYAML), backupVM); err != nil {
+		return errors.Wrap(err, "failed to unmarshal VM backup")
+	}
+
+	if backupVM.Namespace != vm.Namespace || backupVM.Name != vm.Name {
+		return errors.Errorf("VM is a backup of VirtualMachine %s/%s", backupVM.Namespace, backupVM.Name)
+	}
+
+	return nil
+}
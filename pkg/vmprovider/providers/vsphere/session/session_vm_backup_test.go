@@ -0,0 +1,204 @@
+// +build !integration
+
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/session"
+)
+
+func decodeGzipBase64(s string) string {
+	out, err := session.DecodeGzipBase64(s)
+	Expect(err).ToNot(HaveOccurred())
+	return out
+}
+
+var _ = Describe("VirtualMachine Backup", func() {
+	Context("GetBackupExtraConfig", func() {
+		var (
+			vm         *vmopv1alpha1.VirtualMachine
+			backupArgs vmprovider.VMBackupArgs
+		)
+
+		BeforeEach(func() {
+			vm = &vmopv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "dummy-vm",
+					Namespace:       "dummy-ns",
+					ResourceVersion: "42",
+				},
+				Spec: vmopv1alpha1.VirtualMachineSpec{
+					ClassName: "dummy-class",
+					ImageName: "dummy-image",
+				},
+				Status: vmopv1alpha1.VirtualMachineStatus{
+					Phase: vmopv1alpha1.Created,
+				},
+			}
+			backupArgs = vmprovider.VMBackupArgs{}
+		})
+
+		It("returns the VM YAML without the status", func() {
+			extraConfig, err := session.GetBackupExtraConfig(vm, backupArgs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(extraConfig).To(HaveKeyWithValue(constants.BackupVMAdditionalResourcesExtraConfigKey, ""))
+			Expect(extraConfig).To(HaveKeyWithValue(constants.BackupVMPVCDiskDataExtraConfigKey, ""))
+
+			backupVM := &vmopv1alpha1.VirtualMachine{}
+			Expect(yaml.Unmarshal([]byte(decodeGzipBase64(extraConfig[constants.BackupVMKubeDataExtraConfigKey])), backupVM)).To(Succeed())
+			Expect(backupVM.Kind).To(Equal("VirtualMachine"))
+			Expect(backupVM.Name).To(Equal(vm.Name))
+			Expect(backupVM.ResourceVersion).To(BeEmpty())
+			Expect(backupVM.Spec).To(Equal(vm.Spec))
+			Expect(backupVM.Status).To(Equal(vmopv1alpha1.VirtualMachineStatus{}))
+
+			By("does not change when only the status changes", func() {
+				vm.Status.Phase = vmopv1alpha1.Deleting
+				vm.ResourceVersion = "43"
+				newExtraConfig, err := session.GetBackupExtraConfig(vm, backupArgs)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(newExtraConfig).To(Equal(extraConfig))
+			})
+
+			By("does not change when only the annotations managed by VM Operator change", func() {
+				vm.Annotations = map[string]string{
+					vmopapi.CustomizationAttemptsAnnotation:       "2",
+					vmopapi.VirtualMachineStatusDetailsAnnotation: "{}",
+				}
+				newExtraConfig, err := session.GetBackupExtraConfig(vm, backupArgs)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(newExtraConfig).To(Equal(extraConfig))
+			})
+		})
+
+		It("returns the metadata Secret without its data", func() {
+			backupArgs.VMMetadata = &corev1.Secret{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Secret",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "dummy-metadata",
+					Namespace: vm.Namespace,
+				},
+				Data:       map[string][]byte{"foo": []byte("bar")},
+				StringData: map[string]string{"foo": "bar"},
+			}
+
+			extraConfig, err := session.GetBackupExtraConfig(vm, backupArgs)
+			Expect(err).ToNot(HaveOccurred())
+
+			data := decodeGzipBase64(extraConfig[constants.BackupVMAdditionalResourcesExtraConfigKey])
+			Expect(data).ToNot(ContainSubstring("bar"))
+			secret := &corev1.Secret{}
+			Expect(yaml.Unmarshal([]byte(data), secret)).To(Succeed())
+			Expect(secret.Kind).To(Equal("Secret"))
+			Expect(secret.Name).To(Equal("dummy-metadata"))
+			Expect(secret.Data).To(BeEmpty())
+			Expect(secret.StringData).To(BeEmpty())
+			Expect(backupArgs.VMMetadata.(*corev1.Secret).Data).To(HaveKey("foo"))
+		})
+
+		It("returns the metadata and PVC disk data", func() {
+			backupArgs.VMMetadata = &corev1.ConfigMap{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "dummy-metadata",
+					Namespace: vm.Namespace,
+				},
+				Data: map[string]string{"foo": "bar"},
+			}
+			backupArgs.DiskUUIDToPVC = map[string]corev1.PersistentVolumeClaim{
+				"disk-uuid-2": {
+					ObjectMeta: metav1.ObjectMeta{Name: "pvc-2"},
+				},
+				"disk-uuid-1": {
+					ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					},
+				},
+			}
+
+			extraConfig, err := session.GetBackupExtraConfig(vm, backupArgs)
+			Expect(err).ToNot(HaveOccurred())
+
+			cm := &corev1.ConfigMap{}
+			Expect(yaml.Unmarshal([]byte(decodeGzipBase64(extraConfig[constants.BackupVMAdditionalResourcesExtraConfigKey])), cm)).To(Succeed())
+			Expect(cm.Kind).To(Equal("ConfigMap"))
+			Expect(cm.Data).To(HaveKeyWithValue("foo", "bar"))
+
+			var pvcDiskData []vmopapi.PVCDiskData
+			Expect(json.Unmarshal([]byte(decodeGzipBase64(extraConfig[constants.BackupVMPVCDiskDataExtraConfigKey])), &pvcDiskData)).To(Succeed())
+			Expect(pvcDiskData).To(Equal([]vmopapi.PVCDiskData{
+				{
+					DiskUUID:    "disk-uuid-1",
+					PVCName:     "pvc-1",
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				},
+				{
+					DiskUUID: "disk-uuid-2",
+					PVCName:  "pvc-2",
+				},
+			}))
+		})
+	})
+
+	Context("VerifyBackupOfVM", func() {
+		var (
+			vm          *vmopv1alpha1.VirtualMachine
+			extraConfig map[string]string
+		)
+
+		BeforeEach(func() {
+			vm = &vmopv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "dummy-vm",
+					Namespace: "dummy-ns",
+				},
+			}
+
+			var err error
+			extraConfig, err = session.GetBackupExtraConfig(vm, vmprovider.VMBackupArgs{})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("succeeds for the backup of the VM", func() {
+			Expect(session.VerifyBackupOfVM(vm, extraConfig)).To(Succeed())
+		})
+
+		It("returns an error when there is no backup", func() {
+			Expect(session.VerifyBackupOfVM(vm, nil)).ToNot(Succeed())
+		})
+
+		It("returns an error for the backup of a VM in another namespace", func() {
+			vm.Namespace = "other-ns"
+			err := session.VerifyBackupOfVM(vm, extraConfig)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("dummy-ns/dummy-vm"))
+		})
+
+		It("returns an error for the backup of another VM", func() {
+			vm.Name = "other-vm"
+			Expect(session.VerifyBackupOfVM(vm, extraConfig)).ToNot(Succeed())
+		})
+	})
+})
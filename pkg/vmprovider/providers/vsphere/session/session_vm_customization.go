@@ -5,20 +5,30 @@ package session
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
+	"github.com/pkg/errors"
 	vimTypes "github.com/vmware/govmomi/vim25/types"
 	"gopkg.in/yaml.v2"
 	apiEquality "k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"github.com/vmware/govmomi/task"
 
 	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	"github.com/acharyasreej/vm-operator/pkg/context"
 	"github.com/acharyasreej/vm-operator/pkg/lib"
+	"github.com/acharyasreej/vm-operator/pkg/topology"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/internal"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/network"
@@ -36,6 +46,107 @@ func IsCustomizationPendingExtraConfig(extraConfig []vimTypes.BaseOptionValue) b
 	return false
 }
 
+// CustomizationPendingTimeout is how long a pending customization can wait for the powered on guest to run it
+// before the pending customization is considered stale.
+const CustomizationPendingTimeout = 10 * time.Minute
+
+// IsCustomizationPendingStale returns true if the pending customization of the VM will not be run by the
+// guest. The customization is stale when the guest finished a customization after it was requested, since
+// the guest clears the pending customization when it runs it, or when the guest has not started to run the
+// customization within the CustomizationPendingTimeout. Only the time the VM has been powered on counts towards
+// the timeout: it is timed from the later of the request and the last boot of the VM, and never elapses while
+// the VM is powered off.
+func IsCustomizationPendingStale(
+	vm *v1alpha1.VirtualMachine,
+	guestInfo *vimTypes.GuestInfo,
+	runtime *vimTypes.VirtualMachineRuntimeInfo,
+	now time.Time) bool {
+
+	requestedAt, err := time.Parse(time.RFC3339, vm.Annotations[vmopapi.CustomizationRequestedAtAnnotation])
+	if err != nil {
+		// Without the time of the request, we cannot tell for how long the customization has been pending.
+		return false
+	}
+
+	if guestInfo != nil && guestInfo.CustomizationInfo != nil {
+		custInfo := guestInfo.CustomizationInfo
+		switch custInfo.CustomizationStatus {
+		case string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_RUNNING):
+			return false
+		case string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_SUCCEEDED),
+			string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_FAILED):
+			if custInfo.EndTime != nil && custInfo.EndTime.After(requestedAt) {
+				return true
+			}
+		}
+	}
+
+	if runtime == nil || runtime.PowerState != vimTypes.VirtualMachinePowerStatePoweredOn {
+		// The guest cannot run the customization while the VM is not powered on.
+		return false
+	}
+
+	pendingSince := requestedAt
+	if runtime.BootTime != nil && runtime.BootTime.After(pendingSince) {
+		pendingSince = *runtime.BootTime
+	}
+
+	return now.Sub(pendingSince) > CustomizationPendingTimeout
+}
+
+// recordCustomizationAttempt records the time of the customization request and increments the number of
+// customization attempts of the VM, and returns the number of attempts.
+func recordCustomizationAttempt(vm *v1alpha1.VirtualMachine, now time.Time) int {
+	attempts, _ := strconv.Atoi(vm.Annotations[vmopapi.CustomizationAttemptsAnnotation])
+	attempts++
+
+	if vm.Annotations == nil {
+		vm.Annotations = map[string]string{}
+	}
+	vm.Annotations[vmopapi.CustomizationRequestedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	vm.Annotations[vmopapi.CustomizationAttemptsAnnotation] = strconv.Itoa(attempts)
+
+	return attempts
+}
+
+// clearStalePendingCustomization clears the pending customization of the VM when it is stale, and returns
+// true if the VM can be customized again.
+func clearStalePendingCustomization(
+	vmCtx context.VirtualMachineContext,
+	resVM *res.VirtualMachine) (bool, error) {
+
+	// The pending customization of a VM customized before the requests were recorded is timed from now.
+	if _, ok := vmCtx.VM.Annotations[vmopapi.CustomizationRequestedAtAnnotation]; !ok {
+		if vmCtx.VM.Annotations == nil {
+			vmCtx.VM.Annotations = map[string]string{}
+		}
+		vmCtx.VM.Annotations[vmopapi.CustomizationRequestedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		return false, nil
+	}
+
+	moVM, err := resVM.GetProperties(vmCtx, []string{"guest", "runtime"})
+	if err != nil {
+		return false, err
+	}
+
+	if !IsCustomizationPendingStale(vmCtx.VM, moVM.Guest, &moVM.Runtime, time.Now()) {
+		return false, nil
+	}
+
+	vmCtx.Logger.Info("Clearing stale pending customization",
+		"requestedAt", vmCtx.VM.Annotations[vmopapi.CustomizationRequestedAtAnnotation])
+	configSpec := &vimTypes.VirtualMachineConfigSpec{
+		ExtraConfig: []vimTypes.BaseOptionValue{
+			&vimTypes.OptionValue{Key: constants.GOSCPendingExtraConfigKey, Value: ""},
+		},
+	}
+	if err := resVM.Reconfigure(vmCtx, configSpec); err != nil {
+		return false, errors.Wrap(err, "failed to clear stale pending customization")
+	}
+
+	return true, nil
+}
+
 func isCustomizationPendingError(err error) bool {
 	if te, ok := err.(task.Error); ok {
 		if _, ok := te.Fault().(*vimTypes.CustomizationPending); ok {
@@ -175,6 +286,21 @@ func GetOvfEnvCustSpec(
 	return configSpec
 }
 
+// getPrepCustSpec returns the Sysprep CustomizationSpec for a Windows VM, and the LinuxPrep
+// CustomizationSpec otherwise.
+func getPrepCustSpec(
+	vmCtx context.VirtualMachineContext,
+	config *vimTypes.VirtualMachineConfigInfo,
+	updateArgs VMUpdateArgs) (*vimTypes.CustomizationSpec, error) {
+
+	if IsWindowsGuest(updateArgs.VMImage, config) {
+		vmCtx.Logger.V(4).Info("Using Sysprep customization for Windows VM")
+		return GetSysprepCustSpec(vmCtx.VM.Name, updateArgs)
+	}
+
+	return GetLinuxPrepCustSpec(vmCtx.VM.Name, updateArgs), nil
+}
+
 func customizeCloudInit(
 	vmCtx context.VirtualMachineContext,
 	resVM *res.VirtualMachine,
@@ -218,8 +344,14 @@ func (s *Session) customize(
 	config *vimTypes.VirtualMachineConfigInfo,
 	updateArgs VMUpdateArgs) error {
 
+	// An imported VM's guest is already configured, so only customize it when asked to with metadata.
+	if vmopapi.IsImportedVM(vmCtx.VM) && vmCtx.VM.Spec.VmMetadata == nil {
+		vmCtx.Logger.V(4).Info("Skipping customization of imported VM without metadata")
+		return nil
+	}
+
 	if lib.IsVMServiceV1Alpha2FSSEnabled() {
-		TemplateVMMetadata(vmCtx, updateArgs)
+		TemplateVMMetadata(vmCtx, config, updateArgs)
 	}
 
 	transport := updateArgs.VMMetadata.Transport
@@ -233,12 +365,14 @@ func (s *Session) customize(
 		configSpec, custSpec, err = customizeCloudInit(vmCtx, resVM, config, updateArgs)
 	case v1alpha1.VirtualMachineMetadataOvfEnvTransport:
 		configSpec = GetOvfEnvCustSpec(config, updateArgs)
-		custSpec = GetLinuxPrepCustSpec(vmCtx.VM.Name, updateArgs)
+		custSpec, err = getPrepCustSpec(vmCtx, config, updateArgs)
 	case v1alpha1.VirtualMachineMetadataExtraConfigTransport:
 		configSpec = GetExtraConfigCustSpec(config, updateArgs)
-		custSpec = GetLinuxPrepCustSpec(vmCtx.VM.Name, updateArgs)
+		custSpec, err = getPrepCustSpec(vmCtx, config, updateArgs)
+	case vmopapi.VirtualMachineMetadataIgnitionTransport:
+		configSpec, err = customizeIgnition(vmCtx, resVM, config, updateArgs)
 	default:
-		custSpec = GetLinuxPrepCustSpec(vmCtx.VM.Name, updateArgs)
+		custSpec, err = getPrepCustSpec(vmCtx, config, updateArgs)
 	}
 
 	if err != nil {
@@ -262,13 +396,24 @@ func (s *Session) customize(
 			return nil
 		}
 		if IsCustomizationPendingExtraConfig(config.ExtraConfig) {
-			vmCtx.Logger.Info("Skipping customization because it is already pending")
-			// TODO: We should really determine if the pending customization is stale, clear it
-			// if so, and then re-customize. Otherwise, the Customize call could perpetually fail
-			// preventing power on.
-			return nil
+			cleared, err := clearStalePendingCustomization(vmCtx, resVM)
+			if err != nil {
+				return err
+			}
+			if !cleared {
+				vmCtx.Logger.Info("Skipping customization because it is already pending")
+				return nil
+			}
+		}
+		attempt := recordCustomizationAttempt(vmCtx.VM, time.Now())
+		conditions.MarkFalse(vmCtx.VM, v1alpha1.GuestCustomizationCondition, v1alpha1.GuestCustomizationPendingReason,
+			v1alpha1.ConditionSeverityInfo, "Customization attempt %d", attempt)
+		if _, ok := custSpec.Identity.(*vimTypes.CustomizationLinuxPrep); ok {
+			vmCtx.Logger.Info("Customizing VM", "customizationSpec", *custSpec)
+		} else {
+			// Do not log the Sysprep passwords.
+			vmCtx.Logger.Info("Customizing VM", "identity", fmt.Sprintf("%T", custSpec.Identity))
 		}
-		vmCtx.Logger.Info("Customizing VM", "customizationSpec", *custSpec)
 		if err := resVM.Customize(vmCtx, *custSpec); err != nil {
 			// isCustomizationPendingExtraConfig() above is suppose to prevent this error, but
 			// handle it explicitly here just in case so VM reconciliation can proceed.
@@ -288,33 +433,120 @@ func (s *Session) customize(
 type TemplateData struct {
 	NetworkInterfaces []network.IPConfig
 	NameServers       []string
+	SearchDomains     []string
+	NTPServers        []string
+
+	Name         string
+	Namespace    string
+	Labels       map[string]string
+	Annotations  map[string]string
+	Zone         string
+	InstanceUUID string
+
+	// Hardware is the hardware of the VM's VirtualMachineClass.
+	Hardware v1alpha1.VirtualMachineClassHardware
+	// OSInfo is the guest OS of the VM's VirtualMachineImage.
+	OSInfo v1alpha1.VirtualMachineImageOSInfo
+}
+
+// templateFuncs are the functions available to the templates in the VM metadata. The functions only
+// transform their arguments, so templates cannot access anything but the TemplateData.
+var templateFuncs = template.FuncMap{
+	// base64 returns the base64 encoding of the string.
+	"base64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	// default returns the value, or the default value when the value is empty.
+	"default": func(defaultValue, value interface{}) interface{} {
+		if value == nil {
+			return defaultValue
+		}
+		if v := reflect.ValueOf(value); v.IsZero() ||
+			((v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.Len() == 0) {
+			return defaultValue
+		}
+		return value
+	},
+	// indent prefixes each line of the string with the number of spaces.
+	"indent": func(spaces int, s string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+	// toYaml returns the YAML encoding of the value, without the trailing newline.
+	"toYaml": func(v interface{}) (string, error) {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(data), "\n"), nil
+	},
 }
 
-func TemplateVMMetadata(vmCtx context.VirtualMachineContext, updateArgs VMUpdateArgs) {
+// TemplateVMMetadata renders the values of the VM metadata as templates of the TemplateData. A value that
+// fails to render, like a value with templates for the guest such as cloud-init Jinja templates, is left as
+// is, and the VM's MetadataTemplateRendered condition is set to false with why it failed to render. The VM is
+// still customized with the values that were left as is.
+func TemplateVMMetadata(
+	vmCtx context.VirtualMachineContext,
+	config *vimTypes.VirtualMachineConfigInfo,
+	updateArgs VMUpdateArgs) {
+
 	templateData := TemplateData{
 		NetworkInterfaces: updateArgs.NetIfList.GetIPConfigs(),
 		NameServers:       updateArgs.DNSServers,
+		SearchDomains:     updateArgs.SearchDomains,
+		NTPServers:        updateArgs.NTPServers,
+		Name:              vmCtx.VM.Name,
+		Namespace:         vmCtx.VM.Namespace,
+		Labels:            vmCtx.VM.Labels,
+		Annotations:       vmCtx.VM.Annotations,
+		Zone:              vmCtx.VM.Labels[topology.KubernetesTopologyZoneLabelKey],
+		Hardware:          updateArgs.VMClass.Spec.Hardware,
+	}
+	if config != nil {
+		templateData.InstanceUUID = config.InstanceUuid
+	}
+	if updateArgs.VMImage != nil {
+		templateData.OSInfo = updateArgs.VMImage.Spec.OSInfo
 	}
 
-	renderTemplate := func(name, templateStr string) string {
-		templ, err := template.New(name).Parse(templateStr)
+	renderTemplate := func(name, templateStr string) (string, error) {
+		templ, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(templateStr)
 		if err != nil {
-			vmCtx.Logger.Error(err, "failed to parse template", "templateStr", templateStr)
-			// TODO: emit related events
-			return templateStr
+			return "", errors.Wrapf(err, "failed to parse template of key %s", name)
 		}
 		var doc bytes.Buffer
-		err = templ.Execute(&doc, &templateData)
-		if err != nil {
-			vmCtx.Logger.Error(err, "failed to execute template", "templateStr", templateStr)
-			// TODO: emit related events
-			return templateStr
+		if err := templ.Execute(&doc, &templateData); err != nil {
+			return "", errors.Wrapf(err, "failed to execute template of key %s", name)
 		}
-		return doc.String()
+		return doc.String(), nil
 	}
 
 	data := updateArgs.VMMetadata.Data
-	for key, val := range data {
-		data[key] = renderTemplate(key, val)
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		val, err := renderTemplate(key, data[key])
+		if err != nil {
+			vmCtx.Logger.Info("Using value of key as is since it failed to render", "key", key, "reason", err.Error())
+			errs = append(errs, err)
+			continue
+		}
+		data[key] = val
+	}
+
+	if len(errs) > 0 {
+		conditions.MarkFalse(vmCtx.VM, vmopapi.VirtualMachineMetadataTemplateRenderedCondition,
+			vmopapi.VirtualMachineMetadataTemplateRenderFailedReason, v1alpha1.ConditionSeverityWarning,
+			"%v", k8serrors.NewAggregate(errs))
+		return
 	}
+
+	conditions.MarkTrue(vmCtx.VM, vmopapi.VirtualMachineMetadataTemplateRenderedCondition)
 }
@@ -7,7 +7,9 @@ package session_test
 
 import (
 	goctx "context"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -19,7 +21,10 @@ import (
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/topology"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/internal"
@@ -59,6 +64,135 @@ var _ = Describe("Customization utils", func() {
 			})
 		})
 	})
+
+	Context("IsCustomizationPendingStale", func() {
+		var (
+			vm          *vmopv1alpha1.VirtualMachine
+			guestInfo   *vimTypes.GuestInfo
+			runtime     *vimTypes.VirtualMachineRuntimeInfo
+			requestedAt time.Time
+			now         time.Time
+			stale       bool
+		)
+
+		BeforeEach(func() {
+			requestedAt = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+			now = requestedAt.Add(time.Minute)
+			vm = &vmopv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						vmopapi.CustomizationRequestedAtAnnotation: requestedAt.Format(time.RFC3339),
+					},
+				},
+			}
+			guestInfo = &vimTypes.GuestInfo{
+				CustomizationInfo: &vimTypes.GuestInfoCustomizationInfo{
+					CustomizationStatus: string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_PENDING),
+				},
+			}
+			bootTime := requestedAt.Add(-time.Hour)
+			runtime = &vimTypes.VirtualMachineRuntimeInfo{
+				PowerState: vimTypes.VirtualMachinePowerStatePoweredOn,
+				BootTime:   &bootTime,
+			}
+		})
+
+		JustBeforeEach(func() {
+			stale = session.IsCustomizationPendingStale(vm, guestInfo, runtime, now)
+		})
+
+		Context("Within the timeout", func() {
+			It("is not stale", func() {
+				Expect(stale).To(BeFalse())
+			})
+		})
+
+		Context("After the timeout", func() {
+			BeforeEach(func() {
+				now = requestedAt.Add(session.CustomizationPendingTimeout + time.Minute)
+			})
+
+			It("is stale", func() {
+				Expect(stale).To(BeTrue())
+			})
+
+			Context("When the guest is running the customization", func() {
+				BeforeEach(func() {
+					guestInfo.CustomizationInfo.CustomizationStatus = string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_RUNNING)
+				})
+
+				It("is not stale", func() {
+					Expect(stale).To(BeFalse())
+				})
+			})
+
+			Context("When the VM is powered off", func() {
+				BeforeEach(func() {
+					runtime.PowerState = vimTypes.VirtualMachinePowerStatePoweredOff
+					runtime.BootTime = nil
+				})
+
+				It("is not stale", func() {
+					Expect(stale).To(BeFalse())
+				})
+			})
+
+			Context("When the VM was powered on after the request", func() {
+				BeforeEach(func() {
+					bootTime := now.Add(-time.Minute)
+					runtime.BootTime = &bootTime
+				})
+
+				It("is not stale", func() {
+					Expect(stale).To(BeFalse())
+				})
+
+				Context("After the timeout from the power on", func() {
+					BeforeEach(func() {
+						now = runtime.BootTime.Add(session.CustomizationPendingTimeout + time.Minute)
+					})
+
+					It("is stale", func() {
+						Expect(stale).To(BeTrue())
+					})
+				})
+			})
+
+			Context("Without the requested time", func() {
+				BeforeEach(func() {
+					vm.Annotations = nil
+				})
+
+				It("is not stale", func() {
+					Expect(stale).To(BeFalse())
+				})
+			})
+		})
+
+		Context("When the guest finished a customization after it was requested", func() {
+			BeforeEach(func() {
+				endTime := requestedAt.Add(30 * time.Second)
+				guestInfo.CustomizationInfo.CustomizationStatus = string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_FAILED)
+				guestInfo.CustomizationInfo.EndTime = &endTime
+			})
+
+			It("is stale", func() {
+				Expect(stale).To(BeTrue())
+			})
+		})
+
+		Context("When the guest finished a customization before it was requested", func() {
+			BeforeEach(func() {
+				endTime := requestedAt.Add(-time.Hour)
+				guestInfo.CustomizationInfo.CustomizationStatus = string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_SUCCEEDED)
+				guestInfo.CustomizationInfo.EndTime = &endTime
+			})
+
+			It("is not stale", func() {
+				Expect(stale).To(BeFalse())
+			})
+		})
+	})
 })
 
 var _ = Describe("Customization via ConfigSpec", func() {
@@ -351,6 +485,9 @@ var _ = Describe("TemplateVMMetadata", func() {
 	Context("update VmConfigArgs", func() {
 		var (
 			updateArgs session.VMUpdateArgs
+			config     *vimTypes.VirtualMachineConfigInfo
+			vm         *vmopv1alpha1.VirtualMachine
+			vmCtx      context.VirtualMachineContext
 
 			ip         = "192.168.1.37"
 			subnetMask = "255.255.255.0"
@@ -358,20 +495,31 @@ var _ = Describe("TemplateVMMetadata", func() {
 			nameserver = "8.8.8.8"
 		)
 
-		vm := &vmopv1alpha1.VirtualMachine{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "dummy-vm",
-				Namespace: "dummy-ns",
-			},
-		}
-		vmCtx := context.VirtualMachineContext{
-			Context: goctx.Background(),
-			Logger:  logf.Log.WithValues("vmName", vm.NamespacedName()),
-			VM:      vm,
-		}
-
 		BeforeEach(func() {
+			vm = &vmopv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "dummy-vm",
+					Namespace: "dummy-ns",
+					Labels: map[string]string{
+						topology.KubernetesTopologyZoneLabelKey: "zone-a",
+						"app":                                   "web",
+					},
+				},
+			}
+			vmCtx = context.VirtualMachineContext{
+				Context: goctx.Background(),
+				Logger:  logf.Log.WithValues("vmName", vm.NamespacedName()),
+				VM:      vm,
+			}
+
+			config = &vimTypes.VirtualMachineConfigInfo{
+				InstanceUuid: "dummy-instance-uuid",
+			}
+
+			updateArgs = session.VMUpdateArgs{}
 			updateArgs.DNSServers = []string{nameserver}
+			updateArgs.SearchDomains = []string{"example.com"}
+			updateArgs.NTPServers = []string{"ntp.example.com"}
 			updateArgs.NetIfList = []network.InterfaceInfo{
 				{
 					IPConfiguration: network.IPConfig{
@@ -381,6 +529,9 @@ var _ = Describe("TemplateVMMetadata", func() {
 					},
 				},
 			}
+			updateArgs.VMClass.Spec.Hardware.Cpus = 4
+			updateArgs.VMImage = &vmopv1alpha1.VirtualMachineImage{}
+			updateArgs.VMImage.Spec.OSInfo.Type = "ubuntu64Guest"
 			updateArgs.VMMetadata = vmprovider.VMMetadata{
 				Data: make(map[string]string),
 			}
@@ -392,26 +543,76 @@ var _ = Describe("TemplateVMMetadata", func() {
 			updateArgs.VMMetadata.Data["gateway"] = "{{ (index .NetworkInterfaces 0).Gateway }}"
 			updateArgs.VMMetadata.Data["nameserver"] = "{{ (index .NameServers 0) }}"
 
-			session.TemplateVMMetadata(vmCtx, updateArgs)
+			session.TemplateVMMetadata(vmCtx, config, updateArgs)
 
 			Expect(updateArgs.VMMetadata.Data["ip"]).To(Equal(ip))
 			Expect(updateArgs.VMMetadata.Data["subMask"]).To(Equal(subnetMask))
 			Expect(updateArgs.VMMetadata.Data["gateway"]).To(Equal(gateway))
 			Expect(updateArgs.VMMetadata.Data["nameserver"]).To(Equal(nameserver))
+			Expect(conditions.IsTrue(vm, vmopapi.VirtualMachineMetadataTemplateRenderedCondition)).To(BeTrue())
 		})
 
-		It("should use the original text if resolving template failed", func() {
+		It("should resolve the VM, class, image and network settings", func() {
+			updateArgs.VMMetadata.Data["name"] = "{{ .Namespace }}/{{ .Name }}"
+			updateArgs.VMMetadata.Data["labels"] = "{{ .Labels.app }}"
+			updateArgs.VMMetadata.Data["zone"] = "{{ .Zone }}"
+			updateArgs.VMMetadata.Data["uuid"] = "{{ .InstanceUUID }}"
+			updateArgs.VMMetadata.Data["cpus"] = "{{ .Hardware.Cpus }}"
+			updateArgs.VMMetadata.Data["os"] = "{{ .OSInfo.Type }}"
+			updateArgs.VMMetadata.Data["search"] = "{{ index .SearchDomains 0 }}"
+			updateArgs.VMMetadata.Data["ntp"] = "{{ index .NTPServers 0 }}"
+
+			session.TemplateVMMetadata(vmCtx, config, updateArgs)
+
+			Expect(updateArgs.VMMetadata.Data["name"]).To(Equal("dummy-ns/dummy-vm"))
+			Expect(updateArgs.VMMetadata.Data["labels"]).To(Equal("web"))
+			Expect(updateArgs.VMMetadata.Data["zone"]).To(Equal("zone-a"))
+			Expect(updateArgs.VMMetadata.Data["uuid"]).To(Equal("dummy-instance-uuid"))
+			Expect(updateArgs.VMMetadata.Data["cpus"]).To(Equal("4"))
+			Expect(updateArgs.VMMetadata.Data["os"]).To(Equal("ubuntu64Guest"))
+			Expect(updateArgs.VMMetadata.Data["search"]).To(Equal("example.com"))
+			Expect(updateArgs.VMMetadata.Data["ntp"]).To(Equal("ntp.example.com"))
+		})
+
+		It("should provide the template functions", func() {
+			updateArgs.VMMetadata.Data["base64"] = "{{ base64 .Name }}"
+			updateArgs.VMMetadata.Data["default"] = "{{ default \"none\" .Annotations }}"
+			updateArgs.VMMetadata.Data["indent"] = "{{ indent 2 \"a\\nb\" }}"
+			updateArgs.VMMetadata.Data["toYaml"] = "{{ toYaml .NTPServers }}"
+
+			session.TemplateVMMetadata(vmCtx, config, updateArgs)
+
+			Expect(updateArgs.VMMetadata.Data["base64"]).To(Equal("ZHVtbXktdm0="))
+			Expect(updateArgs.VMMetadata.Data["default"]).To(Equal("none"))
+			Expect(updateArgs.VMMetadata.Data["indent"]).To(Equal("  a\n  b"))
+			Expect(updateArgs.VMMetadata.Data["toYaml"]).To(Equal("- ntp.example.com"))
+		})
+
+		It("should use the original text and set the condition if resolving template failed", func() {
 			updateArgs.VMMetadata.Data["ip"] = "{{ (index .NetworkInterfaces 100).IP }}"
 			updateArgs.VMMetadata.Data["subMask"] = "{{ invalidTemplate }}"
 			updateArgs.VMMetadata.Data["gateway"] = "{{ (index .NetworkInterfaces ).Gateway }}"
+			updateArgs.VMMetadata.Data["missing"] = "{{ .Labels.missing }}"
 			updateArgs.VMMetadata.Data["nameserver"] = "{{ (index .NameServers 0) }}"
 
-			session.TemplateVMMetadata(vmCtx, updateArgs)
+			updateArgs.VMMetadata.Data["user-data"] = "## template: jinja\n#cloud-config\nhostname: {{ ds.meta_data.hostname }}"
+
+			session.TemplateVMMetadata(vmCtx, config, updateArgs)
 
 			Expect(updateArgs.VMMetadata.Data["ip"]).To(Equal("{{ (index .NetworkInterfaces 100).IP }}"))
 			Expect(updateArgs.VMMetadata.Data["subMask"]).To(Equal("{{ invalidTemplate }}"))
 			Expect(updateArgs.VMMetadata.Data["gateway"]).To(Equal("{{ (index .NetworkInterfaces ).Gateway }}"))
+			Expect(updateArgs.VMMetadata.Data["missing"]).To(Equal("{{ .Labels.missing }}"))
 			Expect(updateArgs.VMMetadata.Data["nameserver"]).To(Equal(nameserver))
+			Expect(updateArgs.VMMetadata.Data["user-data"]).To(Equal("## template: jinja\n#cloud-config\nhostname: {{ ds.meta_data.hostname }}"))
+
+			c := conditions.Get(vm, vmopapi.VirtualMachineMetadataTemplateRenderedCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Status).To(Equal(corev1.ConditionFalse))
+			Expect(c.Reason).To(Equal(vmopapi.VirtualMachineMetadataTemplateRenderFailedReason))
+			Expect(c.Severity).To(Equal(vmopv1alpha1.ConditionSeverityWarning))
+			Expect(c.Message).To(ContainSubstring("subMask"))
+			Expect(c.Message).To(ContainSubstring("user-data"))
 		})
 	})
 })
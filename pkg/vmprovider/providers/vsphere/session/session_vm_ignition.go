@@ -0,0 +1,251 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/ignition"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/network"
+	res "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
+)
+
+const (
+	ignitionFileModeDefault = 0644
+	ignitionFileModePrivate = 0600
+)
+
+// IgnitionConfigInvalidError is returned when the Ignition config of the VM metadata is not valid.
+type IgnitionConfigInvalidError struct {
+	Err error
+}
+
+func (e IgnitionConfigInvalidError) Error() string {
+	return fmt.Sprintf("invalid Ignition config: %v", e.Err)
+}
+
+// IgnitionConfigTooLargeError is returned when the encoded Ignition config is larger than a guestinfo value
+// can be.
+type IgnitionConfigTooLargeError struct {
+	Size int
+}
+
+func (e IgnitionConfigTooLargeError) Error() string {
+	return fmt.Sprintf("encoded Ignition config is %d bytes, which exceeds the maximum of %d bytes",
+		e.Size, constants.IgnitionGuestInfoConfigDataMaxSize)
+}
+
+// GetIgnitionConfig returns the Ignition config of the VM metadata merged with the hostname and network
+// configuration of the VM. The network configuration is written both as systemd-networkd units for Flatcar,
+// and as NetworkManager keyfiles for Fedora CoreOS. Files that are already in the Ignition config of the
+// VM metadata take precedence over the generated ones.
+func GetIgnitionConfig(vmName, ignitionConfig string, netplan network.Netplan) (string, error) {
+	if ignitionConfig == "" {
+		ignitionConfig = fmt.Sprintf(`{"ignition":{"version":%q}}`, ignition.DefaultVersion)
+	}
+
+	if err := ignition.Validate(ignitionConfig); err != nil {
+		return "", IgnitionConfigInvalidError{Err: err}
+	}
+
+	// The config is valid, so the sections that are merged have the expected types.
+	config := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(ignitionConfig), &config); err != nil {
+		return "", IgnitionConfigInvalidError{Err: err}
+	}
+
+	storage, _ := config["storage"].(map[string]interface{})
+	if storage == nil {
+		storage = map[string]interface{}{}
+	}
+	files, _ := storage["files"].([]interface{})
+
+	paths := map[string]struct{}{}
+	for _, f := range files {
+		if file, ok := f.(map[string]interface{}); ok {
+			if path, ok := file["path"].(string); ok {
+				paths[path] = struct{}{}
+			}
+		}
+	}
+
+	for _, file := range getIgnitionFiles(vmName, netplan) {
+		if _, exists := paths[file["path"].(string)]; !exists {
+			files = append(files, file)
+		}
+	}
+
+	storage["files"] = files
+	config["storage"] = storage
+
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return "", errors.Wrap(err, "json marshalling of Ignition config failed")
+	}
+
+	return string(configBytes), nil
+}
+
+// getIgnitionFiles returns the Ignition files with the hostname and network configuration of the VM.
+func getIgnitionFiles(vmName string, netplan network.Netplan) []map[string]interface{} {
+	files := []map[string]interface{}{
+		ignitionFile("/etc/hostname", ignitionFileModeDefault, vmName+"\n"),
+	}
+
+	names := make([]string, 0, len(netplan.Ethernets))
+	for name := range netplan.Ethernets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		eth := netplan.Ethernets[name]
+		files = append(files,
+			ignitionFile(fmt.Sprintf("/etc/systemd/network/%02d-%s.network", 10+i, name),
+				ignitionFileModeDefault, getNetworkdUnit(eth)),
+			ignitionFile(fmt.Sprintf("/etc/NetworkManager/system-connections/%s.nmconnection", name),
+				ignitionFileModePrivate, getNetworkManagerKeyfile(name, eth)))
+	}
+
+	return files
+}
+
+func ignitionFile(path string, mode int, contents string) map[string]interface{} {
+	return map[string]interface{}{
+		"path":      path,
+		"mode":      mode,
+		"overwrite": true,
+		"contents": map[string]interface{}{
+			"source": "data:;base64," + base64.StdEncoding.EncodeToString([]byte(contents)),
+		},
+	}
+}
+
+func getNetworkdUnit(eth network.NetplanEthernet) string {
+	var sb strings.Builder
+
+	sb.WriteString("[Match]\n")
+	if eth.Match.MacAddress != "" {
+		fmt.Fprintf(&sb, "MACAddress=%s\n", strings.ToLower(eth.Match.MacAddress))
+	}
+
+	sb.WriteString("\n[Network]\n")
+	if eth.Dhcp4 {
+		sb.WriteString("DHCP=ipv4\n")
+	}
+	for _, addr := range eth.Addresses {
+		fmt.Fprintf(&sb, "Address=%s\n", addr)
+	}
+	if eth.Gateway4 != "" {
+		fmt.Fprintf(&sb, "Gateway=%s\n", eth.Gateway4)
+	}
+	for _, ns := range eth.Nameservers.Addresses {
+		fmt.Fprintf(&sb, "DNS=%s\n", ns)
+	}
+
+	return sb.String()
+}
+
+func getNetworkManagerKeyfile(name string, eth network.NetplanEthernet) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "[connection]\nid=%s\ntype=ethernet\n", name)
+
+	sb.WriteString("\n[ethernet]\n")
+	if eth.Match.MacAddress != "" {
+		fmt.Fprintf(&sb, "mac-address=%s\n", eth.Match.MacAddress)
+	}
+
+	sb.WriteString("\n[ipv4]\n")
+	if eth.Dhcp4 {
+		sb.WriteString("method=auto\n")
+	} else {
+		sb.WriteString("method=manual\n")
+	}
+	for i, addr := range eth.Addresses {
+		if i == 0 && eth.Gateway4 != "" {
+			fmt.Fprintf(&sb, "address%d=%s,%s\n", i+1, addr, eth.Gateway4)
+		} else {
+			fmt.Fprintf(&sb, "address%d=%s\n", i+1, addr)
+		}
+	}
+	if len(eth.Nameservers.Addresses) > 0 {
+		fmt.Fprintf(&sb, "dns=%s;\n", strings.Join(eth.Nameservers.Addresses, ";"))
+	}
+
+	return sb.String()
+}
+
+// GetIgnitionGuestInfoCustSpec returns the ConfigSpec that sets the encoded Ignition config in the
+// guestinfo.ignition.config.data ExtraConfig key.
+func GetIgnitionGuestInfoCustSpec(
+	ignitionConfig string,
+	config *vimTypes.VirtualMachineConfigInfo) (*vimTypes.VirtualMachineConfigSpec, error) {
+
+	encodedConfig, err := EncodeGzipBase64(ignitionConfig)
+	if err != nil {
+		return nil, fmt.Errorf("encoding Ignition config failed %v", err)
+	}
+
+	if len(encodedConfig) > constants.IgnitionGuestInfoConfigDataMaxSize {
+		return nil, IgnitionConfigTooLargeError{Size: len(encodedConfig)}
+	}
+
+	extraConfig := map[string]string{
+		constants.IgnitionGuestInfoConfigData:         encodedConfig,
+		constants.IgnitionGuestInfoConfigDataEncoding: "gzip+base64",
+	}
+
+	configSpec := &vimTypes.VirtualMachineConfigSpec{}
+	configSpec.ExtraConfig = MergeExtraConfig(config.ExtraConfig, extraConfig)
+	return configSpec, nil
+}
+
+func customizeIgnition(
+	vmCtx context.VirtualMachineContext,
+	resVM *res.VirtualMachine,
+	config *vimTypes.VirtualMachineConfigInfo,
+	updateArgs VMUpdateArgs) (*vimTypes.VirtualMachineConfigSpec, error) {
+
+	ethCards, err := resVM.GetNetworkDevices(vmCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	netplan := updateArgs.NetIfList.GetNetplan(ethCards, updateArgs.DNSServers)
+
+	configSpec, err := func() (*vimTypes.VirtualMachineConfigSpec, error) {
+		ignitionConfig, err := GetIgnitionConfig(vmCtx.VM.Name, updateArgs.VMMetadata.Data[vmopapi.IgnitionConfigKey], netplan)
+		if err != nil {
+			return nil, err
+		}
+		return GetIgnitionGuestInfoCustSpec(ignitionConfig, config)
+	}()
+
+	switch err.(type) {
+	case nil:
+		conditions.MarkTrue(vmCtx.VM, vmopapi.VirtualMachineIgnitionConfigReadyCondition)
+	case IgnitionConfigInvalidError:
+		conditions.MarkFalse(vmCtx.VM, vmopapi.VirtualMachineIgnitionConfigReadyCondition,
+			vmopapi.VirtualMachineIgnitionConfigInvalidReason, v1alpha1.ConditionSeverityError, "%v", err)
+	case IgnitionConfigTooLargeError:
+		conditions.MarkFalse(vmCtx.VM, vmopapi.VirtualMachineIgnitionConfigReadyCondition,
+			vmopapi.VirtualMachineIgnitionConfigTooLargeReason, v1alpha1.ConditionSeverityError, "%v", err)
+	}
+
+	return configSpec, err
+}
@@ -0,0 +1,161 @@
+// +build !integration
+
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"encoding/json"
+	"math/rand"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/network"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/session"
+)
+
+var _ = Describe("Ignition", func() {
+
+	Context("GetIgnitionConfig", func() {
+		var (
+			ignitionConfig string
+			netplan        network.Netplan
+			mergedConfig   string
+			err            error
+		)
+
+		BeforeEach(func() {
+			ignitionConfig = ""
+			netplan = network.Netplan{
+				Version: constants.NetPlanVersion,
+				Ethernets: map[string]network.NetplanEthernet{
+					"nic0": {
+						Match:       network.NetplanEthernetMatch{MacAddress: "00:50:56:AB:CD:EF"},
+						Addresses:   []string{"192.168.1.10/24"},
+						Gateway4:    "192.168.1.1",
+						Nameservers: network.NetplanEthernetNameserver{Addresses: []string{"8.8.8.8"}},
+					},
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			mergedConfig, err = session.GetIgnitionConfig("dummy-vm", ignitionConfig, netplan)
+		})
+
+		filePaths := func() []string {
+			config := struct {
+				Ignition struct {
+					Version string `json:"version"`
+				} `json:"ignition"`
+				Storage struct {
+					Files []struct {
+						Path string `json:"path"`
+					} `json:"files"`
+				} `json:"storage"`
+			}{}
+			Expect(json.Unmarshal([]byte(mergedConfig), &config)).To(Succeed())
+			Expect(config.Ignition.Version).ToNot(BeEmpty())
+
+			var paths []string
+			for _, f := range config.Storage.Files {
+				paths = append(paths, f.Path)
+			}
+			return paths
+		}
+
+		Context("Without an Ignition config", func() {
+			It("returns a config with the hostname and network files", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(filePaths()).To(ConsistOf(
+					"/etc/hostname",
+					"/etc/systemd/network/10-nic0.network",
+					"/etc/NetworkManager/system-connections/nic0.nmconnection"))
+			})
+		})
+
+		Context("With an Ignition config", func() {
+			BeforeEach(func() {
+				ignitionConfig = `{
+  "ignition": {"version": "3.2.0"},
+  "passwd": {"users": [{"name": "core", "sshAuthorizedKeys": ["ssh-rsa AAAA"]}]},
+  "storage": {"files": [{"path": "/etc/hostname", "contents": {"source": "data:,my-host"}}]}
+}`
+			})
+
+			It("merges the network files and keeps the files of the config", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(mergedConfig).To(ContainSubstring("data:,my-host"))
+				Expect(mergedConfig).To(ContainSubstring("sshAuthorizedKeys"))
+				Expect(filePaths()).To(ConsistOf(
+					"/etc/hostname",
+					"/etc/systemd/network/10-nic0.network",
+					"/etc/NetworkManager/system-connections/nic0.nmconnection"))
+			})
+		})
+
+		Context("With invalid JSON", func() {
+			BeforeEach(func() {
+				ignitionConfig = `{"ignition": `
+			})
+
+			It("returns an invalid config error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(BeAssignableToTypeOf(session.IgnitionConfigInvalidError{}))
+			})
+		})
+
+		Context("Without an Ignition version", func() {
+			BeforeEach(func() {
+				ignitionConfig = `{"storage": {}}`
+			})
+
+			It("returns an invalid config error", func() {
+				Expect(err).To(BeAssignableToTypeOf(session.IgnitionConfigInvalidError{}))
+			})
+		})
+
+		Context("With an unsupported Ignition version", func() {
+			BeforeEach(func() {
+				ignitionConfig = `{"ignition": {"version": "2.3.0"}}`
+			})
+
+			It("returns an invalid config error", func() {
+				Expect(err).To(BeAssignableToTypeOf(session.IgnitionConfigInvalidError{}))
+			})
+		})
+	})
+
+	Context("GetIgnitionGuestInfoCustSpec", func() {
+		var (
+			config *vimTypes.VirtualMachineConfigInfo
+		)
+
+		BeforeEach(func() {
+			config = &vimTypes.VirtualMachineConfigInfo{}
+		})
+
+		It("sets the encoded Ignition config in ExtraConfig", func() {
+			configSpec, err := session.GetIgnitionGuestInfoCustSpec(`{"ignition":{"version":"3.0.0"}}`, config)
+			Expect(err).ToNot(HaveOccurred())
+
+			extraConfig := session.ExtraConfigToMap(configSpec.ExtraConfig)
+			Expect(extraConfig).To(HaveKey(constants.IgnitionGuestInfoConfigData))
+			Expect(extraConfig).To(HaveKeyWithValue(constants.IgnitionGuestInfoConfigDataEncoding, "gzip+base64"))
+		})
+
+		It("returns a too large error when the encoded config exceeds the maximum size", func() {
+			// Random data does not compress.
+			data := make([]byte, constants.IgnitionGuestInfoConfigDataMaxSize)
+			_, _ = rand.New(rand.NewSource(0)).Read(data)
+
+			_, err := session.GetIgnitionGuestInfoCustSpec(string(data), config)
+			Expect(err).To(BeAssignableToTypeOf(session.IgnitionConfigTooLargeError{}))
+		})
+	})
+})
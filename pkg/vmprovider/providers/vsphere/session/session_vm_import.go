@@ -0,0 +1,145 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+	"k8s.io/apimachinery/pkg/api/resource"
+	ctrlruntime "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+	res "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
+)
+
+// ImportVirtualMachine moves the existing vSphere VM that the VM's import annotations point to into
+// the VM's namespace: the vSphere VM is relocated into the namespace's ResourcePool, moved into the
+// namespace's Folder, and renamed to the name of the VM. Each step is skipped when it was already
+// done, so this can be called again after the VM was imported. The hardware of the vSphere VM is
+// returned so a matching VirtualMachineClass can be found.
+func (s *Session) ImportVirtualMachine(vmCtx context.VirtualMachineContext) (vmprovider.VMHardware, error) {
+	resVM, err := s.lookupImportedVM(vmCtx)
+	if err != nil {
+		return vmprovider.VMHardware{}, err
+	}
+
+	moVM, err := resVM.GetProperties(vmCtx, []string{"name", "parent", "resourcePool", "config.template", "config.hardware"})
+	if err != nil {
+		return vmprovider.VMHardware{}, err
+	}
+
+	if moVM.Config == nil {
+		return vmprovider.VMHardware{}, errors.Errorf("imported VM %s does not have a config", resVM.ReferenceValue())
+	}
+	if moVM.Config.Template {
+		return vmprovider.VMHardware{}, errors.Errorf("imported VM %s is a template", resVM.ReferenceValue())
+	}
+
+	resourcePolicy, err := s.getVMResourcePolicy(vmCtx)
+	if err != nil {
+		return vmprovider.VMHardware{}, err
+	}
+
+	resourcePool, folder, err := s.getResourcePoolAndFolder(vmCtx, resourcePolicy)
+	if err != nil {
+		return vmprovider.VMHardware{}, err
+	}
+
+	if poolRef := resourcePool.Reference(); moVM.ResourcePool == nil || moVM.ResourcePool.Value != poolRef.Value {
+		vmCtx.Logger.Info("Relocating imported VM into ResourcePool", "resourcePool", poolRef.Value)
+		if err := resVM.Relocate(vmCtx, vimTypes.VirtualMachineRelocateSpec{Pool: &poolRef}); err != nil {
+			return vmprovider.VMHardware{}, err
+		}
+	}
+
+	if folderRef := folder.Reference(); moVM.Parent == nil || moVM.Parent.Value != folderRef.Value {
+		vmCtx.Logger.Info("Moving imported VM into Folder", "folder", folderRef.Value)
+		if err := resVM.MoveInto(vmCtx, folder); err != nil {
+			return vmprovider.VMHardware{}, err
+		}
+	}
+
+	if moVM.Name != vmCtx.VM.Name {
+		vmCtx.Logger.Info("Renaming imported VM", "oldName", moVM.Name)
+		if err := resVM.Rename(vmCtx, vmCtx.VM.Name); err != nil {
+			return vmprovider.VMHardware{}, err
+		}
+	}
+
+	// Set the UniqueID so that later lookups find the VM by its MoID.
+	vmCtx.VM.Status.UniqueID = resVM.ReferenceValue()
+
+	return vmprovider.VMHardware{
+		NumCPUs: int64(moVM.Config.Hardware.NumCPU),
+		Memory:  *resource.NewQuantity(int64(moVM.Config.Hardware.MemoryMB)*1024*1024, resource.BinarySI),
+	}, nil
+}
+
+// lookupImportedVM returns the vSphere VM that the VM's import annotations point to. When both annotations
+// are set, they must point to the same vSphere VM.
+func (s *Session) lookupImportedVM(vmCtx context.VirtualMachineContext) (*res.VirtualMachine, error) {
+	uuid := vmCtx.VM.Annotations[vmopapi.ImportVMInstanceUUIDAnnotation]
+
+	if moID := vmCtx.VM.Annotations[vmopapi.ImportVMMoIDAnnotation]; moID != "" {
+		resVM, err := s.lookupVMByMoID(vmCtx, moID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find imported VM with MoID %s", moID)
+		}
+
+		if uuid != "" {
+			moVM, err := resVM.GetProperties(vmCtx, []string{"config.instanceUuid"})
+			if err != nil {
+				return nil, err
+			}
+			if moVM.Config == nil || moVM.Config.InstanceUuid != uuid {
+				return nil, errors.Errorf("imported VM with MoID %s does not have instance UUID %s", moID, uuid)
+			}
+		}
+
+		return resVM, nil
+	}
+
+	if uuid == "" {
+		return nil, errors.Errorf("VM %s does not have an import annotation", vmCtx.VM.NamespacedName())
+	}
+
+	instanceUUID := true
+	searchIndex := object.NewSearchIndex(s.Client.VimClient())
+	ref, err := searchIndex.FindByUuid(vmCtx, s.datacenter, uuid, true, &instanceUUID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find imported VM with instance UUID %s", uuid)
+	}
+	if ref == nil {
+		return nil, errors.Errorf("imported VM with instance UUID %s does not exist", uuid)
+	}
+
+	vm, ok := ref.(*object.VirtualMachine)
+	if !ok {
+		return nil, errors.Errorf("imported VM instance UUID %s is for a %s", uuid, ref.Reference().Type)
+	}
+
+	return res.NewVMFromObject(vm)
+}
+
+// getVMResourcePolicy returns the VM's resource policy, if any, so a VM that is moved by VM Operator is
+// placed where GetVirtualMachine looks for the VM.
+func (s *Session) getVMResourcePolicy(vmCtx context.VirtualMachineContext) (*v1alpha1.VirtualMachineSetResourcePolicy, error) {
+	policyName := vmCtx.VM.Spec.ResourcePolicyName
+	if policyName == "" {
+		return nil, nil
+	}
+
+	rp := &v1alpha1.VirtualMachineSetResourcePolicy{}
+	rpKey := ctrlruntime.ObjectKey{Name: policyName, Namespace: vmCtx.VM.Namespace}
+	if err := s.k8sClient.Get(vmCtx, rpKey, rp); err != nil {
+		return nil, errors.Wrapf(err, "failed to get resource policy %s", rpKey)
+	}
+
+	return rp, nil
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/lib"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/instancestorage"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/pool"
+	res "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
+)
+
+// PlaceInstanceStorage selects the host of the instance storage volumes of the VM, and sets it in the selected
+// node annotation of the VM for the volume controller to place the PVCs of the volumes on the host. The candidate
+// hosts are the hosts of the session's cluster that are connected, are not in maintenance mode, are not
+// excluded in the placement diagnostics of the VM and did not recently fail to place the volumes. The current host of the VM is kept when it is a candidate, and
+// otherwise the VM is relocated to the candidate that placement recommends. Every host of the cluster is recorded
+// in a new attempt of the placement diagnostics, with why it was rejected.
+func (s *Session) PlaceInstanceStorage(vmCtx context.VirtualMachineContext, resVM *res.VirtualMachine) error {
+	if s.cluster == nil {
+		return errors.New("cannot place instance storage volumes without a cluster")
+	}
+
+	moVM, err := resVM.GetProperties(vmCtx, []string{"runtime.host"})
+	if err != nil {
+		return err
+	}
+
+	hosts, datastoreNames, err := s.clusterHosts(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	diagnostics, err := vmopapi.GetInstanceStoragePlacementDiagnostics(vmCtx.VM)
+	if err != nil || diagnostics == nil {
+		diagnostics = &vmopapi.InstanceStoragePlacementDiagnostics{}
+	}
+	now := metav1.Now()
+	diagnostics.PruneExcludedHosts(now.Time)
+
+	candidates, allowed := GetInstanceStorageHostCandidates(hosts, datastoreNames, diagnostics, now.Time,
+		lib.GetInstanceStoragePVPlacementFailedTTL())
+	attempt := vmopapi.InstanceStoragePlacementAttempt{
+		StartTime:  now,
+		Result:     vmopapi.InstanceStoragePlacementPending,
+		Candidates: candidates,
+	}
+
+	if len(allowed) == 0 {
+		attempt.CompletionTime = &now
+		attempt.Result = vmopapi.InstanceStoragePlacementFailed
+		attempt.Reason = "no host is available"
+		instancestorage.AppendPlacementAttempt(diagnostics, attempt)
+		if err := instancestorage.SetPlacementDiagnostics(vmCtx.VM, diagnostics); err != nil {
+			vmCtx.Logger.Error(err, "Failed to set instance storage placement diagnostics")
+		}
+		return errors.Errorf("no host of cluster %s is available for the instance storage volumes",
+			s.cluster.Reference().Value)
+	}
+
+	var selected *vimTypes.ManagedObjectReference
+	reason := "the VM is on another host"
+	for i := range allowed {
+		if moVM.Runtime.Host != nil && allowed[i].Value == moVM.Runtime.Host.Value {
+			selected = &allowed[i]
+			break
+		}
+	}
+
+	if selected == nil {
+		relocateSpec, err := pool.RelocateVMRelocateSpec(vmCtx, s.cluster, resVM.MoRef(), allowed)
+		if err != nil {
+			return errors.Wrap(err, "failed to place VM for instance storage volumes")
+		}
+
+		vmCtx.Logger.Info("Relocating VM for instance storage volumes", "host", relocateSpec.Host.Value)
+		if err := resVM.Relocate(vmCtx, vimTypes.VirtualMachineRelocateSpec{Host: relocateSpec.Host}); err != nil {
+			return err
+		}
+		selected = relocateSpec.Host
+		reason = "placement recommended another host"
+	}
+
+	for i := range hosts {
+		if hosts[i].Self.Value != selected.Value {
+			continue
+		}
+		attempt.Host = hosts[i].Name
+		for j := range attempt.Candidates {
+			switch {
+			case attempt.Candidates[j].Host == attempt.Host:
+				attempt.Candidates[j].Reason = ""
+			case attempt.Candidates[j].Reason == "":
+				attempt.Candidates[j].Reason = reason
+			}
+		}
+	}
+
+	if attempt.Host == "" {
+		return errors.Errorf("selected host %s is not a host of cluster %s", selected.Value, s.cluster.Reference().Value)
+	}
+
+	vmCtx.Logger.Info("Selected host for instance storage volumes", "host", attempt.Host)
+	instancestorage.AppendPlacementAttempt(diagnostics, attempt)
+	if err := instancestorage.SetPlacementDiagnostics(vmCtx.VM, diagnostics); err != nil {
+		vmCtx.Logger.Error(err, "Failed to set instance storage placement diagnostics")
+	}
+	if vmCtx.VM.Annotations == nil {
+		vmCtx.VM.Annotations = map[string]string{}
+	}
+	vmCtx.VM.Annotations[constants.InstanceStorageSelectedNodeAnnotationKey] = attempt.Host
+
+	return nil
+}
+
+// GetInstanceStorageHostCandidates returns every host with its datastores and why it cannot be selected for the
+// instance storage volumes, sorted by host name, and the references of the hosts that can be selected. A host on
+// which the placement of the volumes failed within the failedTTL cannot be selected.
+func GetInstanceStorageHostCandidates(
+	hosts []mo.HostSystem,
+	datastoreNames map[string]string,
+	diagnostics *vmopapi.InstanceStoragePlacementDiagnostics,
+	now time.Time,
+	failedTTL time.Duration) ([]vmopapi.InstanceStorageHostCandidate, []vimTypes.ManagedObjectReference) {
+
+	if diagnostics == nil {
+		diagnostics = &vmopapi.InstanceStoragePlacementDiagnostics{}
+	}
+
+	failedHosts := map[string]*vmopapi.InstanceStoragePlacementAttempt{}
+	for i := range diagnostics.Attempts {
+		attempt := &diagnostics.Attempts[i]
+		if attempt.Result == vmopapi.InstanceStoragePlacementFailed && attempt.Host != "" &&
+			attempt.CompletionTime != nil && now.Sub(attempt.CompletionTime.Time) < failedTTL {
+			failedHosts[attempt.Host] = attempt
+		}
+	}
+
+	sorted := make([]mo.HostSystem, len(hosts))
+	copy(sorted, hosts)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	candidates := make([]vmopapi.InstanceStorageHostCandidate, 0, len(sorted))
+	var allowed []vimTypes.ManagedObjectReference
+	for _, host := range sorted {
+		candidate := vmopapi.InstanceStorageHostCandidate{Host: host.Name}
+		for _, ds := range host.Datastore {
+			name := datastoreNames[ds.Value]
+			if name == "" {
+				name = ds.Value
+			}
+			candidate.Datastores = append(candidate.Datastores, name)
+		}
+
+		exclusion := diagnostics.GetExcludedHost(host.Name, now)
+		failed := failedHosts[host.Name]
+		switch {
+		case exclusion != nil:
+			candidate.Reason = fmt.Sprintf("the host is excluded until %s", exclusion.ExpirationTime.UTC().Format(time.RFC3339))
+			if exclusion.Reason != "" {
+				candidate.Reason += ": " + exclusion.Reason
+			}
+		case failed != nil:
+			candidate.Reason = fmt.Sprintf("the volumes failed to be placed on the host at %s",
+				failed.CompletionTime.UTC().Format(time.RFC3339))
+			if failed.Reason != "" {
+				candidate.Reason += ": " + failed.Reason
+			}
+		case host.Runtime.ConnectionState != vimTypes.HostSystemConnectionStateConnected:
+			candidate.Reason = fmt.Sprintf("the host is %s", host.Runtime.ConnectionState)
+		case host.Runtime.InMaintenanceMode:
+			candidate.Reason = "the host is in maintenance mode"
+		case len(host.Datastore) == 0:
+			candidate.Reason = "the host does not have a datastore"
+		default:
+			allowed = append(allowed, host.Self)
+		}
+
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates, allowed
+}
+
+// clusterHosts returns the hosts of the session's cluster, and the names of their datastores by managed object ID.
+func (s *Session) clusterHosts(vmCtx context.VirtualMachineContext) ([]mo.HostSystem, map[string]string, error) {
+	var cr mo.ClusterComputeResource
+	if err := s.cluster.Properties(vmCtx, s.cluster.Reference(), []string{"host"}, &cr); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get hosts of cluster %s", s.cluster.Reference().Value)
+	}
+
+	if len(cr.Host) == 0 {
+		return nil, nil, nil
+	}
+
+	pc := property.DefaultCollector(s.cluster.Client())
+	var hosts []mo.HostSystem
+	if err := pc.Retrieve(vmCtx, cr.Host, []string{"name", "datastore", "runtime"}, &hosts); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get hosts of cluster %s", s.cluster.Reference().Value)
+	}
+
+	var datastoreRefs []vimTypes.ManagedObjectReference
+	seen := map[string]struct{}{}
+	for _, host := range hosts {
+		for _, ds := range host.Datastore {
+			if _, ok := seen[ds.Value]; !ok {
+				seen[ds.Value] = struct{}{}
+				datastoreRefs = append(datastoreRefs, ds)
+			}
+		}
+	}
+
+	datastoreNames := make(map[string]string, len(datastoreRefs))
+	if len(datastoreRefs) > 0 {
+		var datastores []mo.Datastore
+		if err := pc.Retrieve(vmCtx, datastoreRefs, []string{"name"}, &datastores); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to get datastores of cluster %s", s.cluster.Reference().Value)
+		}
+		for _, ds := range datastores {
+			datastoreNames[ds.Self.Value] = ds.Name
+		}
+	}
+
+	return hosts, datastoreNames, nil
+}
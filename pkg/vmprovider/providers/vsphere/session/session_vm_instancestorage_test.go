@@ -0,0 +1,105 @@
+// +build !integration
+
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/vim25/mo"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/session"
+)
+
+var _ = Describe("Instance Storage Host Candidates", func() {
+	const failedTTL = 5 * time.Minute
+
+	var (
+		now            time.Time
+		hosts          []mo.HostSystem
+		datastoreNames map[string]string
+		diagnostics    *vmopapi.InstanceStoragePlacementDiagnostics
+	)
+
+	newHost := func(moID, name string) mo.HostSystem {
+		host := mo.HostSystem{
+			Datastore: []vimTypes.ManagedObjectReference{{Type: "Datastore", Value: "ds-" + moID}},
+		}
+		host.Self = vimTypes.ManagedObjectReference{Type: "HostSystem", Value: moID}
+		host.Name = name
+		host.Runtime.ConnectionState = vimTypes.HostSystemConnectionStateConnected
+		return host
+	}
+
+	BeforeEach(func() {
+		now = time.Now()
+		hosts = []mo.HostSystem{
+			newHost("host-2", "esx-2"),
+			newHost("host-1", "esx-1"),
+		}
+		datastoreNames = map[string]string{"ds-host-1": "local-1"}
+		diagnostics = &vmopapi.InstanceStoragePlacementDiagnostics{}
+	})
+
+	It("returns every host sorted by name with its datastores", func() {
+		candidates, allowed := session.GetInstanceStorageHostCandidates(hosts, datastoreNames, diagnostics, now, failedTTL)
+		Expect(candidates).To(Equal([]vmopapi.InstanceStorageHostCandidate{
+			{Host: "esx-1", Datastores: []string{"local-1"}},
+			{Host: "esx-2", Datastores: []string{"ds-host-2"}},
+		}))
+		Expect(allowed).To(Equal([]vimTypes.ManagedObjectReference{hosts[1].Self, hosts[0].Self}))
+	})
+
+	It("rejects excluded hosts until their exclusion expires", func() {
+		diagnostics.ExcludeHost("esx-1", "host maintenance", now)
+
+		candidates, allowed := session.GetInstanceStorageHostCandidates(hosts, datastoreNames, diagnostics, now, failedTTL)
+		Expect(candidates[0].Reason).To(ContainSubstring("excluded"))
+		Expect(candidates[0].Reason).To(ContainSubstring("host maintenance"))
+		Expect(allowed).To(Equal([]vimTypes.ManagedObjectReference{hosts[0].Self}))
+
+		later := now.Add(vmopapi.InstanceStorageHostExclusionDuration)
+		_, allowed = session.GetInstanceStorageHostCandidates(hosts, datastoreNames, diagnostics, later, failedTTL)
+		Expect(allowed).To(HaveLen(2))
+	})
+
+	It("rejects hosts in maintenance mode, disconnected or without a datastore", func() {
+		hosts[0].Runtime.InMaintenanceMode = true
+		hosts[1].Runtime.ConnectionState = vimTypes.HostSystemConnectionStateDisconnected
+		hosts = append(hosts, newHost("host-3", "esx-3"))
+		hosts[2].Datastore = nil
+
+		candidates, allowed := session.GetInstanceStorageHostCandidates(hosts, datastoreNames, diagnostics, now, failedTTL)
+		Expect(candidates).To(HaveLen(3))
+		Expect(candidates[0].Reason).To(Equal("the host is disconnected"))
+		Expect(candidates[1].Reason).To(Equal("the host is in maintenance mode"))
+		Expect(candidates[2].Reason).To(Equal("the host does not have a datastore"))
+		Expect(allowed).To(BeEmpty())
+	})
+
+	It("rejects hosts the volumes recently failed to be placed on", func() {
+		completionTime := metav1.NewTime(now.Add(-time.Minute))
+		diagnostics.Attempts = []vmopapi.InstanceStoragePlacementAttempt{
+			{
+				Host:           "esx-2",
+				CompletionTime: &completionTime,
+				Result:         vmopapi.InstanceStoragePlacementFailed,
+				Reason:         "pvc-1: FAILED_PLACEMENT-NotEnoughResources",
+			},
+		}
+
+		candidates, allowed := session.GetInstanceStorageHostCandidates(hosts, datastoreNames, diagnostics, now, failedTTL)
+		Expect(candidates[1].Reason).To(ContainSubstring("NotEnoughResources"))
+		Expect(allowed).To(Equal([]vimTypes.ManagedObjectReference{hosts[1].Self}))
+
+		_, allowed = session.GetInstanceStorageHostCandidates(hosts, datastoreNames, diagnostics, now.Add(failedTTL), failedTTL)
+		Expect(allowed).To(HaveLen(2))
+	})
+})
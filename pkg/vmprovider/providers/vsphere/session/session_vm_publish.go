@@ -0,0 +1,191 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vapi/vcenter"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+	res "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
+)
+
+// PublishVirtualMachine captures the VM as an OVF library item in the content library, and returns the ID of
+// the new item. The VM must be powered off, unless the item is captured from a snapshot of the VM, in which
+// case a temporary linked clone of the snapshot is captured instead, and then removed along with the snapshot.
+// An item with the same name and description is the item of a previous publish of the VM, which is returned
+// when it is complete, and is otherwise deleted before the VM is captured again.
+func (s *Session) PublishVirtualMachine(vmCtx context.VirtualMachineContext, args vmprovider.VMPublishArgs) (string, error) {
+	resVM, err := s.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return "", transformVMError(vmCtx.VM.NamespacedName(), err)
+	}
+
+	if itemID, err := s.findPublishedItem(vmCtx, args); err != nil || itemID != "" {
+		return itemID, err
+	}
+
+	source := resVM
+	if args.FromSnapshot {
+		clone, cleanup, err := s.cloneVMFromSnapshot(vmCtx, resVM, args.ItemName)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		source = clone
+	} else {
+		moVM, err := resVM.GetProperties(vmCtx, []string{"summary.runtime.powerState"})
+		if err != nil {
+			return "", err
+		}
+		if moVM.Summary.Runtime.PowerState != vimTypes.VirtualMachinePowerStatePoweredOff {
+			return "", errors.Errorf("VM %s must be powered off to be published", vmCtx.VM.NamespacedName())
+		}
+	}
+
+	ovf := vcenter.OVF{
+		Spec: vcenter.CreateSpec{
+			Name:        args.ItemName,
+			Description: args.Description,
+		},
+		Source: vcenter.ResourceID{
+			Type:  "VirtualMachine",
+			Value: source.ReferenceValue(),
+		},
+		Target: vcenter.LibraryTarget{
+			LibraryID: args.LibraryUUID,
+		},
+	}
+
+	vmCtx.Logger.Info("Publishing VM to content library", "libraryUUID", args.LibraryUUID, "itemName", args.ItemName)
+	itemID, err := vcenter.NewManager(s.Client.RestClient()).CreateOVF(vmCtx, ovf)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to publish VM %s to content library %s", vmCtx.VM.NamespacedName(), args.LibraryUUID)
+	}
+
+	return itemID, nil
+}
+
+// findPublishedItem returns the ID of the complete library item that a previous publish of the VM created,
+// when the publish succeeded but its result was not recorded. The incomplete item of a publish that was
+// interrupted is deleted, so that the VM can be captured again with the same item name.
+func (s *Session) findPublishedItem(vmCtx context.VirtualMachineContext, args vmprovider.VMPublishArgs) (string, error) {
+	libMgr := library.NewManager(s.Client.RestClient())
+	itemIDs, err := libMgr.FindLibraryItems(vmCtx, library.FindItem{LibraryID: args.LibraryUUID, Name: args.ItemName})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find library item %s", args.ItemName)
+	}
+
+	if len(itemIDs) == 0 {
+		return "", nil
+	}
+	if len(itemIDs) != 1 {
+		return "", errors.Errorf("multiple library items named: %s", args.ItemName)
+	}
+
+	item, err := libMgr.GetLibraryItem(vmCtx, itemIDs[0])
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get library item %s", itemIDs[0])
+	}
+	if item.Description != args.Description {
+		return "", errors.Errorf("library already has an item named %s", args.ItemName)
+	}
+
+	files, err := libMgr.ListLibraryItemFiles(vmCtx, item.ID)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list files of library item %s", item.ID)
+	}
+	if isCompleteOVFItem(files) {
+		vmCtx.Logger.Info("VM was already published to content library", "itemID", item.ID)
+		return item.ID, nil
+	}
+
+	vmCtx.Logger.Info("Deleting incomplete library item of interrupted publish", "itemID", item.ID)
+	if err := libMgr.DeleteLibraryItem(vmCtx, item); err != nil {
+		return "", errors.Wrapf(err, "failed to delete incomplete library item %s", item.ID)
+	}
+
+	return "", nil
+}
+
+// isCompleteOVFItem returns whether the files of a library item include an OVF descriptor, and all the files
+// were uploaded.
+func isCompleteOVFItem(files []library.File) bool {
+	hasOVF := false
+	for _, file := range files {
+		if file.Cached == nil || !*file.Cached {
+			return false
+		}
+		if filepath.Ext(file.Name) == ".ovf" {
+			hasOVF = true
+		}
+	}
+	return hasOVF
+}
+
+// cloneVMFromSnapshot snapshots the VM and creates a powered off linked clone of the snapshot in the folder of
+// the VM. The returned function removes the clone and the snapshot.
+func (s *Session) cloneVMFromSnapshot(
+	vmCtx context.VirtualMachineContext,
+	resVM *res.VirtualMachine,
+	name string) (*res.VirtualMachine, func(), error) {
+
+	moVM, err := resVM.GetProperties(vmCtx, []string{"parent"})
+	if err != nil {
+		return nil, nil, err
+	}
+	if moVM.Parent == nil {
+		return nil, nil, errors.Errorf("VM %s does not have a folder", resVM.ReferenceValue())
+	}
+
+	snapshotName := name + "-publish"
+	snapshotRef, err := resVM.CreateSnapshot(vmCtx, snapshotName, "Snapshot to publish the VM", false, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to snapshot VM %s", vmCtx.VM.NamespacedName())
+	}
+
+	removeSnapshot := func() {
+		if err := resVM.RemoveSnapshot(vmCtx, snapshotRef.Value); err != nil {
+			vmCtx.Logger.Error(err, "failed to remove publish snapshot", "snapshotID", snapshotRef.Value)
+		}
+	}
+
+	cloneSpec := &vimTypes.VirtualMachineCloneSpec{
+		Config: &vimTypes.VirtualMachineConfigSpec{
+			Name: snapshotName,
+		},
+		Location: vimTypes.VirtualMachineRelocateSpec{
+			DiskMoveType: string(vimTypes.VirtualMachineRelocateDiskMoveOptionsCreateNewChildDiskBacking),
+		},
+		Snapshot: snapshotRef,
+	}
+
+	folder := object.NewFolder(s.Client.VimClient(), *moVM.Parent)
+	cloneRef, err := resVM.Clone(vmCtx, folder, cloneSpec)
+	if err != nil {
+		removeSnapshot()
+		return nil, nil, errors.Wrapf(err, "failed to clone snapshot of VM %s", vmCtx.VM.NamespacedName())
+	}
+
+	clone, err := res.NewVMFromObject(object.NewVirtualMachine(s.Client.VimClient(), *cloneRef))
+	if err != nil {
+		removeSnapshot()
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		if err := clone.Delete(vmCtx); err != nil {
+			vmCtx.Logger.Error(err, "failed to delete publish clone", "cloneRef", cloneRef.Value)
+		}
+		removeSnapshot()
+	}
+
+	return clone, cleanup, nil
+}
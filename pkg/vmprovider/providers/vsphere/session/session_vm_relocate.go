@@ -0,0 +1,179 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	res "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
+)
+
+// RelocateVirtualMachine relocates the vSphere VM into this session's zone: the VM is relocated into
+// the zone's ResourcePool and onto a datastore accessible from the zone's cluster, and then moved into
+// the zone's Folder. Disks that back a CNS volume are left on their current datastore so the volume
+// stays attached to the VM. Each step is skipped when it was already done, so this can be called again
+// after the VM was relocated.
+func (s *Session) RelocateVirtualMachine(vmCtx context.VirtualMachineContext, resVM *res.VirtualMachine) error {
+	moVM, err := resVM.GetProperties(vmCtx, []string{"parent", "resourcePool", "datastore", "config.hardware.device"})
+	if err != nil {
+		return err
+	}
+
+	if moVM.Config == nil {
+		return errors.Errorf("VM %s does not have a config", resVM.ReferenceValue())
+	}
+
+	resourcePolicy, err := s.getVMResourcePolicy(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	resourcePool, folder, err := s.getResourcePoolAndFolder(vmCtx, resourcePolicy)
+	if err != nil {
+		return err
+	}
+
+	if poolRef := resourcePool.Reference(); moVM.ResourcePool == nil || moVM.ResourcePool.Value != poolRef.Value {
+		relocateSpec, err := s.relocateVMSpec(vmCtx, moVM)
+		if err != nil {
+			return err
+		}
+		relocateSpec.Pool = &poolRef
+
+		vmCtx.Logger.Info("Relocating VM into ResourcePool", "resourcePool", poolRef.Value)
+		if err := resVM.Relocate(vmCtx, *relocateSpec); err != nil {
+			return err
+		}
+	}
+
+	if folderRef := folder.Reference(); moVM.Parent == nil || moVM.Parent.Value != folderRef.Value {
+		vmCtx.Logger.Info("Moving VM into Folder", "folder", folderRef.Value)
+		if err := resVM.MoveInto(vmCtx, folder); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// relocateVMSpec returns the RelocateSpec with the datastore placement of the VM. The VM's datastores are
+// kept when the session's cluster can access them. Otherwise, the session's datastore is used if configured,
+// or else the cluster's datastore with the most free space.
+func (s *Session) relocateVMSpec(
+	vmCtx context.VirtualMachineContext,
+	moVM *mo.VirtualMachine) (*vimTypes.VirtualMachineRelocateSpec, error) {
+
+	relocateSpec := &vimTypes.VirtualMachineRelocateSpec{}
+
+	datastores, err := s.clusterDatastores(vmCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	if datastores == nil {
+		// Without a cluster, there is nothing to check the VM's datastore against.
+		return relocateSpec, nil
+	}
+
+	accessible := map[string]bool{}
+	for _, ds := range datastores {
+		if ds.Summary.Accessible {
+			accessible[ds.Self.Value] = true
+		}
+	}
+
+	keepDatastores := len(moVM.Datastore) > 0
+	for _, ref := range moVM.Datastore {
+		keepDatastores = keepDatastores && accessible[ref.Value]
+	}
+	if keepDatastores {
+		vmCtx.Logger.V(4).Info("Keeping VM on its datastores")
+		return relocateSpec, nil
+	}
+
+	var datastoreRef *vimTypes.ManagedObjectReference
+	if s.datastore != nil {
+		ref := s.datastore.Reference()
+		datastoreRef = &ref
+	} else {
+		var freeSpace int64
+		for i := range datastores {
+			ds := &datastores[i]
+			if ds.Summary.Accessible && (datastoreRef == nil || ds.Summary.FreeSpace > freeSpace) {
+				datastoreRef = &ds.Self
+				freeSpace = ds.Summary.FreeSpace
+			}
+		}
+	}
+
+	if datastoreRef == nil {
+		return nil, errors.Errorf("no datastore is accessible from cluster %s", s.cluster.Reference().Value)
+	}
+
+	vmCtx.Logger.Info("Relocating VM onto datastore", "datastore", datastoreRef.Value)
+	relocateSpec.Datastore = datastoreRef
+	relocateSpec.Disk = relocateVMDiskLocators(object.VirtualDeviceList(moVM.Config.Hardware.Device), *datastoreRef)
+
+	return relocateSpec, nil
+}
+
+// clusterDatastores returns the datastores of the session's cluster, or nil if the session does not have
+// a cluster.
+func (s *Session) clusterDatastores(vmCtx context.VirtualMachineContext) ([]mo.Datastore, error) {
+	if s.cluster == nil {
+		return nil, nil
+	}
+
+	var cr mo.ClusterComputeResource
+	if err := s.cluster.Properties(vmCtx, s.cluster.Reference(), []string{"datastore"}, &cr); err != nil {
+		return nil, errors.Wrapf(err, "failed to get datastores of cluster %s", s.cluster.Reference().Value)
+	}
+
+	datastores := []mo.Datastore{}
+	if len(cr.Datastore) == 0 {
+		return datastores, nil
+	}
+
+	pc := property.DefaultCollector(s.cluster.Client())
+	if err := pc.Retrieve(vmCtx, cr.Datastore, []string{"summary"}, &datastores); err != nil {
+		return nil, errors.Wrapf(err, "failed to get summary of datastores of cluster %s", s.cluster.Reference().Value)
+	}
+
+	return datastores, nil
+}
+
+// relocateVMDiskLocators returns the disk locators that move the VM's disks to the datastore. Disks that
+// are first class disks, like the disks of CNS volumes, are kept on their current datastore.
+func relocateVMDiskLocators(
+	devices object.VirtualDeviceList,
+	datastore vimTypes.ManagedObjectReference) []vimTypes.VirtualMachineRelocateSpecDiskLocator {
+
+	disks := devices.SelectByType((*vimTypes.VirtualDisk)(nil))
+	diskLocators := make([]vimTypes.VirtualMachineRelocateSpecDiskLocator, 0, len(disks))
+
+	for _, dev := range disks {
+		disk := dev.(*vimTypes.VirtualDisk)
+		locator := vimTypes.VirtualMachineRelocateSpecDiskLocator{
+			DiskId:    disk.Key,
+			Datastore: datastore,
+		}
+
+		if disk.VDiskId != nil {
+			if backing, ok := disk.Backing.(vimTypes.BaseVirtualDeviceFileBackingInfo); ok {
+				if ds := backing.GetVirtualDeviceFileBackingInfo().Datastore; ds != nil {
+					locator.Datastore = *ds
+				}
+			}
+		}
+
+		diskLocators = append(diskLocators, locator)
+	}
+
+	return diskLocators
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"fmt"
+
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	apiEquality "k8s.io/apimachinery/pkg/api/equality"
+
+	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/contentlibrary"
+	res "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
+)
+
+// minHotAddHardwareVersion is the minimum hardware version that supports CPU and memory hot add.
+const minHotAddHardwareVersion = 7
+
+// ResizeConfigSpec returns the ConfigSpec that changes the CPU and memory of the VM, and their reservations
+// and limits, to match its class. The returned ConfigSpec only has the CPU, memory, reservations and limits
+// set that do not already match the class, and so is empty when the VM matches its class.
+func ResizeConfigSpec(
+	config *vimTypes.VirtualMachineConfigInfo,
+	vmClassSpec *v1alpha1.VirtualMachineClassSpec,
+	minCPUFreq uint64) *vimTypes.VirtualMachineConfigSpec {
+
+	configSpec := &vimTypes.VirtualMachineConfigSpec{}
+	if nCPUs := int32(vmClassSpec.Hardware.Cpus); config.Hardware.NumCPU != nCPUs {
+		configSpec.NumCPUs = nCPUs
+	}
+	if memMB := MemoryQuantityToMb(vmClassSpec.Hardware.Memory); int64(config.Hardware.MemoryMB) != memMB {
+		configSpec.MemoryMB = memMB
+	}
+	UpdateConfigSpecCPUAllocation(config, configSpec, vmClassSpec, minCPUFreq)
+	UpdateConfigSpecMemoryAllocation(config, configSpec, vmClassSpec)
+
+	return configSpec
+}
+
+// HotResizeUnsupportedReason returns why the CPU and memory changes of the ConfigSpec cannot be made while the
+// VM is running, or an empty string if they can be hot added.
+func HotResizeUnsupportedReason(
+	config *vimTypes.VirtualMachineConfigInfo,
+	configSpec *vimTypes.VirtualMachineConfigSpec) string {
+
+	if configSpec.NumCPUs == 0 && configSpec.MemoryMB == 0 {
+		// Only the reservations and limits change, which is always allowed.
+		return ""
+	}
+
+	if version := contentlibrary.ParseVirtualHardwareVersion(config.Version); version != 0 && version < minHotAddHardwareVersion {
+		return fmt.Sprintf("hardware version %s does not support hot add", config.Version)
+	}
+
+	switch {
+	case configSpec.NumCPUs > config.Hardware.NumCPU && !isTrue(config.CpuHotAddEnabled):
+		return "CPU hot add is not enabled"
+	case configSpec.NumCPUs != 0 && configSpec.NumCPUs < config.Hardware.NumCPU && !isTrue(config.CpuHotRemoveEnabled):
+		return "CPU hot remove is not enabled"
+	}
+
+	switch {
+	case configSpec.MemoryMB == 0:
+	case configSpec.MemoryMB < int64(config.Hardware.MemoryMB):
+		return "memory cannot be removed from a running VM"
+	case !isTrue(config.MemoryHotAddEnabled):
+		return "memory hot add is not enabled"
+	case config.HotPlugMemoryLimit > 0 && configSpec.MemoryMB > config.HotPlugMemoryLimit:
+		return fmt.Sprintf("memory exceeds the hot add limit of %d MB", config.HotPlugMemoryLimit)
+	}
+
+	return ""
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// resizePoweredOnVM changes the CPU and memory of the powered on VM, and their reservations and limits, to match
+// its class. The VM is resized while running when hot add allows it. Otherwise, the VM is only power cycled to be
+// resized when its ResizePolicyAnnotation allows it. The outcome is reported in the VirtualMachineResizedCondition.
+func (s *Session) resizePoweredOnVM(
+	vmCtx context.VirtualMachineContext,
+	resVM *res.VirtualMachine,
+	config *vimTypes.VirtualMachineConfigInfo,
+	vmClassSpec *v1alpha1.VirtualMachineClassSpec) error {
+
+	vm := vmCtx.VM
+	configSpec := ResizeConfigSpec(config, vmClassSpec, s.GetCPUMinMHzInCluster())
+	defaultConfigSpec := &vimTypes.VirtualMachineConfigSpec{}
+	if apiEquality.Semantic.DeepEqual(configSpec, defaultConfigSpec) {
+		// Clear a pending or failed resize when the class was changed back.
+		if conditions.IsFalse(vm, vmopapi.VirtualMachineResizedCondition) {
+			conditions.Delete(vm, vmopapi.VirtualMachineResizedCondition)
+		}
+		return nil
+	}
+
+	reason := HotResizeUnsupportedReason(config, configSpec)
+
+	if reason == "" {
+		vmCtx.Logger.Info("Hot resizing VM", "configSpec", configSpec)
+		if err := resVM.Reconfigure(vmCtx, configSpec); err != nil {
+			conditions.MarkFalse(vm, vmopapi.VirtualMachineResizedCondition, vmopapi.VirtualMachineResizeFailedReason,
+				v1alpha1.ConditionSeverityError, "Failed to hot resize VM: %v", err)
+			return err
+		}
+
+		markResizedCondition(vm, vmopapi.VirtualMachineResizedHotAddReason, "VM was resized while running")
+		return nil
+	}
+
+	if vm.Annotations[vmopapi.ResizePolicyAnnotation] != vmopapi.ResizePolicyPowerCycle {
+		conditions.MarkFalse(vm, vmopapi.VirtualMachineResizedCondition, vmopapi.VirtualMachineResizePowerCycleRequiredReason,
+			v1alpha1.ConditionSeverityWarning, "VM cannot be resized while running because %s. Set the %s annotation to %s to power cycle the VM",
+			reason, vmopapi.ResizePolicyAnnotation, vmopapi.ResizePolicyPowerCycle)
+		return nil
+	}
+
+	vmCtx.Logger.Info("Power cycling VM to resize it", "reason", reason, "configSpec", configSpec)
+
+	if err := resVM.SetPowerState(vmCtx, v1alpha1.VirtualMachinePoweredOff); err != nil {
+		conditions.MarkFalse(vm, vmopapi.VirtualMachineResizedCondition, vmopapi.VirtualMachineResizeFailedReason,
+			v1alpha1.ConditionSeverityError, "Failed to power off VM: %v", err)
+		return err
+	}
+
+	reconfigureErr := resVM.Reconfigure(vmCtx, configSpec)
+
+	// Always power the VM back on, even when the reconfigure failed.
+	if err := resVM.SetPowerState(vmCtx, v1alpha1.VirtualMachinePoweredOn); err != nil {
+		conditions.MarkFalse(vm, vmopapi.VirtualMachineResizedCondition, vmopapi.VirtualMachineResizeFailedReason,
+			v1alpha1.ConditionSeverityError, "Failed to power on VM: %v", err)
+		return err
+	}
+
+	if reconfigureErr != nil {
+		conditions.MarkFalse(vm, vmopapi.VirtualMachineResizedCondition, vmopapi.VirtualMachineResizeFailedReason,
+			v1alpha1.ConditionSeverityError, "Failed to resize powered off VM: %v", reconfigureErr)
+		return reconfigureErr
+	}
+
+	markResizedCondition(vm, vmopapi.VirtualMachineResizedPowerCycleReason, fmt.Sprintf("VM was power cycled to be resized because %s", reason))
+	return nil
+}
+
+func markResizedCondition(vm *v1alpha1.VirtualMachine, reason, msg string) {
+	conditions.Set(vm, &v1alpha1.Condition{
+		Type:    vmopapi.VirtualMachineResizedCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  reason,
+		Message: msg,
+	})
+}
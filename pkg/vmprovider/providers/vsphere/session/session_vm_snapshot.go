@@ -0,0 +1,168 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"fmt"
+
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+	res "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
+)
+
+// CreateVirtualMachineSnapshot snapshots the VM and returns the ID of the new snapshot.
+func (s *Session) CreateVirtualMachineSnapshot(vmCtx context.VirtualMachineContext, args vmprovider.VMSnapshotArgs) (string, error) {
+	resVM, err := s.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return "", transformVMError(vmCtx.VM.NamespacedName(), err)
+	}
+
+	ref, err := resVM.CreateSnapshot(vmCtx, args.Name, args.Description, args.Memory, args.Quiesce)
+	if err != nil {
+		return "", err
+	}
+
+	return ref.Value, nil
+}
+
+// ListVirtualMachineSnapshots returns all the snapshots of the VM.
+func (s *Session) ListVirtualMachineSnapshots(vmCtx context.VirtualMachineContext) ([]vmprovider.VMSnapshot, error) {
+	resVM, err := s.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return nil, transformVMError(vmCtx.VM.NamespacedName(), err)
+	}
+
+	return listSnapshots(vmCtx, resVM)
+}
+
+// RevertVirtualMachineSnapshot reverts the VM to the snapshot.
+func (s *Session) RevertVirtualMachineSnapshot(vmCtx context.VirtualMachineContext, snapshotID string) error {
+	resVM, err := s.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return transformVMError(vmCtx.VM.NamespacedName(), err)
+	}
+
+	snapshots, err := listSnapshots(vmCtx, resVM)
+	if err != nil {
+		return err
+	}
+
+	if FindVMSnapshot(snapshots, snapshotID) == nil {
+		return transformSnapshotNotFoundError(snapshotID)
+	}
+
+	return resVM.RevertToSnapshot(vmCtx, snapshotID)
+}
+
+// DeleteVirtualMachineSnapshot removes the snapshot from the VM. It is not an error if the snapshot
+// does not exist.
+func (s *Session) DeleteVirtualMachineSnapshot(vmCtx context.VirtualMachineContext, snapshotID string) error {
+	resVM, err := s.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return transformVMError(vmCtx.VM.NamespacedName(), err)
+	}
+
+	snapshots, err := listSnapshots(vmCtx, resVM)
+	if err != nil {
+		return err
+	}
+
+	if FindVMSnapshot(snapshots, snapshotID) == nil {
+		vmCtx.Logger.V(4).Info("Snapshot does not exist", "snapshotID", snapshotID)
+		return nil
+	}
+
+	return resVM.RemoveSnapshot(vmCtx, snapshotID)
+}
+
+func listSnapshots(vmCtx context.VirtualMachineContext, resVM *res.VirtualMachine) ([]vmprovider.VMSnapshot, error) {
+	moVM, err := resVM.GetProperties(vmCtx, []string{"snapshot"})
+	if err != nil {
+		return nil, err
+	}
+
+	return SnapshotInfoToVMSnapshots(moVM.Snapshot), nil
+}
+
+func transformSnapshotNotFoundError(snapshotID string) error {
+	return k8serrors.NewNotFound(schema.GroupResource{Group: "vmoperator.vmware.com", Resource: "virtualmachinesnapshot"}, snapshotID)
+}
+
+// SnapshotInfoToVMSnapshots flattens the snapshot tree of the VM. Parents are listed before their
+// children.
+func SnapshotInfoToVMSnapshots(info *vimTypes.VirtualMachineSnapshotInfo) []vmprovider.VMSnapshot {
+	if info == nil {
+		return nil
+	}
+
+	var current string
+	if info.CurrentSnapshot != nil {
+		current = info.CurrentSnapshot.Value
+	}
+
+	var snapshots []vmprovider.VMSnapshot
+	var walk func(parentID string, trees []vimTypes.VirtualMachineSnapshotTree)
+	walk = func(parentID string, trees []vimTypes.VirtualMachineSnapshotTree) {
+		for _, tree := range trees {
+			snapshots = append(snapshots, vmprovider.VMSnapshot{
+				ID:          tree.Snapshot.Value,
+				Name:        tree.Name,
+				Description: tree.Description,
+				CreateTime:  tree.CreateTime,
+				PowerState:  vmopv1alpha1.VirtualMachinePowerState(tree.State),
+				Quiesced:    tree.Quiesced,
+				Current:     tree.Snapshot.Value == current,
+				ParentID:    parentID,
+			})
+			walk(tree.Snapshot.Value, tree.ChildSnapshotList)
+		}
+	}
+	walk("", info.RootSnapshotList)
+
+	return snapshots
+}
+
+// FindVMSnapshot returns the snapshot with the ID, or nil if there is no such snapshot.
+func FindVMSnapshot(snapshots []vmprovider.VMSnapshot, snapshotID string) *vmprovider.VMSnapshot {
+	for i := range snapshots {
+		if snapshots[i].ID == snapshotID {
+			return &snapshots[i]
+		}
+	}
+	return nil
+}
+
+// MarkSnapshotCondition reflects the current snapshot of the VM in its Status. The condition is
+// removed when the VM has no snapshots.
+func MarkSnapshotCondition(vm *vmopv1alpha1.VirtualMachine, info *vimTypes.VirtualMachineSnapshotInfo) {
+	snapshots := SnapshotInfoToVMSnapshots(info)
+	if len(snapshots) == 0 {
+		conditions.Delete(vm, vmopapi.VirtualMachineSnapshotCondition)
+		return
+	}
+
+	msg := fmt.Sprintf("VM has %d snapshots and is not running from a snapshot", len(snapshots))
+	for _, snapshot := range snapshots {
+		if snapshot.Current {
+			msg = fmt.Sprintf("VM has %d snapshots and is running from snapshot %q (%s)", len(snapshots), snapshot.Name, snapshot.ID)
+			break
+		}
+	}
+
+	conditions.Set(vm, &vmopv1alpha1.Condition{
+		Type:    vmopapi.VirtualMachineSnapshotCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  vmopapi.VirtualMachineSnapshotPresentReason,
+		Message: msg,
+	})
+}
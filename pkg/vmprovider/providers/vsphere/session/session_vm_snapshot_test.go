@@ -0,0 +1,92 @@
+// +build !integration
+
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/session"
+)
+
+var _ = Describe("VirtualMachine Snapshots", func() {
+	var (
+		info *vimTypes.VirtualMachineSnapshotInfo
+	)
+
+	BeforeEach(func() {
+		info = &vimTypes.VirtualMachineSnapshotInfo{
+			CurrentSnapshot: &vimTypes.ManagedObjectReference{Type: "VirtualMachineSnapshot", Value: "snapshot-2"},
+			RootSnapshotList: []vimTypes.VirtualMachineSnapshotTree{
+				{
+					Snapshot: vimTypes.ManagedObjectReference{Type: "VirtualMachineSnapshot", Value: "snapshot-1"},
+					Name:     "root",
+					State:    vimTypes.VirtualMachinePowerStatePoweredOff,
+					ChildSnapshotList: []vimTypes.VirtualMachineSnapshotTree{
+						{
+							Snapshot: vimTypes.ManagedObjectReference{Type: "VirtualMachineSnapshot", Value: "snapshot-2"},
+							Name:     "child",
+							State:    vimTypes.VirtualMachinePowerStatePoweredOn,
+							Quiesced: true,
+						},
+					},
+				},
+			},
+		}
+	})
+
+	Context("SnapshotInfoToVMSnapshots", func() {
+		It("returns nil when the VM has no snapshots", func() {
+			Expect(session.SnapshotInfoToVMSnapshots(nil)).To(BeEmpty())
+		})
+
+		It("flattens the snapshot tree", func() {
+			snapshots := session.SnapshotInfoToVMSnapshots(info)
+			Expect(snapshots).To(HaveLen(2))
+
+			Expect(snapshots[0].ID).To(Equal("snapshot-1"))
+			Expect(snapshots[0].Name).To(Equal("root"))
+			Expect(snapshots[0].ParentID).To(BeEmpty())
+			Expect(snapshots[0].Current).To(BeFalse())
+			Expect(snapshots[0].PowerState).To(Equal(vmopv1alpha1.VirtualMachinePoweredOff))
+
+			Expect(snapshots[1].ID).To(Equal("snapshot-2"))
+			Expect(snapshots[1].ParentID).To(Equal("snapshot-1"))
+			Expect(snapshots[1].Current).To(BeTrue())
+			Expect(snapshots[1].Quiesced).To(BeTrue())
+
+			Expect(session.FindVMSnapshot(snapshots, "snapshot-2")).To(Equal(&snapshots[1]))
+			Expect(session.FindVMSnapshot(snapshots, "snapshot-3")).To(BeNil())
+		})
+	})
+
+	Context("MarkSnapshotCondition", func() {
+		var vm *vmopv1alpha1.VirtualMachine
+
+		BeforeEach(func() {
+			vm = &vmopv1alpha1.VirtualMachine{}
+		})
+
+		It("sets the condition when the VM has snapshots", func() {
+			session.MarkSnapshotCondition(vm, info)
+			c := conditions.Get(vm, vmopapi.VirtualMachineSnapshotCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Reason).To(Equal(vmopapi.VirtualMachineSnapshotPresentReason))
+			Expect(c.Message).To(ContainSubstring("snapshot-2"))
+		})
+
+		It("removes the condition when the VM has no snapshots", func() {
+			session.MarkSnapshotCondition(vm, info)
+			session.MarkSnapshotCondition(vm, nil)
+			Expect(conditions.Get(vm, vmopapi.VirtualMachineSnapshotCondition)).To(BeNil())
+		})
+	})
+})
@@ -4,15 +4,23 @@
 package session
 
 import (
+	"encoding/json"
+	"sort"
 	"strconv"
+	"time"
 
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8serrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
 	vimTypes "github.com/vmware/govmomi/vim25/types"
 
 	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	"github.com/acharyasreej/vm-operator/pkg/context"
 	res "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
@@ -62,13 +70,25 @@ func MarkCustomizationInfoCondition(vm *v1alpha1.VirtualMachine, guestInfo *vimT
 		return
 	}
 
+	// Include the customization attempt, if recorded, so re-customizations are visible in the condition.
+	attemptMsg := func(msg string) string {
+		attempt := vm.Annotations[vmopapi.CustomizationAttemptsAnnotation]
+		if attempt == "" {
+			return msg
+		}
+		if msg == "" {
+			return "Customization attempt " + attempt
+		}
+		return msg + " (customization attempt " + attempt + ")"
+	}
+
 	switch guestInfo.CustomizationInfo.CustomizationStatus {
 	case string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_IDLE), "":
 		conditions.MarkTrue(vm, v1alpha1.GuestCustomizationCondition)
 	case string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_PENDING):
-		conditions.MarkFalse(vm, v1alpha1.GuestCustomizationCondition, v1alpha1.GuestCustomizationPendingReason, v1alpha1.ConditionSeverityInfo, "")
+		conditions.MarkFalse(vm, v1alpha1.GuestCustomizationCondition, v1alpha1.GuestCustomizationPendingReason, v1alpha1.ConditionSeverityInfo, attemptMsg(""))
 	case string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_RUNNING):
-		conditions.MarkFalse(vm, v1alpha1.GuestCustomizationCondition, v1alpha1.GuestCustomizationRunningReason, v1alpha1.ConditionSeverityInfo, "")
+		conditions.MarkFalse(vm, v1alpha1.GuestCustomizationCondition, v1alpha1.GuestCustomizationRunningReason, v1alpha1.ConditionSeverityInfo, attemptMsg(""))
 	case string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_SUCCEEDED):
 		conditions.MarkTrue(vm, v1alpha1.GuestCustomizationCondition)
 	case string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_FAILED):
@@ -76,23 +96,298 @@ func MarkCustomizationInfoCondition(vm *v1alpha1.VirtualMachine, guestInfo *vimT
 		if errorMsg == "" {
 			errorMsg = "vSphere VM Customization failed due to an unknown error."
 		}
-		conditions.MarkFalse(vm, v1alpha1.GuestCustomizationCondition, v1alpha1.GuestCustomizationFailedReason, v1alpha1.ConditionSeverityError, errorMsg)
+		conditions.MarkFalse(vm, v1alpha1.GuestCustomizationCondition, v1alpha1.GuestCustomizationFailedReason, v1alpha1.ConditionSeverityError, attemptMsg(errorMsg))
 	default:
 		errorMsg := guestInfo.CustomizationInfo.ErrorMsg
 		if errorMsg == "" {
 			errorMsg = "Unexpected VM Customization status"
 		}
-		conditions.MarkFalse(vm, v1alpha1.GuestCustomizationCondition, "", v1alpha1.ConditionSeverityError, errorMsg)
+		conditions.MarkFalse(vm, v1alpha1.GuestCustomizationCondition, "", v1alpha1.ConditionSeverityError, attemptMsg(errorMsg))
+	}
+}
+
+// vmStatusProperties are the properties of the VM that are reflected in the status of a VirtualMachine.
+var vmStatusProperties = []string{
+	"config.changeTrackingEnabled",
+	"config.hardware.device",
+	"config.version",
+	"guest",
+	"snapshot",
+	"summary",
+}
+
+// getVMStatusProperties returns the status properties of the VM, and the info of its recent tasks, which are
+// retrieved together by traversing from the VM to its recent tasks.
+func (s *Session) getVMStatusProperties(
+	vmCtx context.VirtualMachineContext,
+	resVM *res.VirtualMachine) (*mo.VirtualMachine, []mo.Task, error) {
+
+	req := vimTypes.RetrieveProperties{
+		SpecSet: []vimTypes.PropertyFilterSpec{
+			{
+				ObjectSet: []vimTypes.ObjectSpec{
+					{
+						Obj: resVM.MoRef(),
+						SelectSet: []vimTypes.BaseSelectionSpec{
+							&vimTypes.TraversalSpec{
+								Type: "VirtualMachine",
+								Path: "recentTask",
+							},
+						},
+					},
+				},
+				PropSet: []vimTypes.PropertySpec{
+					{
+						Type:    "VirtualMachine",
+						PathSet: vmStatusProperties,
+					},
+					{
+						Type:    "Task",
+						PathSet: []string{"info"},
+					},
+				},
+			},
+		},
+	}
+
+	res, err := property.DefaultCollector(s.Client.VimClient()).RetrieveProperties(vmCtx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var vmContent, taskContent []vimTypes.ObjectContent
+	for _, content := range res.Returnval {
+		switch content.Obj.Type {
+		case "VirtualMachine":
+			vmContent = append(vmContent, content)
+		case "Task":
+			taskContent = append(taskContent, content)
+		}
+	}
+
+	var moVM mo.VirtualMachine
+	if err := mo.LoadObjectContent(vmContent, &moVM); err != nil {
+		return nil, nil, err
+	}
+
+	var tasks []mo.Task
+	if err := mo.LoadObjectContent(taskContent, &tasks); err != nil {
+		return nil, nil, err
+	}
+
+	return &moVM, tasks, nil
+}
+
+// GetVMStatusDetails returns the hardware, disks and guest OS of the VM. The guest OS reported by VMware Tools is
+// only known while the tools are running, so the guest OS of the previous details is kept while they are not,
+// and the configured guest OS is only used when the tools have not reported one yet.
+func GetVMStatusDetails(
+	moVM *mo.VirtualMachine,
+	prevDetails *vmopapi.VirtualMachineStatusDetails) *vmopapi.VirtualMachineStatusDetails {
+
+	details := &vmopapi.VirtualMachineStatusDetails{}
+	if prevDetails != nil {
+		details.GuestOSFullName = prevDetails.GuestOSFullName
+		details.GuestOSFamily = prevDetails.GuestOSFamily
+		details.ToolsVersion = prevDetails.ToolsVersion
+		details.ToolsVersionStatus = prevDetails.ToolsVersionStatus
+	}
+
+	summaryConfig := moVM.Summary.Config
+	details.NumCPUs = summaryConfig.NumCpu
+	details.MemoryMiB = summaryConfig.MemorySizeMB
+
+	guestInfo := moVM.Guest
+	if guestInfo != nil && guestInfo.ToolsRunningStatus == string(vimTypes.VirtualMachineToolsRunningStatusGuestToolsRunning) {
+		if guestInfo.GuestFullName != "" {
+			details.GuestOSFullName = guestInfo.GuestFullName
+		}
+		details.GuestOSFamily = getGuestOSFamily(guestInfo.GuestFamily)
+		details.ToolsVersion = guestInfo.ToolsVersion
+		details.ToolsVersionStatus = guestInfo.ToolsVersionStatus2
+	}
+	if details.GuestOSFullName == "" {
+		details.GuestOSFullName = summaryConfig.GuestFullName
+	}
+
+	if config := moVM.Config; config != nil {
+		details.HardwareVersion = config.Version
+		details.Disks = getVMDiskDetails(object.VirtualDeviceList(config.Hardware.Device))
+	}
+
+	return details
+}
+
+// getGuestOSFamily returns the family of the guest family reported by VMware Tools, like linuxGuest.
+func getGuestOSFamily(guestFamily string) string {
+	switch guestFamily {
+	case "":
+		return ""
+	case string(vimTypes.VirtualMachineGuestOsFamilyLinuxGuest):
+		return vmopapi.GuestOSFamilyLinux
+	case string(vimTypes.VirtualMachineGuestOsFamilyWindowsGuest):
+		return vmopapi.GuestOSFamilyWindows
+	default:
+		return vmopapi.GuestOSFamilyOther
+	}
+}
+
+func getVMDiskDetails(devices object.VirtualDeviceList) []vmopapi.VirtualMachineDiskDetails {
+	var disks []vmopapi.VirtualMachineDiskDetails
+
+	for _, device := range devices.SelectByType((*vimTypes.VirtualDisk)(nil)) {
+		disk := device.(*vimTypes.VirtualDisk)
+		diskDetails := vmopapi.VirtualMachineDiskDetails{
+			Label:         devices.Name(disk),
+			DeviceKey:     disk.Key,
+			CapacityBytes: disk.CapacityInBytes,
+			UnitNumber:    disk.UnitNumber,
+		}
+		if info := disk.DeviceInfo; info != nil && info.GetDescription() != nil {
+			diskDetails.Label = info.GetDescription().Label
+		}
+
+		if controller := devices.FindByKey(disk.ControllerKey); controller != nil {
+			diskDetails.Controller = devices.Name(controller)
+			if info := controller.GetVirtualDevice().DeviceInfo; info != nil && info.GetDescription() != nil {
+				diskDetails.Controller = info.GetDescription().Label
+			}
+		}
+
+		if backing, ok := disk.Backing.(*vimTypes.VirtualDiskFlatVer2BackingInfo); ok {
+			diskDetails.FileName = backing.FileName
+			diskDetails.Provisioning = getDiskProvisioning(backing)
+			var dsPath object.DatastorePath
+			if dsPath.FromString(backing.FileName) {
+				diskDetails.Datastore = dsPath.Datastore
+			}
+		}
+
+		disks = append(disks, diskDetails)
+	}
+
+	return disks
+}
+
+func getDiskProvisioning(backing *vimTypes.VirtualDiskFlatVer2BackingInfo) string {
+	switch {
+	case backing.ThinProvisioned != nil && *backing.ThinProvisioned:
+		return string(vimTypes.OvfCreateImportSpecParamsDiskProvisioningTypeThin)
+	case backing.EagerlyScrub != nil && *backing.EagerlyScrub:
+		return string(vimTypes.OvfCreateImportSpecParamsDiskProvisioningTypeEagerZeroedThick)
+	default:
+		return string(vimTypes.OvfCreateImportSpecParamsDiskProvisioningTypeThick)
 	}
 }
 
+// SetVMStatusDetailsAnnotation sets the JSON status details in the annotations of the VM. The annotation is not
+// updated when it already has the same details, so that the VM is not patched for an unchanged annotation.
+func SetVMStatusDetailsAnnotation(vm *v1alpha1.VirtualMachine, details *vmopapi.VirtualMachineStatusDetails) error {
+	if curDetails, err := vmopapi.GetVirtualMachineStatusDetails(vm); err == nil && curDetails != nil &&
+		apiequality.Semantic.DeepEqual(curDetails, details) {
+		return nil
+	}
+
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	if vm.Annotations == nil {
+		vm.Annotations = map[string]string{}
+	}
+	vm.Annotations[vmopapi.VirtualMachineStatusDetailsAnnotation] = string(data)
+
+	return nil
+}
+
+// GetVMRecentTasks returns the most recent completed tasks of the VM, newest first. The completed tasks among the
+// recent tasks of the VM are merged with the previous tasks, since vCenter drops the tasks from the recent tasks
+// of the VM shortly after they complete.
+func GetVMRecentTasks(
+	tasks []mo.Task,
+	prevTasks []vmopapi.VirtualMachineTaskDetails) []vmopapi.VirtualMachineTaskDetails {
+
+	// The times are truncated to the second precision they are stored at in the annotation, so that unchanged
+	// tasks are equal to the previous tasks.
+	recentTasks := make([]vmopapi.VirtualMachineTaskDetails, 0, len(prevTasks)+len(tasks))
+	seen := map[string]struct{}{}
+	for _, task := range tasks {
+		info := task.Info
+		if info.CompleteTime == nil ||
+			(info.State != vimTypes.TaskInfoStateSuccess && info.State != vimTypes.TaskInfoStateError) {
+			continue
+		}
+
+		details := vmopapi.VirtualMachineTaskDetails{
+			Key:          info.Key,
+			Name:         info.DescriptionId,
+			State:        string(info.State),
+			CompleteTime: metav1.NewTime(info.CompleteTime.Truncate(time.Second)),
+		}
+		if info.StartTime != nil {
+			startTime := metav1.NewTime(info.StartTime.Truncate(time.Second))
+			details.StartTime = &startTime
+		}
+		if info.Error != nil {
+			details.Error = info.Error.LocalizedMessage
+		}
+		recentTasks = append(recentTasks, details)
+		seen[info.Key] = struct{}{}
+	}
+
+	for _, task := range prevTasks {
+		if _, ok := seen[task.Key]; !ok {
+			recentTasks = append(recentTasks, task)
+		}
+	}
+
+	sort.SliceStable(recentTasks, func(i, j int) bool {
+		ti, tj := recentTasks[i].CompleteTime, recentTasks[j].CompleteTime
+		if !ti.Equal(&tj) {
+			return tj.Before(&ti)
+		}
+		return recentTasks[i].Key > recentTasks[j].Key
+	})
+	if len(recentTasks) > vmopapi.VirtualMachineRecentTasksMaxTasks {
+		recentTasks = recentTasks[:vmopapi.VirtualMachineRecentTasksMaxTasks]
+	}
+
+	return recentTasks
+}
+
+// SetVMRecentTasksAnnotation sets the JSON recent tasks in the annotations of the VM. Like the status details, the
+// annotation is not updated when it already has the same tasks.
+func SetVMRecentTasksAnnotation(vm *v1alpha1.VirtualMachine, tasks []vmopapi.VirtualMachineTaskDetails) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	if curTasks, err := vmopapi.GetVirtualMachineRecentTasks(vm); err == nil &&
+		apiequality.Semantic.DeepEqual(curTasks, tasks) {
+		return nil
+	}
+
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	}
+
+	if vm.Annotations == nil {
+		vm.Annotations = map[string]string{}
+	}
+	vm.Annotations[vmopapi.VirtualMachineRecentTasksAnnotation] = string(data)
+
+	return nil
+}
+
 func (s *Session) updateVMStatus(
 	vmCtx context.VirtualMachineContext,
 	resVM *res.VirtualMachine) error {
 
 	// TODO: We could be smarter about not re-fetching the config: if we didn't do a
 	// reconfigure or power change, the prior config is still entirely valid.
-	moVM, err := resVM.GetProperties(vmCtx, []string{"config.changeTrackingEnabled", "guest", "summary"})
+	moVM, tasks, err := s.getVMStatusProperties(vmCtx, resVM)
 	if err != nil {
 		// Leave the current Status unchanged.
 		return err
@@ -136,12 +431,27 @@ func (s *Session) updateVMStatus(
 
 	MarkCustomizationInfoCondition(vm, guestInfo)
 	MarkVMToolsRunningStatusCondition(vm, guestInfo)
+	MarkSnapshotCondition(vm, moVM.Snapshot)
 
 	if config := moVM.Config; config != nil {
 		vm.Status.ChangeBlockTracking = config.ChangeTrackingEnabled
+		UpdateVsphereVolumesStatus(vm, config.Hardware.Device)
+		MarkVolumePlacementCondition(vm, config.Hardware.Device)
 	} else {
 		vm.Status.ChangeBlockTracking = nil
 	}
 
+	// Invalid previous details are replaced.
+	prevDetails, _ := vmopapi.GetVirtualMachineStatusDetails(vm)
+	if err := SetVMStatusDetailsAnnotation(vm, GetVMStatusDetails(moVM, prevDetails)); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Invalid previous tasks are replaced.
+	prevTasks, _ := vmopapi.GetVirtualMachineRecentTasks(vm)
+	if err := SetVMRecentTasksAnnotation(vm, GetVMRecentTasks(tasks, prevTasks)); err != nil {
+		errs = append(errs, err)
+	}
+
 	return k8serrors.NewAggregate(errs)
 }
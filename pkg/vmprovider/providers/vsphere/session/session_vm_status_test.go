@@ -6,12 +6,19 @@
 package session_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/vim25/mo"
 	vimTypes "github.com/vmware/govmomi/vim25/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/conditions"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/session"
 )
@@ -229,6 +236,31 @@ var _ = Describe("VSphere Customization Status to VM Status Condition", func() {
 				Expect(vm.Status.Conditions).To(conditions.MatchConditions(expectedConditions))
 			})
 		})
+		Context("customizationInfo failed with recorded attempts", func() {
+			BeforeEach(func() {
+				vm.Annotations = map[string]string{vmopapi.CustomizationAttemptsAnnotation: "2"}
+				guestInfo.CustomizationInfo.CustomizationStatus = string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_FAILED)
+				guestInfo.CustomizationInfo.ErrorMsg = "some error message"
+			})
+			It("sets condition false with the attempt", func() {
+				expectedConditions := vmopv1alpha1.Conditions{
+					*conditions.FalseCondition(vmopv1alpha1.GuestCustomizationCondition, vmopv1alpha1.GuestCustomizationFailedReason, vmopv1alpha1.ConditionSeverityError, "some error message (customization attempt 2)"),
+				}
+				Expect(vm.Status.Conditions).To(conditions.MatchConditions(expectedConditions))
+			})
+		})
+		Context("customizationInfo pending with recorded attempts", func() {
+			BeforeEach(func() {
+				vm.Annotations = map[string]string{vmopapi.CustomizationAttemptsAnnotation: "3"}
+				guestInfo.CustomizationInfo.CustomizationStatus = string(vimTypes.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_PENDING)
+			})
+			It("sets condition false with the attempt", func() {
+				expectedConditions := vmopv1alpha1.Conditions{
+					*conditions.FalseCondition(vmopv1alpha1.GuestCustomizationCondition, vmopv1alpha1.GuestCustomizationPendingReason, vmopv1alpha1.ConditionSeverityInfo, "Customization attempt 3"),
+				}
+				Expect(vm.Status.Conditions).To(conditions.MatchConditions(expectedConditions))
+			})
+		})
 		Context("customizationInfo invalid", func() {
 			BeforeEach(func() {
 				guestInfo.CustomizationInfo.CustomizationStatus = "asdf"
@@ -243,3 +275,225 @@ var _ = Describe("VSphere Customization Status to VM Status Condition", func() {
 		})
 	})
 })
+
+var _ = Describe("VM Status Details", func() {
+	Context("GetVMStatusDetails", func() {
+		var (
+			moVM *mo.VirtualMachine
+		)
+
+		BeforeEach(func() {
+			unitNumber := int32(0)
+			moVM = &mo.VirtualMachine{
+				Summary: vimTypes.VirtualMachineSummary{
+					Config: vimTypes.VirtualMachineConfigSummary{
+						GuestFullName: "Other Linux (64-bit)",
+						NumCpu:        2,
+						MemorySizeMB:  4096,
+					},
+				},
+				Guest: &vimTypes.GuestInfo{
+					GuestFullName:       "Ubuntu Linux (64-bit)",
+					GuestFamily:         string(vimTypes.VirtualMachineGuestOsFamilyLinuxGuest),
+					ToolsVersion:        "11333",
+					ToolsVersionStatus2: string(vimTypes.VirtualMachineToolsVersionStatusGuestToolsCurrent),
+					ToolsRunningStatus:  string(vimTypes.VirtualMachineToolsRunningStatusGuestToolsRunning),
+				},
+				Config: &vimTypes.VirtualMachineConfigInfo{
+					Version: "vmx-19",
+					Hardware: vimTypes.VirtualHardware{
+						Device: []vimTypes.BaseVirtualDevice{
+							&vimTypes.ParaVirtualSCSIController{
+								VirtualSCSIController: vimTypes.VirtualSCSIController{
+									VirtualController: vimTypes.VirtualController{
+										VirtualDevice: vimTypes.VirtualDevice{
+											Key:        1000,
+											DeviceInfo: &vimTypes.Description{Label: "SCSI controller 0"},
+										},
+									},
+								},
+							},
+							&vimTypes.VirtualDisk{
+								VirtualDevice: vimTypes.VirtualDevice{
+									Key:           2000,
+									DeviceInfo:    &vimTypes.Description{Label: "Hard disk 1"},
+									ControllerKey: 1000,
+									UnitNumber:    &unitNumber,
+									Backing: &vimTypes.VirtualDiskFlatVer2BackingInfo{
+										VirtualDeviceFileBackingInfo: vimTypes.VirtualDeviceFileBackingInfo{
+											FileName: "[datastore1] vm/vm.vmdk",
+										},
+										ThinProvisioned: vimTypes.NewBool(true),
+									},
+								},
+								CapacityInBytes: 10 * 1024 * 1024 * 1024,
+							},
+						},
+					},
+				},
+			}
+		})
+
+		It("returns the hardware, disks and guest OS of the VM", func() {
+			details := session.GetVMStatusDetails(moVM, nil)
+			Expect(details.GuestOSFullName).To(Equal("Ubuntu Linux (64-bit)"))
+			Expect(details.GuestOSFamily).To(Equal(vmopapi.GuestOSFamilyLinux))
+			Expect(details.ToolsVersion).To(Equal("11333"))
+			Expect(details.ToolsVersionStatus).To(Equal("guestToolsCurrent"))
+			Expect(details.HardwareVersion).To(Equal("vmx-19"))
+			Expect(details.NumCPUs).To(BeEquivalentTo(2))
+			Expect(details.MemoryMiB).To(BeEquivalentTo(4096))
+
+			Expect(details.Disks).To(HaveLen(1))
+			disk := details.Disks[0]
+			Expect(disk.Label).To(Equal("Hard disk 1"))
+			Expect(disk.Datastore).To(Equal("datastore1"))
+			Expect(disk.CapacityBytes).To(BeEquivalentTo(10 * 1024 * 1024 * 1024))
+			Expect(disk.Provisioning).To(Equal("thin"))
+			Expect(disk.Controller).To(Equal("SCSI controller 0"))
+			Expect(disk.UnitNumber).ToNot(BeNil())
+			Expect(*disk.UnitNumber).To(BeEquivalentTo(0))
+		})
+
+		It("uses the configured guest OS when the guest was never running", func() {
+			moVM.Guest = nil
+			details := session.GetVMStatusDetails(moVM, nil)
+			Expect(details.GuestOSFullName).To(Equal("Other Linux (64-bit)"))
+			Expect(details.GuestOSFamily).To(BeEmpty())
+		})
+
+		It("keeps the previous guest OS while VMware Tools are not running", func() {
+			prevDetails := session.GetVMStatusDetails(moVM, nil)
+			moVM.Guest = &vimTypes.GuestInfo{
+				ToolsRunningStatus: string(vimTypes.VirtualMachineToolsRunningStatusGuestToolsNotRunning),
+			}
+
+			details := session.GetVMStatusDetails(moVM, prevDetails)
+			Expect(details).To(Equal(prevDetails))
+		})
+
+		It("sets the details in the annotations of the VM", func() {
+			vm := &vmopv1alpha1.VirtualMachine{}
+			Expect(session.SetVMStatusDetailsAnnotation(vm, session.GetVMStatusDetails(moVM, nil))).To(Succeed())
+
+			details, err := vmopapi.GetVirtualMachineStatusDetails(vm)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(details).ToNot(BeNil())
+			Expect(details.HardwareVersion).To(Equal("vmx-19"))
+		})
+
+		It("does not update the annotation when the details did not change", func() {
+			vm := &vmopv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						vmopapi.VirtualMachineStatusDetailsAnnotation: `{"hardwareVersion": "vmx-19", "numCPUs": 2}`,
+					},
+				},
+			}
+			details := &vmopapi.VirtualMachineStatusDetails{HardwareVersion: "vmx-19", NumCPUs: 2}
+
+			Expect(session.SetVMStatusDetailsAnnotation(vm, details)).To(Succeed())
+			Expect(vm.Annotations).To(HaveKeyWithValue(vmopapi.VirtualMachineStatusDetailsAnnotation,
+				`{"hardwareVersion": "vmx-19", "numCPUs": 2}`))
+
+			details.NumCPUs = 4
+			Expect(session.SetVMStatusDetailsAnnotation(vm, details)).To(Succeed())
+			Expect(vm.Annotations).To(HaveKeyWithValue(vmopapi.VirtualMachineStatusDetailsAnnotation,
+				`{"hardwareVersion":"vmx-19","numCPUs":4}`))
+		})
+	})
+
+	Context("GetVMRecentTasks", func() {
+		var (
+			now   time.Time
+			tasks []mo.Task
+		)
+
+		BeforeEach(func() {
+			now = time.Now()
+			earlier := now.Add(-time.Minute)
+			tasks = []mo.Task{
+				{
+					Info: vimTypes.TaskInfo{
+						Key:           "task-1",
+						DescriptionId: "VirtualMachine.powerOn",
+						State:         vimTypes.TaskInfoStateSuccess,
+						StartTime:     &earlier,
+						CompleteTime:  &earlier,
+					},
+				},
+				{
+					Info: vimTypes.TaskInfo{
+						Key:           "task-2",
+						DescriptionId: "VirtualMachine.reconfigure",
+						State:         vimTypes.TaskInfoStateError,
+						StartTime:     &now,
+						CompleteTime:  &now,
+						Error:         &vimTypes.LocalizedMethodFault{LocalizedMessage: "reconfigure failed"},
+					},
+				},
+				{
+					Info: vimTypes.TaskInfo{
+						Key:           "task-3",
+						DescriptionId: "VirtualMachine.relocate",
+						State:         vimTypes.TaskInfoStateRunning,
+						StartTime:     &now,
+					},
+				},
+			}
+		})
+
+		It("returns the completed tasks newest first with their errors", func() {
+			recentTasks := session.GetVMRecentTasks(tasks, nil)
+			Expect(recentTasks).To(HaveLen(2))
+			Expect(recentTasks[0].Key).To(Equal("task-2"))
+			Expect(recentTasks[0].Name).To(Equal("VirtualMachine.reconfigure"))
+			Expect(recentTasks[0].State).To(Equal("error"))
+			Expect(recentTasks[0].Error).To(Equal("reconfigure failed"))
+			Expect(recentTasks[1].Key).To(Equal("task-1"))
+			Expect(recentTasks[1].State).To(Equal("success"))
+			Expect(recentTasks[1].Error).To(BeEmpty())
+		})
+
+		It("keeps the previous tasks that are no longer recent tasks of the VM", func() {
+			prevTasks := session.GetVMRecentTasks(tasks[:1], nil)
+			recentTasks := session.GetVMRecentTasks(tasks[1:], prevTasks)
+			Expect(recentTasks).To(HaveLen(2))
+			Expect(recentTasks[0].Key).To(Equal("task-2"))
+			Expect(recentTasks[1]).To(Equal(prevTasks[0]))
+		})
+
+		It("keeps at most the maximum number of tasks", func() {
+			var prevTasks []vmopapi.VirtualMachineTaskDetails
+			for i := 0; i < vmopapi.VirtualMachineRecentTasksMaxTasks; i++ {
+				prevTasks = append(prevTasks, vmopapi.VirtualMachineTaskDetails{
+					Key:          fmt.Sprintf("task-old-%d", i),
+					State:        "success",
+					CompleteTime: metav1.NewTime(now.Add(-time.Hour)),
+				})
+			}
+
+			recentTasks := session.GetVMRecentTasks(tasks, prevTasks)
+			Expect(recentTasks).To(HaveLen(vmopapi.VirtualMachineRecentTasksMaxTasks))
+			Expect(recentTasks[0].Key).To(Equal("task-2"))
+			Expect(recentTasks[1].Key).To(Equal("task-1"))
+		})
+
+		It("does not update the annotation when the tasks did not change", func() {
+			data, err := json.MarshalIndent(session.GetVMRecentTasks(tasks, nil), "", "  ")
+			Expect(err).ToNot(HaveOccurred())
+			vm := &vmopv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						vmopapi.VirtualMachineRecentTasksAnnotation: string(data),
+					},
+				},
+			}
+			prevTasks, err := vmopapi.GetVirtualMachineRecentTasks(vm)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(session.SetVMRecentTasksAnnotation(vm, session.GetVMRecentTasks(tasks, prevTasks))).To(Succeed())
+			Expect(vm.Annotations).To(HaveKeyWithValue(vmopapi.VirtualMachineRecentTasksAnnotation, string(data)))
+		})
+	})
+})
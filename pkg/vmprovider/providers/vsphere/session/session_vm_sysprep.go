@@ -0,0 +1,137 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+)
+
+const (
+	defaultSysprepFullName  = "vmoperator"
+	defaultSysprepOrgName   = "vmoperator"
+	defaultSysprepWorkgroup = "WORKGROUP"
+
+	// defaultSysprepTimeZone is the Microsoft time zone index of (GMT) Greenwich Mean Time.
+	defaultSysprepTimeZone = 85
+
+	// maxWindowsComputerNameLen is the maximum length of a Windows (NetBIOS) computer name.
+	maxWindowsComputerNameLen = 15
+)
+
+// IsWindowsGuest returns true if the guest OS of the VM is Windows, as per the OS type of its image, or
+// the guest ID of the VM when the image does not have an OS type.
+func IsWindowsGuest(image *v1alpha1.VirtualMachineImage, config *vimTypes.VirtualMachineConfigInfo) bool {
+	var guestID string
+	if image != nil {
+		guestID = image.Spec.OSInfo.Type
+	}
+	if guestID == "" && config != nil {
+		guestID = config.GuestId
+	}
+
+	// All the vSphere guest IDs of Windows start with "win".
+	return strings.HasPrefix(strings.ToLower(guestID), "win")
+}
+
+// GetSysprepCustSpec returns the Sysprep CustomizationSpec of a Windows VM. The unattend.xml answer file in
+// the VM metadata is used as is when present. Otherwise, the answer file is built from the Sysprep keys of
+// the VM metadata, and the credentials in the Sysprep Secret data.
+func GetSysprepCustSpec(
+	vmName string,
+	updateArgs VMUpdateArgs) (*vimTypes.CustomizationSpec, error) {
+
+	data := updateArgs.VMMetadata.Data
+	secretData := updateArgs.SysprepSecretData
+
+	custSpec := &vimTypes.CustomizationSpec{
+		GlobalIPSettings: vimTypes.CustomizationGlobalIPSettings{
+			DnsServerList: updateArgs.DNSServers,
+		},
+		NicSettingMap: updateArgs.NetIfList.GetInterfaceCustomizations(),
+	}
+
+	if unattend := data[vmopapi.SysprepUnattendKey]; unattend != "" {
+		custSpec.Identity = &vimTypes.CustomizationSysprepText{
+			Value: unattend,
+		}
+		return custSpec, nil
+	}
+
+	timeZone := defaultSysprepTimeZone
+	if tz := data[vmopapi.SysprepTimeZoneKey]; tz != "" {
+		var err error
+		if timeZone, err = strconv.Atoi(tz); err != nil {
+			return nil, errors.Wrapf(err, "invalid Sysprep time zone %q", tz)
+		}
+	}
+
+	sysprep := &vimTypes.CustomizationSysprep{
+		GuiUnattended: vimTypes.CustomizationGuiUnattended{
+			TimeZone: int32(timeZone),
+		},
+		UserData: vimTypes.CustomizationUserData{
+			FullName:  valueOrDefault(data[vmopapi.SysprepFullNameKey], defaultSysprepFullName),
+			OrgName:   valueOrDefault(data[vmopapi.SysprepOrgNameKey], defaultSysprepOrgName),
+			ProductId: data[vmopapi.SysprepProductIDKey],
+			ComputerName: &vimTypes.CustomizationFixedName{
+				Name: windowsComputerName(vmName),
+			},
+		},
+	}
+
+	if password := secretData[vmopapi.SysprepAdminPasswordKey]; password != "" {
+		sysprep.GuiUnattended.Password = &vimTypes.CustomizationPassword{
+			Value:     password,
+			PlainText: true,
+		}
+	}
+
+	if domain := data[vmopapi.SysprepDomainKey]; domain != "" {
+		domainAdmin := secretData[vmopapi.SysprepDomainAdminKey]
+		domainAdminPassword := secretData[vmopapi.SysprepDomainAdminPasswordKey]
+		if domainAdmin == "" || domainAdminPassword == "" {
+			return nil, errors.Errorf("joining domain %s requires the %s and %s keys in the Secret of the %s annotation",
+				domain, vmopapi.SysprepDomainAdminKey, vmopapi.SysprepDomainAdminPasswordKey, vmopapi.SysprepSecretAnnotation)
+		}
+
+		sysprep.Identification = vimTypes.CustomizationIdentification{
+			JoinDomain:  domain,
+			DomainAdmin: domainAdmin,
+			DomainAdminPassword: &vimTypes.CustomizationPassword{
+				Value:     domainAdminPassword,
+				PlainText: true,
+			},
+		}
+	} else {
+		sysprep.Identification = vimTypes.CustomizationIdentification{
+			JoinWorkgroup: valueOrDefault(data[vmopapi.SysprepWorkgroupKey], defaultSysprepWorkgroup),
+		}
+	}
+
+	custSpec.Identity = sysprep
+	return custSpec, nil
+}
+
+// windowsComputerName returns the VM name shortened to the maximum length of a Windows computer name.
+func windowsComputerName(vmName string) string {
+	if len(vmName) <= maxWindowsComputerNameLen {
+		return vmName
+	}
+	return strings.TrimRight(vmName[:maxWindowsComputerNameLen], "-.")
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,238 @@
+// +build !integration
+
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	goctx "context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/network"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/session"
+)
+
+var _ = Describe("IsWindowsGuest", func() {
+	var (
+		image  *vmopv1alpha1.VirtualMachineImage
+		config *vimTypes.VirtualMachineConfigInfo
+	)
+
+	BeforeEach(func() {
+		image = &vmopv1alpha1.VirtualMachineImage{}
+		config = &vimTypes.VirtualMachineConfigInfo{}
+	})
+
+	It("is true when the image OS type is Windows", func() {
+		image.Spec.OSInfo.Type = "windows2019srv_64Guest"
+		Expect(session.IsWindowsGuest(image, config)).To(BeTrue())
+	})
+
+	It("is false when the image OS type is Linux", func() {
+		image.Spec.OSInfo.Type = "ubuntu64Guest"
+		config.GuestId = "windows2019srv_64Guest"
+		Expect(session.IsWindowsGuest(image, config)).To(BeFalse())
+	})
+
+	It("uses the guest ID of the VM when the image does not have an OS type", func() {
+		config.GuestId = "windows9Server64Guest"
+		Expect(session.IsWindowsGuest(nil, config)).To(BeTrue())
+		Expect(session.IsWindowsGuest(image, config)).To(BeTrue())
+	})
+})
+
+var _ = Describe("GetSysprepCustSpec", func() {
+	var (
+		updateArgs session.VMUpdateArgs
+		vmName     string
+		custSpec   *vimTypes.CustomizationSpec
+		err        error
+
+		adapterMapping = vimTypes.CustomizationAdapterMapping{MacAddress: "01-23-45-67-89-AB-CD-EF"}
+	)
+
+	BeforeEach(func() {
+		vmName = "dummy-vm"
+		updateArgs = session.VMUpdateArgs{
+			VMConfigArgs: vmprovider.VMConfigArgs{
+				VMMetadata: vmprovider.VMMetadata{
+					Data: map[string]string{},
+				},
+				SysprepSecretData: map[string]string{
+					vmopapi.SysprepAdminPasswordKey: "admin-pass",
+				},
+			},
+			DNSServers: []string{"8.8.8.8"},
+			NetIfList: []network.InterfaceInfo{
+				{Customization: &adapterMapping},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		custSpec, err = session.GetSysprepCustSpec(vmName, updateArgs)
+	})
+
+	Context("Without metadata", func() {
+		It("returns a Sysprep spec that joins the default workgroup", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(custSpec.GlobalIPSettings.DnsServerList).To(Equal(updateArgs.DNSServers))
+			Expect(custSpec.NicSettingMap).To(Equal([]vimTypes.CustomizationAdapterMapping{adapterMapping}))
+
+			sysprep, ok := custSpec.Identity.(*vimTypes.CustomizationSysprep)
+			Expect(ok).To(BeTrue())
+			Expect(sysprep.UserData.ComputerName.(*vimTypes.CustomizationFixedName).Name).To(Equal(vmName))
+			Expect(sysprep.UserData.FullName).ToNot(BeEmpty())
+			Expect(sysprep.UserData.OrgName).ToNot(BeEmpty())
+			Expect(sysprep.GuiUnattended.Password).ToNot(BeNil())
+			Expect(sysprep.GuiUnattended.Password.Value).To(Equal("admin-pass"))
+			Expect(sysprep.GuiUnattended.Password.PlainText).To(BeTrue())
+			Expect(sysprep.Identification.JoinWorkgroup).To(Equal("WORKGROUP"))
+			Expect(sysprep.Identification.JoinDomain).To(BeEmpty())
+		})
+	})
+
+	Context("With a long VM name", func() {
+		BeforeEach(func() {
+			vmName = "a-very-long-windows-vm-name"
+		})
+
+		It("shortens the computer name", func() {
+			Expect(err).ToNot(HaveOccurred())
+			sysprep := custSpec.Identity.(*vimTypes.CustomizationSysprep)
+			Expect(sysprep.UserData.ComputerName.(*vimTypes.CustomizationFixedName).Name).To(Equal("a-very-long-win"))
+		})
+	})
+
+	Context("With metadata", func() {
+		BeforeEach(func() {
+			updateArgs.VMMetadata.Data = map[string]string{
+				vmopapi.SysprepFullNameKey:  "Jane Doe",
+				vmopapi.SysprepOrgNameKey:   "Example",
+				vmopapi.SysprepProductIDKey: "AAAAA-BBBBB-CCCCC-DDDDD-EEEEE",
+				vmopapi.SysprepTimeZoneKey:  "4",
+				vmopapi.SysprepWorkgroupKey: "EXAMPLE",
+			}
+		})
+
+		It("returns a Sysprep spec with the metadata", func() {
+			Expect(err).ToNot(HaveOccurred())
+			sysprep := custSpec.Identity.(*vimTypes.CustomizationSysprep)
+			Expect(sysprep.UserData.FullName).To(Equal("Jane Doe"))
+			Expect(sysprep.UserData.OrgName).To(Equal("Example"))
+			Expect(sysprep.UserData.ProductId).To(Equal("AAAAA-BBBBB-CCCCC-DDDDD-EEEEE"))
+			Expect(sysprep.GuiUnattended.TimeZone).To(BeEquivalentTo(4))
+			Expect(sysprep.Identification.JoinWorkgroup).To(Equal("EXAMPLE"))
+		})
+
+		Context("With an invalid time zone", func() {
+			BeforeEach(func() {
+				updateArgs.VMMetadata.Data[vmopapi.SysprepTimeZoneKey] = "Pacific"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Context("Joining a domain", func() {
+		BeforeEach(func() {
+			updateArgs.VMMetadata.Data[vmopapi.SysprepDomainKey] = "example.com"
+		})
+
+		It("returns an error without the domain credentials", func() {
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(vmopapi.SysprepDomainAdminKey))
+		})
+
+		Context("With the domain credentials", func() {
+			BeforeEach(func() {
+				updateArgs.SysprepSecretData[vmopapi.SysprepDomainAdminKey] = "joiner"
+				updateArgs.SysprepSecretData[vmopapi.SysprepDomainAdminPasswordKey] = "joiner-pass"
+			})
+
+			It("returns a Sysprep spec that joins the domain", func() {
+				Expect(err).ToNot(HaveOccurred())
+				sysprep := custSpec.Identity.(*vimTypes.CustomizationSysprep)
+				Expect(sysprep.Identification.JoinDomain).To(Equal("example.com"))
+				Expect(sysprep.Identification.DomainAdmin).To(Equal("joiner"))
+				Expect(sysprep.Identification.DomainAdminPassword.Value).To(Equal("joiner-pass"))
+				Expect(sysprep.Identification.JoinWorkgroup).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("With an unattend.xml", func() {
+		BeforeEach(func() {
+			updateArgs.VMMetadata.Data[vmopapi.SysprepUnattendKey] = "<unattend></unattend>"
+			updateArgs.VMMetadata.Data[vmopapi.SysprepDomainKey] = "example.com"
+		})
+
+		It("returns a Sysprep text spec with the unattend.xml", func() {
+			Expect(err).ToNot(HaveOccurred())
+			sysprepText, ok := custSpec.Identity.(*vimTypes.CustomizationSysprepText)
+			Expect(ok).To(BeTrue())
+			Expect(sysprepText.Value).To(Equal("<unattend></unattend>"))
+			Expect(custSpec.NicSettingMap).To(Equal([]vimTypes.CustomizationAdapterMapping{adapterMapping}))
+		})
+	})
+
+	Context("Customizing a vcsim VM", func() {
+		It("sets the computer name of the guest", func() {
+			res := simulator.VPX().Run(func(ctx goctx.Context, c *vim25.Client) error {
+				vm, err := find.NewFinder(c).VirtualMachine(ctx, "DC0_H0_VM0")
+				Expect(err).ToNot(HaveOccurred())
+
+				task, err := vm.PowerOff(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(task.Wait(ctx)).To(Succeed())
+
+				devices, err := vm.Device(ctx)
+				Expect(err).ToNot(HaveOccurred())
+
+				args := updateArgs
+				args.NetIfList = nil
+				for range devices.SelectByType((*vimTypes.VirtualEthernetCard)(nil)) {
+					args.NetIfList = append(args.NetIfList, network.InterfaceInfo{
+						Customization: &vimTypes.CustomizationAdapterMapping{
+							Adapter: vimTypes.CustomizationIPSettings{Ip: &vimTypes.CustomizationDhcpIpGenerator{}},
+						},
+					})
+				}
+
+				custSpec, err := session.GetSysprepCustSpec("windows-vm", args)
+				Expect(err).ToNot(HaveOccurred())
+
+				task, err = vm.Customize(ctx, *custSpec)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(task.Wait(ctx)).To(Succeed())
+
+				task, err = vm.PowerOn(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(task.Wait(ctx)).To(Succeed())
+
+				var moVM mo.VirtualMachine
+				Expect(vm.Properties(ctx, vm.Reference(), []string{"guest"}, &moVM)).To(Succeed())
+				Expect(moVM.Guest).ToNot(BeNil())
+				Expect(moVM.Guest.HostName).To(Equal("windows-vm"))
+
+				return nil
+			})
+			Expect(res).To(Succeed())
+		})
+	})
+})
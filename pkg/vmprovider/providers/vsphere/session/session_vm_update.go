@@ -312,6 +312,12 @@ func UpdateConfigSpecExtraConfig(
 	vm *v1alpha1.VirtualMachine,
 	globalExtraConfig map[string]string) {
 
+	// An imported VM may not have an image, in which case the template is rendered with an empty status.
+	var imageStatus v1alpha1.VirtualMachineImageStatus
+	if vmImage != nil {
+		imageStatus = vmImage.Status
+	}
+
 	// The only use of this is for the global JSON_EXTRA_CONFIG to set the image name.
 	renderTemplateFn := func(name, text string) string {
 		t, err := template.New(name).Parse(text)
@@ -319,7 +325,7 @@ func UpdateConfigSpecExtraConfig(
 			return text
 		}
 		b := strings.Builder{}
-		if err := t.Execute(&b, vmImage.Status); err != nil {
+		if err := t.Execute(&b, imageStatus); err != nil {
 			return text
 		}
 		return b.String()
@@ -344,7 +350,7 @@ func UpdateConfigSpecExtraConfig(
 
 	configSpec.ExtraConfig = MergeExtraConfig(config.ExtraConfig, extraConfig)
 
-	if conditions.IsTrue(vmImage, v1alpha1.VirtualMachineImageV1Alpha1CompatibleCondition) {
+	if vmImage != nil && conditions.IsTrue(vmImage, v1alpha1.VirtualMachineImageV1Alpha1CompatibleCondition) {
 		ecMap := ExtraConfigToMap(config.ExtraConfig)
 		if ecMap[constants.VMOperatorV1Alpha1ExtraConfigKey] == constants.VMOperatorV1Alpha1ConfigReady {
 			// Set VMOperatorV1Alpha1ExtraConfigKey for v1alpha1 VirtualMachineImage compatibility.
@@ -461,6 +467,12 @@ func (s *Session) prePowerOnVMConfigSpec(
 	}
 	configSpec.DeviceChange = append(configSpec.DeviceChange, diskDeviceChanges...)
 
+	placementDeviceChanges, err := UpdateVolumePlacementDeviceChanges(vmCtx.VM, virtualDevices, diskDeviceChanges)
+	if err != nil {
+		return nil, err
+	}
+	configSpec.DeviceChange = append(configSpec.DeviceChange, placementDeviceChanges...)
+
 	expectedEthCards := updateArgs.NetIfList.GetVirtualDeviceList()
 	ethCardDeviceChanges, err := UpdateEthCardDeviceChanges(expectedEthCards, currentEthCards)
 	if err != nil {
@@ -582,8 +594,10 @@ func (s *Session) ensureCNSVolumes(vmCtx context.VirtualMachineContext) error {
 
 type VMUpdateArgs struct {
 	vmprovider.VMConfigArgs
-	NetIfList  network.InterfaceInfoList
-	DNSServers []string
+	NetIfList     network.InterfaceInfoList
+	DNSServers    []string
+	SearchDomains []string
+	NTPServers    []string
 }
 
 func (s *Session) prepareVMForPowerOn(
@@ -610,10 +624,17 @@ func (s *Session) prepareVMForPowerOn(
 		// Prior code only logged?!?
 	}
 
+	searchDomains, ntpServers, err := config.GetSearchDomainsAndNTPServersFromConfigMap(s.k8sClient)
+	if err != nil {
+		vmCtx.Logger.Error(err, "Unable to get search domains and NTP servers from ConfigMap")
+	}
+
 	updateArgs := VMUpdateArgs{
-		VMConfigArgs: vmConfigArgs,
-		NetIfList:    netIfList,
-		DNSServers:   dnsServers,
+		VMConfigArgs:  vmConfigArgs,
+		NetIfList:     netIfList,
+		DNSServers:    dnsServers,
+		SearchDomains: searchDomains,
+		NTPServers:    ntpServers,
 	}
 
 	err = s.prePowerOnVMReconfigure(vmCtx, resVM, cfg, updateArgs)
@@ -724,6 +745,7 @@ func (s *Session) UpdateVirtualMachine(
 	}()
 
 	isOff := moVM.Runtime.PowerState == vimTypes.VirtualMachinePowerStatePoweredOff
+	var expandErr error
 
 	switch vmCtx.VM.Spec.PowerState {
 	case v1alpha1.VirtualMachinePoweredOff:
@@ -761,9 +783,22 @@ func (s *Session) UpdateVirtualMachine(
 			if err != nil {
 				return err
 			}
+
+			// A failure to expand the disks is set in the status of the volumes, and does not prevent
+			// the resize of the VM and the update of its tags and modules.
+			expandErr = s.expandPoweredOnVMDisks(vmCtx, resVM, config)
+
+			err = s.resizePoweredOnVM(vmCtx, resVM, config, &vmConfigArgs.VMClass.Spec)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
 	// TODO: Find a better place for this?
-	return s.attachTagsAndModules(vmCtx, resVM, vmConfigArgs.ResourcePolicy)
+	if err := s.attachTagsAndModules(vmCtx, resVM, vmConfigArgs.ResourcePolicy); err != nil {
+		return err
+	}
+
+	return expandErr
 }
@@ -419,6 +419,103 @@ var _ = Describe("Update ConfigSpec", func() {
 		})
 	})
 
+	Context("Resize", func() {
+		var vmClassSpec *vmopv1alpha1.VirtualMachineClassSpec
+
+		BeforeEach(func() {
+			config.Version = "vmx-15"
+			config.Hardware.NumCPU = 2
+			config.Hardware.MemoryMB = 2048
+			vmClassSpec = &vmopv1alpha1.VirtualMachineClassSpec{}
+			vmClassSpec.Hardware.Cpus = 2
+			vmClassSpec.Hardware.Memory = resource.MustParse("2Gi")
+		})
+
+		JustBeforeEach(func() {
+			configSpec = session.ResizeConfigSpec(config, vmClassSpec, 0)
+		})
+
+		Context("config already matches", func() {
+			It("config spec shows no changes", func() {
+				Expect(configSpec.NumCPUs).To(BeZero())
+				Expect(configSpec.MemoryMB).To(BeZero())
+				Expect(session.HotResizeUnsupportedReason(config, configSpec)).To(BeEmpty())
+			})
+		})
+
+		Context("class has other reservations and limits", func() {
+			BeforeEach(func() {
+				vmClassSpec.Policies.Resources.Requests.Memory = resource.MustParse("1Gi")
+				vmClassSpec.Policies.Resources.Limits.Memory = resource.MustParse("2Gi")
+			})
+
+			It("config spec has the reservations and limits of the class", func() {
+				Expect(configSpec.NumCPUs).To(BeZero())
+				Expect(configSpec.MemoryMB).To(BeZero())
+				Expect(configSpec.MemoryAllocation).ToNot(BeNil())
+				Expect(configSpec.MemoryAllocation.Reservation).ToNot(BeNil())
+				Expect(*configSpec.MemoryAllocation.Reservation).To(BeNumerically("==", 1024))
+				Expect(configSpec.MemoryAllocation.Limit).ToNot(BeNil())
+				Expect(*configSpec.MemoryAllocation.Limit).To(BeNumerically("==", 2048))
+				Expect(session.HotResizeUnsupportedReason(config, configSpec)).To(BeEmpty())
+			})
+		})
+
+		Context("class has more CPU and memory", func() {
+			BeforeEach(func() {
+				vmClassSpec.Hardware.Cpus = 4
+				vmClassSpec.Hardware.Memory = resource.MustParse("4Gi")
+			})
+
+			It("config spec has the CPU and memory of the class", func() {
+				Expect(configSpec.NumCPUs).To(BeNumerically("==", 4))
+				Expect(configSpec.MemoryMB).To(BeNumerically("==", 4096))
+			})
+
+			It("cannot be hot resized when hot add is not enabled", func() {
+				Expect(session.HotResizeUnsupportedReason(config, configSpec)).To(Equal("CPU hot add is not enabled"))
+				config.CpuHotAddEnabled = pointer.BoolPtr(true)
+				Expect(session.HotResizeUnsupportedReason(config, configSpec)).To(Equal("memory hot add is not enabled"))
+			})
+
+			Context("hot add is enabled", func() {
+				BeforeEach(func() {
+					config.CpuHotAddEnabled = pointer.BoolPtr(true)
+					config.MemoryHotAddEnabled = pointer.BoolPtr(true)
+				})
+
+				It("can be hot resized", func() {
+					Expect(session.HotResizeUnsupportedReason(config, configSpec)).To(BeEmpty())
+				})
+
+				It("cannot be hot resized past the hot add memory limit", func() {
+					config.HotPlugMemoryLimit = 3072
+					Expect(session.HotResizeUnsupportedReason(config, configSpec)).To(Equal("memory exceeds the hot add limit of 3072 MB"))
+				})
+
+				It("cannot be hot resized with an old hardware version", func() {
+					config.Version = "vmx-04"
+					Expect(session.HotResizeUnsupportedReason(config, configSpec)).To(Equal("hardware version vmx-04 does not support hot add"))
+				})
+			})
+		})
+
+		Context("class has less CPU and memory", func() {
+			BeforeEach(func() {
+				config.CpuHotAddEnabled = pointer.BoolPtr(true)
+				config.MemoryHotAddEnabled = pointer.BoolPtr(true)
+				vmClassSpec.Hardware.Cpus = 1
+				vmClassSpec.Hardware.Memory = resource.MustParse("1Gi")
+			})
+
+			It("cannot be hot resized", func() {
+				Expect(session.HotResizeUnsupportedReason(config, configSpec)).To(Equal("CPU hot remove is not enabled"))
+				config.CpuHotRemoveEnabled = pointer.BoolPtr(true)
+				Expect(session.HotResizeUnsupportedReason(config, configSpec)).To(Equal("memory cannot be removed from a running VM"))
+			})
+		})
+	})
+
 	Context("ChangeBlockTracking", func() {
 		var vmSpec vmopv1alpha1.VirtualMachineSpec
 
@@ -0,0 +1,303 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+)
+
+// firstNewControllerKey is the device key of the first controller added for the volume placements. Devices
+// added by a reconfigure must have a unique negative key.
+const firstNewControllerKey = int32(-300)
+
+// UpdateVolumePlacementDeviceChanges returns the device changes that add the controllers requested by the volume
+// placements of the VM, and that move the disks of the vSphere volumes to their requested controller and unit.
+// A disk that is already edited by diskDeviceChanges is moved in that edit instead of a new one. Placements of
+// PersistentVolumeClaim volumes are not supported and are rejected by the webhook, since their disks are attached
+// by the storage provider, so they are ignored here.
+func UpdateVolumePlacementDeviceChanges(
+	vm *vmopv1alpha1.VirtualMachine,
+	devices object.VirtualDeviceList,
+	diskDeviceChanges []vimTypes.BaseVirtualDeviceConfigSpec) ([]vimTypes.BaseVirtualDeviceConfigSpec, error) {
+
+	placements, err := vmopapi.GetVolumePlacements(vm)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse volume placements")
+	}
+	if len(placements) == 0 {
+		return nil, nil
+	}
+
+	editedDevices := map[int32]struct{}{}
+	for _, deviceChange := range diskDeviceChanges {
+		editedDevices[deviceChange.GetVirtualDeviceConfigSpec().Device.GetVirtualDevice().Key] = struct{}{}
+	}
+
+	var deviceChanges []vimTypes.BaseVirtualDeviceConfigSpec
+	newControllerKey := firstNewControllerKey
+
+	for _, placement := range placements {
+		disk, err := findVsphereVolumeDisk(vm, devices, placement.VolumeName)
+		if err != nil {
+			return nil, err
+		}
+		if disk == nil {
+			continue
+		}
+
+		controllerType, busNumber := placement.GetControllerType(), placement.GetBusNumber()
+
+		controller := findVolumeController(devices, controllerType, busNumber)
+		if controller == nil {
+			if isSCSIBusInUse(devices, controllerType, busNumber) {
+				return nil, errors.Errorf("volume %s cannot use SCSI bus %d since it is used by another type of controller",
+					placement.VolumeName, busNumber)
+			}
+
+			controller = newVolumeController(controllerType, busNumber, newControllerKey)
+			if controller == nil {
+				return nil, errors.Errorf("volume %s has unsupported controller type %s", placement.VolumeName, controllerType)
+			}
+			newControllerKey--
+
+			devices = append(devices, controller)
+			deviceChanges = append(deviceChanges, &vimTypes.VirtualDeviceConfigSpec{
+				Operation: vimTypes.VirtualDeviceConfigSpecOperationAdd,
+				Device:    controller,
+			})
+		}
+
+		controllerKey := controller.GetVirtualDevice().Key
+		unitNumber, err := volumePlacementUnitNumber(devices, disk, controllerKey, controllerType, placement)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to place volume %s", placement.VolumeName)
+		}
+
+		if disk.ControllerKey == controllerKey && disk.UnitNumber != nil && *disk.UnitNumber == unitNumber {
+			continue
+		}
+
+		disk.ControllerKey = controllerKey
+		disk.UnitNumber = &unitNumber
+		if _, ok := editedDevices[disk.Key]; !ok {
+			editedDevices[disk.Key] = struct{}{}
+			deviceChanges = append(deviceChanges, &vimTypes.VirtualDeviceConfigSpec{
+				Operation: vimTypes.VirtualDeviceConfigSpecOperationEdit,
+				Device:    disk,
+			})
+		}
+	}
+
+	return deviceChanges, nil
+}
+
+// volumePlacementUnitNumber returns the unit the disk is placed on: the requested unit, the current unit of the
+// disk when it is already on the controller, or else the first free unit of the controller.
+func volumePlacementUnitNumber(
+	devices object.VirtualDeviceList,
+	disk *vimTypes.VirtualDisk,
+	controllerKey int32,
+	controllerType vmopapi.VirtualMachineVolumeControllerType,
+	placement vmopapi.VirtualMachineVolumePlacement) (int32, error) {
+
+	usedUnits := map[int32]struct{}{}
+	for _, device := range devices {
+		d := device.GetVirtualDevice()
+		if d.Key != disk.Key && d.ControllerKey == controllerKey && d.UnitNumber != nil {
+			usedUnits[*d.UnitNumber] = struct{}{}
+		}
+	}
+
+	if placement.UnitNumber != nil {
+		if _, ok := usedUnits[*placement.UnitNumber]; ok {
+			return 0, errors.Errorf("unit %d of the %s controller %d is in use", *placement.UnitNumber,
+				controllerType, placement.GetBusNumber())
+		}
+		return *placement.UnitNumber, nil
+	}
+
+	if disk.ControllerKey == controllerKey && disk.UnitNumber != nil {
+		return *disk.UnitNumber, nil
+	}
+
+	maxUnitNumber := int32(vmopapi.MaxSCSIUnitNumber)
+	if controllerType == vmopapi.VirtualMachineVolumeControllerNVMe {
+		maxUnitNumber = vmopapi.MaxNVMeUnitNumber
+	}
+	for unitNumber := int32(0); unitNumber <= maxUnitNumber; unitNumber++ {
+		if controllerType != vmopapi.VirtualMachineVolumeControllerNVMe && unitNumber == vmopapi.SCSIControllerUnitNumber {
+			continue
+		}
+		if _, ok := usedUnits[unitNumber]; !ok {
+			return unitNumber, nil
+		}
+	}
+
+	return 0, errors.Errorf("the %s controller %d has no free units", controllerType, placement.GetBusNumber())
+}
+
+// findVsphereVolumeDisk returns the disk of the named volume, or nil if the volume is not a vSphere volume.
+func findVsphereVolumeDisk(
+	vm *vmopv1alpha1.VirtualMachine,
+	devices object.VirtualDeviceList,
+	volumeName string) (*vimTypes.VirtualDisk, error) {
+
+	for _, volume := range vm.Spec.Volumes {
+		if volume.Name != volumeName || volume.VsphereVolume == nil || volume.VsphereVolume.DeviceKey == nil {
+			continue
+		}
+
+		deviceKey := int32(*volume.VsphereVolume.DeviceKey)
+		if disk, ok := devices.FindByKey(deviceKey).(*vimTypes.VirtualDisk); ok {
+			return disk, nil
+		}
+		return nil, errors.Errorf("could not find volume with device key %d", deviceKey)
+	}
+
+	return nil, nil
+}
+
+func findVolumeController(
+	devices object.VirtualDeviceList,
+	controllerType vmopapi.VirtualMachineVolumeControllerType,
+	busNumber int32) vimTypes.BaseVirtualDevice {
+
+	for _, device := range devices {
+		t, ok := getVolumeControllerType(device)
+		if ok && t == controllerType && device.(vimTypes.BaseVirtualController).GetVirtualController().BusNumber == busNumber {
+			return device
+		}
+	}
+	return nil
+}
+
+// isSCSIBusInUse returns whether a SCSI controller has the bus number. All the types of SCSI controllers share
+// the same bus numbers.
+func isSCSIBusInUse(
+	devices object.VirtualDeviceList,
+	controllerType vmopapi.VirtualMachineVolumeControllerType,
+	busNumber int32) bool {
+
+	if controllerType == vmopapi.VirtualMachineVolumeControllerNVMe {
+		return false
+	}
+
+	for _, device := range devices {
+		if c, ok := device.(vimTypes.BaseVirtualSCSIController); ok && c.GetVirtualSCSIController().BusNumber == busNumber {
+			return true
+		}
+	}
+	return false
+}
+
+func getVolumeControllerType(device vimTypes.BaseVirtualDevice) (vmopapi.VirtualMachineVolumeControllerType, bool) {
+	switch device.(type) {
+	case *vimTypes.ParaVirtualSCSIController:
+		return vmopapi.VirtualMachineVolumeControllerParaVirtualSCSI, true
+	case *vimTypes.VirtualLsiLogicController:
+		return vmopapi.VirtualMachineVolumeControllerLsiLogic, true
+	case *vimTypes.VirtualNVMEController:
+		return vmopapi.VirtualMachineVolumeControllerNVMe, true
+	default:
+		return "", false
+	}
+}
+
+func newVolumeController(
+	controllerType vmopapi.VirtualMachineVolumeControllerType,
+	busNumber, key int32) vimTypes.BaseVirtualDevice {
+
+	controller := vimTypes.VirtualController{
+		BusNumber:     busNumber,
+		VirtualDevice: vimTypes.VirtualDevice{Key: key},
+	}
+
+	switch controllerType {
+	case vmopapi.VirtualMachineVolumeControllerParaVirtualSCSI:
+		return &vimTypes.ParaVirtualSCSIController{
+			VirtualSCSIController: vimTypes.VirtualSCSIController{
+				VirtualController: controller,
+				SharedBus:         vimTypes.VirtualSCSISharingNoSharing,
+			},
+		}
+	case vmopapi.VirtualMachineVolumeControllerLsiLogic:
+		return &vimTypes.VirtualLsiLogicController{
+			VirtualSCSIController: vimTypes.VirtualSCSIController{
+				VirtualController: controller,
+				SharedBus:         vimTypes.VirtualSCSISharingNoSharing,
+			},
+		}
+	case vmopapi.VirtualMachineVolumeControllerNVMe:
+		return &vimTypes.VirtualNVMEController{VirtualController: controller}
+	default:
+		return nil
+	}
+}
+
+// MarkVolumePlacementCondition reflects in the VirtualMachineVolumesPlacedCondition whether the vSphere volumes of
+// the VM are on their requested controller and unit. Volumes that do not have a disk yet are not considered. The
+// condition is removed when the VM does not have volume placements.
+func MarkVolumePlacementCondition(vm *vmopv1alpha1.VirtualMachine, devices object.VirtualDeviceList) {
+	placements, err := vmopapi.GetVolumePlacements(vm)
+	if err != nil {
+		conditions.MarkFalse(vm, vmopapi.VirtualMachineVolumesPlacedCondition, vmopapi.VirtualMachineVolumePlacementInvalidReason,
+			vmopv1alpha1.ConditionSeverityError, "failed to parse volume placements: %v", err)
+		return
+	}
+	if len(placements) == 0 {
+		conditions.Delete(vm, vmopapi.VirtualMachineVolumesPlacedCondition)
+		return
+	}
+
+	var mismatches []string
+	for _, placement := range placements {
+		disk, _ := findVsphereVolumeDisk(vm, devices, placement.VolumeName)
+		if disk == nil {
+			continue
+		}
+
+		controller := devices.FindByKey(disk.ControllerKey)
+		if controller == nil {
+			continue
+		}
+
+		controllerType, _ := getVolumeControllerType(controller)
+		var busNumber int32
+		if c, ok := controller.(vimTypes.BaseVirtualController); ok {
+			busNumber = c.GetVirtualController().BusNumber
+		}
+		var unitNumber int32
+		if disk.UnitNumber != nil {
+			unitNumber = *disk.UnitNumber
+		}
+
+		if controllerType == placement.GetControllerType() && busNumber == placement.GetBusNumber() &&
+			(placement.UnitNumber == nil || *placement.UnitNumber == unitNumber) {
+			continue
+		}
+
+		if controllerType == "" {
+			controllerType = "unsupported controller"
+		}
+		mismatches = append(mismatches, fmt.Sprintf("volume %s is on %s %d:%d", placement.VolumeName, controllerType, busNumber, unitNumber))
+	}
+
+	if len(mismatches) > 0 {
+		conditions.MarkFalse(vm, vmopapi.VirtualMachineVolumesPlacedCondition, vmopapi.VirtualMachineVolumePlacementMismatchReason,
+			vmopv1alpha1.ConditionSeverityWarning, strings.Join(mismatches, ", "))
+		return
+	}
+
+	conditions.MarkTrue(vm, vmopapi.VirtualMachineVolumesPlacedCondition)
+}
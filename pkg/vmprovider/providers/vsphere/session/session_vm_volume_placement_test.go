@@ -0,0 +1,239 @@
+// +build !integration
+
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	corev1 "k8s.io/api/core/v1"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/conditions"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/session"
+)
+
+var _ = Describe("Volume Placement", func() {
+	const (
+		controllerKey = 1000
+		diskKey       = 2000
+		pvcDiskKey    = 2001
+		pvcDiskUUID   = "6000C29a-5678"
+	)
+
+	var (
+		vm      *vmopv1alpha1.VirtualMachine
+		devices object.VirtualDeviceList
+		disk    *vimTypes.VirtualDisk
+		pvcDisk *vimTypes.VirtualDisk
+	)
+
+	BeforeEach(func() {
+		key := diskKey
+		vm = &vmopv1alpha1.VirtualMachine{
+			Spec: vmopv1alpha1.VirtualMachineSpec{
+				Volumes: []vmopv1alpha1.VirtualMachineVolume{
+					{
+						Name:          "vsphere-volume",
+						VsphereVolume: &vmopv1alpha1.VsphereVolumeSource{DeviceKey: &key},
+					},
+					{
+						Name: "pvc-volume",
+						PersistentVolumeClaim: &vmopv1alpha1.PersistentVolumeClaimVolumeSource{
+							PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: "pvc-claim",
+							},
+						},
+					},
+				},
+			},
+			Status: vmopv1alpha1.VirtualMachineStatus{
+				Volumes: []vmopv1alpha1.VirtualMachineVolumeStatus{
+					{Name: "pvc-volume", Attached: true, DiskUuid: pvcDiskUUID},
+				},
+			},
+		}
+
+		unitNumber := int32(0)
+		disk = &vimTypes.VirtualDisk{
+			VirtualDevice: vimTypes.VirtualDevice{
+				Key:           diskKey,
+				ControllerKey: controllerKey,
+				UnitNumber:    &unitNumber,
+			},
+		}
+
+		pvcUnitNumber := int32(1)
+		pvcDisk = &vimTypes.VirtualDisk{
+			VirtualDevice: vimTypes.VirtualDevice{
+				Key:           pvcDiskKey,
+				ControllerKey: controllerKey,
+				UnitNumber:    &pvcUnitNumber,
+				Backing: &vimTypes.VirtualDiskFlatVer2BackingInfo{
+					Uuid: pvcDiskUUID,
+				},
+			},
+		}
+
+		devices = object.VirtualDeviceList{
+			&vimTypes.ParaVirtualSCSIController{
+				VirtualSCSIController: vimTypes.VirtualSCSIController{
+					VirtualController: vimTypes.VirtualController{
+						VirtualDevice: vimTypes.VirtualDevice{Key: controllerKey},
+					},
+				},
+			},
+			disk,
+			pvcDisk,
+		}
+	})
+
+	Context("UpdateVolumePlacementDeviceChanges", func() {
+		It("returns no changes when the VM has no placements", func() {
+			deviceChanges, err := session.UpdateVolumePlacementDeviceChanges(vm, devices, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deviceChanges).To(BeEmpty())
+		})
+
+		It("returns an error when the placements cannot be parsed", func() {
+			vm.Annotations = map[string]string{vmopapi.VirtualMachineVolumePlacementAnnotation: "not-json"}
+			_, err := session.UpdateVolumePlacementDeviceChanges(vm, devices, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns no changes when the disk is on its requested controller", func() {
+			vm.Annotations = map[string]string{
+				vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "vsphere-volume", "unitNumber": 0}]`,
+			}
+			deviceChanges, err := session.UpdateVolumePlacementDeviceChanges(vm, devices, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deviceChanges).To(BeEmpty())
+		})
+
+		It("adds the controller and moves the disk to its first free unit", func() {
+			vm.Annotations = map[string]string{
+				vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "vsphere-volume", "controllerType": "NVMe", "busNumber": 1}]`,
+			}
+			deviceChanges, err := session.UpdateVolumePlacementDeviceChanges(vm, devices, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deviceChanges).To(HaveLen(2))
+
+			addSpec := deviceChanges[0].GetVirtualDeviceConfigSpec()
+			Expect(addSpec.Operation).To(Equal(vimTypes.VirtualDeviceConfigSpecOperationAdd))
+			controller, ok := addSpec.Device.(*vimTypes.VirtualNVMEController)
+			Expect(ok).To(BeTrue())
+			Expect(controller.BusNumber).To(BeEquivalentTo(1))
+			Expect(controller.Key).To(BeNumerically("<", 0))
+
+			editSpec := deviceChanges[1].GetVirtualDeviceConfigSpec()
+			Expect(editSpec.Operation).To(Equal(vimTypes.VirtualDeviceConfigSpecOperationEdit))
+			Expect(editSpec.Device).To(Equal(disk))
+			Expect(disk.ControllerKey).To(Equal(controller.Key))
+			Expect(disk.UnitNumber).ToNot(BeNil())
+			Expect(*disk.UnitNumber).To(BeEquivalentTo(0))
+		})
+
+		It("moves the disk to its requested unit", func() {
+			vm.Annotations = map[string]string{
+				vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "vsphere-volume", "unitNumber": 8}]`,
+			}
+			deviceChanges, err := session.UpdateVolumePlacementDeviceChanges(vm, devices, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deviceChanges).To(HaveLen(1))
+			Expect(disk.ControllerKey).To(BeEquivalentTo(controllerKey))
+			Expect(*disk.UnitNumber).To(BeEquivalentTo(8))
+		})
+
+		It("moves an already edited disk without another edit", func() {
+			vm.Annotations = map[string]string{
+				vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "vsphere-volume", "unitNumber": 8}]`,
+			}
+			diskDeviceChanges := []vimTypes.BaseVirtualDeviceConfigSpec{
+				&vimTypes.VirtualDeviceConfigSpec{
+					Operation: vimTypes.VirtualDeviceConfigSpecOperationEdit,
+					Device:    disk,
+				},
+			}
+			deviceChanges, err := session.UpdateVolumePlacementDeviceChanges(vm, devices, diskDeviceChanges)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deviceChanges).To(BeEmpty())
+			Expect(*disk.UnitNumber).To(BeEquivalentTo(8))
+		})
+
+		It("returns an error when the requested unit is in use", func() {
+			vm.Annotations = map[string]string{
+				vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "vsphere-volume", "unitNumber": 1}]`,
+			}
+			_, err := session.UpdateVolumePlacementDeviceChanges(vm, devices, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is in use"))
+		})
+
+		It("returns an error when the SCSI bus is used by another type of controller", func() {
+			vm.Annotations = map[string]string{
+				vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "vsphere-volume", "controllerType": "LsiLogic"}]`,
+			}
+			_, err := session.UpdateVolumePlacementDeviceChanges(vm, devices, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("SCSI bus 0"))
+		})
+
+		It("ignores the placement of a PersistentVolumeClaim volume", func() {
+			vm.Annotations = map[string]string{
+				vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "pvc-volume", "busNumber": 2}]`,
+			}
+			deviceChanges, err := session.UpdateVolumePlacementDeviceChanges(vm, devices, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deviceChanges).To(BeEmpty())
+			Expect(pvcDisk.ControllerKey).To(BeEquivalentTo(controllerKey))
+		})
+	})
+
+	Context("MarkVolumePlacementCondition", func() {
+		It("removes the condition when the VM has no placements", func() {
+			conditions.MarkTrue(vm, vmopapi.VirtualMachineVolumesPlacedCondition)
+			session.MarkVolumePlacementCondition(vm, devices)
+			Expect(conditions.Has(vm, vmopapi.VirtualMachineVolumesPlacedCondition)).To(BeFalse())
+		})
+
+		It("marks the condition false when the placements cannot be parsed", func() {
+			vm.Annotations = map[string]string{vmopapi.VirtualMachineVolumePlacementAnnotation: "not-json"}
+			session.MarkVolumePlacementCondition(vm, devices)
+			Expect(conditions.IsFalse(vm, vmopapi.VirtualMachineVolumesPlacedCondition)).To(BeTrue())
+			Expect(conditions.GetReason(vm, vmopapi.VirtualMachineVolumesPlacedCondition)).To(Equal(vmopapi.VirtualMachineVolumePlacementInvalidReason))
+		})
+
+		It("marks the condition true when the volumes are on their requested units", func() {
+			vm.Annotations = map[string]string{
+				vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "vsphere-volume", "unitNumber": 0}]`,
+			}
+			session.MarkVolumePlacementCondition(vm, devices)
+			Expect(conditions.IsTrue(vm, vmopapi.VirtualMachineVolumesPlacedCondition)).To(BeTrue())
+		})
+
+		It("marks the condition false when a volume is on another unit", func() {
+			vm.Annotations = map[string]string{
+				vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "vsphere-volume", "unitNumber": 3}]`,
+			}
+			session.MarkVolumePlacementCondition(vm, devices)
+			Expect(conditions.IsFalse(vm, vmopapi.VirtualMachineVolumesPlacedCondition)).To(BeTrue())
+			Expect(conditions.GetReason(vm, vmopapi.VirtualMachineVolumesPlacedCondition)).To(Equal(vmopapi.VirtualMachineVolumePlacementMismatchReason))
+			Expect(conditions.GetMessage(vm, vmopapi.VirtualMachineVolumesPlacedCondition)).To(Equal("volume vsphere-volume is on ParaVirtualSCSI 0:0"))
+		})
+
+		It("does not consider PersistentVolumeClaim volumes", func() {
+			vm.Annotations = map[string]string{
+				vmopapi.VirtualMachineVolumePlacementAnnotation: `[{"volumeName": "pvc-volume", "unitNumber": 3}]`,
+			}
+			session.MarkVolumePlacementCondition(vm, devices)
+			Expect(conditions.IsTrue(vm, vmopapi.VirtualMachineVolumesPlacedCondition)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,134 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"sort"
+
+	"github.com/vmware/govmomi/object"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	res "github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/resources"
+)
+
+// expandPoweredOnVMDisks expands the disks of the vSphere volumes whose capacity was increased while the VM is
+// powered on. A failure to expand the disks, like when the VM has snapshots, is set in the status of the volumes.
+func (s *Session) expandPoweredOnVMDisks(
+	vmCtx context.VirtualMachineContext,
+	resVM *res.VirtualMachine,
+	config *vimTypes.VirtualMachineConfigInfo) error {
+
+	virtualDisks := object.VirtualDeviceList(config.Hardware.Device).SelectByType((*vimTypes.VirtualDisk)(nil))
+	deviceChanges, err := updateVirtualDiskDeviceChanges(vmCtx, virtualDisks)
+	if err != nil {
+		// The failure is not specific to a disk, so it is set in the status of every vSphere volume. The error
+		// is cleared from the volumes whose disk is not smaller than their capacity when the status is updated.
+		markVsphereVolumesResizeFailed(vmCtx.VM, nil, err)
+		return err
+	}
+
+	if len(deviceChanges) == 0 {
+		return nil
+	}
+
+	configSpec := &vimTypes.VirtualMachineConfigSpec{DeviceChange: deviceChanges}
+	vmCtx.Logger.Info("Expanding disks of powered on VM", "configSpec", configSpec)
+	if err := resVM.Reconfigure(vmCtx, configSpec); err != nil {
+		vmCtx.Logger.Error(err, "powered on disk expansion failed")
+		markVsphereVolumesResizeFailed(vmCtx.VM, deviceChanges, err)
+		return err
+	}
+
+	return nil
+}
+
+// markVsphereVolumesResizeFailed sets the error in the status of the vSphere volumes of the expanded disks, or
+// of every vSphere volume when there are no device changes.
+func markVsphereVolumesResizeFailed(
+	vm *v1alpha1.VirtualMachine,
+	deviceChanges []vimTypes.BaseVirtualDeviceConfigSpec,
+	resizeErr error) {
+
+	deviceKeys := map[int]struct{}{}
+	for _, deviceChange := range deviceChanges {
+		deviceKeys[int(deviceChange.GetVirtualDeviceConfigSpec().Device.GetVirtualDevice().Key)] = struct{}{}
+	}
+
+	for _, volume := range vm.Spec.Volumes {
+		if volume.VsphereVolume == nil || volume.VsphereVolume.DeviceKey == nil {
+			continue
+		}
+		if _, ok := deviceKeys[*volume.VsphereVolume.DeviceKey]; !ok && len(deviceChanges) > 0 {
+			continue
+		}
+
+		volumeStatus := getOrAddVolumeStatus(vm, volume.Name)
+		volumeStatus.Error = "failed to expand disk: " + resizeErr.Error()
+	}
+}
+
+func getOrAddVolumeStatus(vm *v1alpha1.VirtualMachine, name string) *v1alpha1.VirtualMachineVolumeStatus {
+	for i := range vm.Status.Volumes {
+		if vm.Status.Volumes[i].Name == name {
+			return &vm.Status.Volumes[i]
+		}
+	}
+
+	vm.Status.Volumes = append(vm.Status.Volumes, v1alpha1.VirtualMachineVolumeStatus{Name: name})
+	return &vm.Status.Volumes[len(vm.Status.Volumes)-1]
+}
+
+// UpdateVsphereVolumesStatus updates the status of the vSphere volumes of the VM from its disks. The status of
+// the PersistentVolumeClaim volumes is left to the volume controller. The error of a volume is kept while its disk
+// is still smaller than the capacity of the volume, so that a failed expansion remains visible until it succeeds.
+func UpdateVsphereVolumesStatus(vm *v1alpha1.VirtualMachine, devices object.VirtualDeviceList) {
+	existingErrors := map[string]string{}
+	var volumeStatuses []v1alpha1.VirtualMachineVolumeStatus
+	for _, volumeStatus := range vm.Status.Volumes {
+		existingErrors[volumeStatus.Name] = volumeStatus.Error
+		if !isVsphereVolume(vm, volumeStatus.Name) {
+			volumeStatuses = append(volumeStatuses, volumeStatus)
+		}
+	}
+
+	for _, volume := range vm.Spec.Volumes {
+		if volume.VsphereVolume == nil || volume.VsphereVolume.DeviceKey == nil {
+			continue
+		}
+
+		volumeStatus := v1alpha1.VirtualMachineVolumeStatus{Name: volume.Name}
+
+		if device := devices.FindByKey(int32(*volume.VsphereVolume.DeviceKey)); device != nil {
+			if disk, ok := device.(*vimTypes.VirtualDisk); ok {
+				volumeStatus.Attached = true
+				if backing, ok := disk.Backing.(*vimTypes.VirtualDiskFlatVer2BackingInfo); ok {
+					volumeStatus.DiskUuid = backing.Uuid
+				}
+				if disk.CapacityInBytes < volume.VsphereVolume.Capacity.StorageEphemeral().Value() {
+					volumeStatus.Error = existingErrors[volume.Name]
+				}
+			}
+		}
+
+		volumeStatuses = append(volumeStatuses, volumeStatus)
+	}
+
+	// Sort like the volume controller so the two controllers do not reorder the volumes.
+	sort.Slice(volumeStatuses, func(i, j int) bool {
+		return volumeStatuses[i].DiskUuid < volumeStatuses[j].DiskUuid
+	})
+	vm.Status.Volumes = volumeStatuses
+}
+
+func isVsphereVolume(vm *v1alpha1.VirtualMachine, name string) bool {
+	for _, volume := range vm.Spec.Volumes {
+		if volume.Name == name {
+			return volume.VsphereVolume != nil
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+// +build !integration
+
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	vimTypes "github.com/vmware/govmomi/vim25/types"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/session"
+)
+
+var _ = Describe("Vsphere Volumes Status", func() {
+	Context("UpdateVsphereVolumesStatus", func() {
+		const (
+			deviceKey = 2000
+			diskUUID  = "6000C29a-1234"
+		)
+
+		var (
+			vm      *vmopv1alpha1.VirtualMachine
+			devices object.VirtualDeviceList
+		)
+
+		BeforeEach(func() {
+			key := deviceKey
+			vm = &vmopv1alpha1.VirtualMachine{
+				Spec: vmopv1alpha1.VirtualMachineSpec{
+					Volumes: []vmopv1alpha1.VirtualMachineVolume{
+						{
+							Name: "vsphere-volume",
+							VsphereVolume: &vmopv1alpha1.VsphereVolumeSource{
+								Capacity: corev1.ResourceList{
+									corev1.ResourceEphemeralStorage: resource.MustParse("8Gi"),
+								},
+								DeviceKey: &key,
+							},
+						},
+						{
+							Name: "cns-volume",
+							PersistentVolumeClaim: &vmopv1alpha1.PersistentVolumeClaimVolumeSource{
+								PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: "pvc",
+								},
+							},
+						},
+					},
+				},
+				Status: vmopv1alpha1.VirtualMachineStatus{
+					Volumes: []vmopv1alpha1.VirtualMachineVolumeStatus{
+						{
+							Name:     "cns-volume",
+							Attached: true,
+							DiskUuid: "cns-disk-uuid",
+						},
+						{
+							Name:  "vsphere-volume",
+							Error: "failed to expand disk",
+						},
+					},
+				},
+			}
+
+			devices = object.VirtualDeviceList{
+				&vimTypes.VirtualDisk{
+					VirtualDevice: vimTypes.VirtualDevice{
+						Key: deviceKey,
+						Backing: &vimTypes.VirtualDiskFlatVer2BackingInfo{
+							Uuid: diskUUID,
+						},
+					},
+					CapacityInBytes: 4 * 1024 * 1024 * 1024,
+				},
+			}
+		})
+
+		It("keeps the error of a disk that is not yet expanded", func() {
+			session.UpdateVsphereVolumesStatus(vm, devices)
+
+			Expect(vm.Status.Volumes).To(HaveLen(2))
+			Expect(vm.Status.Volumes[0]).To(Equal(vmopv1alpha1.VirtualMachineVolumeStatus{
+				Name:     "vsphere-volume",
+				Attached: true,
+				DiskUuid: diskUUID,
+				Error:    "failed to expand disk",
+			}))
+			Expect(vm.Status.Volumes[1].Name).To(Equal("cns-volume"))
+			Expect(vm.Status.Volumes[1].Attached).To(BeTrue())
+		})
+
+		It("clears the error once the disk is expanded", func() {
+			devices[0].(*vimTypes.VirtualDisk).CapacityInBytes = 8 * 1024 * 1024 * 1024
+			session.UpdateVsphereVolumesStatus(vm, devices)
+
+			Expect(vm.Status.Volumes).To(HaveLen(2))
+			Expect(vm.Status.Volumes[0].Name).To(Equal("vsphere-volume"))
+			Expect(vm.Status.Volumes[0].Error).To(BeEmpty())
+		})
+
+		It("reports a missing disk as not attached", func() {
+			session.UpdateVsphereVolumesStatus(vm, nil)
+
+			Expect(vm.Status.Volumes).To(HaveLen(2))
+			Expect(vm.Status.Volumes[0].Name).To(Equal("vsphere-volume"))
+			Expect(vm.Status.Volumes[0].Attached).To(BeFalse())
+		})
+	})
+})
@@ -8,16 +8,21 @@ import (
 	"crypto/rand"
 	"math/big"
 	"strings"
+	"time"
 
 	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vapi/library"
 	vimtypes "github.com/vmware/govmomi/vim25/types"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrlruntime "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	"github.com/acharyasreej/vm-operator/pkg/lib"
+	"github.com/acharyasreej/vm-operator/pkg/metrics"
 	"github.com/acharyasreej/vm-operator/pkg/record"
 	"github.com/acharyasreej/vm-operator/pkg/topology"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider"
@@ -34,9 +39,17 @@ const (
 
 var log = logf.Log.WithName(VsphereVMProviderName)
 
+const (
+	// vmEventsBufferSize is the number of VirtualMachine events that are buffered until they are handled by the
+	// VirtualMachine controller.
+	vmEventsBufferSize = 1024
+)
+
 type vSphereVMProvider struct {
+	k8sClient     ctrlruntime.Client
 	sessions      session.Manager
 	eventRecorder record.Recorder
+	vmEvents      chan event.GenericEvent
 }
 
 func NewVSphereVMProviderFromClient(
@@ -44,8 +57,10 @@ func NewVSphereVMProviderFromClient(
 	recorder record.Recorder) vmprovider.VirtualMachineProviderInterface {
 
 	return &vSphereVMProvider{
+		k8sClient:     client,
 		sessions:      session.NewManager(client),
 		eventRecorder: recorder,
+		vmEvents:      make(chan event.GenericEvent, vmEventsBufferSize),
 	}
 }
 
@@ -66,7 +81,15 @@ func (vs *vSphereVMProvider) Name() string {
 	return VsphereVMProviderName
 }
 
+// Initialize starts watching the VMs on vCenter for changes, which are sent as events for the VirtualMachines
+// until the stop channel is closed.
 func (vs *vSphereVMProvider) Initialize(stop <-chan struct{}) {
+	go vs.watchVirtualMachines(stop)
+}
+
+// VirtualMachineEvents returns the channel of the events for the VirtualMachines whose VM changed on vCenter.
+func (vs *vSphereVMProvider) VirtualMachineEvents() <-chan event.GenericEvent {
+	return vs.vmEvents
 }
 
 func (vs *vSphereVMProvider) GetClient(ctx goctx.Context) (*vcclient.Client, error) {
@@ -102,6 +125,89 @@ func (vs *vSphereVMProvider) ListVirtualMachineImagesFromContentLibrary(
 		currentCLImages)
 }
 
+// GetContentLibraryChangeToken returns a token that changes whenever the items of a ContentLibrary change.
+func (vs *vSphereVMProvider) GetContentLibraryChangeToken(ctx goctx.Context, clUUID string) (string, error) {
+	client, err := vs.sessions.GetClient(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return client.ContentLibClient().GetLibraryChangeToken(ctx, clUUID)
+}
+
+// CreateOrUpdateSubscribedContentLibrary creates or updates a subscribed ContentLibrary, and returns its UUID.
+func (vs *vSphereVMProvider) CreateOrUpdateSubscribedContentLibrary(
+	ctx goctx.Context,
+	clUUID string,
+	sub vmprovider.ContentLibrarySubscription) (string, error) {
+
+	client, err := vs.sessions.GetClient(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return client.ContentLibClient().CreateOrUpdateSubscribedLibrary(ctx, clUUID, sub)
+}
+
+// GetContentLibraryLastSyncTime returns the last time a subscribed ContentLibrary was synced.
+func (vs *vSphereVMProvider) GetContentLibraryLastSyncTime(ctx goctx.Context, clUUID string) (*time.Time, error) {
+	client, err := vs.sessions.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.ContentLibClient().GetLibraryLastSyncTime(ctx, clUUID)
+}
+
+// IsContentLibraryWritable returns whether the items of a ContentLibrary can be changed.
+func (vs *vSphereVMProvider) IsContentLibraryWritable(ctx goctx.Context, clUUID string) (bool, error) {
+	client, err := vs.sessions.GetClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return client.ContentLibClient().IsLibraryWritable(ctx, clUUID)
+}
+
+// DeleteContentLibrary deletes a subscribed ContentLibrary with the UUID and name.
+func (vs *vSphereVMProvider) DeleteContentLibrary(ctx goctx.Context, clUUID, libraryName string) error {
+	client, err := vs.sessions.GetClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	return client.ContentLibClient().DeleteLibrary(ctx, clUUID, libraryName)
+}
+
+// ImportContentLibraryItem uploads the files of an OVF into an item of a ContentLibrary, and returns the
+// ID of the item. An existing item is only updated when it has the same description.
+func (vs *vSphereVMProvider) ImportContentLibraryItem(
+	ctx goctx.Context,
+	clUUID, itemName, itemDescription string,
+	filePaths []string) (string, error) {
+
+	client, err := vs.sessions.GetClient(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return client.ContentLibClient().ImportLibraryItem(ctx, clUUID, itemName, itemDescription, filePaths)
+}
+
+// DeleteContentLibraryItem deletes an item of a ContentLibrary. It is not an error if the item does not exist.
+func (vs *vSphereVMProvider) DeleteContentLibraryItem(ctx goctx.Context, itemID string) error {
+	client, err := vs.sessions.GetClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = client.ContentLibClient().DeleteLibraryItem(ctx, &library.Item{ID: itemID})
+	if err != nil && lib.IsNotFoundError(err) {
+		return nil
+	}
+	return err
+}
+
 func (vs *vSphereVMProvider) DoesVirtualMachineExist(ctx goctx.Context, vm *v1alpha1.VirtualMachine) (bool, error) {
 	vmCtx := context.VirtualMachineContext{
 		Context: ctx,
@@ -145,7 +251,11 @@ func (vs *vSphereVMProvider) getOpID(ctx goctx.Context, vm *v1alpha1.VirtualMach
 	return strings.Join([]string{"vmoperator", clusterID, vm.Name, operation, string(id)}, "-")
 }
 
-func (vs *vSphereVMProvider) CreateVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine, vmConfigArgs vmprovider.VMConfigArgs) error {
+func (vs *vSphereVMProvider) CreateVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine, vmConfigArgs vmprovider.VMConfigArgs) (retErr error) {
+	defer func(start time.Time) {
+		metrics.ObserveVMOperation("create", start, retErr)
+	}(time.Now())
+
 	vmCtx := context.VirtualMachineContext{
 		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "create")),
 		Logger:  log.WithValues("vmName", vm.NamespacedName()),
@@ -174,7 +284,11 @@ func (vs *vSphereVMProvider) CreateVirtualMachine(ctx goctx.Context, vm *v1alpha
 }
 
 // UpdateVirtualMachine updates the VM status, power state, phase etc.
-func (vs *vSphereVMProvider) UpdateVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine, vmConfigArgs vmprovider.VMConfigArgs) error {
+func (vs *vSphereVMProvider) UpdateVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine, vmConfigArgs vmprovider.VMConfigArgs) (retErr error) {
+	defer func(start time.Time) {
+		metrics.ObserveVMOperation("update", start, retErr)
+	}(time.Now())
+
 	vmCtx := context.VirtualMachineContext{
 		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "update")),
 		Logger:  log.WithValues("vmName", vm.NamespacedName()),
@@ -196,7 +310,11 @@ func (vs *vSphereVMProvider) UpdateVirtualMachine(ctx goctx.Context, vm *v1alpha
 	return nil
 }
 
-func (vs *vSphereVMProvider) DeleteVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine) error {
+func (vs *vSphereVMProvider) DeleteVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine) (retErr error) {
+	defer func(start time.Time) {
+		metrics.ObserveVMOperation("delete", start, retErr)
+	}(time.Now())
+
 	vmCtx := context.VirtualMachineContext{
 		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "delete")),
 		Logger:  log.WithValues("vmName", vm.NamespacedName()),
@@ -239,6 +357,215 @@ func (vs *vSphereVMProvider) GetVirtualMachineGuestHeartbeat(ctx goctx.Context,
 	return status, nil
 }
 
+func (vs *vSphereVMProvider) RunVirtualMachineGuestCommand(ctx goctx.Context, vm *v1alpha1.VirtualMachine, cmd vmprovider.GuestCommand) (int32, error) {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "guestCommand")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return 0, err
+	}
+
+	return ses.RunVirtualMachineGuestCommand(vmCtx, cmd)
+}
+
+func (vs *vSphereVMProvider) ResetVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine) error {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "reset")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	vmCtx.Logger.Info("Resetting VirtualMachine")
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	return ses.ResetVirtualMachine(vmCtx)
+}
+
+func (vs *vSphereVMProvider) PowerCycleVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine) error {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "powerCycle")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	vmCtx.Logger.Info("Power cycling VirtualMachine")
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	return ses.PowerCycleVirtualMachine(vmCtx)
+}
+
+func (vs *vSphereVMProvider) BackupVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine, args vmprovider.VMBackupArgs) error {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "backup")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	return ses.BackupVirtualMachine(vmCtx, args)
+}
+
+func (vs *vSphereVMProvider) ImportVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine) (vmprovider.VMHardware, error) {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "import")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return vmprovider.VMHardware{}, err
+	}
+
+	return ses.ImportVirtualMachine(vmCtx)
+}
+
+func (vs *vSphereVMProvider) RelocateVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine, zone string) error {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "relocate")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	vmCtx.Logger.Info("Relocating VirtualMachine", "zone", zone)
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	targetSes, err := vs.sessions.GetSession(vmCtx, zone, vm.Namespace)
+	if err != nil {
+		return err
+	}
+
+	resVM, err := ses.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	return targetSes.RelocateVirtualMachine(vmCtx, resVM)
+}
+
+func (vs *vSphereVMProvider) PlaceVirtualMachineInstanceStorage(ctx goctx.Context, vm *v1alpha1.VirtualMachine) error {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "placeInstanceStorage")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	resVM, err := ses.GetVirtualMachine(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	return ses.PlaceInstanceStorage(vmCtx, resVM)
+}
+
+func (vs *vSphereVMProvider) PublishVirtualMachine(ctx goctx.Context, vm *v1alpha1.VirtualMachine, args vmprovider.VMPublishArgs) (string, error) {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "publish")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	vmCtx.Logger.Info("Publishing VirtualMachine", "libraryUUID", args.LibraryUUID, "itemName", args.ItemName)
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return "", err
+	}
+
+	return ses.PublishVirtualMachine(vmCtx, args)
+}
+
+func (vs *vSphereVMProvider) CreateVirtualMachineSnapshot(ctx goctx.Context, vm *v1alpha1.VirtualMachine, args vmprovider.VMSnapshotArgs) (string, error) {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "createSnapshot")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	vmCtx.Logger.Info("Creating VirtualMachine snapshot", "snapshotName", args.Name)
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return "", err
+	}
+
+	return ses.CreateVirtualMachineSnapshot(vmCtx, args)
+}
+
+func (vs *vSphereVMProvider) ListVirtualMachineSnapshots(ctx goctx.Context, vm *v1alpha1.VirtualMachine) ([]vmprovider.VMSnapshot, error) {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "listSnapshots")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ses.ListVirtualMachineSnapshots(vmCtx)
+}
+
+func (vs *vSphereVMProvider) RevertVirtualMachineSnapshot(ctx goctx.Context, vm *v1alpha1.VirtualMachine, snapshotID string) error {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "revertSnapshot")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	vmCtx.Logger.Info("Reverting VirtualMachine to snapshot", "snapshotID", snapshotID)
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	return ses.RevertVirtualMachineSnapshot(vmCtx, snapshotID)
+}
+
+func (vs *vSphereVMProvider) DeleteVirtualMachineSnapshot(ctx goctx.Context, vm *v1alpha1.VirtualMachine, snapshotID string) error {
+	vmCtx := context.VirtualMachineContext{
+		Context: goctx.WithValue(ctx, vimtypes.ID{}, vs.getOpID(ctx, vm, "deleteSnapshot")),
+		Logger:  log.WithValues("vmName", vm.NamespacedName()),
+		VM:      vm,
+	}
+
+	vmCtx.Logger.Info("Deleting VirtualMachine snapshot", "snapshotID", snapshotID)
+
+	ses, err := vs.sessions.GetSessionForVM(vmCtx)
+	if err != nil {
+		return err
+	}
+
+	return ses.DeleteVirtualMachineSnapshot(vmCtx, snapshotID)
+}
+
 func (vs *vSphereVMProvider) ComputeClusterCPUMinFrequency(ctx goctx.Context) error {
 	return vs.sessions.ComputeClusterCPUMinFrequency(ctx)
 }
@@ -0,0 +1,174 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package vsphere
+
+import (
+	goctx "context"
+	"sort"
+	"time"
+
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlruntime "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/indexer"
+	"github.com/acharyasreej/vm-operator/pkg/topology"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/watcher"
+)
+
+const (
+	// vmWatcherRetryInterval is how long to wait before the VM watcher is restarted after it failed.
+	vmWatcherRetryInterval = 10 * time.Second
+
+	// vmWatcherFoldersInterval is how often the folders of the namespaces are checked for changes, which
+	// restart the VM watcher.
+	vmWatcherFoldersInterval = time.Minute
+)
+
+// watchVirtualMachines streams the changes of the VMs in the folders of the namespaces until the stop channel is
+// closed, and sends an event for the VirtualMachine of each changed VM. The watcher is restarted whenever it fails,
+// for example when the vCenter client is recreated after the vCenter credentials changed, and whenever the folders
+// of the namespaces change. All the VirtualMachines are resynced after each start, since the changes made while
+// the VMs were not watched are not reported.
+func (vs *vSphereVMProvider) watchVirtualMachines(stop <-chan struct{}) {
+	ctx, cancel := goctx.WithCancel(goctx.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	wait.Until(func() {
+		folders, err := vs.getNamespaceFolders(ctx)
+		if err != nil {
+			log.Error(err, "Failed to get namespace folders to watch VMs")
+			return
+		}
+		if len(folders) == 0 {
+			log.V(4).Info("No namespace folders to watch VMs in")
+			return
+		}
+
+		client, err := vs.sessions.GetClient(ctx)
+		if err != nil {
+			log.Error(err, "Failed to get vCenter client to watch VMs")
+			return
+		}
+
+		runCtx, runCancel := goctx.WithCancel(ctx)
+		defer runCancel()
+		go wait.Until(func() {
+			if newFolders, err := vs.getNamespaceFolders(runCtx); err == nil && !equalFolders(folders, newFolders) {
+				log.Info("Namespace folders changed, restarting to watch VMs")
+				runCancel()
+			}
+		}, vmWatcherFoldersInterval, runCtx.Done())
+
+		containers := make([]vimtypes.ManagedObjectReference, 0, len(folders))
+		for _, folder := range folders {
+			containers = append(containers, vimtypes.ManagedObjectReference{Type: "Folder", Value: folder})
+		}
+
+		log.Info("Starting to watch VMs", "folders", folders)
+		w := watcher.New(client.VimClient(), containers, watcher.DefaultWatchedProperties,
+			func(moID string) {
+				vs.enqueueVirtualMachine(runCtx, moID)
+			},
+			func() {
+				vs.enqueueAllVirtualMachines(runCtx)
+			})
+		if err := w.Run(runCtx); err != nil {
+			log.Error(err, "Failed to watch VMs")
+		}
+	}, vmWatcherRetryInterval, stop)
+}
+
+// getNamespaceFolders returns the sorted managed object IDs of the folders of the namespaces in all the
+// availability zones, which contain the VMs of the VirtualMachines.
+func (vs *vSphereVMProvider) getNamespaceFolders(ctx goctx.Context) ([]string, error) {
+	availabilityZones, err := topology.GetAvailabilityZones(ctx, vs.k8sClient)
+	if err != nil {
+		return nil, err
+	}
+
+	folderSet := map[string]struct{}{}
+	for _, az := range availabilityZones {
+		for _, nsInfo := range az.Spec.Namespaces {
+			if nsInfo.FolderMoId != "" {
+				folderSet[nsInfo.FolderMoId] = struct{}{}
+			}
+		}
+	}
+
+	folders := make([]string, 0, len(folderSet))
+	for folder := range folderSet {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+
+	return folders, nil
+}
+
+func equalFolders(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// enqueueVirtualMachine sends an event for the VirtualMachine of the VM with the managed object ID. Changes to
+// VMs that are not managed by VM Operator are ignored.
+func (vs *vSphereVMProvider) enqueueVirtualMachine(ctx goctx.Context, moID string) {
+	vmList := &v1alpha1.VirtualMachineList{}
+	if err := vs.k8sClient.List(ctx, vmList, ctrlruntime.MatchingFields{indexer.VirtualMachineMoIDField: moID}); err != nil {
+		log.Error(err, "Failed to list VirtualMachines for changed VM", "moID", moID)
+		return
+	}
+
+	for i := range vmList.Items {
+		vm := &vmList.Items[i]
+		// Match the MoID again, in case the client does not support the field index.
+		if vm.Status.UniqueID != moID && vm.Annotations[vmopapi.ImportVMMoIDAnnotation] != moID {
+			continue
+		}
+
+		log.V(4).Info("Enqueuing changed VirtualMachine", "name", vm.NamespacedName(), "moID", moID)
+		vs.sendVirtualMachineEvent(ctx, vm)
+		return
+	}
+}
+
+// enqueueAllVirtualMachines sends an event for each VirtualMachine that has a VM.
+func (vs *vSphereVMProvider) enqueueAllVirtualMachines(ctx goctx.Context) {
+	vmList := &v1alpha1.VirtualMachineList{}
+	if err := vs.k8sClient.List(ctx, vmList); err != nil {
+		log.Error(err, "Failed to list VirtualMachines to resync")
+		return
+	}
+
+	log.V(4).Info("Enqueuing all VirtualMachines", "count", len(vmList.Items))
+	for i := range vmList.Items {
+		vm := &vmList.Items[i]
+		if vm.Status.UniqueID == "" {
+			continue
+		}
+		vs.sendVirtualMachineEvent(ctx, vm)
+	}
+}
+
+func (vs *vSphereVMProvider) sendVirtualMachineEvent(ctx goctx.Context, vm *v1alpha1.VirtualMachine) {
+	select {
+	case vs.vmEvents <- event.GenericEvent{Object: vm}:
+	case <-ctx.Done():
+	}
+}
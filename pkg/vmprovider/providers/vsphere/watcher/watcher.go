@@ -0,0 +1,195 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package watcher
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("vmwatcher")
+
+// DefaultWatchedProperties are the properties of the VMs whose changes are reported by a Watcher. These are
+// the properties of the VM that are reflected in the status of a VirtualMachine and that can change without
+// VM Operator changing the VM.
+var DefaultWatchedProperties = []string{
+	"guest.ipAddress",
+	"guest.net",
+	"runtime.host",
+	"runtime.powerState",
+}
+
+// maxWaitSeconds is how long a single WaitForUpdatesEx call waits for updates, so that a broken connection
+// is noticed even when no VM changes.
+const maxWaitSeconds = int32(60)
+
+// Watcher reports the changes of the watched properties of the VMs in a set of containers, like the folders of
+// the namespaces, using a PropertyCollector filter on a ContainerView of the VMs of each container.
+type Watcher struct {
+	client     *vim25.Client
+	containers []types.ManagedObjectReference
+	properties []string
+	onChange   func(moID string)
+	onSynced   func()
+
+	collector *property.Collector
+	views     []*view.ContainerView
+}
+
+// New returns a Watcher of the VMs under the containers, which calls onChange with the managed object ID of a VM
+// whenever one of the properties of the VM changes, or when the VM is removed. Since the changes made before Run
+// was called are not reported, onSynced is called once the initial state of the VMs is received, so the VMs can
+// be resynced then.
+func New(
+	client *vim25.Client,
+	containers []types.ManagedObjectReference,
+	properties []string,
+	onChange func(moID string),
+	onSynced func()) *Watcher {
+
+	return &Watcher{
+		client:     client,
+		containers: containers,
+		properties: properties,
+		onChange:   onChange,
+		onSynced:   onSynced,
+	}
+}
+
+// Run watches the VMs until the context is done or an error occurs. The changes that are part of the initial
+// state of the VMs are not reported, so only changes made after Run was called are reported, and onSynced is
+// called instead. The returned error is nil when the context is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	if err := w.createFilter(ctx); err != nil {
+		w.destroy()
+		return err
+	}
+	defer w.destroy()
+
+	version := ""
+	initial := true
+	for {
+		maxWait := maxWaitSeconds
+		res, err := methods.WaitForUpdatesEx(ctx, w.client, &types.WaitForUpdatesEx{
+			This:    w.collector.Reference(),
+			Version: version,
+			Options: &types.WaitOptions{MaxWaitSeconds: &maxWait},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "failed to wait for VM updates")
+		}
+
+		updateSet := res.Returnval
+		if updateSet != nil {
+			version = updateSet.Version
+		}
+
+		if !initial {
+			if updateSet != nil {
+				w.reportChanges(updateSet)
+			}
+			continue
+		}
+
+		// A truncated update set is followed by the rest of the initial state of the VMs. When there are no
+		// updates before the wait timed out, there are no VMs in the initial state.
+		initial = updateSet != nil && updateSet.Truncated != nil && *updateSet.Truncated
+		if !initial && w.onSynced != nil {
+			w.onSynced()
+		}
+	}
+}
+
+func (w *Watcher) reportChanges(updateSet *types.UpdateSet) {
+	for _, filterSet := range updateSet.FilterSet {
+		for _, objectSet := range filterSet.ObjectSet {
+			if objectSet.Obj.Type != "VirtualMachine" {
+				continue
+			}
+			log.V(5).Info("VM changed", "moID", objectSet.Obj.Value, "kind", objectSet.Kind)
+			w.onChange(objectSet.Obj.Value)
+		}
+	}
+}
+
+func (w *Watcher) createFilter(ctx context.Context) error {
+	if len(w.containers) == 0 {
+		return errors.New("no containers of VMs to watch")
+	}
+
+	viewManager := view.NewManager(w.client)
+	objectSet := make([]types.ObjectSpec, 0, len(w.containers))
+	for _, container := range w.containers {
+		v, err := viewManager.CreateContainerView(ctx, container, []string{"VirtualMachine"}, true)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create VM container view of %s", container.Value)
+		}
+		w.views = append(w.views, v)
+
+		objectSet = append(objectSet, types.ObjectSpec{
+			Obj:  v.Reference(),
+			Skip: types.NewBool(true),
+			SelectSet: []types.BaseSelectionSpec{
+				&types.TraversalSpec{
+					Type: "ContainerView",
+					Path: "view",
+				},
+			},
+		})
+	}
+
+	var err error
+	w.collector, err = property.DefaultCollector(w.client).Create(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to create property collector")
+	}
+
+	filter := types.CreateFilter{
+		Spec: types.PropertyFilterSpec{
+			ObjectSet: objectSet,
+			PropSet: []types.PropertySpec{
+				{
+					Type:    "VirtualMachine",
+					PathSet: w.properties,
+				},
+			},
+		},
+	}
+
+	if err := w.collector.CreateFilter(ctx, filter); err != nil {
+		return errors.Wrap(err, "failed to create property filter")
+	}
+
+	return nil
+}
+
+// destroy removes the property collector and the container views from the server. A new context is used since
+// the context of Run is usually done by then.
+func (w *Watcher) destroy() {
+	ctx := context.Background()
+
+	if w.collector != nil {
+		if err := w.collector.Destroy(ctx); err != nil {
+			log.V(4).Info("Failed to destroy property collector", "error", err)
+		}
+		w.collector = nil
+	}
+
+	for _, v := range w.views {
+		if err := v.Destroy(ctx); err != nil {
+			log.V(4).Info("Failed to destroy container view", "error", err)
+		}
+	}
+	w.views = nil
+}
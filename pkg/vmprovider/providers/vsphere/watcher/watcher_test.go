@@ -0,0 +1,160 @@
+// +build !integration
+
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package watcher_test
+
+import (
+	goctx "context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/watcher"
+)
+
+var _ = Describe("Watcher", func() {
+
+	var (
+		changes chan string
+		synced  chan struct{}
+	)
+
+	BeforeEach(func() {
+		changes = make(chan string, 100)
+		synced = make(chan struct{}, 100)
+	})
+
+	runWatcher := func(ctx goctx.Context, c *vim25.Client, containers ...types.ManagedObjectReference) (goctx.CancelFunc, chan error) {
+		if len(containers) == 0 {
+			containers = []types.ManagedObjectReference{c.ServiceContent.RootFolder}
+		}
+
+		w := watcher.New(c, containers, watcher.DefaultWatchedProperties,
+			func(moID string) {
+				changes <- moID
+			},
+			func() {
+				synced <- struct{}{}
+			})
+
+		ctx, cancel := goctx.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() {
+			done <- w.Run(ctx)
+		}()
+
+		return cancel, done
+	}
+
+	It("reports the VMs that change", func() {
+		res := simulator.VPX().Run(func(ctx goctx.Context, c *vim25.Client) error {
+			finder := find.NewFinder(c)
+			vms, err := finder.VirtualMachineList(ctx, "*")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(vms)).To(BeNumerically(">", 1))
+			vm := vms[0]
+
+			cancel, done := runWatcher(ctx, c)
+			defer cancel()
+
+			By("not reporting the initial state of the VMs", func() {
+				Eventually(synced).Should(Receive())
+				Consistently(changes).ShouldNot(Receive())
+				Expect(synced).ToNot(Receive())
+			})
+
+			By("reporting the VM that is powered off", func() {
+				task, err := vm.PowerOff(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(task.Wait(ctx)).To(Succeed())
+
+				Eventually(changes).Should(Receive(Equal(vm.Reference().Value)))
+			})
+
+			By("returning without an error once the context is done", func() {
+				cancel()
+				Eventually(done).Should(Receive(BeNil()))
+			})
+
+			return nil
+		})
+		Expect(res).To(BeNil())
+	})
+
+	It("reports the VMs that are removed", func() {
+		res := simulator.VPX().Run(func(ctx goctx.Context, c *vim25.Client) error {
+			finder := find.NewFinder(c)
+			vms, err := finder.VirtualMachineList(ctx, "*")
+			Expect(err).ToNot(HaveOccurred())
+			vm := vms[0]
+
+			task, err := vm.PowerOff(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(task.Wait(ctx)).To(Succeed())
+
+			cancel, _ := runWatcher(ctx, c)
+			defer cancel()
+			Consistently(changes).ShouldNot(Receive())
+
+			task, err = vm.Destroy(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(task.Wait(ctx)).To(Succeed())
+
+			Eventually(changes).Should(Receive(Equal(vm.Reference().Value)))
+
+			return nil
+		})
+		Expect(res).To(BeNil())
+	})
+
+	It("only reports the VMs in its containers", func() {
+		res := simulator.VPX().Run(func(ctx goctx.Context, c *vim25.Client) error {
+			finder := find.NewFinder(c)
+			vms, err := finder.VirtualMachineList(ctx, "*")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(vms)).To(BeNumerically(">", 1))
+			watchedVM, otherVM := vms[0], vms[1]
+
+			vmFolder, err := finder.DefaultFolder(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			folder, err := vmFolder.CreateFolder(ctx, "watched")
+			Expect(err).ToNot(HaveOccurred())
+			task, err := folder.MoveInto(ctx, []types.ManagedObjectReference{watchedVM.Reference()})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(task.Wait(ctx)).To(Succeed())
+
+			cancel, _ := runWatcher(ctx, c, folder.Reference())
+			defer cancel()
+			Eventually(synced).Should(Receive())
+
+			for _, vm := range []*object.VirtualMachine{otherVM, watchedVM} {
+				task, err := vm.PowerOff(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(task.Wait(ctx)).To(Succeed())
+			}
+
+			Eventually(changes).Should(Receive(Equal(watchedVM.Reference().Value)))
+			Consistently(changes).ShouldNot(Receive())
+
+			return nil
+		})
+		Expect(res).To(BeNil())
+	})
+
+	It("returns an error when it has no containers", func() {
+		res := simulator.VPX().Run(func(ctx goctx.Context, c *vim25.Client) error {
+			w := watcher.New(c, nil, watcher.DefaultWatchedProperties, func(string) {}, nil)
+			Expect(w.Run(ctx)).To(MatchError(ContainSubstring("no containers")))
+			return nil
+		})
+		Expect(res).To(BeNil())
+	})
+})
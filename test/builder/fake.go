@@ -11,6 +11,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	vmopv1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	ncpv1alpha1 "github.com/acharyasreej/vm-operator/external/ncp/api/v1alpha1"
 
 	netopv1alpha1 "github.com/acharyasreej/vm-operator/external/net-operator/api/v1alpha1"
@@ -38,5 +40,6 @@ func NewScheme() *runtime.Scheme {
 	_ = cnsv1alpha1.AddToScheme(scheme)
 	_ = netopv1alpha1.AddToScheme(scheme)
 	_ = topologyv1.AddToScheme(scheme)
+	_ = vmopapi.AddToScheme(scheme)
 	return scheme
 }
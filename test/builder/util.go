@@ -218,6 +218,28 @@ func DummyVirtualMachineImage(imageName string) *vmopv1.VirtualMachineImage {
 	}
 }
 
+// DummyContentSource returns a ContentLibraryProvider of a content library with the UUID, and its ContentSource
+// and the ContentSourceBinding of the ContentSource to the namespace, which are all named after the library.
+func DummyContentSource(clUUID, namespace string) (
+	*vmopv1.ContentLibraryProvider, *vmopv1.ContentSource, *vmopv1.ContentSourceBinding) {
+
+	clProvider := &vmopv1.ContentLibraryProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: clUUID},
+		Spec:       vmopv1.ContentLibraryProviderSpec{UUID: clUUID},
+	}
+	cs := &vmopv1.ContentSource{
+		ObjectMeta: metav1.ObjectMeta{Name: clUUID},
+		Spec: vmopv1.ContentSourceSpec{
+			ProviderRef: vmopv1.ContentProviderReference{Name: clUUID, Kind: "ContentLibraryProvider"},
+		},
+	}
+	binding := &vmopv1.ContentSourceBinding{
+		ObjectMeta:       metav1.ObjectMeta{Name: clUUID, Namespace: namespace},
+		ContentSourceRef: vmopv1.ContentSourceReference{Name: clUUID, Kind: "ContentSource"},
+	}
+	return clProvider, cs, binding
+}
+
 func DummyStorageClass() *storagev1.StorageClass {
 	return &storagev1.StorageClass{
 		ObjectMeta: metav1.ObjectMeta{
@@ -29,6 +29,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	vmopv1alpha1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	ncpv1alpha1 "github.com/acharyasreej/vm-operator/external/ncp/api/v1alpha1"
 
 	topologyv1 "github.com/acharyasreej/vm-operator/external/tanzu-topology/api/v1alpha1"
@@ -145,6 +146,7 @@ func GetCtrlRuntimeClient(config *rest.Config) (client.Client, error) {
 	_ = ncpv1alpha1.AddToScheme(s)
 	_ = netopv1alpha1.AddToScheme(s)
 	_ = topologyv1.AddToScheme(s)
+	_ = vmopapi.AddToScheme(s)
 	_ = cnsv1alpha1.SchemeBuilder.AddToScheme(s)
 	return client.New(config, client.Options{Scheme: s})
 }
@@ -0,0 +1,184 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package validation
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmgr "sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/pkg/errors"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/builder"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/webhooks/common"
+)
+
+const (
+	webHookName = "default"
+
+	publisherURLSchemeMsg  = "must be an http or https URL"
+	publisherURLHostMsg    = "must have a host"
+	publisherURLUserMsg    = "must not have user information"
+	publisherURLAddressMsg = "must not be a loopback, link-local or unspecified address"
+	publisherURLPathMsg    = "must be the URL of the lib.json of a published content library"
+	sslThumbprintMsg       = "must be a SHA-1 thumbprint like 01:23:...:EF"
+	datastoreIDMsg         = "must be the managed object ID of a datastore like datastore-42"
+)
+
+var (
+	sslThumbprintRegex = regexp.MustCompile(`^([0-9A-Fa-f]{2}:){19}[0-9A-Fa-f]{2}$`)
+	datastoreIDRegex   = regexp.MustCompile(`^datastore-[0-9]+$`)
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/default-validate-vmoperator-vmware-com-v1alpha1-subscribedcontentlibrary,mutating=false,failurePolicy=fail,groups=vmoperator.vmware.com,resources=subscribedcontentlibraries,versions=v1alpha1,name=default.validating.subscribedcontentlibrary.vmoperator.vmware.com,sideEffects=None,admissionReviewVersions=v1;v1beta1
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=subscribedcontentlibraries,verbs=get;list
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=subscribedcontentlibraries/status,verbs=get
+
+// AddToManager adds the webhook to the provided manager.
+func AddToManager(ctx *context.ControllerManagerContext, mgr ctrlmgr.Manager) error {
+	hook, err := builder.NewValidatingWebhook(ctx, mgr, webHookName, NewValidator(mgr.GetClient()))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create SubscribedContentLibrary validation webhook")
+	}
+	mgr.GetWebhookServer().Register(hook.Path, hook)
+
+	return nil
+}
+
+// NewValidator returns the package's Validator.
+func NewValidator(_ client.Client) builder.Validator {
+	return validator{
+		converter: runtime.DefaultUnstructuredConverter,
+	}
+}
+
+type validator struct {
+	converter runtime.UnstructuredConverter
+}
+
+func (v validator) For() schema.GroupVersionKind {
+	return vmopapi.GroupVersion.WithKind(reflect.TypeOf(vmopapi.SubscribedContentLibrary{}).Name())
+}
+
+func (v validator) ValidateCreate(ctx *context.WebhookRequestContext) admission.Response {
+	scl, err := v.sclFromUnstructured(ctx.Obj)
+	if err != nil {
+		return webhook.Errored(http.StatusBadRequest, err)
+	}
+
+	var fieldErrs field.ErrorList
+	fieldErrs = append(fieldErrs, v.validateSpec(ctx, scl)...)
+
+	validationErrs := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		validationErrs = append(validationErrs, fieldErr.Error())
+	}
+
+	return common.BuildValidationResponse(ctx, validationErrs, nil)
+}
+
+func (v validator) ValidateDelete(*context.WebhookRequestContext) admission.Response {
+	return admission.Allowed("")
+}
+
+func (v validator) ValidateUpdate(ctx *context.WebhookRequestContext) admission.Response {
+	scl, err := v.sclFromUnstructured(ctx.Obj)
+	if err != nil {
+		return webhook.Errored(http.StatusBadRequest, err)
+	}
+
+	oldSCL, err := v.sclFromUnstructured(ctx.OldObj)
+	if err != nil {
+		return webhook.Errored(http.StatusBadRequest, err)
+	}
+
+	var fieldErrs field.ErrorList
+	fieldErrs = append(fieldErrs, v.validateAllowedChanges(ctx, scl, oldSCL)...)
+	fieldErrs = append(fieldErrs, v.validateSpec(ctx, scl)...)
+
+	validationErrs := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		validationErrs = append(validationErrs, fieldErr.Error())
+	}
+
+	return common.BuildValidationResponse(ctx, validationErrs, nil)
+}
+
+func (v validator) validateSpec(ctx *context.WebhookRequestContext, scl *vmopapi.SubscribedContentLibrary) field.ErrorList {
+	var fieldErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	fieldErrs = append(fieldErrs, v.validatePublisherURL(ctx, specPath.Child("publisherURL"), scl.Spec.PublisherURL)...)
+	if t := scl.Spec.SSLThumbprint; t != "" && !sslThumbprintRegex.MatchString(t) {
+		fieldErrs = append(fieldErrs, field.Invalid(specPath.Child("sslThumbprint"), t, sslThumbprintMsg))
+	}
+	if id := scl.Spec.StorageBacking.DatastoreID; !datastoreIDRegex.MatchString(id) {
+		fieldErrs = append(fieldErrs, field.Invalid(specPath.Child("storageBacking", "datastoreID"), id, datastoreIDMsg))
+	}
+
+	return fieldErrs
+}
+
+// validatePublisherURL validates that the URL is the URL of a published content library that vCenter can
+// subscribe to. URLs of the loopback and link-local addresses are not allowed since these would make vCenter
+// connect to itself or to the metadata services of its host.
+func (v validator) validatePublisherURL(ctx *context.WebhookRequestContext, fldPath *field.Path, publisherURL string) field.ErrorList {
+	u, err := url.Parse(publisherURL)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, publisherURL, err.Error())}
+	}
+
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https":
+		return field.ErrorList{field.Invalid(fldPath, publisherURL, publisherURLSchemeMsg)}
+	case u.Hostname() == "":
+		return field.ErrorList{field.Invalid(fldPath, publisherURL, publisherURLHostMsg)}
+	case u.User != nil:
+		return field.ErrorList{field.Invalid(fldPath, publisherURL, publisherURLUserMsg)}
+	case path.Base(u.Path) != "lib.json":
+		return field.ErrorList{field.Invalid(fldPath, publisherURL, publisherURLPathMsg)}
+	}
+
+	if ip := net.ParseIP(u.Hostname()); u.Hostname() == "localhost" ||
+		ip != nil && (ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()) {
+		return field.ErrorList{field.Invalid(fldPath, publisherURL, publisherURLAddressMsg)}
+	}
+
+	return nil
+}
+
+// validateAllowedChanges returns true only if immutable fields have not been modified.
+func (v validator) validateAllowedChanges(ctx *context.WebhookRequestContext, scl, oldSCL *vmopapi.SubscribedContentLibrary) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	// The storage of an existing content library cannot be changed.
+	allErrs = append(allErrs, validation.ValidateImmutableField(scl.Spec.StorageBacking, oldSCL.Spec.StorageBacking, specPath.Child("storageBacking"))...)
+
+	return allErrs
+}
+
+// sclFromUnstructured returns the SubscribedContentLibrary from the unstructured object.
+func (v validator) sclFromUnstructured(obj runtime.Unstructured) (*vmopapi.SubscribedContentLibrary, error) {
+	scl := &vmopapi.SubscribedContentLibrary{}
+	if err := v.converter.FromUnstructured(obj.UnstructuredContent(), scl); err != nil {
+		return nil, err
+	}
+	return scl, nil
+}
@@ -18,6 +18,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -29,15 +30,18 @@ import (
 
 	vmopv1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/controllers/volume"
 	netopv1alpha1 "github.com/acharyasreej/vm-operator/external/net-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/auth"
 	"github.com/acharyasreej/vm-operator/pkg/builder"
 	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/pkg/indexer"
 	"github.com/acharyasreej/vm-operator/pkg/lib"
 	"github.com/acharyasreej/vm-operator/pkg/topology"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/config"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/constants"
+	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/ignition"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/instancestorage"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/network"
 	"github.com/acharyasreej/vm-operator/webhooks/common"
@@ -51,6 +55,8 @@ const (
 
 	readinessProbeNoActions                   = "must specify an action"
 	readinessProbeOnlyOneAction               = "only one action can be specified"
+	readinessProbeActionsRequireProbe         = "must be specified when the readiness probe actions annotation is set"
+	readinessProbeHTTPStatusRangeInvalid      = "min must be less than or equal to max, and both must be between 100 and 599"
 	updatesNotAllowedWhenPowerOn              = "updates to this filed is not allowed when VM power is on"
 	virtualMachineImageNotSupported           = "VirtualMachineImage is not compatible with v1alpha1 or is not a TKG Image"
 	storageClassNotAssignedFmt                = "Storage policy is not associated with the namespace %s"
@@ -58,10 +64,24 @@ const (
 	pvcHardwareVersionNotSupportedFmt         = "VirtualMachineImage has an unsupported hardware version %d for PersistentVolumes. Minimum supported hardware version %d"
 	invalidVolumeSpecified                    = "only one of persistentVolumeClaim or vsphereVolume must be specified"
 	vSphereVolumeSizeNotMBMultiple            = "value must be a multiple of MB"
+	vSphereVolumeShrinkNotAllowedFmt          = "value must not be less than the current capacity %s"
+	volumePlacementVolumeNotFound             = "volume does not exist"
+	instanceStorageReplaceNotConfigured       = "VM does not have instance storage volumes"
+	instanceStorageReplaceNotPoweredOff       = "instance storage volumes can only be replaced when the VM is powered off"
+	volumePlacementBusNumberOutOfRangeFmt     = "bus number must be between 0 and %d"
+	volumePlacementUnitNumberInvalidFmt       = "unit number is not valid for a %s controller"
+	volumePlacementUnitInUseFmt               = "unit %s is already used by volume %s"
+	volumePlacementPVCNotSupported            = "placing PersistentVolumeClaim volumes is not supported, only vSphere volumes can be placed"
+	volumePlacementSCSIBusInUseFmt            = "SCSI bus %d is already used by a %s controller"
 	eagerZeroedAndThinProvisionedNotSupported = "Volume provisioning cannot have EagerZeroed and ThinProvisioning set. Eager zeroing requires thick provisioning"
 	addingModifyingInstanceVolumesNotAllowed  = "adding or modifying instance storage volume(s) is not allowed"
 	metadataTransportResourcesEmpty           = "must specify either %s or %s, but not both"
 	metadataTransportResourcesInvalid         = "%s and %s cannot be specified simultaneously"
+	importVMAlreadyManagedFmt                 = "VM is already managed by VirtualMachine %s"
+	importAnnotationPrivilegedOnly            = "only privileged users can import VMs"
+	restoreAnnotationPrivilegedOnly           = "only privileged users can set the annotation"
+	metadataTransportRequiresMetadataFmt      = "must be specified when the metadata transport annotation is %s"
+	ignitionConfigInvalidFmt                  = "the %s key has an invalid Ignition config: %v"
 )
 
 // +kubebuilder:webhook:verbs=create;update,path=/default-validate-vmoperator-vmware-com-v1alpha1-virtualmachine,mutating=false,failurePolicy=fail,groups=vmoperator.vmware.com,resources=virtualmachines,versions=v1alpha1,name=default.validating.virtualmachine.vmoperator.vmware.com,sideEffects=None,admissionReviewVersions=v1;v1beta1
@@ -107,13 +127,17 @@ func (v validator) ValidateCreate(ctx *context.WebhookRequestContext) admission.
 
 	fieldErrs = append(fieldErrs, v.validateMetadata(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateAvailabilityZone(ctx, vm, nil)...)
+	fieldErrs = append(fieldErrs, v.validateImport(ctx, vm, nil)...)
+	fieldErrs = append(fieldErrs, v.validateRestore(ctx, vm, nil)...)
 	fieldErrs = append(fieldErrs, v.validateImage(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateClass(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateStorageClass(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateNetwork(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateVolumes(ctx, vm)...)
+	fieldErrs = append(fieldErrs, v.validateVolumePlacements(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateVMVolumeProvisioningOptions(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateReadinessProbe(ctx, vm)...)
+	fieldErrs = append(fieldErrs, v.validateLivenessProbe(ctx, vm)...)
 	if lib.IsInstanceStorageFSSEnabled() {
 		fieldErrs = append(fieldErrs, v.validateInstanceStorageVolumes(ctx, vm, nil)...)
 	}
@@ -133,7 +157,6 @@ func (v validator) ValidateDelete(*context.WebhookRequestContext) admission.Resp
 // ValidateUpdate validates if the given VirtualMachineSpec update is valid.
 // Updates to following fields are not allowed:
 //   - ImageName
-//   - ClassName
 //   - StorageClass
 //   - ResourcePolicyName
 
@@ -178,12 +201,18 @@ func (v validator) ValidateUpdate(ctx *context.WebhookRequestContext) admission.
 	// of whether the update is allowed or not.
 	fieldErrs = append(fieldErrs, v.validateMetadata(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateAvailabilityZone(ctx, vm, oldVM)...)
+	fieldErrs = append(fieldErrs, v.validateImport(ctx, vm, oldVM)...)
+	fieldErrs = append(fieldErrs, v.validateRestore(ctx, vm, oldVM)...)
 	fieldErrs = append(fieldErrs, v.validateNetwork(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateVolumes(ctx, vm)...)
+	fieldErrs = append(fieldErrs, v.validateVsphereVolumesCapacityUpdate(ctx, vm, oldVM)...)
+	fieldErrs = append(fieldErrs, v.validateVolumePlacements(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateVMVolumeProvisioningOptions(ctx, vm)...)
 	fieldErrs = append(fieldErrs, v.validateReadinessProbe(ctx, vm)...)
+	fieldErrs = append(fieldErrs, v.validateLivenessProbe(ctx, vm)...)
 	if lib.IsInstanceStorageFSSEnabled() {
 		fieldErrs = append(fieldErrs, v.validateInstanceStorageVolumes(ctx, vm, oldVM)...)
+		fieldErrs = append(fieldErrs, v.validateInstanceStorageReplace(ctx, vm, oldVM)...)
 	}
 
 	validationErrs := make([]string, 0, len(fieldErrs))
@@ -197,6 +226,8 @@ func (v validator) ValidateUpdate(ctx *context.WebhookRequestContext) admission.
 func (v validator) validateMetadata(ctx *context.WebhookRequestContext, vm *vmopv1.VirtualMachine) field.ErrorList {
 	var allErrs field.ErrorList
 
+	allErrs = append(allErrs, v.validateMetadataTransport(ctx, vm)...)
+
 	if vm.Spec.VmMetadata == nil {
 		return allErrs
 	}
@@ -216,12 +247,68 @@ func (v validator) validateMetadata(ctx *context.WebhookRequestContext, vm *vmop
 	return allErrs
 }
 
+// validateMetadataTransport validates the transport of the VirtualMachineMetadataTransportAnnotation, and the
+// Ignition config of the VM metadata when the VM uses the Ignition transport.
+func (v validator) validateMetadataTransport(ctx *context.WebhookRequestContext, vm *vmopv1.VirtualMachine) field.ErrorList {
+	var allErrs field.ErrorList
+
+	transport, ok := vm.Annotations[vmopapi.VirtualMachineMetadataTransportAnnotation]
+	if !ok {
+		return allErrs
+	}
+
+	annotationPath := field.NewPath("metadata", "annotations").Key(vmopapi.VirtualMachineMetadataTransportAnnotation)
+	if transport != string(vmopapi.VirtualMachineMetadataIgnitionTransport) {
+		return append(allErrs, field.NotSupported(annotationPath, transport,
+			[]string{string(vmopapi.VirtualMachineMetadataIgnitionTransport)}))
+	}
+
+	mdPath := field.NewPath("spec", "vmMetadata")
+	if vm.Spec.VmMetadata == nil {
+		return append(allErrs, field.Required(mdPath, fmt.Sprintf(metadataTransportRequiresMetadataFmt, transport)))
+	}
+	if vm.Spec.VmMetadata.Transport != vmopv1.VirtualMachineMetadataExtraConfigTransport {
+		allErrs = append(allErrs, field.NotSupported(mdPath.Child("transport"), vm.Spec.VmMetadata.Transport,
+			[]string{string(vmopv1.VirtualMachineMetadataExtraConfigTransport)}))
+	}
+
+	// The ConfigMap or Secret may be created after the VM, in which case the Ignition config is validated by
+	// the VM provider when the VM is customized.
+	var ignitionConfig string
+	var namePath *field.Path
+	var name string
+	switch {
+	case vm.Spec.VmMetadata.ConfigMapName != "":
+		namePath, name = mdPath.Child("configMapName"), vm.Spec.VmMetadata.ConfigMapName
+		configMap := &corev1.ConfigMap{}
+		if err := v.client.Get(ctx, client.ObjectKey{Namespace: vm.Namespace, Name: name}, configMap); err == nil {
+			ignitionConfig = configMap.Data[vmopapi.IgnitionConfigKey]
+		}
+	case vm.Spec.VmMetadata.SecretName != "":
+		namePath, name = mdPath.Child("secretName"), vm.Spec.VmMetadata.SecretName
+		secret := &corev1.Secret{}
+		if err := v.client.Get(ctx, client.ObjectKey{Namespace: vm.Namespace, Name: name}, secret); err == nil {
+			ignitionConfig = string(secret.Data[vmopapi.IgnitionConfigKey])
+		}
+	}
+
+	if err := ignition.Validate(ignitionConfig); err != nil {
+		allErrs = append(allErrs, field.Invalid(namePath, name, fmt.Sprintf(ignitionConfigInvalidFmt, vmopapi.IgnitionConfigKey, err)))
+	}
+
+	return allErrs
+}
+
 func (v validator) validateImage(ctx *context.WebhookRequestContext, vm *vmopv1.VirtualMachine) field.ErrorList {
 	var allErrs field.ErrorList
 
 	imageNamePath := field.NewPath("spec", "imageName")
 
 	if vm.Spec.ImageName == "" {
+		// An imported VM was not deployed from an image.
+		if vmopapi.IsImportedVM(vm) {
+			return allErrs
+		}
 		return append(allErrs, field.Required(imageNamePath, ""))
 	}
 
@@ -230,8 +317,11 @@ func (v validator) validateImage(ctx *context.WebhookRequestContext, vm *vmopv1.
 		return allErrs
 	}
 
-	if vm.Spec.VmMetadata != nil && vm.Spec.VmMetadata.Transport == vmopv1.VirtualMachineMetadataCloudInitTransport {
-		return allErrs
+	if vm.Spec.VmMetadata != nil {
+		switch vmopapi.GetVirtualMachineMetadataTransport(vm) {
+		case vmopv1.VirtualMachineMetadataCloudInitTransport, vmopapi.VirtualMachineMetadataIgnitionTransport:
+			return allErrs
+		}
 	}
 
 	image := vmopv1.VirtualMachineImage{}
@@ -240,16 +330,39 @@ func (v validator) validateImage(ctx *context.WebhookRequestContext, vm *vmopv1.
 		return append(allErrs, field.Invalid(imageNamePath, imageName, err.Error()))
 	}
 	if image.Status.ImageSupported != nil && !*image.Status.ImageSupported {
-		allErrs = append(allErrs, field.Invalid(imageNamePath, imageName, virtualMachineImageNotSupported))
+		allErrs = append(allErrs, field.Invalid(imageNamePath, imageName, imageNotSupportedMessage(&image)))
 	}
 
 	return allErrs
 }
 
+// imageNotSupportedMessage returns the message of an unsupported image with the reasons from the compatibility
+// report of the image, when the image has one.
+func imageNotSupportedMessage(image *vmopv1.VirtualMachineImage) string {
+	report, err := vmopapi.GetImageCompatibilityReport(image)
+	if err != nil || report == nil || len(report.Reasons) == 0 {
+		return virtualMachineImageNotSupported
+	}
+
+	return fmt.Sprintf("%s: %s%s", virtualMachineImageNotSupported, strings.Join(report.Reasons, "; "),
+		compatibilityReportReference(image))
+}
+
+// compatibilityReportReference returns a reference to the compatibility report of the image, or an empty string
+// when the image does not have one.
+func compatibilityReportReference(image *vmopv1.VirtualMachineImage) string {
+	if _, ok := image.Annotations[vmopapi.VirtualMachineImageCompatibilityReportAnnotation]; !ok {
+		return ""
+	}
+	return fmt.Sprintf(" (see the %s annotation of the VirtualMachineImage)",
+		vmopapi.VirtualMachineImageCompatibilityReportAnnotation)
+}
+
 func (v validator) validateClass(ctx *context.WebhookRequestContext, vm *vmopv1.VirtualMachine) field.ErrorList {
 	var allErrs field.ErrorList
 
-	if vm.Spec.ClassName == "" {
+	// The class of an imported VM is set from the VM's hardware when it is not specified.
+	if vm.Spec.ClassName == "" && !vmopapi.IsImportedVM(vm) {
 		allErrs = append(allErrs, field.Required(field.NewPath("spec", "className"), ""))
 	}
 
@@ -347,7 +460,7 @@ func (v validator) validateNetwork(ctx *context.WebhookRequestContext, vm *vmopv
 func (v validator) validateInstanceStorageVolumes(ctx *context.WebhookRequestContext, vm, oldVM *vmopv1.VirtualMachine) field.ErrorList {
 	var allErrs field.ErrorList
 	// Skip validations for VMOperator service account user and Kubernetes administrator.
-	if auth.IsPODServiceAccountUser(*ctx.UserInfo) || auth.IsKubernetesAdmin(*ctx.UserInfo) {
+	if isPrivilegedUser(ctx) {
 		return allErrs
 	}
 	volumesPath := field.NewPath("spec", "volumes")
@@ -366,6 +479,28 @@ func (v validator) validateInstanceStorageVolumes(ctx *context.WebhookRequestCon
 	return allErrs
 }
 
+// validateInstanceStorageReplace validates that the instance storage volumes are only replaced when the VM is
+// powered off, since replacing them deletes their disks.
+func (v validator) validateInstanceStorageReplace(ctx *context.WebhookRequestContext, vm, oldVM *vmopv1.VirtualMachine) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if _, ok := vm.Annotations[vmopapi.InstanceStorageReplaceAnnotation]; !ok {
+		return allErrs
+	}
+	if _, ok := oldVM.Annotations[vmopapi.InstanceStorageReplaceAnnotation]; ok {
+		return allErrs
+	}
+
+	replacePath := field.NewPath("metadata", "annotations").Key(vmopapi.InstanceStorageReplaceAnnotation)
+	if !instancestorage.IsConfigured(vm) {
+		allErrs = append(allErrs, field.Forbidden(replacePath, instanceStorageReplaceNotConfigured))
+	} else if vm.Spec.PowerState != vmopv1.VirtualMachinePoweredOff {
+		allErrs = append(allErrs, field.Forbidden(replacePath, instanceStorageReplaceNotPoweredOff))
+	}
+
+	return allErrs
+}
+
 func (v validator) validateVolumes(ctx *context.WebhookRequestContext, vm *vmopv1.VirtualMachine) field.ErrorList {
 	var allErrs field.ErrorList
 
@@ -401,22 +536,120 @@ func (v validator) validateVolumes(ctx *context.WebhookRequestContext, vm *vmopv
 	return allErrs
 }
 
-func (v validator) validateVolumeWithPVC(ctx *context.WebhookRequestContext, vm *vmopv1.VirtualMachine,
-	vol vmopv1.VirtualMachineVolume, volPath *field.Path) field.ErrorList {
+// validateVolumePlacements validates the controllers and units requested for the volumes by the
+// VirtualMachineVolumePlacementAnnotation.
+func (v validator) validateVolumePlacements(ctx *context.WebhookRequestContext, vm *vmopv1.VirtualMachine) field.ErrorList {
 	var allErrs field.ErrorList
 
-	imageNamePath := field.NewPath("spec", "imageName")
-	image := vmopv1.VirtualMachineImage{}
-	err := v.client.Get(ctx, types.NamespacedName{Name: vm.Spec.ImageName}, &image)
+	annotationPath := field.NewPath("metadata", "annotations").Key(vmopapi.VirtualMachineVolumePlacementAnnotation)
+
+	placements, err := vmopapi.GetVolumePlacements(vm)
 	if err != nil {
-		allErrs = append(allErrs, field.Invalid(imageNamePath, vm.Spec.ImageName,
-			fmt.Sprintf("error validating image for PVC: %v", err)))
+		return append(allErrs, field.Invalid(annotationPath, vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation], err.Error()))
+	}
+
+	volumes := map[string]vmopv1.VirtualMachineVolume{}
+	for _, vol := range vm.Spec.Volumes {
+		volumes[vol.Name] = vol
+	}
+
+	placedVolumes := map[string]bool{}
+	usedUnits := map[string]string{}
+	scsiBusTypes := map[int32]vmopapi.VirtualMachineVolumeControllerType{}
+	for _, placement := range placements {
+		vol, ok := volumes[placement.VolumeName]
+		if !ok {
+			allErrs = append(allErrs, field.Invalid(annotationPath, placement.VolumeName, volumePlacementVolumeNotFound))
+			continue
+		}
+		// Placing PersistentVolumeClaim volumes is not supported: their disks are attached by CNS, which picks
+		// their controller and unit.
+		if vol.VsphereVolume == nil {
+			allErrs = append(allErrs, field.Invalid(annotationPath, placement.VolumeName, volumePlacementPVCNotSupported))
+			continue
+		}
+		if placedVolumes[placement.VolumeName] {
+			allErrs = append(allErrs, field.Duplicate(annotationPath, placement.VolumeName))
+			continue
+		}
+		placedVolumes[placement.VolumeName] = true
+
+		controllerType := placement.GetControllerType()
+		maxUnitNumber := int32(vmopapi.MaxSCSIUnitNumber)
+		switch controllerType {
+		case vmopapi.VirtualMachineVolumeControllerParaVirtualSCSI, vmopapi.VirtualMachineVolumeControllerLsiLogic:
+		case vmopapi.VirtualMachineVolumeControllerNVMe:
+			maxUnitNumber = vmopapi.MaxNVMeUnitNumber
+		default:
+			allErrs = append(allErrs, field.NotSupported(annotationPath, controllerType, []string{
+				string(vmopapi.VirtualMachineVolumeControllerParaVirtualSCSI),
+				string(vmopapi.VirtualMachineVolumeControllerLsiLogic),
+				string(vmopapi.VirtualMachineVolumeControllerNVMe),
+			}))
+			continue
+		}
+
+		busNumber := placement.GetBusNumber()
+		if busNumber < 0 || busNumber > vmopapi.MaxVolumeControllerBusNumber {
+			allErrs = append(allErrs, field.Invalid(annotationPath, busNumber,
+				fmt.Sprintf(volumePlacementBusNumberOutOfRangeFmt, vmopapi.MaxVolumeControllerBusNumber)))
+			continue
+		}
+
+		// A SCSI bus number is shared by the ParaVirtualSCSI and LsiLogic controllers of the VM.
+		if controllerType != vmopapi.VirtualMachineVolumeControllerNVMe {
+			if otherType, ok := scsiBusTypes[busNumber]; ok && otherType != controllerType {
+				allErrs = append(allErrs, field.Invalid(annotationPath, placement.VolumeName,
+					fmt.Sprintf(volumePlacementSCSIBusInUseFmt, busNumber, otherType)))
+				continue
+			}
+			scsiBusTypes[busNumber] = controllerType
+		}
+
+		if placement.UnitNumber == nil {
+			continue
+		}
+
+		unitNumber := *placement.UnitNumber
+		if unitNumber < 0 || unitNumber > maxUnitNumber ||
+			(controllerType != vmopapi.VirtualMachineVolumeControllerNVMe && unitNumber == vmopapi.SCSIControllerUnitNumber) {
+			allErrs = append(allErrs, field.Invalid(annotationPath, unitNumber,
+				fmt.Sprintf(volumePlacementUnitNumberInvalidFmt, controllerType)))
+			continue
+		}
+
+		unit := fmt.Sprintf("%s %d:%d", controllerType, busNumber, unitNumber)
+		if otherVolume, ok := usedUnits[unit]; ok {
+			allErrs = append(allErrs, field.Invalid(annotationPath, placement.VolumeName,
+				fmt.Sprintf(volumePlacementUnitInUseFmt, unit, otherVolume)))
+			continue
+		}
+		usedUnits[unit] = placement.VolumeName
 	}
 
-	// Check that the VirtualMachineImage's hardware version is at least the minimum supported virtual hardware version
-	if image.Spec.HardwareVersion != 0 && image.Spec.HardwareVersion < constants.MinSupportedHWVersionForPVC {
-		allErrs = append(allErrs, field.Invalid(imageNamePath, vm.Spec.ImageName,
-			fmt.Sprintf(pvcHardwareVersionNotSupportedFmt, image.Spec.HardwareVersion, constants.MinSupportedHWVersionForPVC)))
+	return allErrs
+}
+
+func (v validator) validateVolumeWithPVC(ctx *context.WebhookRequestContext, vm *vmopv1.VirtualMachine,
+	vol vmopv1.VirtualMachineVolume, volPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	// An imported VM may not have an image to check the hardware version of.
+	if vm.Spec.ImageName != "" || !vmopapi.IsImportedVM(vm) {
+		imageNamePath := field.NewPath("spec", "imageName")
+		image := vmopv1.VirtualMachineImage{}
+		err := v.client.Get(ctx, types.NamespacedName{Name: vm.Spec.ImageName}, &image)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(imageNamePath, vm.Spec.ImageName,
+				fmt.Sprintf("error validating image for PVC: %v", err)))
+		}
+
+		// Check that the VirtualMachineImage's hardware version is at least the minimum supported virtual hardware version
+		if image.Spec.HardwareVersion != 0 && image.Spec.HardwareVersion < constants.MinSupportedHWVersionForPVC {
+			allErrs = append(allErrs, field.Invalid(imageNamePath, vm.Spec.ImageName,
+				fmt.Sprintf(pvcHardwareVersionNotSupportedFmt, image.Spec.HardwareVersion, constants.MinSupportedHWVersionForPVC)+
+					compatibilityReportReference(&image)))
+		}
 	}
 
 	// Check that the name used for the CnsNodeVmAttachment will be valid. Don't double up errors if name is missing.
@@ -473,34 +706,172 @@ func (v validator) validateVMVolumeProvisioningOptions(ctx *context.WebhookReque
 func (v validator) validateReadinessProbe(ctx *context.WebhookRequestContext, vm *vmopv1.VirtualMachine) field.ErrorList {
 	var allErrs field.ErrorList
 
+	readinessProbePath := field.NewPath("spec", "readinessProbe")
+	probeActionsPath := field.NewPath("metadata", "annotations").Key(vmopapi.ReadinessProbeActionsAnnotation)
+
+	probeActions, err := vmopapi.GetReadinessProbeActions(vm)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(probeActionsPath, vm.Annotations[vmopapi.ReadinessProbeActionsAnnotation], err.Error()))
+	}
+
 	probe := vm.Spec.ReadinessProbe
 	if probe == nil {
+		if probeActions != nil {
+			allErrs = append(allErrs, field.Required(readinessProbePath, readinessProbeActionsRequireProbe))
+		}
 		return allErrs
 	}
 
-	readinessProbePath := field.NewPath("spec", "readinessProbe")
+	numActions := 0
+	for _, isSet := range []bool{
+		probe.TCPSocket != nil,
+		probe.GuestHeartbeat != nil,
+		probeActions != nil && probeActions.HTTPGet != nil,
+		probeActions != nil && probeActions.Exec != nil,
+	} {
+		if isSet {
+			numActions++
+		}
+	}
 
-	if probe.TCPSocket == nil && probe.GuestHeartbeat == nil {
+	if numActions == 0 {
 		allErrs = append(allErrs, field.Forbidden(readinessProbePath, readinessProbeNoActions))
-	} else if probe.TCPSocket != nil && probe.GuestHeartbeat != nil {
+	} else if numActions > 1 {
 		allErrs = append(allErrs, field.Forbidden(readinessProbePath, readinessProbeOnlyOneAction))
 	}
 
-	// Validate the TCP probe if set and environment is a restricted network environment between CP VMs and Workload VMs e.g. VMC
+	if probeActions != nil {
+		if probeActions.HTTPGet != nil {
+			allErrs = append(allErrs, validateHTTPGetAction(probeActions.HTTPGet, probeActionsPath.Child("httpGet"))...)
+		}
+		if probeActions.Exec != nil {
+			allErrs = append(allErrs, validateExecAction(probeActions.Exec, probeActionsPath.Child("exec"))...)
+		}
+	}
+
+	// Validate the TCP and HTTP GET probe port if set and environment is a restricted network environment between
+	// CP VMs and Workload VMs e.g. VMC. The exec probe is run through the vSphere guest operations instead of the
+	// workload network so it is allowed regardless.
 	if probe.TCPSocket != nil {
-		tcpSocketPath := readinessProbePath.Child("tcpSocket")
-		isRestrictedEnv, err := v.isNetworkRestrictedForReadinessProbe(ctx)
-		if err != nil {
-			allErrs = append(allErrs, field.Forbidden(tcpSocketPath, err.Error()))
-		} else if isRestrictedEnv && probe.TCPSocket.Port.IntValue() != allowedRestrictedNetworkTCPProbePort {
-			allErrs = append(allErrs, field.NotSupported(tcpSocketPath.Child("port"), probe.TCPSocket.Port.IntValue(),
-				[]string{strconv.Itoa(allowedRestrictedNetworkTCPProbePort)}))
+		allErrs = append(allErrs, v.validateRestrictedNetworkProbePort(ctx, probe.TCPSocket.Port, readinessProbePath.Child("tcpSocket"))...)
+	}
+	if probeActions != nil && probeActions.HTTPGet != nil {
+		allErrs = append(allErrs, v.validateRestrictedNetworkProbePort(ctx, probeActions.HTTPGet.Port, probeActionsPath.Child("httpGet"))...)
+	}
+
+	return allErrs
+}
+
+func (v validator) validateLivenessProbe(ctx *context.WebhookRequestContext, vm *vmopv1.VirtualMachine) field.ErrorList {
+	var allErrs field.ErrorList
+
+	livenessProbePath := field.NewPath("metadata", "annotations").Key(vmopapi.LivenessProbeAnnotation)
+
+	probe, err := vmopapi.GetLivenessProbe(vm)
+	if err != nil {
+		return append(allErrs, field.Invalid(livenessProbePath, vm.Annotations[vmopapi.LivenessProbeAnnotation], err.Error()))
+	}
+	if probe == nil {
+		return allErrs
+	}
+
+	numActions := 0
+	for _, isSet := range []bool{probe.TCPSocket != nil, probe.GuestHeartbeat != nil, probe.HTTPGet != nil, probe.Exec != nil} {
+		if isSet {
+			numActions++
+		}
+	}
+
+	if numActions == 0 {
+		allErrs = append(allErrs, field.Forbidden(livenessProbePath, readinessProbeNoActions))
+	} else if numActions > 1 {
+		allErrs = append(allErrs, field.Forbidden(livenessProbePath, readinessProbeOnlyOneAction))
+	}
+
+	if probe.HTTPGet != nil {
+		allErrs = append(allErrs, validateHTTPGetAction(probe.HTTPGet, livenessProbePath.Child("httpGet"))...)
+		allErrs = append(allErrs, v.validateRestrictedNetworkProbePort(ctx, probe.HTTPGet.Port, livenessProbePath.Child("httpGet"))...)
+	}
+	if probe.Exec != nil {
+		allErrs = append(allErrs, validateExecAction(probe.Exec, livenessProbePath.Child("exec"))...)
+	}
+	if probe.TCPSocket != nil {
+		allErrs = append(allErrs, v.validateRestrictedNetworkProbePort(ctx, probe.TCPSocket.Port, livenessProbePath.Child("tcpSocket"))...)
+	}
+
+	if probe.FailureThreshold < 0 {
+		allErrs = append(allErrs, field.Invalid(livenessProbePath.Child("failureThreshold"), probe.FailureThreshold, validation.IsNegativeErrorMsg))
+	}
+	if probe.SuccessThreshold < 0 {
+		allErrs = append(allErrs, field.Invalid(livenessProbePath.Child("successThreshold"), probe.SuccessThreshold, validation.IsNegativeErrorMsg))
+	}
+
+	switch probe.Action {
+	case "", vmopapi.LivenessActionEventOnly, vmopapi.LivenessActionReset, vmopapi.LivenessActionPowerCycle:
+	default:
+		allErrs = append(allErrs, field.NotSupported(livenessProbePath.Child("action"), probe.Action,
+			[]string{string(vmopapi.LivenessActionEventOnly), string(vmopapi.LivenessActionReset), string(vmopapi.LivenessActionPowerCycle)}))
+	}
+
+	return allErrs
+}
+
+func (v validator) validateRestrictedNetworkProbePort(
+	ctx *context.WebhookRequestContext,
+	port intstr.IntOrString,
+	actionPath *field.Path) field.ErrorList {
+
+	var allErrs field.ErrorList
+
+	isRestrictedEnv, err := v.isNetworkRestrictedForReadinessProbe(ctx)
+	if err != nil {
+		allErrs = append(allErrs, field.Forbidden(actionPath, err.Error()))
+	} else if isRestrictedEnv && port.IntValue() != allowedRestrictedNetworkTCPProbePort {
+		allErrs = append(allErrs, field.NotSupported(actionPath.Child("port"), port.IntValue(),
+			[]string{strconv.Itoa(allowedRestrictedNetworkTCPProbePort)}))
+	}
+
+	return allErrs
+}
+
+func validateHTTPGetAction(action *vmopapi.HTTPGetAction, actionPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if action.Port.Type == intstr.Int && (action.Port.IntValue() < 1 || action.Port.IntValue() > 65535) {
+		allErrs = append(allErrs, field.Invalid(actionPath.Child("port"), action.Port.IntValue(), "must be between 1 and 65535"))
+	} else if action.Port.Type == intstr.String && action.Port.StrVal == "" {
+		allErrs = append(allErrs, field.Required(actionPath.Child("port"), ""))
+	}
+
+	switch action.Scheme {
+	case "", corev1.URISchemeHTTP, corev1.URISchemeHTTPS:
+	default:
+		allErrs = append(allErrs, field.NotSupported(actionPath.Child("scheme"), action.Scheme,
+			[]string{string(corev1.URISchemeHTTP), string(corev1.URISchemeHTTPS)}))
+	}
+
+	if r := action.SuccessStatusRange; r != nil {
+		if r.Min < 100 || r.Max > 599 || r.Min > r.Max {
+			allErrs = append(allErrs, field.Invalid(actionPath.Child("successStatusRange"), *r, readinessProbeHTTPStatusRangeInvalid))
 		}
 	}
 
 	return allErrs
 }
 
+func validateExecAction(action *vmopapi.ExecAction, actionPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(action.Command) == 0 || action.Command[0] == "" {
+		allErrs = append(allErrs, field.Required(actionPath.Child("command"), ""))
+	}
+	if action.CredentialsSecretName == "" {
+		allErrs = append(allErrs, field.Required(actionPath.Child("credentialsSecretName"), ""))
+	}
+
+	return allErrs
+}
+
 func (v validator) validateUpdatesWhenPoweredOn(ctx *context.WebhookRequestContext, vm, oldVM *vmopv1.VirtualMachine) field.ErrorList {
 	var allErrs field.ErrorList
 
@@ -525,6 +896,11 @@ func (v validator) validateUpdatesWhenPoweredOn(ctx *context.WebhookRequestConte
 		allErrs = append(allErrs, v.validateVsphereVolumesUpdateWhenPoweredOn(ctx, vm, oldVM)...)
 	}
 
+	if vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] != oldVM.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] {
+		allErrs = append(allErrs, field.Forbidden(
+			field.NewPath("metadata", "annotations").Key(vmopapi.VirtualMachineVolumePlacementAnnotation), updatesNotAllowedWhenPowerOn))
+	}
+
 	return allErrs
 }
 
@@ -551,24 +927,25 @@ func (v validator) validateAdvancedOptionsUpdateWhenPoweredOn(ctx *context.Webho
 }
 
 // validateVsphereVolumesUpdateWhenPoweredOn validates that Volume update request is valid when the VM is powered on.
-// We do not support any modifications to vSphere volumes while the VM is powered on.
+// vSphere volumes cannot be added, removed or modified while the VM is powered on, except to increase their
+// capacity, which expands the disk online.
 func (v validator) validateVsphereVolumesUpdateWhenPoweredOn(ctx *context.WebhookRequestContext, vm, oldVM *vmopv1.VirtualMachine) field.ErrorList {
 	var allErrs field.ErrorList
 
 	fieldPath := field.NewPath("spec", "volumes").Key("VsphereVolume")
 
-	// Do not allow modifications to vSphere Volumes while the VM is powered on.
+	// Compare the vSphere Volumes without their capacity, which is validated by validateVsphereVolumesCapacityUpdate.
 	oldvSphereVolumes := make(map[string]vmopv1.VirtualMachineVolume)
 	for _, vol := range oldVM.Spec.Volumes {
 		if vol.VsphereVolume != nil {
-			oldvSphereVolumes[vol.Name] = vol
+			oldvSphereVolumes[vol.Name] = withoutVsphereVolumeCapacity(vol)
 		}
 	}
 
 	newvSphereVolumes := make(map[string]vmopv1.VirtualMachineVolume)
 	for _, vol := range vm.Spec.Volumes {
 		if vol.VsphereVolume != nil {
-			newvSphereVolumes[vol.Name] = vol
+			newvSphereVolumes[vol.Name] = withoutVsphereVolumeCapacity(vol)
 		}
 	}
 
@@ -579,13 +956,69 @@ func (v validator) validateVsphereVolumesUpdateWhenPoweredOn(ctx *context.Webhoo
 	return allErrs
 }
 
+func withoutVsphereVolumeCapacity(vol vmopv1.VirtualMachineVolume) vmopv1.VirtualMachineVolume {
+	vol = *vol.DeepCopy()
+	vol.VsphereVolume.Capacity = nil
+	return vol
+}
+
+// validateVsphereVolumesCapacityUpdate validates that the capacity of the existing vSphere volumes is not less than
+// the capacity of their disks, since disks cannot be shrunk. The capacity can be reverted to the capacity of the
+// disk after a failed expansion. When the capacity of the disk is not known, the capacity must not be decreased.
+func (v validator) validateVsphereVolumesCapacityUpdate(ctx *context.WebhookRequestContext, vm, oldVM *vmopv1.VirtualMachine) field.ErrorList {
+	var allErrs field.ErrorList
+
+	diskCapacities := make(map[int]int64)
+	if details, err := vmopapi.GetVirtualMachineStatusDetails(oldVM); err == nil && details != nil {
+		for _, disk := range details.Disks {
+			if disk.DeviceKey != 0 {
+				diskCapacities[int(disk.DeviceKey)] = disk.CapacityBytes
+			}
+		}
+	}
+
+	oldCapacities := make(map[string]*resource.Quantity)
+	for _, vol := range oldVM.Spec.Volumes {
+		if vol.VsphereVolume != nil && vol.VsphereVolume.DeviceKey != nil {
+			oldCapacities[vol.Name] = vol.VsphereVolume.Capacity.StorageEphemeral()
+		}
+	}
+
+	volumesPath := field.NewPath("spec", "volumes")
+	for i, vol := range vm.Spec.Volumes {
+		if vol.VsphereVolume == nil || vol.VsphereVolume.DeviceKey == nil {
+			continue
+		}
+
+		minCapacity, ok := oldCapacities[vol.Name]
+		if !ok {
+			continue
+		}
+		if diskCapacity, ok := diskCapacities[*vol.VsphereVolume.DeviceKey]; ok {
+			minCapacity = resource.NewQuantity(diskCapacity, resource.BinarySI)
+		}
+
+		capacity := vol.VsphereVolume.Capacity.StorageEphemeral()
+		if capacity.Cmp(*minCapacity) < 0 {
+			fieldPath := volumesPath.Index(i).Child("vsphereVolume", "capacity", "ephemeral-storage")
+			allErrs = append(allErrs, field.Invalid(fieldPath, capacity.String(), fmt.Sprintf(vSphereVolumeShrinkNotAllowedFmt, minCapacity.String())))
+		}
+	}
+
+	return allErrs
+}
+
 func (v validator) validateImmutableFields(ctx *context.WebhookRequestContext, vm, oldVM *vmopv1.VirtualMachine) field.ErrorList {
 	var allErrs field.ErrorList
 
 	specPath := field.NewPath("spec")
 
 	allErrs = append(allErrs, validation.ValidateImmutableField(vm.Spec.ImageName, oldVM.Spec.ImageName, specPath.Child("imageName"))...)
-	allErrs = append(allErrs, validation.ValidateImmutableField(vm.Spec.ClassName, oldVM.Spec.ClassName, specPath.Child("className"))...)
+	// The class can be changed to resize the VM. It can only be removed from an imported VM, whose class is
+	// then set from the VM's hardware, like when it is created.
+	if vm.Spec.ClassName != oldVM.Spec.ClassName {
+		allErrs = append(allErrs, v.validateClass(ctx, vm)...)
+	}
 	allErrs = append(allErrs, validation.ValidateImmutableField(vm.Spec.StorageClass, oldVM.Spec.StorageClass, specPath.Child("storageClass"))...)
 	allErrs = append(allErrs, validation.ValidateImmutableField(vm.Spec.ResourcePolicyName, oldVM.Spec.ResourcePolicyName, specPath.Child("resourcePolicyName"))...)
 
@@ -596,11 +1029,28 @@ func (v validator) validateAvailabilityZone(ctx *context.WebhookRequestContext,
 	var allErrs field.ErrorList
 
 	zoneLabelPath := field.NewPath("metadata", "labels").Key(topology.KubernetesTopologyZoneLabelKey)
+	relocatePath := field.NewPath("metadata", "annotations").Key(vmopapi.RelocateToZoneAnnotation)
+
+	var oldRelocateZone string
+	if oldVM != nil {
+		oldRelocateZone = oldVM.Annotations[vmopapi.RelocateToZoneAnnotation]
+	}
+
+	// Validate the name of the availability zone the VM is relocated to.
+	if zone, ok := vm.Annotations[vmopapi.RelocateToZoneAnnotation]; ok && zone != oldRelocateZone {
+		if _, err := topology.GetAvailabilityZone(ctx.Context, v.client, zone); err != nil {
+			allErrs = append(allErrs, field.Invalid(relocatePath, zone, err.Error()))
+		}
+	}
 
-	// If there is an oldVM in play then make sure the field is immutable.
+	// If there is an oldVM in play then make sure the field is immutable, except when VM Operator updates it
+	// once the VM was relocated to the zone in the oldVM's relocate annotation.
 	if oldVM != nil {
 		newVal := vm.Labels[topology.KubernetesTopologyZoneLabelKey]
 		oldVal := oldVM.Labels[topology.KubernetesTopologyZoneLabelKey]
+		if oldRelocateZone != "" && newVal == oldRelocateZone && isPrivilegedUser(ctx) {
+			return allErrs
+		}
 		return append(allErrs, validation.ValidateImmutableField(newVal, oldVal, zoneLabelPath)...)
 	}
 
@@ -614,6 +1064,123 @@ func (v validator) validateAvailabilityZone(ctx *context.WebhookRequestContext,
 	return allErrs
 }
 
+// validateImport validates the import annotations. The annotations are immutable, only privileged users can
+// set them, and a VM cannot import a vSphere VM that is already managed by another VirtualMachine.
+func (v validator) validateImport(ctx *context.WebhookRequestContext, vm, oldVM *vmopv1.VirtualMachine) field.ErrorList {
+	var allErrs field.ErrorList
+
+	annotationsPath := field.NewPath("metadata", "annotations")
+	moIDPath := annotationsPath.Key(vmopapi.ImportVMMoIDAnnotation)
+	instanceUUIDPath := annotationsPath.Key(vmopapi.ImportVMInstanceUUIDAnnotation)
+	moID := vm.Annotations[vmopapi.ImportVMMoIDAnnotation]
+	instanceUUID := vm.Annotations[vmopapi.ImportVMInstanceUUIDAnnotation]
+
+	if oldVM != nil {
+		allErrs = append(allErrs, validation.ValidateImmutableField(moID, oldVM.Annotations[vmopapi.ImportVMMoIDAnnotation], moIDPath)...)
+		allErrs = append(allErrs, validation.ValidateImmutableField(instanceUUID, oldVM.Annotations[vmopapi.ImportVMInstanceUUIDAnnotation], instanceUUIDPath)...)
+		return allErrs
+	}
+
+	if moID == "" && instanceUUID == "" {
+		return allErrs
+	}
+
+	// The imported vSphere VM is moved into the namespace, so only privileged users can import VMs.
+	if !isPrivilegedUser(ctx) {
+		if moID != "" {
+			allErrs = append(allErrs, field.Forbidden(moIDPath, importAnnotationPrivilegedOnly))
+		}
+		if instanceUUID != "" {
+			allErrs = append(allErrs, field.Forbidden(instanceUUIDPath, importAnnotationPrivilegedOnly))
+		}
+		return allErrs
+	}
+
+	for _, check := range []struct {
+		path  *field.Path
+		value string
+		index string
+	}{
+		{moIDPath, moID, indexer.VirtualMachineMoIDField},
+		{instanceUUIDPath, instanceUUID, indexer.VirtualMachineInstanceUUIDField},
+	} {
+		if check.value == "" {
+			continue
+		}
+
+		otherVM, err := v.getVMByIndex(ctx, check.index, check.value, vm)
+		if err != nil {
+			allErrs = append(allErrs, field.InternalError(check.path, err))
+		} else if otherVM != nil {
+			allErrs = append(allErrs, field.Forbidden(check.path, fmt.Sprintf(importVMAlreadyManagedFmt, otherVM.NamespacedName())))
+		}
+	}
+
+	return allErrs
+}
+
+// getVMByIndex returns a VirtualMachine other than vm that has the value in the field index, or nil if there
+// is no such VirtualMachine.
+func (v validator) getVMByIndex(
+	ctx *context.WebhookRequestContext,
+	index, value string,
+	vm *vmopv1.VirtualMachine) (*vmopv1.VirtualMachine, error) {
+
+	vmList := &vmopv1.VirtualMachineList{}
+	if err := v.client.List(ctx, vmList, client.MatchingFields{index: value}); err != nil {
+		return nil, err
+	}
+
+	for i := range vmList.Items {
+		otherVM := &vmList.Items[i]
+		if otherVM.Namespace == vm.Namespace && otherVM.Name == vm.Name {
+			continue
+		}
+
+		// Check the fields again since the field selector is not applied by every client.
+		switch index {
+		case indexer.VirtualMachineMoIDField:
+			if otherVM.Status.UniqueID == value || otherVM.Annotations[vmopapi.ImportVMMoIDAnnotation] == value {
+				return otherVM, nil
+			}
+		case indexer.VirtualMachineInstanceUUIDField:
+			if otherVM.Status.InstanceUUID == value || otherVM.Annotations[vmopapi.ImportVMInstanceUUIDAnnotation] == value {
+				return otherVM, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// validateRestore validates that only privileged users set the restore annotations, since the VM adopts the
+// vSphere VM that the annotations point to.
+func (v validator) validateRestore(ctx *context.WebhookRequestContext, vm, oldVM *vmopv1.VirtualMachine) field.ErrorList {
+	var allErrs field.ErrorList
+
+	annotationsPath := field.NewPath("metadata", "annotations")
+	for _, key := range []string{vmopapi.RestoreInstanceUUIDAnnotation, vmopapi.RestoreBiosUUIDAnnotation} {
+		var oldVal string
+		if oldVM != nil {
+			oldVal = oldVM.Annotations[key]
+		}
+		if val := vm.Annotations[key]; val != "" && val != oldVal && !isPrivilegedUser(ctx) {
+			allErrs = append(allErrs, field.Forbidden(annotationsPath.Key(key), restoreAnnotationPrivilegedOnly))
+		}
+	}
+
+	return allErrs
+}
+
+// isPrivilegedUser returns true if the request is from the VM Operator service account or the Kubernetes
+// administrator.
+func isPrivilegedUser(ctx *context.WebhookRequestContext) bool {
+	if ctx.UserInfo == nil {
+		return false
+	}
+	return auth.IsPODServiceAccountUser(*ctx.UserInfo) || auth.IsKubernetesAdmin(*ctx.UserInfo)
+}
+
 // vmFromUnstructured returns the VirtualMachine from the unstructured object.
 func (v validator) vmFromUnstructured(obj runtime.Unstructured) (*vmopv1.VirtualMachine, error) {
 	vm := &vmopv1.VirtualMachine{}
@@ -626,13 +1193,13 @@ func (v validator) vmFromUnstructured(obj runtime.Unstructured) (*vmopv1.Virtual
 func (v validator) isNetworkRestrictedForReadinessProbe(ctx *context.WebhookRequestContext) (bool, error) {
 	vmopNamespace, err := lib.GetVMOpNamespaceFromEnv()
 	if err != nil {
-		return false, fmt.Errorf("error fetching VMOpNamespace while validating TCP readiness probe port: %v", err)
+		return false, fmt.Errorf("error fetching VMOpNamespace while validating readiness probe port: %v", err)
 	}
 	configMap := &corev1.ConfigMap{}
 	configMapKey := types.NamespacedName{Name: config.ProviderConfigMapName, Namespace: vmopNamespace}
 	err = v.client.Get(ctx, configMapKey, configMap)
 	if err != nil {
-		return false, fmt.Errorf("error fetching config map: %s while validating TCP readiness probe port: %v", config.ProviderConfigMapName, err)
+		return false, fmt.Errorf("error fetching config map: %s while validating readiness probe port: %v", config.ProviderConfigMapName, err)
 	}
 	restrictedNetworkEnv := configMap.Data[isRestrictedNetworkKey]
 	return restrictedNetworkEnv == "true", nil
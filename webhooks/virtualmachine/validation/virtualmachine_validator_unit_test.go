@@ -4,6 +4,7 @@
 package validation_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -24,6 +25,7 @@ import (
 
 	vmopv1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
 
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
 	"github.com/acharyasreej/vm-operator/pkg/lib"
 	"github.com/acharyasreej/vm-operator/pkg/topology"
 	"github.com/acharyasreej/vm-operator/pkg/vmprovider/providers/vsphere/config"
@@ -32,7 +34,12 @@ import (
 	"github.com/acharyasreej/vm-operator/test/builder"
 )
 
-const updateSuffix = "-updated"
+const (
+	updateSuffix         = "-updated"
+	importVMMoID         = "vm-42"
+	importVMInstanceUUID = "import-vm-instance-uuid"
+	vsphereVolumeName    = "vsphere-volume"
+)
 
 func unitTests() {
 	Describe("Invoking ValidateCreate", unitTestsValidateCreate)
@@ -104,6 +111,15 @@ func setReadinessProbe(validPortProbe bool) *vmopv1.Probe {
 	}
 }
 
+func setReadinessProbeHTTPGetAction(vm *vmopv1.VirtualMachine, validPortProbe bool) {
+	portValue := 6443
+	if !validPortProbe {
+		portValue = 443
+	}
+	vm.Spec.ReadinessProbe = &vmopv1.Probe{}
+	vm.Annotations[vmopapi.ReadinessProbeActionsAnnotation] = fmt.Sprintf(`{"httpGet": {"path": "/healthz", "port": %d}}`, portValue)
+}
+
 // nolint:gocyclo
 func unitTestsValidateCreate() {
 	var (
@@ -138,13 +154,28 @@ func unitTestsValidateCreate() {
 		notfoundStorageClass                 bool
 		validStorageClass                    bool
 		imageNonCompatible                   bool
+		imageNonCompatibleWithReport         bool
 		imageSupportCheckSkipAnnotation      bool
 		imageNonCompatibleCloudInitTransport bool
+		imageNonCompatibleIgnitionTransport  bool
+		unsupportedMetadataTransport         bool
+		ignitionTransportNotExtraConfig      bool
+		invalidIgnitionConfig                bool
 		invalidReadinessNoProbe              bool
 		invalidReadinessProbe                bool
 		isRestrictedNetworkEnv               bool
 		isRestrictedNetworkValidProbePort    bool
 		isNonRestrictedNetworkEnv            bool
+		isHTTPGetReadinessProbe              bool
+		execReadinessProbe                   bool
+		invalidExecReadinessProbe            bool
+		invalidHTTPGetReadinessProbe         bool
+		invalidReadinessProbeActions         bool
+		readinessProbeActionsWithoutProbe    bool
+		readinessProbeActionsWithTCPProbe    bool
+		validLivenessProbe                   bool
+		invalidLivenessProbeAction           bool
+		livenessProbeNoActions               bool
 		isNoAvailabilityZones                bool
 		isWCPFaultDomainsFSSEnabled          bool
 		isInvalidAvailabilityZone            bool
@@ -152,6 +183,18 @@ func unitTestsValidateCreate() {
 		isWCPInstanceStorageFSSEnabled       bool
 		isServiceUser                        bool
 		addInstanceStorageVolumes            bool
+		importVM                             bool
+		importVMManagedByOtherVM             bool
+		importVMImportedByOtherVM            bool
+		restoreVM                            bool
+		validVolumePlacement                 bool
+		invalidVolumePlacement               bool
+		volumePlacementVolumeNotFound        bool
+		volumePlacementInvalidBusNumber      bool
+		volumePlacementSCSIControllerUnit    bool
+		volumePlacementUnitInUse             bool
+		volumePlacementPVC                   bool
+		volumePlacementSCSIBusInUse          bool
 	}
 
 	validateCreate := func(args createArgs, expectedAllowed bool, expectedReason string, expectedErr error) {
@@ -170,12 +213,40 @@ func unitTestsValidateCreate() {
 			ctx.vmImage.Status.ImageSupported = &[]bool{false}[0]
 			Expect(ctx.Client.Status().Update(ctx, ctx.vmImage)).ToNot(HaveOccurred())
 		}
+		if args.imageNonCompatibleWithReport {
+			ctx.vmImage.Annotations = map[string]string{
+				vmopapi.VirtualMachineImageCompatibilityReportAnnotation: `{"supported":false,"reasons":["reason-1","reason-2"]}`,
+			}
+			Expect(ctx.Client.Update(ctx, ctx.vmImage)).ToNot(HaveOccurred())
+			ctx.vmImage.Status.ImageSupported = &[]bool{false}[0]
+			Expect(ctx.Client.Status().Update(ctx, ctx.vmImage)).ToNot(HaveOccurred())
+		}
 		if args.imageSupportCheckSkipAnnotation {
 			ctx.vm.Annotations[constants.VMOperatorImageSupportedCheckKey] = constants.VMOperatorImageSupportedCheckDisable
 		}
 		if args.imageNonCompatibleCloudInitTransport {
 			ctx.vm.Spec.VmMetadata.Transport = vmopv1.VirtualMachineMetadataCloudInitTransport
 		}
+		if args.imageNonCompatibleIgnitionTransport || args.ignitionTransportNotExtraConfig || args.invalidIgnitionConfig {
+			ctx.vm.Annotations[vmopapi.VirtualMachineMetadataTransportAnnotation] = string(vmopapi.VirtualMachineMetadataIgnitionTransport)
+		}
+		if args.unsupportedMetadataTransport {
+			ctx.vm.Annotations[vmopapi.VirtualMachineMetadataTransportAnnotation] = "Other"
+		}
+		if args.ignitionTransportNotExtraConfig {
+			ctx.vm.Spec.VmMetadata.Transport = vmopv1.VirtualMachineMetadataOvfEnvTransport
+		}
+		if args.invalidIgnitionConfig {
+			Expect(ctx.Client.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ctx.vm.Spec.VmMetadata.ConfigMapName,
+					Namespace: ctx.vm.Namespace,
+				},
+				Data: map[string]string{
+					vmopapi.IgnitionConfigKey: `{"ignition": {"version": "3.2.0"}, "storage": {"files": [{"path": "etc/hostname"}]}}`,
+				},
+			})).To(Succeed())
+		}
 		if args.invalidNetworkName {
 			ctx.vm.Spec.NetworkInterfaces[0].NetworkName = ""
 			ctx.vm.Spec.NetworkInterfaces[0].NetworkType = network.VdsNetworkType
@@ -277,9 +348,47 @@ func unitTestsValidateCreate() {
 		}
 		if args.isRestrictedNetworkEnv || args.isNonRestrictedNetworkEnv {
 			configMapIn := setConfigMap(args.isRestrictedNetworkEnv)
-			ctx.vm.Spec.ReadinessProbe = setReadinessProbe(args.isRestrictedNetworkValidProbePort)
+			if args.isHTTPGetReadinessProbe {
+				setReadinessProbeHTTPGetAction(ctx.vm, args.isRestrictedNetworkValidProbePort)
+			} else {
+				ctx.vm.Spec.ReadinessProbe = setReadinessProbe(args.isRestrictedNetworkValidProbePort)
+			}
 			Expect(ctx.Client.Create(ctx, configMapIn)).To(Succeed())
 		}
+		if args.execReadinessProbe || args.invalidExecReadinessProbe {
+			Expect(ctx.Client.Create(ctx, setConfigMap(true))).To(Succeed())
+			ctx.vm.Spec.ReadinessProbe = &vmopv1.Probe{}
+			ctx.vm.Annotations[vmopapi.ReadinessProbeActionsAnnotation] = `{"exec": {"command": ["/bin/true"], "credentialsSecretName": "creds"}}`
+			if args.invalidExecReadinessProbe {
+				ctx.vm.Annotations[vmopapi.ReadinessProbeActionsAnnotation] = `{"exec": {"command": []}}`
+			}
+		}
+		if args.invalidHTTPGetReadinessProbe {
+			ctx.vm.Spec.ReadinessProbe = &vmopv1.Probe{}
+			ctx.vm.Annotations[vmopapi.ReadinessProbeActionsAnnotation] = `{"httpGet": {"port": 0, "successStatusRange": {"min": 400, "max": 200}}}`
+		}
+		if args.invalidReadinessProbeActions {
+			ctx.vm.Spec.ReadinessProbe = &vmopv1.Probe{}
+			ctx.vm.Annotations[vmopapi.ReadinessProbeActionsAnnotation] = "not-json"
+		}
+		if args.readinessProbeActionsWithoutProbe {
+			setReadinessProbeHTTPGetAction(ctx.vm, true)
+			ctx.vm.Spec.ReadinessProbe = nil
+		}
+		if args.readinessProbeActionsWithTCPProbe {
+			Expect(ctx.Client.Create(ctx, setConfigMap(false))).To(Succeed())
+			setReadinessProbeHTTPGetAction(ctx.vm, true)
+			ctx.vm.Spec.ReadinessProbe = setReadinessProbe(true)
+		}
+		if args.validLivenessProbe {
+			ctx.vm.Annotations[vmopapi.LivenessProbeAnnotation] = `{"guestHeartbeat": {}, "failureThreshold": 5, "action": "Reset"}`
+		}
+		if args.invalidLivenessProbeAction {
+			ctx.vm.Annotations[vmopapi.LivenessProbeAnnotation] = `{"guestHeartbeat": {}, "action": "Reboot"}`
+		}
+		if args.livenessProbeNoActions {
+			ctx.vm.Annotations[vmopapi.LivenessProbeAnnotation] = `{"periodSeconds": 10}`
+		}
 		if args.isServiceUser {
 			Expect(os.Setenv("POD_SERVICE_ACCOUNT_NAME", "default")).To(Succeed())
 			Expect(os.Setenv("POD_NAMESPACE", "vmware-system-vmop")).To(Succeed())
@@ -289,6 +398,74 @@ func unitTestsValidateCreate() {
 			instanceStorageVolume := builder.DummyInstanceStorageVirtualMachineVolumes()
 			ctx.vm.Spec.Volumes = append(ctx.vm.Spec.Volumes, instanceStorageVolume...)
 		}
+		if args.importVM || args.importVMManagedByOtherVM || args.importVMImportedByOtherVM {
+			ctx.vm.Annotations[vmopapi.ImportVMMoIDAnnotation] = importVMMoID
+			ctx.vm.Annotations[vmopapi.ImportVMInstanceUUIDAnnotation] = importVMInstanceUUID
+			ctx.vm.Spec.ClassName = ""
+			ctx.vm.Spec.ImageName = ""
+		}
+		if args.importVMManagedByOtherVM {
+			otherVM := builder.DummyVirtualMachine()
+			otherVM.Name = "other-vm"
+			otherVM.Namespace = "other-namespace"
+			otherVM.Status.UniqueID = importVMMoID
+			Expect(ctx.Client.Create(ctx, otherVM)).To(Succeed())
+		}
+		if args.importVMImportedByOtherVM {
+			otherVM := builder.DummyVirtualMachine()
+			otherVM.Name = "other-vm"
+			otherVM.Namespace = "other-namespace"
+			otherVM.Annotations[vmopapi.ImportVMInstanceUUIDAnnotation] = importVMInstanceUUID
+			Expect(ctx.Client.Create(ctx, otherVM)).To(Succeed())
+		}
+		if args.restoreVM {
+			ctx.vm.Annotations[vmopapi.RestoreInstanceUUIDAnnotation] = "restore-instance-uuid"
+		}
+		if args.validVolumePlacement || args.volumePlacementPVC || args.volumePlacementInvalidBusNumber ||
+			args.volumePlacementSCSIControllerUnit || args.volumePlacementUnitInUse || args.volumePlacementSCSIBusInUse {
+			for _, name := range []string{vsphereVolumeName, vsphereVolumeName + "-2"} {
+				ctx.vm.Spec.Volumes = append(ctx.vm.Spec.Volumes, vmopv1.VirtualMachineVolume{
+					Name: name,
+					VsphereVolume: &vmopv1.VsphereVolumeSource{
+						Capacity: corev1.ResourceList{
+							corev1.ResourceEphemeralStorage: resource.MustParse("4Gi"),
+						},
+					},
+				})
+			}
+		}
+		if args.validVolumePlacement {
+			ctx.vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] =
+				fmt.Sprintf(`[{"volumeName": %q, "controllerType": "NVMe", "busNumber": 1, "unitNumber": 14}]`, vsphereVolumeName)
+		}
+		if args.invalidVolumePlacement {
+			ctx.vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] = "not-json"
+		}
+		if args.volumePlacementVolumeNotFound {
+			ctx.vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] = `[{"volumeName": "no-such-volume"}]`
+		}
+		if args.volumePlacementPVC {
+			ctx.vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] =
+				fmt.Sprintf(`[{"volumeName": %q}]`, builder.DummyVolumeName)
+		}
+		if args.volumePlacementInvalidBusNumber {
+			ctx.vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] =
+				fmt.Sprintf(`[{"volumeName": %q, "busNumber": 4}]`, vsphereVolumeName)
+		}
+		if args.volumePlacementSCSIControllerUnit {
+			ctx.vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] =
+				fmt.Sprintf(`[{"volumeName": %q, "controllerType": "LsiLogic", "unitNumber": 7}]`, vsphereVolumeName)
+		}
+		if args.volumePlacementUnitInUse {
+			ctx.vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] = fmt.Sprintf(
+				`[{"volumeName": %q, "unitNumber": 1}, {"volumeName": %q, "controllerType": "ParaVirtualSCSI", "busNumber": 0, "unitNumber": 1}]`,
+				vsphereVolumeName, vsphereVolumeName+"-2")
+		}
+		if args.volumePlacementSCSIBusInUse {
+			ctx.vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] = fmt.Sprintf(
+				`[{"volumeName": %q, "busNumber": 1}, {"volumeName": %q, "controllerType": "LsiLogic", "busNumber": 1}]`,
+				vsphereVolumeName, vsphereVolumeName+"-2")
+		}
 		lib.IsInstanceStorageFSSEnabled = func() bool {
 			return args.isWCPInstanceStorageFSSEnabled
 		}
@@ -352,6 +529,11 @@ func unitTestsValidateCreate() {
 	})
 
 	specPath := field.NewPath("spec")
+	probeActionsPath := field.NewPath("metadata", "annotations").Key(vmopapi.ReadinessProbeActionsAnnotation)
+	livenessProbePath := field.NewPath("metadata", "annotations").Key(vmopapi.LivenessProbeAnnotation)
+	importMoIDPath := field.NewPath("metadata", "annotations").Key(vmopapi.ImportVMMoIDAnnotation)
+	importInstanceUUIDPath := field.NewPath("metadata", "annotations").Key(vmopapi.ImportVMInstanceUUIDAnnotation)
+	volumePlacementPath := field.NewPath("metadata", "annotations").Key(vmopapi.VirtualMachineVolumePlacementAnnotation)
 	netIntPath := specPath.Child("networkInterfaces")
 	volPath := specPath.Child("volumes")
 	DescribeTable("create table", validateCreate,
@@ -408,13 +590,43 @@ func unitTestsValidateCreate() {
 
 		Entry("should fail when image is not compatible", createArgs{imageNonCompatible: true}, false,
 			field.Invalid(specPath.Child("imageName"), builder.DummyImageName, "VirtualMachineImage is not compatible with v1alpha1 or is not a TKG Image").Error(), nil),
+		Entry("should fail with the compatibility report reasons when image is not compatible", createArgs{imageNonCompatibleWithReport: true}, false,
+			field.Invalid(specPath.Child("imageName"), builder.DummyImageName, "VirtualMachineImage is not compatible with v1alpha1 or is not a TKG Image: "+
+				"reason-1; reason-2 (see the vmoperator.vmware.com/compatibility-report annotation of the VirtualMachineImage)").Error(), nil),
 		Entry("should allow despite incompatible image when VMOperatorImageSupportedCheckKey is disabled", createArgs{imageSupportCheckSkipAnnotation: true, imageNonCompatible: true}, true, nil, nil),
 		Entry("should allow when image is not compatible and VirtualMachineMetadataTransport is CloudInit", createArgs{imageNonCompatibleCloudInitTransport: true}, true, nil, nil),
+		Entry("should allow when image is not compatible and VirtualMachineMetadataTransport is Ignition", createArgs{imageNonCompatibleIgnitionTransport: true}, true, nil, nil),
+		Entry("should deny an unsupported metadata transport annotation", createArgs{unsupportedMetadataTransport: true}, false,
+			field.NotSupported(field.NewPath("metadata", "annotations").Key(vmopapi.VirtualMachineMetadataTransportAnnotation), "Other", []string{"Ignition"}).Error(), nil),
+		Entry("should deny the Ignition transport without the ExtraConfig transport", createArgs{ignitionTransportNotExtraConfig: true}, false,
+			field.NotSupported(specPath.Child("vmMetadata", "transport"), "OvfEnv", []string{"ExtraConfig"}).Error(), nil),
+		Entry("should deny an invalid Ignition config", createArgs{invalidIgnitionConfig: true}, false,
+			`storage.files[0].path "etc/hostname" must be a clean absolute path`, nil),
 
 		Entry("should fail when restricted network env is set in provider config map and TCP port in readiness probe is not 6443", createArgs{isRestrictedNetworkEnv: true, isRestrictedNetworkValidProbePort: false}, false,
 			field.NotSupported(specPath.Child("readinessProbe", "tcpSocket", "port"), 443, []string{"6443"}).Error(), nil),
 		Entry("should allow when restricted network env is set in provider config map and TCP port in readiness probe is 6443", createArgs{isRestrictedNetworkEnv: true, isRestrictedNetworkValidProbePort: true}, true, nil, nil),
 		Entry("should allow when restricted network env is not set in provider config map and TCP port in readiness probe is not 6443", createArgs{isNonRestrictedNetworkEnv: true, isRestrictedNetworkValidProbePort: false}, true, nil, nil),
+		Entry("should fail when restricted network env is set in provider config map and HTTP GET port in readiness probe is not 6443", createArgs{isHTTPGetReadinessProbe: true, isRestrictedNetworkEnv: true, isRestrictedNetworkValidProbePort: false}, false,
+			field.NotSupported(probeActionsPath.Child("httpGet", "port"), 443, []string{"6443"}).Error(), nil),
+		Entry("should allow when restricted network env is set in provider config map and HTTP GET port in readiness probe is 6443", createArgs{isHTTPGetReadinessProbe: true, isRestrictedNetworkEnv: true, isRestrictedNetworkValidProbePort: true}, true, nil, nil),
+		Entry("should allow when restricted network env is not set in provider config map and HTTP GET port in readiness probe is not 6443", createArgs{isHTTPGetReadinessProbe: true, isNonRestrictedNetworkEnv: true, isRestrictedNetworkValidProbePort: false}, true, nil, nil),
+		Entry("should allow exec readiness probe when restricted network env is set in provider config map", createArgs{execReadinessProbe: true}, true, nil, nil),
+		Entry("should fail when exec readiness probe is missing command and credentials", createArgs{invalidExecReadinessProbe: true}, false,
+			field.Required(probeActionsPath.Child("exec", "command"), "").Error(), nil),
+		Entry("should fail when HTTP GET readiness probe has invalid port and status range", createArgs{invalidHTTPGetReadinessProbe: true}, false,
+			field.Invalid(probeActionsPath.Child("httpGet", "port"), 0, "must be between 1 and 65535").Error(), nil),
+		Entry("should fail when readiness probe actions annotation is not valid JSON", createArgs{invalidReadinessProbeActions: true}, false,
+			probeActionsPath.String(), nil),
+		Entry("should fail when readiness probe actions annotation is set without readiness probe", createArgs{readinessProbeActionsWithoutProbe: true}, false,
+			field.Required(specPath.Child("readinessProbe"), "must be specified when the readiness probe actions annotation is set").Error(), nil),
+		Entry("should allow valid liveness probe", createArgs{validLivenessProbe: true}, true, nil, nil),
+		Entry("should fail when liveness probe action is not supported", createArgs{invalidLivenessProbeAction: true}, false,
+			field.NotSupported(livenessProbePath.Child("action"), "Reboot", []string{"EventOnly", "Reset", "PowerCycle"}).Error(), nil),
+		Entry("should fail when liveness probe has no actions", createArgs{livenessProbeNoActions: true}, false,
+			field.Forbidden(livenessProbePath, "must specify an action").Error(), nil),
+		Entry("should fail when readiness probe actions annotation is set with TCP readiness probe", createArgs{readinessProbeActionsWithTCPProbe: true}, false,
+			field.Forbidden(specPath.Child("readinessProbe"), "only one action can be specified").Error(), nil),
 
 		Entry("should allow when VM specifies no availability zone, there are availability zones, and WCP FaultDomains FSS is disabled", createArgs{isEmptyAvailabilityZone: true}, true, nil, nil),
 		Entry("should allow when VM specifies no availability zone, there are no availability zones, and WCP FaultDomains FSS is disabled", createArgs{isEmptyAvailabilityZone: true, isNoAvailabilityZones: true}, true, nil, nil),
@@ -433,6 +645,34 @@ func unitTestsValidateCreate() {
 		Entry("should deny when there are instance storage volumes with WCP Instance Storage FSS enabled and user is SSO user", createArgs{addInstanceStorageVolumes: true, isWCPInstanceStorageFSSEnabled: true}, false,
 			field.Forbidden(volPath, "adding or modifying instance storage volume(s) is not allowed").Error(), nil),
 		Entry("should allow when there are instance storage volumes with WCP Instance Storage FSS enabled and user is service user", createArgs{addInstanceStorageVolumes: true, isWCPInstanceStorageFSSEnabled: true, isServiceUser: true}, true, nil, nil),
+
+		Entry("should allow imported VM without class and image name", createArgs{importVM: true, isServiceUser: true}, true, nil, nil),
+		Entry("should deny import of a VM by a user that is not privileged", createArgs{importVM: true}, false,
+			field.Forbidden(importMoIDPath, "only privileged users can import VMs").Error(), nil),
+		Entry("should deny import of a VM that is managed by another VirtualMachine", createArgs{importVMManagedByOtherVM: true, isServiceUser: true}, false,
+			field.Forbidden(importMoIDPath, "VM is already managed by VirtualMachine other-namespace/other-vm").Error(), nil),
+		Entry("should deny import of a VM that is imported by another VirtualMachine", createArgs{importVMImportedByOtherVM: true, isServiceUser: true}, false,
+			field.Forbidden(importInstanceUUIDPath, "VM is already managed by VirtualMachine other-namespace/other-vm").Error(), nil),
+		Entry("should allow the restore annotation for a service user", createArgs{restoreVM: true, isServiceUser: true}, true, nil, nil),
+		Entry("should deny the restore annotation for a user that is not privileged", createArgs{restoreVM: true}, false,
+			field.Forbidden(field.NewPath("metadata", "annotations").Key(vmopapi.RestoreInstanceUUIDAnnotation), "only privileged users can set the annotation").Error(), nil),
+		Entry("should allow valid volume placement", createArgs{validVolumePlacement: true}, true, nil, nil),
+		Entry("should deny volume placement annotation that is not valid JSON", createArgs{invalidVolumePlacement: true}, false,
+			volumePlacementPath.String(), nil),
+		Entry("should deny volume placement of a volume that does not exist", createArgs{volumePlacementVolumeNotFound: true}, false,
+			field.Invalid(volumePlacementPath, "no-such-volume", "volume does not exist").Error(), nil),
+		Entry("should deny volume placement with a bus number out of range", createArgs{volumePlacementInvalidBusNumber: true}, false,
+			field.Invalid(volumePlacementPath, int32(4), "bus number must be between 0 and 3").Error(), nil),
+		Entry("should deny volume placement on the unit of the SCSI controller", createArgs{volumePlacementSCSIControllerUnit: true}, false,
+			field.Invalid(volumePlacementPath, int32(7), "unit number is not valid for a LsiLogic controller").Error(), nil),
+		Entry("should deny volume placements on the same unit", createArgs{volumePlacementUnitInUse: true}, false,
+			field.Invalid(volumePlacementPath, vsphereVolumeName+"-2",
+				"unit ParaVirtualSCSI 0:1 is already used by volume "+vsphereVolumeName).Error(), nil),
+		Entry("should deny volume placement of a PersistentVolumeClaim volume", createArgs{volumePlacementPVC: true}, false,
+			field.Invalid(volumePlacementPath, builder.DummyVolumeName, "placing PersistentVolumeClaim volumes is not supported, only vSphere volumes can be placed").Error(), nil),
+		Entry("should deny volume placements of different SCSI controller types on the same bus", createArgs{volumePlacementSCSIBusInUse: true}, false,
+			field.Invalid(volumePlacementPath, vsphereVolumeName+"-2",
+				"SCSI bus 1 is already used by a ParaVirtualSCSI controller").Error(), nil),
 	)
 }
 
@@ -446,6 +686,7 @@ func unitTestsValidateUpdate() {
 
 	type updateArgs struct {
 		changeClassName                 bool
+		removeClassName                 bool
 		changeImageName                 bool
 		changeStorageClass              bool
 		changeResourcePolicy            bool
@@ -454,6 +695,20 @@ func unitTestsValidateUpdate() {
 		isServiceUser                   bool
 		isWCPInstanceStorageFSSEnabled  bool
 		addInstanceStorageVolume        bool
+		setImportedVMClassName          bool
+		changeImportAnnotation          bool
+		relocateToZone                  bool
+		relocateToInvalidZone           bool
+		changeZoneNameToRelocateZone    bool
+		growVsphereVolume               bool
+		shrinkVsphereVolume             bool
+		vsphereVolumeDiskCapacity       string
+		changeVsphereVolumeDeviceKey    bool
+		powerOffVM                      bool
+		changeVolumePlacement           bool
+		replaceInstanceStorage          bool
+		withInstanceStorageVolumes      bool
+		changeRestoreAnnotation         bool
 	}
 
 	validateUpdate := func(args updateArgs, expectedAllowed bool, expectedReason string, expectedErr error) {
@@ -462,6 +717,9 @@ func unitTestsValidateUpdate() {
 		if args.changeClassName {
 			ctx.vm.Spec.ClassName += updateSuffix
 		}
+		if args.removeClassName {
+			ctx.vm.Spec.ClassName = ""
+		}
 		if args.changeImageName {
 			ctx.vm.Spec.ImageName += updateSuffix
 		}
@@ -489,6 +747,91 @@ func unitTestsValidateUpdate() {
 			instanceStorageVolumes[0].Name += updateSuffix
 			ctx.vm.Spec.Volumes = append(ctx.vm.Spec.Volumes, instanceStorageVolumes...)
 		}
+		if args.setImportedVMClassName {
+			ctx.oldVM.Annotations[vmopapi.ImportVMMoIDAnnotation] = importVMMoID
+			ctx.oldVM.Spec.ClassName = ""
+			ctx.vm.Annotations[vmopapi.ImportVMMoIDAnnotation] = importVMMoID
+		}
+		if args.changeImportAnnotation {
+			ctx.oldVM.Annotations[vmopapi.ImportVMMoIDAnnotation] = importVMMoID
+			ctx.vm.Annotations[vmopapi.ImportVMMoIDAnnotation] = importVMMoID + updateSuffix
+		}
+		if args.relocateToZone {
+			ctx.vm.Annotations[vmopapi.RelocateToZoneAnnotation] = builder.DummyAvailabilityZoneName
+		}
+		if args.relocateToInvalidZone {
+			ctx.vm.Annotations[vmopapi.RelocateToZoneAnnotation] = "invalid"
+		}
+		if args.changeZoneNameToRelocateZone {
+			ctx.vm.Labels[topology.KubernetesTopologyZoneLabelKey] += updateSuffix
+			ctx.oldVM.Annotations[vmopapi.RelocateToZoneAnnotation] = ctx.vm.Labels[topology.KubernetesTopologyZoneLabelKey]
+		}
+		if args.growVsphereVolume || args.shrinkVsphereVolume || args.changeVsphereVolumeDeviceKey {
+			deviceKey := 2000
+			vsphereVolume := vmopv1.VirtualMachineVolume{
+				Name: vsphereVolumeName,
+				VsphereVolume: &vmopv1.VsphereVolumeSource{
+					Capacity: corev1.ResourceList{
+						corev1.ResourceEphemeralStorage: resource.MustParse("4Gi"),
+					},
+					DeviceKey: &deviceKey,
+				},
+			}
+			ctx.oldVM.Spec.Volumes = append(ctx.oldVM.Spec.Volumes, vsphereVolume)
+			if args.vsphereVolumeDiskCapacity != "" {
+				diskCapacity := resource.MustParse(args.vsphereVolumeDiskCapacity)
+				details, err := json.Marshal(vmopapi.VirtualMachineStatusDetails{
+					Disks: []vmopapi.VirtualMachineDiskDetails{
+						{Label: "Hard disk 1", DeviceKey: int32(deviceKey), CapacityBytes: diskCapacity.Value()},
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				ctx.oldVM.Annotations[vmopapi.VirtualMachineStatusDetailsAnnotation] = string(details)
+				ctx.vm.Annotations[vmopapi.VirtualMachineStatusDetailsAnnotation] = string(details)
+			}
+
+			vsphereVolume = *vsphereVolume.DeepCopy()
+			switch {
+			case args.growVsphereVolume:
+				vsphereVolume.VsphereVolume.Capacity[corev1.ResourceEphemeralStorage] = resource.MustParse("8Gi")
+			case args.shrinkVsphereVolume:
+				vsphereVolume.VsphereVolume.Capacity[corev1.ResourceEphemeralStorage] = resource.MustParse("2Gi")
+			case args.changeVsphereVolumeDeviceKey:
+				otherDeviceKey := 2001
+				vsphereVolume.VsphereVolume.DeviceKey = &otherDeviceKey
+			}
+			ctx.vm.Spec.Volumes = append(ctx.vm.Spec.Volumes, vsphereVolume)
+		}
+		if args.changeVolumePlacement {
+			vsphereVolume := vmopv1.VirtualMachineVolume{
+				Name: vsphereVolumeName,
+				VsphereVolume: &vmopv1.VsphereVolumeSource{
+					Capacity: corev1.ResourceList{
+						corev1.ResourceEphemeralStorage: resource.MustParse("4Gi"),
+					},
+				},
+			}
+			ctx.oldVM.Spec.Volumes = append(ctx.oldVM.Spec.Volumes, vsphereVolume)
+			ctx.vm.Spec.Volumes = append(ctx.vm.Spec.Volumes, *vsphereVolume.DeepCopy())
+			ctx.oldVM.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] =
+				fmt.Sprintf(`[{"volumeName": %q, "unitNumber": 1}]`, vsphereVolumeName)
+			ctx.vm.Annotations[vmopapi.VirtualMachineVolumePlacementAnnotation] =
+				fmt.Sprintf(`[{"volumeName": %q, "unitNumber": 2}]`, vsphereVolumeName)
+		}
+		if args.withInstanceStorageVolumes {
+			ctx.oldVM.Spec.Volumes = append(ctx.oldVM.Spec.Volumes, builder.DummyInstanceStorageVirtualMachineVolumes()...)
+			ctx.vm.Spec.Volumes = append(ctx.vm.Spec.Volumes, builder.DummyInstanceStorageVirtualMachineVolumes()...)
+		}
+		if args.replaceInstanceStorage {
+			ctx.vm.Annotations[vmopapi.InstanceStorageReplaceAnnotation] = "host maintenance"
+		}
+		if args.changeRestoreAnnotation {
+			ctx.oldVM.Annotations[vmopapi.RestoreBiosUUIDAnnotation] = "restore-bios-uuid"
+			ctx.vm.Annotations[vmopapi.RestoreBiosUUIDAnnotation] = "other-bios-uuid"
+		}
+		if args.powerOffVM {
+			ctx.vm.Spec.PowerState = vmopv1.VirtualMachinePoweredOff
+		}
 		lib.IsInstanceStorageFSSEnabled = func() bool {
 			return args.isWCPInstanceStorageFSSEnabled
 		}
@@ -527,17 +870,48 @@ func unitTestsValidateUpdate() {
 	DescribeTable("update table", validateUpdate,
 		// Immutable Fields
 		Entry("should allow", updateArgs{}, true, nil, nil),
-		Entry("should deny class name change", updateArgs{changeClassName: true}, false, msg, nil),
+		Entry("should allow class name change", updateArgs{changeClassName: true}, true, nil, nil),
+		Entry("should deny removing the class name", updateArgs{removeClassName: true}, false,
+			field.Required(field.NewPath("spec", "className"), "").Error(), nil),
 		Entry("should deny image name change", updateArgs{changeImageName: true}, false, msg, nil),
 		Entry("should deny storageClass change", updateArgs{changeStorageClass: true}, false, msg, nil),
 		Entry("should deny resourcePolicy change", updateArgs{changeResourcePolicy: true}, false, msg, nil),
 		Entry("should deny zone name change", updateArgs{changeZoneName: true}, false, msg, nil),
+		Entry("should allow relocating to a valid zone", updateArgs{relocateToZone: true}, true, nil, nil),
+		Entry("should deny relocating to an invalid zone", updateArgs{relocateToInvalidZone: true}, false, nil, nil),
+		Entry("should allow zone name change to the zone the VM is relocated to for a service user", updateArgs{changeZoneNameToRelocateZone: true, isServiceUser: true}, true, nil, nil),
+		Entry("should deny zone name change to the zone the VM is relocated to for a user that is not privileged", updateArgs{changeZoneNameToRelocateZone: true}, false, msg, nil),
+		Entry("should allow setting the class name of an imported VM", updateArgs{setImportedVMClassName: true}, true, nil, nil),
+		Entry("should deny import annotation change", updateArgs{changeImportAnnotation: true}, false, msg, nil),
+		Entry("should deny restore annotation change for a user that is not privileged", updateArgs{changeRestoreAnnotation: true}, false,
+			field.Forbidden(field.NewPath("metadata", "annotations").Key(vmopapi.RestoreBiosUUIDAnnotation), "only privileged users can set the annotation").Error(), nil),
+		Entry("should allow restore annotation change for a service user", updateArgs{changeRestoreAnnotation: true, isServiceUser: true}, true, nil, nil),
 		Entry("should deny instance storage volume name change, when WCP Instance Storage FSS is enabled and user type is SSO user", updateArgs{isWCPInstanceStorageFSSEnabled: true, changeInstanceStorageVolumeName: true}, false,
 			field.Forbidden(volumesPath, "adding or modifying instance storage volume(s) is not allowed").Error(), nil),
 		Entry("should deny adding new instance storage volume, when WCP Instance Storage FSS is enabled and user type is SSO user", updateArgs{isWCPInstanceStorageFSSEnabled: true, addInstanceStorageVolume: true}, false,
 			field.Forbidden(volumesPath, "adding or modifying instance storage volume(s) is not allowed").Error(), nil),
 		Entry("should allow adding new instance storage volume, when WCP Instance Storage FSS is enabled and user type is service user", updateArgs{isWCPInstanceStorageFSSEnabled: true, addInstanceStorageVolume: true, isServiceUser: true}, true, nil, nil),
 		Entry("should allow instance storage volume name change, when WCP Instance Storage FSS is enabled and user type is service user", updateArgs{isWCPInstanceStorageFSSEnabled: true, changeInstanceStorageVolumeName: true, isServiceUser: true}, true, nil, nil),
+
+		Entry("should allow growing a vSphere volume when the VM is powered on", updateArgs{growVsphereVolume: true}, true, nil, nil),
+		Entry("should deny shrinking a vSphere volume", updateArgs{shrinkVsphereVolume: true}, false,
+			field.Invalid(volumesPath.Index(1).Child("vsphereVolume", "capacity", "ephemeral-storage"), "2Gi", "value must not be less than the current capacity 4Gi").Error(), nil),
+		Entry("should allow reverting a vSphere volume to the capacity of its disk", updateArgs{shrinkVsphereVolume: true, vsphereVolumeDiskCapacity: "2Gi"}, true, nil, nil),
+		Entry("should deny shrinking a vSphere volume below the capacity of its disk", updateArgs{shrinkVsphereVolume: true, vsphereVolumeDiskCapacity: "3Gi"}, false,
+			field.Invalid(volumesPath.Index(1).Child("vsphereVolume", "capacity", "ephemeral-storage"), "2Gi", "value must not be less than the current capacity 3Gi").Error(), nil),
+		Entry("should deny shrinking a vSphere volume when the VM is powered off", updateArgs{shrinkVsphereVolume: true, powerOffVM: true}, false,
+			field.Invalid(volumesPath.Index(1).Child("vsphereVolume", "capacity", "ephemeral-storage"), "2Gi", "value must not be less than the current capacity 4Gi").Error(), nil),
+		Entry("should deny changing a vSphere volume when the VM is powered on", updateArgs{changeVsphereVolumeDeviceKey: true}, false,
+			field.Forbidden(volumesPath.Key("VsphereVolume"), "updates to this filed is not allowed when VM power is on").Error(), nil),
+		Entry("should allow changing a vSphere volume when the VM is powered off", updateArgs{changeVsphereVolumeDeviceKey: true, powerOffVM: true}, true, nil, nil),
+		Entry("should deny changing the volume placement when the VM is powered on", updateArgs{changeVolumePlacement: true}, false,
+			field.Forbidden(field.NewPath("metadata", "annotations").Key(vmopapi.VirtualMachineVolumePlacementAnnotation), "updates to this filed is not allowed when VM power is on").Error(), nil),
+		Entry("should allow changing the volume placement when the VM is powered off", updateArgs{changeVolumePlacement: true, powerOffVM: true}, true, nil, nil),
+		Entry("should allow replacing the instance storage volumes when the VM is powered off", updateArgs{isWCPInstanceStorageFSSEnabled: true, withInstanceStorageVolumes: true, replaceInstanceStorage: true, powerOffVM: true}, true, nil, nil),
+		Entry("should deny replacing the instance storage volumes when the VM is powered on", updateArgs{isWCPInstanceStorageFSSEnabled: true, withInstanceStorageVolumes: true, replaceInstanceStorage: true}, false,
+			field.Forbidden(field.NewPath("metadata", "annotations").Key(vmopapi.InstanceStorageReplaceAnnotation), "instance storage volumes can only be replaced when the VM is powered off").Error(), nil),
+		Entry("should deny replacing the instance storage volumes when the VM does not have any", updateArgs{isWCPInstanceStorageFSSEnabled: true, replaceInstanceStorage: true, powerOffVM: true}, false,
+			field.Forbidden(field.NewPath("metadata", "annotations").Key(vmopapi.InstanceStorageReplaceAnnotation), "VM does not have instance storage volumes").Error(), nil),
 	)
 
 	When("the update is performed while object deletion", func() {
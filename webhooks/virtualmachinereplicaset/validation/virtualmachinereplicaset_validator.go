@@ -0,0 +1,139 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package validation
+
+import (
+	"net/http"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmgr "sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/pkg/errors"
+
+	vmopv1 "github.com/acharyasreej/vm-operator-api/api/v1alpha1"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/builder"
+	"github.com/acharyasreej/vm-operator/pkg/context"
+	vmvalidation "github.com/acharyasreej/vm-operator/webhooks/virtualmachine/validation"
+)
+
+const (
+	webHookName = "default"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/default-validate-vmoperator-vmware-com-v1alpha1-virtualmachinereplicaset,mutating=false,failurePolicy=fail,groups=vmoperator.vmware.com,resources=virtualmachinereplicasets,versions=v1alpha1,name=default.validating.virtualmachinereplicaset.vmoperator.vmware.com,sideEffects=None,admissionReviewVersions=v1;v1beta1
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachinereplicasets,verbs=get;list
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachinereplicasets/status,verbs=get
+
+// AddToManager adds the webhook to the provided manager.
+func AddToManager(ctx *context.ControllerManagerContext, mgr ctrlmgr.Manager) error {
+	hook, err := builder.NewValidatingWebhook(ctx, mgr, webHookName, NewValidator(mgr.GetClient()))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create VirtualMachineReplicaSet validation webhook")
+	}
+	mgr.GetWebhookServer().Register(hook.Path, hook)
+
+	return nil
+}
+
+// NewValidator returns the package's Validator.
+func NewValidator(client client.Client) builder.Validator {
+	return validator{
+		converter:   runtime.DefaultUnstructuredConverter,
+		vmValidator: vmvalidation.NewValidator(client),
+	}
+}
+
+// validator validates the template of a VirtualMachineReplicaSet with the VirtualMachine validator. The
+// VirtualMachines of a replica set are created by the VM Operator service account, so the checks of the
+// VirtualMachine validator that depend on the user, like the ones for instance storage volumes, are done for
+// the user that creates or updates the replica set instead.
+type validator struct {
+	converter   runtime.UnstructuredConverter
+	vmValidator builder.Validator
+}
+
+func (v validator) For() schema.GroupVersionKind {
+	return vmopapi.GroupVersion.WithKind(reflect.TypeOf(vmopapi.VirtualMachineReplicaSet{}).Name())
+}
+
+func (v validator) ValidateCreate(ctx *context.WebhookRequestContext) admission.Response {
+	rs, err := v.rsFromUnstructured(ctx.Obj)
+	if err != nil {
+		return webhook.Errored(http.StatusBadRequest, err)
+	}
+
+	return v.validateTemplate(ctx, rs)
+}
+
+func (v validator) ValidateDelete(*context.WebhookRequestContext) admission.Response {
+	return admission.Allowed("")
+}
+
+// ValidateUpdate validates the template when it is changed. Other updates, like scaling the replica set,
+// are allowed.
+func (v validator) ValidateUpdate(ctx *context.WebhookRequestContext) admission.Response {
+	rs, err := v.rsFromUnstructured(ctx.Obj)
+	if err != nil {
+		return webhook.Errored(http.StatusBadRequest, err)
+	}
+
+	oldRS, err := v.rsFromUnstructured(ctx.OldObj)
+	if err != nil {
+		return webhook.Errored(http.StatusBadRequest, err)
+	}
+
+	if equality.Semantic.DeepEqual(rs.Spec.Template, oldRS.Spec.Template) {
+		return admission.Allowed("")
+	}
+
+	return v.validateTemplate(ctx, rs)
+}
+
+// validateTemplate validates the template as the creation of a VirtualMachine of the replica set.
+func (v validator) validateTemplate(ctx *context.WebhookRequestContext, rs *vmopapi.VirtualMachineReplicaSet) admission.Response {
+	vm := &vmopv1.VirtualMachine{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: vmopv1.SchemeGroupVersion.String(),
+			Kind:       reflect.TypeOf(vmopv1.VirtualMachine{}).Name(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        rs.Name,
+			Namespace:   rs.Namespace,
+			Labels:      rs.Spec.Template.ObjectMeta.Labels,
+			Annotations: rs.Spec.Template.ObjectMeta.Annotations,
+		},
+		Spec: rs.Spec.Template.Spec,
+	}
+
+	obj, err := v.converter.ToUnstructured(vm)
+	if err != nil {
+		return webhook.Errored(http.StatusBadRequest, err)
+	}
+
+	vmCtx := *ctx
+	vmCtx.Obj = &unstructured.Unstructured{Object: obj}
+	vmCtx.OldObj = nil
+
+	return v.vmValidator.ValidateCreate(&vmCtx)
+}
+
+// rsFromUnstructured returns the VirtualMachineReplicaSet from the unstructured object.
+func (v validator) rsFromUnstructured(obj runtime.Unstructured) (*vmopapi.VirtualMachineReplicaSet, error) {
+	rs := &vmopapi.VirtualMachineReplicaSet{}
+	if err := v.converter.FromUnstructured(obj.UnstructuredContent(), rs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2021 VMware, Inc. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package validation_test
+
+import (
+	"os"
+
+	v1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	vmopapi "github.com/acharyasreej/vm-operator/api/v1alpha1"
+	"github.com/acharyasreej/vm-operator/pkg/lib"
+	"github.com/acharyasreej/vm-operator/test/builder"
+)
+
+func unitTests() {
+	Describe("Invoking ValidateCreate", unitTestsValidateCreate)
+	Describe("Invoking ValidateUpdate", unitTestsValidateUpdate)
+	Describe("Invoking ValidateDelete", unitTestsValidateDelete)
+}
+
+type unitValidatingWebhookContext struct {
+	builder.UnitTestContextForValidatingWebhook
+	rs    *vmopapi.VirtualMachineReplicaSet
+	oldRS *vmopapi.VirtualMachineReplicaSet
+}
+
+func dummyVirtualMachineReplicaSet() *vmopapi.VirtualMachineReplicaSet {
+	vm := builder.DummyVirtualMachine()
+	return &vmopapi.VirtualMachineReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "dummy-rs",
+			Namespace: "dummy-ns",
+		},
+		Spec: vmopapi.VirtualMachineReplicaSetSpec{
+			Replicas: pointer.Int32Ptr(2),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "dummy"},
+			},
+			Template: vmopapi.VirtualMachineTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"app": "dummy"},
+					Annotations: map[string]string{},
+				},
+				Spec: vm.Spec,
+			},
+		},
+	}
+}
+
+func newUnitTestContextForValidatingWebhook(isUpdate bool) *unitValidatingWebhookContext {
+	rs := dummyVirtualMachineReplicaSet()
+	obj, err := builder.ToUnstructured(rs)
+	Expect(err).ToNot(HaveOccurred())
+
+	var oldRS *vmopapi.VirtualMachineReplicaSet
+	var oldObj *unstructured.Unstructured
+
+	if isUpdate {
+		oldRS = rs.DeepCopy()
+		oldObj, err = builder.ToUnstructured(oldRS)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	vmImage := builder.DummyVirtualMachineImage(rs.Spec.Template.Spec.ImageName)
+
+	ctx := &unitValidatingWebhookContext{
+		UnitTestContextForValidatingWebhook: *suite.NewUnitTestContextForValidatingWebhook(obj, oldObj, vmImage),
+		rs:                                  rs,
+		oldRS:                               oldRS,
+	}
+	ctx.WebhookRequestContext.UserInfo = &v1.UserInfo{
+		Username: "sso:devUser1@vsphere.local",
+	}
+
+	return ctx
+}
+
+func setServiceUser(ctx *unitValidatingWebhookContext) {
+	Expect(os.Setenv("POD_SERVICE_ACCOUNT_NAME", "default")).To(Succeed())
+	Expect(os.Setenv("POD_NAMESPACE", "vmware-system-vmop")).To(Succeed())
+	ctx.WebhookRequestContext.UserInfo.Username = "system:serviceaccount:vmware-system-vmop:default"
+}
+
+func unsetServiceUser() {
+	if _, ok := os.LookupEnv("POD_SERVICE_ACCOUNT_NAME"); ok {
+		Expect(os.Unsetenv("POD_SERVICE_ACCOUNT_NAME")).To(Succeed())
+	}
+	if _, ok := os.LookupEnv("POD_NAMESPACE"); ok {
+		Expect(os.Unsetenv("POD_NAMESPACE")).To(Succeed())
+	}
+}
+
+func unitTestsValidateCreate() {
+	var (
+		ctx                       *unitValidatingWebhookContext
+		oldInstanceStorageFSSFunc func() bool
+	)
+
+	type createArgs struct {
+		noClassName               bool
+		addInstanceStorageVolumes bool
+		isServiceUser             bool
+	}
+
+	validateCreate := func(args createArgs, expectedAllowed bool, expectedReason string, expectedErr error) {
+		var err error
+
+		if args.noClassName {
+			ctx.rs.Spec.Template.Spec.ClassName = ""
+		}
+		if args.addInstanceStorageVolumes {
+			ctx.rs.Spec.Template.Spec.Volumes = append(ctx.rs.Spec.Template.Spec.Volumes,
+				builder.DummyInstanceStorageVirtualMachineVolumes()...)
+		}
+		if args.isServiceUser {
+			setServiceUser(ctx)
+		}
+
+		ctx.WebhookRequestContext.Obj, err = builder.ToUnstructured(ctx.rs)
+		Expect(err).ToNot(HaveOccurred())
+
+		response := ctx.ValidateCreate(&ctx.WebhookRequestContext)
+		Expect(response.Allowed).To(Equal(expectedAllowed))
+		if expectedReason != "" {
+			Expect(string(response.Result.Reason)).To(ContainSubstring(expectedReason))
+		}
+		if expectedErr != nil {
+			Expect(response.Result.Message).To(Equal(expectedErr.Error()))
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = newUnitTestContextForValidatingWebhook(false)
+		oldInstanceStorageFSSFunc = lib.IsInstanceStorageFSSEnabled
+		lib.IsInstanceStorageFSSEnabled = func() bool {
+			return true
+		}
+	})
+	AfterEach(func() {
+		ctx = nil
+		lib.IsInstanceStorageFSSEnabled = oldInstanceStorageFSSFunc
+		unsetServiceUser()
+	})
+
+	DescribeTable("create table", validateCreate,
+		Entry("should allow valid", createArgs{}, true, nil, nil),
+		Entry("should deny template without class name", createArgs{noClassName: true}, false,
+			"spec.className: Required value", nil),
+		Entry("should deny template with instance storage volumes when user is SSO user", createArgs{addInstanceStorageVolumes: true}, false,
+			"adding or modifying instance storage volume(s) is not allowed", nil),
+		Entry("should allow template with instance storage volumes when user is service user",
+			createArgs{addInstanceStorageVolumes: true, isServiceUser: true}, true, nil, nil),
+	)
+}
+
+func unitTestsValidateUpdate() {
+	var (
+		ctx                       *unitValidatingWebhookContext
+		oldInstanceStorageFSSFunc func() bool
+	)
+
+	type updateArgs struct {
+		scale                     bool
+		addInstanceStorageVolumes bool
+	}
+
+	validateUpdate := func(args updateArgs, expectedAllowed bool, expectedReason string, expectedErr error) {
+		var err error
+
+		if args.scale {
+			ctx.rs.Spec.Replicas = pointer.Int32Ptr(3)
+		}
+		if args.addInstanceStorageVolumes {
+			ctx.rs.Spec.Template.Spec.Volumes = append(ctx.rs.Spec.Template.Spec.Volumes,
+				builder.DummyInstanceStorageVirtualMachineVolumes()...)
+		}
+
+		ctx.WebhookRequestContext.Obj, err = builder.ToUnstructured(ctx.rs)
+		Expect(err).ToNot(HaveOccurred())
+
+		response := ctx.ValidateUpdate(&ctx.WebhookRequestContext)
+		Expect(response.Allowed).To(Equal(expectedAllowed))
+		if expectedReason != "" {
+			Expect(string(response.Result.Reason)).To(ContainSubstring(expectedReason))
+		}
+		if expectedErr != nil {
+			Expect(response.Result.Message).To(Equal(expectedErr.Error()))
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = newUnitTestContextForValidatingWebhook(true)
+		oldInstanceStorageFSSFunc = lib.IsInstanceStorageFSSEnabled
+		lib.IsInstanceStorageFSSEnabled = func() bool {
+			return true
+		}
+	})
+	AfterEach(func() {
+		ctx = nil
+		lib.IsInstanceStorageFSSEnabled = oldInstanceStorageFSSFunc
+	})
+
+	DescribeTable("update table", validateUpdate,
+		Entry("should allow", updateArgs{}, true, nil, nil),
+		Entry("should allow scaling", updateArgs{scale: true}, true, nil, nil),
+		Entry("should deny adding instance storage volumes to the template when user is SSO user", updateArgs{addInstanceStorageVolumes: true}, false,
+			"adding or modifying instance storage volume(s) is not allowed", nil),
+	)
+}
+
+func unitTestsValidateDelete() {
+	var (
+		ctx      *unitValidatingWebhookContext
+		response admission.Response
+	)
+
+	BeforeEach(func() {
+		ctx = newUnitTestContextForValidatingWebhook(false)
+	})
+	AfterEach(func() {
+		ctx = nil
+	})
+
+	When("the delete is performed", func() {
+		JustBeforeEach(func() {
+			response = ctx.ValidateDelete(&ctx.WebhookRequestContext)
+		})
+
+		It("should allow the request", func() {
+			Expect(response.Allowed).To(BeTrue())
+			Expect(response.Result).ToNot(BeNil())
+		})
+	})
+}
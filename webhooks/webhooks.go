@@ -8,8 +8,10 @@ import (
 	ctrlmgr "sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/acharyasreej/vm-operator/pkg/context"
+	"github.com/acharyasreej/vm-operator/webhooks/subscribedcontentlibrary"
 	"github.com/acharyasreej/vm-operator/webhooks/virtualmachine"
 	"github.com/acharyasreej/vm-operator/webhooks/virtualmachineclass"
+	"github.com/acharyasreej/vm-operator/webhooks/virtualmachinereplicaset"
 	"github.com/acharyasreej/vm-operator/webhooks/virtualmachineservice"
 	"github.com/acharyasreej/vm-operator/webhooks/virtualmachinesetresourcepolicy"
 )
@@ -22,11 +24,17 @@ func AddToManager(ctx *context.ControllerManagerContext, mgr ctrlmgr.Manager) er
 	if err := virtualmachineclass.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize VirtualMachineClass webhooks")
 	}
+	if err := virtualmachinereplicaset.AddToManager(ctx, mgr); err != nil {
+		return errors.Wrap(err, "failed to initialize VirtualMachineReplicaSet webhooks")
+	}
 	if err := virtualmachineservice.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize VirtualMachineService webhooks")
 	}
 	if err := virtualmachinesetresourcepolicy.AddToManager(ctx, mgr); err != nil {
 		return errors.Wrap(err, "failed to initialize VirtualMachineSetResourcePolicy webhooks")
 	}
+	if err := subscribedcontentlibrary.AddToManager(ctx, mgr); err != nil {
+		return errors.Wrap(err, "failed to initialize SubscribedContentLibrary webhooks")
+	}
 	return nil
 }